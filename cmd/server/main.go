@@ -2,8 +2,13 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/labstack/echo/v4"
 	"github.com/nsvirk/moneybotsapi/internal/api"
@@ -11,7 +16,9 @@ import (
 	"github.com/nsvirk/moneybotsapi/internal/config"
 	"github.com/nsvirk/moneybotsapi/internal/repository"
 	"github.com/nsvirk/moneybotsapi/internal/service"
+	"github.com/nsvirk/moneybotsapi/pkg/utils/response"
 	"github.com/nsvirk/moneybotsapi/pkg/utils/zaplogger"
+	"github.com/redis/go-redis/v9"
 )
 
 func main() {
@@ -24,6 +31,12 @@ func main() {
 	// Print the configuration
 	fmt.Println(cfg.String())
 
+	// In-memory storage runs without Postgres and Redis, for development and tests only
+	if cfg.IsMemoryStorage() {
+		runInMemory(cfg)
+		return
+	}
+
 	// Connect to Postgres
 	db, err := repository.ConnectPostgres(cfg)
 	if err != nil {
@@ -45,19 +58,55 @@ func main() {
 	// Setup logger
 	defer zaplogger.Sync()
 	zaplogger.SetLogLevel(cfg.ServerLogLevel)
+	zaplogger.SetDBCircuit(cfg.LogDBMaxFailures, cfg.LogDBCooldown)
+	zaplogger.SetDBWriter(cfg.LogQueueSize, cfg.LogFlushInterval)
+	service.SetAuthFallback(cfg.AuthDBFallback, cfg.CacheTTL(config.CacheAuthSessions))
+	service.SetJWTAuth(cfg.JWTSecret, cfg.JWTTTL)
+
+	// Send internal error details to clients only in development
+	response.SetVerboseErrors(cfg.IsDevelopment())
+
+	// Sync the freeze limits along with the instruments
+	service.SetFreezeLimitsURL(cfg.FreezeLimitsURL)
+
+	// Sync the corporate actions with the corporate actions job
+	service.SetCorporateActionsURL(cfg.CorpActionsURL)
+
+	// Serve the quotes out of Redis, kept warm by the ticker
+	service.SetQuoteRedisCache(redisClient, cfg.QuoteRedisTTL)
 
 	// startUpMessage
 	zaplogger.Info(cfg.APIName + " - " + cfg.APIVersion + " initialized")
 	zaplogger.Info("Postgres initialized")
 	zaplogger.Info("Redis initialized")
 
+	// Check the database health in the background until shutdown
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	repository.StartDBHealthCheck(ctx, db, cfg.DBHealthInterval)
+
+	// Refresh the breadth of the configured indices in the background
+	service.StartIndexBreadthRefresh(ctx, cfg, db)
+
+	// Persist the ticks of the ticker in the background, the queued ticks are written on shutdown
+	service.StartTickWriter(cfg, db)
+	defer service.StopTickWriter()
+
+	// Aggregate the persisted ticks into the intraday candles in the background
+	service.StartCandleAggregation(ctx, cfg, db)
+
 	// Create a new Echo instance
 	e := echo.New()
 	e.HideBanner = true
 	e.HidePort = true
+	e.HTTPErrorHandler = response.HTTPErrorHandler(e)
+	e.Pre(middleware.HeadMiddleware())
+	middleware.SetupIPExtractor(e, cfg)
 
 	// Setup middleware
 	middleware.SetupLoggerMiddleware(e)
+	e.Use(middleware.QueryBudgetMiddleware(cfg.QueryBudget))
+	e.Use(middleware.CompressMiddleware(cfg))
 
 	// Setup routes
 	api.SetupRoutes(e, cfg, db, redisClient)
@@ -68,7 +117,7 @@ func main() {
 	cronService.Start()
 
 	// Setup and start ticks
-	publishService := service.NewPublishService(db, redisClient, cfg.PostgresDsn)
+	publishService := service.NewPublishService(db, redisClient, repository.PostgresDSN(cfg))
 	go publishService.PublishTicksToRedisChannel()
 
 	// Start the server
@@ -76,13 +125,91 @@ func main() {
 
 }
 
+// runInMemory serves the API on the in-memory storage with mock quotes
+// The cron jobs and the tick publisher need Kite and Postgres, so they are not started
+func runInMemory(cfg *config.Config) {
+	db, err := repository.ConnectMemory(cfg)
+	if err != nil {
+		log.Fatalf("Failed to open in-memory storage: %v", err)
+	}
+
+	// Redis is connected lazily, only the ticker endpoints use it
+	redisClient := redis.NewClient(&redis.Options{
+		Addr:     fmt.Sprintf("%s:%s", cfg.RedisHost, cfg.RedisPort),
+		Password: cfg.RedisPassword,
+	})
+
+	err = zaplogger.InitLogger(db)
+	if err != nil {
+		log.Fatalf("Failed to initialize logger: %v", err)
+	}
+	defer zaplogger.Sync()
+	zaplogger.SetLogLevel(cfg.ServerLogLevel)
+	zaplogger.SetDBCircuit(cfg.LogDBMaxFailures, cfg.LogDBCooldown)
+	zaplogger.SetDBWriter(cfg.LogQueueSize, cfg.LogFlushInterval)
+	service.SetAuthFallback(cfg.AuthDBFallback, cfg.CacheTTL(config.CacheAuthSessions))
+	service.SetJWTAuth(cfg.JWTSecret, cfg.JWTTTL)
+
+	response.SetVerboseErrors(cfg.IsDevelopment())
+	service.SetOfflineSessions(true)
+	// the seeded quotes stand in for the first refresh
+	service.MarkQuotesRefreshed()
+
+	zaplogger.Info(cfg.APIName + " - " + cfg.APIVersion + " initialized")
+	zaplogger.Info("In-memory storage initialized")
+
+	e := echo.New()
+	e.HideBanner = true
+	e.HidePort = true
+	e.HTTPErrorHandler = response.HTTPErrorHandler(e)
+	e.Pre(middleware.HeadMiddleware())
+	middleware.SetupIPExtractor(e, cfg)
+
+	middleware.SetupLoggerMiddleware(e)
+	e.Use(middleware.QueryBudgetMiddleware(cfg.QueryBudget))
+	e.Use(middleware.CompressMiddleware(cfg))
+
+	api.SetupRoutes(e, cfg, db, redisClient)
+
+	startServer(e, cfg)
+}
+
 // startServer starts the Echo server on the specified port
 func startServer(e *echo.Echo, cfg *config.Config) {
 	port := cfg.ServerPort
 	if port == "" {
 		port = "3007"
 	}
+	applyServerTimeouts(e, cfg)
 	zaplogger.Info("SERVER STARTED ON PORT " + port)
-	e.Logger.Fatal(e.Start(":" + port))
+	go func() {
+		if err := e.Start(":" + port); err != nil && err != http.ErrServerClosed {
+			e.Logger.Fatal(err)
+		}
+	}()
+
+	// On shutdown the open streams are drained for up to ShutdownDrain, see StreamService.Drain
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
+	<-quit
+	zaplogger.Info("SERVER SHUTTING DOWN", zaplogger.Fields{"drain": cfg.ShutdownDrain.String()})
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownDrain)
+	defer cancel()
+	if err := e.Shutdown(ctx); err != nil {
+		zaplogger.Error("Server shutdown did not complete", zaplogger.Fields{"error": err.Error()})
+	}
+	// the sockets are hijacked, so the shutdown does not wait for their reconnect message and close frame
+	if !service.WaitSocketsClosed(ctx) {
+		zaplogger.Warn("Socket streams still open after the shutdown drain", zaplogger.Fields{"drain": cfg.ShutdownDrain.String()})
+	}
+}
 
+// applyServerTimeouts sets the configured timeouts on the http server
+// Streaming endpoints clear their write deadline, see StreamService
+func applyServerTimeouts(e *echo.Echo, cfg *config.Config) {
+	e.Server.ReadTimeout = cfg.HTTPReadTimeout
+	e.Server.WriteTimeout = cfg.HTTPWriteTimeout
+	e.Server.IdleTimeout = cfg.HTTPIdleTimeout
+	e.Server.ReadHeaderTimeout = cfg.HTTPHeaderTimeout
 }
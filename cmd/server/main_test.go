@@ -0,0 +1,36 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/nsvirk/moneybotsapi/internal/config"
+)
+
+func TestApplyServerTimeouts(t *testing.T) {
+	cfg := &config.Config{
+		HTTPReadTimeout:   15 * time.Second,
+		HTTPWriteTimeout:  30 * time.Second,
+		HTTPIdleTimeout:   time.Minute,
+		HTTPHeaderTimeout: 5 * time.Second,
+	}
+	e := echo.New()
+	applyServerTimeouts(e, cfg)
+
+	tests := []struct {
+		name string
+		got  time.Duration
+		want time.Duration
+	}{
+		{"ReadTimeout", e.Server.ReadTimeout, cfg.HTTPReadTimeout},
+		{"WriteTimeout", e.Server.WriteTimeout, cfg.HTTPWriteTimeout},
+		{"IdleTimeout", e.Server.IdleTimeout, cfg.HTTPIdleTimeout},
+		{"ReadHeaderTimeout", e.Server.ReadHeaderTimeout, cfg.HTTPHeaderTimeout},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s = %v, want %v", tt.name, tt.got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,191 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/nsvirk/moneybotsapi/internal/models"
+	"github.com/nsvirk/moneybotsapi/internal/repository"
+	"github.com/nsvirk/moneybotsapi/internal/service"
+)
+
+func TestAdminConfig(t *testing.T) {
+	e, db := memoryServer(t)
+	adminAuth := repository.MemoryDevUserID + ":" + repository.MemoryDevEnctoken
+	userAuth := testSession(t, db, "US0001", models.RoleUser)
+
+	tests := []struct {
+		name          string
+		authorization string
+		wantStatus    int
+	}{
+		{name: "no authorization", wantStatus: http.StatusUnauthorized},
+		{name: "user", authorization: userAuth, wantStatus: http.StatusForbidden},
+		{name: "admin", authorization: adminAuth, wantStatus: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(e, http.MethodGet, APIV1Prefix+"/admin/config", tt.authorization)
+			if rec.Code != tt.wantStatus {
+				t.Errorf("GET /admin/config status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+		})
+	}
+
+	t.Run("masked config", func(t *testing.T) {
+		rec := serve(e, http.MethodGet, APIV1Prefix+"/admin/config", adminAuth)
+		var body struct {
+			Data struct {
+				Config  map[string]string      `json:"config"`
+				Derived map[string]interface{} `json:"derived"`
+			} `json:"data"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Fatalf("failed to decode the config: %v: %s", err, rec.Body.String())
+		}
+		// the memory server runs in development, which reveals 3 characters of the secrets
+		for field, want := range map[string]string{
+			"KitetickerPassword": "tes*******",
+			"TelegramBotToken":   "tes*******",
+			"Storage":            "memory",
+		} {
+			if got := body.Data.Config[field]; got != want {
+				t.Errorf("config %s = %q, want %q", field, got, want)
+			}
+		}
+		for _, key := range []string{"log_level", "pg_pool_max_open", "pg_pool_open"} {
+			if _, ok := body.Data.Derived[key]; !ok {
+				t.Errorf("derived config is missing %s: %v", key, body.Data.Derived)
+			}
+		}
+	})
+}
+
+func TestAdminDiagnostics(t *testing.T) {
+	e, db := memoryServer(t)
+	userAuth := testSession(t, db, "US0002", models.RoleUser)
+
+	tests := []struct {
+		name          string
+		authorization string
+		wantStatus    int
+	}{
+		{name: "no authorization", wantStatus: http.StatusUnauthorized},
+		{name: "user", authorization: userAuth, wantStatus: http.StatusForbidden},
+		{name: "admin", authorization: devAuthorization, wantStatus: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(e, http.MethodGet, APIV1Prefix+"/admin/diagnostics", tt.authorization)
+			if rec.Code != tt.wantStatus {
+				t.Errorf("GET /admin/diagnostics status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+		})
+	}
+
+	t.Run("bundle", func(t *testing.T) {
+		rec := serve(e, http.MethodGet, APIV1Prefix+"/admin/diagnostics", devAuthorization)
+		var body struct {
+			Data map[string]json.RawMessage `json:"data"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Fatalf("failed to decode the diagnostics: %v: %s", err, rec.Body.String())
+		}
+		for _, section := range []string{"build", "config", "database", "instruments", "caches", "streams", "feed", "errors", "log_sink", "log_queue", "generated_at"} {
+			if _, ok := body.Data[section]; !ok {
+				t.Errorf("diagnostics are missing the %s section", section)
+			}
+		}
+
+		var config map[string]string
+		if err := json.Unmarshal(body.Data["config"], &config); err != nil {
+			t.Fatalf("failed to decode the diagnostics config: %v", err)
+		}
+		for _, field := range []string{"KitetickerPassword", "KitetickerTotpSecret", "TelegramBotToken", "RedisPassword", "PostgresDsn"} {
+			if got := config[field]; got != "tes*******" {
+				t.Errorf("diagnostics config %s = %q, want it masked", field, got)
+			}
+		}
+	})
+}
+
+func TestAdminUpstreamRaw(t *testing.T) {
+	e, db := memoryServer(t)
+	userAuth := testSession(t, db, "US0003", models.RoleUser)
+
+	tests := []struct {
+		name          string
+		query         string
+		authorization string
+		wantStatus    int
+	}{
+		{name: "no authorization", query: "token=408065", wantStatus: http.StatusUnauthorized},
+		{name: "user", query: "token=408065", authorization: userAuth, wantStatus: http.StatusForbidden},
+		{name: "no token", authorization: devAuthorization, wantStatus: http.StatusBadRequest},
+		{name: "invalid token", query: "token=INFY", authorization: devAuthorization, wantStatus: http.StatusBadRequest},
+		{name: "no tick since startup", query: "token=408065", authorization: devAuthorization, wantStatus: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(e, http.MethodGet, APIV1Prefix+"/admin/upstream/raw?"+tt.query, tt.authorization)
+			if rec.Code != tt.wantStatus {
+				t.Errorf("GET /admin/upstream/raw?%s status = %d, want %d: %s", tt.query, rec.Code, tt.wantStatus, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestAdminStats(t *testing.T) {
+	e, db := memoryServer(t)
+	userAuth := testSession(t, db, "US0004", models.RoleUser)
+	if rec := serve(e, http.MethodGet, APIV1Prefix+"/admin/stats", userAuth); rec.Code != http.StatusForbidden {
+		t.Errorf("GET /admin/stats of a user status = %d, want %d", rec.Code, http.StatusForbidden)
+	}
+
+	stats := func() service.ProcessStats {
+		t.Helper()
+		rec := serve(e, http.MethodGet, APIV1Prefix+"/admin/stats", devAuthorization)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("GET /admin/stats status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body.String())
+		}
+		var body struct {
+			Data service.ProcessStats `json:"data"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Fatalf("failed to decode the stats: %v: %s", err, rec.Body.String())
+		}
+		return body.Data
+	}
+
+	before := stats()
+	if rec := serve(e, http.MethodGet, APIV1Prefix+"/quote?i=NSE:INFY&i=NSE:TCS", devAuthorization); rec.Code != http.StatusOK {
+		t.Fatalf("GET /quote status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body.String())
+	}
+	if rec := serve(e, http.MethodGet, APIV1Prefix+"/quote?i=NSE:INFY", ""); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("GET /quote without authorization status = %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+	after := stats()
+
+	// the first stats request is counted once it is served
+	tests := []struct {
+		name string
+		got  uint64
+		want uint64
+	}{
+		{"requests", after.Requests - before.Requests, 3},
+		{"2xx requests", after.RequestsByClass["2xx"] - before.RequestsByClass["2xx"], 2},
+		{"4xx requests", after.RequestsByClass["4xx"] - before.RequestsByClass["4xx"], 1},
+		{"quotes served", after.QuotesServed - before.QuotesServed, 2},
+		{"datasource calls", after.DatasourceCalls - before.DatasourceCalls, 1},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s increment = %d, want %d", tt.name, tt.got, tt.want)
+		}
+	}
+	if after.Goroutines <= 0 || after.Memory.HeapAllocBytes == 0 {
+		t.Errorf("stats goroutines, heap = %d, %d, want the runtime summary", after.Goroutines, after.Memory.HeapAllocBytes)
+	}
+}
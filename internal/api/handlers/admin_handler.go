@@ -0,0 +1,406 @@
+// Package handlers contains the handlers for the API
+package handlers
+
+import (
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/nsvirk/moneybotsapi/internal/config"
+	"github.com/nsvirk/moneybotsapi/internal/models"
+	"github.com/nsvirk/moneybotsapi/internal/repository"
+	"github.com/nsvirk/moneybotsapi/internal/service"
+	"github.com/nsvirk/moneybotsapi/pkg/utils/response"
+	"github.com/nsvirk/moneybotsapi/pkg/utils/zaplogger"
+	"gorm.io/gorm"
+)
+
+// AdminHandler is the handler for the admin API
+type AdminHandler struct {
+	cfg            *config.Config
+	DB             *gorm.DB
+	AlertService   *service.AlertService
+	SessionService *service.SessionService
+	Capturer       *service.RequestCapturer
+	Diagnostics    *service.DiagnosticsService
+}
+
+// NewAdminHandler creates a new handler for the admin API
+func NewAdminHandler(cfg *config.Config, db *gorm.DB, capturer *service.RequestCapturer, streamService *service.StreamService) *AdminHandler {
+	return &AdminHandler{
+		cfg:            cfg,
+		DB:             db,
+		AlertService:   service.NewAlertService(cfg),
+		SessionService: service.NewSessionService(db),
+		Capturer:       capturer,
+		Diagnostics:    service.NewDiagnosticsService(cfg, db, streamService),
+	}
+}
+
+// ConfigResponseData is the response data for the GetConfig endpoint
+type ConfigResponseData struct {
+	Config  map[string]string      `json:"config"`
+	Derived map[string]interface{} `json:"derived"`
+}
+
+// GetConfig returns the effective masked configuration
+func (h *AdminHandler) GetConfig(c echo.Context) error {
+	derived := map[string]interface{}{
+		"log_level": zaplogger.GetLogLevel(),
+	}
+
+	cacheTTLs := make(map[string]string)
+	for _, category := range []string{config.CacheQuoteNegative, config.CacheQuoteMovers, config.CacheInstruments, config.CacheIndices} {
+		cacheTTLs[category] = h.cfg.CacheTTL(category).String()
+	}
+	derived["cache_ttls"] = cacheTTLs
+
+	sqlDB, err := h.DB.DB()
+	if err != nil {
+		return response.ErrorResponse(c, http.StatusInternalServerError, "ServerException", err.Error())
+	}
+	stats := sqlDB.Stats()
+	derived["pg_pool_max_open"] = stats.MaxOpenConnections
+	derived["pg_pool_open"] = stats.OpenConnections
+	derived["pg_pool_in_use"] = stats.InUse
+	derived["pg_pool_idle"] = stats.Idle
+
+	return response.SuccessResponse(c, ConfigResponseData{
+		Config:  h.cfg.Masked(),
+		Derived: derived,
+	})
+}
+
+// GetDiagnostics returns the diagnostics bundle for triaging support tickets
+func (h *AdminHandler) GetDiagnostics(c echo.Context) error {
+	return response.SuccessResponse(c, h.Diagnostics.GetDiagnostics())
+}
+
+// GetStats returns the process level counters accumulated since startup
+func (h *AdminHandler) GetStats(c echo.Context) error {
+	return response.SuccessResponse(c, service.GetProcessStats())
+}
+
+// GetJobs returns the status of the cron jobs, their schedule, next run and last run
+func (h *AdminHandler) GetJobs(c echo.Context) error {
+	return response.SuccessResponse(c, service.GetCronJobStatuses())
+}
+
+// GetUpstreamRaw returns the last tick of the `token` query param as received from the ticker, untransformed
+func (h *AdminHandler) GetUpstreamRaw(c echo.Context) error {
+	tokenStr := c.QueryParam("token")
+	if tokenStr == "" {
+		return response.ErrorResponse(c, http.StatusBadRequest, "InputException", "`token` is required")
+	}
+	token, err := models.ParseInstrumentToken(tokenStr)
+	if err != nil {
+		return response.ErrorResponse(c, http.StatusBadRequest, "InputException", err.Error())
+	}
+	rawTick, ok := service.GetRawTick(token)
+	if !ok {
+		return response.ErrorResponse(c, http.StatusNotFound, "DataNotFound", fmt.Sprintf("No tick received for `token` %d since startup", token))
+	}
+	return response.SuccessResponse(c, rawTick)
+}
+
+// GetRecentErrors returns the most recent error level log events
+func (h *AdminHandler) GetRecentErrors(c echo.Context) error {
+	return response.SuccessResponse(c, zaplogger.RecentErrors())
+}
+
+// TestAlertRequest is the request body for the TestAlert endpoint
+type TestAlertRequest struct {
+	Message string `json:"message"`
+}
+
+// TestAlert sends a synthetic alert through every configured channel
+func (h *AdminHandler) TestAlert(c echo.Context) error {
+	var req TestAlertRequest
+	if err := c.Bind(&req); err != nil {
+		return response.ErrorResponse(c, http.StatusBadRequest, "InputException", "Invalid request body")
+	}
+	if req.Message == "" {
+		req.Message = "Test alert from " + h.cfg.APIName
+	}
+	results := h.AlertService.Send(c.Request().Context(), req.Message)
+	return response.SuccessResponse(c, results)
+}
+
+// LogsResponseData is the response data for the GetLogs endpoint
+type LogsResponseData struct {
+	Logs       []zaplogger.LogModel `json:"logs"`
+	NextCursor string               `json:"next_cursor,omitempty"`
+	HasMore    bool                 `json:"has_more"`
+}
+
+// logsExportMax is the max number of logs exported as CSV, a narrower time range exports the rest
+const logsExportMax = 100000
+
+// logsExportPage is the number of logs read at a time for the CSV export
+const logsExportPage = 1000
+
+// GetLogs returns a page of app logs using keyset pagination on the log id, with `?after=<id>`
+// The logs are filtered by `level` (comma separated), `module` (the caller prefix, like `service/ticker_service.go`),
+// `from` and `to` (RFC3339, or `YYYY-MM-DD` for the whole day) and `q` (text in the message or the fields)
+// With `format=csv` all the matching logs after the cursor are sent as a CSV, up to logsExportMax
+func (h *AdminHandler) GetLogs(c echo.Context) error {
+	limit := 100
+	if limitStr := c.QueryParam("limit"); limitStr != "" {
+		l, err := strconv.Atoi(limitStr)
+		if err != nil || l <= 0 || l > 1000 {
+			return response.ErrorResponse(c, http.StatusBadRequest, "InputException", "Invalid `limit` value, must be between 1 and 1000")
+		}
+		limit = l
+	}
+
+	var after uint
+	if afterStr := c.QueryParam("after"); afterStr != "" {
+		cursor, err := parseLogCursor(afterStr)
+		if err != nil {
+			return response.ErrorResponse(c, http.StatusBadRequest, "InputException", err.Error())
+		}
+		after = cursor
+	}
+
+	filter, err := parseLogFilter(c)
+	if err != nil {
+		return response.ErrorResponse(c, http.StatusBadRequest, "InputException", err.Error())
+	}
+
+	logRepo := repository.NewLogRepository(h.DB.WithContext(c.Request().Context()))
+	switch c.QueryParam("format") {
+	case "", "json":
+	case "csv":
+		return exportLogsCSV(c, logRepo, after, filter)
+	default:
+		return response.ErrorResponse(c, http.StatusBadRequest, "InputException", "Invalid `format` value, must be `json` or `csv`")
+	}
+
+	logs, err := logRepo.GetLogsAfter(after, filter, limit)
+	if err != nil {
+		return response.ErrorResponse(c, http.StatusInternalServerError, "ServerException", err.Error())
+	}
+
+	// the cursor is returned at the end too, so a client can resume from it to tail the new logs
+	data := LogsResponseData{Logs: logs, NextCursor: c.QueryParam("after"), HasMore: len(logs) == limit}
+	if len(logs) > 0 {
+		last := logs[len(logs)-1]
+		data.NextCursor = strconv.FormatUint(uint64(last.ID), 10)
+	}
+	return response.SuccessResponse(c, data)
+}
+
+// exportLogsCSV streams the logs matching the filter after the cursor as a CSV, reading them a page at a time
+// An error after the first page is written can only end the CSV early, so it is logged
+func exportLogsCSV(c echo.Context, logRepo *repository.LogRepository, after uint, filter repository.LogFilter) error {
+	logs, err := logRepo.GetLogsAfter(after, filter, logsExportPage)
+	if err != nil {
+		return response.ErrorResponse(c, http.StatusInternalServerError, "ServerException", err.Error())
+	}
+
+	header := c.Response().Header()
+	header.Set(echo.HeaderContentType, "text/csv; charset=utf-8")
+	header.Set(echo.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="logs-%s.csv"`, time.Now().In(service.MarketLocation).Format("20060102-150405")))
+	c.Response().WriteHeader(http.StatusOK)
+
+	writer := csv.NewWriter(c.Response())
+	if err := writer.Write([]string{"id", "timestamp", "level", "caller", "message", "fields"}); err != nil {
+		return err
+	}
+	exported := 0
+	for len(logs) > 0 && exported < logsExportMax {
+		for _, log := range logs[:min(len(logs), logsExportMax-exported)] {
+			record := []string{
+				strconv.FormatUint(uint64(log.ID), 10),
+				log.Timestamp.Format(time.RFC3339Nano),
+				log.Level,
+				log.Caller,
+				log.Message,
+				log.Fields,
+			}
+			if err := writer.Write(record); err != nil {
+				return err
+			}
+			exported++
+		}
+		writer.Flush()
+		if err := writer.Error(); err != nil {
+			return err
+		}
+		if len(logs) < logsExportPage {
+			break
+		}
+		last := logs[len(logs)-1]
+		if logs, err = logRepo.GetLogsAfter(last.ID, filter, logsExportPage); err != nil {
+			zaplogger.Error("Failed to export logs", zaplogger.Fields{"exported": exported, "error": err.Error()})
+			return nil
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// parseLogFilter parses the `level`, `module`, `from`, `to` and `q` filters of the logs
+func parseLogFilter(c echo.Context) (repository.LogFilter, error) {
+	filter := repository.LogFilter{
+		Module: strings.TrimSpace(c.QueryParam("module")),
+		Search: strings.TrimSpace(c.QueryParam("q")),
+	}
+	for _, level := range strings.Split(c.QueryParam("level"), ",") {
+		if level = strings.ToUpper(strings.TrimSpace(level)); level != "" {
+			filter.Levels = append(filter.Levels, level)
+		}
+	}
+
+	var err error
+	if fromStr := c.QueryParam("from"); fromStr != "" {
+		if filter.From, _, err = parseLogTime(fromStr); err != nil {
+			return filter, fmt.Errorf("invalid `from` value, must be RFC3339 or `YYYY-MM-DD`")
+		}
+	}
+	if toStr := c.QueryParam("to"); toStr != "" {
+		var isDate bool
+		if filter.To, isDate, err = parseLogTime(toStr); err != nil {
+			return filter, fmt.Errorf("invalid `to` value, must be RFC3339 or `YYYY-MM-DD`")
+		}
+		// the logs are filtered before `to`, so a date includes its day
+		if isDate {
+			filter.To = filter.To.AddDate(0, 0, 1)
+		}
+	}
+	if !filter.From.IsZero() && !filter.To.IsZero() && !filter.From.Before(filter.To) {
+		return filter, fmt.Errorf("`from` must be before `to`")
+	}
+	return filter, nil
+}
+
+// parseLogTime parses an RFC3339 time, or a `YYYY-MM-DD` date as its start in the market time zone
+func parseLogTime(s string) (t time.Time, isDate bool, err error) {
+	if t, err = time.Parse(time.RFC3339Nano, s); err == nil {
+		return t, false, nil
+	}
+	t, err = time.ParseInLocation("2006-01-02", s, service.MarketLocation)
+	return t, err == nil, err
+}
+
+// parseLogCursor parses an `<id>` cursor, the `<timestamp>,<id>` cursors of the earlier pages are still accepted
+func parseLogCursor(s string) (uint, error) {
+	if _, idStr, ok := strings.Cut(s, ","); ok {
+		s = idStr
+	}
+	id, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid `after` value, must be the id of a log")
+	}
+	return uint(id), nil
+}
+
+// GetUsers returns the users of all sessions
+func (h *AdminHandler) GetUsers(c echo.Context) error {
+	users, err := h.SessionService.GetSessionUsers()
+	if err != nil {
+		return response.ErrorResponse(c, http.StatusInternalServerError, "ServerException", err.Error())
+	}
+	return response.SuccessResponse(c, users)
+}
+
+// DisableUser disables a user
+func (h *AdminHandler) DisableUser(c echo.Context) error {
+	return h.updateUser(c, func(userID string) (int64, error) {
+		return h.SessionService.SetUserDisabled(userID, true)
+	})
+}
+
+// EnableUser enables a disabled user
+func (h *AdminHandler) EnableUser(c echo.Context) error {
+	return h.updateUser(c, func(userID string) (int64, error) {
+		return h.SessionService.SetUserDisabled(userID, false)
+	})
+}
+
+// ResetUser clears the stored password and enctoken of a user, forcing a fresh login
+func (h *AdminHandler) ResetUser(c echo.Context) error {
+	return h.updateUser(c, h.SessionService.ResetUser)
+}
+
+// UserRoleRequest is the request body for the SetUserRole endpoint
+type UserRoleRequest struct {
+	Role string `json:"role"`
+}
+
+// SetUserRole sets the role of a user, `admin`, `user` or `readonly`
+func (h *AdminHandler) SetUserRole(c echo.Context) error {
+	var req UserRoleRequest
+	if err := c.Bind(&req); err != nil {
+		return response.ErrorResponse(c, http.StatusBadRequest, "InputException", "Invalid request body")
+	}
+	if !models.IsValidRole(req.Role) {
+		return response.ErrorResponse(c, http.StatusBadRequest, "InputException", "Invalid `role` value, must be `admin`, `user` or `readonly`")
+	}
+	return h.updateUser(c, func(userID string) (int64, error) {
+		return h.SessionService.SetUserRole(userID, req.Role)
+	})
+}
+
+// updateUser applies the update to the `user_id` path param user
+func (h *AdminHandler) updateUser(c echo.Context, update func(userID string) (int64, error)) error {
+	userID := c.Param("user_id")
+	if userID == "" {
+		return response.ErrorResponse(c, http.StatusBadRequest, "InputException", "`user_id` is required")
+	}
+	updated, err := update(userID)
+	if err != nil {
+		return response.ErrorResponse(c, http.StatusInternalServerError, "ServerException", err.Error())
+	}
+	if updated == 0 {
+		return response.ErrorResponse(c, http.StatusNotFound, "DataNotFound", fmt.Sprintf("`user_id` %s not found", userID))
+	}
+	return response.SuccessResponse(c, map[string]string{"user_id": userID})
+}
+
+// CaptureRequest is the request body for the SetCapture endpoint
+type CaptureRequest struct {
+	Enabled bool `json:"enabled"`
+}
+
+// CaptureResponseData is the response data for the capture endpoints
+type CaptureResponseData struct {
+	Available bool   `json:"available"`
+	Enabled   bool   `json:"enabled"`
+	Dir       string `json:"dir"`
+	Routes    string `json:"routes"`
+	Percent   int    `json:"sample_percent"`
+}
+
+// GetCapture returns the request capture status
+func (h *AdminHandler) GetCapture(c echo.Context) error {
+	return response.SuccessResponse(c, h.captureStatus())
+}
+
+// SetCapture turns the request capture on or off
+func (h *AdminHandler) SetCapture(c echo.Context) error {
+	var req CaptureRequest
+	if err := c.Bind(&req); err != nil {
+		return response.ErrorResponse(c, http.StatusBadRequest, "InputException", "Invalid request body")
+	}
+	if err := h.Capturer.SetEnabled(req.Enabled); err != nil {
+		return response.ErrorResponse(c, http.StatusBadRequest, "InputException", err.Error())
+	}
+	zaplogger.Info("Request capture updated", zaplogger.Fields{"enabled": req.Enabled, "user_id": c.Get("user_id")})
+	return response.SuccessResponse(c, h.captureStatus())
+}
+
+// captureStatus returns the request capture status with its configuration
+func (h *AdminHandler) captureStatus() CaptureResponseData {
+	return CaptureResponseData{
+		Available: h.Capturer.Available(),
+		Enabled:   h.Capturer.Enabled(),
+		Dir:       h.cfg.CaptureDir,
+		Routes:    h.cfg.CaptureRoutes,
+		Percent:   h.cfg.CapturePercent,
+	}
+}
@@ -24,29 +24,47 @@ func NewCronHandler(e *echo.Echo, cfg *config.Config, db *gorm.DB, redisClient *
 
 // UpdateInstruments updates the instruments
 func (h *CronHandler) UpdateInstruments(c echo.Context) error {
-	h.CronService.ApiInstrumentsUpdateJob()
+	if err := h.CronService.ApiInstrumentsUpdateJob(); err != nil {
+		return response.NewError(response.ErrInternal, err.Error())
+	}
 	return response.SuccessResponse(c, "Instruments updated")
 }
 
+// UpdateCorporateActions updates the corporate actions
+func (h *CronHandler) UpdateCorporateActions(c echo.Context) error {
+	if err := h.CronService.ApiCorporateActionsUpdateJob(); err != nil {
+		return response.NewError(response.ErrInternal, err.Error())
+	}
+	return response.SuccessResponse(c, "Corporate actions updated")
+}
+
 func (h *CronHandler) UpdateIndices(c echo.Context) error {
-	h.CronService.ApiIndicesUpdateJob()
+	if err := h.CronService.ApiIndicesUpdateJob(); err != nil {
+		return response.NewError(response.ErrInternal, err.Error())
+	}
 	return response.SuccessResponse(c, "Indices updated")
 }
 
 // TickerInstrumentsUpdateJob updates the ticker instruments
 func (h *CronHandler) TickerInstrumentsUpdateJob(c echo.Context) error {
-	h.CronService.TickerInstrumentsUpdateJob()
+	if err := h.CronService.TickerInstrumentsUpdateJob(); err != nil {
+		return response.NewError(response.ErrInternal, err.Error())
+	}
 	return response.SuccessResponse(c, "Ticker instruments updated")
 }
 
 // TickerStartJob starts the ticker
 func (h *CronHandler) TickerStartJob(c echo.Context) error {
-	h.CronService.TickerStartJob()
+	if err := h.CronService.TickerStartJob(); err != nil {
+		return response.NewError(response.ErrInternal, err.Error())
+	}
 	return response.SuccessResponse(c, "Ticker started")
 }
 
 // TickerStopJob stops the ticker
 func (h *CronHandler) TickerStopJob(c echo.Context) error {
-	h.CronService.TickerStopJob()
+	if err := h.CronService.TickerStopJob(); err != nil {
+		return response.NewError(response.ErrInternal, err.Error())
+	}
 	return response.SuccessResponse(c, "Ticker stopped")
 }
@@ -0,0 +1,40 @@
+// Package handlers contains the handlers for the API
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/nsvirk/moneybotsapi/internal/service"
+	"github.com/nsvirk/moneybotsapi/pkg/utils/response"
+)
+
+// HealthHandler is the handler for the health API
+type HealthHandler struct {
+	service          *service.HealthService
+	readinessService *service.ReadinessService
+}
+
+// NewHealthHandler creates a new handler for the health API
+func NewHealthHandler(service *service.HealthService, readinessService *service.ReadinessService) *HealthHandler {
+	return &HealthHandler{service: service, readinessService: readinessService}
+}
+
+// GetHealth returns the health status of the API, for the liveness probes
+// It does not fail on the dependencies, which are checked by GetReady
+func (h *HealthHandler) GetHealth(c echo.Context) error {
+	return response.SuccessResponse(c, h.service.GetHealth())
+}
+
+// GetReady returns the readiness of the API with the checks of its dependencies, for the readiness probes
+// It is a 503, still with the checks, until the API is ready to serve quotes and while a required dependency is down
+func (h *HealthHandler) GetReady(c echo.Context) error {
+	readiness, err := h.readinessService.CheckReadiness(c.Request().Context())
+	if err != nil {
+		return response.ErrorResponse(c, http.StatusInternalServerError, "ServerException", err.Error())
+	}
+	if !readiness.Ready {
+		return response.ErrorDataResponse(c, http.StatusServiceUnavailable, "ServiceUnavailableException", readiness.Reason(), readiness)
+	}
+	return response.SuccessResponse(c, readiness)
+}
@@ -0,0 +1,191 @@
+// Package handlers contains the handlers for the API
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/nsvirk/moneybotsapi/internal/api/middleware"
+	"github.com/nsvirk/moneybotsapi/internal/config"
+	"github.com/nsvirk/moneybotsapi/internal/models"
+	"github.com/nsvirk/moneybotsapi/internal/service"
+	"github.com/nsvirk/moneybotsapi/pkg/utils/response"
+	"gorm.io/gorm"
+)
+
+// historicalDefaultRange is the range of the candles when `from` is not given
+const historicalDefaultRange = 365 * 24 * time.Hour
+
+// HistoricalHandler is the handler for the historical candles
+type HistoricalHandler struct {
+	candleService *service.CandleService
+}
+
+// NewHistoricalHandler creates a new HistoricalHandler
+func NewHistoricalHandler(cfg *config.Config, db *gorm.DB) *HistoricalHandler {
+	return &HistoricalHandler{candleService: service.NewCandleService(cfg, db)}
+}
+
+// HistoricalResponseData is the response data for the GetHistoricalCandles endpoint
+type HistoricalResponseData struct {
+	InstrumentToken uint32               `json:"instrument_token"`
+	Interval        string               `json:"interval"`
+	Adjusted        bool                 `json:"adjusted"`
+	Candles         []models.CandleModel `json:"candles"`
+}
+
+// GetHistoricalCandles gets the day candles of the `:token` instrument from `from` to `to`, both `YYYY-MM-DD`
+// `to` defaults to today and `from` to a year before `to`, `adjusted=true` back adjusts for corporate actions
+func (h *HistoricalHandler) GetHistoricalCandles(c echo.Context) error {
+	token, err := models.ParseInstrumentToken(c.Param("token"))
+	if err != nil {
+		return response.NewError(response.ErrValidation, err.Error())
+	}
+
+	to := time.Now().In(service.MarketLocation)
+	to = time.Date(to.Year(), to.Month(), to.Day(), 0, 0, 0, 0, service.MarketLocation)
+	if toStr := c.QueryParam("to"); toStr != "" {
+		if to, err = time.ParseInLocation("2006-01-02", toStr, service.MarketLocation); err != nil {
+			return response.NewError(response.ErrValidation, "Invalid `to` value, must be `YYYY-MM-DD`")
+		}
+	}
+	from := to.Add(-historicalDefaultRange)
+	if fromStr := c.QueryParam("from"); fromStr != "" {
+		if from, err = time.ParseInLocation("2006-01-02", fromStr, service.MarketLocation); err != nil {
+			return response.NewError(response.ErrValidation, "Invalid `from` value, must be `YYYY-MM-DD`")
+		}
+	}
+	if from.After(to) {
+		return response.NewError(response.ErrValidation, "`from` must not be after `to`")
+	}
+
+	adjusted := false
+	if adjustedStr := c.QueryParam("adjusted"); adjustedStr != "" {
+		if adjusted, err = strconv.ParseBool(adjustedStr); err != nil {
+			return response.NewError(response.ErrValidation, "Invalid `adjusted` value, must be `true` or `false`")
+		}
+	}
+
+	candles, err := h.candleService.GetHistoricalCandles(token, from, to, adjusted)
+	if err != nil {
+		return response.NewError(response.ErrInternal, err.Error())
+	}
+	return response.SuccessResponse(c, HistoricalResponseData{
+		InstrumentToken: token,
+		Interval:        models.CandleIntervalDay,
+		Adjusted:        adjusted,
+		Candles:         candles,
+	})
+}
+
+// GetIntervalCandles gets the `interval` candles of the `token` instrument on the days from `from` to `to`,
+// both `YYYY-MM-DD`, out of the historical API, `interval` defaults to day and `to` to today
+// The range is limited to the max days of a historical API request for the interval
+func (h *HistoricalHandler) GetIntervalCandles(c echo.Context) error {
+	_, enctoken, err := middleware.GetUserIdEnctokenFromEchoContext(c)
+	if err != nil {
+		return response.NewError(response.ErrUnauthorized, err.Error())
+	}
+
+	token, err := models.ParseInstrumentToken(c.QueryParam("token"))
+	if err != nil {
+		return response.NewError(response.ErrValidation, err.Error())
+	}
+
+	interval := c.QueryParam("interval")
+	if interval == "" {
+		interval = models.CandleIntervalDay
+	}
+	maxDays, ok := models.CandleIntervalMaxDays[interval]
+	if !ok {
+		return response.NewError(response.ErrValidation, "Invalid `interval` value, must be one of minute, 3minute, 5minute, 10minute, 15minute, 30minute, 60minute or day")
+	}
+
+	to := time.Now().In(service.MarketLocation)
+	to = time.Date(to.Year(), to.Month(), to.Day(), 0, 0, 0, 0, service.MarketLocation)
+	if toStr := c.QueryParam("to"); toStr != "" {
+		if to, err = time.ParseInLocation("2006-01-02", toStr, service.MarketLocation); err != nil {
+			return response.NewError(response.ErrValidation, "Invalid `to` value, must be `YYYY-MM-DD`")
+		}
+	}
+	fromStr := c.QueryParam("from")
+	if fromStr == "" {
+		return response.NewError(response.ErrValidation, "`from` is required")
+	}
+	from, err := time.ParseInLocation("2006-01-02", fromStr, service.MarketLocation)
+	if err != nil {
+		return response.NewError(response.ErrValidation, "Invalid `from` value, must be `YYYY-MM-DD`")
+	}
+	if from.After(to) {
+		return response.NewError(response.ErrValidation, "`from` must not be after `to`")
+	}
+	if to.After(from.AddDate(0, 0, maxDays-1)) {
+		return response.NewError(response.ErrValidation, fmt.Sprintf("The range of `%s` candles must not be over %d days", interval, maxDays))
+	}
+
+	candles, err := h.candleService.GetIntervalCandles(enctoken, token, interval, from, to)
+	if err != nil {
+		if errors.Is(err, service.ErrHistoricalUpstream) {
+			return response.NewError(response.ErrUpstream, err.Error())
+		}
+		return response.NewError(response.ErrInternal, err.Error())
+	}
+	if candles == nil {
+		return response.NewError(response.ErrNotFound, fmt.Sprintf("Instrument token %d not found", token))
+	}
+	return response.SuccessResponse(c, HistoricalResponseData{
+		InstrumentToken: token,
+		Interval:        interval,
+		Candles:         candles,
+	})
+}
+
+// IntradayCandlesResponseData is the response data for the GetIntradayCandles endpoint
+type IntradayCandlesResponseData struct {
+	Interval string                          `json:"interval"`
+	Date     string                          `json:"date"`
+	Candles  map[string][]models.CandleModel `json:"candles"`
+}
+
+// GetIntradayCandles gets the `interval` candles aggregated from the stored ticks of the `i` instruments
+// on `date`, `YYYY-MM-DD`, `interval` defaults to minute and `date` to today
+func (h *HistoricalHandler) GetIntradayCandles(c echo.Context) error {
+	instruments := c.QueryParams()["i"]
+	if len(instruments) == 0 {
+		return response.NewError(response.ErrValidation, "No instruments specified")
+	}
+
+	interval := c.QueryParam("interval")
+	if interval == "" {
+		interval = models.CandleIntervalMinute
+	}
+	if !slices.Contains(service.IntradayCandleIntervals, interval) {
+		return response.NewError(response.ErrValidation, "Invalid `interval` value, must be one of minute, 5minute, 15minute or 60minute")
+	}
+
+	date := time.Now().In(service.MarketLocation)
+	date = time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, service.MarketLocation)
+	if dateStr := c.QueryParam("date"); dateStr != "" {
+		var err error
+		if date, err = time.ParseInLocation("2006-01-02", dateStr, service.MarketLocation); err != nil {
+			return response.NewError(response.ErrValidation, "Invalid `date` value, must be `YYYY-MM-DD`")
+		}
+	}
+
+	candles, err := h.candleService.GetIntradayCandles(instruments, interval, date)
+	if err != nil {
+		return response.NewError(response.ErrInternal, err.Error())
+	}
+	if len(candles) == 0 {
+		return response.NewError(response.ErrNotFound, fmt.Sprintf("No candles found for instruments: %v", instruments))
+	}
+	return response.SuccessResponse(c, IntradayCandlesResponseData{
+		Interval: interval,
+		Date:     date.Format("2006-01-02"),
+		Candles:  candles,
+	})
+}
@@ -4,9 +4,11 @@ package handlers
 import (
 	"fmt"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/labstack/echo/v4"
+	"github.com/nsvirk/moneybotsapi/internal/config"
 	"github.com/nsvirk/moneybotsapi/internal/models"
 	"github.com/nsvirk/moneybotsapi/internal/service"
 	"github.com/nsvirk/moneybotsapi/pkg/utils/response"
@@ -21,13 +23,15 @@ type UpdateIndexResponseData struct {
 
 // IndexHandler is the handler for the indices
 type IndexHandler struct {
+	cfg               *config.Config
 	DB                *gorm.DB
 	InstrumentService *service.InstrumentService
 	IndexService      *service.IndexService
 }
 
-func NewIndexHandler(db *gorm.DB) *IndexHandler {
+func NewIndexHandler(cfg *config.Config, db *gorm.DB) *IndexHandler {
 	return &IndexHandler{
+		cfg:               cfg,
 		DB:                db,
 		InstrumentService: service.NewInstrumentService(db),
 		IndexService:      service.NewIndexService(db),
@@ -47,6 +51,24 @@ func (h *IndexHandler) UpdateIndices(c echo.Context) error {
 	return response.SuccessResponse(c, responseData)
 }
 
+// SyncIndicesResponseData is the response data for the SyncIndices endpoint
+type SyncIndicesResponseData struct {
+	Timestamp string `json:"timestamp"`
+	*service.IndicesSyncReport
+}
+
+// SyncIndices downloads the index constituent lists and replaces the indices, even if they were synced today
+func (h *IndexHandler) SyncIndices(c echo.Context) error {
+	report, err := h.IndexService.SyncIndices(true)
+	if err != nil {
+		return response.ErrorResponse(c, http.StatusInternalServerError, "ServerException", err.Error())
+	}
+	return response.SuccessResponse(c, SyncIndicesResponseData{
+		Timestamp:         time.Now().Format("2006-01-02 15:04:05"),
+		IndicesSyncReport: report,
+	})
+}
+
 // GetAllIndices returns a list of all indices
 func (h *IndexHandler) GetAllIndices(c echo.Context) error {
 	indices, err := h.IndexService.GetAllIndices()
@@ -60,6 +82,50 @@ func (h *IndexHandler) GetAllIndices(c echo.Context) error {
 	return response.SuccessResponse(c, result)
 }
 
+// ListIndices returns the names of the indices with their exchange and number of constituents
+func (h *IndexHandler) ListIndices(c echo.Context) error {
+	indices, err := h.IndexService.GetIndexSummaries()
+	if err != nil {
+		return response.ErrorResponse(c, http.StatusInternalServerError, "ServerException", err.Error())
+	}
+	return response.SuccessResponse(c, indices)
+}
+
+// GetIndexConstituents returns the constituents of the `:name` index with their instrument tokens and weights,
+// the largest weights first, the name is case insensitive like `NIFTY 50`
+func (h *IndexHandler) GetIndexConstituents(c echo.Context) error {
+	name := strings.ToUpper(strings.TrimSpace(c.Param("name")))
+	if name == "" || name == ":NAME" {
+		return response.ErrorResponse(c, http.StatusBadRequest, "InputException", "`name` is required")
+	}
+	constituents, err := h.IndexService.GetIndexConstituents(name)
+	if err != nil {
+		return response.ErrorResponse(c, http.StatusInternalServerError, "ServerException", fmt.Sprintf("Error fetching constituents for index %s: %v", name, err))
+	}
+	if len(constituents) == 0 {
+		return response.ErrorResponse(c, http.StatusNotFound, "DataNotFound", fmt.Sprintf("Index %s not found", name))
+	}
+	return response.SuccessResponse(c, constituents)
+}
+
+// GetInstrumentIndices returns the indices the `:symbol` instrument is a constituent of, with its weight in each
+// The symbol is `EXCHANGE:TRADINGSYMBOL`, or a tradingsymbol of NSE
+func (h *IndexHandler) GetInstrumentIndices(c echo.Context) error {
+	symbol := strings.ToUpper(strings.TrimSpace(c.Param("symbol")))
+	exchange, tradingsymbol, ok := strings.Cut(symbol, ":")
+	if !ok {
+		exchange, tradingsymbol = "NSE", symbol
+	}
+	if exchange == "" || tradingsymbol == "" {
+		return response.ErrorResponse(c, http.StatusBadRequest, "InputException", "Invalid `symbol` value, must be `EXCHANGE:TRADINGSYMBOL` or `TRADINGSYMBOL`")
+	}
+	memberships, err := h.IndexService.GetInstrumentIndices(exchange, tradingsymbol)
+	if err != nil {
+		return response.ErrorResponse(c, http.StatusInternalServerError, "ServerException", fmt.Sprintf("Error fetching indices for %s:%s: %v", exchange, tradingsymbol, err))
+	}
+	return response.SuccessResponse(c, memberships)
+}
+
 // GetIndicesByExchange returns a list of indices for a given exchange
 func (h *IndexHandler) GetIndicesByExchange(c echo.Context) error {
 	exchange := c.Param("exchange")
@@ -89,3 +155,43 @@ func (h *IndexHandler) GetIndexInstruments(c echo.Context) error {
 	}
 	return response.SuccessResponse(c, instruments)
 }
+
+// GetIndexBreadth returns the advances, declines and sum of constituent changes of a configured index
+func (h *IndexHandler) GetIndexBreadth(c echo.Context) error {
+	exchange := c.Param("exchange")
+	index := c.Param("index")
+	if exchange == "" || exchange == ":exchange" {
+		return response.ErrorResponse(c, http.StatusBadRequest, "InputException", "`exchange` is required")
+	}
+	if index == "" || index == ":index" {
+		return response.ErrorResponse(c, http.StatusBadRequest, "InputException", "`index` is required")
+	}
+	breadth, configured, err := h.IndexService.GetIndexBreadth(h.cfg, exchange, index)
+	if err != nil {
+		return response.ErrorResponse(c, http.StatusInternalServerError, "ServerException", fmt.Sprintf("Error computing breadth for index %s: %v", index, err))
+	}
+	if !configured {
+		return response.ErrorResponse(c, http.StatusNotFound, "DataNotFound", fmt.Sprintf("Breadth is not computed for index %s", index))
+	}
+	return response.SuccessResponse(c, breadth)
+}
+
+// GetIndexSynthetic returns the index value computed from the weighted quotes of its constituents
+func (h *IndexHandler) GetIndexSynthetic(c echo.Context) error {
+	exchange := c.Param("exchange")
+	index := c.Param("index")
+	if exchange == "" || exchange == ":exchange" {
+		return response.ErrorResponse(c, http.StatusBadRequest, "InputException", "`exchange` is required")
+	}
+	if index == "" || index == ":index" {
+		return response.ErrorResponse(c, http.StatusBadRequest, "InputException", "`index` is required")
+	}
+	synthetic, err := h.IndexService.GetIndexSynthetic(h.cfg, exchange, index)
+	if err != nil {
+		return response.ErrorResponse(c, http.StatusInternalServerError, "ServerException", fmt.Sprintf("Error computing synthetic value for index %s: %v", index, err))
+	}
+	if synthetic == nil {
+		return response.ErrorResponse(c, http.StatusNotFound, "DataNotFound", fmt.Sprintf("No constituents found for index %s", index))
+	}
+	return response.SuccessResponse(c, synthetic)
+}
@@ -3,12 +3,15 @@ package handlers
 
 import (
 	"fmt"
+	"math"
 	"net/http"
 	"regexp"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/labstack/echo/v4"
+	"github.com/nsvirk/moneybotsapi/internal/config"
 	"github.com/nsvirk/moneybotsapi/internal/models"
 	"github.com/nsvirk/moneybotsapi/internal/service"
 	"github.com/nsvirk/moneybotsapi/pkg/utils/response"
@@ -16,40 +19,94 @@ import (
 )
 
 type InstrumentHandler struct {
+	cfg               *config.Config
 	DB                *gorm.DB
 	InstrumentService *service.InstrumentService
 	IndexService      *service.IndexService
+	QuoteService      *service.QuoteService
+	Ranker            service.InstrumentRanker
 }
 
-func NewInstrumentHandler(db *gorm.DB) *InstrumentHandler {
+func NewInstrumentHandler(cfg *config.Config, db *gorm.DB) *InstrumentHandler {
 	return &InstrumentHandler{
+		cfg:               cfg,
 		DB:                db,
 		InstrumentService: service.NewInstrumentService(db),
 		IndexService:      service.NewIndexService(db),
+		QuoteService:      service.NewQuoteService(cfg, db),
+		Ranker:            service.GetInstrumentRanker(cfg.SearchRanking),
 	}
 }
 
 // UpdateInstrumentsResponseData is the response data for the UpdateInstruments endpoint
 type UpdateInstrumentsResponseData struct {
-	Timestamp string `json:"timestamp"`
-	Records   int    `json:"records"`
+	Timestamp string                        `json:"timestamp"`
+	Records   int                           `json:"records"`
+	Inserted  int                           `json:"inserted"`
+	Skipped   int                           `json:"skipped"`
+	Unchanged bool                          `json:"unchanged"`
+	Errors    []models.InstrumentsSyncError `json:"errors"`
 }
 
 // UpdateInstruments updates the instruments in the database
 func (h *InstrumentHandler) UpdateInstruments(c echo.Context) error {
-	totalInserted, err := h.InstrumentService.UpdateInstruments()
+	report, err := h.InstrumentService.UpdateInstruments()
 	if err != nil {
 		return response.ErrorResponse(c, http.StatusInternalServerError, "ServerException", err.Error())
 	}
 
 	responseData := UpdateInstrumentsResponseData{
 		Timestamp: time.Now().Format("2006-01-02 15:04:05"),
-		Records:   int(totalInserted),
+		Records:   int(report.Records),
+		Inserted:  int(report.Inserted),
+		Skipped:   report.Skipped,
+		Unchanged: report.Unchanged,
+		Errors:    report.Errors,
 	}
 
 	return response.SuccessResponse(c, responseData)
 }
 
+// SyncInstruments syncs all instruments, or only those of the `exchange` query param
+func (h *InstrumentHandler) SyncInstruments(c echo.Context) error {
+	exchange := strings.ToUpper(c.QueryParam("exchange"))
+	if exchange == "" {
+		return h.UpdateInstruments(c)
+	}
+	if !regexp.MustCompile(`^[A-Z]{2,4}$`).MatchString(exchange) {
+		return response.ErrorResponse(c, http.StatusBadRequest, "InputException", "Invalid `exchange` value")
+	}
+
+	report, err := h.InstrumentService.UpdateExchangeInstruments(exchange)
+	if err != nil {
+		return response.ErrorResponse(c, http.StatusInternalServerError, "ServerException", err.Error())
+	}
+
+	responseData := UpdateInstrumentsResponseData{
+		Timestamp: time.Now().Format("2006-01-02 15:04:05"),
+		Records:   int(report.Records),
+		Inserted:  int(report.Inserted),
+		Skipped:   report.Skipped,
+		Errors:    report.Errors,
+	}
+
+	return response.SuccessResponse(c, responseData)
+}
+
+// GetInstrumentsChecksum returns the checksum of the instruments, or of the `exchange` query param
+func (h *InstrumentHandler) GetInstrumentsChecksum(c echo.Context) error {
+	exchange := strings.ToUpper(c.QueryParam("exchange"))
+	if exchange != "" && !regexp.MustCompile(`^[A-Z]{2,4}$`).MatchString(exchange) {
+		return response.ErrorResponse(c, http.StatusBadRequest, "InputException", "Invalid `exchange` value")
+	}
+
+	checksum, err := h.InstrumentService.WithContext(c.Request().Context()).GetInstrumentsChecksum(h.cfg, exchange)
+	if err != nil {
+		return response.ErrorResponse(c, http.StatusInternalServerError, "ServerException", err.Error())
+	}
+	return response.SuccessResponse(c, checksum)
+}
+
 // GetInstrumentsInfo returns instruments by symbols or tokens
 func (h *InstrumentHandler) GetInstrumentsInfo(c echo.Context) error {
 	symbols := c.QueryParams()["s"]
@@ -66,7 +123,7 @@ func (h *InstrumentHandler) GetInstrumentsInfo(c echo.Context) error {
 	result := make(map[string]interface{})
 	// get instruments for symbols or tokens
 	if len(symbols) > 0 {
-		symbolInstruments, err := h.InstrumentService.GetInstrumentsInfoBySymbols(symbols)
+		symbolInstruments, err := h.InstrumentService.WithContext(c.Request().Context()).GetInstrumentsInfoBySymbols(symbols)
 		if err != nil {
 			return response.ErrorResponse(c, http.StatusInternalServerError, "ServerException", err.Error())
 		}
@@ -77,13 +134,13 @@ func (h *InstrumentHandler) GetInstrumentsInfo(c echo.Context) error {
 		// convert tokensStr to []uint32
 		var tokens []uint32
 		for _, tokenStr := range tokensStr {
-			token, err := strconv.ParseUint(tokenStr, 10, 32)
+			token, err := models.ParseInstrumentToken(tokenStr)
 			if err != nil {
-				return response.ErrorResponse(c, http.StatusBadRequest, "InputException", "Invalid `instrument_token`, must be digits")
+				return response.ErrorResponse(c, http.StatusBadRequest, "InputException", err.Error())
 			}
-			tokens = append(tokens, uint32(token))
+			tokens = append(tokens, token)
 		}
-		tokenInstruments, err := h.InstrumentService.GetInstrumentsInfoByTokens(tokens)
+		tokenInstruments, err := h.InstrumentService.WithContext(c.Request().Context()).GetInstrumentsInfoByTokens(tokens)
 		if err != nil {
 			return response.ErrorResponse(c, http.StatusInternalServerError, "ServerException", err.Error())
 		}
@@ -94,7 +151,130 @@ func (h *InstrumentHandler) GetInstrumentsInfo(c echo.Context) error {
 	return response.SuccessResponse(c, result)
 }
 
+// GetInstrumentsAsOf resolves the `s` symbols or `t` tokens against the instrument master of the `as_of` date
+func (h *InstrumentHandler) GetInstrumentsAsOf(c echo.Context) error {
+	asOf := c.QueryParam("as_of")
+	if asOf == "" {
+		return response.ErrorResponse(c, http.StatusBadRequest, "InputException", "`as_of` is required")
+	}
+	if _, err := time.Parse("2006-01-02", asOf); err != nil {
+		return response.ErrorResponse(c, http.StatusBadRequest, "InputException", "`as_of` must be a date in YYYY-MM-DD format")
+	}
+	symbols := c.QueryParams()["s"]
+	tokensStr := c.QueryParams()["t"]
+	if len(symbols) == 0 && len(tokensStr) == 0 {
+		return response.ErrorResponse(c, http.StatusBadRequest, "InputException", "`s` or `t` is required")
+	}
+	if len(symbols) > 0 && len(tokensStr) > 0 {
+		return response.ErrorResponse(c, http.StatusBadRequest, "InputException", "Either `s` or `t` is required, not both")
+	}
+
+	instrumentService := h.InstrumentService.WithContext(c.Request().Context())
+	result := make(map[string]interface{})
+	if len(symbols) > 0 {
+		versions, err := instrumentService.GetInstrumentsAsOfBySymbols(asOf, symbols)
+		if err != nil {
+			return response.ErrorResponse(c, http.StatusInternalServerError, "ServerException", err.Error())
+		}
+		for _, version := range versions {
+			result[fmt.Sprintf("%s:%s", version.Exchange, version.Tradingsymbol)] = version
+		}
+		return response.SuccessResponse(c, result)
+	}
+
+	var tokens []uint32
+	for _, tokenStr := range tokensStr {
+		token, err := models.ParseInstrumentToken(tokenStr)
+		if err != nil {
+			return response.ErrorResponse(c, http.StatusBadRequest, "InputException", err.Error())
+		}
+		tokens = append(tokens, token)
+	}
+	versions, err := instrumentService.GetInstrumentsAsOfByTokens(asOf, tokens)
+	if err != nil {
+		return response.ErrorResponse(c, http.StatusInternalServerError, "ServerException", err.Error())
+	}
+	for _, version := range versions {
+		result[fmt.Sprintf("%d", version.InstrumentToken)] = version
+	}
+	return response.SuccessResponse(c, result)
+}
+
+// GetInstrumentLimits returns the order quantity limits of the `i` instruments
+// Instruments without a freeze limit, such as equities, have a null `freeze_quantity`
+func (h *InstrumentHandler) GetInstrumentLimits(c echo.Context) error {
+	instruments := c.QueryParams()["i"]
+	if len(instruments) == 0 {
+		return response.ErrorResponse(c, http.StatusBadRequest, "InputException", "No instruments specified")
+	}
+	limits, err := h.InstrumentService.WithContext(c.Request().Context()).GetInstrumentLimits(instruments)
+	if err != nil {
+		return response.ErrorResponse(c, http.StatusInternalServerError, "ServerException", err.Error())
+	}
+	return response.SuccessResponse(c, limits)
+}
+
+// GetCorporateActions returns the upcoming and past corporate actions of the `i` instruments
+// Instruments without corporate actions have empty lists
+func (h *InstrumentHandler) GetCorporateActions(c echo.Context) error {
+	instruments := c.QueryParams()["i"]
+	if len(instruments) == 0 {
+		return response.ErrorResponse(c, http.StatusBadRequest, "InputException", "No instruments specified")
+	}
+	actions, err := h.InstrumentService.WithContext(c.Request().Context()).GetCorporateActions(instruments)
+	if err != nil {
+		return response.ErrorResponse(c, http.StatusInternalServerError, "ServerException", err.Error())
+	}
+	return response.SuccessResponse(c, actions)
+}
+
+// Option chain strikes on each side of the ATM strike
+const (
+	defaultOptionChainStrikes = 10
+	maxOptionChainStrikes     = 50
+)
+
+// GetOptionChain returns the CE and PE contracts of the `name` options around the spot price, with their quotes
+// `exchange` defaults to NFO and `expiry` to the nearest expiry, `strikes` is the strikes on each side of the ATM strike
+func (h *InstrumentHandler) GetOptionChain(c echo.Context) error {
+	name := strings.ToUpper(c.QueryParam("name"))
+	if name == "" {
+		return response.ErrorResponse(c, http.StatusBadRequest, "InputException", "`name` is required")
+	}
+	exchange := strings.ToUpper(c.QueryParam("exchange"))
+	if exchange == "" {
+		exchange = "NFO"
+	}
+	expiry := c.QueryParam("expiry")
+	if len(expiry) > 0 {
+		if _, err := time.Parse("2006-01-02", expiry); err != nil {
+			return response.ErrorResponse(c, http.StatusBadRequest, "InputException", "Invalid `expiry` value, must be a valid date")
+		}
+	}
+	strikes := defaultOptionChainStrikes
+	if strikesStr := c.QueryParam("strikes"); strikesStr != "" {
+		var err error
+		strikes, err = strconv.Atoi(strikesStr)
+		if err != nil || strikes < 1 || strikes > maxOptionChainStrikes {
+			return response.ErrorResponse(c, http.StatusBadRequest, "InputException", fmt.Sprintf("Invalid `strikes` value, must be between 1 and %d", maxOptionChainStrikes))
+		}
+	}
+
+	chain, err := h.QuoteService.WithContext(c.Request().Context()).GetOptionChain(exchange, name, expiry, strikes)
+	if err != nil {
+		return response.ErrorResponse(c, http.StatusInternalServerError, "ServerException", err.Error())
+	}
+	if chain == nil {
+		return response.ErrorResponse(c, http.StatusNotFound, "DataNotFound", fmt.Sprintf("No %s options found for %s", exchange, name))
+	}
+	return response.SuccessResponse(c, chain)
+}
+
+// maxInstrumentsQueryLimit is the max page size of the instruments query
+const maxInstrumentsQueryLimit = 5000
+
 // GetInstrumentsQuery returns a list of instruments for a given exchange, tradingsymbol, expiry, strike and segment
+// `tradingsymbol_prefix`, `strike_min` and `strike_max` narrow the instruments, and `limit` and `offset` page them
 func (h *InstrumentHandler) GetInstrumentsQuery(c echo.Context) error {
 	// get the exchange, tradingsymbol, instrument_token, name, expiry, strike and segment from the request
 	exchange := c.QueryParam("exchange")
@@ -105,9 +285,14 @@ func (h *InstrumentHandler) GetInstrumentsQuery(c echo.Context) error {
 	strike := c.QueryParam("strike")
 	segment := c.QueryParam("segment")
 	instrumentType := c.QueryParam("instrument_type")
-	// check instrumentToken is all digits
-	if len(instrumentToken) > 0 && !regexp.MustCompile(`^\d+$`).MatchString(instrumentToken) {
-		return response.ErrorResponse(c, http.StatusBadRequest, "InputException", "Invalid `instrument_token` value, must be digits")
+	tradingsymbolPrefix := c.QueryParam("tradingsymbol_prefix")
+	strikeMin := c.QueryParam("strike_min")
+	strikeMax := c.QueryParam("strike_max")
+	// check instrumentToken is a valid token
+	if len(instrumentToken) > 0 {
+		if _, err := models.ParseInstrumentToken(instrumentToken); err != nil {
+			return response.ErrorResponse(c, http.StatusBadRequest, "InputException", err.Error())
+		}
 	}
 	// check if expiry is input and is a valid date
 	if len(expiry) > 0 {
@@ -124,6 +309,37 @@ func (h *InstrumentHandler) GetInstrumentsQuery(c echo.Context) error {
 	if len(instrumentType) > 0 && !regexp.MustCompile(`^(FUT|CE|PE|EQ)$|%`).MatchString(instrumentType) {
 		return response.ErrorResponse(c, http.StatusBadRequest, "InputException", "Invalid `instrument_type` value, must be `FUT`, `CE`, `PE` or `EQ` or include `%`")
 	}
+	// check if the strike range bounds are non-negative numbers if not blank, and are in order
+	minBound, ok := parseStrikeBound(strikeMin)
+	if !ok {
+		return response.ErrorResponse(c, http.StatusBadRequest, "InputException", "Invalid `strike_min` value, must be a non-negative number")
+	}
+	maxBound, ok := parseStrikeBound(strikeMax)
+	if !ok {
+		return response.ErrorResponse(c, http.StatusBadRequest, "InputException", "Invalid `strike_max` value, must be a non-negative number")
+	}
+	if strikeMin != "" && strikeMax != "" && minBound > maxBound {
+		return response.ErrorResponse(c, http.StatusBadRequest, "InputException", "Invalid strike range, `strike_min` must not exceed `strike_max`")
+	}
+	// check the page, the instruments are only paged when a limit is given
+	limit, offset := 0, 0
+	if limitStr := c.QueryParam("limit"); limitStr != "" {
+		var err error
+		limit, err = strconv.Atoi(limitStr)
+		if err != nil || limit < 1 || limit > maxInstrumentsQueryLimit {
+			return response.ErrorResponse(c, http.StatusBadRequest, "InputException", fmt.Sprintf("Invalid `limit` value, must be between 1 and %d", maxInstrumentsQueryLimit))
+		}
+	}
+	if offsetStr := c.QueryParam("offset"); offsetStr != "" {
+		var err error
+		offset, err = strconv.Atoi(offsetStr)
+		if err != nil || offset < 0 {
+			return response.ErrorResponse(c, http.StatusBadRequest, "InputException", "Invalid `offset` value, must be a non-negative integer")
+		}
+		if limit == 0 {
+			return response.ErrorResponse(c, http.StatusBadRequest, "InputException", "`offset` requires `limit`")
+		}
+	}
 	// Create the query instruments params
 	queryInstrumentsParams := models.QueryInstrumentsParams{
 		Exchange:        exchange,
@@ -134,9 +350,49 @@ func (h *InstrumentHandler) GetInstrumentsQuery(c echo.Context) error {
 		Strike:          strike,
 		Segment:         segment,
 		InstrumentType:  instrumentType,
+
+		TradingsymbolPrefix: tradingsymbolPrefix,
+		StrikeMin:           strikeMin,
+		StrikeMax:           strikeMax,
+		Limit:               limit,
+		Offset:              offset,
 	}
 	// get the instruments
-	instruments, err := h.InstrumentService.GetInstrumentsQuery(queryInstrumentsParams)
+	instruments, err := h.InstrumentService.WithContext(c.Request().Context()).GetInstrumentsQuery(queryInstrumentsParams)
+	if err != nil {
+		return response.ErrorResponse(c, http.StatusInternalServerError, "ServerException", err.Error())
+	}
+	return response.SuccessResponse(c, instruments)
+}
+
+// parseStrikeBound parses a bound of the strike range, a blank bound is valid and unset
+// NaN, infinite and negative bounds are invalid
+func parseStrikeBound(value string) (float64, bool) {
+	if value == "" {
+		return 0, true
+	}
+	bound, err := strconv.ParseFloat(value, 64)
+	if err != nil || math.IsNaN(bound) || math.IsInf(bound, 0) || bound < 0 {
+		return 0, false
+	}
+	return bound, true
+}
+
+// SearchInstruments returns instruments matching the query `q`, ranked by the configured strategy
+func (h *InstrumentHandler) SearchInstruments(c echo.Context) error {
+	query := strings.TrimSpace(c.QueryParam("q"))
+	if query == "" {
+		return response.ErrorResponse(c, http.StatusBadRequest, "InputException", "`q` is required")
+	}
+	limit := 20
+	if limitStr := c.QueryParam("limit"); limitStr != "" {
+		l, err := strconv.Atoi(limitStr)
+		if err != nil || l <= 0 || l > 100 {
+			return response.ErrorResponse(c, http.StatusBadRequest, "InputException", "Invalid `limit` value, must be between 1 and 100")
+		}
+		limit = l
+	}
+	instruments, err := h.InstrumentService.WithContext(c.Request().Context()).SearchInstruments(h.Ranker, query, limit)
 	if err != nil {
 		return response.ErrorResponse(c, http.StatusInternalServerError, "ServerException", err.Error())
 	}
@@ -156,7 +412,7 @@ func (h *InstrumentHandler) GetFNOSegmentWiseName(c echo.Context) error {
 		return response.ErrorResponse(c, http.StatusBadRequest, "InputException", "Invalid `expiry` format")
 	}
 
-	instruments, err := h.InstrumentService.GetFNOSegmentWiseName(expiry)
+	instruments, err := h.InstrumentService.WithContext(c.Request().Context()).GetFNOSegmentWiseName(expiry)
 	if err != nil {
 		return response.ErrorResponse(c, http.StatusInternalServerError, "ServerException", err.Error())
 	}
@@ -184,7 +440,7 @@ func (h *InstrumentHandler) GetFNOSegmentWiseExpiry(c echo.Context) error {
 		return response.ErrorResponse(c, http.StatusBadRequest, "InputException", "`name` is required")
 	}
 
-	instruments, err := h.InstrumentService.GetFNOSegmentWiseExpiry(name, limit, offset)
+	instruments, err := h.InstrumentService.WithContext(c.Request().Context()).GetFNOSegmentWiseExpiry(name, limit, offset)
 	if err != nil {
 		return response.ErrorResponse(c, http.StatusInternalServerError, "ServerException", err.Error())
 	}
@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+)
+
+func TestGetInstrumentsQueryValidation(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+	}{
+		{name: "strike_min not a number", query: "strike_min=abc"},
+		{name: "strike_max not a number", query: "strike_max=abc"},
+		{name: "strike_min negative", query: "strike_min=-100"},
+		{name: "strike_max infinite", query: "strike_max=Inf"},
+		{name: "strike_min nan", query: "strike_min=NaN"},
+		{name: "strike range reversed", query: "strike_min=25000&strike_max=24000"},
+		{name: "limit zero", query: "limit=0"},
+		{name: "limit over the max", query: "limit=100000"},
+		{name: "offset negative", query: "limit=10&offset=-1"},
+		{name: "offset without limit", query: "offset=10"},
+	}
+
+	// the query is validated before the instruments are fetched, so no service is needed
+	h := &InstrumentHandler{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			c := echo.New().NewContext(httptest.NewRequest(http.MethodGet, "/instruments/query?"+tt.query, nil), rec)
+			if err := h.GetInstrumentsQuery(c); err != nil {
+				t.Fatalf("GetInstrumentsQuery() error = %v", err)
+			}
+			if rec.Code != http.StatusBadRequest {
+				t.Errorf("GetInstrumentsQuery(%s) status = %d, want %d: %s", tt.query, rec.Code, http.StatusBadRequest, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestParseStrikeBound(t *testing.T) {
+	tests := []struct {
+		value  string
+		want   float64
+		wantOK bool
+	}{
+		{value: "", want: 0, wantOK: true},
+		{value: "0", want: 0, wantOK: true},
+		{value: "24500", want: 24500, wantOK: true},
+		{value: "72.5", want: 72.5, wantOK: true},
+		{value: "-1", wantOK: false},
+		{value: "1e400", wantOK: false},
+		{value: "NaN", wantOK: false},
+		{value: "strike", wantOK: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, ok := parseStrikeBound(tt.value)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("parseStrikeBound(%q) = %v, %v, want %v, %v", tt.value, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
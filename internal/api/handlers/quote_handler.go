@@ -2,9 +2,12 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/labstack/echo/v4"
 	"github.com/nsvirk/moneybotsapi/internal/models"
@@ -23,8 +26,64 @@ func NewQuoteHandler(service *service.QuoteService) *QuoteHandler {
 }
 
 // GetQuote gets the quote for the given instruments
+// `depth=compact` serializes the depth levels as `[price, quantity, orders]` tuples
+// Derivatives carry the OI change from the previous day when it is known
+// Instruments in the feed but not yet in the instrument master are flagged with `metadata_missing`
+// Derivatives with a freeze limit carry their `freeze_quantity`
 func (h *QuoteHandler) GetQuote(c echo.Context) error {
-	return h.handleRequest(c, mapTickToQuoteData)
+	compact := false
+	switch c.QueryParam("depth") {
+	case "", "object":
+	case "compact":
+		compact = true
+	default:
+		return response.NewError(response.ErrValidation, "Invalid `depth` value, must be `object` or `compact`")
+	}
+
+	return h.handleMappedRequest(c, func(tickDataMap map[string]*models.TickerData) (func(*models.TickerData) interface{}, error) {
+		quoteService := h.service.WithContext(c.Request().Context())
+		oiChanges, err := quoteService.GetOIChanges(tickDataMap)
+		if err != nil {
+			return nil, err
+		}
+		priceUnits, err := quoteService.GetPriceUnits(tickDataMap)
+		if err != nil {
+			return nil, err
+		}
+		metadataMissing, err := quoteService.GetMetadataMissing(tickDataMap)
+		if err != nil {
+			return nil, err
+		}
+		freezeLimits, err := quoteService.GetFreezeLimits(tickDataMap)
+		if err != nil {
+			return nil, err
+		}
+		suspended, err := quoteService.GetSuspended(tickDataMap)
+		if err != nil {
+			return nil, err
+		}
+		return func(tick *models.TickerData) interface{} {
+			data := mapTickToQuoteData(tick)
+			quoteData, ok := data.(models.QuoteData)
+			if !ok {
+				// indices are served as index quotes
+				return data
+			}
+			quoteData.Depth.Compact = compact
+			quoteData.Currency = priceUnits[tick.InstrumentToken].Currency
+			quoteData.PriceUnit = priceUnits[tick.InstrumentToken].Unit
+			quoteData.MetadataMissing = metadataMissing[tick.InstrumentToken]
+			quoteData.IsTradable, quoteData.Suspended = mapTradability(tick, suspended[tick.InstrumentToken])
+			if freezeQty, ok := freezeLimits[tick.InstrumentToken]; ok {
+				quoteData.FreezeQuantity = &freezeQty
+			}
+			if oiChange, ok := oiChanges[tick.InstrumentToken]; ok {
+				quoteData.OIChange = &oiChange.Change
+				quoteData.OIChangePercent = oiChange.ChangePercent
+			}
+			return quoteData
+		}, nil
+	})
 }
 
 // GetOHLC gets the OHLC data for the given instruments
@@ -32,22 +91,250 @@ func (h *QuoteHandler) GetOHLC(c echo.Context) error {
 	return h.handleRequest(c, mapTickToOHLCData)
 }
 
-// GetLTP gets the LTP data for the given instruments
+// GetLTP gets the LTP data for the given instruments, with the currency and unit of the price
 func (h *QuoteHandler) GetLTP(c echo.Context) error {
-	return h.handleRequest(c, mapTickToLTPData)
+	return h.handleMappedRequest(c, func(tickDataMap map[string]*models.TickerData) (func(*models.TickerData) interface{}, error) {
+		priceUnits, err := h.service.WithContext(c.Request().Context()).GetPriceUnits(tickDataMap)
+		if err != nil {
+			return nil, err
+		}
+		return func(tick *models.TickerData) interface{} {
+			ltpData := mapTickToLTPData(tick).(models.LTPData)
+			ltpData.Currency = priceUnits[tick.InstrumentToken].Currency
+			ltpData.PriceUnit = priceUnits[tick.InstrumentToken].Unit
+			return ltpData
+		}, nil
+	})
+}
+
+// GetVWAP gets the windowed VWAP for the given instruments
+// `window` is a duration like `15m`, or `day` (the default) for the full day
+func (h *QuoteHandler) GetVWAP(c echo.Context) error {
+	instruments := c.QueryParams()["i"]
+	if len(instruments) == 0 {
+		return response.NewError(response.ErrValidation, "No instruments specified")
+	}
+
+	var window time.Duration
+	if windowStr := c.QueryParam("window"); windowStr != "" && windowStr != "day" {
+		var err error
+		window, err = time.ParseDuration(windowStr)
+		if err != nil || window < time.Minute {
+			return response.NewError(response.ErrValidation, "Invalid `window` value, must be a duration of at least `1m` or `day`")
+		}
+	}
+
+	vwapData, err := h.service.WithContext(c.Request().Context()).GetVWAP(instruments, window)
+	if err != nil {
+		return response.NewError(response.ErrInternal, err.Error())
+	}
+	if len(vwapData) == 0 {
+		return response.NewError(response.ErrNotFound, fmt.Sprintf("No data found for instruments: %v", instruments))
+	}
+
+	return response.SuccessResponse(c, vwapData)
+}
+
+// defaultTapeTrades is the number of trades returned when `n` is not given
+const defaultTapeTrades = 50
+
+// GetTrades gets the last `n` trades of the day of the given instruments, newest first
+func (h *QuoteHandler) GetTrades(c echo.Context) error {
+	instruments := c.QueryParams()["i"]
+	if len(instruments) == 0 {
+		return response.NewError(response.ErrValidation, "No instruments specified")
+	}
+
+	n := defaultTapeTrades
+	if nStr := c.QueryParam("n"); nStr != "" {
+		var err error
+		n, err = strconv.Atoi(nStr)
+		if err != nil || n < 1 || n > service.TradeTapeSize {
+			return response.NewError(response.ErrValidation, fmt.Sprintf("Invalid `n` value, must be between 1 and %d", service.TradeTapeSize))
+		}
+	}
+
+	return response.SuccessResponse(c, h.service.GetTrades(instruments, n))
+}
+
+// GetQuoteChanges gets the quotes of the given instruments updated since the client's version
+func (h *QuoteHandler) GetQuoteChanges(c echo.Context) error {
+	var req models.QuoteChangesRequest
+	if err := c.Bind(&req); err != nil {
+		return response.NewError(response.ErrValidation, "Invalid request body")
+	}
+	if len(req.Instruments) == 0 {
+		return response.NewError(response.ErrValidation, "No instruments specified")
+	}
+
+	tickDataMap, err := h.service.WithContext(c.Request().Context()).GetTickData(req.Instruments)
+	if err != nil {
+		return response.NewError(response.ErrInternal, fmt.Sprintf("Error fetching tick data: %v", err))
+	}
+
+	changes := models.QuoteChangesData{
+		Quotes:  make(map[string]interface{}),
+		Version: make(map[string]int64, len(tickDataMap)),
+	}
+	for _, instrument := range req.Instruments {
+		tickData, ok := tickDataMap[instrument]
+		if !ok {
+			continue
+		}
+		token := strconv.FormatUint(uint64(tickData.InstrumentToken), 10)
+		updatedAt := tickData.UpdatedAt.UnixNano()
+		changes.Version[token] = updatedAt
+		if seen, ok := req.Version[token]; ok && updatedAt <= seen {
+			continue
+		}
+		changes.Quotes[instrument] = mapTickToQuoteData(tickData)
+	}
+
+	return response.SuccessResponse(c, changes)
+}
+
+// GetQuoteMovers gets the top gainers and losers among the constituents of an index
+// `by` ranks by `percent` (the default) or `absolute` change, `limit` defaults to 10
+func (h *QuoteHandler) GetQuoteMovers(c echo.Context) error {
+	index := c.QueryParam("index")
+	if index == "" {
+		return response.NewError(response.ErrValidation, "`index` is required")
+	}
+	exchange := c.QueryParam("exchange")
+	if exchange == "" {
+		exchange = string(models.ExchangeNSE)
+	}
+
+	by := c.QueryParam("by")
+	switch by {
+	case "":
+		by = service.MoversByPercent
+	case service.MoversByPercent, service.MoversByAbsolute:
+	default:
+		return response.NewError(response.ErrValidation, "Invalid `by` value, must be `percent` or `absolute`")
+	}
+
+	limit := 10
+	if limitStr := c.QueryParam("limit"); limitStr != "" {
+		var err error
+		limit, err = strconv.Atoi(limitStr)
+		if err != nil || limit < 1 || limit > 50 {
+			return response.NewError(response.ErrValidation, "Invalid `limit` value, must be between 1 and 50")
+		}
+	}
+
+	movers, err := h.service.WithContext(c.Request().Context()).GetQuoteMovers(exchange, index, by, limit)
+	if err != nil {
+		return response.NewError(response.ErrInternal, err.Error())
+	}
+	if movers == nil {
+		return response.NewError(response.ErrNotFound, fmt.Sprintf("No constituents found for index %s:%s", exchange, index))
+	}
+	return response.SuccessResponse(c, movers)
+}
+
+// GetQuoteBasis gets the basis of a futures contract over its spot instrument
+func (h *QuoteHandler) GetQuoteBasis(c echo.Context) error {
+	fut := c.QueryParam("fut")
+	spot := c.QueryParam("spot")
+	if fut == "" || spot == "" {
+		return response.NewError(response.ErrValidation, "`fut` and `spot` are required")
+	}
+	if !models.InstrumentExchange(fut).IsDerivative() {
+		return response.NewError(response.ErrValidation, "`fut` must be a futures contract, e.g. NFO:NIFTY24JUNFUT")
+	}
+	if models.InstrumentExchange(spot).IsDerivative() {
+		return response.NewError(response.ErrValidation, "`spot` must not be a derivative, e.g. NSE:NIFTY 50")
+	}
+
+	basis, err := h.service.WithContext(c.Request().Context()).GetQuoteBasis(fut, spot)
+	if err != nil {
+		if errors.Is(err, service.ErrNotFuturesContract) {
+			return response.NewError(response.ErrValidation, fmt.Sprintf("%s is not a futures contract", fut))
+		}
+		return response.NewError(response.ErrInternal, err.Error())
+	}
+	if basis == nil {
+		return response.NewError(response.ErrNotFound, fmt.Sprintf("No quote found for %s or %s", fut, spot))
+	}
+	return response.SuccessResponse(c, basis)
+}
+
+// GetQuoteSummary gets the watchlist summary for the given instruments
+func (h *QuoteHandler) GetQuoteSummary(c echo.Context) error {
+	var req models.QuoteSummaryRequest
+	if err := c.Bind(&req); err != nil {
+		return response.NewError(response.ErrValidation, "Invalid request body")
+	}
+	if len(req.Instruments) == 0 {
+		return response.NewError(response.ErrValidation, "No instruments specified")
+	}
+
+	tickDataMap, err := h.service.WithContext(c.Request().Context()).GetTickData(req.Instruments)
+	if err != nil {
+		return response.NewError(response.ErrInternal, fmt.Sprintf("Error fetching tick data: %v", err))
+	}
+
+	summaries := make(map[string]interface{}, len(tickDataMap))
+	for _, instrument := range req.Instruments {
+		if tickData, ok := tickDataMap[instrument]; ok {
+			summaries[instrument] = mapTickToSummaryData(tickData)
+		}
+	}
+	if len(summaries) == 0 {
+		return response.NewError(response.ErrNotFound, fmt.Sprintf("No data found for instruments: %v", req.Instruments))
+	}
+
+	return response.SuccessResponse(c, summaries)
 }
 
 // handleRequest is the common function to handle the request for the quote API
 func (h *QuoteHandler) handleRequest(c echo.Context, mapper func(*models.TickerData) interface{}) error {
+	return h.handleMappedRequest(c, func(map[string]*models.TickerData) (func(*models.TickerData) interface{}, error) {
+		return mapper, nil
+	})
+}
+
+// handleMappedRequest handles the request for the quote API with a mapper built from the fetched tick data
+func (h *QuoteHandler) handleMappedRequest(c echo.Context, newMapper func(map[string]*models.TickerData) (func(*models.TickerData) interface{}, error)) error {
 	instruments := c.QueryParams()["i"]
 	if len(instruments) == 0 {
-		return response.ErrorResponse(c, http.StatusBadRequest, "InputException", "No instruments specified")
+		return response.NewError(response.ErrValidation, "No instruments specified")
+	}
+
+	// key the response by `symbol` (default) or `token`
+	key := c.QueryParam("key")
+	if key == "" {
+		key = "symbol"
+	}
+	if key != "symbol" && key != "token" {
+		return response.NewError(response.ErrValidation, "Invalid `key` value, must be `symbol` or `token`")
 	}
 
-	tickDataMap, err := h.service.GetTickData(instruments)
+	quoteService := h.service.WithContext(c.Request().Context())
+
+	// continuous futures are looked up by their current contract
+	contracts, err := quoteService.ResolveContinuousFutures(instruments)
+	if err != nil {
+		return response.NewError(response.ErrInternal, err.Error())
+	}
+	lookupInstruments := make([]string, len(instruments))
+	for i, instrument := range instruments {
+		lookupInstruments[i] = instrument
+		if contract, ok := contracts[instrument]; ok {
+			lookupInstruments[i] = contract.Instrument
+		}
+	}
+
+	tickDataMap, err := quoteService.GetTickData(lookupInstruments)
 	if err != nil {
 		log.Printf("Error fetching tick data: %v", err)
-		return response.ErrorResponse(c, http.StatusInternalServerError, "ServerException", fmt.Sprintf("Error fetching tick data: %v", err))
+		return response.NewError(response.ErrInternal, fmt.Sprintf("Error fetching tick data: %v", err))
+	}
+
+	mapper, err := newMapper(tickDataMap)
+	if err != nil {
+		return response.NewError(response.ErrInternal, err.Error())
 	}
 
 	quoteResponse := models.QuoteResponse{
@@ -55,14 +342,32 @@ func (h *QuoteHandler) handleRequest(c echo.Context, mapper func(*models.TickerD
 		Data:   make(map[string]interface{}),
 	}
 
-	for _, instrument := range instruments {
-		if tickData, ok := tickDataMap[instrument]; ok {
-			quoteResponse.Data[instrument] = mapper(tickData)
+	for i, instrument := range instruments {
+		if tickData, ok := tickDataMap[lookupInstruments[i]]; ok {
+			responseKey := instrument
+			if key == "token" {
+				responseKey = strconv.FormatUint(uint64(tickData.InstrumentToken), 10)
+			}
+			data := mapper(tickData)
+			if contract, ok := contracts[instrument]; ok {
+				if quoteData, ok := data.(models.QuoteData); ok {
+					quoteData.Contract = contract.Instrument
+					quoteData.DaysToExpiry = &contract.DaysToExpiry
+					data = quoteData
+				}
+			}
+			quoteResponse.Data[responseKey] = data
 		}
 	}
 
 	if len(quoteResponse.Data) == 0 {
-		return response.ErrorResponse(c, http.StatusNotFound, "DataNotFound", fmt.Sprintf("No data found for instruments: %v", instruments))
+		return response.NewError(response.ErrNotFound, fmt.Sprintf("No data found for instruments: %v", instruments))
+	}
+
+	// echo the server time in the market timezone, to compare against the tick timestamps
+	if c.QueryParam("server_time") == "true" {
+		quoteResponse.ServerTime = time.Now().In(service.MarketLocation).Format(time.RFC3339Nano)
+		c.Response().Header().Set("X-Server-Time", quoteResponse.ServerTime)
 	}
 
 	return c.JSON(http.StatusOK, quoteResponse)
@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/nsvirk/moneybotsapi/internal/config"
+	"github.com/nsvirk/moneybotsapi/internal/models"
+	"github.com/nsvirk/moneybotsapi/internal/repository"
+	"github.com/nsvirk/moneybotsapi/internal/service"
+)
+
+func TestGetQuoteChanges(t *testing.T) {
+	cfg := &config.Config{FeedSampleInstr: "NSE:INFY,NSE:TCS"}
+	db, err := repository.ConnectMemory(cfg)
+	if err != nil {
+		t.Fatalf("ConnectMemory() error = %v", err)
+	}
+	handler := NewQuoteHandler(service.NewQuoteService(cfg, db))
+
+	var tickerData []models.TickerData
+	if err := db.Find(&tickerData).Error; err != nil {
+		t.Fatalf("failed to read the ticker data: %v", err)
+	}
+	versions := make(map[string]int64, len(tickerData))
+	for _, tick := range tickerData {
+		versions[tick.Instrument] = tick.UpdatedAt.UnixNano()
+	}
+
+	tests := []struct {
+		name    string
+		body    string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name: "no version returns all",
+			body: `{"instruments":["NSE:INFY","NSE:TCS"]}`,
+			want: []string{"NSE:INFY", "NSE:TCS"},
+		},
+		{
+			name: "unchanged instruments are left out",
+			body: `{"instruments":["NSE:INFY","NSE:TCS"],"version":{"100001":` + strconv.FormatInt(versions["NSE:INFY"], 10) + `}}`,
+			want: []string{"NSE:TCS"},
+		},
+		{
+			name: "instruments updated since the version are returned",
+			body: `{"instruments":["NSE:INFY","NSE:TCS"],"version":{"100001":` + strconv.FormatInt(versions["NSE:INFY"]-1, 10) + `,"100002":` + strconv.FormatInt(versions["NSE:TCS"], 10) + `}}`,
+			want: []string{"NSE:INFY"},
+		},
+		{
+			name:    "no instruments",
+			body:    `{"instruments":[]}`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/quote/changes", strings.NewReader(tt.body))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			rec := httptest.NewRecorder()
+			err := handler.GetQuoteChanges(echo.New().NewContext(req, rec))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("GetQuoteChanges() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			var resp struct {
+				Data models.QuoteChangesData `json:"data"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("failed to decode the response: %v", err)
+			}
+			got := make([]string, 0, len(resp.Data.Quotes))
+			for instrument := range resp.Data.Quotes {
+				got = append(got, instrument)
+			}
+			sort.Strings(got)
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("GetQuoteChanges() quotes = %v, want %v", got, tt.want)
+			}
+			if len(resp.Data.Version) != 2 {
+				t.Errorf("GetQuoteChanges() version = %v, want both tokens", resp.Data.Version)
+			}
+		})
+	}
+}
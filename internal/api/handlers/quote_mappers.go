@@ -3,11 +3,20 @@ package handlers
 
 import (
 	"log"
+	"math"
+	"time"
 
 	"github.com/nsvirk/moneybotsapi/internal/models"
+	"github.com/nsvirk/moneybotsapi/internal/service"
+	"github.com/nsvirk/moneybotsapi/pkg/utils/zaplogger"
 )
 
+// mapTickToQuoteData maps the tick to a full quote, indices are mapped to an index quote
 func mapTickToQuoteData(tick *models.TickerData) interface{} {
+	if tick.IsIndex {
+		return mapTickToIndexQuoteData(tick)
+	}
+
 	ohlc, err := tick.GetOHLC()
 	if err != nil {
 		log.Printf("Error getting OHLC data: %v", err)
@@ -20,14 +29,17 @@ func mapTickToQuoteData(tick *models.TickerData) interface{} {
 		depth = models.TickerDataDepth{} // Use default Depth
 	}
 
+	isTradable, suspended := mapTradability(tick, false)
+
 	return models.QuoteData{
 		Instrument:         tick.Instrument,
 		Mode:               tick.Mode,
 		InstrumentToken:    tick.InstrumentToken,
-		IsTradable:         tick.IsTradable,
+		IsTradable:         isTradable,
+		Suspended:          suspended,
 		IsIndex:            tick.IsIndex,
-		Timestamp:          tick.Timestamp.Format("2006-01-02 15:04:05"),
-		LastTradeTime:      tick.LastTradeTime.Format("2006-01-02 15:04:05"),
+		Timestamp:          formatQuoteTime(tick, "timestamp", tick.Timestamp),
+		LastTradeTime:      formatQuoteTime(tick, "last_trade_time", tick.LastTradeTime),
 		LastPrice:          tick.LastPrice,
 		LastTradedQuantity: tick.LastTradedQuantity,
 		TotalBuyQuantity:   tick.TotalBuyQuantity,
@@ -42,7 +54,30 @@ func mapTickToQuoteData(tick *models.TickerData) interface{} {
 		NetChange:         tick.NetChange,
 		OHLC:              mapOHLC(ohlc),
 		Depth:             mapDepth(depth),
-		UpdatedAt:         tick.UpdatedAt.Format("2006-01-02 15:04:05"),
+		UpdatedAt:         formatQuoteTime(tick, "updated_at", tick.UpdatedAt),
+	}
+}
+
+func mapTickToIndexQuoteData(tick *models.TickerData) interface{} {
+	ohlc, err := tick.GetOHLC()
+	if err != nil {
+		log.Printf("Error getting OHLC data: %v", err)
+	}
+
+	isTradable, suspended := mapTradability(tick, false)
+
+	return models.IndexQuoteData{
+		Instrument:      tick.Instrument,
+		Mode:            tick.Mode,
+		InstrumentToken: tick.InstrumentToken,
+		IsTradable:      isTradable,
+		Suspended:       suspended,
+		IsIndex:         tick.IsIndex,
+		Timestamp:       formatQuoteTime(tick, "timestamp", tick.Timestamp),
+		LastPrice:       tick.LastPrice,
+		NetChange:       tick.NetChange,
+		OHLC:            mapOHLC(ohlc),
+		UpdatedAt:       formatQuoteTime(tick, "updated_at", tick.UpdatedAt),
 	}
 }
 
@@ -57,10 +92,10 @@ func mapTickToOHLCData(tick *models.TickerData) interface{} {
 		LastPrice:         tick.LastPrice,
 		VolumeTraded:      tick.VolumeTraded,
 		AverageTradePrice: tick.AverageTradePrice,
-		Timestamp:         tick.Timestamp.Format("2006-01-02 15:04:05"),
-		LastTradeTime:     tick.LastTradeTime.Format("2006-01-02 15:04:05"),
+		Timestamp:         formatQuoteTime(tick, "timestamp", tick.Timestamp),
+		LastTradeTime:     formatQuoteTime(tick, "last_trade_time", tick.LastTradeTime),
 		OHLC:              mapOHLC(ohlc),
-		UpdatedAt:         tick.UpdatedAt.Format("2006-01-02 15:04:05"),
+		UpdatedAt:         formatQuoteTime(tick, "updated_at", tick.UpdatedAt),
 	}
 }
 
@@ -68,9 +103,54 @@ func mapTickToLTPData(tick *models.TickerData) interface{} {
 	return models.LTPData{
 		InstrumentToken: tick.InstrumentToken,
 		LastPrice:       tick.LastPrice,
-		Timestamp:       tick.Timestamp.Format("2006-01-02 15:04:05"),
-		UpdatedAt:       tick.UpdatedAt.Format("2006-01-02 15:04:05"),
+		Timestamp:       formatQuoteTime(tick, "timestamp", tick.Timestamp),
+		UpdatedAt:       formatQuoteTime(tick, "updated_at", tick.UpdatedAt),
+	}
+}
+
+// mapTickToSummaryData maps the tick to a watchlist summary
+// The change is from the previous close, and omitted with the range position when they cannot be computed
+func mapTickToSummaryData(tick *models.TickerData) interface{} {
+	ohlc, err := tick.GetOHLC()
+	if err != nil {
+		log.Printf("Error getting OHLC data: %v", err)
+	}
+
+	summary := models.QuoteSummaryData{
+		LastPrice: tick.LastPrice,
+		NetChange: tick.NetChange,
+		DayHigh:   ohlc.High,
+		DayLow:    ohlc.Low,
+	}
+	// the ohlc close is the previous day close
+	if ohlc.Close > 0 {
+		summary.NetChange = roundTo2(tick.LastPrice - ohlc.Close)
+		changePercent := roundTo2(summary.NetChange / ohlc.Close * 100)
+		summary.ChangePercent = &changePercent
+	}
+	if ohlc.High > ohlc.Low {
+		position := (tick.LastPrice - ohlc.Low) / (ohlc.High - ohlc.Low)
+		position = math.Round(math.Min(math.Max(position, 0), 1)*10000) / 10000
+		summary.RangePosition = &position
+	}
+	return summary
+}
+
+func roundTo2(value float64) float64 {
+	return math.Round(value*100) / 100
+}
+
+// mapTradability returns if the instrument is tradable, and the reason when it is not
+// Indices are never tradable, other instruments are suspended when the feed's tradable flag is off
+// or when the instrument master no longer lists them, see QuoteService.GetSuspended
+func mapTradability(tick *models.TickerData, suspended bool) (bool, string) {
+	if tick.IsIndex {
+		return false, "index"
+	}
+	if !tick.IsTradable || suspended {
+		return false, "suspended"
 	}
+	return true, ""
 }
 
 func mapOHLC(ohlc models.TickerDataOHLC) models.OHLC {
@@ -91,3 +171,26 @@ func mapDepthItems(items [5]models.TickerDataDepthItem) [5]models.DepthItem {
 	}
 	return mappedItems
 }
+
+// quoteTimeLayout is the layout of the timestamps in the quote responses, in the market timezone
+const quoteTimeLayout = "2006-01-02 15:04:05"
+
+// minQuoteTime is the earliest plausible tick timestamp, earlier ones are treated as malformed
+var minQuoteTime = time.Date(2000, 1, 1, 0, 0, 0, 0, service.MarketLocation)
+
+// formatQuoteTime formats the tick timestamp in the market timezone, whatever zone it was read in
+// An unset timestamp is empty, and a malformed one is logged and left empty instead of failing the quote
+func formatQuoteTime(tick *models.TickerData, field string, t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	if t.Before(minQuoteTime) {
+		zaplogger.Warn("Malformed tick timestamp", zaplogger.Fields{
+			"instrument": tick.Instrument,
+			"field":      field,
+			"value":      t.String(),
+		})
+		return ""
+	}
+	return t.In(service.MarketLocation).Format(quoteTimeLayout)
+}
@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"encoding/json"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/nsvirk/moneybotsapi/internal/models"
+	"github.com/nsvirk/moneybotsapi/internal/service"
+)
+
+func TestFormatQuoteTime(t *testing.T) {
+	tests := []struct {
+		name string
+		t    time.Time
+		want string
+	}{
+		{name: "utc converted to ist", t: time.Date(2024, 10, 15, 4, 0, 5, 0, time.UTC), want: "2024-10-15 09:30:05"},
+		{name: "ist kept", t: time.Date(2024, 10, 15, 9, 30, 5, 0, service.MarketLocation), want: "2024-10-15 09:30:05"},
+		{name: "other zone converted to ist", t: time.Date(2024, 10, 15, 0, 0, 5, 0, time.FixedZone("EDT", -4*3600)), want: "2024-10-15 09:30:05"},
+		{name: "unset", t: time.Time{}, want: ""},
+		{name: "malformed epoch", t: time.Unix(0, 0), want: ""},
+	}
+
+	tick := &models.TickerData{Instrument: "NSE:INFY"}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := formatQuoteTime(tick, "timestamp", tt.t); got != tt.want {
+				t.Errorf("formatQuoteTime() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMapTradability(t *testing.T) {
+	tests := []struct {
+		name         string
+		tick         *models.TickerData
+		suspended    bool
+		wantTradable bool
+		wantReason   string
+	}{
+		{name: "tradable equity", tick: &models.TickerData{Instrument: "NSE:INFY", IsTradable: true}, wantTradable: true},
+		{name: "index", tick: &models.TickerData{Instrument: "NSE:NIFTY 50", IsIndex: true}, wantReason: "index"},
+		{name: "suspended by the feed", tick: &models.TickerData{Instrument: "NSE:INFY"}, wantReason: "suspended"},
+		{name: "suspended by the instrument master", tick: &models.TickerData{Instrument: "NFO:NIFTY24OCT1024500CE", IsTradable: true}, suspended: true, wantReason: "suspended"},
+		{name: "index listed as suspended", tick: &models.TickerData{Instrument: "NSE:NIFTY 50", IsIndex: true}, suspended: true, wantReason: "index"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tradable, reason := mapTradability(tt.tick, tt.suspended)
+			if tradable != tt.wantTradable || reason != tt.wantReason {
+				t.Errorf("mapTradability() = (%v, %q), want (%v, %q)", tradable, reason, tt.wantTradable, tt.wantReason)
+			}
+		})
+	}
+}
+
+func TestMapTickToSummaryData(t *testing.T) {
+	float := func(v float64) *float64 { return &v }
+	tests := []struct {
+		name              string
+		lastPrice         float64
+		netChange         float64
+		ohlc              models.TickerDataOHLC
+		wantNetChange     float64
+		wantChangePercent *float64
+		wantRangePosition *float64
+	}{
+		{
+			name:      "change from the previous close",
+			lastPrice: 1050, ohlc: models.TickerDataOHLC{Open: 1000, High: 1100, Low: 950, Close: 1000},
+			wantNetChange: 50, wantChangePercent: float(5), wantRangePosition: float(0.6667),
+		},
+		{
+			name:      "loss from the previous close",
+			lastPrice: 990, ohlc: models.TickerDataOHLC{Open: 1000, High: 1000, Low: 980, Close: 1020},
+			wantNetChange: -30, wantChangePercent: float(-2.94), wantRangePosition: float(0.5),
+		},
+		{
+			name:      "no previous close",
+			lastPrice: 1050, netChange: 12.5, ohlc: models.TickerDataOHLC{Open: 1000, High: 1100, Low: 950},
+			wantNetChange: 12.5, wantRangePosition: float(0.6667),
+		},
+		{
+			name:      "flat day range",
+			lastPrice: 1000, ohlc: models.TickerDataOHLC{Open: 1000, High: 1000, Low: 1000, Close: 1000},
+			wantChangePercent: float(0),
+		},
+		{
+			name:      "last price above the day high",
+			lastPrice: 1120, ohlc: models.TickerDataOHLC{Open: 1000, High: 1100, Low: 950, Close: 1000},
+			wantNetChange: 120, wantChangePercent: float(12), wantRangePosition: float(1),
+		},
+	}
+
+	equal := func(got, want *float64) bool {
+		return got == nil && want == nil || got != nil && want != nil && *got == *want
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ohlc, _ := json.Marshal(tt.ohlc)
+			tick := &models.TickerData{Instrument: "NSE:INFY", LastPrice: tt.lastPrice, NetChange: tt.netChange, OHLC: ohlc}
+			got := mapTickToSummaryData(tick).(models.QuoteSummaryData)
+			if got.NetChange != tt.wantNetChange {
+				t.Errorf("NetChange = %v, want %v", got.NetChange, tt.wantNetChange)
+			}
+			if !equal(got.ChangePercent, tt.wantChangePercent) {
+				t.Errorf("ChangePercent = %v, want %v", fmtFloat(got.ChangePercent), fmtFloat(tt.wantChangePercent))
+			}
+			if !equal(got.RangePosition, tt.wantRangePosition) {
+				t.Errorf("RangePosition = %v, want %v", fmtFloat(got.RangePosition), fmtFloat(tt.wantRangePosition))
+			}
+			if got.DayHigh != tt.ohlc.High || got.DayLow != tt.ohlc.Low {
+				t.Errorf("day range = %v-%v, want %v-%v", got.DayLow, got.DayHigh, tt.ohlc.Low, tt.ohlc.High)
+			}
+		})
+	}
+}
+
+// fmtFloat formats an optional value for the test errors
+func fmtFloat(v *float64) string {
+	if v == nil {
+		return "nil"
+	}
+	return strconv.FormatFloat(*v, 'f', -1, 64)
+}
@@ -2,23 +2,29 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 	"net/url"
+	"strconv"
 	"time"
 
 	"github.com/labstack/echo/v4"
+	"github.com/nsvirk/moneybotsapi/internal/api/middleware"
 	"github.com/nsvirk/moneybotsapi/internal/service"
 	"github.com/nsvirk/moneybotsapi/pkg/utils/response"
+	"github.com/nsvirk/moneybotsapi/pkg/utils/zaplogger"
 )
 
 // SessionHandler is the handler for the session API
 type SessionHandler struct {
-	service *service.SessionService
+	service      *service.SessionService
+	loginLimiter *service.LoginLimiter
+	quotaTracker *service.QuotaTracker
 }
 
 // NewSessionHandler creates a new handler for the session API
-func NewSessionHandler(service *service.SessionService) *SessionHandler {
-	return &SessionHandler{service: service}
+func NewSessionHandler(service *service.SessionService, loginLimiter *service.LoginLimiter, quotaTracker *service.QuotaTracker) *SessionHandler {
+	return &SessionHandler{service: service, loginLimiter: loginLimiter, quotaTracker: quotaTracker}
 }
 
 // GenerateSession generates a new session for the given user
@@ -40,6 +46,13 @@ func (h *SessionHandler) GenerateSession(c echo.Context) error {
 		return response.ErrorResponse(c, http.StatusBadRequest, "InputException", "Either `totp_value` or `totp_secret` is required")
 	}
 
+	// reject logins while the user id is locked out from the ip
+	ip := c.RealIP()
+	if lockedUntil := h.loginLimiter.LockedUntil(userid, ip); !lockedUntil.IsZero() {
+		c.Response().Header().Set("Retry-After", strconv.Itoa(int(time.Until(lockedUntil).Seconds())+1))
+		return response.ErrorResponse(c, http.StatusTooManyRequests, "LockoutException", "Too many failed login attempts, try again later")
+	}
+
 	// generate the totp value, if top_secret is provided
 	if totpSecret != "" {
 		totpValueGenerated, err := h.service.GenerateTOTP(totpSecret)
@@ -51,10 +64,21 @@ func (h *SessionHandler) GenerateSession(c echo.Context) error {
 	}
 
 	// generate the session
+	// only the credentials rejected by Kite count towards the lockout, not the failures to reach it
 	sessionData, err := h.service.GenerateSession(userid, password, totpValue)
 	if err != nil {
-		return response.ErrorResponse(c, http.StatusUnauthorized, "AuthenticationException", err.Error())
+		var loginErr *service.LoginError
+		if errors.As(err, &loginErr) && loginErr.Reason == service.LoginRejected && h.loginLimiter.RecordFailure(userid, ip) {
+			zaplogger.Warn("Login locked out after repeated failures", zaplogger.Fields{
+				"event":   "login_lockout",
+				"user_id": userid,
+				"ip":      ip,
+			})
+		}
+		status, errorType := loginErrorStatus(err)
+		return response.ErrorResponse(c, status, errorType, err.Error())
 	}
+	h.loginLimiter.RecordSuccess(userid, ip)
 
 	// set the cookies
 	// Cookie 1: user_id
@@ -98,7 +122,26 @@ func (h *SessionHandler) GenerateSession(c echo.Context) error {
 	}
 	c.SetCookie(kfSessionCookie)
 
-	return response.SuccessResponse(c, sessionData)
+	// issue the access token, when enabled
+	login, err := service.NewSessionLogin(sessionData)
+	if err != nil {
+		return response.ErrorResponse(c, http.StatusInternalServerError, "ServerException", err.Error())
+	}
+
+	return response.SuccessResponse(c, login)
+}
+
+// loginErrorStatus returns the status and the error type of a failed login, Kite failures are a 502,
+// rejected credentials and disabled users a 401, and the other errors are internal
+func loginErrorStatus(err error) (int, string) {
+	var loginErr *service.LoginError
+	if !errors.As(err, &loginErr) {
+		return http.StatusInternalServerError, "ServerException"
+	}
+	if loginErr.Reason == service.LoginUpstream {
+		return http.StatusBadGateway, "UpstreamException"
+	}
+	return http.StatusUnauthorized, "AuthenticationException"
 }
 
 // GenerateTOTP generates a TOTP value for the given secret
@@ -203,3 +246,16 @@ func (h *SessionHandler) CheckEnctokenValid(c echo.Context) error {
 	}
 	return response.SuccessResponse(c, enctokenValid)
 }
+
+// GetUsage returns the usage of the daily quota of the authorized user
+func (h *SessionHandler) GetUsage(c echo.Context) error {
+	session, err := middleware.GetUserSessionFromEchoContext(c)
+	if err != nil {
+		return response.ErrorResponse(c, http.StatusUnauthorized, "AuthorizationException", err.Error())
+	}
+	usage, err := h.quotaTracker.Usage(session.UserId, h.quotaTracker.LimitFor(session))
+	if err != nil {
+		return response.ErrorResponse(c, http.StatusInternalServerError, "ServerException", err.Error())
+	}
+	return response.SuccessResponse(c, usage)
+}
@@ -0,0 +1,34 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/nsvirk/moneybotsapi/internal/service"
+)
+
+func TestLoginErrorStatus(t *testing.T) {
+	tests := []struct {
+		name          string
+		err           error
+		wantStatus    int
+		wantErrorType string
+	}{
+		{name: "rejected", err: &service.LoginError{Reason: service.LoginRejected, Err: errors.New("Invalid username or password.")}, wantStatus: http.StatusUnauthorized, wantErrorType: "AuthenticationException"},
+		{name: "disabled", err: &service.LoginError{Reason: service.LoginDisabled, Err: errors.New("`user_id` AB1234 is disabled")}, wantStatus: http.StatusUnauthorized, wantErrorType: "AuthenticationException"},
+		{name: "upstream", err: &service.LoginError{Reason: service.LoginUpstream, Err: errors.New("connection refused")}, wantStatus: http.StatusBadGateway, wantErrorType: "UpstreamException"},
+		{name: "wrapped upstream", err: fmt.Errorf("login: %w", &service.LoginError{Reason: service.LoginUpstream, Err: errors.New("timeout")}), wantStatus: http.StatusBadGateway, wantErrorType: "UpstreamException"},
+		{name: "internal", err: errors.New("failed to upsert session"), wantStatus: http.StatusInternalServerError, wantErrorType: "ServerException"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, errorType := loginErrorStatus(tt.err)
+			if status != tt.wantStatus || errorType != tt.wantErrorType {
+				t.Errorf("loginErrorStatus() = %d, %q, want %d, %q", status, errorType, tt.wantStatus, tt.wantErrorType)
+			}
+		})
+	}
+}
@@ -3,13 +3,18 @@ package handlers
 
 import (
 	"fmt"
+	"log"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/gorilla/websocket"
 	"github.com/labstack/echo/v4"
 	"github.com/nsvirk/moneybotsapi/internal/api/middleware"
+	"github.com/nsvirk/moneybotsapi/internal/models"
 	"github.com/nsvirk/moneybotsapi/internal/service"
 	"github.com/nsvirk/moneybotsapi/pkg/utils/response"
-	"gorm.io/gorm"
 )
 
 // StreamHandler is the handler for the stream API
@@ -18,14 +23,15 @@ type StreamHandler struct {
 }
 
 // NewStreamHandler creates a new handler for the stream API
-func NewStreamHandler(db *gorm.DB) *StreamHandler {
-	return &StreamHandler{
-		service: service.NewStreamService(db),
-	}
+func NewStreamHandler(service *service.StreamService) *StreamHandler {
+	return &StreamHandler{service: service}
 }
 
+// StreamRequestBody is the request body for the StreamTickerData endpoint
+// `batch` sends the updated ticks of each batch interval as a single array frame
 type StreamRequestBody struct {
 	Instruments []string `json:"instruments"`
+	Batch       bool     `json:"batch"`
 }
 
 // StreamTickerData streams the ticker data for the given instruments
@@ -43,12 +49,115 @@ func (h *StreamHandler) StreamTickerData(c echo.Context) error {
 	ctx := c.Request().Context()
 	errChan := make(chan error, 1)
 
-	go h.service.RunTickerStream(ctx, c, userId, enctoken, req.Instruments, errChan)
+	go h.service.RunTickerStream(ctx, c, userId, enctoken, req.Instruments, req.Batch, errChan)
 
 	select {
 	case <-ctx.Done():
 		return nil
 	case err := <-errChan:
-		return response.ErrorResponse(c, http.StatusInternalServerError, "ServerError", fmt.Sprintf("Ticker error: %v", err))
+		// a nil error ends a drained stream on shutdown
+		if err == nil {
+			return nil
+		}
+		return response.ErrorResponse(c, http.StatusInternalServerError, "ServerException", fmt.Sprintf("Ticker error: %v", err))
 	}
 }
+
+// streamUpgrader upgrades the socket streams, browsers are only upgraded from the same origin
+var streamUpgrader = websocket.Upgrader{
+	HandshakeTimeout: 10 * time.Second,
+}
+
+// StreamTickerSocket streams the ticker data over a websocket
+// The client subscribes and unsubscribes instruments with `{"action": "subscribe", "instruments": [...], "mode": "ltp"}`
+// messages, and gets their ticks shaped like the quote, ohlc or ltp APIs, see service.StreamSocketMessage
+func (h *StreamHandler) StreamTickerSocket(c echo.Context) error {
+	userId, enctoken, err := middleware.GetUserIdEnctokenFromEchoContext(c)
+	if err != nil {
+		return response.ErrorResponse(c, http.StatusUnauthorized, "AuthorizationException", err.Error())
+	}
+	if !websocket.IsWebSocketUpgrade(c.Request()) {
+		return response.ErrorResponse(c, http.StatusBadRequest, "InputException", "websocket upgrade required")
+	}
+
+	// the ticker is connected before the upgrade, so its errors are still sent as a response
+	ctx := c.Request().Context()
+	if err := h.service.ConnectTicker(ctx, userId, enctoken); err != nil {
+		return response.ErrorResponse(c, http.StatusInternalServerError, "ServerException", fmt.Sprintf("Ticker error: %v", err))
+	}
+
+	conn, err := streamUpgrader.Upgrade(c.Response(), c.Request(), nil)
+	if err != nil {
+		// the upgrader has already responded with the error
+		return nil
+	}
+	defer conn.Close()
+
+	clientID := c.Response().Header().Get(echo.HeaderXRequestID)
+	if clientID == "" {
+		clientID = fmt.Sprintf("client-%d", time.Now().UnixNano())
+	}
+	if err := h.service.RunTickerSocket(ctx, conn, clientID, mapStreamTick); err != nil {
+		log.Printf("Error streaming to socket client %s: %v", clientID, err)
+	}
+	return nil
+}
+
+// StreamTickerEvents streams the ticker data of the `instruments` as server-sent events, for the clients
+// which cannot open a websocket, the ticks are sent like the socket ticks of the `mode`, which defaults to quote
+// The instruments are comma separated or repeated, and a reconnected client resumes from its `Last-Event-ID`
+func (h *StreamHandler) StreamTickerEvents(c echo.Context) error {
+	userId, enctoken, err := middleware.GetUserIdEnctokenFromEchoContext(c)
+	if err != nil {
+		return response.ErrorResponse(c, http.StatusUnauthorized, "AuthorizationException", err.Error())
+	}
+
+	var instruments []string
+	for _, param := range c.QueryParams()["instruments"] {
+		for _, instrument := range strings.Split(param, ",") {
+			if instrument = strings.TrimSpace(instrument); instrument != "" {
+				instruments = append(instruments, instrument)
+			}
+		}
+	}
+	if len(instruments) == 0 {
+		return response.ErrorResponse(c, http.StatusBadRequest, "InputException", "`instruments` is required")
+	}
+
+	mode := c.QueryParam("mode")
+	if mode == "" {
+		mode = service.StreamModeQuote
+	}
+	if !service.IsStreamMode(mode) {
+		return response.ErrorResponse(c, http.StatusBadRequest, "InputException", "`mode` must be one of quote, ohlc or ltp")
+	}
+
+	var lastEventID int64
+	if header := c.Request().Header.Get("Last-Event-ID"); header != "" {
+		if lastEventID, err = strconv.ParseInt(header, 10, 64); err != nil {
+			return response.ErrorResponse(c, http.StatusBadRequest, "InputException", "Invalid `Last-Event-ID` header")
+		}
+	}
+
+	if err := h.service.RunTickerEvents(c.Request().Context(), c, userId, enctoken, instruments, mode, lastEventID, mapStreamTick); err != nil {
+		return response.ErrorResponse(c, http.StatusInternalServerError, "ServerException", fmt.Sprintf("Ticker error: %v", err))
+	}
+	return nil
+}
+
+// mapStreamTick maps a tick of a socket or event stream to the payload of the quote, ohlc or ltp APIs
+func mapStreamTick(mode string, tick *models.TickerData) interface{} {
+	switch mode {
+	case service.StreamModeLTP:
+		return mapTickToLTPData(tick)
+	case service.StreamModeOHLC:
+		return mapTickToOHLCData(tick)
+	default:
+		return mapTickToQuoteData(tick)
+	}
+}
+
+// Drain ends the open streams on server shutdown
+func (h *StreamHandler) Drain() {
+	h.service.Drain()
+}
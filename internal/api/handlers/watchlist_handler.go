@@ -0,0 +1,191 @@
+// Package handlers contains the handlers for the API
+package handlers
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/nsvirk/moneybotsapi/internal/api/middleware"
+	"github.com/nsvirk/moneybotsapi/internal/config"
+	"github.com/nsvirk/moneybotsapi/internal/models"
+	"github.com/nsvirk/moneybotsapi/internal/service"
+	"github.com/nsvirk/moneybotsapi/pkg/utils/response"
+)
+
+// watchlistMaxName is the max length of a watchlist name
+const watchlistMaxName = 100
+
+// WatchlistHandler is the handler for the watchlists of the users
+type WatchlistHandler struct {
+	cfg     *config.Config
+	service *service.WatchlistService
+}
+
+// NewWatchlistHandler creates a new WatchlistHandler
+func NewWatchlistHandler(cfg *config.Config, watchlistService *service.WatchlistService) *WatchlistHandler {
+	return &WatchlistHandler{cfg: cfg, service: watchlistService}
+}
+
+// WatchlistQuotesResponseData is the response data for the GetWatchlistQuotes endpoint,
+// instruments without a quote have a null quote
+type WatchlistQuotesResponseData struct {
+	Watchlist *models.WatchlistModel `json:"watchlist"`
+	Quotes    map[string]interface{} `json:"quotes"`
+}
+
+// CreateWatchlist creates a watchlist of the user
+func (h *WatchlistHandler) CreateWatchlist(c echo.Context) error {
+	userId, _, err := middleware.GetUserIdEnctokenFromEchoContext(c)
+	if err != nil {
+		return response.NewError(response.ErrUnauthorized, err.Error())
+	}
+	req, err := h.bindWatchlistRequest(c)
+	if err != nil {
+		return err
+	}
+
+	watchlist, err := h.service.WithContext(c.Request().Context()).CreateWatchlist(userId, req)
+	if err != nil {
+		return response.NewError(response.ErrInternal, err.Error())
+	}
+	return response.SuccessResponse(c, watchlist)
+}
+
+// GetWatchlists gets the watchlists of the user
+func (h *WatchlistHandler) GetWatchlists(c echo.Context) error {
+	userId, _, err := middleware.GetUserIdEnctokenFromEchoContext(c)
+	if err != nil {
+		return response.NewError(response.ErrUnauthorized, err.Error())
+	}
+	watchlists, err := h.service.WithContext(c.Request().Context()).GetWatchlists(userId)
+	if err != nil {
+		return response.NewError(response.ErrInternal, err.Error())
+	}
+	return response.SuccessResponse(c, watchlists)
+}
+
+// GetWatchlist gets a watchlist of the user
+func (h *WatchlistHandler) GetWatchlist(c echo.Context) error {
+	userId, id, err := watchlistUserIdAndId(c)
+	if err != nil {
+		return err
+	}
+	watchlist, err := h.service.WithContext(c.Request().Context()).GetWatchlist(userId, id)
+	if err != nil {
+		return response.NewError(response.ErrInternal, err.Error())
+	}
+	if watchlist == nil {
+		return response.NewError(response.ErrNotFound, fmt.Sprintf("Watchlist %d not found", id))
+	}
+	return response.SuccessResponse(c, watchlist)
+}
+
+// UpdateWatchlist replaces the name and instruments of a watchlist of the user
+func (h *WatchlistHandler) UpdateWatchlist(c echo.Context) error {
+	userId, id, err := watchlistUserIdAndId(c)
+	if err != nil {
+		return err
+	}
+	req, err := h.bindWatchlistRequest(c)
+	if err != nil {
+		return err
+	}
+
+	watchlist, err := h.service.WithContext(c.Request().Context()).UpdateWatchlist(userId, id, req)
+	if err != nil {
+		return response.NewError(response.ErrInternal, err.Error())
+	}
+	if watchlist == nil {
+		return response.NewError(response.ErrNotFound, fmt.Sprintf("Watchlist %d not found", id))
+	}
+	return response.SuccessResponse(c, watchlist)
+}
+
+// DeleteWatchlist deletes a watchlist of the user
+func (h *WatchlistHandler) DeleteWatchlist(c echo.Context) error {
+	userId, id, err := watchlistUserIdAndId(c)
+	if err != nil {
+		return err
+	}
+	deleted, err := h.service.WithContext(c.Request().Context()).DeleteWatchlist(userId, id)
+	if err != nil {
+		return response.NewError(response.ErrInternal, err.Error())
+	}
+	if !deleted {
+		return response.NewError(response.ErrNotFound, fmt.Sprintf("Watchlist %d not found", id))
+	}
+	return response.SuccessResponse(c, fmt.Sprintf("Watchlist %d deleted", id))
+}
+
+// GetWatchlistQuotes gets the summary quote of each instrument of a watchlist of the user
+func (h *WatchlistHandler) GetWatchlistQuotes(c echo.Context) error {
+	userId, id, err := watchlistUserIdAndId(c)
+	if err != nil {
+		return err
+	}
+	watchlist, tickDataMap, err := h.service.WithContext(c.Request().Context()).GetWatchlistQuotes(userId, id)
+	if err != nil {
+		return response.NewError(response.ErrInternal, fmt.Sprintf("Error fetching tick data: %v", err))
+	}
+	if watchlist == nil {
+		return response.NewError(response.ErrNotFound, fmt.Sprintf("Watchlist %d not found", id))
+	}
+
+	quotes := make(map[string]interface{}, len(watchlist.Instruments))
+	for _, instrument := range watchlist.Instruments {
+		quotes[instrument] = nil
+		if tickData, ok := tickDataMap[instrument]; ok {
+			quotes[instrument] = mapTickToSummaryData(tickData)
+		}
+	}
+	return response.SuccessResponse(c, WatchlistQuotesResponseData{Watchlist: watchlist, Quotes: quotes})
+}
+
+// bindWatchlistRequest binds and validates the watchlist request, duplicate instruments are dropped
+func (h *WatchlistHandler) bindWatchlistRequest(c echo.Context) (models.WatchlistRequest, error) {
+	var req models.WatchlistRequest
+	if err := c.Bind(&req); err != nil {
+		return req, response.NewError(response.ErrValidation, "Invalid request body")
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		return req, response.NewError(response.ErrValidation, "`name` is required")
+	}
+	if len(req.Name) > watchlistMaxName {
+		return req, response.NewError(response.ErrValidation, fmt.Sprintf("`name` must be at most %d characters", watchlistMaxName))
+	}
+
+	instruments := make([]string, 0, len(req.Instruments))
+	seen := make(map[string]bool, len(req.Instruments))
+	for _, instrument := range req.Instruments {
+		instrument = strings.TrimSpace(instrument)
+		exchange, symbol, ok := strings.Cut(instrument, ":")
+		if !ok || exchange == "" || symbol == "" {
+			return req, response.NewError(response.ErrValidation, fmt.Sprintf("Invalid instrument `%s`, must be `EXCHANGE:SYMBOL`", instrument))
+		}
+		if !seen[instrument] {
+			seen[instrument] = true
+			instruments = append(instruments, instrument)
+		}
+	}
+	if len(instruments) > h.cfg.WatchlistMaxInstr {
+		return req, response.NewError(response.ErrValidation, fmt.Sprintf("A watchlist can have at most %d instruments", h.cfg.WatchlistMaxInstr))
+	}
+	req.Instruments = instruments
+	return req, nil
+}
+
+// watchlistUserIdAndId gets the user id from the context and the watchlist id from the path
+func watchlistUserIdAndId(c echo.Context) (string, uint, error) {
+	userId, _, err := middleware.GetUserIdEnctokenFromEchoContext(c)
+	if err != nil {
+		return "", 0, response.NewError(response.ErrUnauthorized, err.Error())
+	}
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil || id == 0 {
+		return "", 0, response.NewError(response.ErrValidation, "Invalid watchlist `id`")
+	}
+	return userId, uint(id), nil
+}
@@ -0,0 +1,98 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/nsvirk/moneybotsapi/internal/models"
+)
+
+// freezeQuantity formats a freeze quantity of a response, none for instruments without a limit
+func freezeQuantity(qty *uint32) string {
+	if qty == nil {
+		return "none"
+	}
+	b, _ := json.Marshal(*qty)
+	return string(b)
+}
+
+func TestInstrumentLimits(t *testing.T) {
+	e, db := memoryServer(t)
+
+	option := models.InstrumentModel{InstrumentToken: 999101, Tradingsymbol: "NIFTY26OCT25000CE", Name: "NIFTY", Strike: 25000,
+		LotSize: 75, InstrumentType: "CE", Segment: "NFO-OPT", Exchange: "NFO"}
+	if err := db.Create(&option).Error; err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if err := db.Create(&models.FreezeLimitModel{Exchange: "NFO", Name: "NIFTY", FreezeQty: 1800}).Error; err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	ohlc, _ := json.Marshal(models.TickerDataOHLC{Open: 110, High: 125, Low: 105, Close: 112})
+	depth, _ := json.Marshal(models.TickerDataDepth{})
+	if err := db.Create(&models.TickerData{Instrument: "NFO:NIFTY26OCT25000CE", InstrumentToken: 999101, Mode: "full", IsTradable: true,
+		Timestamp: time.Now(), LastPrice: 120, OHLC: ohlc, Depth: depth}).Error; err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	t.Cleanup(func() {
+		db.Where("instrument_token = ?", 999101).Delete(&models.InstrumentModel{})
+		db.Where("instrument_token = ?", 999101).Delete(&models.TickerData{})
+		db.Where("exchange = ? AND name = ?", "NFO", "NIFTY").Delete(&models.FreezeLimitModel{})
+	})
+
+	tests := []struct {
+		instrument    string
+		wantFreezeQty string
+	}{
+		{"NFO:NIFTY26OCT25000CE", "1800"},
+		{"NSE:INFY", "none"},
+	}
+
+	t.Run("limits", func(t *testing.T) {
+		rec := serve(e, http.MethodGet, APIV1Prefix+"/instruments/limits?i=NFO:NIFTY26OCT25000CE&i=NSE:INFY", devAuthorization)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("GET /instruments/limits status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body.String())
+		}
+		var resp struct {
+			Data map[string]models.InstrumentLimits `json:"data"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("failed to decode the response: %v: %s", err, rec.Body.String())
+		}
+		for _, tt := range tests {
+			limits, ok := resp.Data[tt.instrument]
+			if !ok {
+				t.Fatalf("GET /instruments/limits has no %s limits: %s", tt.instrument, rec.Body.String())
+			}
+			if got := freezeQuantity(limits.FreezeQuantity); got != tt.wantFreezeQty {
+				t.Errorf("%s freeze quantity = %s, want %s", tt.instrument, got, tt.wantFreezeQty)
+			}
+		}
+		if got := resp.Data["NFO:NIFTY26OCT25000CE"].LotSize; got != option.LotSize {
+			t.Errorf("NFO:NIFTY26OCT25000CE lot size = %d, want %d", got, option.LotSize)
+		}
+	})
+
+	t.Run("quote", func(t *testing.T) {
+		rec := serve(e, http.MethodGet, APIV1Prefix+"/quote?i=NFO:NIFTY26OCT25000CE&i=NSE:INFY", devAuthorization)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("GET /quote status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body.String())
+		}
+		var resp struct {
+			Data map[string]models.QuoteData `json:"data"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("failed to decode the response: %v: %s", err, rec.Body.String())
+		}
+		for _, tt := range tests {
+			quote, ok := resp.Data[tt.instrument]
+			if !ok {
+				t.Fatalf("GET /quote has no %s quote: %s", tt.instrument, rec.Body.String())
+			}
+			if got := freezeQuantity(quote.FreezeQuantity); got != tt.wantFreezeQty {
+				t.Errorf("%s quote freeze quantity = %s, want %s", tt.instrument, got, tt.wantFreezeQty)
+			}
+		}
+	})
+}
@@ -0,0 +1,50 @@
+package middleware
+
+import (
+	"net"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/nsvirk/moneybotsapi/internal/config"
+	"github.com/nsvirk/moneybotsapi/internal/models"
+	"github.com/nsvirk/moneybotsapi/pkg/utils/response"
+)
+
+// AdminMiddleware creates a new admin authorization middleware
+// It must be used after the AuthMiddleware, which sets the `user_id` in the context
+// Users of MB_API_ADMIN_USER_IDS and users with the `admin` scope are admins
+func AdminMiddleware(cfg *config.Config) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			userID, ok := c.Get("user_id").(string)
+			if ok && cfg.IsAdmin(userID) {
+				return next(c)
+			}
+			if session, err := GetUserSessionFromEchoContext(c); err == nil && session.HasScope(models.ScopeAdmin) {
+				return next(c)
+			}
+			return response.ErrorResponse(c, http.StatusForbidden, "PermissionException", "admin access required")
+		}
+	}
+}
+
+// AdminIPAllowlistMiddleware restricts the admin routes to the IPs and CIDRs of the allowlist,
+// an empty allowlist allows all IPs
+func AdminIPAllowlistMiddleware(cfg *config.Config) echo.MiddlewareFunc {
+	networks := parseNetworks(cfg.AdminIPAllowlist)
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if len(networks) == 0 {
+				return next(c)
+			}
+			ip := net.ParseIP(c.RealIP())
+			for _, network := range networks {
+				if ip != nil && network.Contains(ip) {
+					return next(c)
+				}
+			}
+			return response.ErrorResponse(c, http.StatusForbidden, "PermissionException", "admin access is not allowed from this ip")
+		}
+	}
+}
@@ -2,6 +2,7 @@ package middleware
 
 import (
 	"errors"
+	"fmt"
 	"net/http"
 	"strings"
 
@@ -12,21 +13,32 @@ import (
 	"gorm.io/gorm"
 )
 
+// authRetryAfter is the `Retry-After` seconds of the requests rejected while the session store is unavailable
+const authRetryAfter = "5"
+
 // AuthMiddleware creates a new authorization middleware
 func AuthMiddleware(db *gorm.DB) echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
-			// Get the userId and enctoken from the authorization header
-			userID, enctoken, err := ExtractUserIDEnctokenFromAuthHeader(c)
-			if err != nil {
-				return response.ErrorResponse(c, http.StatusUnauthorized, "AuthorizationException", err.Error())
-			}
-
-			// Verify the session
 			sessionService := service.NewSessionService(db)
-			userSession, err := sessionService.VerifyUserAuthorization(userID, enctoken)
-			if err != nil {
-				return response.ErrorResponse(c, http.StatusUnauthorized, "AuthorizationException", err.Error())
+
+			// Verify the session of the access token, or of the userId and enctoken
+			var userSession *models.SessionModel
+			if accessToken, ok := extractBearerToken(c); ok {
+				var err error
+				userSession, err = sessionService.VerifySessionJWT(accessToken)
+				if err != nil {
+					return authErrorResponse(c, err)
+				}
+			} else {
+				userID, enctoken, err := ExtractUserIDEnctokenFromAuthHeader(c)
+				if err != nil {
+					return response.ErrorResponse(c, http.StatusUnauthorized, "AuthorizationException", err.Error())
+				}
+				userSession, err = sessionService.VerifyUserAuthorization(userID, enctoken)
+				if err != nil {
+					return authErrorResponse(c, err)
+				}
 			}
 
 			// Add session data to context for use in handlers
@@ -44,6 +56,33 @@ func AuthMiddleware(db *gorm.DB) echo.MiddlewareFunc {
 	}
 }
 
+// authErrorResponse responds to a session which failed verification,
+// with a 503 while the session store is unavailable and a 401 otherwise
+func authErrorResponse(c echo.Context, err error) error {
+	if errors.Is(err, service.ErrSessionStoreUnavailable) {
+		c.Response().Header().Set("Retry-After", authRetryAfter)
+		return response.ErrorResponse(c, http.StatusServiceUnavailable, "ServiceUnavailableException", err.Error())
+	}
+	return response.ErrorResponse(c, http.StatusUnauthorized, "AuthorizationException", err.Error())
+}
+
+// ScopeMiddleware creates a middleware requiring the scope from the user's role
+// It must be used after the AuthMiddleware, an authorized user lacking the scope gets a 403
+func ScopeMiddleware(scope string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			session, err := GetUserSessionFromEchoContext(c)
+			if err != nil {
+				return response.ErrorResponse(c, http.StatusUnauthorized, "AuthorizationException", err.Error())
+			}
+			if !session.HasScope(scope) {
+				return response.ErrorResponse(c, http.StatusForbidden, "PermissionException", fmt.Sprintf("`%s` scope required", scope))
+			}
+			return next(c)
+		}
+	}
+}
+
 // ExtractUserIDEnctokenFromAuthHeader extracts the userID and enctoken from the authorization header
 func ExtractUserIDEnctokenFromAuthHeader(c echo.Context) (string, string, error) {
 	// header format is <user_id:enctoken>
@@ -62,6 +101,16 @@ func ExtractUserIDEnctokenFromAuthHeader(c echo.Context) (string, string, error)
 	return userID, enctoken, nil
 }
 
+// extractBearerToken extracts the access token from a `Bearer <access_token>` authorization header
+func extractBearerToken(c echo.Context) (string, bool) {
+	auth := c.Request().Header.Get("Authorization")
+	scheme, token, ok := strings.Cut(auth, " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") || token == "" {
+		return "", false
+	}
+	return token, true
+}
+
 // GetUserIdEnctokenFromEchoContext gets the userId and enctoken from the echo context
 func GetUserIdEnctokenFromEchoContext(c echo.Context) (string, string, error) {
 	userId, ok := c.Get("user_id").(string)
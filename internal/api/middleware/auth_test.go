@@ -0,0 +1,47 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/nsvirk/moneybotsapi/internal/models"
+)
+
+func TestScopeMiddleware(t *testing.T) {
+	tests := []struct {
+		name       string
+		session    *models.SessionModel
+		scope      string
+		wantStatus int
+	}{
+		{name: "no session", scope: models.ScopeQuoteRead, wantStatus: http.StatusUnauthorized},
+		{name: "readonly reads quotes", session: &models.SessionModel{UserId: "AB1234", Role: models.RoleReadOnly}, scope: models.ScopeQuoteRead, wantStatus: http.StatusOK},
+		{name: "readonly writes ticker", session: &models.SessionModel{UserId: "AB1234", Role: models.RoleReadOnly}, scope: models.ScopeTickerWrite, wantStatus: http.StatusForbidden},
+		{name: "no role writes ticker", session: &models.SessionModel{UserId: "AB1234"}, scope: models.ScopeTickerWrite, wantStatus: http.StatusOK},
+		{name: "user administers", session: &models.SessionModel{UserId: "AB1234", Role: models.RoleUser}, scope: models.ScopeAdmin, wantStatus: http.StatusForbidden},
+		{name: "admin administers", session: &models.SessionModel{UserId: "AB1234", Role: models.RoleAdmin}, scope: models.ScopeAdmin, wantStatus: http.StatusOK},
+		{name: "unknown role", session: &models.SessionModel{UserId: "AB1234", Role: "guest"}, scope: models.ScopeQuoteRead, wantStatus: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := echo.New()
+			rec := httptest.NewRecorder()
+			c := e.NewContext(httptest.NewRequest(http.MethodGet, "/", nil), rec)
+			if tt.session != nil {
+				c.Set("user_session", tt.session)
+			}
+			handler := ScopeMiddleware(tt.scope)(func(c echo.Context) error {
+				return c.NoContent(http.StatusOK)
+			})
+			if err := handler(c); err != nil {
+				t.Fatalf("handler() error = %v", err)
+			}
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package middleware
+
+import (
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/nsvirk/moneybotsapi/internal/service"
+	"github.com/nsvirk/moneybotsapi/pkg/utils/response"
+	"github.com/nsvirk/moneybotsapi/pkg/utils/zaplogger"
+)
+
+// CaptureMiddleware records sampled request/response pairs while capturing is enabled
+// Only sizes of the bodies are recorded, and secret headers and query params are redacted
+// The captures are written to disk in the background, see service.RequestCapturer.Enqueue
+func CaptureMiddleware(capturer *service.RequestCapturer) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+			if !capturer.ShouldCapture(req.URL.Path) {
+				return next(c)
+			}
+
+			start := time.Now()
+			err := next(c)
+
+			pathParams := make(map[string]string, len(c.ParamNames()))
+			for i, name := range c.ParamNames() {
+				pathParams[name] = c.ParamValues()[i]
+			}
+
+			capture := &service.RequestCapture{
+				RequestID:     c.Response().Header().Get(echo.HeaderXRequestID),
+				Timestamp:     start,
+				Method:        req.Method,
+				Path:          req.URL.Path,
+				Route:         c.Path(),
+				PathParams:    pathParams,
+				QueryParams:   req.URL.Query(),
+				Headers:       req.Header,
+				Status:        capturedStatus(c, err),
+				RequestBytes:  req.ContentLength,
+				ResponseBytes: c.Response().Size,
+				LatencyMs:     time.Since(start).Milliseconds(),
+			}
+			if !capturer.Enqueue(capture) {
+				zaplogger.Warn("Request capture queue is full, capture dropped", zaplogger.Fields{"path": req.URL.Path})
+			}
+			return err
+		}
+	}
+}
+
+// capturedStatus returns the status of the response, or of the error when it is not rendered yet
+func capturedStatus(c echo.Context, err error) int {
+	if err == nil || c.Response().Committed {
+		return c.Response().Status
+	}
+	return response.StatusOf(err)
+}
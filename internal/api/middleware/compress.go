@@ -0,0 +1,152 @@
+package middleware
+
+import (
+	"bytes"
+	"compress/gzip"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/nsvirk/moneybotsapi/internal/config"
+)
+
+// CompressMiddleware gzips responses of at least minLength bytes when the client accepts gzip
+// Responses with a content type starting with one of the excluded types are never compressed,
+// such as streams and already compressed formats. A negative minLength disables compression.
+func CompressMiddleware(cfg *config.Config) echo.MiddlewareFunc {
+	var excluded []string
+	for _, contentType := range strings.Split(cfg.CompressExcluded, ",") {
+		if contentType = strings.ToLower(strings.TrimSpace(contentType)); contentType != "" {
+			excluded = append(excluded, contentType)
+		}
+	}
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if cfg.CompressMinLength < 0 {
+				return next(c)
+			}
+			res := c.Response()
+			res.Header().Add(echo.HeaderVary, echo.HeaderAcceptEncoding)
+			if !strings.Contains(c.Request().Header.Get(echo.HeaderAcceptEncoding), "gzip") {
+				return next(c)
+			}
+
+			cw := &compressWriter{ResponseWriter: res.Writer, minLength: cfg.CompressMinLength, excluded: excluded}
+			res.Writer = cw
+			defer func() {
+				cw.close()
+				res.Writer = cw.ResponseWriter
+			}()
+			return next(c)
+		}
+	}
+}
+
+// compressWriter buffers the response until it knows if it is compressed,
+// that is on the first write for an excluded content type or once minLength bytes are written
+type compressWriter struct {
+	http.ResponseWriter
+	minLength   int
+	excluded    []string
+	buf         bytes.Buffer
+	gz          *gzip.Writer
+	code        int
+	wroteHeader bool
+	decided     bool
+}
+
+func (w *compressWriter) WriteHeader(code int) {
+	if w.decided {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+	w.code = code
+	w.wroteHeader = true
+}
+
+func (w *compressWriter) Write(b []byte) (int, error) {
+	if !w.decided {
+		if w.Header().Get(echo.HeaderContentType) == "" {
+			w.Header().Set(echo.HeaderContentType, http.DetectContentType(b))
+		}
+		if w.isExcluded() {
+			if err := w.decide(false); err != nil {
+				return 0, err
+			}
+		} else {
+			w.buf.Write(b)
+			if w.buf.Len() < w.minLength {
+				return len(b), nil
+			}
+			return len(b), w.decide(true)
+		}
+	}
+	if w.gz != nil {
+		return w.gz.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush sends a response flushed before reaching minLength uncompressed
+func (w *compressWriter) Flush() {
+	if !w.decided {
+		_ = w.decide(false)
+	}
+	if w.gz != nil {
+		_ = w.gz.Flush()
+	}
+	_ = http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+func (w *compressWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// isExcluded checks if the content type of the response is excluded from compression
+func (w *compressWriter) isExcluded() bool {
+	if w.Header().Get(echo.HeaderContentEncoding) != "" {
+		return true
+	}
+	contentType := strings.ToLower(w.Header().Get(echo.HeaderContentType))
+	for _, excluded := range w.excluded {
+		if strings.HasPrefix(contentType, excluded) {
+			return true
+		}
+	}
+	return false
+}
+
+// decide writes the header and the buffered body, compressed or not
+func (w *compressWriter) decide(compress bool) error {
+	w.decided = true
+	if compress {
+		w.Header().Set(echo.HeaderContentEncoding, "gzip")
+		w.Header().Del(echo.HeaderContentLength)
+		w.gz = gzip.NewWriter(w.ResponseWriter)
+	}
+	if w.wroteHeader {
+		w.ResponseWriter.WriteHeader(w.code)
+	}
+	if w.buf.Len() == 0 {
+		return nil
+	}
+	var err error
+	if w.gz != nil {
+		_, err = w.gz.Write(w.buf.Bytes())
+	} else {
+		_, err = w.ResponseWriter.Write(w.buf.Bytes())
+	}
+	w.buf.Reset()
+	return err
+}
+
+// close writes a response that stayed below minLength uncompressed and ends the gzip stream
+func (w *compressWriter) close() {
+	if !w.decided && (w.wroteHeader || w.buf.Len() > 0) {
+		_ = w.decide(false)
+	}
+	if w.gz != nil {
+		_ = w.gz.Close()
+	}
+}
@@ -0,0 +1,112 @@
+package middleware
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/nsvirk/moneybotsapi/internal/config"
+)
+
+func TestCompressMiddleware(t *testing.T) {
+	largeJSON := `{"data":"` + strings.Repeat("x", 2048) + `"}`
+	smallJSON := `{"data":"x"}`
+	msgpack := string(bytes.Repeat([]byte{0x81, 0xa4}, 1024))
+
+	e := echo.New()
+	e.Use(CompressMiddleware(&config.Config{
+		CompressMinLength: 1024,
+		CompressExcluded:  "text/event-stream, application/msgpack",
+	}))
+	e.GET("/large", func(c echo.Context) error {
+		return c.Blob(http.StatusOK, echo.MIMEApplicationJSON, []byte(largeJSON))
+	})
+	e.GET("/small", func(c echo.Context) error {
+		return c.Blob(http.StatusOK, echo.MIMEApplicationJSON, []byte(smallJSON))
+	})
+	e.GET("/msgpack", func(c echo.Context) error {
+		return c.Blob(http.StatusOK, "application/msgpack", []byte(msgpack))
+	})
+	e.GET("/stream", func(c echo.Context) error {
+		c.Response().Header().Set(echo.HeaderContentType, "text/event-stream")
+		c.Response().WriteHeader(http.StatusOK)
+		for i := 0; i < 100; i++ {
+			if _, err := c.Response().Write([]byte("data: " + strings.Repeat("x", 64) + "\n\n")); err != nil {
+				return err
+			}
+			c.Response().Flush()
+		}
+		return nil
+	})
+
+	tests := []struct {
+		name           string
+		path           string
+		acceptEncoding string
+		wantGzip       bool
+		wantBody       string
+	}{
+		{name: "large json", path: "/large", acceptEncoding: "gzip, deflate", wantGzip: true, wantBody: largeJSON},
+		{name: "large json without gzip accepted", path: "/large", wantBody: largeJSON},
+		{name: "json below the min length", path: "/small", acceptEncoding: "gzip", wantBody: smallJSON},
+		{name: "excluded msgpack", path: "/msgpack", acceptEncoding: "gzip", wantBody: msgpack},
+		{name: "excluded event stream", path: "/stream", acceptEncoding: "gzip", wantBody: strings.Repeat("data: "+strings.Repeat("x", 64)+"\n\n", 100)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.acceptEncoding != "" {
+				req.Header.Set(echo.HeaderAcceptEncoding, tt.acceptEncoding)
+			}
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+
+			if rec.Code != http.StatusOK {
+				t.Fatalf("GET %s status = %d, want %d", tt.path, rec.Code, http.StatusOK)
+			}
+			gzipped := rec.Header().Get(echo.HeaderContentEncoding) == "gzip"
+			if gzipped != tt.wantGzip {
+				t.Fatalf("GET %s gzipped = %v, want %v", tt.path, gzipped, tt.wantGzip)
+			}
+			if vary := rec.Header().Get(echo.HeaderVary); vary != echo.HeaderAcceptEncoding {
+				t.Errorf("GET %s Vary = %q, want %q", tt.path, vary, echo.HeaderAcceptEncoding)
+			}
+
+			body := rec.Body.Bytes()
+			if gzipped {
+				reader, err := gzip.NewReader(rec.Body)
+				if err != nil {
+					t.Fatalf("gzip.NewReader() error = %v", err)
+				}
+				if body, err = io.ReadAll(reader); err != nil {
+					t.Fatalf("reading the gzipped body error = %v", err)
+				}
+			}
+			if string(body) != tt.wantBody {
+				t.Errorf("GET %s body = %d bytes, want %d bytes", tt.path, len(body), len(tt.wantBody))
+			}
+		})
+	}
+}
+
+func TestCompressMiddlewareDisabled(t *testing.T) {
+	e := echo.New()
+	e.Use(CompressMiddleware(&config.Config{CompressMinLength: -1}))
+	e.GET("/large", func(c echo.Context) error {
+		return c.Blob(http.StatusOK, echo.MIMEApplicationJSON, bytes.Repeat([]byte("x"), 4096))
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/large", nil)
+	req.Header.Set(echo.HeaderAcceptEncoding, "gzip")
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	if encoding := rec.Header().Get(echo.HeaderContentEncoding); encoding != "" {
+		t.Errorf("Content-Encoding = %q with compression disabled, want none", encoding)
+	}
+}
@@ -0,0 +1,23 @@
+package middleware
+
+import (
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+// DeprecationMiddleware marks the responses of the unversioned routes as deprecated,
+// with a `Link` to the same route under the versioned prefix
+// The requests already under the prefix are not marked, the group catches their unknown routes too
+func DeprecationMiddleware(prefix string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			path := c.Request().URL.Path
+			if path != prefix && !strings.HasPrefix(path, prefix+"/") {
+				c.Response().Header().Set("Deprecation", "true")
+				c.Response().Header().Set("Link", "<"+prefix+path+`>; rel="successor-version"`)
+			}
+			return next(c)
+		}
+	}
+}
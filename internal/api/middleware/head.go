@@ -0,0 +1,80 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+)
+
+// headRoutes are the read endpoints served for HEAD, of the root and the /api/v1 routes
+// The other GET routes, like /stream/* and /ticker/*, have side effects and answer HEAD with a 405
+var headRoutes = map[string]bool{
+	"/":                   true,
+	"/health":             true,
+	"/ready":              true,
+	"/instruments":        true,
+	"/quote/ltp":          true,
+	"/api/v1/instruments": true,
+	"/api/v1/quote/ltp":   true,
+}
+
+// HeadMiddleware serves HEAD requests of the headRoutes with their GET route, sending its headers without the body
+// It must be registered with `e.Pre` so the request is routed as GET, the `Content-Length` is that of the GET body
+func HeadMiddleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+			if req.Method != http.MethodHead || !headRoutes[req.URL.Path] {
+				return next(c)
+			}
+			req.Method = http.MethodGet
+
+			res := c.Response()
+			hw := &headWriter{ResponseWriter: res.Writer}
+			res.Writer = hw
+			err := next(c)
+			if err != nil {
+				// render the error now, so its length is counted too
+				c.Error(err)
+			}
+			res.Writer = hw.ResponseWriter
+			hw.finish()
+			return nil
+		}
+	}
+}
+
+// headWriter counts the body instead of writing it, and writes the header once the length is known
+type headWriter struct {
+	http.ResponseWriter
+	code   int
+	length int
+}
+
+func (w *headWriter) WriteHeader(code int) {
+	w.code = code
+}
+
+func (w *headWriter) Write(b []byte) (int, error) {
+	w.length += len(b)
+	return len(b), nil
+}
+
+// Flush is a no-op, the header is written when the response is finished
+func (w *headWriter) Flush() {}
+
+func (w *headWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// finish writes the header with the counted body length
+func (w *headWriter) finish() {
+	if w.code == 0 {
+		w.code = http.StatusOK
+	}
+	if w.Header().Get(echo.HeaderContentLength) == "" && w.code != http.StatusNoContent && w.code != http.StatusNotModified {
+		w.Header().Set(echo.HeaderContentLength, strconv.Itoa(w.length))
+	}
+	w.ResponseWriter.WriteHeader(w.code)
+}
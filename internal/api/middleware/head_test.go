@@ -0,0 +1,71 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+)
+
+func TestHeadMiddleware(t *testing.T) {
+	e := echo.New()
+	e.Pre(HeadMiddleware())
+	calls := 0
+	read := func(c echo.Context) error {
+		calls++
+		c.Response().Header().Set("ETag", `"v1"`)
+		return c.JSON(http.StatusOK, map[string]string{"status": "ok"})
+	}
+	e.GET("/", read)
+	e.GET("/quote/ltp", read)
+	e.GET("/api/v1/quote/ltp", read)
+	e.GET("/ticker/start", read)
+	e.GET("/stream/sse", read)
+
+	serve := func(method, path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(method, path, nil))
+		return rec
+	}
+
+	tests := []struct {
+		path       string
+		wantStatus int
+	}{
+		{path: "/", wantStatus: http.StatusOK},
+		{path: "/quote/ltp", wantStatus: http.StatusOK},
+		{path: "/api/v1/quote/ltp", wantStatus: http.StatusOK},
+		{path: "/ticker/start", wantStatus: http.StatusMethodNotAllowed},
+		{path: "/stream/sse", wantStatus: http.StatusMethodNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			calls = 0
+			get := serve(http.MethodGet, tt.path)
+			head := serve(http.MethodHead, tt.path)
+			if head.Code != tt.wantStatus {
+				t.Fatalf("HEAD %s status = %d, want %d", tt.path, head.Code, tt.wantStatus)
+			}
+			if head.Body.Len() != 0 {
+				t.Errorf("HEAD %s body = %q, want empty", tt.path, head.Body.String())
+			}
+			if tt.wantStatus != http.StatusOK {
+				if calls != 1 {
+					t.Errorf("HEAD %s ran the GET handler", tt.path)
+				}
+				return
+			}
+			if got, want := head.Header().Get(echo.HeaderContentLength), strconv.Itoa(get.Body.Len()); got != want {
+				t.Errorf("HEAD %s Content-Length = %s, want %s", tt.path, got, want)
+			}
+			for _, header := range []string{echo.HeaderContentType, "ETag"} {
+				if got, want := head.Header().Get(header), get.Header().Get(header); got != want {
+					t.Errorf("HEAD %s %s = %q, want %q", tt.path, header, got, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,44 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/nsvirk/moneybotsapi/pkg/utils/response"
+)
+
+// inflightRetryAfter is the `Retry-After` seconds of the requests shed over the in-flight cap
+const inflightRetryAfter = "1"
+
+// inflightExempt are the paths which are never shed, so probes keep working under load
+var inflightExempt = map[string]bool{
+	"/health": true,
+	"/ready":  true,
+}
+
+// InflightMiddleware caps the requests handled at once at limit, the requests over the cap are
+// refused with a 503 instead of queueing. A limit of 0 or less disables the cap.
+func InflightMiddleware(limit int) echo.MiddlewareFunc {
+	if limit <= 0 {
+		return func(next echo.HandlerFunc) echo.HandlerFunc {
+			return next
+		}
+	}
+	slots := make(chan struct{}, limit)
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if inflightExempt[c.Path()] {
+				return next(c)
+			}
+			select {
+			case slots <- struct{}{}:
+				defer func() { <-slots }()
+				return next(c)
+			default:
+				c.Response().Header().Set("Retry-After", inflightRetryAfter)
+				return response.ErrorResponse(c, http.StatusServiceUnavailable, "ServiceUnavailableException", "Too many requests in flight, retry shortly")
+			}
+		}
+	}
+}
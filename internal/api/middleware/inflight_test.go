@@ -0,0 +1,65 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+)
+
+func TestInflightMiddleware(t *testing.T) {
+	tests := []struct {
+		name       string
+		limit      int
+		held       int
+		path       string
+		wantStatus int
+	}{
+		{name: "under the cap", limit: 2, held: 1, path: "/quote", wantStatus: http.StatusOK},
+		{name: "over the cap", limit: 2, held: 2, path: "/quote", wantStatus: http.StatusServiceUnavailable},
+		{name: "probes are never shed", limit: 1, held: 1, path: "/health", wantStatus: http.StatusOK},
+		{name: "cap disabled", limit: 0, held: 3, path: "/quote", wantStatus: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := echo.New()
+			release := make(chan struct{})
+			var entered, done sync.WaitGroup
+			// the held requests go through the same middleware as the probed one
+			mw := InflightMiddleware(tt.limit)
+			blocking := mw(func(c echo.Context) error {
+				entered.Done()
+				<-release
+				return c.NoContent(http.StatusOK)
+			})
+			for i := 0; i < tt.held; i++ {
+				entered.Add(1)
+				done.Add(1)
+				go func() {
+					defer done.Done()
+					c := e.NewContext(httptest.NewRequest(http.MethodGet, "/quote", nil), httptest.NewRecorder())
+					c.SetPath("/quote")
+					_ = blocking(c)
+				}()
+			}
+			entered.Wait()
+
+			rec := httptest.NewRecorder()
+			c := e.NewContext(httptest.NewRequest(http.MethodGet, tt.path, nil), rec)
+			c.SetPath(tt.path)
+			_ = mw(func(c echo.Context) error { return c.NoContent(http.StatusOK) })(c)
+			close(release)
+			done.Wait()
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("InflightMiddleware() status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if tt.wantStatus == http.StatusServiceUnavailable && rec.Header().Get("Retry-After") == "" {
+				t.Errorf("InflightMiddleware() Retry-After header missing")
+			}
+		})
+	}
+}
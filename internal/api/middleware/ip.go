@@ -0,0 +1,48 @@
+package middleware
+
+import (
+	"net"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/nsvirk/moneybotsapi/internal/config"
+)
+
+// SetupIPExtractor sets how the client IP of `c.RealIP()` is found, it is used by the login lockout
+// and the admin allowlist so it must not be spoofable
+// Without trusted proxies the IP is the peer address, the `X-Forwarded-For` and `X-Real-IP` headers are ignored
+// With trusted proxies the IP is the last address of `X-Forwarded-For` not of a trusted proxy
+func SetupIPExtractor(e *echo.Echo, cfg *config.Config) {
+	proxies := parseNetworks(cfg.TrustedProxies)
+	if len(proxies) == 0 {
+		e.IPExtractor = echo.ExtractIPDirect()
+		return
+	}
+	options := []echo.TrustOption{echo.TrustLoopback(false), echo.TrustLinkLocal(false), echo.TrustPrivateNet(false)}
+	for _, proxy := range proxies {
+		options = append(options, echo.TrustIPRange(proxy))
+	}
+	e.IPExtractor = echo.ExtractIPFromXFFHeader(options...)
+}
+
+// parseNetworks parses a comma separated list of IPs and CIDRs, an IP is a network of itself
+// The invalid entries are skipped
+func parseNetworks(list string) []*net.IPNet {
+	var networks []*net.IPNet
+	for _, entry := range strings.Split(list, ",") {
+		if entry = strings.TrimSpace(entry); entry == "" {
+			continue
+		}
+		if !strings.Contains(entry, "/") {
+			if ip := net.ParseIP(entry); ip != nil && ip.To4() != nil {
+				entry += "/32"
+			} else {
+				entry += "/128"
+			}
+		}
+		if _, network, err := net.ParseCIDR(entry); err == nil {
+			networks = append(networks, network)
+		}
+	}
+	return networks
+}
@@ -0,0 +1,25 @@
+package middleware
+
+import (
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/nsvirk/moneybotsapi/internal/metrics"
+)
+
+// MetricsMiddleware records the count and latency of the served requests by route for Prometheus
+// The requests matching no route are recorded under the catch-all route, so unknown paths do not add series
+func MetricsMiddleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			start := time.Now()
+			err := next(c)
+			route := c.Path()
+			if route == "" {
+				route = "unmatched"
+			}
+			metrics.RecordHTTPRequest(c.Request().Method, route, capturedStatus(c, err), time.Since(start))
+			return err
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package middleware
+
+import (
+	"github.com/labstack/echo/v4"
+	"github.com/nsvirk/moneybotsapi/internal/repository"
+	"github.com/nsvirk/moneybotsapi/pkg/utils/zaplogger"
+)
+
+// QueryBudgetMiddleware counts the database queries of each request and logs a warning
+// when a request issues more than budget queries. A budget of 0 disables the check.
+func QueryBudgetMiddleware(budget int) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if budget <= 0 {
+				return next(c)
+			}
+
+			ctx := repository.WithQueryCounter(c.Request().Context())
+			c.SetRequest(c.Request().WithContext(ctx))
+
+			err := next(c)
+
+			if count := repository.QueryCount(ctx); count > int64(budget) {
+				zaplogger.Warn("Request exceeded the query budget", zaplogger.Fields{
+					"request_id":  c.Response().Header().Get(echo.HeaderXRequestID),
+					"method":      c.Request().Method,
+					"path":        c.Path(),
+					"query_count": count,
+					"budget":      budget,
+				})
+			}
+			return err
+		}
+	}
+}
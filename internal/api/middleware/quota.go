@@ -0,0 +1,47 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/nsvirk/moneybotsapi/internal/service"
+	"github.com/nsvirk/moneybotsapi/pkg/utils/response"
+	"github.com/nsvirk/moneybotsapi/pkg/utils/zaplogger"
+)
+
+// QuotaMiddleware enforces the daily quota of the user, it must be used after the AuthMiddleware
+// Calls within the quota carry the `X-RateLimit-*` headers, calls beyond it are refused with a 429
+func QuotaMiddleware(tracker *service.QuotaTracker) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			session, err := GetUserSessionFromEchoContext(c)
+			if err != nil {
+				return response.ErrorResponse(c, http.StatusUnauthorized, "AuthorizationException", err.Error())
+			}
+			limit := tracker.LimitFor(session)
+			if limit == 0 {
+				return next(c)
+			}
+
+			// the calls are not refused while the usage cannot be counted
+			usage, allowed, err := tracker.Consume(session.UserId, limit)
+			if err != nil {
+				zaplogger.Warn("Failed to count the quota usage", zaplogger.Fields{"user_id": session.UserId, "error": err})
+				return next(c)
+			}
+			header := c.Response().Header()
+			header.Set("X-RateLimit-Limit", strconv.Itoa(usage.Limit))
+			header.Set("X-RateLimit-Remaining", strconv.Itoa(usage.Remaining))
+			header.Set("X-RateLimit-Reset", strconv.FormatInt(usage.ResetAt.Unix(), 10))
+			if !allowed {
+				header.Set("Retry-After", strconv.Itoa(int(time.Until(usage.ResetAt).Seconds())+1))
+				return response.ErrorResponse(c, http.StatusTooManyRequests, "RateLimitException",
+					fmt.Sprintf("Daily quota of %d calls exceeded, resets at %s", usage.Limit, usage.ResetAt.Format("2006-01-02 15:04:05")))
+			}
+			return next(c)
+		}
+	}
+}
@@ -0,0 +1,17 @@
+package middleware
+
+import (
+	"github.com/labstack/echo/v4"
+	"github.com/nsvirk/moneybotsapi/internal/service"
+)
+
+// StatsMiddleware counts the served requests by their status for the process stats
+func StatsMiddleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			err := next(c)
+			service.RecordRequest(capturedStatus(c, err))
+			return err
+		}
+	}
+}
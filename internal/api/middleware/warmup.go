@@ -0,0 +1,42 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/nsvirk/moneybotsapi/internal/config"
+	"github.com/nsvirk/moneybotsapi/internal/service"
+	"github.com/nsvirk/moneybotsapi/pkg/utils/response"
+	"github.com/nsvirk/moneybotsapi/pkg/utils/zaplogger"
+)
+
+// warmupRetryAfter is the `Retry-After` seconds of the quotes blocked during warmup
+const warmupRetryAfter = "5"
+
+// QuoteWarmupMiddleware gates the quote routes until the API is ready, see ReadinessService
+// In `block` mode the quotes are refused with a 503, in `snapshot` mode the stored quotes are
+// served with the `X-Quotes-Warming-Up` header
+func QuoteWarmupMiddleware(cfg *config.Config, readinessService *service.ReadinessService) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			// the readiness is not known while the instruments cannot be checked, so the quotes are warming up
+			readiness, err := readinessService.GetReadiness()
+			if err == nil && readiness.Ready {
+				return next(c)
+			}
+			if err != nil {
+				zaplogger.Warn("Failed to get the readiness of the quotes", zaplogger.Fields{"error": err})
+			}
+			if cfg.QuoteWarmupMode == config.QuoteWarmupBlock {
+				reason := readiness.Reason()
+				if err != nil {
+					reason = "Instruments cannot be checked yet"
+				}
+				c.Response().Header().Set("Retry-After", warmupRetryAfter)
+				return response.ErrorResponse(c, http.StatusServiceUnavailable, "ServiceUnavailableException", reason)
+			}
+			c.Response().Header().Set("X-Quotes-Warming-Up", "true")
+			return next(c)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"github.com/labstack/echo/v4"
+	"github.com/nsvirk/moneybotsapi/internal/config"
+	"github.com/nsvirk/moneybotsapi/internal/models"
+	"github.com/nsvirk/moneybotsapi/internal/service"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestQuoteWarmupMiddleware(t *testing.T) {
+	service.MarkQuotesRefreshed()
+
+	// openDB opens an in-memory database of its own, with the instruments table unless missing
+	openDB := func(t *testing.T, name string, instruments []models.InstrumentModel, missing bool) *gorm.DB {
+		db, err := gorm.Open(sqlite.Open("file:"+name+"?mode=memory&cache=shared"), &gorm.Config{Logger: logger.Discard})
+		if err != nil {
+			t.Fatalf("failed to open the database: %v", err)
+		}
+		if !missing {
+			if err := db.AutoMigrate(&models.InstrumentModel{}); err != nil {
+				t.Fatalf("AutoMigrate() error = %v", err)
+			}
+		}
+		if len(instruments) > 0 {
+			if err := db.Create(&instruments).Error; err != nil {
+				t.Fatalf("Create() error = %v", err)
+			}
+		}
+		return db
+	}
+	loaded := []models.InstrumentModel{{InstrumentToken: 408065, Exchange: "NSE", Tradingsymbol: "INFY"}}
+
+	tests := []struct {
+		name        string
+		mode        string
+		instruments []models.InstrumentModel
+		missing     bool
+		wantStatus  int
+		wantWarming bool
+	}{
+		{name: "warm", mode: config.QuoteWarmupBlock, instruments: loaded, wantStatus: http.StatusOK},
+		{name: "cold blocked", mode: config.QuoteWarmupBlock, wantStatus: http.StatusServiceUnavailable},
+		{name: "cold snapshot", mode: config.QuoteWarmupSnapshot, wantStatus: http.StatusOK, wantWarming: true},
+		{name: "database error blocked", mode: config.QuoteWarmupBlock, missing: true, wantStatus: http.StatusServiceUnavailable},
+		{name: "database error snapshot", mode: config.QuoteWarmupSnapshot, missing: true, wantStatus: http.StatusOK, wantWarming: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{QuoteWarmupMode: tt.mode}
+			db := openDB(t, t.Name(), tt.instruments, tt.missing)
+			mw := QuoteWarmupMiddleware(cfg, service.NewReadinessService(cfg, db, nil))
+
+			e := echo.New()
+			rec := httptest.NewRecorder()
+			c := e.NewContext(httptest.NewRequest(http.MethodGet, "/quote", nil), rec)
+			_ = mw(func(c echo.Context) error { return c.NoContent(http.StatusOK) })(c)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("QuoteWarmupMiddleware() status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if warming := rec.Header().Get("X-Quotes-Warming-Up") == "true"; warming != tt.wantWarming {
+				t.Errorf("QuoteWarmupMiddleware() warming up = %v, want %v", warming, tt.wantWarming)
+			}
+			if tt.wantStatus == http.StatusServiceUnavailable && rec.Header().Get("Retry-After") == "" {
+				t.Errorf("QuoteWarmupMiddleware() Retry-After header missing")
+			}
+		})
+	}
+}
@@ -0,0 +1,159 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nsvirk/moneybotsapi/internal/models"
+	"github.com/nsvirk/moneybotsapi/internal/repository"
+)
+
+// devAuthorization is the authorization of the seeded session of the memory storage
+const devAuthorization = repository.MemoryDevUserID + ":" + repository.MemoryDevEnctoken
+
+// responseKeys returns the sorted keys of the data of the response
+func responseKeys(t *testing.T, body []byte) []string {
+	t.Helper()
+	var resp struct {
+		Data map[string]json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		t.Fatalf("failed to decode the response: %v: %s", err, body)
+	}
+	keys := make([]string, 0, len(resp.Data))
+	for key := range resp.Data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func TestQuoteResponseKey(t *testing.T) {
+	e, _ := memoryServer(t)
+
+	tests := []struct {
+		name       string
+		query      string
+		wantStatus int
+		wantKeys   string
+	}{
+		{name: "symbol by default", query: "i=NSE:INFY&i=NSE:TCS", wantStatus: http.StatusOK, wantKeys: "NSE:INFY,NSE:TCS"},
+		{name: "symbol", query: "i=NSE:INFY&i=NSE:TCS&key=symbol", wantStatus: http.StatusOK, wantKeys: "NSE:INFY,NSE:TCS"},
+		{name: "token", query: "i=NSE:INFY&i=NSE:TCS&key=token", wantStatus: http.StatusOK, wantKeys: "100001,100002"},
+		{name: "token of a found instrument only", query: "i=NSE:INFY&i=NSE:UNKNOWN&key=token", wantStatus: http.StatusOK, wantKeys: "100001"},
+		{name: "invalid key", query: "i=NSE:INFY&key=isin", wantStatus: http.StatusBadRequest},
+	}
+
+	for _, path := range []string{"/quote", "/quote/ohlc", "/quote/ltp"} {
+		for _, tt := range tests {
+			t.Run(path+" "+tt.name, func(t *testing.T) {
+				rec := serve(e, http.MethodGet, APIV1Prefix+path+"?"+tt.query, devAuthorization)
+				if rec.Code != tt.wantStatus {
+					t.Fatalf("GET %s?%s status = %d, want %d: %s", path, tt.query, rec.Code, tt.wantStatus, rec.Body.String())
+				}
+				if tt.wantStatus != http.StatusOK {
+					return
+				}
+				if keys := strings.Join(responseKeys(t, rec.Body.Bytes()), ","); keys != tt.wantKeys {
+					t.Errorf("GET %s?%s keys = %s, want %s", path, tt.query, keys, tt.wantKeys)
+				}
+			})
+		}
+	}
+}
+
+func TestQuoteIndexWithEquity(t *testing.T) {
+	e, db := memoryServer(t)
+
+	index := models.InstrumentModel{InstrumentToken: 256265, Tradingsymbol: "NIFTY 50", Name: "NIFTY 50", Segment: "INDICES", Exchange: "NSE"}
+	if err := db.Create(&index).Error; err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	ohlc, _ := json.Marshal(models.TickerDataOHLC{Open: 24000, High: 24100, Low: 23900, Close: 24000})
+	if err := db.Create(&models.TickerData{Instrument: "NSE:NIFTY 50", InstrumentToken: 256265, Mode: "full", IsIndex: true, Timestamp: time.Now(), LastPrice: 24050, OHLC: ohlc}).Error; err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	t.Cleanup(func() {
+		db.Where("instrument_token = ?", 256265).Delete(&models.InstrumentModel{})
+		db.Where("instrument_token = ?", 256265).Delete(&models.TickerData{})
+	})
+
+	rec := serve(e, http.MethodGet, APIV1Prefix+"/quote?i=NSE:INFY&i=NSE:NIFTY%2050", devAuthorization)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET /quote status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body.String())
+	}
+	var resp struct {
+		Data map[string]map[string]json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode the response: %v: %s", err, rec.Body.String())
+	}
+
+	tests := []struct {
+		instrument string
+		wantFields bool
+	}{
+		{"NSE:INFY", true},
+		{"NSE:NIFTY 50", false},
+	}
+	for _, tt := range tests {
+		quote, ok := resp.Data[tt.instrument]
+		if !ok {
+			t.Fatalf("GET /quote has no %s quote: %s", tt.instrument, rec.Body.String())
+		}
+		for _, field := range []string{"depth", "oi", "volume"} {
+			if _, ok := quote[field]; ok != tt.wantFields {
+				t.Errorf("%s quote has %s = %v, want %v", tt.instrument, field, ok, tt.wantFields)
+			}
+		}
+		if _, ok := quote["ohlc"]; !ok {
+			t.Errorf("%s quote has no ohlc", tt.instrument)
+		}
+	}
+}
+
+func TestQuoteMetadataMissing(t *testing.T) {
+	e, db := memoryServer(t)
+
+	// listed since the last sync, so in the feed but not in the instrument master
+	if err := db.Create(&models.TickerData{Instrument: "NSE:NEWLIST", InstrumentToken: 999001, Mode: "full", IsTradable: true, Timestamp: time.Now(), LastPrice: 55}).Error; err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	t.Cleanup(func() {
+		db.Where("instrument_token = ?", 999001).Delete(&models.TickerData{})
+	})
+
+	rec := serve(e, http.MethodGet, APIV1Prefix+"/quote?i=NSE:INFY&i=NSE:NEWLIST", devAuthorization)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET /quote status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body.String())
+	}
+	var resp struct {
+		Data map[string]models.QuoteData `json:"data"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode the response: %v: %s", err, rec.Body.String())
+	}
+
+	tests := []struct {
+		instrument string
+		lastPrice  float64
+		want       bool
+	}{
+		{"NSE:INFY", 1000, false},
+		{"NSE:NEWLIST", 55, true},
+	}
+	for _, tt := range tests {
+		quote, ok := resp.Data[tt.instrument]
+		if !ok {
+			t.Fatalf("GET /quote has no %s quote: %s", tt.instrument, rec.Body.String())
+		}
+		if quote.LastPrice != tt.lastPrice || quote.MetadataMissing != tt.want {
+			t.Errorf("%s quote last price, metadata missing = %v, %v, want %v, %v",
+				tt.instrument, quote.LastPrice, quote.MetadataMissing, tt.lastPrice, tt.want)
+		}
+	}
+}
@@ -10,86 +10,313 @@ import (
 	"github.com/nsvirk/moneybotsapi/internal/api/handlers"
 	"github.com/nsvirk/moneybotsapi/internal/api/middleware"
 	"github.com/nsvirk/moneybotsapi/internal/config"
+	"github.com/nsvirk/moneybotsapi/internal/metrics"
+	"github.com/nsvirk/moneybotsapi/internal/models"
 	"github.com/nsvirk/moneybotsapi/internal/service"
 	"github.com/nsvirk/moneybotsapi/pkg/utils/response"
+	"github.com/nsvirk/moneybotsapi/pkg/utils/zaplogger"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/redis/go-redis/v9"
 	"gorm.io/gorm"
 )
 
+// APIV1Prefix is the path prefix of the version 1 routes, breaking changes ship under a new version like /api/v2
+const APIV1Prefix = "/api/v1"
+
+// routeRegistrar registers the routes of a module on the group of an API version
+type routeRegistrar func(g *echo.Group)
+
+// routeDeps are the services shared by the route modules
+type routeDeps struct {
+	e                *echo.Echo
+	cfg              *config.Config
+	db               *gorm.DB
+	redisClient      *redis.Client
+	quotaTracker     *service.QuotaTracker
+	readinessService *service.ReadinessService
+	capturer         *service.RequestCapturer
+	quoteService     *service.QuoteService
+	streamService    *service.StreamService
+}
+
 // SetupRoutes configures the routes for the API
+// The index, health and metrics routes are served at the root, the modules under APIV1Prefix,
+// the modules are also served at the root, as the deprecated routes of the unversioned API
 func SetupRoutes(e *echo.Echo, cfg *config.Config, db *gorm.DB, redisClient *redis.Client) {
 
-	// Create a group for all API routes
+	// Create a group for the unversioned routes
 	api := e.Group("")
 
+	// Request capture, turned on and off by admins
+	capturer := service.NewRequestCapturer(cfg)
+	e.Use(middleware.CaptureMiddleware(capturer))
+
+	// Request counts since startup, for the admin stats
+	e.Use(middleware.StatsMiddleware())
+
+	// Request counts and latencies by route, for Prometheus
+	e.Use(middleware.MetricsMiddleware())
+
+	// Global cap of the requests in flight, shed requests are still counted in the stats
+	e.Use(middleware.InflightMiddleware(cfg.MaxInflight))
+
 	// Index route
 	api.GET("/", indexRoute)
 
-	// Session routes (unprotected)
-	sessionService := service.NewSessionService(db)
-	sessionHandler := handlers.NewSessionHandler(sessionService)
-	sessionGroup := api.Group("/session")
-	sessionGroup.POST("/token", sessionHandler.GenerateSession)
-	sessionGroup.DELETE("/token", sessionHandler.DeleteSession)
-	sessionGroup.POST("/totp", sessionHandler.GenerateTOTP)
-	sessionGroup.POST("/valid", sessionHandler.CheckEnctokenValid)
-
-	// Instrument routes (protected)
-	instrumentHandler := handlers.NewInstrumentHandler(db)
-	instrumentGroup := api.Group("/instruments")
-	instrumentGroup.Use(middleware.AuthMiddleware(db))
-	// instrument routes
-	instrumentGroup.GET("/info", instrumentHandler.GetInstrumentsInfo)
-	instrumentGroup.GET("/query", instrumentHandler.GetInstrumentsQuery)
-	// instrument fno routes
-	instrumentGroup.GET("/fno/segment_expiries/:name", instrumentHandler.GetFNOSegmentWiseExpiry)
-	instrumentGroup.GET("/fno/segment_names/:expiry", instrumentHandler.GetFNOSegmentWiseName)
-
-	// Indices routes (protected)
-	indexHandler := handlers.NewIndexHandler(db)
-	indexGroup := api.Group("/indices")
-	indexGroup.Use(middleware.AuthMiddleware(db))
-	indexGroup.GET("/all", indexHandler.GetAllIndices)
-	indexGroup.GET("/:exchange/info", indexHandler.GetIndicesByExchange)
-	indexGroup.GET("/:exchange/:index/instruments", indexHandler.GetIndexInstruments)
-
-	// Ticker routes (protected)
-	tickerService := service.NewTickerService(db, redisClient)
+	// Health route (unprotected)
+	healthService := service.NewHealthService(cfg, db)
+	readinessService := service.NewReadinessService(cfg, db, redisClient)
+	healthHandler := handlers.NewHealthHandler(healthService, readinessService)
+	api.GET("/health", healthHandler.GetHealth)
+	api.GET("/ready", healthHandler.GetReady)
+
+	// Metrics route (unprotected)
+	marketService := service.NewMarketService(cfg)
+	metrics.RegisterMarketOpen(func() bool { return marketService.IsMarketOpen(marketService.Now()) })
+	metrics.RegisterLogWriterStats(func() (int, uint64) {
+		stats := zaplogger.GetDBWriterStats()
+		return stats.Queued, stats.Dropped
+	})
+	api.GET("/metrics", echo.WrapHandler(promhttp.Handler()))
+
+	deps := &routeDeps{
+		e:                e,
+		cfg:              cfg,
+		db:               db,
+		redisClient:      redisClient,
+		quotaTracker:     service.NewQuotaTracker(cfg.DailyQuota, db),
+		readinessService: readinessService,
+		capturer:         capturer,
+		quoteService:     service.NewQuoteService(cfg, db),
+		streamService:    service.NewStreamService(cfg, db),
+	}
+	metrics.RegisterStreamStats(func() (int, int) {
+		stats := deps.streamService.Stats()
+		return stats.Clients, stats.Tokens
+	})
+
+	// The handlers of each module are created once and shared by its routes of all versions
+	modules := []routeRegistrar{
+		sessionRoutes(deps),
+		instrumentRoutes(deps),
+		indexRoutes(deps),
+		tickerRoutes(deps),
+		quoteRoutes(deps),
+		historicalRoutes(deps),
+		watchlistRoutes(deps),
+		streamRoutes(deps),
+		cronRoutes(deps),
+		adminRoutes(deps),
+	}
+
+	v1 := e.Group(APIV1Prefix)
+	unversioned := api.Group("", middleware.DeprecationMiddleware(APIV1Prefix))
+	for _, register := range modules {
+		register(v1)
+		register(unversioned)
+	}
+}
+
+// sessionRoutes registers the session routes (unprotected)
+func sessionRoutes(deps *routeDeps) routeRegistrar {
+	sessionService := service.NewSessionService(deps.db)
+	loginLimiter := service.NewLoginLimiter(deps.cfg.LoginMaxAttempts, deps.cfg.LoginLockout)
+	sessionHandler := handlers.NewSessionHandler(sessionService, loginLimiter, deps.quotaTracker)
+
+	return func(g *echo.Group) {
+		sessionGroup := g.Group("/session")
+		sessionGroup.POST("/token", sessionHandler.GenerateSession)
+		sessionGroup.POST("/login", sessionHandler.GenerateSession) // alias of POST /session/token
+		sessionGroup.DELETE("/token", sessionHandler.DeleteSession)
+		sessionGroup.POST("/totp", sessionHandler.GenerateTOTP)
+		sessionGroup.POST("/valid", sessionHandler.CheckEnctokenValid)
+		sessionGroup.GET("/usage", sessionHandler.GetUsage, middleware.AuthMiddleware(deps.db))
+	}
+}
+
+// instrumentRoutes registers the instrument routes (protected)
+func instrumentRoutes(deps *routeDeps) routeRegistrar {
+	instrumentHandler := handlers.NewInstrumentHandler(deps.cfg, deps.db)
+	indexHandler := handlers.NewIndexHandler(deps.cfg, deps.db)
+
+	return func(g *echo.Group) {
+		instrumentGroup := g.Group("/instruments")
+		instrumentGroup.Use(middleware.AuthMiddleware(deps.db))
+		instrumentGroup.Use(middleware.ScopeMiddleware(models.ScopeInstrumentsRead))
+		instrumentGroup.Use(middleware.QuotaMiddleware(deps.quotaTracker))
+		// instrument routes
+		instrumentGroup.GET("", instrumentHandler.GetInstrumentsAsOf)
+		instrumentGroup.GET("/info", instrumentHandler.GetInstrumentsInfo)
+		instrumentGroup.GET("/query", instrumentHandler.GetInstrumentsQuery)
+		instrumentGroup.GET("/search", instrumentHandler.SearchInstruments)
+		instrumentGroup.GET("/limits", instrumentHandler.GetInstrumentLimits)
+		instrumentGroup.GET("/actions", instrumentHandler.GetCorporateActions)
+		instrumentGroup.POST("/sync", instrumentHandler.SyncInstruments, middleware.ScopeMiddleware(models.ScopeTickerWrite))
+		instrumentGroup.GET("/checksum", instrumentHandler.GetInstrumentsChecksum)
+		instrumentGroup.GET("/optionchain", instrumentHandler.GetOptionChain, middleware.ScopeMiddleware(models.ScopeQuoteRead))
+		instrumentGroup.GET("/:symbol/indices", indexHandler.GetInstrumentIndices)
+		// instrument fno routes
+		instrumentGroup.GET("/fno/segment_expiries/:name", instrumentHandler.GetFNOSegmentWiseExpiry)
+		instrumentGroup.GET("/fno/segment_names/:expiry", instrumentHandler.GetFNOSegmentWiseName)
+	}
+}
+
+// indexRoutes registers the indices routes (protected)
+func indexRoutes(deps *routeDeps) routeRegistrar {
+	indexHandler := handlers.NewIndexHandler(deps.cfg, deps.db)
+
+	return func(g *echo.Group) {
+		indexGroup := g.Group("/indices")
+		indexGroup.Use(middleware.AuthMiddleware(deps.db))
+		indexGroup.Use(middleware.ScopeMiddleware(models.ScopeInstrumentsRead))
+		indexGroup.GET("", indexHandler.ListIndices)
+		indexGroup.GET("/all", indexHandler.GetAllIndices)
+		indexGroup.POST("/sync", indexHandler.SyncIndices, middleware.ScopeMiddleware(models.ScopeTickerWrite))
+		indexGroup.GET("/:exchange/info", indexHandler.GetIndicesByExchange)
+		indexGroup.GET("/:exchange/:index/instruments", indexHandler.GetIndexInstruments)
+		indexGroup.GET("/:exchange/:index/breadth", indexHandler.GetIndexBreadth)
+		indexGroup.GET("/:exchange/:index/synthetic", indexHandler.GetIndexSynthetic)
+		indexGroup.GET("/:name/instruments", indexHandler.GetIndexConstituents)
+	}
+}
+
+// tickerRoutes registers the ticker routes (protected)
+func tickerRoutes(deps *routeDeps) routeRegistrar {
+	tickerService := service.NewTickerService(deps.cfg, deps.db, deps.redisClient)
 	tickerHandler := handlers.NewTickerHandler(tickerService)
-	tickerGroup := api.Group("/ticker")
-	tickerGroup.Use(middleware.AuthMiddleware(db))
-	tickerGroup.GET("/instruments", tickerHandler.GetTickerInstruments)
-	tickerGroup.POST("/instruments", tickerHandler.AddTickerInstruments)
-	tickerGroup.DELETE("/instruments", tickerHandler.DeleteTickerInstruments)
-	tickerGroup.GET("/start", tickerHandler.TickerStart)
-	tickerGroup.GET("/stop", tickerHandler.TickerStop)
-	tickerGroup.GET("/restart", tickerHandler.TickerRestart)
-	tickerGroup.GET("/status", tickerHandler.TickerStatus)
-
-	// Quote routes (protected)
-	quoteService := service.NewQuoteService(db)
-	quoteHandler := handlers.NewQuoteHandler(quoteService)
-	quoteGroup := api.Group("/quote")
-	quoteGroup.Use(middleware.AuthMiddleware(db))
-	quoteGroup.GET("", quoteHandler.GetQuote)
-	quoteGroup.GET("/ohlc", quoteHandler.GetOHLC)
-	quoteGroup.GET("/ltp", quoteHandler.GetLTP)
-
-	// Stream routes (protected)
-	streamHandler := handlers.NewStreamHandler(db)
-	streamGroup := api.Group("/stream")
-	streamGroup.Use(middleware.AuthMiddleware(db))
-	streamGroup.POST("/ticks", streamHandler.StreamTickerData)
-
-	// Cron routes (protected)
-	cronHandler := handlers.NewCronHandler(e, cfg, db, redisClient)
-	cronGroup := api.Group("/cron")
-	cronGroup.Use(middleware.AuthMiddleware(db))
-	cronGroup.PUT("/indices", cronHandler.UpdateIndices)
-	cronGroup.PUT("/instruments", cronHandler.UpdateInstruments)
-	cronGroup.PUT("/ticker_instruments", cronHandler.TickerInstrumentsUpdateJob)
-	// cronGroup.GET("/ticker_start", cronHandler.TickerStartJob)
-	// cronGroup.GET("/ticker_stop", cronHandler.TickerStopJob)
+
+	return func(g *echo.Group) {
+		tickerGroup := g.Group("/ticker")
+		tickerGroup.Use(middleware.AuthMiddleware(deps.db))
+		tickerGroup.Use(middleware.ScopeMiddleware(models.ScopeTickerWrite))
+		tickerGroup.GET("/instruments", tickerHandler.GetTickerInstruments)
+		tickerGroup.POST("/instruments", tickerHandler.AddTickerInstruments)
+		tickerGroup.DELETE("/instruments", tickerHandler.DeleteTickerInstruments)
+		tickerGroup.GET("/start", tickerHandler.TickerStart)
+		tickerGroup.GET("/stop", tickerHandler.TickerStop)
+		tickerGroup.GET("/restart", tickerHandler.TickerRestart)
+		tickerGroup.GET("/status", tickerHandler.TickerStatus)
+	}
+}
+
+// quoteRoutes registers the quote routes (protected)
+func quoteRoutes(deps *routeDeps) routeRegistrar {
+	quoteHandler := handlers.NewQuoteHandler(deps.quoteService)
+
+	return func(g *echo.Group) {
+		quoteGroup := g.Group("/quote")
+		quoteGroup.Use(middleware.AuthMiddleware(deps.db))
+		quoteGroup.Use(middleware.ScopeMiddleware(models.ScopeQuoteRead))
+		quoteGroup.Use(middleware.QuotaMiddleware(deps.quotaTracker))
+		quoteGroup.Use(middleware.QuoteWarmupMiddleware(deps.cfg, deps.readinessService))
+		quoteGroup.GET("", quoteHandler.GetQuote)
+		quoteGroup.GET("/ohlc", quoteHandler.GetOHLC)
+		quoteGroup.GET("/ltp", quoteHandler.GetLTP)
+		quoteGroup.GET("/vwap", quoteHandler.GetVWAP)
+		quoteGroup.GET("/trades", quoteHandler.GetTrades)
+		quoteGroup.POST("/changes", quoteHandler.GetQuoteChanges)
+		quoteGroup.POST("/summary", quoteHandler.GetQuoteSummary)
+		quoteGroup.GET("/movers", quoteHandler.GetQuoteMovers)
+		quoteGroup.GET("/basis", quoteHandler.GetQuoteBasis)
+	}
+}
+
+// historicalRoutes registers the historical and intraday candle routes (protected)
+func historicalRoutes(deps *routeDeps) routeRegistrar {
+	historicalHandler := handlers.NewHistoricalHandler(deps.cfg, deps.db)
+
+	return func(g *echo.Group) {
+		historicalGroup := g.Group("/historical")
+		historicalGroup.Use(middleware.AuthMiddleware(deps.db))
+		historicalGroup.Use(middleware.ScopeMiddleware(models.ScopeQuoteRead))
+		historicalGroup.Use(middleware.QuotaMiddleware(deps.quotaTracker))
+		historicalGroup.GET("/candles", historicalHandler.GetIntervalCandles)
+		historicalGroup.GET("/:token", historicalHandler.GetHistoricalCandles)
+
+		candleGroup := g.Group("/candles")
+		candleGroup.Use(middleware.AuthMiddleware(deps.db))
+		candleGroup.Use(middleware.ScopeMiddleware(models.ScopeQuoteRead))
+		candleGroup.Use(middleware.QuotaMiddleware(deps.quotaTracker))
+		candleGroup.GET("/intraday", historicalHandler.GetIntradayCandles)
+	}
+}
+
+// watchlistRoutes registers the watchlist routes (protected)
+func watchlistRoutes(deps *routeDeps) routeRegistrar {
+	watchlistService := service.NewWatchlistService(deps.db, deps.quoteService)
+	watchlistHandler := handlers.NewWatchlistHandler(deps.cfg, watchlistService)
+
+	return func(g *echo.Group) {
+		watchlistGroup := g.Group("/watchlists")
+		watchlistGroup.Use(middleware.AuthMiddleware(deps.db))
+		watchlistGroup.Use(middleware.ScopeMiddleware(models.ScopeQuoteRead))
+		watchlistGroup.POST("", watchlistHandler.CreateWatchlist)
+		watchlistGroup.GET("", watchlistHandler.GetWatchlists)
+		watchlistGroup.GET("/:id", watchlistHandler.GetWatchlist)
+		watchlistGroup.PUT("/:id", watchlistHandler.UpdateWatchlist)
+		watchlistGroup.DELETE("/:id", watchlistHandler.DeleteWatchlist)
+		watchlistGroup.GET("/:id/quotes", watchlistHandler.GetWatchlistQuotes,
+			middleware.QuotaMiddleware(deps.quotaTracker), middleware.QuoteWarmupMiddleware(deps.cfg, deps.readinessService))
+	}
+}
+
+// streamRoutes registers the stream routes (protected), the streams are drained on shutdown
+func streamRoutes(deps *routeDeps) routeRegistrar {
+	streamHandler := handlers.NewStreamHandler(deps.streamService)
+	deps.e.Server.RegisterOnShutdown(streamHandler.Drain)
+
+	return func(g *echo.Group) {
+		streamGroup := g.Group("/stream")
+		streamGroup.Use(middleware.AuthMiddleware(deps.db))
+		streamGroup.Use(middleware.ScopeMiddleware(models.ScopeQuoteRead))
+		streamGroup.POST("/ticks", streamHandler.StreamTickerData)
+		streamGroup.GET("/ticks", streamHandler.StreamTickerSocket)
+		streamGroup.GET("/sse", streamHandler.StreamTickerEvents)
+	}
+}
+
+// cronRoutes registers the routes running the cron jobs (protected)
+func cronRoutes(deps *routeDeps) routeRegistrar {
+	cronHandler := handlers.NewCronHandler(deps.e, deps.cfg, deps.db, deps.redisClient)
+
+	return func(g *echo.Group) {
+		cronGroup := g.Group("/cron")
+		cronGroup.Use(middleware.AuthMiddleware(deps.db))
+		cronGroup.Use(middleware.ScopeMiddleware(models.ScopeTickerWrite))
+		cronGroup.PUT("/indices", cronHandler.UpdateIndices)
+		cronGroup.PUT("/instruments", cronHandler.UpdateInstruments)
+		cronGroup.PUT("/corporate_actions", cronHandler.UpdateCorporateActions)
+		cronGroup.PUT("/ticker_instruments", cronHandler.TickerInstrumentsUpdateJob)
+		// cronGroup.GET("/ticker_start", cronHandler.TickerStartJob)
+		// cronGroup.GET("/ticker_stop", cronHandler.TickerStopJob)
+	}
+}
+
+// adminRoutes registers the admin routes (protected, admin only)
+func adminRoutes(deps *routeDeps) routeRegistrar {
+	adminHandler := handlers.NewAdminHandler(deps.cfg, deps.db, deps.capturer, deps.streamService)
+
+	return func(g *echo.Group) {
+		adminGroup := g.Group("/admin")
+		adminGroup.Use(middleware.AdminIPAllowlistMiddleware(deps.cfg))
+		adminGroup.Use(middleware.AuthMiddleware(deps.db))
+		adminGroup.Use(middleware.AdminMiddleware(deps.cfg))
+		adminGroup.GET("/config", adminHandler.GetConfig)
+		adminGroup.GET("/errors/recent", adminHandler.GetRecentErrors)
+		adminGroup.GET("/logs", adminHandler.GetLogs)
+		adminGroup.GET("/diagnostics", adminHandler.GetDiagnostics)
+		adminGroup.GET("/stats", adminHandler.GetStats)
+		adminGroup.GET("/jobs", adminHandler.GetJobs)
+		adminGroup.GET("/upstream/raw", adminHandler.GetUpstreamRaw)
+		adminGroup.GET("/users", adminHandler.GetUsers)
+		adminGroup.POST("/users/:user_id/disable", adminHandler.DisableUser)
+		adminGroup.POST("/users/:user_id/enable", adminHandler.EnableUser)
+		adminGroup.POST("/users/:user_id/reset", adminHandler.ResetUser)
+		adminGroup.PUT("/users/:user_id/role", adminHandler.SetUserRole)
+		adminGroup.POST("/alerts/test", adminHandler.TestAlert)
+		adminGroup.GET("/capture", adminHandler.GetCapture)
+		adminGroup.PUT("/capture", adminHandler.SetCapture)
+	}
 }
 
 // indexRoute sets up the index route for the API
@@ -0,0 +1,150 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/nsvirk/moneybotsapi/internal/api/middleware"
+	"github.com/nsvirk/moneybotsapi/internal/config"
+	"github.com/nsvirk/moneybotsapi/internal/models"
+	"github.com/nsvirk/moneybotsapi/internal/repository"
+	"github.com/nsvirk/moneybotsapi/internal/service"
+	"github.com/nsvirk/moneybotsapi/pkg/utils/response"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+)
+
+var (
+	serverOnce sync.Once
+	serverEcho *echo.Echo
+	serverDB   *gorm.DB
+	serverErr  error
+)
+
+// memoryServer boots the server on the in-memory storage, as runInMemory does, it is shared by the tests
+// as the routes register their metrics once
+func memoryServer(t *testing.T) (*echo.Echo, *gorm.DB) {
+	t.Helper()
+	serverOnce.Do(func() {
+		env := map[string]string{
+			"MB_API_STORAGE":                 "memory",
+			"MB_API_SERVER_ENV":              "development",
+			"MB_API_FEED_SAMPLE_INSTRUMENTS": "NSE:INFY,NSE:TCS",
+			"MB_API_ADMIN_USER_IDS":          repository.MemoryDevUserID,
+			// the tests never refresh the instruments from Kite
+			"MB_API_INSTRUMENT_BACKFILL_INTERVAL": "0s",
+		}
+		for _, name := range []string{
+			"MB_API_NAME", "MB_API_VERSION", "MB_API_URL", "MB_API_SERVER_PORT", "MB_API_SERVER_LOG_LEVEL",
+			"MB_API_PG_DSN", "MB_API_PG_SCHEMA", "MB_API_PG_LOG_LEVEL", "MB_API_REDIS_HOST", "MB_API_REDIS_PORT",
+			"MB_API_REDIS_PASSWORD", "MB_API_TELEGRAM_BOT_TOKEN", "MB_API_TELEGRAM_CHAT_ID",
+			"MB_API_KITETICKER_USER_ID", "MB_API_KITETICKER_PASSWORD", "MB_API_KITETICKER_TOTP_SECRET",
+		} {
+			env[name] = "test"
+		}
+		for name, value := range env {
+			os.Setenv(name, value)
+		}
+
+		cfg, err := config.Get()
+		if err != nil {
+			serverErr = err
+			return
+		}
+		if serverDB, serverErr = repository.ConnectMemory(cfg); serverErr != nil {
+			return
+		}
+		service.SetOfflineSessions(true)
+		service.MarkQuotesRefreshed()
+
+		serverEcho = echo.New()
+		serverEcho.HTTPErrorHandler = response.HTTPErrorHandler(serverEcho)
+		serverEcho.Pre(middleware.HeadMiddleware())
+		SetupRoutes(serverEcho, cfg, serverDB, redis.NewClient(&redis.Options{Addr: "127.0.0.1:1"}))
+	})
+	if serverErr != nil {
+		t.Fatalf("failed to boot the memory server: %v", serverErr)
+	}
+	return serverEcho, serverDB
+}
+
+// serve serves the request on the server, authorized as the user when authorization is set
+func serve(e *echo.Echo, method, path, authorization string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestMemoryStorage(t *testing.T) {
+	e, _ := memoryServer(t)
+	devAuth := repository.MemoryDevUserID + ":" + repository.MemoryDevEnctoken
+
+	tests := []struct {
+		name          string
+		path          string
+		authorization string
+		wantStatus    int
+	}{
+		{name: "index", path: "/", wantStatus: http.StatusOK},
+		{name: "health", path: "/health", wantStatus: http.StatusOK},
+		{name: "seeded quote", path: "/api/v1/quote/ltp?i=NSE:INFY", authorization: devAuth, wantStatus: http.StatusOK},
+		{name: "no authorization", path: "/api/v1/quote/ltp?i=NSE:INFY", wantStatus: http.StatusUnauthorized},
+		{name: "wrong enctoken", path: "/api/v1/quote/ltp?i=NSE:INFY", authorization: repository.MemoryDevUserID + ":wrong", wantStatus: http.StatusUnauthorized},
+		{name: "unknown user", path: "/api/v1/quote/ltp?i=NSE:INFY", authorization: "XX0000:memory", wantStatus: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(e, http.MethodGet, tt.path, tt.authorization)
+			if rec.Code != tt.wantStatus {
+				t.Errorf("GET %s status = %d, want %d: %s", tt.path, rec.Code, tt.wantStatus, rec.Body.String())
+			}
+		})
+	}
+
+	t.Run("seeded quote prices", func(t *testing.T) {
+		rec := serve(e, http.MethodGet, "/api/v1/quote/ltp?i=NSE:INFY&i=NSE:TCS", devAuth)
+		var body struct {
+			Data map[string]struct {
+				InstrumentToken uint32  `json:"instrument_token"`
+				LastPrice       float64 `json:"last_price"`
+			} `json:"data"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Fatalf("failed to decode the quote: %v: %s", err, rec.Body.String())
+		}
+		for instrument, want := range map[string]float64{"NSE:INFY": 1000, "NSE:TCS": 2000} {
+			if got := body.Data[instrument].LastPrice; got != want {
+				t.Errorf("last price of %s = %v, want %v", instrument, got, want)
+			}
+		}
+	})
+}
+
+// testSession creates a session of the user with the role for the test, and returns its authorization
+func testSession(t *testing.T, db *gorm.DB, userID, role string) string {
+	t.Helper()
+	session := models.SessionModel{
+		UserId:    userID,
+		Enctoken:  "enc-" + userID,
+		LoginTime: time.Now().Format("2006-01-02 15:04:05"),
+		Role:      role,
+	}
+	if err := db.Create(&session).Error; err != nil {
+		t.Fatalf("failed to create the session of %s: %v", userID, err)
+	}
+	t.Cleanup(func() {
+		db.Delete(&models.SessionModel{}, "user_id = ?", userID)
+	})
+	return session.UserId + ":" + session.Enctoken
+}
@@ -0,0 +1,53 @@
+package api
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/nsvirk/moneybotsapi/internal/models"
+	"github.com/nsvirk/moneybotsapi/internal/repository"
+)
+
+func TestRouteScopes(t *testing.T) {
+	e, db := memoryServer(t)
+	readonly := models.SessionModel{
+		UserId:    "RO0001",
+		UserName:  "Read Only",
+		Enctoken:  "readonly",
+		LoginTime: time.Now().Format("2006-01-02 15:04:05"),
+		Role:      models.RoleReadOnly,
+	}
+	if err := db.Create(&readonly).Error; err != nil {
+		t.Fatalf("failed to create the readonly session: %v", err)
+	}
+	t.Cleanup(func() {
+		db.Delete(&models.SessionModel{}, "user_id = ?", readonly.UserId)
+	})
+	readonlyAuth := readonly.UserId + ":" + readonly.Enctoken
+	userAuth := repository.MemoryDevUserID + ":" + repository.MemoryDevEnctoken
+
+	tests := []struct {
+		name          string
+		path          string
+		authorization string
+		wantStatus    int
+	}{
+		{name: "ticker without credentials", path: "/ticker/instruments", wantStatus: http.StatusUnauthorized},
+		{name: "ticker as readonly", path: "/ticker/instruments", authorization: readonlyAuth, wantStatus: http.StatusForbidden},
+		{name: "ticker as user", path: "/ticker/instruments", authorization: userAuth, wantStatus: http.StatusOK},
+		{name: "quote as readonly", path: "/quote/ltp?i=NSE:INFY", authorization: readonlyAuth, wantStatus: http.StatusOK},
+	}
+
+	for _, prefix := range []string{APIV1Prefix, ""} {
+		for _, tt := range tests {
+			path := prefix + tt.path
+			t.Run(path+" "+tt.name, func(t *testing.T) {
+				rec := serve(e, http.MethodGet, path, tt.authorization)
+				if rec.Code != tt.wantStatus {
+					t.Errorf("GET %s status = %d, want %d: %s", path, rec.Code, tt.wantStatus, rec.Body.String())
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/nsvirk/moneybotsapi/internal/models"
+)
+
+// serveJSON serves a request with a JSON body to the server
+func serveJSON(e *echo.Echo, method, path, authorization, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	req.Header.Set("Authorization", authorization)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestWatchlistQuotes(t *testing.T) {
+	e, db := memoryServer(t)
+	ownerAuth := testSession(t, db, "WL0001", models.RoleUser)
+	otherAuth := testSession(t, db, "WL0002", models.RoleUser)
+	t.Cleanup(func() {
+		db.Where("user_id IN ?", []string{"WL0001", "WL0002"}).Delete(&models.WatchlistModel{})
+	})
+
+	rec := serveJSON(e, http.MethodPost, APIV1Prefix+"/watchlists", ownerAuth,
+		`{"name": " Tech ", "instruments": ["NSE:INFY", "NSE:TCS", "NSE:INFY", "NSE:UNKNOWN"]}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("POST /watchlists status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body.String())
+	}
+	var created struct {
+		Data models.WatchlistModel `json:"data"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &created); err != nil {
+		t.Fatalf("failed to decode the watchlist: %v: %s", err, rec.Body.String())
+	}
+	if got := strings.Join(created.Data.Instruments, ","); created.Data.Name != "Tech" || got != "NSE:INFY,NSE:TCS,NSE:UNKNOWN" {
+		t.Errorf("created watchlist = %q %s, want %q %s", created.Data.Name, got, "Tech", "NSE:INFY,NSE:TCS,NSE:UNKNOWN")
+	}
+	path := fmt.Sprintf("%s/watchlists/%d", APIV1Prefix, created.Data.ID)
+
+	t.Run("get", func(t *testing.T) {
+		rec := serve(e, http.MethodGet, path, ownerAuth)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("GET /watchlists/:id status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body.String())
+		}
+		var resp struct {
+			Data models.WatchlistModel `json:"data"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("failed to decode the watchlist: %v: %s", err, rec.Body.String())
+		}
+		if resp.Data.ID != created.Data.ID || len(resp.Data.Instruments) != 3 {
+			t.Errorf("GET /watchlists/:id = %+v, want the created watchlist", resp.Data)
+		}
+	})
+
+	t.Run("quotes", func(t *testing.T) {
+		rec := serve(e, http.MethodGet, path+"/quotes", ownerAuth)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("GET /watchlists/:id/quotes status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body.String())
+		}
+		var resp struct {
+			Data struct {
+				Watchlist models.WatchlistModel               `json:"watchlist"`
+				Quotes    map[string]*models.QuoteSummaryData `json:"quotes"`
+			} `json:"data"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("failed to decode the quotes: %v: %s", err, rec.Body.String())
+		}
+		if resp.Data.Watchlist.ID != created.Data.ID {
+			t.Errorf("quotes watchlist id = %d, want %d", resp.Data.Watchlist.ID, created.Data.ID)
+		}
+
+		tests := []struct {
+			instrument string
+			lastPrice  float64
+			wantQuote  bool
+		}{
+			{"NSE:INFY", 1000, true},
+			{"NSE:TCS", 2000, true},
+			{"NSE:UNKNOWN", 0, false},
+		}
+		if len(resp.Data.Quotes) != len(tests) {
+			t.Errorf("quotes = %d instruments, want %d: %s", len(resp.Data.Quotes), len(tests), rec.Body.String())
+		}
+		for _, tt := range tests {
+			quote, ok := resp.Data.Quotes[tt.instrument]
+			if !ok {
+				t.Fatalf("quotes have no %s: %s", tt.instrument, rec.Body.String())
+			}
+			if (quote != nil) != tt.wantQuote {
+				t.Fatalf("%s quote = %+v, want a quote %v", tt.instrument, quote, tt.wantQuote)
+			}
+			if quote != nil && quote.LastPrice != tt.lastPrice {
+				t.Errorf("%s last price = %v, want %v", tt.instrument, quote.LastPrice, tt.lastPrice)
+			}
+		}
+	})
+
+	t.Run("other user", func(t *testing.T) {
+		for _, p := range []string{path, path + "/quotes"} {
+			if rec := serve(e, http.MethodGet, p, otherAuth); rec.Code != http.StatusNotFound {
+				t.Errorf("GET %s of another user status = %d, want %d", p, rec.Code, http.StatusNotFound)
+			}
+		}
+	})
+
+	t.Run("max instruments", func(t *testing.T) {
+		instruments := make([]string, 51)
+		for i := range instruments {
+			instruments[i] = fmt.Sprintf("NSE:SYM%d", i)
+		}
+		body, _ := json.Marshal(models.WatchlistRequest{Name: "Too many", Instruments: instruments})
+		rec := serveJSON(e, http.MethodPost, APIV1Prefix+"/watchlists", ownerAuth, string(body))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("POST /watchlists of 51 instruments status = %d, want %d: %s", rec.Code, http.StatusBadRequest, rec.Body.String())
+		}
+	})
+}
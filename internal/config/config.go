@@ -5,8 +5,10 @@ import (
 	"fmt"
 	"os"
 	"reflect"
+	"strconv"
 	"strings"
 	"sync"
+	"time"
 )
 
 // Config represents the application configuration
@@ -27,8 +29,91 @@ type Config struct {
 	KitetickerUserID     string `env:"MB_API_KITETICKER_USER_ID"`
 	KitetickerPassword   string `env:"MB_API_KITETICKER_PASSWORD"`
 	KitetickerTotpSecret string `env:"MB_API_KITETICKER_TOTP_SECRET"`
+
+	// Optional fields, a `default` tag makes the env variable optional
+	QuoteTickRounding bool          `env:"MB_API_QUOTE_TICK_ROUNDING" default:"false"`
+	AdminUserIDs      string        `env:"MB_API_ADMIN_USER_IDS" default:""`
+	MarketHolidays    string        `env:"MB_API_MARKET_HOLIDAYS" default:""`
+	FeedSampleInstr   string        `env:"MB_API_FEED_SAMPLE_INSTRUMENTS" default:"NSE:RELIANCE,NSE:HDFCBANK,NSE:INFY,NSE:TCS,NSE:ICICIBANK"`
+	FeedStaleAfter    time.Duration `env:"MB_API_FEED_STALE_AFTER" default:"2m"`
+	SearchRanking     string        `env:"MB_API_SEARCH_RANKING" default:"match"`
+	QuoteCoalesce     bool          `env:"MB_API_QUOTE_COALESCE" default:"true"`
+	ServerEnv         string        `env:"MB_API_SERVER_ENV" default:"production"`
+	QuoteNegativeTTL  time.Duration `env:"MB_API_QUOTE_NEGATIVE_TTL" default:"5s"`
+	QueryBudget       int           `env:"MB_API_QUERY_BUDGET" default:"20"`
+	LoginMaxAttempts  int           `env:"MB_API_LOGIN_MAX_ATTEMPTS" default:"5"`
+	LoginLockout      time.Duration `env:"MB_API_LOGIN_LOCKOUT" default:"15m"`
+	AlertWebhookURL   string        `env:"MB_API_ALERT_WEBHOOK_URL" default:""`
+	HTTPReadTimeout   time.Duration `env:"MB_API_HTTP_READ_TIMEOUT" default:"15s"`
+	HTTPWriteTimeout  time.Duration `env:"MB_API_HTTP_WRITE_TIMEOUT" default:"30s"`
+	HTTPIdleTimeout   time.Duration `env:"MB_API_HTTP_IDLE_TIMEOUT" default:"60s"`
+	HTTPHeaderTimeout time.Duration `env:"MB_API_HTTP_READ_HEADER_TIMEOUT" default:"5s"`
+	DBHealthInterval  time.Duration `env:"MB_API_DB_HEALTHCHECK_INTERVAL" default:"30s"`
+	MaskPatterns      string        `env:"MB_API_CONFIG_MASK_PATTERNS" default:""`
+	MaskReveal        int           `env:"MB_API_CONFIG_MASK_REVEAL" default:"-1"`
+	FuturesRollDays   int           `env:"MB_API_FUTURES_ROLL_DAYS" default:"0"`
+	Storage           string        `env:"MB_API_STORAGE" default:"postgres"`
+	StreamBatchEvery  time.Duration `env:"MB_API_STREAM_BATCH_INTERVAL" default:"1s"`
+	PriceAlerts       string        `env:"MB_API_PRICE_ALERTS" default:""`
+	BreadthIndices    string        `env:"MB_API_BREADTH_INDICES" default:"NSE:NIFTY 50,NSE:NIFTY BANK"`
+	BreadthRefresh    time.Duration `env:"MB_API_BREADTH_REFRESH_INTERVAL" default:"5s"`
+	DatasourceTimeout time.Duration `env:"MB_API_DATASOURCE_TIMEOUT" default:"2s"`
+	CaptureDir        string        `env:"MB_API_CAPTURE_DIR" default:""`
+	CaptureRoutes     string        `env:"MB_API_CAPTURE_ROUTES" default:""`
+	CapturePercent    int           `env:"MB_API_CAPTURE_SAMPLE_PERCENT" default:"10"`
+	ShutdownDrain     time.Duration `env:"MB_API_SHUTDOWN_DRAIN" default:"5s"`
+	ReconnectBackoff  time.Duration `env:"MB_API_STREAM_RECONNECT_BACKOFF" default:"1s"`
+	ReconnectJitter   time.Duration `env:"MB_API_STREAM_RECONNECT_JITTER" default:"5s"`
+	CompressMinLength int           `env:"MB_API_COMPRESS_MIN_LENGTH" default:"1024"`
+	QuoteMoversTTL    time.Duration `env:"MB_API_QUOTE_MOVERS_TTL" default:"5s"`
+	CacheTTLs         string        `env:"MB_API_CACHE_TTLS" default:""`
+	AdminIPAllowlist  string        `env:"MB_API_ADMIN_IP_ALLOWLIST" default:""`
+	InstrBackfillGap  time.Duration `env:"MB_API_INSTRUMENT_BACKFILL_INTERVAL" default:"10m"`
+	CompressExcluded  string        `env:"MB_API_COMPRESS_EXCLUDED_TYPES" default:"text/event-stream,application/msgpack,application/x-msgpack,application/gzip,application/zip,image/"`
+	BroadcastWorkers  int           `env:"MB_API_WS_BROADCAST_WORKERS" default:"4"`
+	BroadcastTimeout  time.Duration `env:"MB_API_WS_BROADCAST_SEND_TIMEOUT" default:"50ms"`
+	FreezeLimitsURL   string        `env:"MB_API_FREEZE_LIMITS_URL" default:""`
+	CorpActionsURL    string        `env:"MB_API_CORPORATE_ACTIONS_URL" default:""`
+	QuoteWarmupMode   string        `env:"MB_API_QUOTE_WARMUP_MODE" default:"snapshot"`
+	DailyQuota        int           `env:"MB_API_DAILY_QUOTA" default:"0"`
+	LogDBMaxFailures  int           `env:"MB_API_LOG_DB_MAX_FAILURES" default:"5"`
+	LogDBCooldown     time.Duration `env:"MB_API_LOG_DB_COOLDOWN" default:"30s"`
+	WatchlistMaxInstr int           `env:"MB_API_WATCHLIST_MAX_INSTRUMENTS" default:"50"`
+	AuthDBFallback    string        `env:"MB_API_AUTH_DB_FALLBACK" default:"closed"`
+	MaxInflight       int           `env:"MB_API_MAX_INFLIGHT" default:"256"`
+	JWTSecret         string        `env:"MB_API_JWT_SECRET" default:""`
+	JWTTTL            time.Duration `env:"MB_API_JWT_TTL" default:"24h"`
+	TickerMode        string        `env:"MB_API_TICKER_MODE" default:"full"`
+	QuoteRedisTTL     time.Duration `env:"MB_API_QUOTE_REDIS_TTL" default:"0s"`
+	TickStore         bool          `env:"MB_API_TICK_STORE" default:"false"`
+	TickStoreBatch    int           `env:"MB_API_TICK_STORE_BATCH_SIZE" default:"1000"`
+	TickStoreFlush    time.Duration `env:"MB_API_TICK_STORE_FLUSH_INTERVAL" default:"1s"`
+	CandleAggregation time.Duration `env:"MB_API_CANDLE_AGGREGATION_INTERVAL" default:"10s"`
+	LogRetention      time.Duration `env:"MB_API_LOG_RETENTION" default:"720h"`
+	LogQueueSize      int           `env:"MB_API_LOG_QUEUE_SIZE" default:"10000"`
+	LogFlushInterval  time.Duration `env:"MB_API_LOG_FLUSH_INTERVAL" default:"1s"`
+	TrustedProxies    string        `env:"MB_API_TRUSTED_PROXIES" default:""`
 }
 
+// Auth fallbacks, how sessions are verified while the session store is unavailable
+const (
+	AuthFallbackOpen   = "open"
+	AuthFallbackClosed = "closed"
+)
+
+// Ticker modes, the fields of the ticks the ticker subscribes to
+const (
+	TickerModeFull  = "full"
+	TickerModeQuote = "quote"
+	TickerModeLTP   = "ltp"
+)
+
+// Quote warmup modes, how quotes are served until the API is ready
+const (
+	QuoteWarmupBlock    = "block"
+	QuoteWarmupSnapshot = "snapshot"
+)
+
 var (
 	SingleLine string = "--------------------------------------------------"
 	DoubleLine string = "=================================================="
@@ -54,6 +139,28 @@ func loadConfig() (*Config, error) {
 	if err := cfg.loadFromEnv(); err != nil {
 		return nil, err
 	}
+	if _, err := cfg.parseCacheTTLs(); err != nil {
+		return nil, fmt.Errorf("invalid value for env variable MB_API_CACHE_TTLS: %v", err)
+	}
+	if cfg.QuoteWarmupMode != QuoteWarmupBlock && cfg.QuoteWarmupMode != QuoteWarmupSnapshot {
+		return nil, fmt.Errorf("invalid value for env variable MB_API_QUOTE_WARMUP_MODE: must be `%s` or `%s`", QuoteWarmupBlock, QuoteWarmupSnapshot)
+	}
+	if cfg.AuthDBFallback != AuthFallbackOpen && cfg.AuthDBFallback != AuthFallbackClosed {
+		return nil, fmt.Errorf("invalid value for env variable MB_API_AUTH_DB_FALLBACK: must be `%s` or `%s`", AuthFallbackOpen, AuthFallbackClosed)
+	}
+	if cfg.TickerMode != TickerModeFull && cfg.TickerMode != TickerModeQuote && cfg.TickerMode != TickerModeLTP {
+		return nil, fmt.Errorf("invalid value for env variable MB_API_TICKER_MODE: must be `%s`, `%s` or `%s`", TickerModeFull, TickerModeQuote, TickerModeLTP)
+	}
+	if cfg.TickStore && (cfg.TickStoreBatch <= 0 || cfg.TickStoreFlush <= 0) {
+		return nil, fmt.Errorf("invalid value for env variables MB_API_TICK_STORE_BATCH_SIZE and MB_API_TICK_STORE_FLUSH_INTERVAL: must be positive")
+	}
+	if cfg.LogQueueSize <= 0 || cfg.LogFlushInterval <= 0 {
+		return nil, fmt.Errorf("invalid value for env variables MB_API_LOG_QUEUE_SIZE and MB_API_LOG_FLUSH_INTERVAL: must be positive")
+	}
+	// the memory storage serves a seeded login without Kite, so it is only for development
+	if cfg.IsMemoryStorage() && !cfg.IsDevelopment() {
+		return nil, fmt.Errorf("invalid value for env variable MB_API_STORAGE: `memory` needs MB_API_SERVER_ENV `development`")
+	}
 	return cfg, nil
 }
 
@@ -69,17 +176,61 @@ func (c *Config) loadFromEnv() error {
 			return fmt.Errorf("missing env tag for field %s", field.Name)
 		}
 
-		value := os.Getenv(envTag)
-		if value == "" {
+		// an optional field set to empty is respected, an unset one takes the default
+		value, isSet := os.LookupEnv(envTag)
+		defaultValue, isOptional := field.Tag.Lookup("default")
+		switch {
+		case !isOptional && !isSet:
 			return fmt.Errorf("env variable %s is required but not set", envTag)
+		case !isOptional && value == "":
+			return fmt.Errorf("env variable %s is required but set to empty", envTag)
+		case isOptional && !isSet:
+			value = defaultValue
+		case isOptional && value == "" && field.Type.Kind() != reflect.String:
+			// only strings can be blanked, other types fall back to the default
+			value = defaultValue
 		}
 
-		v.Field(i).SetString(value)
+		if err := setFieldValue(v.Field(i), value); err != nil {
+			return fmt.Errorf("invalid value for env variable %s: %v", envTag, err)
+		}
 	}
 
 	return nil
 }
 
+// setFieldValue parses the value into the field according to its kind
+func setFieldValue(field reflect.Value, value string) error {
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+	case reflect.Int, reflect.Int64:
+		// time.Duration is an int64 kind
+		if field.Type() == reflect.TypeOf(time.Duration(0)) {
+			d, err := time.ParseDuration(value)
+			if err != nil {
+				return err
+			}
+			field.SetInt(int64(d))
+			return nil
+		}
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return err
+		}
+		field.SetInt(n)
+	default:
+		return fmt.Errorf("unsupported field type %s", field.Type())
+	}
+	return nil
+}
+
 // String returns the configuration as a string
 func (c *Config) String() string {
 	var sb strings.Builder
@@ -89,15 +240,11 @@ func (c *Config) String() string {
 	sb.WriteString(SingleLine + "\n")
 
 	t := reflect.TypeOf(*c)
-	v := reflect.ValueOf(*c)
+	masked := c.Masked()
 
 	for i := 0; i < t.NumField(); i++ {
 		field := t.Field(i)
-		value := v.Field(i).String()
-
-		// Mask sensitive fields
-		value = maskSensitiveField(field.Name, value)
-		sb.WriteString(fmt.Sprintf("  %s:  %s\n", field.Name, value))
+		sb.WriteString(fmt.Sprintf("  %s:  %s\n", field.Name, masked[field.Name]))
 	}
 
 	sb.WriteString(SingleLine + "\n")
@@ -105,22 +252,140 @@ func (c *Config) String() string {
 	return sb.String()
 }
 
-func maskSensitiveField(fieldName, value string) string {
-	sensitiveFields := []string{"token", "dsn", "secret", "password", "url"}
+// Masked returns the configuration as a map of field name to value with sensitive fields masked
+func (c *Config) Masked() map[string]string {
+	t := reflect.TypeOf(*c)
+	v := reflect.ValueOf(*c)
+
+	masked := make(map[string]string, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		value := fmt.Sprint(v.Field(i).Interface())
+		masked[field.Name] = c.maskSensitiveField(field.Name, value)
+	}
+	return masked
+}
+
+// maskRevealChars returns the number of leading characters revealed by a mask,
+// a negative MaskReveal reveals 3 in development and none otherwise
+func (c *Config) maskRevealChars() int {
+	if c.MaskReveal >= 0 {
+		return c.MaskReveal
+	}
+	if c.IsDevelopment() {
+		return 3
+	}
+	return 0
+}
+
+// IsDevelopment checks if the server is running in the development environment
+func (c *Config) IsDevelopment() bool {
+	return strings.EqualFold(c.ServerEnv, "development")
+}
+
+// IsMemoryStorage checks if the server runs on in-memory storage instead of Postgres
+func (c *Config) IsMemoryStorage() bool {
+	return strings.EqualFold(c.Storage, "memory")
+}
+
+// Cache categories, their TTLs are set with `MB_API_CACHE_TTLS` as comma separated `category:duration` pairs
+const (
+	CacheQuoteNegative = "quote_negative"
+	CacheQuoteMovers   = "movers"
+	CacheInstruments   = "instruments"
+	CacheIndices       = "indices"
+	CacheAuthSessions  = "auth_sessions"
+)
+
+// defaultCacheTTL returns the TTL of a cache category not set in CacheTTLs
+func (c *Config) defaultCacheTTL(category string) time.Duration {
+	switch category {
+	case CacheQuoteNegative:
+		return c.QuoteNegativeTTL
+	case CacheQuoteMovers:
+		return c.QuoteMoversTTL
+	case CacheInstruments:
+		return 24 * time.Hour
+	case CacheIndices:
+		return 5 * time.Minute
+	case CacheAuthSessions:
+		return 10 * time.Minute
+	}
+	return 0
+}
+
+// CacheTTL returns the TTL of the cache category, a TTL of 0 disables the cache
+func (c *Config) CacheTTL(category string) time.Duration {
+	ttls, err := c.parseCacheTTLs()
+	if err == nil {
+		if ttl, ok := ttls[category]; ok {
+			return ttl
+		}
+	}
+	return c.defaultCacheTTL(category)
+}
+
+// parseCacheTTLs parses the cache TTLs, only known categories and non-negative durations are accepted
+func (c *Config) parseCacheTTLs() (map[string]time.Duration, error) {
+	ttls := make(map[string]time.Duration)
+	for _, pair := range strings.Split(c.CacheTTLs, ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+		category, value, ok := strings.Cut(pair, ":")
+		category = strings.TrimSpace(category)
+		if !ok {
+			return nil, fmt.Errorf("`%s` is not a `category:duration` pair", pair)
+		}
+		switch category {
+		case CacheQuoteNegative, CacheQuoteMovers, CacheInstruments, CacheIndices, CacheAuthSessions:
+		default:
+			return nil, fmt.Errorf("unknown cache category `%s`", category)
+		}
+		ttl, err := time.ParseDuration(strings.TrimSpace(value))
+		if err != nil || ttl < 0 {
+			return nil, fmt.Errorf("invalid duration for cache category `%s`", category)
+		}
+		ttls[category] = ttl
+	}
+	return ttls, nil
+}
+
+// IsAdmin checks if the user id is in the list of admin user ids
+func (c *Config) IsAdmin(userID string) bool {
+	for _, adminUserID := range strings.Split(c.AdminUserIDs, ",") {
+		if adminUserID = strings.TrimSpace(adminUserID); adminUserID != "" && adminUserID == userID {
+			return true
+		}
+	}
+	return false
+}
+
+// sensitiveFields are the field name patterns always masked, MaskPatterns adds to them
+var sensitiveFields = []string{"token", "dsn", "secret", "password", "url", "chatid"}
+
+func (c *Config) maskSensitiveField(fieldName, value string) string {
+	patterns := append([]string{}, sensitiveFields...)
+	for _, pattern := range strings.Split(c.MaskPatterns, ",") {
+		if pattern = strings.ToLower(strings.TrimSpace(pattern)); pattern != "" {
+			patterns = append(patterns, pattern)
+		}
+	}
 
 	fieldNameLower := strings.ToLower(fieldName)
-	for _, sensitive := range sensitiveFields {
+	for _, sensitive := range patterns {
 		if strings.Contains(fieldNameLower, sensitive) {
-			return maskValue(value)
+			return maskValue(value, c.maskRevealChars())
 		}
 	}
 
 	return value
 }
 
-func maskValue(value string) string {
-	if len(value) <= 3 {
+// maskValue masks the value, revealing up to reveal leading characters of values longer than reveal
+func maskValue(value string, reveal int) string {
+	if reveal <= 0 || len(value) <= reveal {
 		return strings.Repeat("*", 7)
 	}
-	return value[:3] + strings.Repeat("*", 7)
+	return value[:reveal] + strings.Repeat("*", 7)
 }
@@ -0,0 +1,218 @@
+package config
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// setRequiredEnv sets the required env variables, which have no default
+func setRequiredEnv(t *testing.T) {
+	t.Helper()
+	for _, name := range []string{
+		"MB_API_NAME", "MB_API_VERSION", "MB_API_URL", "MB_API_SERVER_PORT", "MB_API_SERVER_LOG_LEVEL",
+		"MB_API_PG_DSN", "MB_API_PG_SCHEMA", "MB_API_PG_LOG_LEVEL", "MB_API_REDIS_HOST", "MB_API_REDIS_PORT",
+		"MB_API_REDIS_PASSWORD", "MB_API_TELEGRAM_BOT_TOKEN", "MB_API_TELEGRAM_CHAT_ID",
+		"MB_API_KITETICKER_USER_ID", "MB_API_KITETICKER_PASSWORD", "MB_API_KITETICKER_TOTP_SECRET",
+	} {
+		t.Setenv(name, "x")
+	}
+}
+
+func TestLoadConfigStorage(t *testing.T) {
+	tests := []struct {
+		name    string
+		storage string
+		env     string
+		wantErr bool
+	}{
+		{name: "postgres in production", storage: "postgres", env: "production"},
+		{name: "memory in development", storage: "memory", env: "development"},
+		{name: "memory in production", storage: "memory", env: "production", wantErr: true},
+		{name: "memory without an env", storage: "memory", env: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setRequiredEnv(t)
+			t.Setenv("MB_API_STORAGE", tt.storage)
+			t.Setenv("MB_API_SERVER_ENV", tt.env)
+			_, err := loadConfig()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("loadConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestConfigMasking(t *testing.T) {
+	cfg := Config{
+		RedisPassword:        "redis-pass",
+		TelegramBotToken:     "bot-token",
+		TelegramChatID:       "123456789",
+		KitetickerTotpSecret: "totp-secret",
+		KitetickerUserID:     "AB1234",
+	}
+
+	tests := []struct {
+		name       string
+		env        string
+		maskReveal int
+		want       map[string]string
+	}{
+		{
+			name:       "production",
+			env:        "production",
+			maskReveal: -1,
+			want: map[string]string{
+				"RedisPassword":        "*******",
+				"TelegramBotToken":     "*******",
+				"TelegramChatID":       "*******",
+				"KitetickerTotpSecret": "*******",
+				"KitetickerUserID":     "AB1234",
+			},
+		},
+		{
+			name:       "development",
+			env:        "development",
+			maskReveal: -1,
+			want: map[string]string{
+				"RedisPassword":        "red*******",
+				"TelegramBotToken":     "bot*******",
+				"TelegramChatID":       "123*******",
+				"KitetickerTotpSecret": "tot*******",
+				"KitetickerUserID":     "AB1234",
+			},
+		},
+		{
+			name:       "reveal set in development",
+			env:        "development",
+			maskReveal: 0,
+			want: map[string]string{
+				"RedisPassword":  "*******",
+				"TelegramChatID": "*******",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := cfg
+			cfg.ServerEnv = tt.env
+			cfg.MaskReveal = tt.maskReveal
+			masked := cfg.Masked()
+			str := cfg.String()
+			for field, want := range tt.want {
+				if got := masked[field]; got != want {
+					t.Errorf("Masked()[%s] = %q, want %q", field, got, want)
+				}
+				if line := "  " + field + ":  " + want + "\n"; !strings.Contains(str, line) {
+					t.Errorf("String() is missing %q", line)
+				}
+			}
+			for _, secret := range []string{"redis-pass", "bot-token", "123456789", "totp-secret"} {
+				if strings.Contains(str, secret) {
+					t.Errorf("String() reveals %q", secret)
+				}
+			}
+		})
+	}
+}
+
+func TestLoadConfigEnvPresence(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     string
+		unset   bool
+		value   string
+		check   func(cfg *Config) bool
+		wantErr bool
+	}{
+		{name: "required unset", env: "MB_API_PG_SCHEMA", unset: true, wantErr: true},
+		{name: "required empty", env: "MB_API_PG_SCHEMA", value: "", wantErr: true},
+		{name: "required set", env: "MB_API_PG_SCHEMA", value: "api", check: func(cfg *Config) bool { return cfg.PostgresSchema == "api" }},
+		{name: "optional string unset takes the default", env: "MB_API_BREADTH_INDICES", unset: true, check: func(cfg *Config) bool { return cfg.BreadthIndices == "NSE:NIFTY 50,NSE:NIFTY BANK" }},
+		{name: "optional string empty is kept", env: "MB_API_BREADTH_INDICES", value: "", check: func(cfg *Config) bool { return cfg.BreadthIndices == "" }},
+		{name: "optional int unset takes the default", env: "MB_API_QUERY_BUDGET", unset: true, check: func(cfg *Config) bool { return cfg.QueryBudget == 20 }},
+		{name: "optional int empty takes the default", env: "MB_API_QUERY_BUDGET", value: "", check: func(cfg *Config) bool { return cfg.QueryBudget == 20 }},
+		{name: "optional int set", env: "MB_API_QUERY_BUDGET", value: "7", check: func(cfg *Config) bool { return cfg.QueryBudget == 7 }},
+		{name: "optional int invalid", env: "MB_API_QUERY_BUDGET", value: "many", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setRequiredEnv(t)
+			t.Setenv(tt.env, tt.value)
+			if tt.unset {
+				os.Unsetenv(tt.env)
+			}
+			cfg, err := loadConfig()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("loadConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.check != nil && !tt.check(cfg) {
+				t.Errorf("loadConfig() = %+v, want %s %q applied", cfg, tt.env, tt.value)
+			}
+		})
+	}
+}
+
+func TestCacheTTL(t *testing.T) {
+	cfg := &Config{
+		CacheTTLs:        "instruments:6h, indices : 2m,movers:0s",
+		QuoteNegativeTTL: 3 * time.Second,
+		QuoteMoversTTL:   10 * time.Second,
+	}
+
+	tests := []struct {
+		category string
+		want     time.Duration
+	}{
+		{CacheInstruments, 6 * time.Hour},
+		{CacheIndices, 2 * time.Minute},
+		{CacheQuoteMovers, 0},
+		{CacheQuoteNegative, 3 * time.Second},
+		{CacheAuthSessions, 10 * time.Minute},
+		{"unknown", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.category, func(t *testing.T) {
+			if got := cfg.CacheTTL(tt.category); got != tt.want {
+				t.Errorf("CacheTTL(%q) = %v, want %v", tt.category, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseCacheTTLs(t *testing.T) {
+	tests := []struct {
+		name      string
+		cacheTTLs string
+		wantErr   bool
+	}{
+		{name: "empty", cacheTTLs: ""},
+		{name: "pairs", cacheTTLs: "instruments:6h,indices:5m,auth_sessions:1m,quote_negative:2s,movers:500ms"},
+		{name: "trailing comma", cacheTTLs: "instruments:6h,"},
+		{name: "unknown category", cacheTTLs: "ltp:500ms", wantErr: true},
+		{name: "missing duration", cacheTTLs: "instruments", wantErr: true},
+		{name: "invalid duration", cacheTTLs: "instruments:6 hours", wantErr: true},
+		{name: "negative duration", cacheTTLs: "instruments:-1s", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := (&Config{CacheTTLs: tt.cacheTTLs}).parseCacheTTLs()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("parseCacheTTLs() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	t.Run("rejected on load", func(t *testing.T) {
+		setRequiredEnv(t)
+		t.Setenv("MB_API_CACHE_TTLS", "ltp:500ms")
+		if _, err := loadConfig(); err == nil || !strings.Contains(err.Error(), "MB_API_CACHE_TTLS") {
+			t.Errorf("loadConfig() error = %v, want the MB_API_CACHE_TTLS error", err)
+		}
+	})
+}
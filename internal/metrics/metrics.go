@@ -0,0 +1,226 @@
+// Package metrics contains the Prometheus metrics for the Moneybots API
+package metrics
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// feedFreshness tracks when the quotes were last successfully refreshed
+type feedFreshness struct {
+	mu         sync.RWMutex
+	lastUpdate time.Time
+	byExchange map[string]time.Time
+}
+
+var freshness = &feedFreshness{
+	lastUpdate: time.Now(), // age counts from startup until the first refresh
+	byExchange: make(map[string]time.Time),
+}
+
+var (
+	feedAgeDesc = prometheus.NewDesc(
+		"quote_feed_last_update_age_seconds",
+		"Seconds since the quotes were last successfully refreshed",
+		nil, nil,
+	)
+	feedExchangeAgeDesc = prometheus.NewDesc(
+		"quote_feed_exchange_last_update_age_seconds",
+		"Seconds since the quotes of an exchange were last successfully refreshed",
+		[]string{"exchange"}, nil,
+	)
+)
+
+// RecordQuoteRefresh records a successful refresh of the given instruments at time t
+// Instruments are in the `EXCHANGE:TRADINGSYMBOL` format
+func RecordQuoteRefresh(instruments []string, t time.Time) {
+	freshness.mu.Lock()
+	defer freshness.mu.Unlock()
+	freshness.lastUpdate = t
+	for _, instrument := range instruments {
+		if exchange, _, ok := strings.Cut(instrument, ":"); ok {
+			freshness.byExchange[exchange] = t
+		}
+	}
+}
+
+// freshnessCollector computes the feed age gauges at scrape time
+type freshnessCollector struct{}
+
+// Describe implements prometheus.Collector
+func (freshnessCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- feedAgeDesc
+	ch <- feedExchangeAgeDesc
+}
+
+// Collect implements prometheus.Collector
+func (freshnessCollector) Collect(ch chan<- prometheus.Metric) {
+	now := time.Now()
+	freshness.mu.RLock()
+	defer freshness.mu.RUnlock()
+	ch <- prometheus.MustNewConstMetric(feedAgeDesc, prometheus.GaugeValue, now.Sub(freshness.lastUpdate).Seconds())
+	for exchange, lastUpdate := range freshness.byExchange {
+		ch <- prometheus.MustNewConstMetric(feedExchangeAgeDesc, prometheus.GaugeValue, now.Sub(lastUpdate).Seconds(), exchange)
+	}
+}
+
+// RegisterMarketOpen registers the `market_open` gauge, computed from isOpen at scrape time
+func RegisterMarketOpen(isOpen func() bool) {
+	prometheus.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "market_open",
+		Help: "1 if the market is open as per the calendar, else 0",
+	}, func() float64 {
+		if isOpen() {
+			return 1
+		}
+		return 0
+	}))
+}
+
+var (
+	broadcastLatency = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "stream_broadcast_latency_seconds",
+		Help:    "Seconds from receiving a tick to handing it to a stream client",
+		Buckets: []float64{.0001, .0005, .001, .005, .01, .025, .05, .1, .25},
+	})
+	broadcastDropped = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "stream_broadcast_dropped_total",
+		Help: "Ticks dropped instead of being sent to a stream client, by reason",
+	}, []string{"reason"})
+)
+
+// RecordBroadcastSend records a tick handed to a stream client d after it was received
+func RecordBroadcastSend(d time.Duration) {
+	broadcastLatency.Observe(d.Seconds())
+}
+
+// RecordBroadcastDrop records a tick dropped for a stream client
+// reason is `timeout` for a send to a slow client that timed out, `lagging` for a client still behind
+// after a timeout or `queue_full` when the broadcast workers are all busy
+func RecordBroadcastDrop(reason string) {
+	broadcastDropped.WithLabelValues(reason).Inc()
+}
+
+var (
+	ticksStored = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "tick_store_written_total",
+		Help: "Ticks written to the ticks table",
+	})
+	ticksDropped = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "tick_store_dropped_total",
+		Help: "Ticks dropped instead of being written to the ticks table, by reason",
+	}, []string{"reason"})
+)
+
+// RecordTicksStored records n ticks written to the ticks table
+func RecordTicksStored(n int) {
+	ticksStored.Add(float64(n))
+}
+
+// RecordTicksDropped records n ticks dropped by the tick writer
+// reason is `queue_full` when the writer falls behind the ticker or `insert_failed` for a batch which failed to write
+func RecordTicksDropped(reason string, n int) {
+	ticksDropped.WithLabelValues(reason).Add(float64(n))
+}
+
+var (
+	httpRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_total",
+		Help: "HTTP requests served, by method, route and status",
+	}, []string{"method", "route", "status"})
+	httpRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "Seconds taken to serve an HTTP request, by method and route",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route"})
+)
+
+// RecordHTTPRequest records a request served with the status in d
+// route is the route pattern, like `/api/v1/quote`, so the paths with parameters share a series
+func RecordHTTPRequest(method, route string, status int, d time.Duration) {
+	httpRequests.WithLabelValues(method, route, strconv.Itoa(status)).Inc()
+	httpRequestDuration.WithLabelValues(method, route).Observe(d.Seconds())
+}
+
+var dbQueryDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+	Name:    "db_query_duration_seconds",
+	Help:    "Seconds taken by a database query, by result",
+	Buckets: []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+}, []string{"result"})
+
+// RecordDBQuery records a database query which took d, result is `ok` or `error`
+func RecordDBQuery(result string, d time.Duration) {
+	dbQueryDuration.WithLabelValues(result).Observe(d.Seconds())
+}
+
+var (
+	tickerTicks = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "ticker_ticks_received_total",
+		Help: "Ticks received from the ticker, its rate is the ticks per second",
+	})
+	tickerSubscriptions = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "ticker_subscribed_instruments",
+		Help: "Instruments subscribed on the ticker, 0 while it is stopped",
+	})
+)
+
+// RecordTick records a tick received from the ticker
+func RecordTick() {
+	tickerTicks.Inc()
+}
+
+// SetTickerSubscriptions sets the number of instruments subscribed on the ticker
+func SetTickerSubscriptions(n int) {
+	tickerSubscriptions.Set(float64(n))
+}
+
+// RegisterStreamStats registers the `stream_clients` and `stream_subscribed_tokens` gauges,
+// computed from stats at scrape time
+func RegisterStreamStats(stats func() (clients, tokens int)) {
+	prometheus.MustRegister(
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "stream_clients",
+			Help: "Clients connected to the tick streams",
+		}, func() float64 {
+			clients, _ := stats()
+			return float64(clients)
+		}),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "stream_subscribed_tokens",
+			Help: "Distinct instrument tokens subscribed by the stream clients",
+		}, func() float64 {
+			_, tokens := stats()
+			return float64(tokens)
+		}),
+	)
+}
+
+// RegisterLogWriterStats registers the `log_writer_queue_depth` gauge and the `log_writer_dropped_total` counter
+// of the database log writer, computed from stats at scrape time
+func RegisterLogWriterStats(stats func() (queued int, dropped uint64)) {
+	prometheus.MustRegister(
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "log_writer_queue_depth",
+			Help: "Log entries queued for the database",
+		}, func() float64 {
+			queued, _ := stats()
+			return float64(queued)
+		}),
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Name: "log_writer_dropped_total",
+			Help: "Log entries dropped from the full database log queue",
+		}, func() float64 {
+			_, dropped := stats()
+			return float64(dropped)
+		}),
+	)
+}
+
+func init() {
+	prometheus.MustRegister(freshnessCollector{}, broadcastLatency, broadcastDropped, ticksStored, ticksDropped,
+		httpRequests, httpRequestDuration, dbQueryDuration, tickerTicks, tickerSubscriptions)
+}
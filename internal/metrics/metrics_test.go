@@ -0,0 +1,93 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// gather scrapes the collectors into the gauge values keyed by metric name and exchange label
+func gather(t *testing.T, collectors ...prometheus.Collector) map[string]float64 {
+	t.Helper()
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(collectors...)
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatalf("Gather() error = %v", err)
+	}
+	values := make(map[string]float64)
+	for _, family := range families {
+		for _, metric := range family.GetMetric() {
+			key := family.GetName()
+			for _, label := range metric.GetLabel() {
+				key += "{" + label.GetValue() + "}"
+			}
+			values[key] = metric.GetGauge().GetValue()
+		}
+	}
+	return values
+}
+
+func TestFreshnessCollector(t *testing.T) {
+	freshness.mu.Lock()
+	freshness.byExchange = make(map[string]time.Time)
+	freshness.mu.Unlock()
+
+	now := time.Now()
+	RecordQuoteRefresh([]string{"NFO:NIFTY24JULFUT"}, now.Add(-time.Minute))
+	RecordQuoteRefresh([]string{"NSE:INFY", "BSE:TCS", "INVALID"}, now.Add(-10*time.Second))
+
+	values := gather(t, freshnessCollector{})
+	tests := []struct {
+		key  string
+		want float64
+	}{
+		{"quote_feed_last_update_age_seconds", 10},
+		{"quote_feed_exchange_last_update_age_seconds{NSE}", 10},
+		{"quote_feed_exchange_last_update_age_seconds{BSE}", 10},
+		{"quote_feed_exchange_last_update_age_seconds{NFO}", 60},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			got, ok := values[tt.key]
+			if !ok {
+				t.Fatalf("%s not collected, got %v", tt.key, values)
+			}
+			if got < tt.want || got > tt.want+5 {
+				t.Errorf("%s = %v, want about %v", tt.key, got, tt.want)
+			}
+		})
+	}
+	if len(values) != len(tests) {
+		t.Errorf("collected %d series, want %d: %v", len(values), len(tests), values)
+	}
+}
+
+func TestMarketOpenGauge(t *testing.T) {
+	open := false
+	RegisterMarketOpen(func() bool { return open })
+
+	for _, tt := range []struct {
+		open bool
+		want float64
+	}{
+		{false, 0},
+		{true, 1},
+	} {
+		open = tt.open
+		families, err := prometheus.DefaultGatherer.Gather()
+		if err != nil {
+			t.Fatalf("Gather() error = %v", err)
+		}
+		got := -1.0
+		for _, family := range families {
+			if family.GetName() == "market_open" {
+				got = family.GetMetric()[0].GetGauge().GetValue()
+			}
+		}
+		if got != tt.want {
+			t.Errorf("market_open with open = %v = %v, want %v", tt.open, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+// Package models contains the models for the Moneybots API
+package models
+
+import "time"
+
+// CandlesTableName is the name of the table for candles
+const CandlesTableName = "candles"
+
+// CandleCoverageTableName is the name of the table for the days of candles fetched from the historical API
+const CandleCoverageTableName = "candle_coverage"
+
+// Candle intervals, the intervals other than day are fetched from the historical API,
+// and the minute, 5minute, 15minute and 60minute candles of the day are also aggregated from the stored ticks
+const (
+	CandleIntervalMinute   = "minute"
+	CandleInterval3Minute  = "3minute"
+	CandleInterval5Minute  = "5minute"
+	CandleInterval10Minute = "10minute"
+	CandleInterval15Minute = "15minute"
+	CandleInterval30Minute = "30minute"
+	CandleInterval60Minute = "60minute"
+	CandleIntervalDay      = "day"
+)
+
+// CandleIntervalMaxDays are the max days of a historical API request for each interval
+var CandleIntervalMaxDays = map[string]int{
+	CandleIntervalMinute:   60,
+	CandleInterval3Minute:  100,
+	CandleInterval5Minute:  100,
+	CandleInterval10Minute: 100,
+	CandleInterval15Minute: 200,
+	CandleInterval30Minute: 200,
+	CandleInterval60Minute: 400,
+	CandleIntervalDay:      2000,
+}
+
+// CandleModel is an OHLCV+OI candle of an instrument for an interval
+type CandleModel struct {
+	InstrumentToken uint32    `gorm:"primaryKey;autoIncrement:false" json:"instrument_token"`
+	Interval        string    `gorm:"primaryKey;type:varchar(10)" json:"interval"`
+	Timestamp       time.Time `gorm:"primaryKey" json:"timestamp"`
+	Instrument      string    `gorm:"index" json:"instrument"`
+	Open            float64   `json:"open"`
+	High            float64   `json:"high"`
+	Low             float64   `json:"low"`
+	Close           float64   `json:"close"`
+	Volume          uint64    `json:"volume"`
+	OI              uint64    `json:"oi"`
+	UpdatedAt       time.Time `gorm:"autoUpdateTime" json:"-"`
+}
+
+// TableName specifies the table name for the Candle model
+func (CandleModel) TableName() string {
+	return CandlesTableName
+}
+
+// CandleCoverageModel is a day whose candles of an interval were fetched in full from the historical API,
+// so they are served from the candles table, the current trading day is never covered
+type CandleCoverageModel struct {
+	InstrumentToken uint32    `gorm:"primaryKey;autoIncrement:false" json:"instrument_token"`
+	Interval        string    `gorm:"primaryKey;type:varchar(10)" json:"interval"`
+	Day             string    `gorm:"primaryKey;type:varchar(10)" json:"day"`
+	CreatedAt       time.Time `json:"-"`
+}
+
+// TableName specifies the table name for the CandleCoverage model
+func (CandleCoverageModel) TableName() string {
+	return CandleCoverageTableName
+}
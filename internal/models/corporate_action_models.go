@@ -0,0 +1,40 @@
+// Package models contains the models for the Moneybots API
+package models
+
+import "time"
+
+// CorporateActionsTableName is the name of the table for corporate actions
+const CorporateActionsTableName = "corporate_actions"
+
+// Corporate action types
+const (
+	CorporateActionSplit    = "split"
+	CorporateActionBonus    = "bonus"
+	CorporateActionDividend = "dividend"
+)
+
+// CorporateActionModel is a split, bonus or dividend of an instrument going ex on ExDate
+// Ratio is the shares held after the action per share held before it, e.g. 5 for a 1:5 split
+// and 2 for a 1:1 bonus, Amount is the dividend per share
+type CorporateActionModel struct {
+	InstrumentToken uint32    `gorm:"primaryKey;autoIncrement:false" json:"instrument_token"`
+	ExDate          string    `gorm:"primaryKey;type:varchar(10)" json:"ex_date"`
+	Type            string    `gorm:"primaryKey;type:varchar(10)" json:"type"`
+	Instrument      string    `gorm:"index" json:"instrument"`
+	Ratio           float64   `json:"ratio,omitempty"`
+	Amount          float64   `json:"amount,omitempty"`
+	UpdatedAt       time.Time `gorm:"autoUpdateTime" json:"-"`
+}
+
+// TableName specifies the table name for the CorporateAction model
+func (CorporateActionModel) TableName() string {
+	return CorporateActionsTableName
+}
+
+// CorporateActions are the corporate actions of an instrument sorted by ex date,
+// split into the ones going ex from today on and the past ones
+type CorporateActions struct {
+	InstrumentToken uint32                 `json:"instrument_token"`
+	Upcoming        []CorporateActionModel `json:"upcoming"`
+	Past            []CorporateActionModel `json:"past"`
+}
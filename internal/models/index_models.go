@@ -3,22 +3,24 @@ package models
 
 import "time"
 
-// TableName is the name of the table for instruments
-var IndexTableName = "indices"
+// IndexTableName is the name of the table for indices
+const IndexTableName = "indices"
 
 // Company Name	Industry	Symbol	Series	ISIN Code
 
 // Index represents a trading index
 type IndexModel struct {
-	ID            uint32    `gorm:"primaryKey;autoIncrement" json:"-"`
-	Index         string    `json:"index" gorm:"index"`
-	Exchange      string    `json:"exchange"`
-	Tradingsymbol string    `json:"tradingsymbol" gorm:"index"`
-	CompanyName   string    `json:"company_name"`
-	Industry      string    `json:"industry" gorm:"index"`
-	Series        string    `json:"series"`
-	ISINCode      string    `json:"isin_code"`
-	UpdatedAt     time.Time `gorm:"autoUpdateTime" json:"-"`
+	ID              uint32    `gorm:"primaryKey;autoIncrement" json:"-"`
+	Index           string    `json:"index" gorm:"index"`
+	Exchange        string    `json:"exchange"`
+	Tradingsymbol   string    `json:"tradingsymbol" gorm:"index"`
+	InstrumentToken uint32    `json:"instrument_token" gorm:"index"` // 0 when the constituent is not in the instrument master
+	CompanyName     string    `json:"company_name"`
+	Industry        string    `json:"industry" gorm:"index"`
+	Series          string    `json:"series"`
+	ISINCode        string    `json:"isin_code"`
+	Weight          float64   `json:"weight"` // 0 when the weight of the constituent is not known
+	UpdatedAt       time.Time `gorm:"autoUpdateTime" json:"-"`
 }
 
 // TableName specifies the table name for the Index model
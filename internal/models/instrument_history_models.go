@@ -0,0 +1,64 @@
+// Package models contains the models for the Moneybots API
+package models
+
+// InstrumentHistoryTableName is the name of the table for the versions of the instrument master
+const InstrumentHistoryTableName = "instrument_history"
+
+// InstrumentVersionModel is a version of an instrument of the instrument master,
+// valid from the sync date ValidFrom up to but excluding ValidTo, ValidTo is nil for the current version
+// Only the syncs which change an instrument add a version, so the history grows with the changes
+// and not with the number of syncs
+type InstrumentVersionModel struct {
+	ID              uint    `gorm:"primaryKey" json:"-"`
+	InstrumentToken uint32  `gorm:"index" json:"instrument_token"`
+	ExchangeToken   uint32  `json:"exchange_token"`
+	Tradingsymbol   string  `gorm:"index:idx_ih_ex_ts,priority:2" json:"tradingsymbol"`
+	Name            string  `json:"name"`
+	Expiry          string  `json:"expiry"`
+	Strike          float64 `json:"strike"`
+	TickSize        float64 `json:"tick_size"`
+	LotSize         uint    `json:"lot_size"`
+	InstrumentType  string  `json:"instrument_type"`
+	Segment         string  `json:"segment"`
+	Exchange        string  `gorm:"index:idx_ih_ex_ts,priority:1" json:"exchange"`
+	ValidFrom       string  `gorm:"type:varchar(10);index" json:"valid_from"`
+	ValidTo         *string `gorm:"type:varchar(10);index" json:"valid_to"`
+}
+
+// TableName specifies the table name for the InstrumentVersion model
+func (InstrumentVersionModel) TableName() string {
+	return InstrumentHistoryTableName
+}
+
+// SameAs reports whether the version describes the instrument, the last price is not versioned
+func (v InstrumentVersionModel) SameAs(instrument InstrumentModel) bool {
+	return v.InstrumentToken == instrument.InstrumentToken &&
+		v.ExchangeToken == instrument.ExchangeToken &&
+		v.Tradingsymbol == instrument.Tradingsymbol &&
+		v.Name == instrument.Name &&
+		v.Expiry == instrument.Expiry &&
+		v.Strike == instrument.Strike &&
+		v.TickSize == instrument.TickSize &&
+		v.LotSize == instrument.LotSize &&
+		v.InstrumentType == instrument.InstrumentType &&
+		v.Segment == instrument.Segment &&
+		v.Exchange == instrument.Exchange
+}
+
+// NewInstrumentVersion creates the version of the instrument valid from the date validFrom
+func NewInstrumentVersion(instrument InstrumentModel, validFrom string) InstrumentVersionModel {
+	return InstrumentVersionModel{
+		InstrumentToken: instrument.InstrumentToken,
+		ExchangeToken:   instrument.ExchangeToken,
+		Tradingsymbol:   instrument.Tradingsymbol,
+		Name:            instrument.Name,
+		Expiry:          instrument.Expiry,
+		Strike:          instrument.Strike,
+		TickSize:        instrument.TickSize,
+		LotSize:         instrument.LotSize,
+		InstrumentType:  instrument.InstrumentType,
+		Segment:         instrument.Segment,
+		Exchange:        instrument.Exchange,
+		ValidFrom:       validFrom,
+	}
+}
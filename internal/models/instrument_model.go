@@ -1,10 +1,14 @@
 // Package models contains the models for the Moneybots API
 package models
 
-import "time"
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
 
-// TableName is the name of the table for instruments
-var InstrumentsTableName = "instruments"
+// InstrumentsTableName is the name of the table for instruments
+const InstrumentsTableName = "instruments"
 
 // Instrument represents a trading instrument
 type InstrumentModel struct {
@@ -28,6 +32,71 @@ func (InstrumentModel) TableName() string {
 	return InstrumentsTableName
 }
 
+// FreezeLimitsTableName is the name of the table for freeze quantity limits
+const FreezeLimitsTableName = "freeze_limits"
+
+// FreezeLimitModel is the max quantity of a single order in the derivatives of an underlying
+type FreezeLimitModel struct {
+	Exchange  string    `gorm:"primaryKey" json:"exchange"`
+	Name      string    `gorm:"primaryKey" json:"name"`
+	FreezeQty uint32    `json:"freeze_quantity"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"-"`
+}
+
+// TableName specifies the table name for the FreezeLimit model
+func (FreezeLimitModel) TableName() string {
+	return FreezeLimitsTableName
+}
+
+// InstrumentLimits are the order quantity limits of an instrument
+// FreezeQuantity is nil for instruments without a freeze limit, such as equities
+type InstrumentLimits struct {
+	InstrumentToken uint32  `json:"instrument_token"`
+	LotSize         uint    `json:"lot_size"`
+	FreezeQuantity  *uint32 `json:"freeze_quantity"`
+}
+
+// InstrumentsChecksum is the checksum of the instruments of an exchange, or of all instruments
+type InstrumentsChecksum struct {
+	Exchange string    `json:"exchange,omitempty"`
+	Checksum string    `json:"checksum"`
+	Records  int64     `json:"records"`
+	SyncedAt time.Time `json:"synced_at"`
+}
+
+// InstrumentsSyncError is a malformed row of the instruments CSV, skipped by a sync
+type InstrumentsSyncError struct {
+	Line   int    `json:"line"`
+	Reason string `json:"reason"`
+}
+
+// InstrumentsSyncReport is the outcome of an instruments sync, Records is the number of instruments
+// after the sync and Errors holds at most the first few of the Skipped rows
+// Unchanged is set when the instruments were not modified upstream, so the reload was skipped
+type InstrumentsSyncReport struct {
+	Records   int64                  `json:"records"`
+	Inserted  int64                  `json:"inserted"`
+	Skipped   int                    `json:"skipped"`
+	Unchanged bool                   `json:"unchanged"`
+	Errors    []InstrumentsSyncError `json:"errors"`
+}
+
+// ParseInstrumentToken parses an instrument token strictly as a non zero uint32
+// Negative, zero, non digit and out of range values are rejected
+func ParseInstrumentToken(s string) (uint32, error) {
+	token, err := strconv.ParseUint(s, 10, 32)
+	if err != nil {
+		if numErr, ok := err.(*strconv.NumError); ok && numErr.Err == strconv.ErrRange {
+			return 0, fmt.Errorf("invalid instrument token `%s`, out of range", s)
+		}
+		return 0, fmt.Errorf("invalid instrument token `%s`, must be digits", s)
+	}
+	if token == 0 {
+		return 0, fmt.Errorf("invalid instrument token `%s`, must be greater than zero", s)
+	}
+	return uint32(token), nil
+}
+
 // QueryInstrumentsParams is the parameters for the QueryInstruments endpoint
 type QueryInstrumentsParams struct {
 	Exchange        string
@@ -38,4 +107,12 @@ type QueryInstrumentsParams struct {
 	Strike          string
 	Segment         string
 	InstrumentType  string
+	// TradingsymbolPrefix matches the tradingsymbols starting with it
+	TradingsymbolPrefix string
+	// StrikeMin and StrikeMax bound the strike, inclusive
+	StrikeMin string
+	StrikeMax string
+	// Limit pages the instruments ordered by instrument token, 0 returns all of them
+	Limit  int
+	Offset int
 }
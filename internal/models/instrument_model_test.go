@@ -0,0 +1,35 @@
+package models
+
+import "testing"
+
+func TestParseInstrumentToken(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    uint32
+		wantErr string
+	}{
+		{name: "token", input: "408065", want: 408065},
+		{name: "max uint32", input: "4294967295", want: 4294967295},
+		{name: "out of range", input: "4294967296", wantErr: "invalid instrument token `4294967296`, out of range"},
+		{name: "zero", input: "0", wantErr: "invalid instrument token `0`, must be greater than zero"},
+		{name: "negative", input: "-1", wantErr: "invalid instrument token `-1`, must be digits"},
+		{name: "not digits", input: "12a4", wantErr: "invalid instrument token `12a4`, must be digits"},
+		{name: "empty", input: "", wantErr: "invalid instrument token ``, must be digits"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseInstrumentToken(tt.input)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Errorf("ParseInstrumentToken(%q) error = %v, want %s", tt.input, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("ParseInstrumentToken(%q) = %d, %v, want %d", tt.input, got, err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,101 @@
+// Package models contains the models for the Moneybots API
+package models
+
+import "strings"
+
+// Exchange is an exchange as named by Kite, e.g. `NSE` or `NFO`
+type Exchange string
+
+// Exchanges of the instruments
+const (
+	ExchangeNSE Exchange = "NSE"
+	ExchangeBSE Exchange = "BSE"
+	ExchangeNFO Exchange = "NFO"
+	ExchangeBFO Exchange = "BFO"
+	ExchangeCDS Exchange = "CDS"
+	ExchangeBCD Exchange = "BCD"
+	ExchangeMCX Exchange = "MCX"
+	ExchangeNCO Exchange = "NCO"
+)
+
+// IsDerivative checks if the exchange trades only derivatives
+func (e Exchange) IsDerivative() bool {
+	switch e {
+	case ExchangeNFO, ExchangeBFO, ExchangeCDS, ExchangeBCD, ExchangeMCX, ExchangeNCO:
+		return true
+	}
+	return false
+}
+
+// IsCommodity checks if the exchange is a commodity exchange
+func (e Exchange) IsCommodity() bool {
+	return e == ExchangeMCX || e == ExchangeNCO
+}
+
+// IsCurrency checks if the exchange is a currency derivatives exchange
+func (e Exchange) IsCurrency() bool {
+	return e == ExchangeCDS || e == ExchangeBCD
+}
+
+// InstrumentExchange returns the exchange of an `EXCHANGE:TRADINGSYMBOL` instrument
+func InstrumentExchange(instrument string) Exchange {
+	exchange, _, _ := strings.Cut(instrument, ":")
+	return Exchange(exchange)
+}
+
+// Segment is a market segment as named by Kite, e.g. `NSE`, `NFO-FUT` or `INDICES`
+type Segment string
+
+// Segments of the instruments, the cash segments are named after their exchange
+const (
+	SegmentNSE     Segment = "NSE"
+	SegmentBSE     Segment = "BSE"
+	SegmentIndices Segment = "INDICES"
+	SegmentNFOFut  Segment = "NFO-FUT"
+	SegmentNFOOpt  Segment = "NFO-OPT"
+	SegmentBFOFut  Segment = "BFO-FUT"
+	SegmentBFOOpt  Segment = "BFO-OPT"
+	SegmentCDSFut  Segment = "CDS-FUT"
+	SegmentCDSOpt  Segment = "CDS-OPT"
+	SegmentBCDFut  Segment = "BCD-FUT"
+	SegmentBCDOpt  Segment = "BCD-OPT"
+	SegmentMCXFut  Segment = "MCX-FUT"
+	SegmentMCXOpt  Segment = "MCX-OPT"
+	SegmentNCOFut  Segment = "NCO-FUT"
+	SegmentNCOOpt  Segment = "NCO-OPT"
+)
+
+// IsFuture checks if the segment is a futures segment
+func (s Segment) IsFuture() bool {
+	return strings.HasSuffix(string(s), "-FUT")
+}
+
+// IsOption checks if the segment is an options segment
+func (s Segment) IsOption() bool {
+	return strings.HasSuffix(string(s), "-OPT")
+}
+
+// IsDerivative checks if the segment is a futures or options segment
+func (s Segment) IsDerivative() bool {
+	return s.IsFuture() || s.IsOption()
+}
+
+// IsCommodity checks if the segment is on a commodity exchange
+func (s Segment) IsCommodity() bool {
+	return ExchangeOf(s).IsCommodity()
+}
+
+// IsIndexSegment checks if the segment is the indices segment
+func (s Segment) IsIndexSegment() bool {
+	return s == SegmentIndices
+}
+
+// ExchangeOf returns the exchange of the segment
+// The indices segment spans exchanges, so it has no exchange of its own and an empty one is returned
+func ExchangeOf(segment Segment) Exchange {
+	if segment.IsIndexSegment() {
+		return ""
+	}
+	exchange, _, _ := strings.Cut(string(segment), "-")
+	return Exchange(exchange)
+}
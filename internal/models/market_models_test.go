@@ -0,0 +1,103 @@
+package models
+
+import "testing"
+
+func TestExchangeClassification(t *testing.T) {
+	tests := []struct {
+		exchange       Exchange
+		wantDerivative bool
+		wantCommodity  bool
+		wantCurrency   bool
+	}{
+		{ExchangeNSE, false, false, false},
+		{ExchangeBSE, false, false, false},
+		{ExchangeNFO, true, false, false},
+		{ExchangeBFO, true, false, false},
+		{ExchangeCDS, true, false, true},
+		{ExchangeBCD, true, false, true},
+		{ExchangeMCX, true, true, false},
+		{ExchangeNCO, true, true, false},
+		{Exchange(""), false, false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.exchange), func(t *testing.T) {
+			if got := tt.exchange.IsDerivative(); got != tt.wantDerivative {
+				t.Errorf("IsDerivative() = %v, want %v", got, tt.wantDerivative)
+			}
+			if got := tt.exchange.IsCommodity(); got != tt.wantCommodity {
+				t.Errorf("IsCommodity() = %v, want %v", got, tt.wantCommodity)
+			}
+			if got := tt.exchange.IsCurrency(); got != tt.wantCurrency {
+				t.Errorf("IsCurrency() = %v, want %v", got, tt.wantCurrency)
+			}
+		})
+	}
+}
+
+func TestSegmentClassification(t *testing.T) {
+	tests := []struct {
+		segment       Segment
+		wantExchange  Exchange
+		wantFuture    bool
+		wantOption    bool
+		wantCommodity bool
+		wantIndex     bool
+	}{
+		{SegmentNSE, ExchangeNSE, false, false, false, false},
+		{SegmentBSE, ExchangeBSE, false, false, false, false},
+		{SegmentIndices, "", false, false, false, true},
+		{SegmentNFOFut, ExchangeNFO, true, false, false, false},
+		{SegmentNFOOpt, ExchangeNFO, false, true, false, false},
+		{SegmentBFOFut, ExchangeBFO, true, false, false, false},
+		{SegmentBFOOpt, ExchangeBFO, false, true, false, false},
+		{SegmentCDSFut, ExchangeCDS, true, false, false, false},
+		{SegmentCDSOpt, ExchangeCDS, false, true, false, false},
+		{SegmentBCDFut, ExchangeBCD, true, false, false, false},
+		{SegmentBCDOpt, ExchangeBCD, false, true, false, false},
+		{SegmentMCXFut, ExchangeMCX, true, false, true, false},
+		{SegmentMCXOpt, ExchangeMCX, false, true, true, false},
+		{SegmentNCOFut, ExchangeNCO, true, false, true, false},
+		{SegmentNCOOpt, ExchangeNCO, false, true, true, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.segment), func(t *testing.T) {
+			if got := ExchangeOf(tt.segment); got != tt.wantExchange {
+				t.Errorf("ExchangeOf() = %q, want %q", got, tt.wantExchange)
+			}
+			if got := tt.segment.IsFuture(); got != tt.wantFuture {
+				t.Errorf("IsFuture() = %v, want %v", got, tt.wantFuture)
+			}
+			if got := tt.segment.IsOption(); got != tt.wantOption {
+				t.Errorf("IsOption() = %v, want %v", got, tt.wantOption)
+			}
+			if got := tt.segment.IsDerivative(); got != (tt.wantFuture || tt.wantOption) {
+				t.Errorf("IsDerivative() = %v, want %v", got, tt.wantFuture || tt.wantOption)
+			}
+			if got := tt.segment.IsCommodity(); got != tt.wantCommodity {
+				t.Errorf("IsCommodity() = %v, want %v", got, tt.wantCommodity)
+			}
+			if got := tt.segment.IsIndexSegment(); got != tt.wantIndex {
+				t.Errorf("IsIndexSegment() = %v, want %v", got, tt.wantIndex)
+			}
+		})
+	}
+}
+
+func TestInstrumentExchange(t *testing.T) {
+	tests := []struct {
+		instrument string
+		want       Exchange
+	}{
+		{"NSE:INFY", ExchangeNSE},
+		{"NFO:NIFTY24OCTFUT", ExchangeNFO},
+		{"INFY", Exchange("INFY")},
+	}
+
+	for _, tt := range tests {
+		if got := InstrumentExchange(tt.instrument); got != tt.want {
+			t.Errorf("InstrumentExchange(%q) = %q, want %q", tt.instrument, got, tt.want)
+		}
+	}
+}
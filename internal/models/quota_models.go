@@ -0,0 +1,17 @@
+// Package models contains the models for the Moneybots API
+package models
+
+// QuotaUsageTableName is the name of the table for the daily quota usage
+const QuotaUsageTableName = "quota_usage"
+
+// QuotaUsageModel is the number of quota calls of a user on a market day
+type QuotaUsageModel struct {
+	UserId string `gorm:"primaryKey;type:varchar(20)"`
+	Day    string `gorm:"primaryKey;type:varchar(10)"`
+	Used   int    `gorm:"not null"`
+}
+
+// TableName specifies the table name for the QuotaUsage model
+func (QuotaUsageModel) TableName() string {
+	return QuotaUsageTableName
+}
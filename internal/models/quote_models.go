@@ -1,10 +1,16 @@
 // Package models contains the models for the Moneybots API
 package models
 
+import (
+	"bytes"
+	"encoding/json"
+)
+
 // QuoteResponse is the response for the quote API
 type QuoteResponse struct {
-	Status string                 `json:"status"`
-	Data   map[string]interface{} `json:"data"`
+	Status     string                 `json:"status"`
+	Data       map[string]interface{} `json:"data"`
+	ServerTime string                 `json:"server_time,omitempty"`
 }
 
 // OHLC is the OHLC data for a given instrument
@@ -16,9 +22,72 @@ type OHLC struct {
 }
 
 // Depth is the depth data for a given instrument
+// When Compact is set, the levels are serialized as `[price, quantity, orders]` tuples
 type Depth struct {
-	Buy  [5]DepthItem `json:"buy"`
-	Sell [5]DepthItem `json:"sell"`
+	Buy     [5]DepthItem `json:"buy"`
+	Sell    [5]DepthItem `json:"sell"`
+	Compact bool         `json:"-"`
+}
+
+// compactDepth is the compact serialization of Depth
+type compactDepth struct {
+	Buy  [5][3]float64 `json:"buy"`
+	Sell [5][3]float64 `json:"sell"`
+}
+
+// MarshalJSON serializes the depth in the object or the compact form
+func (d Depth) MarshalJSON() ([]byte, error) {
+	if !d.Compact {
+		type depth Depth // avoid recursion
+		return json.Marshal(depth(d))
+	}
+	var cd compactDepth
+	for i := range d.Buy {
+		cd.Buy[i] = [3]float64{d.Buy[i].Price, float64(d.Buy[i].Quantity), float64(d.Buy[i].Orders)}
+		cd.Sell[i] = [3]float64{d.Sell[i].Price, float64(d.Sell[i].Quantity), float64(d.Sell[i].Orders)}
+	}
+	return json.Marshal(cd)
+}
+
+// UnmarshalJSON deserializes the depth from either the object or the compact form
+func (d *Depth) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Buy  json.RawMessage `json:"buy"`
+		Sell json.RawMessage `json:"sell"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	// the compact form's levels are arrays, the object form's are objects,
+	// pretty-printed input may have whitespace between the brackets
+	if isCompactLevels(raw.Buy) {
+		var cd compactDepth
+		if err := json.Unmarshal(data, &cd); err != nil {
+			return err
+		}
+		for i := range cd.Buy {
+			d.Buy[i] = DepthItem{Price: cd.Buy[i][0], Quantity: uint32(cd.Buy[i][1]), Orders: uint32(cd.Buy[i][2])}
+			d.Sell[i] = DepthItem{Price: cd.Sell[i][0], Quantity: uint32(cd.Sell[i][1]), Orders: uint32(cd.Sell[i][2])}
+		}
+		d.Compact = true
+		return nil
+	}
+	type depth Depth // avoid recursion
+	var od depth
+	if err := json.Unmarshal(data, &od); err != nil {
+		return err
+	}
+	*d = Depth(od)
+	return nil
+}
+
+// isCompactLevels reports whether the levels are an array of arrays, the first level decides
+func isCompactLevels(levels json.RawMessage) bool {
+	levels = bytes.TrimSpace(levels)
+	if !bytes.HasPrefix(levels, []byte("[")) {
+		return false
+	}
+	return bytes.HasPrefix(bytes.TrimSpace(levels[1:]), []byte("["))
 }
 
 // DepthItem is the depth item for a given instrument
@@ -34,24 +103,48 @@ type QuoteData struct {
 	Mode               string  `json:"mode"`
 	InstrumentToken    uint32  `json:"instrument_token"`
 	IsTradable         bool    `json:"is_tradable"`
+	Suspended          string  `json:"suspended,omitempty"`
 	IsIndex            bool    `json:"is_index"`
 	Timestamp          string  `json:"timestamp"`
 	LastTradeTime      string  `json:"last_trade_time"`
 	LastPrice          float64 `json:"last_price"`
+	Currency           string  `json:"currency,omitempty"`
+	PriceUnit          string  `json:"price_unit,omitempty"`
 	LastTradedQuantity uint32  `json:"last_traded_quantity"`
 	TotalBuyQuantity   uint32  `json:"total_buy_quantity"`
 	TotalSellQuantity  uint32  `json:"total_sell_quantity"`
 	VolumeTraded       uint32  `json:"volume"`
 	// TotalBuy           uint32  `json:"total_buy"`
 	// TotalSell          uint32  `json:"total_sell"`
-	AverageTradePrice float64 `json:"average_price"`
-	OI                uint32  `json:"oi"`
-	OIDayHigh         uint32  `json:"oi_day_high"`
-	OIDayLow          uint32  `json:"oi_day_low"`
-	NetChange         float64 `json:"net_change"`
-	OHLC              OHLC    `json:"ohlc"`
-	Depth             Depth   `json:"depth"`
-	UpdatedAt         string  `json:"-"`
+	AverageTradePrice float64  `json:"average_price"`
+	OI                uint32   `json:"oi"`
+	OIDayHigh         uint32   `json:"oi_day_high"`
+	OIDayLow          uint32   `json:"oi_day_low"`
+	OIChange          *int64   `json:"oi_change,omitempty"`
+	OIChangePercent   *float64 `json:"oi_change_percent,omitempty"`
+	Contract          string   `json:"contract,omitempty"`
+	DaysToExpiry      *int     `json:"days_to_expiry,omitempty"`
+	FreezeQuantity    *uint32  `json:"freeze_quantity,omitempty"`
+	MetadataMissing   bool     `json:"metadata_missing,omitempty"`
+	NetChange         float64  `json:"net_change"`
+	OHLC              OHLC     `json:"ohlc"`
+	Depth             Depth    `json:"depth"`
+	UpdatedAt         string   `json:"-"`
+}
+
+// IndexQuoteData is the full quote data for an index, which has no depth, OI or volume
+type IndexQuoteData struct {
+	Instrument      string  `json:"instrument"`
+	Mode            string  `json:"mode"`
+	InstrumentToken uint32  `json:"instrument_token"`
+	IsTradable      bool    `json:"is_tradable"`
+	Suspended       string  `json:"suspended,omitempty"`
+	IsIndex         bool    `json:"is_index"`
+	Timestamp       string  `json:"timestamp"`
+	LastPrice       float64 `json:"last_price"`
+	NetChange       float64 `json:"net_change"`
+	OHLC            OHLC    `json:"ohlc"`
+	UpdatedAt       string  `json:"-"`
 }
 
 // OHLCData is the OHLC data for a given instrument
@@ -70,6 +163,37 @@ type OHLCData struct {
 type LTPData struct {
 	InstrumentToken uint32  `json:"-"`
 	LastPrice       float64 `json:"last_price"`
+	Currency        string  `json:"currency,omitempty"`
+	PriceUnit       string  `json:"price_unit,omitempty"`
 	Timestamp       string  `json:"timestamp"`
 	UpdatedAt       string  `json:"-"`
 }
+
+// QuoteChangesRequest is the request for the quote changes API
+// Version maps the instrument token to the last seen update time in unix nanoseconds
+type QuoteChangesRequest struct {
+	Instruments []string         `json:"instruments"`
+	Version     map[string]int64 `json:"version"`
+}
+
+// QuoteChangesData is the response data for the quote changes API
+type QuoteChangesData struct {
+	Quotes  map[string]interface{} `json:"quotes"`
+	Version map[string]int64       `json:"version"`
+}
+
+// QuoteSummaryRequest is the request for the quote summary API
+type QuoteSummaryRequest struct {
+	Instruments []string `json:"instruments"`
+}
+
+// QuoteSummaryData is the watchlist summary of an instrument
+// RangePosition is the position of the last price within the day range, from 0 at the low to 1 at the high
+type QuoteSummaryData struct {
+	LastPrice     float64  `json:"last_price"`
+	NetChange     float64  `json:"net_change"`
+	ChangePercent *float64 `json:"change_percent,omitempty"`
+	DayHigh       float64  `json:"day_high"`
+	DayLow        float64  `json:"day_low"`
+	RangePosition *float64 `json:"range_position,omitempty"`
+}
@@ -0,0 +1,69 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestDepthJSONRoundTrip(t *testing.T) {
+	var depth Depth
+	for i := range depth.Buy {
+		depth.Buy[i] = DepthItem{Price: 1500.05 - float64(i)*0.05, Quantity: uint32(10 * (i + 1)), Orders: uint32(i + 1)}
+		depth.Sell[i] = DepthItem{Price: 1500.10 + float64(i)*0.05, Quantity: uint32(20 * (i + 1)), Orders: uint32(i + 2)}
+	}
+
+	tests := []struct {
+		name    string
+		compact bool
+		marshal func(v any) ([]byte, error)
+	}{
+		{name: "object", marshal: json.Marshal},
+		{name: "compact", compact: true, marshal: json.Marshal},
+		{name: "object pretty-printed", marshal: func(v any) ([]byte, error) { return json.MarshalIndent(v, "", "  ") }},
+		{name: "compact pretty-printed", compact: true, marshal: func(v any) ([]byte, error) { return json.MarshalIndent(v, "", "  ") }},
+		{name: "compact tab-indented", compact: true, marshal: func(v any) ([]byte, error) { return json.MarshalIndent(v, "\t", "\t") }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			in := depth
+			in.Compact = tt.compact
+			data, err := tt.marshal(in)
+			if err != nil {
+				t.Fatalf("Marshal() error = %v", err)
+			}
+			var got Depth
+			if err := json.Unmarshal(data, &got); err != nil {
+				t.Fatalf("Unmarshal() error = %v, data %s", err, data)
+			}
+			if got != in {
+				t.Errorf("Unmarshal() = %+v, want %+v", got, in)
+			}
+		})
+	}
+}
+
+func TestDepthUnmarshalJSONSpaced(t *testing.T) {
+	tests := []struct {
+		name        string
+		data        string
+		wantCompact bool
+	}{
+		{name: "compact spaced", data: `{"buy": [ [1, 2, 3] ], "sell": [ [4, 5, 6] ]}`, wantCompact: true},
+		{name: "compact newline", data: "{\"buy\": [\n[1, 2, 3]\n], \"sell\": [\n[4, 5, 6]\n]}", wantCompact: true},
+		{name: "object spaced", data: `{"buy": [ {"price": 1, "quantity": 2, "orders": 3} ], "sell": [ {"price": 4, "quantity": 5, "orders": 6} ]}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got Depth
+			if err := json.Unmarshal([]byte(tt.data), &got); err != nil {
+				t.Fatalf("Unmarshal() error = %v", err)
+			}
+			want := DepthItem{Price: 1, Quantity: 2, Orders: 3}
+			if got.Compact != tt.wantCompact || got.Buy[0] != want || got.Sell[0].Price != 4 {
+				t.Errorf("Unmarshal() = %+v, want compact %v and buy %+v", got, tt.wantCompact, want)
+			}
+		})
+	}
+}
@@ -17,6 +17,9 @@ type SessionModel struct {
 	Enctoken       string    `gorm:"index" json:"enctoken"`
 	LoginTime      string    `json:"login_time"`
 	HashedPassword string    `gorm:"index:idx_uid_hpw,priority:2" json:"-"`
+	Disabled       bool      `gorm:"default:false" json:"-"`
+	DailyQuota     *int      `json:"-"` // overrides the default daily quota when set, 0 is unlimited
+	Role           string    `gorm:"default:''" json:"-"`
 	CreatedAt      time.Time `gorm:"autoCreateTime" json:"-"`
 	UpdatedAt      time.Time `gorm:"autoUpdateTime" json:"-"`
 }
@@ -24,3 +27,61 @@ type SessionModel struct {
 func (SessionModel) TableName() string {
 	return SessionsTableName
 }
+
+// Roles of the users, a user without a role has the RoleUser scopes
+const (
+	RoleAdmin    = "admin"
+	RoleUser     = "user"
+	RoleReadOnly = "readonly"
+)
+
+// Scopes required by the route groups
+const (
+	ScopeQuoteRead       = "quote:read"
+	ScopeInstrumentsRead = "instruments:read"
+	ScopeTickerWrite     = "ticker:write"
+	ScopeAdmin           = "admin"
+)
+
+// roleScopes are the scopes granted to each role
+var roleScopes = map[string][]string{
+	RoleAdmin:    {ScopeQuoteRead, ScopeInstrumentsRead, ScopeTickerWrite, ScopeAdmin},
+	RoleUser:     {ScopeQuoteRead, ScopeInstrumentsRead, ScopeTickerWrite},
+	RoleReadOnly: {ScopeQuoteRead, ScopeInstrumentsRead},
+}
+
+// IsValidRole checks if the role is one of the known roles
+func IsValidRole(role string) bool {
+	_, ok := roleScopes[role]
+	return ok
+}
+
+// Scopes returns the scopes granted to the session's role
+func (s SessionModel) Scopes() []string {
+	if s.Role == "" {
+		return roleScopes[RoleUser]
+	}
+	return roleScopes[s.Role]
+}
+
+// HasScope checks if the session's role grants the scope
+func (s SessionModel) HasScope(scope string) bool {
+	for _, granted := range s.Scopes() {
+		if granted == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// SessionUser is a user with a session, without any of the session's secrets
+type SessionUser struct {
+	UserId     string    `json:"user_id"`
+	UserName   string    `json:"user_name"`
+	Disabled   bool      `json:"disabled"`
+	DailyQuota *int      `json:"daily_quota"`
+	Role       string    `json:"role"`
+	LoginTime  string    `json:"login_time"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
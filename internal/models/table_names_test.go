@@ -0,0 +1,44 @@
+package models
+
+import (
+	"sync"
+	"testing"
+
+	"gorm.io/gorm/schema"
+)
+
+func TestTableNames(t *testing.T) {
+	tests := []struct {
+		model interface{}
+		want  string
+	}{
+		{&InstrumentModel{}, InstrumentsTableName},
+		{&FreezeLimitModel{}, FreezeLimitsTableName},
+		{&CorporateActionModel{}, CorporateActionsTableName},
+		{&CandleModel{}, CandlesTableName},
+		{&CandleCoverageModel{}, CandleCoverageTableName},
+		{&TickModel{}, TicksTableName},
+		{&WatchlistModel{}, WatchlistsTableName},
+		{&SessionModel{}, SessionsTableName},
+		{&IndexModel{}, IndexTableName},
+		{&InstrumentVersionModel{}, InstrumentHistoryTableName},
+		{&TickerInstrument{}, TickerInstrumentsTableName},
+		{&TickerData{}, TickerDataTableName},
+		{&TickerLog{}, TickerLogTableName},
+		{&QuotaUsageModel{}, QuotaUsageTableName},
+	}
+
+	// gorm resolves the table of a model from its TableName method, the naming strategy is not used
+	cache := &sync.Map{}
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			s, err := schema.Parse(tt.model, cache, schema.NamingStrategy{SingularTable: true})
+			if err != nil {
+				t.Fatalf("schema.Parse() error = %v", err)
+			}
+			if s.Table != tt.want {
+				t.Errorf("table of %s = %q, want %q", s.Name, s.Table, tt.want)
+			}
+		})
+	}
+}
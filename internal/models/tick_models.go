@@ -0,0 +1,60 @@
+// Package models contains the models for the Moneybots API
+package models
+
+import (
+	"time"
+
+	"gorm.io/datatypes"
+)
+
+// TicksTableName is the name of the table of the persisted ticks, partitioned by day of ReceivedAt on Postgres
+const TicksTableName = "ticks"
+
+// TickModel is a tick of an instrument as received from the ticker, kept to replay the intraday data
+// ID increases with each insert, so new ticks are read after the last ID seen
+type TickModel struct {
+	ID                 uint64         `gorm:"primaryKey;autoIncrement" json:"id"`
+	ReceivedAt         time.Time      `gorm:"not null;index:idx_ticks_token_received_at,priority:2" json:"received_at"`
+	InstrumentToken    uint32         `gorm:"not null;index:idx_ticks_token_received_at,priority:1" json:"instrument_token"`
+	Instrument         string         `json:"instrument"`
+	Mode               string         `gorm:"type:varchar(10)" json:"mode"`
+	Timestamp          time.Time      `json:"timestamp"`
+	LastTradeTime      time.Time      `json:"last_trade_time"`
+	LastPrice          float64        `gorm:"type:decimal(10,2)" json:"last_price"`
+	LastTradedQuantity uint32         `gorm:"type:bigint" json:"last_traded_quantity"`
+	TotalBuyQuantity   uint32         `gorm:"type:bigint" json:"total_buy_quantity"`
+	TotalSellQuantity  uint32         `gorm:"type:bigint" json:"total_sell_quantity"`
+	VolumeTraded       uint32         `gorm:"type:bigint;column:volume" json:"volume"`
+	AverageTradePrice  float64        `gorm:"type:decimal(10,2);column:average_price" json:"average_price"`
+	OI                 uint32         `gorm:"type:bigint;column:oi" json:"oi"`
+	NetChange          float64        `gorm:"type:decimal(10,2)" json:"net_change"`
+	OHLC               datatypes.JSON `gorm:"column:ohlc" json:"ohlc"`
+	Depth              datatypes.JSON `json:"depth"`
+}
+
+// TableName specifies the table name for the Tick model
+func (TickModel) TableName() string {
+	return TicksTableName
+}
+
+// NewTickModel creates the persisted tick of the ticker data, received at its UpdatedAt
+func NewTickModel(tick *TickerData) TickModel {
+	return TickModel{
+		ReceivedAt:         tick.UpdatedAt,
+		InstrumentToken:    tick.InstrumentToken,
+		Instrument:         tick.Instrument,
+		Mode:               tick.Mode,
+		Timestamp:          tick.Timestamp,
+		LastTradeTime:      tick.LastTradeTime,
+		LastPrice:          tick.LastPrice,
+		LastTradedQuantity: tick.LastTradedQuantity,
+		TotalBuyQuantity:   tick.TotalBuyQuantity,
+		TotalSellQuantity:  tick.TotalSellQuantity,
+		VolumeTraded:       tick.VolumeTraded,
+		AverageTradePrice:  tick.AverageTradePrice,
+		OI:                 tick.OI,
+		NetChange:          tick.NetChange,
+		OHLC:               tick.OHLC,
+		Depth:              tick.Depth,
+	}
+}
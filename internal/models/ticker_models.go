@@ -7,6 +7,7 @@ import (
 	"errors"
 	"time"
 
+	"github.com/nsvirk/moneybotsapi/pkg/utils/zaplogger"
 	"gorm.io/datatypes"
 )
 
@@ -81,10 +82,35 @@ func (t *TickerData) GetOHLC() (TickerDataOHLC, error) {
 	return ohlc, err
 }
 
+// GetDepth decodes the depth, tolerating any number of levels per side
 func (t *TickerData) GetDepth() (TickerDataDepth, error) {
+	var raw struct {
+		Buy  []TickerDataDepthItem `json:"buy"`
+		Sell []TickerDataDepthItem `json:"sell"`
+	}
+	if err := json.Unmarshal(t.Depth, &raw); err != nil {
+		return TickerDataDepth{}, err
+	}
+	if len(raw.Buy) > depthLevels || len(raw.Sell) > depthLevels {
+		zaplogger.Warn("Depth truncated to top levels", zaplogger.Fields{
+			"instrument":  t.Instrument,
+			"buy_levels":  len(raw.Buy),
+			"sell_levels": len(raw.Sell),
+		})
+	}
+	return toFixedDepth(raw.Buy, raw.Sell), nil
+}
+
+// depthLevels is the number of depth levels per side
+const depthLevels = 5
+
+// toFixedDepth takes the top levels of each side in the feed's ordering,
+// zero padding sides with fewer levels
+func toFixedDepth(buy, sell []TickerDataDepthItem) TickerDataDepth {
 	var depth TickerDataDepth
-	err := json.Unmarshal(t.Depth, &depth)
-	return depth, err
+	copy(depth.Buy[:], buy)
+	copy(depth.Sell[:], sell)
+	return depth
 }
 
 func (TickerData) TableName() string {
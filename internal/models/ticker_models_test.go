@@ -0,0 +1,60 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestTickerDataGetDepth(t *testing.T) {
+	// levels returns n depth levels priced from 101 upwards
+	levels := func(n int) []TickerDataDepthItem {
+		items := make([]TickerDataDepthItem, n)
+		for i := range items {
+			items[i] = TickerDataDepthItem{Price: float64(101 + i), Quantity: uint32(10 * (i + 1)), Orders: uint32(i + 1)}
+		}
+		return items
+	}
+
+	tests := []struct {
+		name       string
+		levels     int
+		wantLevels int
+	}{
+		{name: "no levels", levels: 0, wantLevels: 0},
+		{name: "fewer levels are zero padded", levels: 3, wantLevels: 3},
+		{name: "five levels", levels: 5, wantLevels: 5},
+		{name: "more levels are truncated to the top five", levels: 8, wantLevels: 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			raw, err := json.Marshal(map[string][]TickerDataDepthItem{"buy": levels(tt.levels), "sell": levels(tt.levels)})
+			if err != nil {
+				t.Fatal(err)
+			}
+			tick := TickerData{Instrument: "NSE:INFY", Depth: raw}
+			depth, err := tick.GetDepth()
+			if err != nil {
+				t.Fatalf("GetDepth() error = %v", err)
+			}
+
+			want := levels(tt.wantLevels)
+			for i := 0; i < depthLevels; i++ {
+				var wantItem TickerDataDepthItem
+				if i < len(want) {
+					wantItem = want[i]
+				}
+				if depth.Buy[i] != wantItem || depth.Sell[i] != wantItem {
+					t.Errorf("GetDepth() level %d = %+v, %+v, want %+v", i, depth.Buy[i], depth.Sell[i], wantItem)
+				}
+			}
+		})
+	}
+
+	t.Run("invalid depth", func(t *testing.T) {
+		tick := TickerData{Depth: []byte(`{"buy":`)}
+		if _, err := tick.GetDepth(); err == nil {
+			t.Error("GetDepth() error = nil, want an error")
+		}
+	})
+}
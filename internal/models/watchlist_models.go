@@ -0,0 +1,28 @@
+// Package models contains the models for the Moneybots API
+package models
+
+import "time"
+
+// WatchlistsTableName is the name of the table for watchlists
+const WatchlistsTableName = "watchlists"
+
+// WatchlistModel is a named list of instruments of a user
+type WatchlistModel struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	UserId      string    `gorm:"index" json:"-"`
+	Name        string    `json:"name"`
+	Instruments []string  `gorm:"serializer:json" json:"instruments"`
+	CreatedAt   time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt   time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// TableName specifies the table name for the Watchlist model
+func (WatchlistModel) TableName() string {
+	return WatchlistsTableName
+}
+
+// WatchlistRequest is the request for creating or updating a watchlist
+type WatchlistRequest struct {
+	Name        string   `json:"name"`
+	Instruments []string `json:"instruments"`
+}
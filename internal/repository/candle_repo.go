@@ -0,0 +1,146 @@
+// Package repository contains the repository layer for the Moneybots API
+package repository
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/nsvirk/moneybotsapi/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// CandleRepository is the database repository for candles
+type CandleRepository struct {
+	DB *gorm.DB
+}
+
+// NewCandleRepository creates a new candle repository
+func NewCandleRepository(db *gorm.DB) *CandleRepository {
+	return &CandleRepository{DB: db}
+}
+
+// UpsertCandles upserts the candles, replacing existing candles of the same token, interval and timestamp
+func (r *CandleRepository) UpsertCandles(candles []models.CandleModel) (int64, error) {
+	if len(candles) == 0 {
+		return 0, nil
+	}
+	result := r.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "instrument_token"}, {Name: "interval"}, {Name: "timestamp"}},
+		DoUpdates: clause.AssignmentColumns([]string{"instrument", "open", "high", "low", "close", "volume", "oi", "updated_at"}),
+	}).CreateInBatches(candles, 500)
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to upsert candles into %s: %v", models.CandlesTableName, result.Error)
+	}
+	return result.RowsAffected, nil
+}
+
+// GetPrevDayOI returns the OI of the latest day candle before the given time for each token
+// Tokens without a previous day candle, such as a contract's first day, are absent
+func (r *CandleRepository) GetPrevDayOI(tokens []uint32, before time.Time) (map[uint32]uint64, error) {
+	var candles []models.CandleModel
+	err := r.DB.Raw(`SELECT c.instrument_token, c.oi FROM `+models.CandlesTableName+` c
+		WHERE c.interval = ? AND c.instrument_token IN ? AND c.timestamp = (
+			SELECT MAX(p.timestamp) FROM `+models.CandlesTableName+` p
+			WHERE p.instrument_token = c.instrument_token AND p.interval = c.interval AND p.timestamp < ?
+		)`, models.CandleIntervalDay, tokens, before).Scan(&candles).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get previous day oi from %s: %v", models.CandlesTableName, err)
+	}
+
+	prevOI := make(map[uint32]uint64, len(candles))
+	for _, candle := range candles {
+		prevOI[candle.InstrumentToken] = candle.OI
+	}
+	return prevOI, nil
+}
+
+// GetCandles returns the candles of the token for the interval from from to to, both inclusive, sorted by timestamp
+func (r *CandleRepository) GetCandles(token uint32, interval string, from, to time.Time) ([]models.CandleModel, error) {
+	var candles []models.CandleModel
+	err := r.DB.Where("instrument_token = ? AND interval = ? AND timestamp >= ? AND timestamp <= ?", token, interval, from, to).
+		Order("timestamp").
+		Find(&candles).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get candles from %s: %v", models.CandlesTableName, err)
+	}
+	return candles, nil
+}
+
+// GetCoveredDays returns the days from fromDay to toDay, both `YYYY-MM-DD`, whose candles of the interval
+// were fetched in full for the token
+func (r *CandleRepository) GetCoveredDays(token uint32, interval, fromDay, toDay string) (map[string]bool, error) {
+	var days []string
+	err := r.DB.Model(&models.CandleCoverageModel{}).
+		Where("instrument_token = ? AND interval = ? AND day >= ? AND day <= ?", token, interval, fromDay, toDay).
+		Pluck("day", &days).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get covered days from %s: %v", models.CandleCoverageTableName, err)
+	}
+	covered := make(map[string]bool, len(days))
+	for _, day := range days {
+		covered[day] = true
+	}
+	return covered, nil
+}
+
+// UpsertFetchedCandles upserts the candles fetched for the token and interval, and records the covered days
+// as fetched in full, in a single transaction
+func (r *CandleRepository) UpsertFetchedCandles(candles []models.CandleModel, token uint32, interval string, coveredDays []string) (int64, error) {
+	var count int64
+	err := r.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if count, err = NewCandleRepository(tx).UpsertCandles(candles); err != nil {
+			return err
+		}
+		if len(coveredDays) == 0 {
+			return nil
+		}
+		coverage := make([]models.CandleCoverageModel, len(coveredDays))
+		for i, day := range coveredDays {
+			coverage[i] = models.CandleCoverageModel{InstrumentToken: token, Interval: interval, Day: day}
+		}
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).CreateInBatches(coverage, 500).Error; err != nil {
+			return fmt.Errorf("failed to insert into %s: %v", models.CandleCoverageTableName, err)
+		}
+		return nil
+	})
+	return count, err
+}
+
+// GetTokensCandles returns the candles of the tokens for the interval from from to to, both inclusive
+func (r *CandleRepository) GetTokensCandles(tokens []uint32, interval string, from, to time.Time) ([]models.CandleModel, error) {
+	var candles []models.CandleModel
+	err := r.DB.Where("instrument_token IN ? AND interval = ? AND timestamp >= ? AND timestamp <= ?", tokens, interval, from, to).
+		Find(&candles).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get candles from %s: %v", models.CandlesTableName, err)
+	}
+	return candles, nil
+}
+
+// GetInstrumentsCandles returns the candles of the `EXCHANGE:TRADINGSYMBOL` instruments for the interval
+// from from to to, both inclusive, sorted by instrument and timestamp
+func (r *CandleRepository) GetInstrumentsCandles(instruments []string, interval string, from, to time.Time) ([]models.CandleModel, error) {
+	var candles []models.CandleModel
+	err := r.DB.Where("instrument IN ? AND interval = ? AND timestamp >= ? AND timestamp <= ?", instruments, interval, from, to).
+		Order("instrument, timestamp").
+		Find(&candles).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get candles from %s: %v", models.CandlesTableName, err)
+	}
+	return candles, nil
+}
+
+// GetCandleTokens returns the tokens with candles of the interval from from to to, both inclusive
+func (r *CandleRepository) GetCandleTokens(interval string, from, to time.Time) ([]uint32, error) {
+	var tokens []uint32
+	err := r.DB.Model(&models.CandleModel{}).
+		Where("interval = ? AND timestamp >= ? AND timestamp <= ?", interval, from, to).
+		Distinct().
+		Pluck("instrument_token", &tokens).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get candle tokens from %s: %v", models.CandlesTableName, err)
+	}
+	return tokens, nil
+}
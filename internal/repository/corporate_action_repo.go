@@ -0,0 +1,46 @@
+// Package repository contains the repository layer for the Moneybots API
+package repository
+
+import (
+	"fmt"
+
+	"github.com/nsvirk/moneybotsapi/internal/models"
+	"gorm.io/gorm"
+)
+
+// CorporateActionRepository is the database repository for corporate actions
+type CorporateActionRepository struct {
+	DB *gorm.DB
+}
+
+// NewCorporateActionRepository creates a new corporate action repository
+func NewCorporateActionRepository(db *gorm.DB) *CorporateActionRepository {
+	return &CorporateActionRepository{DB: db}
+}
+
+// ReplaceCorporateActions replaces all corporate actions in a single transaction
+func (r *CorporateActionRepository) ReplaceCorporateActions(actions []models.CorporateActionModel) (int64, error) {
+	err := r.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("1 = 1").Delete(&models.CorporateActionModel{}).Error; err != nil {
+			return fmt.Errorf("failed to delete corporate actions: %v", err)
+		}
+		if len(actions) == 0 {
+			return nil
+		}
+		return tx.CreateInBatches(actions, 500).Error
+	})
+	if err != nil {
+		return 0, err
+	}
+	return int64(len(actions)), nil
+}
+
+// GetCorporateActions returns the corporate actions of the tokens sorted by ex date
+func (r *CorporateActionRepository) GetCorporateActions(tokens []uint32) ([]models.CorporateActionModel, error) {
+	var actions []models.CorporateActionModel
+	err := r.DB.Where("instrument_token IN ?", tokens).Order("ex_date, type").Find(&actions).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get corporate actions from %s: %v", models.CorporateActionsTableName, err)
+	}
+	return actions, nil
+}
@@ -0,0 +1,48 @@
+// Package repository contains the repository layer for the Moneybots API
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/nsvirk/moneybotsapi/pkg/utils/zaplogger"
+	"gorm.io/gorm"
+)
+
+// StartDBHealthCheck runs `SELECT 1` on the database every interval until ctx is cancelled
+// Failures are logged at error level, and the recovery at info level
+func StartDBHealthCheck(ctx context.Context, db *gorm.DB, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		healthy := true
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				err := PingDB(ctx, db, interval)
+				switch {
+				case err != nil:
+					zaplogger.Error("Postgres health check failed", zaplogger.Fields{"error": err.Error()})
+					healthy = false
+				case !healthy:
+					zaplogger.Info("Postgres health check recovered")
+					healthy = true
+				}
+			}
+		}
+	}()
+}
+
+// PingDB runs a lightweight query on the database, bounded by the timeout
+func PingDB(ctx context.Context, db *gorm.DB, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	return db.WithContext(ctx).Exec("SELECT 1").Error
+}
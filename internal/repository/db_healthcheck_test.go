@@ -0,0 +1,79 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"github.com/nsvirk/moneybotsapi/pkg/utils/zaplogger"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// waitFor polls cond until it holds or the timeout passes
+func waitFor(timeout time.Duration, cond func() bool) bool {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if cond() {
+			return true
+		}
+		time.Sleep(time.Millisecond)
+	}
+	return cond()
+}
+
+func TestStartDBHealthCheck(t *testing.T) {
+	db := memoryDB(t)
+	ctx, cancel := context.WithCancel(WithQueryCounter(context.Background()))
+	defer cancel()
+
+	// the pings run with the context of the check, so they are counted
+	StartDBHealthCheck(ctx, db, 5*time.Millisecond)
+	if !waitFor(time.Second, func() bool { return QueryCount(ctx) >= 3 }) {
+		t.Fatalf("QueryCount() = %d after 1s, want at least 3 pings", QueryCount(ctx))
+	}
+
+	cancel()
+	time.Sleep(20 * time.Millisecond)
+	stopped := QueryCount(ctx)
+	time.Sleep(20 * time.Millisecond)
+	if got := QueryCount(ctx); got != stopped {
+		t.Errorf("QueryCount() = %d after the cancel, want %d", got, stopped)
+	}
+}
+
+func TestStartDBHealthCheckDisabled(t *testing.T) {
+	db := memoryDB(t)
+	ctx, cancel := context.WithCancel(WithQueryCounter(context.Background()))
+	defer cancel()
+
+	StartDBHealthCheck(ctx, db, 0)
+	time.Sleep(20 * time.Millisecond)
+	if got := QueryCount(ctx); got != 0 {
+		t.Errorf("QueryCount() = %d with no interval, want 0", got)
+	}
+}
+
+func TestStartDBHealthCheckFailure(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("gorm.Open() error = %v", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("DB() error = %v", err)
+	}
+	sqlDB.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	logged := zaplogger.ErrorsLogged()
+	StartDBHealthCheck(ctx, db, 5*time.Millisecond)
+	if !waitFor(time.Second, func() bool { return zaplogger.ErrorsLogged() > logged }) {
+		t.Fatalf("no error logged for the closed database")
+	}
+	if got := zaplogger.RecentErrors()[0].Message; got != "Postgres health check failed" {
+		t.Errorf("logged error = %q, want %q", got, "Postgres health check failed")
+	}
+}
@@ -0,0 +1,38 @@
+// Package repository contains the repository layer for the Moneybots API
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/nsvirk/moneybotsapi/internal/metrics"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// DBLogger is the gorm logger, it records the duration of every query for the metrics
+// and passes the queries on to the wrapped logger, which logs them by its level
+type DBLogger struct {
+	logger.Interface
+}
+
+// NewDBLogger creates a DBLogger wrapping the logger
+func NewDBLogger(l logger.Interface) *DBLogger {
+	return &DBLogger{Interface: l}
+}
+
+// LogMode returns a DBLogger with the log level of the wrapped logger set
+func (l *DBLogger) LogMode(level logger.LogLevel) logger.Interface {
+	return &DBLogger{Interface: l.Interface.LogMode(level)}
+}
+
+// Trace records the duration of the query, a record not found is not a failed query
+func (l *DBLogger) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	result := "ok"
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		result = "error"
+	}
+	metrics.RecordDBQuery(result, time.Since(begin))
+	l.Interface.Trace(ctx, begin, fc, err)
+}
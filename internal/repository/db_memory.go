@@ -0,0 +1,126 @@
+// Package repository contains the repository layer for the Moneybots API
+package repository
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"github.com/nsvirk/moneybotsapi/internal/config"
+	"github.com/nsvirk/moneybotsapi/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// Memory storage dev session, seeded so the protected endpoints can be used without Kite
+const (
+	MemoryDevUserID   = "DEV001"
+	MemoryDevEnctoken = "memory"
+)
+
+// ConnectMemory opens an in-memory database with the same tables as Postgres,
+// seeded with a dev session and mock quotes for the feed sample instruments
+// Postgres only features, like the notify listener and unlogged tables, are not available
+func ConnectMemory(cfg *config.Config) (*gorm.DB, error) {
+	db, err := gorm.Open(sqlite.Open("file::memory:?cache=shared"), &gorm.Config{
+		Logger: NewDBLogger(logger.Default.LogMode(logger.Silent)),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open in-memory database: %v", err)
+	}
+
+	// a single connection keeps every query on the same in-memory database
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+	sqlDB.SetMaxOpenConns(1)
+
+	if err := registerQueryCounter(db); err != nil {
+		return nil, fmt.Errorf("failed to register query counter: %v", err)
+	}
+
+	if err := db.AutoMigrate(
+		&models.SessionModel{},
+		&models.InstrumentModel{},
+		&models.IndexModel{},
+		&models.TickerInstrument{},
+		&models.TickerLog{},
+		&models.TickerData{},
+		&models.CandleModel{},
+		&models.CandleCoverageModel{},
+		&models.TickModel{},
+		&models.FreezeLimitModel{},
+		&models.WatchlistModel{},
+		&models.CorporateActionModel{},
+		&models.InstrumentVersionModel{},
+		&models.QuotaUsageModel{},
+	); err != nil {
+		return nil, fmt.Errorf("failed to auto migrate: %v", err)
+	}
+
+	if err := seedMemory(db, cfg); err != nil {
+		return nil, fmt.Errorf("failed to seed in-memory database: %v", err)
+	}
+	return db, nil
+}
+
+// seedMemory seeds the dev session, and instruments with mock quotes for the feed sample instruments
+func seedMemory(db *gorm.DB, cfg *config.Config) error {
+	session := models.SessionModel{
+		UserId:    MemoryDevUserID,
+		UserName:  "Memory Dev",
+		Enctoken:  MemoryDevEnctoken,
+		LoginTime: time.Now().Format("2006-01-02 15:04:05"),
+	}
+	if err := db.Create(&session).Error; err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for i, instrument := range strings.Split(cfg.FeedSampleInstr, ",") {
+		exchange, tradingsymbol, ok := strings.Cut(strings.TrimSpace(instrument), ":")
+		if !ok {
+			continue
+		}
+		token := uint32(100001 + i)
+		price := float64(1000 * (i + 1))
+
+		instrumentModel := models.InstrumentModel{
+			InstrumentToken: token,
+			Tradingsymbol:   tradingsymbol,
+			Name:            tradingsymbol,
+			TickSize:        0.05,
+			LotSize:         1,
+			InstrumentType:  "EQ",
+			Segment:         exchange,
+			Exchange:        exchange,
+		}
+		if err := db.Create(&instrumentModel).Error; err != nil {
+			return err
+		}
+		version := models.NewInstrumentVersion(instrumentModel, now.Format("2006-01-02"))
+		if err := db.Create(&version).Error; err != nil {
+			return err
+		}
+
+		ohlc, _ := json.Marshal(models.TickerDataOHLC{Open: price, High: price * 1.01, Low: price * 0.99, Close: price})
+		depth, _ := json.Marshal(models.TickerDataDepth{})
+		if err := db.Create(&models.TickerData{
+			Instrument:      exchange + ":" + tradingsymbol,
+			InstrumentToken: token,
+			Mode:            "full",
+			IsTradable:      true,
+			Timestamp:       now,
+			LastTradeTime:   now,
+			LastPrice:       price,
+			OHLC:            ohlc,
+			Depth:           depth,
+		}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,28 @@
+package repository
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/nsvirk/moneybotsapi/internal/config"
+	"gorm.io/gorm"
+)
+
+var (
+	memoryOnce sync.Once
+	memoryConn *gorm.DB
+	memoryErr  error
+)
+
+// memoryDB returns the in-memory database of the tests, it is shared as the in-memory database is
+// opened with a shared cache, and seeded once
+func memoryDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	memoryOnce.Do(func() {
+		memoryConn, memoryErr = ConnectMemory(&config.Config{FeedSampleInstr: "NSE:INFY,NSE:TCS"})
+	})
+	if memoryErr != nil {
+		t.Fatalf("ConnectMemory() error = %v", memoryErr)
+	}
+	return memoryConn
+}
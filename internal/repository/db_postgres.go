@@ -2,6 +2,7 @@
 package repository
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/nsvirk/moneybotsapi/internal/config"
@@ -29,22 +30,31 @@ func ConnectPostgres(cfg *config.Config) (*gorm.DB, error) {
 	}
 
 	gormConfig := &gorm.Config{
-		Logger: logger.Default.LogMode(logLevel),
+		Logger: NewDBLogger(logger.Default.LogMode(logLevel)),
 	}
 
 	// Open database connection
-	postgresDSN := cfg.PostgresDsn + " search_path=api,public"
-	db, err := gorm.Open(postgres.Open(postgresDSN), gormConfig)
+	db, err := gorm.Open(postgres.Open(PostgresDSN(cfg)), gormConfig)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to Postgres: %v", err)
 	}
 
+	// Count queries per request context
+	if err := registerQueryCounter(db); err != nil {
+		return nil, fmt.Errorf("failed to register query counter: %v", err)
+	}
+
 	// Create the schema if it doesn't exist
 	createSchemaSql := fmt.Sprintf("CREATE SCHEMA IF NOT EXISTS %s", cfg.PostgresSchema)
 	if err := db.Exec(createSchemaSql).Error; err != nil {
 		panic("failed to create schema: " + err.Error())
 	}
 
+	// Unqualified queries must resolve to the configured schema
+	if err := verifySearchPath(db, cfg); err != nil {
+		return nil, err
+	}
+
 	// AutoMigrate will create tables and add/modify columns
 	if err := autoMigrate(db, cfg); err != nil {
 		return nil, fmt.Errorf("failed to auto migrate: %v", err)
@@ -55,9 +65,21 @@ func ConnectPostgres(cfg *config.Config) (*gorm.DB, error) {
 	if err != nil {
 		return nil, err
 	}
+
+	// The ticks table is partitioned, which AutoMigrate cannot create
+	if err := createTicksTable(db, cfg); err != nil {
+		return nil, err
+	}
 	return db, nil
 }
 
+// PostgresDSN returns the DSN of the Postgres connections, the gorm pool and the tick listener alike
+// The search_path is a runtime parameter of the DSN, so every pooled connection is opened with it
+// and not only the first one
+func PostgresDSN(cfg *config.Config) string {
+	return fmt.Sprintf("%s search_path=%s,public", cfg.PostgresDsn, cfg.PostgresSchema)
+}
+
 func autoMigrate(db *gorm.DB, cfg *config.Config) error {
 	tables := []struct {
 		name  string
@@ -69,6 +91,13 @@ func autoMigrate(db *gorm.DB, cfg *config.Config) error {
 		{models.TickerInstrumentsTableName, &models.TickerInstrument{}},
 		{models.TickerLogTableName, &models.TickerLog{}},
 		{models.TickerDataTableName, &models.TickerData{}},
+		{models.CandlesTableName, &models.CandleModel{}},
+		{models.CandleCoverageTableName, &models.CandleCoverageModel{}},
+		{models.FreezeLimitsTableName, &models.FreezeLimitModel{}},
+		{models.WatchlistsTableName, &models.WatchlistModel{}},
+		{models.CorporateActionsTableName, &models.CorporateActionModel{}},
+		{models.InstrumentHistoryTableName, &models.InstrumentVersionModel{}},
+		{models.QuotaUsageTableName, &models.QuotaUsageModel{}},
 	}
 
 	for _, table := range tables {
@@ -80,6 +109,35 @@ func autoMigrate(db *gorm.DB, cfg *config.Config) error {
 	return nil
 }
 
+// searchPathConns is the number of pooled connections checked by verifySearchPath
+const searchPathConns = 3
+
+// verifySearchPath checks the connections resolve unqualified tables to the configured schema
+// The connections are held until all are checked, so each check runs on a new connection of the pool
+func verifySearchPath(db *gorm.DB, cfg *config.Config) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	for i := 0; i < searchPathConns; i++ {
+		conn, err := sqlDB.Conn(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to get a connection: %v", err)
+		}
+		defer conn.Close()
+
+		var currentSchema string
+		if err := conn.QueryRowContext(ctx, "SELECT current_schema()").Scan(&currentSchema); err != nil {
+			return fmt.Errorf("failed to get current schema: %v", err)
+		}
+		if currentSchema != cfg.PostgresSchema {
+			return fmt.Errorf("connection search_path resolves to schema %q, expected %q", currentSchema, cfg.PostgresSchema)
+		}
+	}
+	return nil
+}
+
 func setTickerDataTableAsUnlogged(db *gorm.DB, cfg *config.Config) error {
 	// Set the table as unlogged
 	table := models.TickerDataTableName
@@ -88,3 +146,38 @@ func setTickerDataTableAsUnlogged(db *gorm.DB, cfg *config.Config) error {
 	}
 	return nil
 }
+
+// createTicksTable creates the ticks table partitioned by day of received_at, see models.TickModel
+// The partitions of the days are created by the TickRepository as ticks are inserted
+func createTicksTable(db *gorm.DB, cfg *config.Config) error {
+	table := cfg.PostgresSchema + "." + models.TicksTableName
+	statements := []string{
+		`CREATE TABLE IF NOT EXISTS ` + table + ` (
+			id bigserial NOT NULL,
+			received_at timestamptz NOT NULL,
+			instrument_token bigint NOT NULL,
+			instrument text,
+			mode varchar(10),
+			timestamp timestamptz,
+			last_trade_time timestamptz,
+			last_price decimal(10,2),
+			last_traded_quantity bigint,
+			total_buy_quantity bigint,
+			total_sell_quantity bigint,
+			volume bigint,
+			average_price decimal(10,2),
+			oi bigint,
+			net_change decimal(10,2),
+			ohlc jsonb,
+			depth jsonb
+		) PARTITION BY RANGE (received_at)`,
+		`CREATE INDEX IF NOT EXISTS idx_ticks_token_received_at ON ` + table + ` (instrument_token, received_at)`,
+		`CREATE INDEX IF NOT EXISTS idx_ticks_id ON ` + table + ` (id)`,
+	}
+	for _, statement := range statements {
+		if err := db.Exec(statement).Error; err != nil {
+			return fmt.Errorf("failed to create table %s: %v", models.TicksTableName, err)
+		}
+	}
+	return nil
+}
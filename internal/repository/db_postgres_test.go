@@ -0,0 +1,108 @@
+package repository
+
+import (
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+
+	sqlite3 "github.com/glebarez/go-sqlite"
+	"github.com/glebarez/sqlite"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/nsvirk/moneybotsapi/internal/config"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// currentSchemas are the schemas returned by the sqlite current_schema() of the tests, one per call
+var currentSchemas struct {
+	sync.Mutex
+	schemas []string
+}
+
+func init() {
+	sqlite3.MustRegisterScalarFunction("current_schema", 0, func(*sqlite3.FunctionContext, []driver.Value) (driver.Value, error) {
+		currentSchemas.Lock()
+		defer currentSchemas.Unlock()
+		if len(currentSchemas.schemas) == 0 {
+			return nil, errors.New("no current schema")
+		}
+		schema := currentSchemas.schemas[0]
+		currentSchemas.schemas = currentSchemas.schemas[1:]
+		return schema, nil
+	})
+}
+
+func TestVerifySearchPath(t *testing.T) {
+	tests := []struct {
+		name    string
+		schemas []string
+		wantErr string
+	}{
+		{name: "every connection on the schema", schemas: []string{"api", "api", "api"}},
+		{name: "first connection on public", schemas: []string{"public", "api", "api"}, wantErr: `resolves to schema "public"`},
+		{name: "new connection on public", schemas: []string{"api", "api", "public"}, wantErr: `resolves to schema "public"`},
+		{name: "schema query failed", schemas: []string{"api"}, wantErr: "failed to get current schema"},
+	}
+
+	cfg := &config.Config{PostgresSchema: "api"}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			name := strings.NewReplacer("/", "_", " ", "_").Replace(t.Name())
+			db, err := gorm.Open(sqlite.Open("file:"+name+"?mode=memory&cache=shared"), &gorm.Config{Logger: logger.Discard})
+			if err != nil {
+				t.Fatalf("gorm.Open() error = %v", err)
+			}
+			currentSchemas.Lock()
+			currentSchemas.schemas = tt.schemas
+			currentSchemas.Unlock()
+
+			err = verifySearchPath(db, cfg)
+			if tt.wantErr == "" && err != nil {
+				t.Errorf("verifySearchPath() error = %v, want nil", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("verifySearchPath() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPostgresDSN(t *testing.T) {
+	tests := []struct {
+		name    string
+		sslMode string
+		wantTLS bool
+	}{
+		{name: "ssl required", sslMode: "require", wantTLS: true},
+		{name: "ssl disabled", sslMode: "disable"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{
+				PostgresDsn:    "host=db.example.com user=api password=secret dbname=moneybots port=5432 sslmode=" + tt.sslMode,
+				PostgresSchema: "api",
+			}
+			dsn := PostgresDSN(cfg)
+			// parsed as the gorm pool parses it, so every pooled connection is opened with these settings
+			pgConfig, err := pgconn.ParseConfig(dsn)
+			if err != nil {
+				t.Fatalf("ParseConfig(%q) error = %v", dsn, err)
+			}
+			if got := pgConfig.RuntimeParams["search_path"]; got != "api,public" {
+				t.Errorf("PostgresDSN() search_path = %q, want %q", got, "api,public")
+			}
+			if !strings.Contains(dsn, "sslmode="+tt.sslMode) {
+				t.Errorf("PostgresDSN() = %q, want sslmode=%s", dsn, tt.sslMode)
+			}
+			if got := pgConfig.TLSConfig != nil; got != tt.wantTLS {
+				t.Errorf("PostgresDSN() TLS = %v, want %v", got, tt.wantTLS)
+			}
+			if pgConfig.Host != "db.example.com" || pgConfig.Database != "moneybots" || pgConfig.User != "api" {
+				t.Errorf("PostgresDSN() host, database, user = %s, %s, %s, want the ones of the DSN", pgConfig.Host, pgConfig.Database, pgConfig.User)
+			}
+		})
+	}
+}
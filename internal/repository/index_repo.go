@@ -8,7 +8,7 @@ import (
 	"gorm.io/gorm"
 )
 
-// Repository is the database repository for indices
+// IndexRepository is the database repository for indices
 type IndexRepository struct {
 	DB *gorm.DB
 }
@@ -18,25 +18,30 @@ func NewIndexRepository(db *gorm.DB) *IndexRepository {
 	return &IndexRepository{DB: db}
 }
 
-// TruncateIndicesTable truncates the indices table
-func (r *IndexRepository) TruncateIndicesTable() error {
-	return r.DB.Exec(fmt.Sprintf("TRUNCATE TABLE %s RESTART IDENTITY CASCADE", models.IndexTableName)).Error
-}
-
-// InsertIndices inserts a batch of indices into the database
-func (r *IndexRepository) InsertIndices(indexInstruments []models.IndexModel) (int64, error) {
-	// insert the records into the database
-	result := r.DB.Create(indexInstruments)
-	if result.Error != nil {
-		return 0, fmt.Errorf("failed to insert batch into %s: %v", models.IndexTableName, result.Error)
-	}
-	return result.RowsAffected, nil
+// ReplaceIndices replaces the indices table with the indices in a single transaction
+func (r *IndexRepository) ReplaceIndices(indices []models.IndexModel) (int64, error) {
+	var count int64
+	err := r.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Session(&gorm.Session{AllowGlobalUpdate: true}).Delete(&models.IndexModel{}).Error; err != nil {
+			return fmt.Errorf("failed to delete from %s: %v", models.IndexTableName, err)
+		}
+		if len(indices) == 0 {
+			return nil
+		}
+		result := tx.CreateInBatches(indices, 1000)
+		if result.Error != nil {
+			return fmt.Errorf("failed to insert into %s: %v", models.IndexTableName, result.Error)
+		}
+		count = result.RowsAffected
+		return nil
+	})
+	return count, err
 }
 
 // GetIndicesRecordCount returns the number of records in the indices table
 func (r *IndexRepository) GetIndicesRecordCount() (int64, error) {
 	var count int64
-	err := r.DB.Table(models.IndexTableName).Count(&count).Error
+	err := r.DB.Model(&models.IndexModel{}).Count(&count).Error
 	if err != nil {
 		return 0, fmt.Errorf("failed to get indices record count: %v", err)
 	}
@@ -46,7 +51,7 @@ func (r *IndexRepository) GetIndicesRecordCount() (int64, error) {
 // GetAllIndices gets all indices
 func (r *IndexRepository) GetAllIndices() ([]models.IndexModel, error) {
 	var indices []models.IndexModel
-	err := r.DB.Table(models.IndexTableName).
+	err := r.DB.Model(&models.IndexModel{}).
 		Find(&indices).Error
 	if err != nil {
 		return nil, fmt.Errorf("failed to get all indices: %v", err)
@@ -57,7 +62,7 @@ func (r *IndexRepository) GetAllIndices() ([]models.IndexModel, error) {
 // GetIndicesByExchange gets the names of all indices for a given exchange
 func (r *IndexRepository) GetIndicesByExchange(exchange string) ([]models.IndexModel, error) {
 	var indices []models.IndexModel
-	err := r.DB.Table(models.IndexTableName).
+	err := r.DB.Model(&models.IndexModel{}).
 		Where("exchange = ?", exchange).
 		Find(&indices).Error
 	if err != nil {
@@ -69,7 +74,8 @@ func (r *IndexRepository) GetIndicesByExchange(exchange string) ([]models.IndexM
 // GetIndexInstruments fetches the instruments for a given index
 func (r *IndexRepository) GetIndexInstruments(exchange, index string) ([]models.IndexModel, error) {
 	var indexInstruments []models.IndexModel
-	err := r.DB.Where("index = ?", index).
+	err := r.DB.Model(&models.IndexModel{}).
+		Where(`"index" = ?`, index).
 		Where("exchange = ?", exchange).
 		Find(&indexInstruments).Error
 	if err != nil {
@@ -83,7 +89,7 @@ func (r *IndexRepository) GetIndexInstruments(exchange, index string) ([]models.
 // Used by cron
 func (r *IndexRepository) GetAllDistinctIndexSymbol() ([]models.IndexModel, error) {
 	var indices []models.IndexModel
-	err := r.DB.Table(models.IndexTableName).
+	err := r.DB.Model(&models.IndexModel{}).
 		Select("DISTINCT exchange, tradingsymbol").
 		Find(&indices).Error
 	if err != nil {
@@ -91,3 +97,67 @@ func (r *IndexRepository) GetAllDistinctIndexSymbol() ([]models.IndexModel, erro
 	}
 	return indices, nil
 }
+
+// GetIndexWeights gets the stored constituent weights, keyed by `EXCHANGE:INDEX:TRADINGSYMBOL`
+func (r *IndexRepository) GetIndexWeights() (map[string]float64, error) {
+	var indices []models.IndexModel
+	err := r.DB.Model(&models.IndexModel{}).
+		Select(`exchange, "index", tradingsymbol, weight`).
+		Where("weight > 0").
+		Find(&indices).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get index weights: %v", err)
+	}
+	weights := make(map[string]float64, len(indices))
+	for _, index := range indices {
+		weights[index.Exchange+":"+index.Index+":"+index.Tradingsymbol] = index.Weight
+	}
+	return weights, nil
+}
+
+// IndexSummary is an index and its number of constituents
+type IndexSummary struct {
+	Exchange     string `json:"exchange"`
+	Index        string `json:"index"`
+	Constituents int64  `json:"constituents"`
+}
+
+// GetIndexSummaries gets the indices with their number of constituents, sorted by exchange and index
+func (r *IndexRepository) GetIndexSummaries() ([]IndexSummary, error) {
+	summaries := []IndexSummary{}
+	err := r.DB.Model(&models.IndexModel{}).
+		Select(`exchange, "index", COUNT(*) AS constituents`).
+		Group(`exchange, "index"`).
+		Order(`exchange, "index"`).
+		Scan(&summaries).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get index summaries: %v", err)
+	}
+	return summaries, nil
+}
+
+// GetIndexConstituents gets the constituents of the index on any exchange, by weight and tradingsymbol
+func (r *IndexRepository) GetIndexConstituents(index string) ([]models.IndexModel, error) {
+	var constituents []models.IndexModel
+	err := r.DB.Model(&models.IndexModel{}).
+		Where(`"index" = ?`, index).
+		Order("weight DESC, tradingsymbol").
+		Find(&constituents).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get `%s` constituents: %v", index, err)
+	}
+	return constituents, nil
+}
+
+// GetInstrumentIndices gets the index memberships of the instrument, sorted by index
+func (r *IndexRepository) GetInstrumentIndices(exchange, tradingsymbol string) ([]models.IndexModel, error) {
+	var memberships []models.IndexModel
+	err := r.DB.Model(&models.IndexModel{}).
+		Where("exchange = ? AND tradingsymbol = ?", exchange, tradingsymbol).
+		Order(`"index"`).
+		Find(&memberships).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get `%s:%s` indices: %v", exchange, tradingsymbol, err)
+	}
+	return memberships, nil
+}
@@ -0,0 +1,136 @@
+// Package repository contains the repository layer for the Moneybots API
+package repository
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/nsvirk/moneybotsapi/internal/models"
+	"gorm.io/gorm"
+)
+
+// instrumentFromRecord converts a validated row of the instruments CSV to an instrument
+func instrumentFromRecord(record []string) models.InstrumentModel {
+	instrumentToken, _ := strconv.ParseUint(record[0], 10, 32)
+	exchangeToken, _ := strconv.ParseUint(record[1], 10, 32)
+	lastPrice, _ := strconv.ParseFloat(record[4], 64)
+	strike, _ := strconv.ParseFloat(record[6], 64)
+	tickSize, _ := strconv.ParseFloat(record[7], 64)
+	lotSize, _ := strconv.ParseUint(record[8], 10, 32)
+
+	return models.InstrumentModel{
+		InstrumentToken: uint32(instrumentToken),
+		ExchangeToken:   uint32(exchangeToken),
+		Tradingsymbol:   record[2],
+		Name:            record[3],
+		LastPrice:       lastPrice,
+		Expiry:          record[5],
+		Strike:          strike,
+		TickSize:        tickSize,
+		LotSize:         uint(lotSize),
+		InstrumentType:  record[9],
+		Segment:         record[10],
+		Exchange:        record[11],
+	}
+}
+
+// RecordInstrumentVersions diffs the synced rows of the instruments CSV against the current versions of the
+// instrument master and records the changes as of the sync date day, in a single transaction
+// An empty exchange diffs the whole master, otherwise only the instruments of the exchange
+// It returns the number of versions added and closed
+func (r *InstrumentRepository) RecordInstrumentVersions(exchange string, records [][]string, day string) (int64, int64, error) {
+	var added, closed int64
+	err := r.DB.Transaction(func(tx *gorm.DB) error {
+		query := tx.Where("valid_to IS NULL")
+		if exchange != "" {
+			query = query.Where("exchange = ?", exchange)
+		}
+		var current []models.InstrumentVersionModel
+		if err := query.Find(&current).Error; err != nil {
+			return fmt.Errorf("failed to get current instrument versions: %v", err)
+		}
+		open := make(map[uint32]models.InstrumentVersionModel, len(current))
+		for _, version := range current {
+			open[version.InstrumentToken] = version
+		}
+
+		var versions []models.InstrumentVersionModel
+		var stale []models.InstrumentVersionModel
+		for _, record := range records {
+			instrument := instrumentFromRecord(record)
+			version, ok := open[instrument.InstrumentToken]
+			delete(open, instrument.InstrumentToken)
+			if ok && version.SameAs(instrument) {
+				continue
+			}
+			if ok {
+				stale = append(stale, version)
+			}
+			versions = append(versions, models.NewInstrumentVersion(instrument, day))
+		}
+		// instruments missing from the sync are delisted or expired
+		for _, version := range open {
+			stale = append(stale, version)
+		}
+
+		// a version opened by an earlier sync of the same day never was valid on its own, so it is dropped
+		var dropIDs, closeIDs []uint
+		for _, version := range stale {
+			if version.ValidFrom >= day {
+				dropIDs = append(dropIDs, version.ID)
+			} else {
+				closeIDs = append(closeIDs, version.ID)
+			}
+		}
+		for i := 0; i < len(dropIDs); i += 500 {
+			end := min(i+500, len(dropIDs))
+			if err := tx.Delete(&models.InstrumentVersionModel{}, dropIDs[i:end]).Error; err != nil {
+				return fmt.Errorf("failed to drop instrument versions: %v", err)
+			}
+		}
+		for i := 0; i < len(closeIDs); i += 500 {
+			end := min(i+500, len(closeIDs))
+			if err := tx.Model(&models.InstrumentVersionModel{}).Where("id IN ?", closeIDs[i:end]).Update("valid_to", day).Error; err != nil {
+				return fmt.Errorf("failed to close instrument versions: %v", err)
+			}
+		}
+		if len(versions) > 0 {
+			if err := tx.CreateInBatches(versions, 500).Error; err != nil {
+				return fmt.Errorf("failed to insert instrument versions: %v", err)
+			}
+		}
+		added, closed = int64(len(versions)), int64(len(stale))
+		return nil
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+	return added, closed, nil
+}
+
+// asOf scopes a query to the instrument versions valid on the date day
+func asOf(day string) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("valid_from <= ? AND (valid_to IS NULL OR valid_to > ?)", day, day)
+	}
+}
+
+// GetInstrumentVersionsByExchangeTradingsymbols returns the versions of the exchange's tradingsymbols valid on the date day
+func (r *InstrumentRepository) GetInstrumentVersionsByExchangeTradingsymbols(day, exchange string, tradingsymbols []string) ([]models.InstrumentVersionModel, error) {
+	var versions []models.InstrumentVersionModel
+	err := r.DB.Scopes(asOf(day)).Where("exchange = ? AND tradingsymbol IN ?", exchange, tradingsymbols).Find(&versions).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get instrument versions from %s: %v", models.InstrumentHistoryTableName, err)
+	}
+	return versions, nil
+}
+
+// GetInstrumentVersionsByTokens returns the versions of the tokens valid on the date day
+func (r *InstrumentRepository) GetInstrumentVersionsByTokens(day string, tokens []uint32) ([]models.InstrumentVersionModel, error) {
+	var versions []models.InstrumentVersionModel
+	err := r.DB.Scopes(asOf(day)).Where("instrument_token IN ?", tokens).Find(&versions).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get instrument versions from %s: %v", models.InstrumentHistoryTableName, err)
+	}
+	return versions, nil
+}
@@ -0,0 +1,123 @@
+package repository
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/nsvirk/moneybotsapi/internal/models"
+)
+
+func TestInstrumentVersionsRename(t *testing.T) {
+	db := instrumentsFileDB(t)
+	if err := db.AutoMigrate(&models.InstrumentVersionModel{}); err != nil {
+		t.Fatalf("AutoMigrate() error = %v", err)
+	}
+	repo := NewInstrumentRepository(db)
+
+	syncs := []struct {
+		day        string
+		records    [][]string
+		wantAdded  int64
+		wantClosed int64
+	}{
+		{day: "2024-01-10", records: [][]string{
+			instrumentRecord("100001", "MINDTREE", "NSE", "3500"),
+			instrumentRecord("100002", "INFY", "NSE", "1500"),
+		}, wantAdded: 2},
+		// the last price is not versioned
+		{day: "2024-01-11", records: [][]string{
+			instrumentRecord("100001", "MINDTREE", "NSE", "3550"),
+			instrumentRecord("100002", "INFY", "NSE", "1510"),
+		}},
+		// MINDTREE is renamed to LTIM on the same token
+		{day: "2024-02-01", records: [][]string{
+			instrumentRecord("100001", "LTIM", "NSE", "5500"),
+			instrumentRecord("100002", "INFY", "NSE", "1600"),
+		}, wantAdded: 1, wantClosed: 1},
+		// INFY is delisted
+		{day: "2024-03-01", records: [][]string{
+			instrumentRecord("100001", "LTIM", "NSE", "5600"),
+		}, wantClosed: 1},
+	}
+	for _, sync := range syncs {
+		added, closed, err := repo.RecordInstrumentVersions("NSE", sync.records, sync.day)
+		if err != nil {
+			t.Fatalf("RecordInstrumentVersions() of %s error = %v", sync.day, err)
+		}
+		if added != sync.wantAdded || closed != sync.wantClosed {
+			t.Errorf("RecordInstrumentVersions() of %s = %d added, %d closed, want %d, %d", sync.day, added, closed, sync.wantAdded, sync.wantClosed)
+		}
+	}
+
+	tokens := func(versions []models.InstrumentVersionModel) string {
+		got := make([]string, 0, len(versions))
+		for _, version := range versions {
+			got = append(got, strconv.Itoa(int(version.InstrumentToken)))
+		}
+		return strings.Join(got, ",")
+	}
+	symbolTests := []struct {
+		asOf          string
+		tradingsymbol string
+		want          string
+	}{
+		{"2024-01-01", "MINDTREE", ""},
+		{"2024-01-15", "MINDTREE", "100001"},
+		{"2024-01-31", "MINDTREE", "100001"},
+		{"2024-02-01", "MINDTREE", ""},
+		{"2024-01-15", "LTIM", ""},
+		{"2024-02-01", "LTIM", "100001"},
+		{"2024-06-30", "LTIM", "100001"},
+		{"2024-02-29", "INFY", "100002"},
+		{"2024-03-01", "INFY", ""},
+	}
+	for _, tt := range symbolTests {
+		versions, err := repo.GetInstrumentVersionsByExchangeTradingsymbols(tt.asOf, "NSE", []string{tt.tradingsymbol})
+		if err != nil {
+			t.Fatalf("GetInstrumentVersionsByExchangeTradingsymbols() error = %v", err)
+		}
+		if got := tokens(versions); got != tt.want {
+			t.Errorf("NSE:%s as of %s = token %q, want %q", tt.tradingsymbol, tt.asOf, got, tt.want)
+		}
+	}
+
+	tokenTests := []struct {
+		asOf string
+		want string
+	}{
+		{"2024-01-15", "MINDTREE"},
+		{"2024-02-01", "LTIM"},
+	}
+	for _, tt := range tokenTests {
+		versions, err := repo.GetInstrumentVersionsByTokens(tt.asOf, []uint32{100001})
+		if err != nil {
+			t.Fatalf("GetInstrumentVersionsByTokens() error = %v", err)
+		}
+		if len(versions) != 1 || versions[0].Tradingsymbol != tt.want {
+			t.Errorf("token 100001 as of %s = %+v, want %s", tt.asOf, versions, tt.want)
+		}
+	}
+}
+
+func TestInstrumentVersionsSameDay(t *testing.T) {
+	db := instrumentsFileDB(t)
+	if err := db.AutoMigrate(&models.InstrumentVersionModel{}); err != nil {
+		t.Fatalf("AutoMigrate() error = %v", err)
+	}
+	repo := NewInstrumentRepository(db)
+
+	// a second sync of the day replaces the version the first one opened
+	for _, tradingsymbol := range []string{"MINDTREE", "LTIM"} {
+		if _, _, err := repo.RecordInstrumentVersions("NSE", [][]string{instrumentRecord("100001", tradingsymbol, "NSE", "100")}, "2024-02-01"); err != nil {
+			t.Fatalf("RecordInstrumentVersions() error = %v", err)
+		}
+	}
+	var versions []models.InstrumentVersionModel
+	if err := db.Find(&versions).Error; err != nil {
+		t.Fatalf("Find() error = %v", err)
+	}
+	if len(versions) != 1 || versions[0].Tradingsymbol != "LTIM" || versions[0].ValidTo != nil {
+		t.Errorf("versions = %+v, want the single open LTIM version", versions)
+	}
+}
@@ -0,0 +1,67 @@
+package repository
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/nsvirk/moneybotsapi/internal/models"
+)
+
+func TestGetInstrumentsQuery(t *testing.T) {
+	db := memoryDB(t)
+	instruments := []models.InstrumentModel{
+		{InstrumentToken: 310001, Tradingsymbol: "AB%C", Exchange: "TST", Strike: 100},
+		{InstrumentToken: 310002, Tradingsymbol: "AB_D", Exchange: "TST", Strike: 200},
+		{InstrumentToken: 310003, Tradingsymbol: "ABXE", Exchange: "TST", Strike: 300},
+		{InstrumentToken: 310004, Tradingsymbol: `AB\F`, Exchange: "TST", Strike: 400},
+		{InstrumentToken: 310005, Tradingsymbol: "XYAB", Exchange: "TST", Strike: 500},
+	}
+	if err := db.Create(&instruments).Error; err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	t.Cleanup(func() {
+		db.Where("exchange = ?", "TST").Delete(&models.InstrumentModel{})
+	})
+
+	tests := []struct {
+		name   string
+		params models.QueryInstrumentsParams
+		want   string
+	}{
+		{name: "prefix", params: models.QueryInstrumentsParams{TradingsymbolPrefix: "AB"}, want: `AB%C,AB_D,ABXE,AB\F`},
+		{name: "percent is literal", params: models.QueryInstrumentsParams{TradingsymbolPrefix: "AB%"}, want: "AB%C"},
+		{name: "underscore is literal", params: models.QueryInstrumentsParams{TradingsymbolPrefix: "AB_"}, want: "AB_D"},
+		{name: "backslash is literal", params: models.QueryInstrumentsParams{TradingsymbolPrefix: `AB\`}, want: `AB\F`},
+		{name: "strike min", params: models.QueryInstrumentsParams{StrikeMin: "300"}, want: `ABXE,AB\F,XYAB`},
+		{name: "strike max", params: models.QueryInstrumentsParams{StrikeMax: "200"}, want: "AB%C,AB_D"},
+		{name: "strike range inclusive", params: models.QueryInstrumentsParams{StrikeMin: "200", StrikeMax: "400"}, want: `AB_D,ABXE,AB\F`},
+		{name: "first page", params: models.QueryInstrumentsParams{Limit: 2}, want: "AB%C,AB_D"},
+		{name: "second page", params: models.QueryInstrumentsParams{Limit: 2, Offset: 2}, want: `ABXE,AB\F`},
+		{name: "last page", params: models.QueryInstrumentsParams{Limit: 2, Offset: 4}, want: "XYAB"},
+		{name: "past the last page", params: models.QueryInstrumentsParams{Limit: 2, Offset: 6}, want: ""},
+		{name: "page of a filter", params: models.QueryInstrumentsParams{TradingsymbolPrefix: "AB", StrikeMin: "200", Limit: 1, Offset: 1}, want: "ABXE"},
+	}
+
+	repo := NewInstrumentRepository(db)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params := tt.params
+			params.Exchange = "TST"
+			got, err := repo.GetInstrumentsQuery(params)
+			if err != nil {
+				t.Fatalf("GetInstrumentsQuery() error = %v", err)
+			}
+			// only the pages are ordered, by instrument token
+			slices.SortFunc(got, func(a, b models.InstrumentModel) int { return cmp.Compare(a.InstrumentToken, b.InstrumentToken) })
+			symbols := make([]string, len(got))
+			for i, instrument := range got {
+				symbols[i] = instrument.Tradingsymbol
+			}
+			if joined := strings.Join(symbols, ","); joined != tt.want {
+				t.Errorf("GetInstrumentsQuery() = %s, want %s", joined, tt.want)
+			}
+		})
+	}
+}
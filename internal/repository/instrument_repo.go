@@ -2,6 +2,8 @@
 package repository
 
 import (
+	"crypto/md5"
+	"encoding/hex"
 	"fmt"
 	"strconv"
 	"strings"
@@ -9,6 +11,7 @@ import (
 
 	"github.com/nsvirk/moneybotsapi/internal/models"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // InstrumentRepository is the database repository for instruments
@@ -73,6 +76,55 @@ func (r *InstrumentRepository) InsertInstruments(records [][]string) (int64, err
 	return result.RowsAffected, nil
 }
 
+// ReplaceAllInstruments replaces all the instruments in a single transaction,
+// concurrent readers see either the old or the new instruments, never an empty or partial table
+func (r *InstrumentRepository) ReplaceAllInstruments(records [][]string, batchSize int) (int64, error) {
+	return r.replaceInstruments(func(tx *gorm.DB) error {
+		if err := tx.Exec(fmt.Sprintf("DELETE FROM %s", models.InstrumentsTableName)).Error; err != nil {
+			return fmt.Errorf("failed to delete instruments: %v", err)
+		}
+		return nil
+	}, records, batchSize)
+}
+
+// ReplaceExchangeInstruments replaces the instruments of an exchange in a single transaction,
+// instruments of other exchanges are left untouched
+func (r *InstrumentRepository) ReplaceExchangeInstruments(exchange string, records [][]string, batchSize int) (int64, error) {
+	return r.replaceInstruments(func(tx *gorm.DB) error {
+		if err := tx.Where("exchange = ?", exchange).Delete(&models.InstrumentModel{}).Error; err != nil {
+			return fmt.Errorf("failed to delete %s instruments: %v", exchange, err)
+		}
+		return nil
+	}, records, batchSize)
+}
+
+// replaceInstruments deletes and inserts the instruments in batches within a single transaction
+func (r *InstrumentRepository) replaceInstruments(deleteFn func(tx *gorm.DB) error, records [][]string, batchSize int) (int64, error) {
+	var totalInserted int64
+	err := r.DB.Transaction(func(tx *gorm.DB) error {
+		txRepo := NewInstrumentRepository(tx)
+		if err := deleteFn(tx); err != nil {
+			return err
+		}
+		for i := 0; i < len(records); i += batchSize {
+			end := i + batchSize
+			if end > len(records) {
+				end = len(records)
+			}
+			inserted, err := txRepo.InsertInstruments(records[i:end])
+			if err != nil {
+				return fmt.Errorf("failed to insert batch starting at index %d: %v", i, err)
+			}
+			totalInserted += inserted
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return totalInserted, nil
+}
+
 // GetInstrumentsRecordCount returns the number of records in the instruments table
 func (r *InstrumentRepository) GetInstrumentsRecordCount() (int64, error) {
 	var count int64
@@ -83,6 +135,56 @@ func (r *InstrumentRepository) GetInstrumentsRecordCount() (int64, error) {
 	return count, nil
 }
 
+// HasInstruments checks if the instruments table has any record, without counting them
+func (r *InstrumentRepository) HasInstruments() (bool, error) {
+	var tokens []uint32
+	err := r.DB.Table(models.InstrumentsTableName).Limit(1).Pluck("instrument_token", &tokens).Error
+	if err != nil {
+		return false, fmt.Errorf("failed to check the instruments: %v", err)
+	}
+	return len(tokens) > 0, nil
+}
+
+// GetInstrumentsChecksum returns the md5 checksum, record count and last sync time of the instruments,
+// limited to the exchange if given
+// The rows are hashed in token order as they are read, so the instruments are not all held in memory
+func (r *InstrumentRepository) GetInstrumentsChecksum(exchange string) (models.InstrumentsChecksum, error) {
+	query := r.DB.Model(&models.InstrumentModel{}).Order("instrument_token")
+	if exchange != "" {
+		query = query.Where("exchange = ?", exchange)
+	}
+	rows, err := query.Rows()
+	if err != nil {
+		return models.InstrumentsChecksum{}, fmt.Errorf("failed to get instruments checksum: %v", err)
+	}
+	defer rows.Close()
+
+	hash := md5.New()
+	checksum := models.InstrumentsChecksum{Exchange: exchange}
+	for rows.Next() {
+		var instrument models.InstrumentModel
+		if err := r.DB.ScanRows(rows, &instrument); err != nil {
+			return models.InstrumentsChecksum{}, fmt.Errorf("failed to get instruments checksum: %v", err)
+		}
+		fmt.Fprintf(hash, "%d|%d|%s|%s|%g|%s|%g|%g|%d|%s|%s|%s\n",
+			instrument.InstrumentToken, instrument.ExchangeToken, instrument.Tradingsymbol, instrument.Name,
+			instrument.LastPrice, instrument.Expiry, instrument.Strike, instrument.TickSize, instrument.LotSize,
+			instrument.InstrumentType, instrument.Segment, instrument.Exchange)
+		checksum.Records++
+		if instrument.UpdatedAt.After(checksum.SyncedAt) {
+			checksum.SyncedAt = instrument.UpdatedAt
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return models.InstrumentsChecksum{}, fmt.Errorf("failed to get instruments checksum: %v", err)
+	}
+	checksum.Checksum = hex.EncodeToString(hash.Sum(nil))
+	return checksum, nil
+}
+
+// likePrefixEscaper escapes the LIKE wildcards of a prefix or of a search term
+var likePrefixEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
 // GetInstrumentsQuery queries the instruments table
 func (r *InstrumentRepository) GetInstrumentsQuery(qip models.QueryInstrumentsParams) ([]models.InstrumentModel, error) {
 
@@ -97,11 +199,11 @@ func (r *InstrumentRepository) GetInstrumentsQuery(qip models.QueryInstrumentsPa
 	}
 
 	if qip.InstrumentToken != "" {
-		instrumentToken, err := strconv.ParseUint(qip.InstrumentToken, 10, 32)
+		instrumentToken, err := models.ParseInstrumentToken(qip.InstrumentToken)
 		if err != nil {
 			return nil, err
 		}
-		query = query.Where("instrument_token = ?", uint32(instrumentToken))
+		query = query.Where("instrument_token = ?", instrumentToken)
 	}
 
 	if qip.Name != "" {
@@ -128,6 +230,30 @@ func (r *InstrumentRepository) GetInstrumentsQuery(qip models.QueryInstrumentsPa
 		query = query.Where("instrument_type = ?", qip.InstrumentType)
 	}
 
+	if qip.TradingsymbolPrefix != "" {
+		query = query.Where(`tradingsymbol LIKE ? ESCAPE '\'`, likePrefixEscaper.Replace(qip.TradingsymbolPrefix)+"%")
+	}
+
+	if qip.StrikeMin != "" {
+		strikeMin, err := strconv.ParseFloat(qip.StrikeMin, 64)
+		if err != nil {
+			return nil, err
+		}
+		query = query.Where("strike >= ?", strikeMin)
+	}
+
+	if qip.StrikeMax != "" {
+		strikeMax, err := strconv.ParseFloat(qip.StrikeMax, 64)
+		if err != nil {
+			return nil, err
+		}
+		query = query.Where("strike <= ?", strikeMax)
+	}
+
+	if qip.Limit > 0 {
+		query = query.Order("instrument_token").Limit(qip.Limit).Offset(qip.Offset)
+	}
+
 	var instruments []models.InstrumentModel
 	if err := query.Find(&instruments).Error; err != nil {
 		return nil, err
@@ -172,6 +298,35 @@ func (r *InstrumentRepository) GetInstrumentsByExpiry(expiry string) ([]models.I
 	return instruments, nil
 }
 
+// GetFutureContracts gets the futures of the underlying name expiring on or after fromExpiry, nearest first
+func (r *InstrumentRepository) GetFutureContracts(exchange, name, fromExpiry string) ([]models.InstrumentModel, error) {
+	var instruments []models.InstrumentModel
+	err := r.DB.Where("exchange = ? AND name = ? AND instrument_type = ? AND expiry >= ?", exchange, name, "FUT", fromExpiry).
+		Order("expiry ASC").Find(&instruments).Error
+	return instruments, err
+}
+
+// GetOptionContracts returns the CE and PE contracts of the name expiring on expiry, ordered by strike
+func (r *InstrumentRepository) GetOptionContracts(exchange, name, expiry string) ([]models.InstrumentModel, error) {
+	var instruments []models.InstrumentModel
+	err := r.DB.Where("exchange = ? AND name = ? AND instrument_type IN ? AND expiry = ?", exchange, name, []string{"CE", "PE"}, expiry).
+		Order("strike ASC, instrument_type ASC").Find(&instruments).Error
+	return instruments, err
+}
+
+// GetNearestOptionExpiry returns the nearest expiry from fromExpiry on of the options of the name,
+// or "" if the name has no options expiring from then on
+func (r *InstrumentRepository) GetNearestOptionExpiry(exchange, name, fromExpiry string) (string, error) {
+	var expiries []string
+	err := r.DB.Model(&models.InstrumentModel{}).
+		Where("exchange = ? AND name = ? AND instrument_type IN ? AND expiry >= ?", exchange, name, []string{"CE", "PE"}, fromExpiry).
+		Order("expiry ASC").Limit(1).Pluck("expiry", &expiries).Error
+	if err != nil || len(expiries) == 0 {
+		return "", err
+	}
+	return expiries[0], nil
+}
+
 // GetInstrumentByExchangeTradingsymbol gets an instrument by exchange and tradingsymbol
 func (r *InstrumentRepository) GetInstrumentByExchangeTradingsymbol(exchange, tradingsymbol string) (models.InstrumentModel, error) {
 	var instrument models.InstrumentModel
@@ -195,6 +350,78 @@ func (r *InstrumentRepository) GetInstrumentsByTokens(tokens []uint32) ([]models
 	return instruments, nil
 }
 
+// GetKnownTokens returns the tokens which are in the instruments table
+func (r *InstrumentRepository) GetKnownTokens(tokens []uint32) ([]uint32, error) {
+	var known []uint32
+	err := r.DB.Model(&models.InstrumentModel{}).
+		Where("instrument_token IN ?", tokens).
+		Pluck("instrument_token", &known).Error
+	if err != nil {
+		return nil, err
+	}
+	return known, nil
+}
+
+// GetDelistedTokens returns the tokens whose versions in the instrument history are all closed,
+// the instruments were dropped from the instrument master by a sync
+func (r *InstrumentRepository) GetDelistedTokens(tokens []uint32) ([]uint32, error) {
+	var delisted []uint32
+	listed := r.DB.Model(&models.InstrumentVersionModel{}).Select("instrument_token").Where("valid_to IS NULL")
+	err := r.DB.Model(&models.InstrumentVersionModel{}).
+		Where("instrument_token IN ? AND valid_to IS NOT NULL", tokens).
+		Where("instrument_token NOT IN (?)", listed).
+		Distinct().Pluck("instrument_token", &delisted).Error
+	if err != nil {
+		return nil, err
+	}
+	return delisted, nil
+}
+
+// ReplaceFreezeLimits replaces all freeze limits in a single transaction
+func (r *InstrumentRepository) ReplaceFreezeLimits(limits []models.FreezeLimitModel) (int64, error) {
+	err := r.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("1 = 1").Delete(&models.FreezeLimitModel{}).Error; err != nil {
+			return fmt.Errorf("failed to delete freeze limits: %v", err)
+		}
+		if len(limits) == 0 {
+			return nil
+		}
+		return tx.CreateInBatches(limits, 500).Error
+	})
+	if err != nil {
+		return 0, err
+	}
+	return int64(len(limits)), nil
+}
+
+// GetFreezeLimits returns the freeze limits of the given underlying names of an exchange
+func (r *InstrumentRepository) GetFreezeLimits(exchange string, names []string) ([]models.FreezeLimitModel, error) {
+	var limits []models.FreezeLimitModel
+	if err := r.DB.Where("exchange = ? AND name IN ?", exchange, names).Find(&limits).Error; err != nil {
+		return nil, err
+	}
+	return limits, nil
+}
+
+// SearchInstrumentsByTradingsymbol returns instruments whose tradingsymbol contains the query, case insensitively
+// The exact matches come first, then the prefix matches, then the other matches, the shorter tradingsymbols first
+// in each, so the limit keeps the closest matches and not the first of the many derivatives containing the query
+func (r *InstrumentRepository) SearchInstrumentsByTradingsymbol(query string, limit int) ([]models.InstrumentModel, error) {
+	query = strings.ToUpper(query)
+	escaped := likePrefixEscaper.Replace(query)
+	var instruments []models.InstrumentModel
+	err := r.DB.Where(`UPPER(tradingsymbol) LIKE ? ESCAPE '\'`, "%"+escaped+"%").
+		Order(clause.OrderBy{Expression: clause.Expr{
+			SQL:                `CASE WHEN UPPER(tradingsymbol) = ? THEN 0 WHEN UPPER(tradingsymbol) LIKE ? ESCAPE '\' THEN 1 ELSE 2 END, LENGTH(tradingsymbol), tradingsymbol`,
+			Vars:               []interface{}{query, escaped + "%"},
+			WithoutParentheses: true,
+		}}).
+		Limit(limit).
+		Find(&instruments).
+		Error
+	return instruments, err
+}
+
 // GetFNOSegmentWiseName returns a list of segment wise name for a given expiry
 func (r *InstrumentRepository) GetFNOSegmentWiseName(expiry string) ([]models.InstrumentModel, error) {
 	var instruments []models.InstrumentModel
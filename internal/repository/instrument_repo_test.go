@@ -0,0 +1,164 @@
+package repository
+
+import (
+	"path/filepath"
+	"slices"
+	"strconv"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"github.com/nsvirk/moneybotsapi/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestGetDelistedTokens(t *testing.T) {
+	db := memoryDB(t)
+	closed := "2024-10-15"
+	versions := []models.InstrumentVersionModel{
+		// listed, never changed
+		{InstrumentToken: 300001, Tradingsymbol: "LISTED", Exchange: "NSE", ValidFrom: "2024-10-01"},
+		// listed, changed on a later sync
+		{InstrumentToken: 300002, Tradingsymbol: "CHANGED", Exchange: "NSE", ValidFrom: "2024-10-01", ValidTo: &closed},
+		{InstrumentToken: 300002, Tradingsymbol: "CHANGED", Exchange: "NSE", LotSize: 2, ValidFrom: closed},
+		// dropped from the master on a later sync
+		{InstrumentToken: 300003, Tradingsymbol: "DELISTED", Exchange: "NSE", ValidFrom: "2024-10-01", ValidTo: &closed},
+	}
+	if err := db.Create(&versions).Error; err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	t.Cleanup(func() {
+		db.Where("instrument_token IN ?", []uint32{300001, 300002, 300003}).Delete(&models.InstrumentVersionModel{})
+	})
+
+	got, err := NewInstrumentRepository(db).GetDelistedTokens([]uint32{300001, 300002, 300003, 300004})
+	if err != nil {
+		t.Fatalf("GetDelistedTokens() error = %v", err)
+	}
+	if want := []uint32{300003}; !slices.Equal(got, want) {
+		t.Errorf("GetDelistedTokens() = %v, want %v", got, want)
+	}
+}
+
+// instrumentsFileDB returns a database of the instruments table in a file, in WAL mode so the readers
+// run alongside a write transaction and see the last committed instruments, like on Postgres
+func instrumentsFileDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	dsn := "file:" + filepath.Join(t.TempDir(), "instruments.db") + "?_pragma=journal_mode(WAL)&_pragma=busy_timeout(5000)"
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("gorm.Open() error = %v", err)
+	}
+	if err := db.AutoMigrate(&models.InstrumentModel{}); err != nil {
+		t.Fatalf("AutoMigrate() error = %v", err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return db
+}
+
+// instrumentRecord returns a row of the instruments CSV
+func instrumentRecord(token, tradingsymbol, exchange, lastPrice string) []string {
+	return []string{token, token, tradingsymbol, tradingsymbol, lastPrice, "", "0", "0.05", "1", "EQ", exchange, exchange}
+}
+
+func TestReplaceAllInstrumentsConcurrentLookup(t *testing.T) {
+	db := instrumentsFileDB(t)
+	repo := NewInstrumentRepository(db)
+	// INFY is inserted in the last batch, so a replace which is not atomic leaves it missing for a while
+	var records [][]string
+	for token := 200001; token <= 200200; token++ {
+		records = append(records, instrumentRecord(strconv.Itoa(token), "SYM"+strconv.Itoa(token), "NSE", "100"))
+	}
+	records = append(records, instrumentRecord("100001", "INFY", "NSE", "1000"))
+	infy := records[len(records)-1]
+	if _, err := repo.ReplaceAllInstruments(records, 50); err != nil {
+		t.Fatalf("ReplaceAllInstruments() error = %v", err)
+	}
+
+	done := make(chan error)
+	go func() {
+		for i := 0; i < 5; i++ {
+			// INFY is in both the old and the new instruments, only its price changes
+			infy[4] = strconv.Itoa(1000 + i)
+			if _, err := repo.ReplaceAllInstruments(records, 50); err != nil {
+				done <- err
+				return
+			}
+		}
+		done <- nil
+	}()
+
+	lookups := 0
+	for {
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Fatalf("ReplaceAllInstruments() error = %v", err)
+			}
+			if lookups == 0 {
+				t.Fatalf("no lookup ran during the replace")
+			}
+			return
+		default:
+		}
+		if _, err := repo.GetInstrumentByExchangeTradingsymbol("NSE", "INFY"); err != nil {
+			t.Fatalf("GetInstrumentByExchangeTradingsymbol() during the replace error = %v", err)
+		}
+		lookups++
+	}
+}
+
+func TestGetInstrumentsChecksum(t *testing.T) {
+	repo := NewInstrumentRepository(instrumentsFileDB(t))
+	records := [][]string{
+		instrumentRecord("100001", "INFY", "NSE", "1000"),
+		instrumentRecord("100002", "TCS", "NSE", "2000"),
+		instrumentRecord("200001", "RELIANCE", "BSE", "3000"),
+	}
+	sync := func(records [][]string) {
+		t.Helper()
+		if _, err := repo.ReplaceAllInstruments(records, 500); err != nil {
+			t.Fatalf("ReplaceAllInstruments() error = %v", err)
+		}
+	}
+	checksum := func(exchange string) models.InstrumentsChecksum {
+		t.Helper()
+		checksum, err := repo.GetInstrumentsChecksum(exchange)
+		if err != nil {
+			t.Fatalf("GetInstrumentsChecksum(%q) error = %v", exchange, err)
+		}
+		return checksum
+	}
+
+	sync(records)
+	all, nse, bse := checksum(""), checksum("NSE"), checksum("BSE")
+	if all.Records != 3 || nse.Records != 2 || bse.Records != 1 {
+		t.Fatalf("GetInstrumentsChecksum() records = %d, %d, %d, want 3, 2, 1", all.Records, nse.Records, bse.Records)
+	}
+	if all.SyncedAt.IsZero() {
+		t.Errorf("GetInstrumentsChecksum() synced at is zero")
+	}
+
+	// a sync of the same rows keeps the checksums
+	sync(records)
+	if got := checksum(""); got.Checksum != all.Checksum {
+		t.Errorf("GetInstrumentsChecksum() after an unchanged sync = %s, want %s", got.Checksum, all.Checksum)
+	}
+
+	// a sync changing an NSE row changes the checksum of NSE only
+	records[1][4] = "2010"
+	sync(records)
+	if got := checksum(""); got.Checksum == all.Checksum {
+		t.Errorf("GetInstrumentsChecksum() after a changed sync = %s, want it changed", got.Checksum)
+	}
+	if got := checksum("NSE"); got.Checksum == nse.Checksum {
+		t.Errorf("GetInstrumentsChecksum(NSE) after a changed sync = %s, want it changed", got.Checksum)
+	}
+	if got := checksum("BSE"); got.Checksum != bse.Checksum {
+		t.Errorf("GetInstrumentsChecksum(BSE) after a changed NSE sync = %s, want %s", got.Checksum, bse.Checksum)
+	}
+}
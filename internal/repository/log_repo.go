@@ -0,0 +1,86 @@
+// Package repository contains the repository layer for the Moneybots API
+package repository
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/nsvirk/moneybotsapi/pkg/utils/zaplogger"
+	"gorm.io/gorm"
+)
+
+// LogRepository is the database repository for app logs
+type LogRepository struct {
+	DB *gorm.DB
+}
+
+// NewLogRepository creates a new log repository
+func NewLogRepository(db *gorm.DB) *LogRepository {
+	return &LogRepository{DB: db}
+}
+
+// LogFilter filters the logs, the zero value matches all logs
+// Module matches the callers under it, like `service` or `service/ticker_service.go`, and Search
+// matches the message and the JSON fields, case insensitively
+type LogFilter struct {
+	Levels []string
+	Module string
+	From   time.Time
+	To     time.Time
+	Search string
+}
+
+// settledLogsCondition keeps the logs inserted by the transactions older than every open transaction
+// An open insert holds ids it has not committed yet, lower than the ids committed after it by the other writers,
+// so a page must stop below them or they are skipped by the next page, age(xmin) is the age of the inserting
+// transaction and the frozen rows are the oldest
+const settledLogsCondition = "age(xmin) > age((txid_snapshot_xmin(txid_current_snapshot()) % 4294967296)::text::xid)"
+
+// GetLogsAfter returns up to limit logs matching the filter after the afterID, ordered by id
+// The logs are paged by id and not by timestamp, as the log writer inserts the entries in batches after they
+// are logged, so an entry can be inserted with a timestamp before that of a log already read, but not a lower id
+// On Postgres the logs of the inserts committed while an older insert is still open are left for a later page,
+// so the pages are disjoint and complete with concurrent writers, see settledLogsCondition
+// In memory mode the inserts are serialized, so they are committed in the order of their ids
+func (r *LogRepository) GetLogsAfter(afterID uint, filter LogFilter, limit int) ([]zaplogger.LogModel, error) {
+	query := r.DB.Model(&zaplogger.LogModel{})
+	if afterID > 0 {
+		query = query.Where("id > ?", afterID)
+	}
+	if r.DB.Dialector.Name() == "postgres" {
+		query = query.Where(settledLogsCondition)
+	}
+	if len(filter.Levels) > 0 {
+		query = query.Where("level IN ?", filter.Levels)
+	}
+	if filter.Module != "" {
+		query = query.Where(`caller LIKE ? ESCAPE '\'`, likePrefixEscaper.Replace(filter.Module)+"%")
+	}
+	if !filter.From.IsZero() {
+		query = query.Where("timestamp >= ?", filter.From)
+	}
+	if !filter.To.IsZero() {
+		query = query.Where("timestamp < ?", filter.To)
+	}
+	if filter.Search != "" {
+		pattern := "%" + likePrefixEscaper.Replace(strings.ToLower(filter.Search)) + "%"
+		query = query.Where(`(LOWER(message) LIKE ? ESCAPE '\' OR LOWER(fields) LIKE ? ESCAPE '\')`, pattern, pattern)
+	}
+
+	var logs []zaplogger.LogModel
+	err := query.Order("id ASC").Limit(limit).Find(&logs).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get logs from %s: %v", zaplogger.LogsTableName, err)
+	}
+	return logs, nil
+}
+
+// DeleteLogsBefore deletes the logs older than before, it returns the number of logs deleted
+func (r *LogRepository) DeleteLogsBefore(before time.Time) (int64, error) {
+	result := r.DB.Where("timestamp < ?", before).Delete(&zaplogger.LogModel{})
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to delete logs from %s: %v", zaplogger.LogsTableName, result.Error)
+	}
+	return result.RowsAffected, nil
+}
@@ -0,0 +1,120 @@
+package repository
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nsvirk/moneybotsapi/pkg/utils/zaplogger"
+)
+
+func TestGetLogsAfter(t *testing.T) {
+	db := memoryDB(t)
+	if err := db.AutoMigrate(&zaplogger.LogModel{}); err != nil {
+		t.Fatalf("AutoMigrate() error = %v", err)
+	}
+	t.Cleanup(func() { db.Where("1 = 1").Delete(&zaplogger.LogModel{}) })
+	repo := NewLogRepository(db)
+
+	insert := func(message string) {
+		log := zaplogger.LogModel{Timestamp: time.Now(), Level: "info", Caller: "service/test.go", Message: message}
+		if err := db.Create(&log).Error; err != nil {
+			t.Errorf("Create() error = %v", err)
+		}
+	}
+	for i := 0; i < 5; i++ {
+		insert(fmt.Sprintf("before %d", i))
+	}
+
+	// the logs are inserted while they are paged
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 20; i++ {
+			insert(fmt.Sprintf("during %d", i))
+		}
+	}()
+
+	seen := make(map[uint]bool)
+	var after uint
+	for done := false; ; {
+		page, err := repo.GetLogsAfter(after, LogFilter{}, 3)
+		if err != nil {
+			t.Fatalf("GetLogsAfter() error = %v", err)
+		}
+		for _, log := range page {
+			if log.ID <= after {
+				t.Fatalf("GetLogsAfter(%d) returned id %d, pages are not ordered", after, log.ID)
+			}
+			if seen[log.ID] {
+				t.Fatalf("GetLogsAfter(%d) returned id %d again, pages are not disjoint", after, log.ID)
+			}
+			seen[log.ID] = true
+			after = log.ID
+		}
+		if len(page) == 0 {
+			if done {
+				break
+			}
+			wg.Wait()
+			done = true
+		}
+	}
+
+	var total int64
+	db.Model(&zaplogger.LogModel{}).Count(&total)
+	if int64(len(seen)) != total || total != 25 {
+		t.Errorf("GetLogsAfter() paged %d logs, want all %d of 25", len(seen), total)
+	}
+}
+
+func TestGetLogsAfterFilter(t *testing.T) {
+	db := memoryDB(t)
+	if err := db.AutoMigrate(&zaplogger.LogModel{}); err != nil {
+		t.Fatalf("AutoMigrate() error = %v", err)
+	}
+	t.Cleanup(func() { db.Where("1 = 1").Delete(&zaplogger.LogModel{}) })
+	repo := NewLogRepository(db)
+
+	now := time.Date(2024, 10, 15, 10, 0, 0, 0, time.UTC)
+	logs := []zaplogger.LogModel{
+		{Timestamp: now, Level: "info", Caller: "service/quote_service.go:10", Message: "Quotes refreshed"},
+		{Timestamp: now.Add(time.Minute), Level: "error", Caller: "service/ticker_service.go:20", Message: "Ticker error", Fields: `{"token":"408065"}`},
+		{Timestamp: now.Add(2 * time.Minute), Level: "warn", Caller: "repository/tick_repo.go:30", Message: "Slow_query 100%"},
+	}
+	if err := db.Create(&logs).Error; err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		filter LogFilter
+		want   []string
+	}{
+		{name: "all", want: []string{"Quotes refreshed", "Ticker error", "Slow_query 100%"}},
+		{name: "levels", filter: LogFilter{Levels: []string{"error", "warn"}}, want: []string{"Ticker error", "Slow_query 100%"}},
+		{name: "module", filter: LogFilter{Module: "service"}, want: []string{"Quotes refreshed", "Ticker error"}},
+		{name: "time range", filter: LogFilter{From: now.Add(time.Minute), To: now.Add(2 * time.Minute)}, want: []string{"Ticker error"}},
+		{name: "search fields", filter: LogFilter{Search: "408065"}, want: []string{"Ticker error"}},
+		{name: "search escapes wildcards", filter: LogFilter{Search: "w%q"}, want: nil},
+		{name: "search percent", filter: LogFilter{Search: "100%"}, want: []string{"Slow_query 100%"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := repo.GetLogsAfter(0, tt.filter, 10)
+			if err != nil {
+				t.Fatalf("GetLogsAfter() error = %v", err)
+			}
+			var messages []string
+			for _, log := range got {
+				messages = append(messages, log.Message)
+			}
+			if fmt.Sprint(messages) != fmt.Sprint(tt.want) {
+				t.Errorf("GetLogsAfter() = %v, want %v", messages, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,57 @@
+// Package repository contains the repository layer for the Moneybots API
+package repository
+
+import (
+	"context"
+	"sync/atomic"
+
+	"gorm.io/gorm"
+)
+
+// queryCounterKey is the context key for the per request query counter
+type queryCounterKey struct{}
+
+// WithQueryCounter returns a context carrying a new query counter
+// Queries issued with a *gorm.DB bound to this context are counted
+func WithQueryCounter(ctx context.Context) context.Context {
+	return context.WithValue(ctx, queryCounterKey{}, new(atomic.Int64))
+}
+
+// QueryCount returns the number of queries counted for the context
+func QueryCount(ctx context.Context) int64 {
+	if counter, ok := ctx.Value(queryCounterKey{}).(*atomic.Int64); ok {
+		return counter.Load()
+	}
+	return 0
+}
+
+// countQuery increments the query counter of the statement context, if any
+func countQuery(db *gorm.DB) {
+	if db.Statement == nil || db.Statement.Context == nil {
+		return
+	}
+	if counter, ok := db.Statement.Context.Value(queryCounterKey{}).(*atomic.Int64); ok {
+		counter.Add(1)
+	}
+}
+
+// registerQueryCounter registers the gorm callbacks that count queries per context
+func registerQueryCounter(db *gorm.DB) error {
+	callbacks := db.Callback()
+	if err := callbacks.Query().After("gorm:query").Register("moneybots:count_query", countQuery); err != nil {
+		return err
+	}
+	if err := callbacks.Create().After("gorm:create").Register("moneybots:count_create", countQuery); err != nil {
+		return err
+	}
+	if err := callbacks.Update().After("gorm:update").Register("moneybots:count_update", countQuery); err != nil {
+		return err
+	}
+	if err := callbacks.Delete().After("gorm:delete").Register("moneybots:count_delete", countQuery); err != nil {
+		return err
+	}
+	if err := callbacks.Row().After("gorm:row").Register("moneybots:count_row", countQuery); err != nil {
+		return err
+	}
+	return callbacks.Raw().After("gorm:raw").Register("moneybots:count_raw", countQuery)
+}
@@ -0,0 +1,52 @@
+package repository
+
+import (
+	"context"
+	"testing"
+
+	"github.com/nsvirk/moneybotsapi/internal/models"
+)
+
+func TestQueryCounter(t *testing.T) {
+	db := memoryDB(t)
+
+	tests := []struct {
+		name    string
+		queries int
+		counted bool
+		want    int64
+	}{
+		{name: "no queries", counted: true},
+		{name: "queries bound to the request context", queries: 3, counted: true, want: 3},
+		{name: "queries outside the request context", queries: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := WithQueryCounter(context.Background())
+			queryDB := db
+			if tt.counted {
+				queryDB = db.WithContext(ctx)
+			}
+			repo := NewInstrumentRepository(queryDB)
+			for i := 0; i < tt.queries; i++ {
+				if _, err := repo.GetInstrumentsByTokens([]uint32{100001}); err != nil {
+					t.Fatalf("GetInstrumentsByTokens() error = %v", err)
+				}
+			}
+			if got := QueryCount(ctx); got != tt.want {
+				t.Errorf("QueryCount() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("writes are counted", func(t *testing.T) {
+		ctx := WithQueryCounter(context.Background())
+		watchlist := models.WatchlistModel{}
+		db.WithContext(ctx).Where("1 = 0").Find(&watchlist)
+		db.WithContext(ctx).Where("1 = 0").Delete(&watchlist)
+		if got := QueryCount(ctx); got != 2 {
+			t.Errorf("QueryCount() = %d, want 2", got)
+		}
+	})
+}
@@ -0,0 +1,62 @@
+// Package repository contains the repository layer for the Moneybots API
+package repository
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/nsvirk/moneybotsapi/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// QuotaRepository is the database repository for the daily quota usage, shared by the instances
+type QuotaRepository struct {
+	DB *gorm.DB
+}
+
+// NewQuotaRepository creates a new quota repository
+func NewQuotaRepository(db *gorm.DB) *QuotaRepository {
+	return &QuotaRepository{DB: db}
+}
+
+// IncrementUsage counts a call of the user on the day, unless limit calls are already counted,
+// a limit of 0 or less counts every call, it returns the calls counted and if this one was counted
+// The check and the increment are a single upsert, so concurrent calls cannot exceed the limit
+func (r *QuotaRepository) IncrementUsage(userId, day string, limit int) (int, bool, error) {
+	onConflict := clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "day"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{"used": gorm.Expr(models.QuotaUsageTableName + ".used + 1")}),
+	}
+	if limit > 0 {
+		onConflict.Where = clause.Where{Exprs: []clause.Expression{gorm.Expr(models.QuotaUsageTableName+".used < ?", limit)}}
+	}
+	result := r.DB.Clauses(onConflict).Create(&models.QuotaUsageModel{UserId: userId, Day: day, Used: 1})
+	if result.Error != nil {
+		return 0, false, fmt.Errorf("failed to upsert into %s: %v", models.QuotaUsageTableName, result.Error)
+	}
+	used, err := r.GetUsage(userId, day)
+	return used, result.RowsAffected > 0, err
+}
+
+// GetUsage returns the calls of the user counted on the day
+func (r *QuotaRepository) GetUsage(userId, day string) (int, error) {
+	var usage models.QuotaUsageModel
+	err := r.DB.Where("user_id = ? AND day = ?", userId, day).First(&usage).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to get usage from %s: %v", models.QuotaUsageTableName, err)
+	}
+	return usage.Used, nil
+}
+
+// DeleteUsageBefore deletes the usage of the days before day, it returns the number of rows deleted
+func (r *QuotaRepository) DeleteUsageBefore(day string) (int64, error) {
+	result := r.DB.Where("day < ?", day).Delete(&models.QuotaUsageModel{})
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to delete from %s: %v", models.QuotaUsageTableName, result.Error)
+	}
+	return result.RowsAffected, nil
+}
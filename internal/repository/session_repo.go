@@ -20,6 +20,7 @@ func NewSessionRepository(db *gorm.DB) *SessionRepository {
 }
 
 // UpsertSession upserts a session into the database
+// On conflict `updated_at` is bumped while `created_at` is left out of the updated columns, so it is never overwritten
 func (r *SessionRepository) UpsertSession(session *models.SessionModel) error {
 	return r.DB.Clauses(clause.OnConflict{
 		Columns:   []clause.Column{{Name: "user_id"}},
@@ -62,3 +63,44 @@ func (r *SessionRepository) DeleteSession(userId, enctoken string) (int64, error
 	rowsAffected := result.RowsAffected
 	return rowsAffected, nil
 }
+
+// GetSessionUsers gets the users of all sessions
+func (r *SessionRepository) GetSessionUsers() ([]models.SessionUser, error) {
+	var users []models.SessionUser
+	err := r.DB.Model(&models.SessionModel{}).
+		Select("user_id, user_name, disabled, daily_quota, role, login_time, created_at, updated_at").
+		Order("user_id").
+		Scan(&users).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get session users: %v", err)
+	}
+	return users, nil
+}
+
+// SetSessionDisabled disables or enables the session of a user
+func (r *SessionRepository) SetSessionDisabled(userId string, disabled bool) (int64, error) {
+	result := r.DB.Model(&models.SessionModel{}).Where("user_id = ?", userId).Update("disabled", disabled)
+	if result.Error != nil {
+		return 0, result.Error
+	}
+	return result.RowsAffected, nil
+}
+
+// SetSessionRole sets the role of a user
+func (r *SessionRepository) SetSessionRole(userId, role string) (int64, error) {
+	result := r.DB.Model(&models.SessionModel{}).Where("user_id = ?", userId).Update("role", role)
+	if result.Error != nil {
+		return 0, result.Error
+	}
+	return result.RowsAffected, nil
+}
+
+// ResetSession clears the stored password hash and enctoken of a user, forcing a fresh login
+func (r *SessionRepository) ResetSession(userId string) (int64, error) {
+	result := r.DB.Model(&models.SessionModel{}).Where("user_id = ?", userId).
+		Updates(map[string]interface{}{"hashed_password": "", "enctoken": ""})
+	if result.Error != nil {
+		return 0, result.Error
+	}
+	return result.RowsAffected, nil
+}
@@ -0,0 +1,67 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nsvirk/moneybotsapi/internal/models"
+)
+
+func TestSessionTimestamps(t *testing.T) {
+	repo := NewSessionRepository(memoryDB(t))
+	const userID = "TS0001"
+	t.Cleanup(func() {
+		repo.DB.Where("user_id = ?", userID).Delete(&models.SessionModel{})
+	})
+
+	if err := repo.UpsertSession(&models.SessionModel{UserId: userID, Enctoken: "enctoken-1", HashedPassword: "hash-1"}); err != nil {
+		t.Fatalf("UpsertSession() error = %v", err)
+	}
+	created, err := repo.GetSessionByUserId(userID)
+	if err != nil {
+		t.Fatalf("GetSessionByUserId() error = %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		update func() error
+	}{
+		{name: "password change", update: func() error {
+			return repo.UpsertSession(&models.SessionModel{UserId: userID, Enctoken: "enctoken-2", HashedPassword: "hash-2"})
+		}},
+		{name: "disable", update: func() error {
+			_, err := repo.SetSessionDisabled(userID, true)
+			return err
+		}},
+		{name: "reset", update: func() error {
+			_, err := repo.ResetSession(userID)
+			return err
+		}},
+	}
+
+	previous := created
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// the timestamps are apart even on a coarse clock
+			time.Sleep(10 * time.Millisecond)
+			if err := tt.update(); err != nil {
+				t.Fatalf("%s error = %v", tt.name, err)
+			}
+			updated, err := repo.GetSessionByUserId(userID)
+			if err != nil {
+				t.Fatalf("GetSessionByUserId() error = %v", err)
+			}
+			if !updated.CreatedAt.Equal(created.CreatedAt) {
+				t.Errorf("CreatedAt = %v after the %s, want it unchanged at %v", updated.CreatedAt, tt.name, created.CreatedAt)
+			}
+			if !updated.UpdatedAt.After(previous.UpdatedAt) {
+				t.Errorf("UpdatedAt = %v after the %s, want after %v", updated.UpdatedAt, tt.name, previous.UpdatedAt)
+			}
+			previous = updated
+		})
+	}
+
+	if previous.HashedPassword != "" || previous.Enctoken != "" || !previous.Disabled {
+		t.Errorf("session = %+v, want the reset and disabled session", previous)
+	}
+}
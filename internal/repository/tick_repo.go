@@ -0,0 +1,103 @@
+// Package repository contains the repository layer for the Moneybots API
+package repository
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/nsvirk/moneybotsapi/internal/models"
+	"gorm.io/gorm"
+)
+
+// ticksPartitionLocation is the location of the days of the ticks partitions
+var ticksPartitionLocation = time.FixedZone("IST", 5*60*60+30*60)
+
+// TickRepository is the database repository for the persisted ticks
+type TickRepository struct {
+	DB          *gorm.DB
+	partitioned bool
+	mu          sync.Mutex
+	partitions  map[string]bool // days whose partition is known to exist
+}
+
+// NewTickRepository creates a new TickRepository
+// The ticks table is only partitioned on Postgres, where the partition of a day is created by its first insert
+func NewTickRepository(db *gorm.DB) *TickRepository {
+	return &TickRepository{
+		DB:          db,
+		partitioned: db.Dialector.Name() == "postgres",
+		partitions:  make(map[string]bool),
+	}
+}
+
+// InsertTicks inserts the ticks, creating the partitions of their days first
+func (r *TickRepository) InsertTicks(ticks []models.TickModel) (int64, error) {
+	if len(ticks) == 0 {
+		return 0, nil
+	}
+	if r.partitioned {
+		for _, tick := range ticks {
+			if err := r.ensurePartition(tick.ReceivedAt); err != nil {
+				return 0, err
+			}
+		}
+	}
+	result := r.DB.CreateInBatches(ticks, 1000)
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to insert ticks into %s: %v", models.TicksTableName, result.Error)
+	}
+	return result.RowsAffected, nil
+}
+
+// ensurePartition creates the partition of the day of t if it does not exist
+func (r *TickRepository) ensurePartition(t time.Time) error {
+	day := t.In(ticksPartitionLocation)
+	dayStart := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, ticksPartitionLocation)
+	name := models.TicksTableName + "_" + dayStart.Format("20060102")
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.partitions[name] {
+		return nil
+	}
+	err := r.DB.Exec(fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s PARTITION OF %s FOR VALUES FROM ('%s') TO ('%s')",
+		name, models.TicksTableName,
+		dayStart.Format(time.RFC3339), dayStart.AddDate(0, 0, 1).Format(time.RFC3339))).Error
+	if err != nil {
+		return fmt.Errorf("failed to create partition %s: %v", name, err)
+	}
+	r.partitions[name] = true
+	return nil
+}
+
+// GetTicksAfter returns up to limit ticks with an ID after afterID in ID order, without their depth
+func (r *TickRepository) GetTicksAfter(afterID uint64, limit int) ([]models.TickModel, error) {
+	var ticks []models.TickModel
+	err := r.DB.Select("id", "received_at", "instrument_token", "instrument", "timestamp", "last_trade_time", "last_price", "volume", "oi").
+		Where("id > ?", afterID).
+		Order("id").
+		Limit(limit).
+		Find(&ticks).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get ticks from %s: %v", models.TicksTableName, err)
+	}
+	return ticks, nil
+}
+
+// GetLastTick returns the latest tick of the token with an ID before beforeID, or nil if there is none
+func (r *TickRepository) GetLastTick(token uint32, beforeID uint64) (*models.TickModel, error) {
+	var ticks []models.TickModel
+	err := r.DB.Select("id", "received_at", "instrument_token", "volume").
+		Where("instrument_token = ? AND id < ?", token, beforeID).
+		Order("id DESC").
+		Limit(1).
+		Find(&ticks).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get last tick of %d from %s: %v", token, models.TicksTableName, err)
+	}
+	if len(ticks) == 0 {
+		return nil, nil
+	}
+	return &ticks[0], nil
+}
@@ -0,0 +1,59 @@
+// Package repository contains the repository layer for the Moneybots API
+package repository
+
+import (
+	"fmt"
+
+	"github.com/nsvirk/moneybotsapi/internal/models"
+	"gorm.io/gorm"
+)
+
+// WatchlistRepository is the database repository for watchlists
+type WatchlistRepository struct {
+	DB *gorm.DB
+}
+
+// NewWatchlistRepository creates a new watchlist repository
+func NewWatchlistRepository(db *gorm.DB) *WatchlistRepository {
+	return &WatchlistRepository{DB: db}
+}
+
+// CreateWatchlist inserts the watchlist, setting its id
+func (r *WatchlistRepository) CreateWatchlist(watchlist *models.WatchlistModel) error {
+	if err := r.DB.Create(watchlist).Error; err != nil {
+		return fmt.Errorf("failed to insert into %s: %v", models.WatchlistsTableName, err)
+	}
+	return nil
+}
+
+// GetWatchlists returns the watchlists of the user
+func (r *WatchlistRepository) GetWatchlists(userId string) ([]models.WatchlistModel, error) {
+	var watchlists []models.WatchlistModel
+	err := r.DB.Where("user_id = ?", userId).Order("id").Find(&watchlists).Error
+	return watchlists, err
+}
+
+// GetWatchlist returns the watchlist of the user, gorm.ErrRecordNotFound if it is not the user's
+func (r *WatchlistRepository) GetWatchlist(userId string, id uint) (models.WatchlistModel, error) {
+	var watchlist models.WatchlistModel
+	err := r.DB.Where("user_id = ? AND id = ?", userId, id).First(&watchlist).Error
+	return watchlist, err
+}
+
+// UpdateWatchlist updates the name and instruments of the user's watchlist
+func (r *WatchlistRepository) UpdateWatchlist(watchlist *models.WatchlistModel) (int64, error) {
+	result := r.DB.Model(watchlist).Where("user_id = ?", watchlist.UserId).Select("name", "instruments", "updated_at").Updates(watchlist)
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to update %s: %v", models.WatchlistsTableName, result.Error)
+	}
+	return result.RowsAffected, nil
+}
+
+// DeleteWatchlist deletes the user's watchlist
+func (r *WatchlistRepository) DeleteWatchlist(userId string, id uint) (int64, error) {
+	result := r.DB.Where("user_id = ? AND id = ?", userId, id).Delete(&models.WatchlistModel{})
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to delete from %s: %v", models.WatchlistsTableName, result.Error)
+	}
+	return result.RowsAffected, nil
+}
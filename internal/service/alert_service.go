@@ -0,0 +1,129 @@
+// Package service contains the service layer for the Moneybots API
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/nsvirk/moneybotsapi/internal/config"
+	"github.com/nsvirk/moneybotsapi/pkg/utils/zaplogger"
+)
+
+var telegramAPIURL = "https://api.telegram.org"
+
+// AlertSink is a notification channel alerts are delivered to
+type AlertSink interface {
+	Name() string
+	Send(ctx context.Context, message string) error
+}
+
+// AlertDeliveryResult is the delivery outcome of an alert on a channel
+type AlertDeliveryResult struct {
+	Channel string `json:"channel"`
+	Success bool   `json:"success"`
+	Error   string `json:"error,omitempty"`
+}
+
+// AlertService is the service for sending alerts to the configured sinks
+type AlertService struct {
+	sinks []AlertSink
+}
+
+// NewAlertService creates a new AlertService with a sink for every configured channel
+func NewAlertService(cfg *config.Config) *AlertService {
+	client := &http.Client{Timeout: 10 * time.Second}
+	sinks := []AlertSink{logAlertSink{}}
+	if cfg.TelegramBotToken != "" && cfg.TelegramChatID != "" {
+		sinks = append(sinks, &telegramAlertSink{client: client, botToken: cfg.TelegramBotToken, chatID: cfg.TelegramChatID})
+	}
+	if cfg.AlertWebhookURL != "" {
+		sinks = append(sinks, &webhookAlertSink{client: client, url: cfg.AlertWebhookURL})
+	}
+	return &AlertService{sinks: sinks}
+}
+
+// Send sends the alert through every sink and returns the delivery result of each
+func (s *AlertService) Send(ctx context.Context, message string) []AlertDeliveryResult {
+	results := make([]AlertDeliveryResult, 0, len(s.sinks))
+	for _, sink := range s.sinks {
+		result := AlertDeliveryResult{Channel: sink.Name(), Success: true}
+		if err := sink.Send(ctx, message); err != nil {
+			result.Success = false
+			result.Error = err.Error()
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// logAlertSink writes alerts to the application logs
+type logAlertSink struct{}
+
+func (logAlertSink) Name() string { return "logs" }
+
+func (logAlertSink) Send(_ context.Context, message string) error {
+	zaplogger.Warn("alert", zaplogger.Fields{"message": message})
+	return nil
+}
+
+// telegramAlertSink sends alerts to a telegram chat
+type telegramAlertSink struct {
+	client   *http.Client
+	botToken string
+	chatID   string
+}
+
+func (t *telegramAlertSink) Name() string { return "telegram" }
+
+func (t *telegramAlertSink) Send(ctx context.Context, message string) error {
+	form := url.Values{"chat_id": {t.chatID}, "text": {message}}
+	endpoint := fmt.Sprintf("%s/bot%s/sendMessage", telegramAPIURL, t.botToken)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewBufferString(form.Encode()))
+	if err != nil {
+		return fmt.Errorf("failed to create telegram request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return doAlertRequest(t.client, req)
+}
+
+// webhookAlertSink posts alerts as json to a webhook
+type webhookAlertSink struct {
+	client *http.Client
+	url    string
+}
+
+func (w *webhookAlertSink) Name() string { return "webhook" }
+
+func (w *webhookAlertSink) Send(ctx context.Context, message string) error {
+	body, err := json.Marshal(map[string]string{"message": message})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewBuffer(body))
+	if err != nil {
+		return fmt.Errorf("failed to create webhook request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return doAlertRequest(w.client, req)
+}
+
+func doAlertRequest(client *http.Client, req *http.Request) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		// strip the url, it may carry credentials
+		if urlErr, ok := err.(*url.Error); ok {
+			return urlErr.Err
+		}
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+	return nil
+}
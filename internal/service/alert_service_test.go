@@ -0,0 +1,130 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/nsvirk/moneybotsapi/internal/config"
+)
+
+// alertRequest is a request received by the alert test server
+type alertRequest struct {
+	path        string
+	contentType string
+	body        string
+}
+
+// alertServer records the alert requests and responds with the status
+func alertServer(t *testing.T, status int) (*httptest.Server, *[]alertRequest) {
+	t.Helper()
+	var requests []alertRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests = append(requests, alertRequest{path: r.URL.Path, contentType: r.Header.Get("Content-Type"), body: string(body)})
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+func TestAlertServiceSinks(t *testing.T) {
+	tests := []struct {
+		name        string
+		status      int
+		wantSuccess bool
+		wantErr     string
+	}{
+		{name: "delivered", status: http.StatusOK, wantSuccess: true},
+		{name: "rejected", status: http.StatusBadRequest, wantErr: "unexpected status code: 400"},
+		{name: "failed upstream", status: http.StatusBadGateway, wantErr: "unexpected status code: 502"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, requests := alertServer(t, tt.status)
+			saved := telegramAPIURL
+			telegramAPIURL = server.URL
+			t.Cleanup(func() { telegramAPIURL = saved })
+
+			s := NewAlertService(&config.Config{
+				TelegramBotToken: "bot-token",
+				TelegramChatID:   "12345",
+				AlertWebhookURL:  server.URL + "/hook",
+			})
+			results := s.Send(context.Background(), "Ticker disconnected")
+
+			if len(results) != 3 || results[0].Channel != "logs" || !results[0].Success {
+				t.Fatalf("Send() = %+v, want the logs, telegram and webhook results", results)
+			}
+			for _, result := range results[1:] {
+				if result.Success != tt.wantSuccess || result.Error != tt.wantErr {
+					t.Errorf("Send() %s = %+v, want success %v and error %q", result.Channel, result, tt.wantSuccess, tt.wantErr)
+				}
+			}
+
+			if len(*requests) != 2 {
+				t.Fatalf("requests = %+v, want 2", *requests)
+			}
+			telegram, webhook := (*requests)[0], (*requests)[1]
+			form, _ := url.ParseQuery(telegram.body)
+			if telegram.path != "/botbot-token/sendMessage" || telegram.contentType != "application/x-www-form-urlencoded" ||
+				form.Get("chat_id") != "12345" || form.Get("text") != "Ticker disconnected" {
+				t.Errorf("telegram request = %+v, want the message to chat 12345", telegram)
+			}
+			var payload map[string]string
+			if err := json.Unmarshal([]byte(webhook.body), &payload); err != nil || webhook.path != "/hook" ||
+				webhook.contentType != "application/json" || payload["message"] != "Ticker disconnected" {
+				t.Errorf("webhook request = %+v, want the json message", webhook)
+			}
+		})
+	}
+}
+
+func TestAlertServiceUnreachable(t *testing.T) {
+	server, _ := alertServer(t, http.StatusOK)
+	server.Close()
+	saved := telegramAPIURL
+	telegramAPIURL = server.URL
+	t.Cleanup(func() { telegramAPIURL = saved })
+
+	s := NewAlertService(&config.Config{TelegramBotToken: "secret-bot-token", TelegramChatID: "12345"})
+	results := s.Send(context.Background(), "Ticker disconnected")
+	if len(results) != 2 || results[1].Success {
+		t.Fatalf("Send() = %+v, want a failed telegram result", results)
+	}
+	// the telegram url carries the bot token, so it is stripped from the error
+	if strings.Contains(results[1].Error, "secret-bot-token") {
+		t.Errorf("Send() error = %q, reveals the bot token", results[1].Error)
+	}
+}
+
+func TestNewAlertServiceSinks(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  config.Config
+		want []string
+	}{
+		{name: "logs only", want: []string{"logs"}},
+		{name: "telegram without a chat", cfg: config.Config{TelegramBotToken: "bot-token"}, want: []string{"logs"}},
+		{name: "telegram", cfg: config.Config{TelegramBotToken: "bot-token", TelegramChatID: "12345"}, want: []string{"logs", "telegram"}},
+		{name: "webhook", cfg: config.Config{AlertWebhookURL: "https://example.com/hook"}, want: []string{"logs", "webhook"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, sink := range NewAlertService(&tt.cfg).sinks {
+				got = append(got, sink.Name())
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("NewAlertService() sinks = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
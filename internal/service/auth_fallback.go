@@ -0,0 +1,61 @@
+// Package service contains the service layer for the Moneybots API
+package service
+
+import (
+	"errors"
+	"time"
+
+	"github.com/nsvirk/moneybotsapi/internal/config"
+	"github.com/nsvirk/moneybotsapi/internal/models"
+	"github.com/nsvirk/moneybotsapi/pkg/utils/zaplogger"
+)
+
+// ErrSessionStoreUnavailable is returned when a session cannot be verified as the session store is unavailable
+var ErrSessionStoreUnavailable = errors.New("session store unavailable, retry shortly")
+
+// authSessionCache holds the recently verified sessions by user id, to verify them while the session store is unavailable
+var authSessionCache = newTTLCache[models.SessionModel]("auth_sessions")
+
+// authFallback is how sessions are verified while the session store is unavailable
+var authFallback = struct {
+	open bool
+	ttl  time.Duration
+}{}
+
+// SetAuthFallback sets if sessions verified within ttl are still accepted while the session store is unavailable
+// The fallback is `open` or `closed`, a closed fallback or a ttl of 0 rejects all sessions until the store is back
+func SetAuthFallback(fallback string, ttl time.Duration) {
+	authFallback.open = fallback == config.AuthFallbackOpen
+	authFallback.ttl = ttl
+}
+
+// rememberVerifiedSession keeps the verified session for the fallback
+func rememberVerifiedSession(session *models.SessionModel) {
+	if authFallback.open && authFallback.ttl > 0 {
+		authSessionCache.Set(session.UserId, *session, authFallback.ttl)
+	}
+}
+
+// forgetVerifiedSession drops the user's session from the fallback, after it was changed or revoked
+func forgetVerifiedSession(userID string) {
+	authSessionCache.Delete(userID)
+}
+
+// fallbackSession verifies the session against the recently verified sessions, as the lookup failed with storeErr
+// Only sessions verified with the same enctoken within the fallback ttl are accepted, and only with an open fallback
+func fallbackSession(userID, enctoken string, storeErr error) (*models.SessionModel, error) {
+	if authFallback.open {
+		if session, ok := authSessionCache.Get(userID); ok && session.Enctoken == enctoken {
+			zaplogger.Warn("Session verified from cache, session store unavailable", zaplogger.Fields{
+				"user_id": userID,
+				"error":   storeErr.Error(),
+			})
+			return &session, nil
+		}
+	}
+	zaplogger.Error("Session store unavailable", zaplogger.Fields{
+		"user_id": userID,
+		"error":   storeErr.Error(),
+	})
+	return nil, ErrSessionStoreUnavailable
+}
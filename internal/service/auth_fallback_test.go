@@ -0,0 +1,94 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"github.com/nsvirk/moneybotsapi/internal/config"
+	"github.com/nsvirk/moneybotsapi/internal/models"
+	"github.com/nsvirk/moneybotsapi/pkg/utils/clock"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestAuthFallback(t *testing.T) {
+	offlineTestSessions(t)
+	fake := clock.NewFake(time.Date(2024, 10, 15, 9, 15, 0, 0, MarketLocation))
+	authSessionCache.clock = fake
+	t.Cleanup(func() {
+		authSessionCache.clock = clock.Real
+		authSessionCache.Clear()
+		SetAuthFallback(config.AuthFallbackClosed, 0)
+	})
+
+	// the sessions table is missing from the unavailable store, so every lookup fails
+	unavailable, err := gorm.Open(sqlite.Open("file:auth_fallback_unavailable?mode=memory&cache=shared"), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("failed to open the database: %v", err)
+	}
+	available := NewSessionService(sessionDB(t, models.SessionModel{UserId: "AB1234", Enctoken: "enc-ab"}))
+	down := NewSessionService(unavailable)
+
+	tests := []struct {
+		name     string
+		fallback string
+		before   func(t *testing.T)
+		advance  time.Duration
+		enctoken string
+		wantErr  error
+	}{
+		{name: "within the ttl", fallback: config.AuthFallbackOpen, enctoken: "enc-ab"},
+		{name: "at the ttl", fallback: config.AuthFallbackOpen, advance: time.Minute, enctoken: "enc-ab"},
+		{name: "past the ttl", fallback: config.AuthFallbackOpen, advance: time.Minute + time.Second, enctoken: "enc-ab", wantErr: ErrSessionStoreUnavailable},
+		{name: "other enctoken", fallback: config.AuthFallbackOpen, enctoken: "enc-other", wantErr: ErrSessionStoreUnavailable},
+		{name: "closed fallback", fallback: config.AuthFallbackClosed, enctoken: "enc-ab", wantErr: ErrSessionStoreUnavailable},
+		{
+			name:     "disabled user",
+			fallback: config.AuthFallbackOpen,
+			before: func(t *testing.T) {
+				if _, err := available.SetUserDisabled("AB1234", true); err != nil {
+					t.Fatalf("SetUserDisabled() error = %v", err)
+				}
+				t.Cleanup(func() { _, _ = available.SetUserDisabled("AB1234", false) })
+			},
+			enctoken: "enc-ab",
+			wantErr:  ErrSessionStoreUnavailable,
+		},
+		{
+			name:     "logged out user",
+			fallback: config.AuthFallbackOpen,
+			before: func(t *testing.T) {
+				forgetVerifiedSession("AB1234")
+			},
+			enctoken: "enc-ab",
+			wantErr:  ErrSessionStoreUnavailable,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			authSessionCache.Clear()
+			SetAuthFallback(tt.fallback, time.Minute)
+			if _, err := available.VerifyUserAuthorization("AB1234", "enc-ab"); err != nil {
+				t.Fatalf("VerifyUserAuthorization() error = %v with the store available", err)
+			}
+			if tt.before != nil {
+				tt.before(t)
+			}
+			fake.Advance(tt.advance)
+
+			session, err := down.VerifyUserAuthorization("AB1234", tt.enctoken)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("VerifyUserAuthorization() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || session.UserId != "AB1234" {
+				t.Errorf("VerifyUserAuthorization() = %v, %v, want the cached session of AB1234", session, err)
+			}
+		})
+	}
+}
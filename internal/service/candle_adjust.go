@@ -0,0 +1,57 @@
+// Package service contains the service layer for the Moneybots API
+package service
+
+import (
+	"math"
+
+	"github.com/nsvirk/moneybotsapi/internal/models"
+)
+
+// adjustCandles back adjusts the candles, sorted by timestamp, for the corporate actions sorted by ex date
+// Only the actions going ex after the first candle's day and up to the last candle's day are applied
+// A split or bonus divides the earlier prices by its ratio and multiplies the earlier volumes by it,
+// a dividend scales the earlier prices by (close - dividend) / close, close being the last close before the ex date
+// The factors accumulate backwards, so the candles from the last ex date on are left as they are
+func adjustCandles(candles []models.CandleModel, actions []models.CorporateActionModel) []models.CandleModel {
+	adjusted := make([]models.CandleModel, len(candles))
+	copy(adjusted, candles)
+	if len(candles) == 0 {
+		return adjusted
+	}
+
+	a := len(actions) - 1
+	for ; a >= 0 && actions[a].ExDate > candleDay(candles[len(candles)-1]); a-- {
+	}
+
+	priceFactor, volumeFactor := 1.0, 1.0
+	for i := len(adjusted) - 1; i >= 0; i-- {
+		// the actions going ex after this candle and up to the next one apply to this candle and the earlier ones
+		for ; a >= 0 && actions[a].ExDate > candleDay(candles[i]); a-- {
+			switch actions[a].Type {
+			case models.CorporateActionSplit, models.CorporateActionBonus:
+				if actions[a].Ratio > 0 {
+					priceFactor /= actions[a].Ratio
+					volumeFactor *= actions[a].Ratio
+				}
+			case models.CorporateActionDividend:
+				if prevClose := candles[i].Close; prevClose > actions[a].Amount {
+					priceFactor *= (prevClose - actions[a].Amount) / prevClose
+				}
+			}
+		}
+		if priceFactor == 1 && volumeFactor == 1 {
+			continue
+		}
+		adjusted[i].Open = roundTo(adjusted[i].Open*priceFactor, 2)
+		adjusted[i].High = roundTo(adjusted[i].High*priceFactor, 2)
+		adjusted[i].Low = roundTo(adjusted[i].Low*priceFactor, 2)
+		adjusted[i].Close = roundTo(adjusted[i].Close*priceFactor, 2)
+		adjusted[i].Volume = uint64(math.Round(float64(adjusted[i].Volume) * volumeFactor))
+	}
+	return adjusted
+}
+
+// candleDay returns the market day of the candle as `YYYY-MM-DD`
+func candleDay(candle models.CandleModel) string {
+	return candle.Timestamp.In(MarketLocation).Format("2006-01-02")
+}
@@ -0,0 +1,65 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/nsvirk/moneybotsapi/internal/models"
+)
+
+func TestAdjustCandles(t *testing.T) {
+	candle := func(day int, close float64, volume uint64) models.CandleModel {
+		return models.CandleModel{
+			Timestamp: time.Date(2024, 10, day, 9, 15, 0, 0, MarketLocation),
+			Open:      close, High: close, Low: close, Close: close, Volume: volume,
+		}
+	}
+	candles := []models.CandleModel{candle(14, 1000, 100), candle(15, 1010, 100), candle(16, 205, 500)}
+	split := models.CorporateActionModel{ExDate: "2024-10-16", Type: models.CorporateActionSplit, Ratio: 5}
+
+	tests := []struct {
+		name    string
+		actions []models.CorporateActionModel
+		want    []models.CandleModel
+	}{
+		{
+			name: "no actions",
+			want: candles,
+		},
+		{
+			name:    "split",
+			actions: []models.CorporateActionModel{split},
+			want:    []models.CandleModel{candle(14, 200, 500), candle(15, 202, 500), candle(16, 205, 500)},
+		},
+		{
+			name:    "dividend",
+			actions: []models.CorporateActionModel{{ExDate: "2024-10-16", Type: models.CorporateActionDividend, Amount: 10}},
+			want:    []models.CandleModel{candle(14, 990.1, 100), candle(15, 1000, 100), candle(16, 205, 500)},
+		},
+		{
+			name: "dividend before a split",
+			actions: []models.CorporateActionModel{
+				{ExDate: "2024-10-15", Type: models.CorporateActionDividend, Amount: 10},
+				split,
+			},
+			want: []models.CandleModel{candle(14, 198, 500), candle(15, 202, 500), candle(16, 205, 500)},
+		},
+		{
+			name: "actions outside the candles are not applied",
+			actions: []models.CorporateActionModel{
+				{ExDate: "2024-10-14", Type: models.CorporateActionSplit, Ratio: 2},
+				{ExDate: "2024-10-17", Type: models.CorporateActionBonus, Ratio: 2},
+			},
+			want: candles,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := adjustCandles(candles, tt.actions); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("adjustCandles() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
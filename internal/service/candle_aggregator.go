@@ -0,0 +1,310 @@
+// Package service contains the service layer for the Moneybots API
+package service
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/nsvirk/moneybotsapi/internal/config"
+	"github.com/nsvirk/moneybotsapi/internal/models"
+	"github.com/nsvirk/moneybotsapi/internal/repository"
+	"github.com/nsvirk/moneybotsapi/pkg/utils/state"
+	"github.com/nsvirk/moneybotsapi/pkg/utils/zaplogger"
+	"gorm.io/gorm"
+)
+
+var ticksAggregatedKey = "TICKS_AGGREGATED_UPTO_ID"
+
+// ticksAggregationBatch is the max number of ticks read at a time by the candle aggregator
+const ticksAggregationBatch = 20000
+
+// IntradayCandleIntervals are the intervals of the candles aggregated from the stored ticks
+var IntradayCandleIntervals = []string{
+	models.CandleIntervalMinute,
+	models.CandleInterval5Minute,
+	models.CandleInterval15Minute,
+	models.CandleInterval60Minute,
+}
+
+// intradayCandleDurations are the durations of the IntradayCandleIntervals
+var intradayCandleDurations = map[string]time.Duration{
+	models.CandleIntervalMinute:   time.Minute,
+	models.CandleInterval5Minute:  5 * time.Minute,
+	models.CandleInterval15Minute: 15 * time.Minute,
+	models.CandleInterval60Minute: time.Hour,
+}
+
+// StartCandleAggregation aggregates the stored ticks into the intraday candles every CandleAggregation,
+// while the tick store is enabled
+func StartCandleAggregation(ctx context.Context, cfg *config.Config, db *gorm.DB) {
+	if !cfg.TickStore || cfg.CandleAggregation <= 0 {
+		return
+	}
+	aggregator := NewCandleAggregator(db)
+
+	go func() {
+		ticker := time.NewTicker(cfg.CandleAggregation)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if _, err := aggregator.Aggregate(); err != nil {
+					zaplogger.Warn("Candle aggregation failed", zaplogger.Fields{
+						"error": err.Error(),
+					})
+				}
+			}
+		}
+	}()
+}
+
+// tickVolume is the cumulative day volume of the last aggregated tick of a token, and the day it was received on
+type tickVolume struct {
+	day    string
+	volume uint32
+}
+
+// candleKey identifies a candle of a token for an interval by the unix nanos of its start
+type candleKey struct {
+	token    uint32
+	interval string
+	start    int64
+}
+
+// CandleAggregator aggregates the stored ticks into the candles of the IntradayCandleIntervals
+// Only the ticks after the last aggregated tick ID are read, and folded into the stored candles,
+// so each tick is aggregated once
+type CandleAggregator struct {
+	tickRepo    *repository.TickRepository
+	candleRepo  *repository.CandleRepository
+	state       *state.State
+	lastID      uint64
+	loaded      bool
+	lastVolumes map[uint32]tickVolume
+}
+
+// NewCandleAggregator creates a new CandleAggregator
+func NewCandleAggregator(db *gorm.DB) *CandleAggregator {
+	stateManager, err := state.NewState(db)
+	if err != nil {
+		zaplogger.Fatal("failed to create state manager", zaplogger.Fields{"error": err})
+	}
+	return &CandleAggregator{
+		tickRepo:    repository.NewTickRepository(db),
+		candleRepo:  repository.NewCandleRepository(db),
+		state:       stateManager,
+		lastVolumes: make(map[uint32]tickVolume),
+	}
+}
+
+// Aggregate aggregates the ticks stored since the last run into the candles, it returns the number of ticks aggregated
+func (a *CandleAggregator) Aggregate() (int, error) {
+	if !a.loaded {
+		lastID, err := a.state.Get(ticksAggregatedKey)
+		if err != nil {
+			return 0, fmt.Errorf("failed to get state: %v", err)
+		}
+		if lastID != "" {
+			if a.lastID, err = strconv.ParseUint(lastID, 10, 64); err != nil {
+				return 0, fmt.Errorf("invalid state %s: %v", ticksAggregatedKey, err)
+			}
+		}
+		a.loaded = true
+	}
+
+	total := 0
+	for {
+		ticks, err := a.tickRepo.GetTicksAfter(a.lastID, ticksAggregationBatch)
+		if err != nil {
+			return total, err
+		}
+		if len(ticks) == 0 {
+			return total, nil
+		}
+		if err := a.aggregateTicks(ticks); err != nil {
+			// the volumes of the batch are reloaded from the ticks on the next run
+			a.lastVolumes = make(map[uint32]tickVolume)
+			return total, err
+		}
+		a.lastID = ticks[len(ticks)-1].ID
+		total += len(ticks)
+
+		// the candles are stored, so a failed update is only aggregated again after a restart
+		if err := a.state.Set(ticksAggregatedKey, strconv.FormatUint(a.lastID, 10)); err != nil {
+			zaplogger.Error("Failed to update state", zaplogger.Fields{
+				ticksAggregatedKey: a.lastID,
+				"error":            err.Error(),
+			})
+		}
+		if len(ticks) < ticksAggregationBatch {
+			return total, nil
+		}
+	}
+}
+
+// aggregateTicks folds the ticks, in ID order, into the candles of each interval and upserts them
+func (a *CandleAggregator) aggregateTicks(ticks []models.TickModel) error {
+	candles := make(map[candleKey]*models.CandleModel)
+	for i := range ticks {
+		tick := &ticks[i]
+		volume, err := a.volumeDelta(tick)
+		if err != nil {
+			return err
+		}
+		if tick.LastPrice <= 0 {
+			continue
+		}
+
+		at := tickCandleTime(tick)
+		for _, interval := range IntradayCandleIntervals {
+			start := intradayCandleStart(at, intradayCandleDurations[interval])
+			key := candleKey{token: tick.InstrumentToken, interval: interval, start: start.UnixNano()}
+			candle, ok := candles[key]
+			if !ok {
+				candle = &models.CandleModel{
+					InstrumentToken: tick.InstrumentToken,
+					Interval:        interval,
+					Timestamp:       start,
+					Open:            tick.LastPrice,
+					High:            tick.LastPrice,
+					Low:             tick.LastPrice,
+				}
+				candles[key] = candle
+			}
+			candle.Instrument = tick.Instrument
+			candle.High = max(candle.High, tick.LastPrice)
+			candle.Low = min(candle.Low, tick.LastPrice)
+			candle.Close = tick.LastPrice
+			candle.Volume += volume
+			candle.OI = uint64(tick.OI)
+		}
+	}
+	if len(candles) == 0 {
+		return nil
+	}
+
+	if err := a.mergeStoredCandles(candles); err != nil {
+		return err
+	}
+	upserts := make([]models.CandleModel, 0, len(candles))
+	for _, candle := range candles {
+		upserts = append(upserts, *candle)
+	}
+	_, err := a.candleRepo.UpsertCandles(upserts)
+	return err
+}
+
+// mergeStoredCandles merges the stored candles into the aggregated candles of the same key,
+// keeping the stored open and adding the stored volume
+func (a *CandleAggregator) mergeStoredCandles(candles map[candleKey]*models.CandleModel) error {
+	type intervalRange struct {
+		tokens   map[uint32]bool
+		from, to time.Time
+	}
+	ranges := make(map[string]*intervalRange)
+	for _, candle := range candles {
+		r, ok := ranges[candle.Interval]
+		if !ok {
+			r = &intervalRange{tokens: make(map[uint32]bool), from: candle.Timestamp, to: candle.Timestamp}
+			ranges[candle.Interval] = r
+		}
+		r.tokens[candle.InstrumentToken] = true
+		if candle.Timestamp.Before(r.from) {
+			r.from = candle.Timestamp
+		}
+		if candle.Timestamp.After(r.to) {
+			r.to = candle.Timestamp
+		}
+	}
+
+	for interval, r := range ranges {
+		tokens := make([]uint32, 0, len(r.tokens))
+		for token := range r.tokens {
+			tokens = append(tokens, token)
+		}
+		stored, err := a.candleRepo.GetTokensCandles(tokens, interval, r.from, r.to)
+		if err != nil {
+			return err
+		}
+		for _, storedCandle := range stored {
+			candle, ok := candles[candleKey{token: storedCandle.InstrumentToken, interval: interval, start: storedCandle.Timestamp.UnixNano()}]
+			if !ok {
+				continue
+			}
+			candle.Open = storedCandle.Open
+			candle.High = max(candle.High, storedCandle.High)
+			candle.Low = min(candle.Low, storedCandle.Low)
+			candle.Volume += storedCandle.Volume
+		}
+	}
+	return nil
+}
+
+// volumeDelta returns the volume traded between the previous tick of the token and the tick,
+// from their cumulative day volumes, which restart with each day
+func (a *CandleAggregator) volumeDelta(tick *models.TickModel) (uint64, error) {
+	last, ok := a.lastVolumes[tick.InstrumentToken]
+	if !ok {
+		prev, err := a.tickRepo.GetLastTick(tick.InstrumentToken, tick.ID)
+		if err != nil {
+			return 0, err
+		}
+		if prev != nil {
+			last = tickVolume{day: prev.ReceivedAt.In(MarketLocation).Format("2006-01-02"), volume: prev.VolumeTraded}
+		}
+	}
+
+	current := tickVolume{day: tick.ReceivedAt.In(MarketLocation).Format("2006-01-02"), volume: tick.VolumeTraded}
+	a.lastVolumes[tick.InstrumentToken] = current
+	switch {
+	case current.day != last.day:
+		return uint64(current.volume), nil
+	case current.volume < last.volume:
+		return 0, nil
+	default:
+		return uint64(current.volume - last.volume), nil
+	}
+}
+
+// tickCandleTime returns the time the tick is aggregated at, the last trade time if the feed has it,
+// else the exchange timestamp, else when it was received
+func tickCandleTime(tick *models.TickModel) time.Time {
+	switch {
+	case tick.LastTradeTime.Unix() > 0:
+		return tick.LastTradeTime.In(MarketLocation)
+	case tick.Timestamp.Unix() > 0:
+		return tick.Timestamp.In(MarketLocation)
+	default:
+		return tick.ReceivedAt.In(MarketLocation)
+	}
+}
+
+// intradayCandleStart returns the start of the candle of the duration at t, the candles are aligned
+// to the 09:15 market open like those of the historical API
+func intradayCandleStart(t time.Time, d time.Duration) time.Time {
+	open := time.Date(t.Year(), t.Month(), t.Day(), 9, 15, 0, 0, MarketLocation)
+	offset := t.Sub(open)
+	buckets := offset / d
+	if offset < 0 && offset%d != 0 {
+		buckets--
+	}
+	return open.Add(buckets * d)
+}
+
+// GetIntradayCandles returns the candles of the instruments for the interval on the day, keyed by instrument
+// and sorted by timestamp, the instruments without candles are absent
+func (s *CandleService) GetIntradayCandles(instruments []string, interval string, day time.Time) (map[string][]models.CandleModel, error) {
+	candles, err := s.repo.GetInstrumentsCandles(instruments, interval, day, day.AddDate(0, 0, 1).Add(-time.Nanosecond))
+	if err != nil {
+		return nil, err
+	}
+	instrumentCandles := make(map[string][]models.CandleModel)
+	for _, candle := range candles {
+		instrumentCandles[candle.Instrument] = append(instrumentCandles[candle.Instrument], candle)
+	}
+	return instrumentCandles, nil
+}
@@ -0,0 +1,205 @@
+// Package service contains the service layer for the Moneybots API
+package service
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/nsvirk/moneybotsapi/internal/config"
+	"github.com/nsvirk/moneybotsapi/internal/models"
+	"github.com/nsvirk/moneybotsapi/internal/repository"
+	"github.com/nsvirk/moneybotsapi/pkg/utils/state"
+	"github.com/nsvirk/moneybotsapi/pkg/utils/zaplogger"
+	"gorm.io/gorm"
+)
+
+var dayCandlesRolledUpKey = "DAY_CANDLES_ROLLED_UP_FOR"
+
+// CandleService is the service for candles
+type CandleService struct {
+	db             *gorm.DB
+	client         *http.Client
+	repo           *repository.CandleRepository
+	actionRepo     *repository.CorporateActionRepository
+	instrumentRepo *repository.InstrumentRepository
+	marketService  *MarketService
+	state          *state.State
+}
+
+// NewCandleService creates a new CandleService
+func NewCandleService(cfg *config.Config, db *gorm.DB) *CandleService {
+	stateManager, err := state.NewState(db)
+	if err != nil {
+		zaplogger.Fatal("failed to create state manager", zaplogger.Fields{"error": err})
+	}
+	return &CandleService{
+		db:             db,
+		client:         &http.Client{Timeout: 30 * time.Second},
+		repo:           repository.NewCandleRepository(db),
+		actionRepo:     repository.NewCorporateActionRepository(db),
+		instrumentRepo: repository.NewInstrumentRepository(db),
+		marketService:  NewMarketService(cfg),
+		state:          stateManager,
+	}
+}
+
+// RollupDayCandles rolls up today's minute candles, and the ticker data of the instruments without them,
+// into day candles
+// It is skipped on non trading days and when today was already rolled up, the day is claimed in the state
+// before the rollup so overlapping runs do not both roll it up, and released if the rollup fails
+func (s *CandleService) RollupDayCandles() (int64, error) {
+	now := time.Now().In(MarketLocation)
+	if !s.marketService.IsTradingDay(now) {
+		zaplogger.Info("Day candles rollup skipped, not a trading day")
+		return 0, nil
+	}
+
+	today := now.Format("2006-01-02")
+	rolledUpFor, err := s.state.Get(dayCandlesRolledUpKey)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get state: %v", err)
+	}
+	claimed := false
+	if rolledUpFor != today {
+		if claimed, err = s.state.CompareAndSet(dayCandlesRolledUpKey, rolledUpFor, today); err != nil {
+			return 0, fmt.Errorf("failed to update state: %v", err)
+		}
+	}
+	if !claimed {
+		zaplogger.Info("Day candles rollup skipped, already done", zaplogger.Fields{
+			dayCandlesRolledUpKey: today,
+		})
+		return 0, nil
+	}
+
+	dayStart := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, MarketLocation)
+	count, err := s.rollupDayCandles(dayStart)
+	if err != nil {
+		if _, releaseErr := s.state.CompareAndSet(dayCandlesRolledUpKey, today, rolledUpFor); releaseErr != nil {
+			zaplogger.Error("Failed to update state", zaplogger.Fields{
+				dayCandlesRolledUpKey: rolledUpFor,
+				"error":               releaseErr.Error(),
+			})
+		}
+		return 0, err
+	}
+	return count, nil
+}
+
+// rollupDayCandles upserts the day candles of the day starting at dayStart
+func (s *CandleService) rollupDayCandles(dayStart time.Time) (int64, error) {
+	dayEnd := dayStart.AddDate(0, 0, 1).Add(-time.Nanosecond)
+	tokens, err := s.repo.GetCandleTokens(models.CandleIntervalMinute, dayStart, dayEnd)
+	if err != nil {
+		return 0, err
+	}
+
+	candles := make([]models.CandleModel, 0, len(tokens))
+	rolledUp := make(map[uint32]bool, len(tokens))
+	for i := 0; i < len(tokens); i += 500 {
+		batch := tokens[i:min(i+500, len(tokens))]
+		minuteCandles, err := s.repo.GetTokensCandles(batch, models.CandleIntervalMinute, dayStart, dayEnd)
+		if err != nil {
+			return 0, err
+		}
+		tokenCandles := make(map[uint32][]models.CandleModel, len(batch))
+		for _, candle := range minuteCandles {
+			tokenCandles[candle.InstrumentToken] = append(tokenCandles[candle.InstrumentToken], candle)
+		}
+		for _, tc := range tokenCandles {
+			if candle, ok := dayCandleFromMinutes(tc, dayStart); ok {
+				candles = append(candles, candle)
+				rolledUp[candle.InstrumentToken] = true
+			}
+		}
+	}
+
+	// the instruments without minute candles, when the tick store is disabled, are rolled up from
+	// the ticker data of those which traded today
+	var tickerData []models.TickerData
+	err = s.db.Where("last_trade_time >= ?", dayStart).Find(&tickerData).Error
+	if err != nil {
+		return 0, fmt.Errorf("error fetching tick data from database: %v", err)
+	}
+	for i := range tickerData {
+		if rolledUp[tickerData[i].InstrumentToken] {
+			continue
+		}
+		if candle, ok := dayCandleFromTick(&tickerData[i], dayStart); ok {
+			candles = append(candles, candle)
+		}
+	}
+
+	return s.repo.UpsertCandles(candles)
+}
+
+// GetHistoricalCandles returns the day candles of the token from from to to, sorted by timestamp
+// When adjusted, the candles before each split, bonus and dividend within the range are back adjusted
+func (s *CandleService) GetHistoricalCandles(token uint32, from, to time.Time, adjusted bool) ([]models.CandleModel, error) {
+	candles, err := s.repo.GetCandles(token, models.CandleIntervalDay, from, to)
+	if err != nil {
+		return nil, err
+	}
+	if !adjusted || len(candles) < 2 {
+		return candles, nil
+	}
+	actions, err := s.actionRepo.GetCorporateActions([]uint32{token})
+	if err != nil {
+		return nil, err
+	}
+	return adjustCandles(candles, actions), nil
+}
+
+// dayCandleFromMinutes aggregates the minute candles of an instrument into its day candle, the open of the
+// first, the highest high, the lowest low, the close and OI of the last, and the summed volume
+func dayCandleFromMinutes(candles []models.CandleModel, day time.Time) (models.CandleModel, bool) {
+	if len(candles) == 0 {
+		return models.CandleModel{}, false
+	}
+	candles = slices.Clone(candles)
+	slices.SortFunc(candles, func(a, b models.CandleModel) int {
+		return a.Timestamp.Compare(b.Timestamp)
+	})
+
+	first, last := candles[0], candles[len(candles)-1]
+	dayCandle := models.CandleModel{
+		InstrumentToken: first.InstrumentToken,
+		Interval:        models.CandleIntervalDay,
+		Timestamp:       day,
+		Instrument:      last.Instrument,
+		Open:            first.Open,
+		High:            first.High,
+		Low:             first.Low,
+		Close:           last.Close,
+		OI:              last.OI,
+	}
+	for _, candle := range candles {
+		dayCandle.High = max(dayCandle.High, candle.High)
+		dayCandle.Low = min(dayCandle.Low, candle.Low)
+		dayCandle.Volume += candle.Volume
+	}
+	return dayCandle, true
+}
+
+// dayCandleFromTick builds the day candle from the tick's day OHLC
+// The feed's OHLC close is the previous day close, so the last price is used as close
+func dayCandleFromTick(tick *models.TickerData, day time.Time) (models.CandleModel, bool) {
+	ohlc, err := tick.GetOHLC()
+	if err != nil || ohlc.Open == 0 {
+		return models.CandleModel{}, false
+	}
+	return models.CandleModel{
+		InstrumentToken: tick.InstrumentToken,
+		Interval:        models.CandleIntervalDay,
+		Timestamp:       day,
+		Instrument:      tick.Instrument,
+		Open:            ohlc.Open,
+		High:            ohlc.High,
+		Low:             ohlc.Low,
+		Close:           tick.LastPrice,
+		Volume:          uint64(tick.VolumeTraded),
+		OI:              uint64(tick.OI),
+	}, true
+}
@@ -0,0 +1,136 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nsvirk/moneybotsapi/internal/models"
+	"gorm.io/datatypes"
+)
+
+func TestDayCandleFromMinutes(t *testing.T) {
+	day := time.Date(2024, 10, 15, 0, 0, 0, 0, MarketLocation)
+	minute := func(hour, min int, open, high, low, close float64, volume, oi uint64) models.CandleModel {
+		return models.CandleModel{
+			InstrumentToken: 408065,
+			Interval:        models.CandleIntervalMinute,
+			Timestamp:       time.Date(2024, 10, 15, hour, min, 0, 0, MarketLocation),
+			Instrument:      "NSE:INFY",
+			Open:            open,
+			High:            high,
+			Low:             low,
+			Close:           close,
+			Volume:          volume,
+			OI:              oi,
+		}
+	}
+
+	tests := []struct {
+		name    string
+		candles []models.CandleModel
+		want    models.CandleModel
+		wantOK  bool
+	}{
+		{
+			name: "no minute candles",
+		},
+		{
+			name:    "single minute candle",
+			candles: []models.CandleModel{minute(9, 15, 100, 102, 99, 101, 500, 10)},
+			want:    models.CandleModel{Open: 100, High: 102, Low: 99, Close: 101, Volume: 500, OI: 10},
+			wantOK:  true,
+		},
+		{
+			name: "aggregated across the day",
+			candles: []models.CandleModel{
+				minute(9, 15, 100, 102, 99, 101, 500, 10),
+				minute(9, 16, 101, 105, 100, 104, 300, 12),
+				minute(15, 29, 104, 104, 97, 98, 200, 15),
+			},
+			want:   models.CandleModel{Open: 100, High: 105, Low: 97, Close: 98, Volume: 1000, OI: 15},
+			wantOK: true,
+		},
+		{
+			name: "unsorted minute candles",
+			candles: []models.CandleModel{
+				minute(15, 29, 104, 104, 97, 98, 200, 15),
+				minute(9, 15, 100, 102, 99, 101, 500, 10),
+				minute(9, 16, 101, 105, 100, 104, 300, 12),
+			},
+			want:   models.CandleModel{Open: 100, High: 105, Low: 97, Close: 98, Volume: 1000, OI: 15},
+			wantOK: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := dayCandleFromMinutes(tt.candles, day)
+			if ok != tt.wantOK {
+				t.Fatalf("dayCandleFromMinutes() ok = %v, want %v", ok, tt.wantOK)
+			}
+			if !ok {
+				return
+			}
+			if got.Interval != models.CandleIntervalDay || !got.Timestamp.Equal(day) || got.Instrument != "NSE:INFY" {
+				t.Errorf("dayCandleFromMinutes() = %s %s %s, want %s %s NSE:INFY", got.Instrument, got.Interval, got.Timestamp, models.CandleIntervalDay, day)
+			}
+			if got.Open != tt.want.Open || got.High != tt.want.High || got.Low != tt.want.Low || got.Close != tt.want.Close ||
+				got.Volume != tt.want.Volume || got.OI != tt.want.OI {
+				t.Errorf("dayCandleFromMinutes() OHLCV OI = %v %v %v %v %v %v, want %v %v %v %v %v %v",
+					got.Open, got.High, got.Low, got.Close, got.Volume, got.OI,
+					tt.want.Open, tt.want.High, tt.want.Low, tt.want.Close, tt.want.Volume, tt.want.OI)
+			}
+		})
+	}
+}
+
+func TestDayCandleFromTick(t *testing.T) {
+	day := time.Date(2024, 10, 15, 0, 0, 0, 0, MarketLocation)
+
+	tests := []struct {
+		name   string
+		ohlc   string
+		want   models.CandleModel
+		wantOK bool
+	}{
+		{
+			name:   "day candle from the tick",
+			ohlc:   `{"open":100,"high":105,"low":97,"close":99}`,
+			want:   models.CandleModel{Open: 100, High: 105, Low: 97, Close: 98, Volume: 1000, OI: 15},
+			wantOK: true,
+		},
+		{
+			name: "not traded today",
+			ohlc: `{"open":0,"high":0,"low":0,"close":99}`,
+		},
+		{
+			name: "invalid ohlc",
+			ohlc: `not json`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tick := &models.TickerData{
+				Instrument:      "NSE:INFY",
+				InstrumentToken: 408065,
+				LastPrice:       98,
+				VolumeTraded:    1000,
+				OI:              15,
+				OHLC:            datatypes.JSON(tt.ohlc),
+			}
+			got, ok := dayCandleFromTick(tick, day)
+			if ok != tt.wantOK {
+				t.Fatalf("dayCandleFromTick() ok = %v, want %v", ok, tt.wantOK)
+			}
+			if !ok {
+				return
+			}
+			tt.want.InstrumentToken, tt.want.Instrument = 408065, "NSE:INFY"
+			tt.want.Interval, tt.want.Timestamp = models.CandleIntervalDay, day
+			if got != tt.want {
+				t.Errorf("dayCandleFromTick() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,78 @@
+// Package service contains the service layer for the Moneybots API
+package service
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/nsvirk/moneybotsapi/internal/models"
+)
+
+// continuousFutureSuffix marks an instrument as the continuous future of an underlying,
+// e.g. `NFO:NIFTY-FUT-CONTINUOUS`
+const continuousFutureSuffix = "-FUT-CONTINUOUS"
+
+// ContinuousContract is the futures contract a continuous future resolves to
+type ContinuousContract struct {
+	Instrument   string
+	Expiry       string
+	DaysToExpiry int
+}
+
+// IsContinuousFuture checks if the instrument is a continuous future
+func IsContinuousFuture(instrument string) bool {
+	return strings.HasSuffix(instrument, continuousFutureSuffix)
+}
+
+// ResolveContinuousFutures resolves the continuous futures among the instruments to their current contract
+// The nearest contract is used until FuturesRollDays days before its expiry, then the next one
+func (s *QuoteService) ResolveContinuousFutures(instruments []string) (map[string]ContinuousContract, error) {
+	now := time.Now().In(MarketLocation)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, MarketLocation)
+
+	resolved := make(map[string]ContinuousContract)
+	for _, instrument := range instruments {
+		if !IsContinuousFuture(instrument) {
+			continue
+		}
+		exchange, name, ok := strings.Cut(strings.TrimSuffix(instrument, continuousFutureSuffix), ":")
+		if !ok || exchange == "" || name == "" {
+			// left unresolved, so it is reported as not found
+			continue
+		}
+
+		contracts, err := s.instrumentRepo.GetFutureContracts(exchange, name, today.Format("2006-01-02"))
+		if err != nil {
+			return nil, fmt.Errorf("error fetching futures of %s: %v", instrument, err)
+		}
+		contract, ok := pickContinuousContract(contracts, today, s.cfg.FuturesRollDays)
+		if ok {
+			resolved[instrument] = contract
+		}
+	}
+	return resolved, nil
+}
+
+// pickContinuousContract picks the first contract, ordered by expiry, with at least rollDays days to expiry,
+// falling back to the farthest contract
+func pickContinuousContract(contracts []models.InstrumentModel, today time.Time, rollDays int) (ContinuousContract, bool) {
+	var picked ContinuousContract
+	found := false
+	for _, contract := range contracts {
+		expiry, err := time.ParseInLocation("2006-01-02", contract.Expiry, MarketLocation)
+		if err != nil {
+			continue
+		}
+		picked = ContinuousContract{
+			Instrument:   contract.Exchange + ":" + contract.Tradingsymbol,
+			Expiry:       contract.Expiry,
+			DaysToExpiry: int(expiry.Sub(today).Hours() / 24),
+		}
+		found = true
+		if picked.DaysToExpiry >= rollDays {
+			break
+		}
+	}
+	return picked, found
+}
@@ -0,0 +1,64 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nsvirk/moneybotsapi/internal/models"
+)
+
+func TestPickContinuousContract(t *testing.T) {
+	contracts := []models.InstrumentModel{
+		{Exchange: "NFO", Tradingsymbol: "NIFTY24OCTFUT", Expiry: "2024-10-31"},
+		{Exchange: "NFO", Tradingsymbol: "NIFTY24NOVFUT", Expiry: "2024-11-28"},
+		{Exchange: "NFO", Tradingsymbol: "NIFTY24DECFUT", Expiry: "2024-12-26"},
+	}
+	day := func(d string) time.Time {
+		t, _ := time.ParseInLocation("2006-01-02", d, MarketLocation)
+		return t
+	}
+
+	tests := []struct {
+		name      string
+		contracts []models.InstrumentModel
+		today     string
+		rollDays  int
+		want      string
+		wantDays  int
+		wantOK    bool
+	}{
+		{name: "nearest before the roll", contracts: contracts, today: "2024-10-25", rollDays: 2, want: "NFO:NIFTY24OCTFUT", wantDays: 6, wantOK: true},
+		{name: "rolled within the roll days", contracts: contracts, today: "2024-10-30", rollDays: 2, want: "NFO:NIFTY24NOVFUT", wantDays: 29, wantOK: true},
+		{name: "expiry day without roll days", contracts: contracts, today: "2024-10-31", rollDays: 0, want: "NFO:NIFTY24OCTFUT", wantDays: 0, wantOK: true},
+		{name: "farthest when all are within the roll days", contracts: contracts[2:], today: "2024-12-25", rollDays: 2, want: "NFO:NIFTY24DECFUT", wantDays: 1, wantOK: true},
+		{name: "invalid expiry skipped", contracts: []models.InstrumentModel{{Exchange: "NFO", Tradingsymbol: "BAD", Expiry: "31-10-2024"}, contracts[1]}, today: "2024-10-25", rollDays: 2, want: "NFO:NIFTY24NOVFUT", wantDays: 34, wantOK: true},
+		{name: "no contracts", today: "2024-10-25", rollDays: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := pickContinuousContract(tt.contracts, day(tt.today), tt.rollDays)
+			if ok != tt.wantOK || got.Instrument != tt.want || (ok && got.DaysToExpiry != tt.wantDays) {
+				t.Errorf("pickContinuousContract() = %+v, %v, want %s with %d days, %v", got, ok, tt.want, tt.wantDays, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestIsContinuousFuture(t *testing.T) {
+	tests := []struct {
+		instrument string
+		want       bool
+	}{
+		{"NFO:NIFTY-FUT-CONTINUOUS", true},
+		{"MCX:GOLD-FUT-CONTINUOUS", true},
+		{"NFO:NIFTY24OCTFUT", false},
+		{"NSE:INFY", false},
+	}
+
+	for _, tt := range tests {
+		if got := IsContinuousFuture(tt.instrument); got != tt.want {
+			t.Errorf("IsContinuousFuture(%q) = %v, want %v", tt.instrument, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,221 @@
+// Package service contains the service layer for the Moneybots API
+package service
+
+import (
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nsvirk/moneybotsapi/internal/models"
+	"github.com/nsvirk/moneybotsapi/internal/repository"
+)
+
+// corporateActionsURL is the CSV of the corporate actions, with the `SYMBOL`, `EX_DATE`, `TYPE`, `RATIO`
+// and `AMOUNT` columns and an optional `EXCHANGE` column, corporate actions are not synced when it is empty
+var corporateActionsURL = ""
+
+// defaultCorporateActionsExchange is the exchange of the corporate actions without an `EXCHANGE` column
+const defaultCorporateActionsExchange = models.ExchangeNSE
+
+// SetCorporateActionsURL sets the URL the corporate actions are synced from
+func SetCorporateActionsURL(url string) {
+	corporateActionsURL = url
+}
+
+// corporateActionRow is a row of the corporate actions CSV, before its instrument is resolved
+type corporateActionRow struct {
+	instrument string
+	action     models.CorporateActionModel
+}
+
+// UpdateCorporateActions replaces the corporate actions with the ones at the corporate actions URL
+// Actions of instruments missing from the instrument master are left out
+func (s *InstrumentService) UpdateCorporateActions() (int64, error) {
+	if corporateActionsURL == "" {
+		return 0, nil
+	}
+	resp, err := s.client.Get(corporateActionsURL)
+	if err != nil {
+		return 0, fmt.Errorf("failed to fetch corporate actions: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("failed to fetch corporate actions: unexpected status %s", resp.Status)
+	}
+
+	reader := csv.NewReader(resp.Body)
+	reader.FieldsPerRecord = -1
+	records, err := reader.ReadAll()
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse corporate actions CSV: %v", err)
+	}
+	rows, err := parseCorporateActions(records)
+	if err != nil {
+		return 0, err
+	}
+
+	symbolsByExchange := make(map[string][]string)
+	for _, row := range rows {
+		exchange, symbol, _ := strings.Cut(row.instrument, ":")
+		symbolsByExchange[exchange] = append(symbolsByExchange[exchange], symbol)
+	}
+	tokens := make(map[string]uint32, len(rows))
+	for exchange, symbols := range symbolsByExchange {
+		instruments, err := s.repo.GetInstrumentByExchangeTradingsymbols(exchange, symbols)
+		if err != nil {
+			return 0, fmt.Errorf("error fetching instruments for corporate actions: %v", err)
+		}
+		for _, instrument := range instruments {
+			tokens[instrument.Exchange+":"+instrument.Tradingsymbol] = instrument.InstrumentToken
+		}
+	}
+
+	actions := make([]models.CorporateActionModel, 0, len(rows))
+	for _, row := range rows {
+		token, ok := tokens[row.instrument]
+		if !ok {
+			continue
+		}
+		row.action.InstrumentToken = token
+		actions = append(actions, row.action)
+	}
+	return repository.NewCorporateActionRepository(s.repo.DB).ReplaceCorporateActions(actions)
+}
+
+// parseCorporateActions parses the corporate actions CSV, the columns are found by their header
+// Rows with an unknown type, an invalid ex date or a missing ratio or amount are skipped
+func parseCorporateActions(records [][]string) ([]corporateActionRow, error) {
+	if len(records) < 2 {
+		return nil, fmt.Errorf("no corporate actions received")
+	}
+	cols := map[string]int{"SYMBOL": -1, "EXCHANGE": -1, "EX_DATE": -1, "TYPE": -1, "RATIO": -1, "AMOUNT": -1}
+	for i, header := range records[0] {
+		if _, ok := cols[strings.ToUpper(strings.TrimSpace(header))]; ok {
+			cols[strings.ToUpper(strings.TrimSpace(header))] = i
+		}
+	}
+	if cols["SYMBOL"] < 0 || cols["EX_DATE"] < 0 || cols["TYPE"] < 0 {
+		return nil, fmt.Errorf("corporate actions CSV must have `SYMBOL`, `EX_DATE` and `TYPE` columns")
+	}
+	field := func(record []string, col string) string {
+		if i := cols[col]; i >= 0 && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	rows := make([]corporateActionRow, 0, len(records)-1)
+	seen := make(map[string]bool, len(records)-1)
+	for _, record := range records[1:] {
+		exchange := strings.ToUpper(field(record, "EXCHANGE"))
+		if exchange == "" {
+			exchange = string(defaultCorporateActionsExchange)
+		}
+		symbol := strings.ToUpper(field(record, "SYMBOL"))
+		exDate := field(record, "EX_DATE")
+		if symbol == "" {
+			continue
+		}
+		if _, err := time.Parse("2006-01-02", exDate); err != nil {
+			continue
+		}
+
+		action := models.CorporateActionModel{
+			Instrument: exchange + ":" + symbol,
+			ExDate:     exDate,
+			Type:       strings.ToLower(field(record, "TYPE")),
+		}
+		switch action.Type {
+		case models.CorporateActionSplit, models.CorporateActionBonus:
+			ratio, err := parseCorporateActionRatio(action.Type, field(record, "RATIO"))
+			if err != nil {
+				continue
+			}
+			action.Ratio = ratio
+		case models.CorporateActionDividend:
+			amount, err := strconv.ParseFloat(field(record, "AMOUNT"), 64)
+			if err != nil || amount <= 0 {
+				continue
+			}
+			action.Amount = amount
+		default:
+			continue
+		}
+
+		key := action.Instrument + "|" + action.ExDate + "|" + action.Type
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		rows = append(rows, corporateActionRow{instrument: action.Instrument, action: action})
+	}
+	return rows, nil
+}
+
+// parseCorporateActionRatio parses the ratio of a split or bonus into the shares held after it per share
+// held before it, a split `A:B` turns A shares into B, a bonus `A:B` gives A shares for every B held,
+// a plain number is taken as is
+func parseCorporateActionRatio(actionType, ratio string) (float64, error) {
+	before, after, isPair := strings.Cut(ratio, ":")
+	if !isPair {
+		value, err := strconv.ParseFloat(ratio, 64)
+		if err != nil || value <= 0 {
+			return 0, fmt.Errorf("invalid ratio `%s`", ratio)
+		}
+		return value, nil
+	}
+	a, errA := strconv.ParseFloat(strings.TrimSpace(before), 64)
+	b, errB := strconv.ParseFloat(strings.TrimSpace(after), 64)
+	if errA != nil || errB != nil || a <= 0 || b <= 0 {
+		return 0, fmt.Errorf("invalid ratio `%s`", ratio)
+	}
+	if actionType == models.CorporateActionBonus {
+		return (a + b) / b, nil
+	}
+	return b / a, nil
+}
+
+// GetCorporateActions returns the corporate actions of the instruments, keyed by `EXCHANGE:TRADINGSYMBOL`
+// Instruments without actions have empty lists, instruments not in the instrument master are left out
+func (s *InstrumentService) GetCorporateActions(symbols []string) (map[string]models.CorporateActions, error) {
+	instruments, err := s.GetInstrumentsInfoBySymbols(symbols)
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[string]models.CorporateActions, len(instruments))
+	if len(instruments) == 0 {
+		return result, nil
+	}
+
+	tokens := make([]uint32, 0, len(instruments))
+	byToken := make(map[uint32]*models.CorporateActions, len(instruments))
+	for _, instrument := range instruments {
+		tokens = append(tokens, instrument.InstrumentToken)
+		byToken[instrument.InstrumentToken] = &models.CorporateActions{
+			InstrumentToken: instrument.InstrumentToken,
+			Upcoming:        []models.CorporateActionModel{},
+			Past:            []models.CorporateActionModel{},
+		}
+	}
+	actions, err := repository.NewCorporateActionRepository(s.repo.DB).GetCorporateActions(tokens)
+	if err != nil {
+		return nil, err
+	}
+	today := s.clock.Now().In(MarketLocation).Format("2006-01-02")
+	for _, action := range actions {
+		tokenActions := byToken[action.InstrumentToken]
+		if action.ExDate >= today {
+			tokenActions.Upcoming = append(tokenActions.Upcoming, action)
+		} else {
+			tokenActions.Past = append(tokenActions.Past, action)
+		}
+	}
+
+	for _, instrument := range instruments {
+		result[instrument.Exchange+":"+instrument.Tradingsymbol] = *byToken[instrument.InstrumentToken]
+	}
+	return result, nil
+}
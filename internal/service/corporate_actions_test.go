@@ -0,0 +1,72 @@
+package service
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nsvirk/moneybotsapi/internal/models"
+	"github.com/nsvirk/moneybotsapi/pkg/utils/clock"
+)
+
+func TestGetCorporateActions(t *testing.T) {
+	db := instrumentsDB(t,
+		models.InstrumentModel{InstrumentToken: 408065, Tradingsymbol: "INFY", Exchange: "NSE"},
+		models.InstrumentModel{InstrumentToken: 2953217, Tradingsymbol: "TCS", Exchange: "NSE"},
+	)
+	if err := db.AutoMigrate(&models.CorporateActionModel{}); err != nil {
+		t.Fatalf("AutoMigrate() error = %v", err)
+	}
+	// inserted out of ex date order
+	actions := []models.CorporateActionModel{
+		{InstrumentToken: 408065, Instrument: "NSE:INFY", ExDate: "2024-10-25", Type: models.CorporateActionDividend, Amount: 21},
+		{InstrumentToken: 408065, Instrument: "NSE:INFY", ExDate: "2018-09-04", Type: models.CorporateActionBonus, Ratio: 2},
+		{InstrumentToken: 408065, Instrument: "NSE:INFY", ExDate: "2024-05-31", Type: models.CorporateActionDividend, Amount: 28},
+		{InstrumentToken: 408065, Instrument: "NSE:INFY", ExDate: "2024-10-15", Type: models.CorporateActionDividend, Amount: 1},
+	}
+	if err := db.Create(&actions).Error; err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	s := NewInstrumentService(db)
+	s.clock = clock.NewFake(time.Date(2024, 10, 15, 10, 0, 0, 0, MarketLocation))
+	got, err := s.GetCorporateActions([]string{"NSE:INFY", "NSE:TCS", "NSE:UNKNOWN"})
+	if err != nil {
+		t.Fatalf("GetCorporateActions() error = %v", err)
+	}
+
+	exDates := func(actions []models.CorporateActionModel) string {
+		dates := make([]string, 0, len(actions))
+		for _, action := range actions {
+			dates = append(dates, action.ExDate)
+		}
+		return strings.Join(dates, ",")
+	}
+	tests := []struct {
+		instrument   string
+		wantUpcoming string
+		wantPast     string
+	}{
+		// an action going ex today is upcoming
+		{"NSE:INFY", "2024-10-15,2024-10-25", "2018-09-04,2024-05-31"},
+		{"NSE:TCS", "", ""},
+	}
+	for _, tt := range tests {
+		actions, ok := got[tt.instrument]
+		if !ok {
+			t.Fatalf("GetCorporateActions() has no %s actions", tt.instrument)
+		}
+		if actions.Upcoming == nil || actions.Past == nil {
+			t.Errorf("%s actions = %+v, want empty lists rather than nil", tt.instrument, actions)
+		}
+		if upcoming := exDates(actions.Upcoming); upcoming != tt.wantUpcoming {
+			t.Errorf("%s upcoming ex dates = %s, want %s", tt.instrument, upcoming, tt.wantUpcoming)
+		}
+		if past := exDates(actions.Past); past != tt.wantPast {
+			t.Errorf("%s past ex dates = %s, want %s", tt.instrument, past, tt.wantPast)
+		}
+	}
+	if _, ok := got["NSE:UNKNOWN"]; ok {
+		t.Errorf("GetCorporateActions() has actions of an instrument not in the master")
+	}
+}
@@ -0,0 +1,117 @@
+// Package service contains the service layer for the Moneybots API
+package service
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// cronJobs is the status of the jobs of the started cron service, nil until it is started
+var cronJobs *cronJobRegistry
+
+// CronJobStatus is the status of a cron job and of its last run
+type CronJobStatus struct {
+	Name           string     `json:"name"`
+	Schedule       string     `json:"schedule,omitempty"`
+	NextRunAt      *time.Time `json:"next_run_at,omitempty"`
+	Running        bool       `json:"running"`
+	Runs           int        `json:"runs"`
+	Failures       int        `json:"failures"`
+	LastStartedAt  *time.Time `json:"last_started_at,omitempty"`
+	LastFinishedAt *time.Time `json:"last_finished_at,omitempty"`
+	LastDuration   string     `json:"last_duration,omitempty"`
+	LastError      string     `json:"last_error,omitempty"`
+}
+
+// GetCronJobStatuses returns the status of the jobs of the started cron service sorted by name,
+// it is empty when the cron service is not started
+func GetCronJobStatuses() []CronJobStatus {
+	if cronJobs == nil {
+		return []CronJobStatus{}
+	}
+	return cronJobs.statuses()
+}
+
+// cronJobRegistry keeps the status of the jobs by name, a job queued on startup and on a schedule has one status
+type cronJobRegistry struct {
+	mu      sync.Mutex
+	c       *cron.Cron
+	jobs    map[string]*CronJobStatus
+	entries map[string]cron.EntryID
+}
+
+func newCronJobRegistry(c *cron.Cron) *cronJobRegistry {
+	return &cronJobRegistry{
+		c:       c,
+		jobs:    make(map[string]*CronJobStatus),
+		entries: make(map[string]cron.EntryID),
+	}
+}
+
+// register adds the job, with its schedule and cron entry when it is scheduled
+func (r *cronJobRegistry) register(name, schedule string, entryID cron.EntryID) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	job := r.job(name)
+	if schedule != "" {
+		job.Schedule = schedule
+		r.entries[name] = entryID
+	}
+}
+
+// run runs the job, recording its start, duration and error
+func (r *cronJobRegistry) run(name string, job func() error) error {
+	startedAt := time.Now().In(MarketLocation)
+	r.mu.Lock()
+	status := r.job(name)
+	status.Running = true
+	status.LastStartedAt = &startedAt
+	r.mu.Unlock()
+
+	err := job()
+
+	finishedAt := time.Now().In(MarketLocation)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	status.Running = false
+	status.Runs++
+	status.LastFinishedAt = &finishedAt
+	status.LastDuration = finishedAt.Sub(startedAt).Round(time.Millisecond).String()
+	status.LastError = ""
+	if err != nil {
+		status.Failures++
+		status.LastError = err.Error()
+	}
+	return err
+}
+
+// job returns the status of the job, adding it if it is new, r.mu must be held
+func (r *cronJobRegistry) job(name string) *CronJobStatus {
+	job, ok := r.jobs[name]
+	if !ok {
+		job = &CronJobStatus{Name: name}
+		r.jobs[name] = job
+	}
+	return job
+}
+
+func (r *cronJobRegistry) statuses() []CronJobStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	statuses := make([]CronJobStatus, 0, len(r.jobs))
+	for name, job := range r.jobs {
+		status := *job
+		if entryID, ok := r.entries[name]; ok {
+			if next := r.c.Entry(entryID).Next; !next.IsZero() {
+				next = next.In(MarketLocation)
+				status.NextRunAt = &next
+			}
+		}
+		statuses = append(statuses, status)
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Name < statuses[j].Name })
+	return statuses
+}
@@ -8,6 +8,7 @@ import (
 
 	"github.com/labstack/echo/v4"
 	"github.com/nsvirk/moneybotsapi/internal/config"
+	"github.com/nsvirk/moneybotsapi/internal/repository"
 	"github.com/nsvirk/moneybotsapi/pkg/utils/zaplogger"
 	"github.com/redis/go-redis/v9"
 	"github.com/robfig/cron/v3"
@@ -25,6 +26,10 @@ type CronService struct {
 	instrumentService *InstrumentService
 	indexService      *IndexService
 	tickerService     *TickerService
+	candleService     *CandleService
+	marketService     *MarketService
+	logRepo           *repository.LogRepository
+	jobs              *cronJobRegistry
 }
 
 // NewCronService creates a new CronService
@@ -33,18 +38,26 @@ func NewCronService(e *echo.Echo, cfg *config.Config, db *gorm.DB, redisClient *
 	sessionService := NewSessionService(db)
 	instrumentService := NewInstrumentService(db)
 	indexService := NewIndexService(db)
-	tickerService := NewTickerService(db, redisClient)
+	tickerService := NewTickerService(cfg, db, redisClient)
+	candleService := NewCandleService(cfg, db)
+
+	// the jobs are scheduled in IST, whatever the server timezone
+	c := cron.New(cron.WithLocation(MarketLocation))
 
 	return &CronService{
 		e:                 e,
 		cfg:               cfg,
 		db:                db,
 		redisClient:       redisClient,
-		c:                 cron.New(),
+		c:                 c,
 		sessionService:    sessionService,
 		instrumentService: instrumentService,
 		tickerService:     tickerService,
 		indexService:      indexService,
+		candleService:     candleService,
+		marketService:     NewMarketService(cfg),
+		logRepo:           repository.NewLogRepository(db),
+		jobs:              newCronJobRegistry(c),
 	}
 }
 
@@ -56,33 +69,51 @@ func (cs *CronService) Start() {
 	// ------------------------------------------------------------
 	// Add your SCHEDULED jobs here
 	// ------------------------------------------------------------
-	cs.addScheduledJob("API Instruments UPDATE Job", cs.ApiInstrumentsUpdateJob, "0 8 * * 1-5") // Once at 08:00am, Mon-Fri
-	cs.addScheduledJob("API Indices UPDATE Job", cs.ApiIndicesUpdateJob, "1 8 * * 1-5")         // Once at 08:01am, Mon-Fri
+	cs.addScheduledJob("API Instruments UPDATE Job", cs.ApiInstrumentsUpdateJob, "0 8 * * 1-5")            // Once at 08:00am, Mon-Fri
+	cs.addScheduledJob("API Corporate Actions UPDATE Job", cs.ApiCorporateActionsUpdateJob, "5 8 * * 1-5") // Once at 08:05am, Mon-Fri
+	cs.addScheduledJob("API Indices UPDATE Job", cs.ApiIndicesUpdateJob, "15 8 * * 1-5")                   // Once at 08:15am, Mon-Fri
+	cs.addScheduledJob("Day Candles ROLLUP Job", cs.DayCandlesRollupJob, "45 15 * * 1-5")                  // Once at 03:45pm, Mon-Fri
+	if cs.cfg.LogRetention > 0 {
+		cs.addScheduledJob("Logs PRUNE Job", cs.LogsPruneJob, "30 0 * * *") // Once at 00:30am, daily
+	}
 	// cs.addScheduledJob("TickerInstruments UPDATE Job", cs.TickerInstrumentsUpdateJob, "2 8 * * 1-5") // Once at 08:02am, Mon-Fri
-	// cs.addScheduledJob("Ticker START Job", cs.TickerStartJob, "55 8	* * 1-5")                        // Once at 08:55am, Mon-Fri
-	// cs.addScheduledJob("Ticker STOP Job", cs.TickerStopJob, "59 23 * * 1-5")                         // Once at 11:59pm, Mon-Fri
+	// The ticker runs from before the 09:00am commodity open to after the 11:30pm commodity close,
+	// with the session of the configured Kite user
+	if cs.cfg.KitetickerUserID != "" && cs.cfg.KitetickerPassword != "" && cs.cfg.KitetickerTotpSecret != "" {
+		cs.addScheduledJob("Ticker START Job", cs.TickerStartJob, "55 8 * * 1-5") // Once at 08:55am, Mon-Fri
+		cs.addScheduledJob("Ticker STOP Job", cs.TickerStopJob, "45 23 * * 1-5")  // Once at 11:45pm, Mon-Fri
+	}
 
 	// ------------------------------------------------------------
 	// Add your STARTUP jobs here
 	// ------------------------------------------------------------
 	cs.addStartupJob("API Instruments UPDATE Job", cs.ApiInstrumentsUpdateJob, 1*time.Second)
 	cs.addStartupJob("API Indices UPDATE Job", cs.ApiIndicesUpdateJob, 5*time.Second)
+	cs.addStartupJob("API Corporate Actions UPDATE Job", cs.ApiCorporateActionsUpdateJob, 15*time.Second)
 	// cs.addStartupJob("TickerInstruments UPDATE Job", cs.TickerInstrumentsUpdateJob, 19*time.Second)
 	// cs.addStartupJob("TickerData TRUNCATE Job", cs.TickerDataTruncateJob, 25*time.Second)
 	// cs.addStartupJob("Ticker START Job", cs.TickerStartJob, 28*time.Second)
 	// ------------------------------------------------------------
 
 	cs.c.Start()
+	cronJobs = cs.jobs
 }
 
 // addStartupJob adds a startup job to the cron service
-func (cs *CronService) addStartupJob(name string, job func(), delay time.Duration) {
+func (cs *CronService) addStartupJob(name string, job func() error, delay time.Duration) {
+	cs.jobs.register(name, "", 0)
 	go func() {
 		time.Sleep(delay)
 		zaplogger.Info("STARTED STARTUP job", zaplogger.Fields{
 			"job": name,
 		})
-		job()
+		if err := cs.jobs.run(name, job); err != nil {
+			zaplogger.Error("FAILED STARTUP job", zaplogger.Fields{
+				"job":   name,
+				"error": err.Error(),
+			})
+			return
+		}
 		zaplogger.Info("COMPLETED STARTUP job", zaplogger.Fields{
 			"job": name,
 		})
@@ -92,12 +123,19 @@ func (cs *CronService) addStartupJob(name string, job func(), delay time.Duratio
 	})
 }
 
-func (cs *CronService) addScheduledJob(name string, job func(), schedule string) {
-	_, err := cs.c.AddFunc(schedule, func() {
+// addScheduledJob adds a job run on the cron schedule, in IST
+func (cs *CronService) addScheduledJob(name string, job func() error, schedule string) {
+	entryID, err := cs.c.AddFunc(schedule, func() {
 		zaplogger.Info("STARTED SCHEDULED JOB", zaplogger.Fields{
 			"job": name,
 		})
-		job()
+		if err := cs.jobs.run(name, job); err != nil {
+			zaplogger.Error("FAILED SCHEDULED JOB", zaplogger.Fields{
+				"job":   name,
+				"error": err.Error(),
+			})
+			return
+		}
 		zaplogger.Info("COMPLETED SCHEDULED JOB", zaplogger.Fields{
 			"job": name,
 		})
@@ -109,45 +147,107 @@ func (cs *CronService) addScheduledJob(name string, job func(), schedule string)
 		})
 		return
 	}
+	cs.jobs.register(name, schedule, entryID)
 	zaplogger.Info("QUEUED SCHEDULED job", zaplogger.Fields{
 		"job": name,
 	})
 }
 
 // ApiInstrumentsUpdateJob updates the instruments from the API
-func (cs *CronService) ApiInstrumentsUpdateJob() {
+func (cs *CronService) ApiInstrumentsUpdateJob() error {
 	jobName := "API Instruments UPDATE Job "
 
-	rowsInserted, err := cs.instrumentService.UpdateInstruments()
+	report, err := cs.instrumentService.UpdateInstruments()
 	if err != nil {
 		zaplogger.Error(jobName, zaplogger.Fields{
 			"error": err.Error(),
 		})
-		return
+		return err
+	}
+	zaplogger.Info(jobName, zaplogger.Fields{
+		"rows_inserted": strconv.FormatInt(report.Records, 10),
+		"rows_skipped":  strconv.Itoa(report.Skipped),
+		"unchanged":     strconv.FormatBool(report.Unchanged),
+	})
+	return nil
+}
+
+// ApiCorporateActionsUpdateJob updates the corporate actions, it runs after the instruments update
+// as the actions are resolved to instruments of the instrument master
+func (cs *CronService) ApiCorporateActionsUpdateJob() error {
+	jobName := "API Corporate Actions UPDATE Job "
+
+	rowsInserted, err := cs.instrumentService.UpdateCorporateActions()
+	if err != nil {
+		zaplogger.Error(jobName, zaplogger.Fields{
+			"error": err.Error(),
+		})
+		return err
 	}
 	zaplogger.Info(jobName, zaplogger.Fields{
 		"rows_inserted": strconv.FormatInt(rowsInserted, 10),
 	})
+	return nil
 }
 
 // ApiIndicesUpdateJob updates the indices from the APIx
-func (cs *CronService) ApiIndicesUpdateJob() {
+func (cs *CronService) ApiIndicesUpdateJob() error {
 	jobName := "API Indices UPDATE Job "
 	rowsInserted, err := cs.indexService.UpdateIndices()
 	if err != nil {
 		zaplogger.Error(jobName, zaplogger.Fields{
 			"error": err.Error(),
 		})
-		return
+		return err
 	}
 	zaplogger.Info(jobName, zaplogger.Fields{
 		"rows_inserted": strconv.FormatInt(rowsInserted, 10),
 	})
+	return nil
+}
+
+// DayCandlesRollupJob rolls up today's minute candles into day candles
+func (cs *CronService) DayCandlesRollupJob() error {
+	jobName := "Day Candles ROLLUP Job "
+	rowsUpserted, err := cs.candleService.RollupDayCandles()
+	if err != nil {
+		zaplogger.Error(jobName, zaplogger.Fields{
+			"error": err.Error(),
+		})
+		return err
+	}
+	zaplogger.Info(jobName, zaplogger.Fields{
+		"rows_upserted": strconv.FormatInt(rowsUpserted, 10),
+	})
+	return nil
+}
+
+// LogsPruneJob deletes the app logs older than the log retention
+func (cs *CronService) LogsPruneJob() error {
+	jobName := "Logs PRUNE Job "
+	rowsDeleted, err := cs.logRepo.DeleteLogsBefore(time.Now().Add(-cs.cfg.LogRetention))
+	if err != nil {
+		zaplogger.Error(jobName, zaplogger.Fields{
+			"error": err.Error(),
+		})
+		return err
+	}
+	zaplogger.Info(jobName, zaplogger.Fields{
+		"rows_deleted": strconv.FormatInt(rowsDeleted, 10),
+	})
+	return nil
 }
 
 // TickerStartJob starts the ticker
-func (cs *CronService) TickerStartJob() {
+func (cs *CronService) TickerStartJob() error {
 	jobName := "Ticker START Job "
+	if !cs.marketService.IsTradingDay(time.Now()) {
+		zaplogger.Info(jobName, zaplogger.Fields{
+			"step": "Skipped, not a trading day",
+		})
+		return nil
+	}
+
 	// Generate the session
 	userId := cs.cfg.KitetickerUserID
 	password := cs.cfg.KitetickerPassword
@@ -160,7 +260,7 @@ func (cs *CronService) TickerStartJob() {
 			"step":  "GenerateTOTP",
 			"error": err.Error(),
 		})
-		return
+		return err
 	}
 
 	// Generate a new session
@@ -173,7 +273,7 @@ func (cs *CronService) TickerStartJob() {
 			"totp_secret": totpSecret[:8] + "..." + totpSecret[len(totpSecret)-8:],
 			"error":       err.Error(),
 		})
-		return
+		return err
 	}
 	zaplogger.Info(jobName, zaplogger.Fields{
 		"step":       "GenerateSession",
@@ -189,15 +289,16 @@ func (cs *CronService) TickerStartJob() {
 			"step":  "TickerStart",
 			"error": err.Error(),
 		})
-		return
+		return err
 	}
 	zaplogger.Info(jobName, zaplogger.Fields{
 		"step": "TickerStart",
 	})
+	return nil
 }
 
 // TickerStopJob stops the ticker
-func (cs *CronService) TickerStopJob() {
+func (cs *CronService) TickerStopJob() error {
 	jobName := "Ticker STOP Job "
 	// Stop the ticker
 	userId := cs.cfg.KitetickerUserID
@@ -207,27 +308,29 @@ func (cs *CronService) TickerStopJob() {
 			"step":  "TickerStop",
 			"error": err.Error(),
 		})
-		return
+		return err
 	}
 	zaplogger.Info(jobName, zaplogger.Fields{
 		"step": "TickerStop",
 	})
+	return nil
 }
 
 // TickerDataTruncateJob truncates the ticker data
-func (cs *CronService) TickerDataTruncateJob() {
+func (cs *CronService) TickerDataTruncateJob() error {
 	jobName := "TickerData TRUNCATE Job "
 	// Truncate the table
 	if err := cs.tickerService.TruncateTickerData(); err != nil {
 		zaplogger.Error(jobName, zaplogger.Fields{
 			"error": err.Error(),
 		})
-		return
+		return err
 	}
+	return nil
 }
 
 // TickerInstrumentsUpdateJob updates the ticker instruments
-func (cs *CronService) TickerInstrumentsUpdateJob() {
+func (cs *CronService) TickerInstrumentsUpdateJob() error {
 	jobName := "TickerInstruments UPDATE Job "
 	userId := cs.cfg.KitetickerUserID
 	var grandTotalInserted int64 = 0
@@ -239,7 +342,7 @@ func (cs *CronService) TickerInstrumentsUpdateJob() {
 			"step":  "TruncateTickerInstruments",
 			"error": err.Error(),
 		})
-		return
+		return err
 	}
 	zaplogger.Info(jobName, zaplogger.Fields{
 		"step":            "TruncateTickerInstruments",
@@ -331,7 +434,7 @@ func (cs *CronService) TickerInstrumentsUpdateJob() {
 			"step":  "GetIndexNames",
 			"error": err.Error(),
 		})
-		return
+		return err
 	}
 	var idxCount int64 = 0
 	var idxQueried, idxInserted, idxUpdated, idxTotal int64 = 0, 0, 0, 0
@@ -401,12 +504,13 @@ func (cs *CronService) TickerInstrumentsUpdateJob() {
 			"step":  "GetTickerInstrumentCount",
 			"error": err.Error(),
 		})
-		return
+		return err
 	}
 
 	zaplogger.Info(jobName, zaplogger.Fields{
 		"total_ticker_instruments": strconv.FormatInt(totalTickerInstruments, 10),
 	})
+	return nil
 }
 
 // // getNFOFilterMonths gets the NFO filter months
@@ -0,0 +1,134 @@
+// Package service contains the service layer for the Moneybots API
+package service
+
+import (
+	"runtime"
+	"runtime/debug"
+	"time"
+
+	"github.com/nsvirk/moneybotsapi/internal/config"
+	"github.com/nsvirk/moneybotsapi/internal/repository"
+	"github.com/nsvirk/moneybotsapi/pkg/utils/state"
+	"github.com/nsvirk/moneybotsapi/pkg/utils/zaplogger"
+	"gorm.io/gorm"
+)
+
+// Diagnostics is the bundle of runtime state gathered for triaging support tickets
+// A section which cannot be gathered carries its error instead of failing the bundle
+type Diagnostics struct {
+	Build       DiagnosticsBuild         `json:"build"`
+	Config      map[string]string        `json:"config"`
+	Database    map[string]interface{}   `json:"database"`
+	Instruments map[string]interface{}   `json:"instruments"`
+	Caches      map[string]CacheStats    `json:"caches"`
+	Streams     StreamStats              `json:"streams"`
+	Feed        HealthStatus             `json:"feed"`
+	Errors      []zaplogger.ErrorEvent   `json:"errors"`
+	LogSink     zaplogger.DBCircuitStats `json:"log_sink"`
+	LogQueue    zaplogger.DBWriterStats  `json:"log_queue"`
+	GeneratedAt time.Time                `json:"generated_at"`
+}
+
+// DiagnosticsBuild is the build and runtime information of the server
+type DiagnosticsBuild struct {
+	APIName     string `json:"api_name"`
+	APIVersion  string `json:"api_version"`
+	GoVersion   string `json:"go_version"`
+	VCSRevision string `json:"vcs_revision,omitempty"`
+	VCSTime     string `json:"vcs_time,omitempty"`
+	Goroutines  int    `json:"goroutines"`
+}
+
+// DiagnosticsService gathers the diagnostics bundle
+type DiagnosticsService struct {
+	cfg            *config.Config
+	db             *gorm.DB
+	instrumentRepo *repository.InstrumentRepository
+	healthService  *HealthService
+	streamService  *StreamService
+}
+
+// NewDiagnosticsService creates a new DiagnosticsService
+func NewDiagnosticsService(cfg *config.Config, db *gorm.DB, streamService *StreamService) *DiagnosticsService {
+	return &DiagnosticsService{
+		cfg:            cfg,
+		db:             db,
+		instrumentRepo: repository.NewInstrumentRepository(db),
+		healthService:  NewHealthService(cfg, db),
+		streamService:  streamService,
+	}
+}
+
+// GetDiagnostics gathers the diagnostics bundle, the config is masked
+func (s *DiagnosticsService) GetDiagnostics() Diagnostics {
+	diagnostics := Diagnostics{
+		Build:       s.getBuild(),
+		Config:      s.cfg.Masked(),
+		Database:    s.getDatabase(),
+		Instruments: s.getInstruments(),
+		Caches:      GetCacheStats(),
+		Errors:      zaplogger.RecentErrors(),
+		LogSink:     zaplogger.GetDBCircuitStats(),
+		LogQueue:    zaplogger.GetDBWriterStats(),
+		GeneratedAt: time.Now(),
+	}
+	if s.streamService != nil {
+		diagnostics.Streams = s.streamService.Stats()
+	}
+	diagnostics.Feed = s.healthService.GetHealth()
+	return diagnostics
+}
+
+// getBuild returns the build information embedded in the binary
+func (s *DiagnosticsService) getBuild() DiagnosticsBuild {
+	build := DiagnosticsBuild{
+		APIName:    s.cfg.APIName,
+		APIVersion: s.cfg.APIVersion,
+		GoVersion:  runtime.Version(),
+		Goroutines: runtime.NumGoroutine(),
+	}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range info.Settings {
+			switch setting.Key {
+			case "vcs.revision":
+				build.VCSRevision = setting.Value
+			case "vcs.time":
+				build.VCSTime = setting.Value
+			}
+		}
+	}
+	return build
+}
+
+// getDatabase returns the connection pool stats
+func (s *DiagnosticsService) getDatabase() map[string]interface{} {
+	sqlDB, err := s.db.DB()
+	if err != nil {
+		return map[string]interface{}{"error": err.Error()}
+	}
+	stats := sqlDB.Stats()
+	return map[string]interface{}{
+		"pool_max_open":   stats.MaxOpenConnections,
+		"pool_open":       stats.OpenConnections,
+		"pool_in_use":     stats.InUse,
+		"pool_idle":       stats.Idle,
+		"pool_wait_count": stats.WaitCount,
+		"pool_wait_time":  stats.WaitDuration.String(),
+	}
+}
+
+// getInstruments returns the instrument master record count and when it was last updated
+func (s *DiagnosticsService) getInstruments() map[string]interface{} {
+	instruments := make(map[string]interface{})
+	if count, err := s.instrumentRepo.GetInstrumentsRecordCount(); err != nil {
+		instruments["error"] = err.Error()
+	} else {
+		instruments["records"] = count
+	}
+	if stateManager, err := state.NewState(s.db); err == nil {
+		if updatedAt, err := stateManager.Get(instrumentsUpdatedAtKey); err == nil && updatedAt != "" {
+			instruments["updated_at"] = updatedAt
+		}
+	}
+	return instruments
+}
@@ -0,0 +1,184 @@
+// Package service contains the service layer for the Moneybots API
+package service
+
+import (
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/nsvirk/moneybotsapi/internal/models"
+	"github.com/nsvirk/moneybotsapi/internal/repository"
+	"github.com/nsvirk/moneybotsapi/pkg/utils/zaplogger"
+)
+
+// freezeLimitsURL is the CSV of the freeze quantities, with the `SYMBOL` and `VOL_FRZ_QTY` columns
+// of the NSE file and an optional `EXCHANGE` column, freeze limits are not synced when it is empty
+var freezeLimitsURL = ""
+
+// defaultFreezeLimitsExchange is the exchange of the freeze limits without an `EXCHANGE` column
+const defaultFreezeLimitsExchange = models.ExchangeNFO
+
+// SetFreezeLimitsURL sets the URL the freeze limits are synced from along with the instruments
+func SetFreezeLimitsURL(url string) {
+	freezeLimitsURL = url
+}
+
+// UpdateFreezeLimits replaces the freeze limits with the ones at the freeze limits URL
+func (s *InstrumentService) UpdateFreezeLimits() (int64, error) {
+	if freezeLimitsURL == "" {
+		return 0, nil
+	}
+	resp, err := s.client.Get(freezeLimitsURL)
+	if err != nil {
+		return 0, fmt.Errorf("failed to fetch freeze limits: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("failed to fetch freeze limits: unexpected status %s", resp.Status)
+	}
+
+	reader := csv.NewReader(resp.Body)
+	reader.FieldsPerRecord = -1
+	records, err := reader.ReadAll()
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse freeze limits CSV: %v", err)
+	}
+	limits, err := parseFreezeLimits(records)
+	if err != nil {
+		return 0, err
+	}
+	return s.repo.ReplaceFreezeLimits(limits)
+}
+
+// syncFreezeLimits updates the freeze limits, a failure is logged and leaves the previous limits in place
+func (s *InstrumentService) syncFreezeLimits() {
+	total, err := s.UpdateFreezeLimits()
+	if err != nil {
+		zaplogger.Warn("Freeze limits update failed", zaplogger.Fields{"error": err})
+		return
+	}
+	if total > 0 {
+		zaplogger.Info("Freeze limits updated", zaplogger.Fields{"totalInserted": total})
+	}
+}
+
+// parseFreezeLimits parses the freeze limits CSV, the columns are found by their header
+func parseFreezeLimits(records [][]string) ([]models.FreezeLimitModel, error) {
+	if len(records) < 2 {
+		return nil, fmt.Errorf("no freeze limits received")
+	}
+	symbolCol, qtyCol, exchangeCol := -1, -1, -1
+	for i, header := range records[0] {
+		switch strings.ToUpper(strings.TrimSpace(header)) {
+		case "SYMBOL":
+			symbolCol = i
+		case "VOL_FRZ_QTY":
+			qtyCol = i
+		case "EXCHANGE":
+			exchangeCol = i
+		}
+	}
+	if symbolCol < 0 || qtyCol < 0 {
+		return nil, fmt.Errorf("freeze limits CSV must have `SYMBOL` and `VOL_FRZ_QTY` columns")
+	}
+
+	limits := make([]models.FreezeLimitModel, 0, len(records)-1)
+	seen := make(map[string]bool, len(records)-1)
+	for _, record := range records[1:] {
+		if len(record) <= symbolCol || len(record) <= qtyCol {
+			continue
+		}
+		name := strings.ToUpper(strings.TrimSpace(record[symbolCol]))
+		qty, err := strconv.ParseUint(strings.TrimSpace(record[qtyCol]), 10, 32)
+		if name == "" || err != nil || qty == 0 {
+			continue
+		}
+		exchange := string(defaultFreezeLimitsExchange)
+		if exchangeCol >= 0 && exchangeCol < len(record) && strings.TrimSpace(record[exchangeCol]) != "" {
+			exchange = strings.ToUpper(strings.TrimSpace(record[exchangeCol]))
+		}
+		if seen[exchange+":"+name] {
+			continue
+		}
+		seen[exchange+":"+name] = true
+		limits = append(limits, models.FreezeLimitModel{Exchange: exchange, Name: name, FreezeQty: uint32(qty)})
+	}
+	return limits, nil
+}
+
+// getFreezeLimits returns the freeze quantities of the derivative instruments, by instrument token
+// Instruments of other exchanges and underlyings without a freeze limit are left out
+func getFreezeLimits(repo *repository.InstrumentRepository, instruments []models.InstrumentModel) (map[uint32]uint32, error) {
+	namesByExchange := make(map[string][]string)
+	for _, instrument := range instruments {
+		if models.Exchange(instrument.Exchange).IsDerivative() {
+			namesByExchange[instrument.Exchange] = append(namesByExchange[instrument.Exchange], instrument.Name)
+		}
+	}
+
+	freezeQty := make(map[string]uint32)
+	for exchange, names := range namesByExchange {
+		limits, err := repo.GetFreezeLimits(exchange, names)
+		if err != nil {
+			return nil, fmt.Errorf("error fetching freeze limits: %v", err)
+		}
+		for _, limit := range limits {
+			freezeQty[limit.Exchange+":"+limit.Name] = limit.FreezeQty
+		}
+	}
+
+	limits := make(map[uint32]uint32)
+	for _, instrument := range instruments {
+		if qty, ok := freezeQty[instrument.Exchange+":"+instrument.Name]; ok {
+			limits[instrument.InstrumentToken] = qty
+		}
+	}
+	return limits, nil
+}
+
+// GetInstrumentLimits returns the order quantity limits of the instruments, keyed by `EXCHANGE:TRADINGSYMBOL`
+// Instruments not in the instrument master are left out
+func (s *InstrumentService) GetInstrumentLimits(symbols []string) (map[string]models.InstrumentLimits, error) {
+	instruments, err := s.GetInstrumentsInfoBySymbols(symbols)
+	if err != nil {
+		return nil, err
+	}
+	freezeLimits, err := getFreezeLimits(s.repo, instruments)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]models.InstrumentLimits, len(instruments))
+	for _, instrument := range instruments {
+		limits := models.InstrumentLimits{
+			InstrumentToken: instrument.InstrumentToken,
+			LotSize:         instrument.LotSize,
+		}
+		if qty, ok := freezeLimits[instrument.InstrumentToken]; ok {
+			limits.FreezeQuantity = &qty
+		}
+		result[instrument.Exchange+":"+instrument.Tradingsymbol] = limits
+	}
+	return result, nil
+}
+
+// GetFreezeLimits returns the freeze quantities of the derivative ticks, by instrument token
+func (s *QuoteService) GetFreezeLimits(tickDataMap map[string]*models.TickerData) (map[uint32]uint32, error) {
+	tokens := make([]uint32, 0)
+	for instrument, tick := range tickDataMap {
+		if models.InstrumentExchange(instrument).IsDerivative() {
+			tokens = append(tokens, tick.InstrumentToken)
+		}
+	}
+	if len(tokens) == 0 {
+		return nil, nil
+	}
+
+	instruments, err := s.instrumentRepo.GetInstrumentsByTokens(tokens)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching instruments for freeze limits: %v", err)
+	}
+	return getFreezeLimits(s.instrumentRepo, instruments)
+}
@@ -0,0 +1,109 @@
+// Package service contains the service layer for the Moneybots API
+package service
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/nsvirk/moneybotsapi/internal/config"
+	"github.com/nsvirk/moneybotsapi/internal/models"
+	"gorm.io/gorm"
+)
+
+// HealthStatus is the health status of the API
+type HealthStatus struct {
+	Status        string `json:"status"`
+	MarketOpen    bool   `json:"market_open"`
+	FeedLive      bool   `json:"feed_live"`
+	LastTradeTime string `json:"last_trade_time,omitempty"`
+	FeedError     string `json:"feed_error,omitempty"`
+	Timestamp     string `json:"timestamp"`
+}
+
+// HealthService is the service for the health API
+type HealthService struct {
+	cfg               *config.Config
+	db                *gorm.DB
+	marketService     *MarketService
+	sampleInstruments []string
+}
+
+// NewHealthService creates a new HealthService
+func NewHealthService(cfg *config.Config, db *gorm.DB) *HealthService {
+	sampleInstruments := make([]string, 0)
+	for _, instrument := range strings.Split(cfg.FeedSampleInstr, ",") {
+		if instrument = strings.TrimSpace(instrument); instrument != "" {
+			sampleInstruments = append(sampleInstruments, instrument)
+		}
+	}
+	return &HealthService{
+		cfg:               cfg,
+		db:                db,
+		marketService:     NewMarketService(cfg),
+		sampleInstruments: sampleInstruments,
+	}
+}
+
+// GetHealth returns the health status, it is the liveness of the API so it is always ok while the API serves
+// The feed is live only if the calendar says the market is open and
+// the sample instruments have traded recently, a failure to read them is reported as the feed error
+func (s *HealthService) GetHealth() HealthStatus {
+	now := time.Now()
+
+	status := HealthStatus{
+		Status:     "ok",
+		MarketOpen: s.marketService.IsMarketOpen(now),
+		Timestamp:  now.In(MarketLocation).Format("2006-01-02 15:04:05"),
+	}
+	lastTradeTimes, err := s.getSampleLastTradeTimes()
+	if err != nil {
+		status.FeedError = err.Error()
+		return status
+	}
+	if latest := latestTime(lastTradeTimes); !latest.IsZero() {
+		status.LastTradeTime = latest.In(MarketLocation).Format("2006-01-02 15:04:05")
+	}
+	status.FeedLive = status.MarketOpen && InferFeedLive(lastTradeTimes, now, s.cfg.FeedStaleAfter)
+
+	return status
+}
+
+// getSampleLastTradeTimes gets the last trade times of the sample instruments
+func (s *HealthService) getSampleLastTradeTimes() ([]time.Time, error) {
+	if len(s.sampleInstruments) == 0 {
+		return nil, nil
+	}
+	var tickerData []models.TickerData
+	err := s.db.Select("instrument, last_trade_time").
+		Where("instrument IN ?", s.sampleInstruments).
+		Find(&tickerData).Error
+	if err != nil {
+		return nil, fmt.Errorf("error fetching sample last trade times: %v", err)
+	}
+	lastTradeTimes := make([]time.Time, len(tickerData))
+	for i, tick := range tickerData {
+		lastTradeTimes[i] = tick.LastTradeTime
+	}
+	return lastTradeTimes, nil
+}
+
+// InferFeedLive infers if the feed is live from the most recent last trade time
+func InferFeedLive(lastTradeTimes []time.Time, now time.Time, staleAfter time.Duration) bool {
+	latest := latestTime(lastTradeTimes)
+	if latest.IsZero() {
+		return false
+	}
+	return now.Sub(latest) <= staleAfter
+}
+
+// latestTime returns the most recent of the given times
+func latestTime(times []time.Time) time.Time {
+	var latest time.Time
+	for _, t := range times {
+		if t.After(latest) {
+			latest = t
+		}
+	}
+	return latest
+}
@@ -0,0 +1,60 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nsvirk/moneybotsapi/internal/config"
+)
+
+func TestInferFeedLive(t *testing.T) {
+	now := time.Date(2024, 10, 15, 11, 0, 0, 0, MarketLocation)
+	tests := []struct {
+		name           string
+		lastTradeTimes []time.Time
+		want           bool
+	}{
+		{name: "no trades", want: false},
+		{name: "unset trade times", lastTradeTimes: []time.Time{{}, {}}, want: false},
+		{name: "recent trade", lastTradeTimes: []time.Time{now.Add(-30 * time.Second)}, want: true},
+		{name: "trade at the stale limit", lastTradeTimes: []time.Time{now.Add(-2 * time.Minute)}, want: true},
+		{name: "stale trade", lastTradeTimes: []time.Time{now.Add(-2*time.Minute - time.Second)}, want: false},
+		{name: "latest of the samples", lastTradeTimes: []time.Time{now.Add(-time.Hour), now.Add(-10 * time.Second), {}}, want: true},
+		{name: "previous day", lastTradeTimes: []time.Time{now.Add(-20 * time.Hour)}, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := InferFeedLive(tt.lastTradeTimes, now, 2*time.Minute); got != tt.want {
+				t.Errorf("InferFeedLive() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsMarketOpen(t *testing.T) {
+	s := NewMarketService(&config.Config{MarketHolidays: "2024-10-02, 2024-11-01"})
+	tests := []struct {
+		name string
+		t    time.Time
+		want bool
+	}{
+		{name: "before the open", t: time.Date(2024, 10, 15, 9, 14, 59, 0, MarketLocation), want: false},
+		{name: "at the open", t: time.Date(2024, 10, 15, 9, 15, 0, 0, MarketLocation), want: true},
+		{name: "before the close", t: time.Date(2024, 10, 15, 15, 29, 59, 0, MarketLocation), want: true},
+		{name: "at the close", t: time.Date(2024, 10, 15, 15, 30, 0, 0, MarketLocation), want: false},
+		{name: "utc during the session", t: time.Date(2024, 10, 15, 5, 0, 0, 0, time.UTC), want: true},
+		{name: "saturday", t: time.Date(2024, 10, 19, 11, 0, 0, 0, MarketLocation), want: false},
+		{name: "sunday", t: time.Date(2024, 10, 20, 11, 0, 0, 0, MarketLocation), want: false},
+		{name: "holiday", t: time.Date(2024, 10, 2, 11, 0, 0, 0, MarketLocation), want: false},
+		{name: "second holiday", t: time.Date(2024, 11, 1, 11, 0, 0, 0, MarketLocation), want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := s.IsMarketOpen(tt.t); got != tt.want {
+				t.Errorf("IsMarketOpen(%v) = %v, want %v", tt.t, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,171 @@
+// Package service contains the service layer for the Moneybots API
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/nsvirk/moneybotsapi/internal/models"
+)
+
+// kiteHistoricalURL is the Kite historical candles API, authorized with the user's enctoken
+var kiteHistoricalURL = "https://kite.zerodha.com/oms/instruments/historical"
+
+// kiteHistoricalTimeLayout is the layout of the candle timestamps and the range of the historical API
+const (
+	kiteHistoricalTimeLayout  = "2006-01-02T15:04:05-0700"
+	kiteHistoricalRangeLayout = "2006-01-02 15:04:05"
+)
+
+// ErrHistoricalUpstream is returned when the candles could not be fetched from the historical API
+var ErrHistoricalUpstream = errors.New("historical API error")
+
+// kiteHistoricalResponse is the response of the historical API,
+// a candle is `[timestamp, open, high, low, close, volume, oi]`
+type kiteHistoricalResponse struct {
+	Status  string `json:"status"`
+	Message string `json:"message"`
+	Data    struct {
+		Candles [][]interface{} `json:"candles"`
+	} `json:"data"`
+}
+
+// GetIntervalCandles returns the candles of the token for the interval on the days from from to to, sorted by timestamp
+// Days fetched in full before are served from the candles table, the other days up to today are fetched from
+// the historical API with the user's enctoken and stored, today is fetched again on each request
+// It returns nil if the token is not in the instrument master
+func (s *CandleService) GetIntervalCandles(enctoken string, token uint32, interval string, from, to time.Time) ([]models.CandleModel, error) {
+	instruments, err := s.instrumentRepo.GetInstrumentsByTokens([]uint32{token})
+	if err != nil {
+		return nil, fmt.Errorf("error fetching instrument %d: %v", token, err)
+	}
+	if len(instruments) == 0 {
+		return nil, nil
+	}
+	instrument := instruments[0].Exchange + ":" + instruments[0].Tradingsymbol
+
+	now := time.Now().In(MarketLocation)
+	today := now.Format("2006-01-02")
+	covered, err := s.repo.GetCoveredDays(token, interval, from.Format("2006-01-02"), to.Format("2006-01-02"))
+	if err != nil {
+		return nil, err
+	}
+
+	// the days after today have no candles yet
+	var firstMissing, lastMissing time.Time
+	for day := from; !day.After(to) && day.Format("2006-01-02") <= today; day = day.AddDate(0, 0, 1) {
+		if covered[day.Format("2006-01-02")] {
+			continue
+		}
+		if firstMissing.IsZero() {
+			firstMissing = day
+		}
+		lastMissing = day
+	}
+
+	if !firstMissing.IsZero() {
+		candles, err := s.fetchKiteCandles(enctoken, token, instrument, interval, firstMissing, lastMissing)
+		if err != nil {
+			return nil, err
+		}
+		// the fetched days are covered, other than today whose candles are still forming
+		var coveredDays []string
+		for day := firstMissing; !day.After(lastMissing); day = day.AddDate(0, 0, 1) {
+			if day.Format("2006-01-02") < today {
+				coveredDays = append(coveredDays, day.Format("2006-01-02"))
+			}
+		}
+		if _, err := s.repo.UpsertFetchedCandles(candles, token, interval, coveredDays); err != nil {
+			return nil, err
+		}
+	}
+
+	candles, err := s.repo.GetCandles(token, interval, from, to.AddDate(0, 0, 1).Add(-time.Nanosecond))
+	if err != nil {
+		return nil, err
+	}
+	if candles == nil {
+		candles = []models.CandleModel{}
+	}
+	return candles, nil
+}
+
+// fetchKiteCandles fetches the candles of the token for the interval on the days from from to to from the historical API
+func (s *CandleService) fetchKiteCandles(enctoken string, token uint32, instrument, interval string, from, to time.Time) ([]models.CandleModel, error) {
+	params := url.Values{}
+	params.Set("from", from.Format(kiteHistoricalRangeLayout))
+	params.Set("to", to.AddDate(0, 0, 1).Add(-time.Second).Format(kiteHistoricalRangeLayout))
+	params.Set("oi", "1")
+	reqURL := fmt.Sprintf("%s/%d/%s?%s", kiteHistoricalURL, token, interval, params.Encode())
+
+	req, err := http.NewRequest(http.MethodGet, reqURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create historical request: %v", err)
+	}
+	req.Header.Set("Authorization", "enctoken "+enctoken)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to fetch candles: %v", ErrHistoricalUpstream, err)
+	}
+	defer resp.Body.Close()
+
+	var historical kiteHistoricalResponse
+	if err := json.NewDecoder(resp.Body).Decode(&historical); err != nil {
+		return nil, fmt.Errorf("%w: failed to parse candles, status %s: %v", ErrHistoricalUpstream, resp.Status, err)
+	}
+	if resp.StatusCode != http.StatusOK || historical.Status != "success" {
+		return nil, fmt.Errorf("%w: %s: %s", ErrHistoricalUpstream, resp.Status, historical.Message)
+	}
+
+	candles := make([]models.CandleModel, 0, len(historical.Data.Candles))
+	for _, row := range historical.Data.Candles {
+		candle, err := candleFromKiteRow(row)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrHistoricalUpstream, err)
+		}
+		candle.InstrumentToken = token
+		candle.Interval = interval
+		candle.Instrument = instrument
+		candles = append(candles, candle)
+	}
+	return candles, nil
+}
+
+// candleFromKiteRow parses a `[timestamp, open, high, low, close, volume, oi]` candle of the historical API,
+// the oi is only present for derivatives
+func candleFromKiteRow(row []interface{}) (models.CandleModel, error) {
+	if len(row) < 6 {
+		return models.CandleModel{}, fmt.Errorf("invalid candle %v", row)
+	}
+	timestampStr, ok := row[0].(string)
+	if !ok {
+		return models.CandleModel{}, fmt.Errorf("invalid candle timestamp %v", row[0])
+	}
+	timestamp, err := time.Parse(kiteHistoricalTimeLayout, timestampStr)
+	if err != nil {
+		return models.CandleModel{}, fmt.Errorf("invalid candle timestamp %s", timestampStr)
+	}
+
+	var values [6]float64
+	for i, value := range row[1:min(len(row), 7)] {
+		number, ok := value.(float64)
+		if !ok {
+			return models.CandleModel{}, fmt.Errorf("invalid candle value %v", value)
+		}
+		values[i] = number
+	}
+	return models.CandleModel{
+		Timestamp: timestamp.In(MarketLocation),
+		Open:      values[0],
+		High:      values[1],
+		Low:       values[2],
+		Close:     values[3],
+		Volume:    uint64(values[4]),
+		OI:        uint64(values[5]),
+	}, nil
+}
@@ -0,0 +1,159 @@
+// Package service contains the service layer for the Moneybots API
+package service
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nsvirk/moneybotsapi/internal/config"
+	"github.com/nsvirk/moneybotsapi/internal/models"
+	"github.com/nsvirk/moneybotsapi/pkg/utils/zaplogger"
+	"gorm.io/gorm"
+)
+
+// IndexBreadth is the market breadth of an index computed from its constituents' quotes
+// Constituents without a quote or a previous close are counted as missing
+type IndexBreadth struct {
+	Index            string    `json:"index"`
+	Exchange         string    `json:"exchange"`
+	Constituents     int       `json:"constituents"`
+	Advances         int       `json:"advances"`
+	Declines         int       `json:"declines"`
+	Unchanged        int       `json:"unchanged"`
+	Missing          int       `json:"missing"`
+	SumNetChange     float64   `json:"sum_net_change"`
+	SumChangePercent float64   `json:"sum_change_percent"`
+	ComputedAt       time.Time `json:"computed_at"`
+}
+
+// indexBreadthCache holds the latest breadth of the configured indices, keyed by `EXCHANGE:INDEX`
+var indexBreadthCache = struct {
+	sync.RWMutex
+	items map[string]IndexBreadth
+}{items: make(map[string]IndexBreadth)}
+
+// BreadthIndices returns the configured `EXCHANGE:INDEX` keys breadth is computed for
+func BreadthIndices(cfg *config.Config) []string {
+	var keys []string
+	for _, key := range strings.Split(cfg.BreadthIndices, ",") {
+		if key = strings.TrimSpace(key); key != "" {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// GetIndexBreadth returns the cached breadth of a configured index, computing it if not cached yet
+func (s *IndexService) GetIndexBreadth(cfg *config.Config, exchange, index string) (IndexBreadth, bool, error) {
+	key := exchange + ":" + index
+	configured := false
+	for _, breadthIndex := range BreadthIndices(cfg) {
+		if breadthIndex == key {
+			configured = true
+			break
+		}
+	}
+	if !configured {
+		return IndexBreadth{}, false, nil
+	}
+
+	indexBreadthCache.RLock()
+	breadth, ok := indexBreadthCache.items[key]
+	indexBreadthCache.RUnlock()
+	if ok {
+		return breadth, true, nil
+	}
+
+	breadth, err := s.refreshIndexBreadth(cfg, exchange, index)
+	return breadth, true, err
+}
+
+// refreshIndexBreadth computes the breadth of the index and caches it
+func (s *IndexService) refreshIndexBreadth(cfg *config.Config, exchange, index string) (IndexBreadth, error) {
+	constituents, err := getIndexConstituents(s.repo, cfg.CacheTTL(config.CacheIndices), exchange, index)
+	if err != nil {
+		return IndexBreadth{}, err
+	}
+	instruments := make([]string, len(constituents))
+	for i, constituent := range constituents {
+		instruments[i] = constituent.Exchange + ":" + constituent.Tradingsymbol
+	}
+
+	var tickerData []models.TickerData
+	if len(instruments) > 0 {
+		if err := s.db.Where("instrument IN ?", instruments).Find(&tickerData).Error; err != nil {
+			return IndexBreadth{}, fmt.Errorf("error fetching tick data from database: %v", err)
+		}
+	}
+
+	breadth := computeIndexBreadth(tickerData, len(constituents))
+	breadth.Index = index
+	breadth.Exchange = exchange
+
+	indexBreadthCache.Lock()
+	indexBreadthCache.items[exchange+":"+index] = breadth
+	indexBreadthCache.Unlock()
+	return breadth, nil
+}
+
+// computeIndexBreadth counts the advances and declines of the constituents from their previous close
+func computeIndexBreadth(tickerData []models.TickerData, constituents int) IndexBreadth {
+	breadth := IndexBreadth{Constituents: constituents, ComputedAt: time.Now()}
+	for i := range tickerData {
+		tick := &tickerData[i]
+		ohlc, err := tick.GetOHLC()
+		// the ohlc close is the previous day close
+		if err != nil || ohlc.Close <= 0 {
+			continue
+		}
+		netChange := tick.LastPrice - ohlc.Close
+		switch {
+		case netChange > 0:
+			breadth.Advances++
+		case netChange < 0:
+			breadth.Declines++
+		default:
+			breadth.Unchanged++
+		}
+		breadth.SumNetChange += netChange
+		breadth.SumChangePercent += netChange / ohlc.Close * 100
+	}
+	breadth.Missing = constituents - breadth.Advances - breadth.Declines - breadth.Unchanged
+	breadth.SumNetChange = math.Round(breadth.SumNetChange*100) / 100
+	breadth.SumChangePercent = math.Round(breadth.SumChangePercent*100) / 100
+	return breadth
+}
+
+// StartIndexBreadthRefresh refreshes the breadth of the configured indices every interval until ctx is cancelled
+func StartIndexBreadthRefresh(ctx context.Context, cfg *config.Config, db *gorm.DB) {
+	keys := BreadthIndices(cfg)
+	if len(keys) == 0 || cfg.BreadthRefresh <= 0 {
+		return
+	}
+	indexService := NewIndexService(db)
+
+	go func() {
+		ticker := time.NewTicker(cfg.BreadthRefresh)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				for _, key := range keys {
+					exchange, index, _ := strings.Cut(key, ":")
+					if _, err := indexService.refreshIndexBreadth(cfg, exchange, index); err != nil {
+						zaplogger.Warn("Index breadth refresh failed", zaplogger.Fields{
+							"index": key,
+							"error": err.Error(),
+						})
+					}
+				}
+			}
+		}
+	}()
+}
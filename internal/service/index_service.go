@@ -5,6 +5,8 @@ import (
 	"encoding/csv"
 	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/nsvirk/moneybotsapi/internal/models"
@@ -43,8 +45,13 @@ var nseIndicesFileMap = map[string]string{
 
 var nseIndicesUpdatedAtKey = "NSE_INDICES_UPDATED_AT"
 
+// indexConstituentsCache holds the constituents of the recently used indices, keyed by `EXCHANGE:INDEX`,
+// it is cleared whenever the indices are updated
+var indexConstituentsCache = newTTLCache[[]models.IndexModel]("index_constituents")
+
 // IndexService is the service for managing indices
 type IndexService struct {
+	db             *gorm.DB
 	client         *http.Client
 	repo           *repository.IndexRepository
 	instrumentRepo *repository.InstrumentRepository
@@ -59,6 +66,7 @@ func NewIndexService(db *gorm.DB) *IndexService {
 	}
 
 	return &IndexService{
+		db:             db,
 		client:         &http.Client{},
 		repo:           repository.NewIndexRepository(db),
 		instrumentRepo: repository.NewInstrumentRepository(db),
@@ -76,6 +84,21 @@ func (s *IndexService) GetIndicesByExchange(exchange string) ([]models.IndexMode
 	return s.repo.GetIndicesByExchange(exchange)
 }
 
+// GetIndexSummaries returns the indices with their number of constituents
+func (s *IndexService) GetIndexSummaries() ([]repository.IndexSummary, error) {
+	return s.repo.GetIndexSummaries()
+}
+
+// GetIndexConstituents returns the constituents of the index with their tokens and weights
+func (s *IndexService) GetIndexConstituents(index string) ([]models.IndexModel, error) {
+	return s.repo.GetIndexConstituents(index)
+}
+
+// GetInstrumentIndices returns the index memberships of the instrument with its weight in each index
+func (s *IndexService) GetInstrumentIndices(exchange, tradingsymbol string) ([]models.IndexModel, error) {
+	return s.repo.GetInstrumentIndices(exchange, tradingsymbol)
+}
+
 // GetIndexInstruments returns the instruments for a given index
 func (s *IndexService) GetIndexInstruments(exchange, index string) ([]models.InstrumentModel, error) {
 	indexRecords, err := s.repo.GetIndexInstruments(exchange, index)
@@ -95,33 +118,64 @@ func (s *IndexService) GetIndexInstruments(exchange, index string) ([]models.Ins
 	return instruments, nil
 }
 
-// UpdateIndices updates the indices in the database
+// getIndexConstituents returns the constituents of the index, cached for ttl
+// The returned slice is shared between callers and must not be modified
+func getIndexConstituents(repo *repository.IndexRepository, ttl time.Duration, exchange, index string) ([]models.IndexModel, error) {
+	key := exchange + ":" + index
+	if constituents, ok := indexConstituentsCache.Get(key); ok {
+		return constituents, nil
+	}
+	constituents, err := repo.GetIndexInstruments(exchange, index)
+	if err != nil {
+		return nil, err
+	}
+	indexConstituentsCache.Set(key, constituents, ttl)
+	return constituents, nil
+}
+
+// IndicesSyncReport is the result of a sync of the indices
+type IndicesSyncReport struct {
+	Indices  int      `json:"indices"`
+	Records  int64    `json:"records"`
+	Unmapped []string `json:"unmapped"` // constituents not in the instrument master, `EXCHANGE:TRADINGSYMBOL`
+	Skipped  bool     `json:"skipped"`  // the indices were already synced today
+}
+
+// UpdateIndices updates the indices in the database, once a day
 func (s *IndexService) UpdateIndices() (int64, error) {
-	var grandTotalInserted int64
+	report, err := s.SyncIndices(false)
+	if err != nil {
+		return 0, err
+	}
+	return report.Records, nil
+}
+
+// SyncIndices downloads the constituents of the indices and replaces the indices table with them,
+// unless they were already synced today and force is false
+func (s *IndexService) SyncIndices(force bool) (*IndicesSyncReport, error) {
 	// update NSE indices
-	totalInserted, err := s.updateNSEIndices()
+	report, err := s.updateNSEIndices(force)
 	if err != nil {
-		return 0, fmt.Errorf("failed to update NSE indices: %v", err)
+		return nil, fmt.Errorf("failed to update NSE indices: %v", err)
 	}
-	grandTotalInserted += totalInserted
 
 	// Update Other indices
 	// ToDo
 
-	return grandTotalInserted, nil
+	return report, nil
 }
 
-// UpdateNSEIndices fetches the instruments for a given NSE index and updates the database
-func (s *IndexService) updateNSEIndices() (int64, error) {
+// UpdateNSEIndices fetches the instruments of the NSE indices and replaces them in the database
+func (s *IndexService) updateNSEIndices(force bool) (*IndicesSyncReport, error) {
 
 	// check if update is required
 	nseIndicesUpdatedAtValue, err := s.state.Get(nseIndicesUpdatedAtKey)
-	if err == nil {
+	if err == nil && !force {
 		if !s.isUpdateIndicesRequired(nseIndicesUpdatedAtValue) {
 			zaplogger.Info("Indices update not required", zaplogger.Fields{
 				nseIndicesUpdatedAtKey: nseIndicesUpdatedAtValue,
 			})
-			return 0, nil
+			return &IndicesSyncReport{Unmapped: []string{}, Skipped: true}, nil
 		}
 	}
 
@@ -130,48 +184,94 @@ func (s *IndexService) updateNSEIndices() (int64, error) {
 		nseIndicesUpdatedAtKey: nseIndicesUpdatedAtValue,
 	})
 
-	// truncate table
-	if err := s.repo.TruncateIndicesTable(); err != nil {
-		return 0, fmt.Errorf("failed to truncate table: %v", err)
+	// keep the stored weights for the lists which do not have them
+	weights, err := s.repo.GetIndexWeights()
+	if err != nil {
+		return nil, err
 	}
 
 	// get instruments for all indices
-	var totalInserted int64
 	var indices []string
 	for index := range nseIndicesFileMap {
 		indices = append(indices, index)
 	}
 
 	if len(indices) == 0 {
-		return 0, fmt.Errorf("no indices found in nseIndicesFileMap")
+		return nil, fmt.Errorf("no indices found in nseIndicesFileMap")
 	}
 
-	// update indices
+	// all the indices are fetched before the table is replaced, so a failed download keeps the stored indices
+	var records []models.IndexModel
 	for _, index := range indices {
 		// get records for index
 		indexRecords, err := s.fetchNSEIndexInstruments(index)
 		if err != nil {
-			return 0, fmt.Errorf("failed to get instruments for index %s: %v", index, err)
+			return nil, fmt.Errorf("failed to get instruments for index %s: %v", index, err)
 		}
-
-		count, err := s.repo.InsertIndices(indexRecords)
-		if err != nil {
-			return 0, fmt.Errorf("failed to create instruments for index %s: %v", index, err)
+		for i := range indexRecords {
+			record := &indexRecords[i]
+			if record.Weight == 0 {
+				record.Weight = weights[record.Exchange+":"+record.Index+":"+record.Tradingsymbol]
+			}
 		}
-		totalInserted += count
+		records = append(records, indexRecords...)
+	}
 
+	unmapped, err := s.mapIndexTokens(records)
+	if err != nil {
+		return nil, err
+	}
+
+	totalInserted, err := s.repo.ReplaceIndices(records)
+	if err != nil {
+		return nil, err
 	}
 
+	indexConstituentsCache.Clear()
+
 	// update state after all indices have been updated
 	if err := s.state.Set(nseIndicesUpdatedAtKey, time.Now().Format("2006-01-02 15:04:05")); err != nil {
-		return 0, fmt.Errorf("failed to update state: %v", err)
+		return nil, fmt.Errorf("failed to update state: %v", err)
 	}
 
 	zaplogger.Info("NSE Indices updated", zaplogger.Fields{
 		"totalInserted": totalInserted,
+		"unmapped":      len(unmapped),
 	})
 
-	return totalInserted, nil
+	return &IndicesSyncReport{Indices: len(indices), Records: totalInserted, Unmapped: unmapped}, nil
+}
+
+// mapIndexTokens sets the instrument token of the constituents from the instrument master,
+// it returns the constituents which are not in it
+func (s *IndexService) mapIndexTokens(records []models.IndexModel) ([]string, error) {
+	tradingsymbols := make(map[string][]string)
+	for _, record := range records {
+		tradingsymbols[record.Exchange] = append(tradingsymbols[record.Exchange], record.Tradingsymbol)
+	}
+
+	tokens := make(map[string]uint32)
+	for exchange, symbols := range tradingsymbols {
+		instruments, err := s.instrumentRepo.GetInstrumentByExchangeTradingsymbols(exchange, symbols)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get %s instruments: %v", exchange, err)
+		}
+		for _, instrument := range instruments {
+			tokens[instrument.Exchange+":"+instrument.Tradingsymbol] = instrument.InstrumentToken
+		}
+	}
+
+	unmapped := []string{}
+	seen := make(map[string]bool)
+	for i := range records {
+		instrument := records[i].Exchange + ":" + records[i].Tradingsymbol
+		records[i].InstrumentToken = tokens[instrument]
+		if records[i].InstrumentToken == 0 && !seen[instrument] {
+			unmapped = append(unmapped, instrument)
+			seen[instrument] = true
+		}
+	}
+	return unmapped, nil
 }
 
 // isUpdateIndicesRequired checks if the indices need to be updated
@@ -232,13 +332,26 @@ func (s *IndexService) fetchNSEIndexInstruments(index string) ([]models.IndexMod
 		return nil, fmt.Errorf("failed to parse CSV for index %s: %v", index, err)
 	}
 
+	if len(records) == 0 {
+		return nil, fmt.Errorf("empty CSV for index %s", index)
+	}
+
+	// the lists with weights have a `Weightage` column after the usual ones
+	weightColumn := -1
+	for i, column := range records[0] {
+		if strings.Contains(strings.ToLower(column), "weight") {
+			weightColumn = i
+			break
+		}
+	}
+
 	indexRecords := make([]models.IndexModel, 0, len(records)-1)
 	for _, record := range records[1:] { // Skip header row
 		// record : [Company Name, Industry, Symbol, Series, ISIN Code]
 		if len(record) < 5 {
 			continue
 		}
-		indexRecords = append(indexRecords, models.IndexModel{
+		indexRecord := models.IndexModel{
 			Index:         index,
 			Exchange:      "NSE",
 			CompanyName:   record[0],
@@ -246,7 +359,14 @@ func (s *IndexService) fetchNSEIndexInstruments(index string) ([]models.IndexMod
 			Tradingsymbol: record[2],
 			Series:        record[3],
 			ISINCode:      record[4],
-		})
+		}
+		if weightColumn >= 0 && weightColumn < len(record) {
+			weight, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(record[weightColumn]), "%"), 64)
+			if err == nil && weight > 0 {
+				indexRecord.Weight = weight
+			}
+		}
+		indexRecords = append(indexRecords, indexRecord)
 	}
 
 	return indexRecords, nil
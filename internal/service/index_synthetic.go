@@ -0,0 +1,142 @@
+// Package service contains the service layer for the Moneybots API
+package service
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/nsvirk/moneybotsapi/internal/config"
+	"github.com/nsvirk/moneybotsapi/internal/models"
+)
+
+// Index weightings of a synthetic index
+const (
+	IndexWeightingStored = "stored"
+	IndexWeightingEqual  = "equal"
+)
+
+// IndexSynthetic is an index value computed from its constituents' quotes and weights
+// The synthetic value applies the weighted change of the constituents to the previous close of the index,
+// constituents without a quote are left out and the weights of the others are renormalized
+type IndexSynthetic struct {
+	Index                 string    `json:"index"`
+	Exchange              string    `json:"exchange"`
+	Weighting             string    `json:"weighting"`
+	Constituents          int       `json:"constituents"`
+	Quoted                int       `json:"quoted"`
+	Missing               []string  `json:"missing"`
+	CoveragePercent       float64   `json:"coverage_percent"`
+	WeightedPrice         float64   `json:"weighted_price"`
+	WeightedChangePercent float64   `json:"weighted_change_percent"`
+	IndexPrevClose        *float64  `json:"index_prev_close"`
+	IndexLastPrice        *float64  `json:"index_last_price"`
+	SyntheticValue        *float64  `json:"synthetic_value"`
+	Deviation             *float64  `json:"deviation"`
+	ComputedAt            time.Time `json:"computed_at"`
+}
+
+// GetIndexSynthetic computes the synthetic value of an index, it returns nil if the index has no constituents
+func (s *IndexService) GetIndexSynthetic(cfg *config.Config, exchange, index string) (*IndexSynthetic, error) {
+	constituents, err := getIndexConstituents(s.repo, cfg.CacheTTL(config.CacheIndices), exchange, index)
+	if err != nil {
+		return nil, err
+	}
+	if len(constituents) == 0 {
+		return nil, nil
+	}
+
+	instruments := make([]string, 0, len(constituents)+1)
+	for _, constituent := range constituents {
+		instruments = append(instruments, constituent.Exchange+":"+constituent.Tradingsymbol)
+	}
+	instruments = append(instruments, exchange+":"+index)
+
+	var tickerData []models.TickerData
+	if err := s.db.Where("instrument IN ?", instruments).Find(&tickerData).Error; err != nil {
+		return nil, fmt.Errorf("error fetching tick data from database: %v", err)
+	}
+	ticks := make(map[string]*models.TickerData, len(tickerData))
+	for i := range tickerData {
+		ticks[tickerData[i].Instrument] = &tickerData[i]
+	}
+
+	synthetic := computeIndexSynthetic(constituents, ticks, ticks[exchange+":"+index])
+	synthetic.Index = index
+	synthetic.Exchange = exchange
+	return &synthetic, nil
+}
+
+// computeIndexSynthetic computes the synthetic index from the constituent ticks keyed by `EXCHANGE:TRADINGSYMBOL`
+// The stored weights are used only when every constituent has one, otherwise all constituents are equal weighted
+func computeIndexSynthetic(constituents []models.IndexModel, ticks map[string]*models.TickerData, indexTick *models.TickerData) IndexSynthetic {
+	synthetic := IndexSynthetic{
+		Weighting:    IndexWeightingStored,
+		Constituents: len(constituents),
+		Missing:      []string{},
+		ComputedAt:   time.Now(),
+	}
+	for _, constituent := range constituents {
+		if constituent.Weight <= 0 {
+			synthetic.Weighting = IndexWeightingEqual
+			break
+		}
+	}
+
+	var totalWeight, quotedWeight, weightedPrice, weightedChange float64
+	for _, constituent := range constituents {
+		weight := constituent.Weight
+		if synthetic.Weighting == IndexWeightingEqual {
+			weight = 1
+		}
+		totalWeight += weight
+
+		instrument := constituent.Exchange + ":" + constituent.Tradingsymbol
+		tick, ok := ticks[instrument]
+		if !ok || tick.LastPrice <= 0 {
+			synthetic.Missing = append(synthetic.Missing, instrument)
+			continue
+		}
+		ohlc, err := tick.GetOHLC()
+		// the ohlc close is the previous day close
+		if err != nil || ohlc.Close <= 0 {
+			synthetic.Missing = append(synthetic.Missing, instrument)
+			continue
+		}
+		synthetic.Quoted++
+		quotedWeight += weight
+		weightedPrice += weight * tick.LastPrice
+		weightedChange += weight * (tick.LastPrice - ohlc.Close) / ohlc.Close
+	}
+	if quotedWeight == 0 {
+		return synthetic
+	}
+
+	synthetic.CoveragePercent = roundTo(quotedWeight/totalWeight*100, 2)
+	synthetic.WeightedPrice = roundTo(weightedPrice/quotedWeight, 2)
+	changeRatio := weightedChange / quotedWeight
+	synthetic.WeightedChangePercent = roundTo(changeRatio*100, 4)
+
+	if indexTick == nil {
+		return synthetic
+	}
+	if ohlc, err := indexTick.GetOHLC(); err == nil && ohlc.Close > 0 {
+		prevClose := ohlc.Close
+		value := roundTo(prevClose*(1+changeRatio), 2)
+		synthetic.IndexPrevClose = &prevClose
+		synthetic.SyntheticValue = &value
+		if indexTick.LastPrice > 0 {
+			lastPrice := indexTick.LastPrice
+			deviation := roundTo(value-lastPrice, 2)
+			synthetic.IndexLastPrice = &lastPrice
+			synthetic.Deviation = &deviation
+		}
+	}
+	return synthetic
+}
+
+// roundTo rounds the value to the given decimal places
+func roundTo(value float64, places int) float64 {
+	p := math.Pow(10, float64(places))
+	return math.Round(value*p) / p
+}
@@ -0,0 +1,96 @@
+package service
+
+import (
+	"encoding/json"
+	"slices"
+	"testing"
+
+	"github.com/nsvirk/moneybotsapi/internal/models"
+)
+
+// syntheticTick returns the tick of an instrument at the last price, with the previous close as the ohlc close
+func syntheticTick(instrument string, lastPrice, prevClose float64) *models.TickerData {
+	ohlc, _ := json.Marshal(models.TickerDataOHLC{Close: prevClose})
+	return &models.TickerData{Instrument: instrument, LastPrice: lastPrice, OHLC: ohlc}
+}
+
+func TestComputeIndexSynthetic(t *testing.T) {
+	float := func(v float64) *float64 { return &v }
+	constituents := func(weightA, weightB float64) []models.IndexModel {
+		return []models.IndexModel{
+			{Index: "TINY", Exchange: "NSE", Tradingsymbol: "A", Weight: weightA},
+			{Index: "TINY", Exchange: "NSE", Tradingsymbol: "B", Weight: weightB},
+		}
+	}
+	// A is up 10% and B down 5%
+	bothTicks := map[string]*models.TickerData{
+		"NSE:A": syntheticTick("NSE:A", 110, 100),
+		"NSE:B": syntheticTick("NSE:B", 190, 200),
+	}
+
+	tests := []struct {
+		name          string
+		constituents  []models.IndexModel
+		ticks         map[string]*models.TickerData
+		indexTick     *models.TickerData
+		wantWeighting string
+		wantQuoted    int
+		wantMissing   []string
+		wantCoverage  float64
+		wantPrice     float64
+		wantChange    float64
+		wantValue     *float64
+		wantDeviation *float64
+	}{
+		{
+			name:         "stored weights",
+			constituents: constituents(0.6, 0.4), ticks: bothTicks, indexTick: syntheticTick("NSE:TINY", 1035, 1000),
+			wantWeighting: IndexWeightingStored, wantQuoted: 2, wantMissing: []string{},
+			wantCoverage: 100, wantPrice: 142, wantChange: 4, wantValue: float(1040), wantDeviation: float(5),
+		},
+		{
+			name:         "equal weights when a weight is missing",
+			constituents: constituents(0.6, 0), ticks: bothTicks, indexTick: syntheticTick("NSE:TINY", 1035, 1000),
+			wantWeighting: IndexWeightingEqual, wantQuoted: 2, wantMissing: []string{},
+			wantCoverage: 100, wantPrice: 150, wantChange: 2.5, wantValue: float(1025), wantDeviation: float(-10),
+		},
+		{
+			name:         "missing constituent quote",
+			constituents: constituents(0.6, 0.4), ticks: map[string]*models.TickerData{"NSE:A": bothTicks["NSE:A"]}, indexTick: syntheticTick("NSE:TINY", 1035, 1000),
+			wantWeighting: IndexWeightingStored, wantQuoted: 1, wantMissing: []string{"NSE:B"},
+			wantCoverage: 60, wantPrice: 110, wantChange: 10, wantValue: float(1100), wantDeviation: float(65),
+		},
+		{
+			name:         "no index quote",
+			constituents: constituents(0.6, 0.4), ticks: bothTicks,
+			wantWeighting: IndexWeightingStored, wantQuoted: 2, wantMissing: []string{},
+			wantCoverage: 100, wantPrice: 142, wantChange: 4,
+		},
+		{
+			name:         "no constituent quotes",
+			constituents: constituents(0.6, 0.4), ticks: map[string]*models.TickerData{}, indexTick: syntheticTick("NSE:TINY", 1035, 1000),
+			wantWeighting: IndexWeightingStored, wantMissing: []string{"NSE:A", "NSE:B"},
+		},
+	}
+
+	equal := func(got, want *float64) bool {
+		return got == nil && want == nil || got != nil && want != nil && *got == *want
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := computeIndexSynthetic(tt.constituents, tt.ticks, tt.indexTick)
+			if got.Weighting != tt.wantWeighting || got.Quoted != tt.wantQuoted || !slices.Equal(got.Missing, tt.wantMissing) {
+				t.Errorf("computeIndexSynthetic() weighting, quoted, missing = %s, %d, %v, want %s, %d, %v",
+					got.Weighting, got.Quoted, got.Missing, tt.wantWeighting, tt.wantQuoted, tt.wantMissing)
+			}
+			if got.CoveragePercent != tt.wantCoverage || got.WeightedPrice != tt.wantPrice || got.WeightedChangePercent != tt.wantChange {
+				t.Errorf("computeIndexSynthetic() coverage, price, change = %v, %v, %v, want %v, %v, %v",
+					got.CoveragePercent, got.WeightedPrice, got.WeightedChangePercent, tt.wantCoverage, tt.wantPrice, tt.wantChange)
+			}
+			if !equal(got.SyntheticValue, tt.wantValue) || !equal(got.Deviation, tt.wantDeviation) {
+				t.Errorf("computeIndexSynthetic() value, deviation = %v, %v, want %v, %v",
+					got.SyntheticValue, got.Deviation, tt.wantValue, tt.wantDeviation)
+			}
+		})
+	}
+}
@@ -0,0 +1,87 @@
+// Package service contains the service layer for the Moneybots API
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nsvirk/moneybotsapi/internal/models"
+	"github.com/nsvirk/moneybotsapi/pkg/utils/zaplogger"
+)
+
+// instrumentBackfill holds when the instruments of each exchange were last refreshed for a missing instrument
+var instrumentBackfill = struct {
+	sync.Mutex
+	lastRun map[string]time.Time
+}{lastRun: make(map[string]time.Time)}
+
+// GetMetadataMissing returns the tokens of the ticks which are not in the instrument master, such as
+// instruments listed since the last sync. Their quotes are served from the feed as they are, and their
+// exchanges are refreshed in the background at most once per InstrBackfillGap
+func (s *QuoteService) GetMetadataMissing(tickDataMap map[string]*models.TickerData) (map[uint32]bool, error) {
+	tokens := make([]uint32, 0, len(tickDataMap))
+	for _, tick := range tickDataMap {
+		if !tick.IsIndex {
+			tokens = append(tokens, tick.InstrumentToken)
+		}
+	}
+	if len(tokens) == 0 {
+		return nil, nil
+	}
+
+	known, err := s.instrumentRepo.GetKnownTokens(tokens)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching known instruments: %v", err)
+	}
+	knownTokens := make(map[uint32]bool, len(known))
+	for _, token := range known {
+		knownTokens[token] = true
+	}
+
+	missing := make(map[uint32]bool)
+	exchanges := make(map[string]bool)
+	for instrument, tick := range tickDataMap {
+		if tick.IsIndex || knownTokens[tick.InstrumentToken] {
+			continue
+		}
+		missing[tick.InstrumentToken] = true
+		if exchange, _, ok := strings.Cut(instrument, ":"); ok {
+			exchanges[exchange] = true
+		}
+	}
+	for exchange := range exchanges {
+		s.backfillExchangeInstruments(exchange)
+	}
+	return missing, nil
+}
+
+// backfillExchangeInstruments refreshes the instruments of the exchange in the background, unless it was
+// refreshed within InstrBackfillGap, a gap of 0 disables the refresh
+func (s *QuoteService) backfillExchangeInstruments(exchange string) {
+	if s.cfg.InstrBackfillGap <= 0 {
+		return
+	}
+	now := time.Now()
+	instrumentBackfill.Lock()
+	if lastRun, ok := instrumentBackfill.lastRun[exchange]; ok && now.Sub(lastRun) < s.cfg.InstrBackfillGap {
+		instrumentBackfill.Unlock()
+		return
+	}
+	instrumentBackfill.lastRun[exchange] = now
+	instrumentBackfill.Unlock()
+
+	// the refresh outlives the request, so it is not bound to the request context
+	db := s.db.WithContext(context.Background())
+	go func() {
+		zaplogger.Info("Refreshing instruments for a missing instrument", zaplogger.Fields{"exchange": exchange})
+		if _, err := NewInstrumentService(db).UpdateExchangeInstruments(exchange); err != nil {
+			zaplogger.Warn("Instrument backfill failed", zaplogger.Fields{
+				"exchange": exchange,
+				"error":    err.Error(),
+			})
+		}
+	}()
+}
@@ -0,0 +1,63 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nsvirk/moneybotsapi/internal/config"
+	"github.com/nsvirk/moneybotsapi/internal/models"
+)
+
+func TestGetMetadataMissing(t *testing.T) {
+	db := instrumentsDB(t, models.InstrumentModel{InstrumentToken: 100001, Tradingsymbol: "INFY", Exchange: "NSE"})
+	s := NewQuoteService(&config.Config{}, db)
+
+	got, err := s.GetMetadataMissing(map[string]*models.TickerData{
+		"NSE:INFY":     {Instrument: "NSE:INFY", InstrumentToken: 100001},
+		"NSE:NEWLIST":  {Instrument: "NSE:NEWLIST", InstrumentToken: 999001},
+		"NSE:NIFTY 50": {Instrument: "NSE:NIFTY 50", InstrumentToken: 256265, IsIndex: true},
+	})
+	if err != nil {
+		t.Fatalf("GetMetadataMissing() error = %v", err)
+	}
+	if len(got) != 1 || !got[999001] {
+		t.Errorf("GetMetadataMissing() = %v, want only the token missing from the master", got)
+	}
+}
+
+func TestBackfillExchangeInstrumentsRateLimit(t *testing.T) {
+	instrumentBackfill.Lock()
+	instrumentBackfill.lastRun = make(map[string]time.Time)
+	instrumentBackfill.Unlock()
+
+	// only the skipped refreshes are covered, a refresh downloads the instruments from Kite
+	tests := []struct {
+		name    string
+		gap     time.Duration
+		lastRun time.Time
+	}{
+		{name: "disabled", gap: 0},
+		{name: "refreshed within the gap", gap: 10 * time.Minute, lastRun: time.Now().Add(-time.Minute)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			instrumentBackfill.Lock()
+			if tt.lastRun.IsZero() {
+				delete(instrumentBackfill.lastRun, "NFO")
+			} else {
+				instrumentBackfill.lastRun["NFO"] = tt.lastRun
+			}
+			instrumentBackfill.Unlock()
+
+			s := NewQuoteService(&config.Config{InstrBackfillGap: tt.gap}, instrumentsDB(t))
+			s.backfillExchangeInstruments("NFO")
+
+			instrumentBackfill.Lock()
+			lastRun := instrumentBackfill.lastRun["NFO"]
+			instrumentBackfill.Unlock()
+			if !lastRun.Equal(tt.lastRun) {
+				t.Errorf("backfillExchangeInstruments() refreshed at %v, want it skipped", lastRun)
+			}
+		})
+	}
+}
@@ -0,0 +1,100 @@
+// Package service contains the service layer for the Moneybots API
+package service
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/nsvirk/moneybotsapi/internal/models"
+)
+
+// instrumentsCSVFields is the number of fields of a row of the instruments CSV
+const instrumentsCSVFields = 12
+
+// maxInstrumentsSyncErrors is the max number of malformed rows reported by a sync,
+// the rest are only counted as skipped
+const maxInstrumentsSyncErrors = 100
+
+// parseInstrumentsCSV reads the instruments CSV, skipping the header row and the malformed rows
+// It returns the valid rows along with a report of the skipped ones, only an unreadable header fails the parse
+func parseInstrumentsCSV(r io.Reader) ([][]string, models.InstrumentsSyncReport, error) {
+	report := models.InstrumentsSyncReport{Errors: []models.InstrumentsSyncError{}}
+
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1 // rows with a wrong field count are reported, not fatal
+	if _, err := reader.Read(); err != nil {
+		return nil, report, fmt.Errorf("failed to parse CSV header: %v", err)
+	}
+
+	var records [][]string
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			var parseErr *csv.ParseError
+			if !errors.As(err, &parseErr) {
+				return nil, report, fmt.Errorf("failed to parse CSV: %v", err)
+			}
+			skipInstrumentRecord(&report, parseErr.StartLine, parseErr.Err.Error())
+			continue
+		}
+		if reason := validateInstrumentRecord(record); reason != "" {
+			line, _ := reader.FieldPos(0)
+			skipInstrumentRecord(&report, line, reason)
+			continue
+		}
+		records = append(records, record)
+	}
+	return records, report, nil
+}
+
+// skipInstrumentRecord counts the malformed row as skipped, reporting it while under maxInstrumentsSyncErrors
+func skipInstrumentRecord(report *models.InstrumentsSyncReport, line int, reason string) {
+	report.Skipped++
+	if len(report.Errors) < maxInstrumentsSyncErrors {
+		report.Errors = append(report.Errors, models.InstrumentsSyncError{Line: line, Reason: reason})
+	}
+}
+
+// validateInstrumentRecord returns why the row of the instruments CSV is malformed, or "" if it is valid
+func validateInstrumentRecord(record []string) string {
+	if len(record) != instrumentsCSVFields {
+		return fmt.Sprintf("expected %d fields, got %d", instrumentsCSVFields, len(record))
+	}
+	if _, err := models.ParseInstrumentToken(record[0]); err != nil {
+		return fmt.Sprintf("invalid instrument_token %q", record[0])
+	}
+	if _, err := strconv.ParseUint(record[1], 10, 32); err != nil {
+		return fmt.Sprintf("invalid exchange_token %q", record[1])
+	}
+	if record[2] == "" {
+		return "missing tradingsymbol"
+	}
+	if _, err := strconv.ParseFloat(record[4], 64); err != nil {
+		return fmt.Sprintf("invalid last_price %q", record[4])
+	}
+	if record[5] != "" {
+		if _, err := time.Parse("2006-01-02", record[5]); err != nil {
+			return fmt.Sprintf("invalid expiry %q", record[5])
+		}
+	}
+	if _, err := strconv.ParseFloat(record[6], 64); err != nil {
+		return fmt.Sprintf("invalid strike %q", record[6])
+	}
+	if _, err := strconv.ParseFloat(record[7], 64); err != nil {
+		return fmt.Sprintf("invalid tick_size %q", record[7])
+	}
+	if _, err := strconv.ParseUint(record[8], 10, 32); err != nil {
+		return fmt.Sprintf("invalid lot_size %q", record[8])
+	}
+	if record[11] == "" {
+		return "missing exchange"
+	}
+	return ""
+}
@@ -0,0 +1,78 @@
+package service
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/nsvirk/moneybotsapi/internal/models"
+)
+
+func TestParseInstrumentsCSVBadRows(t *testing.T) {
+	f, err := os.Open("testdata/instruments_bad_rows.csv")
+	if err != nil {
+		t.Fatalf("failed to open the fixture: %v", err)
+	}
+	defer f.Close()
+
+	records, report, err := parseInstrumentsCSV(f)
+	if err != nil {
+		t.Fatalf("parseInstrumentsCSV() error = %v", err)
+	}
+	if len(records) != 1 || records[0][2] != "INFY" {
+		t.Errorf("parseInstrumentsCSV() records = %v, want only INFY", records)
+	}
+
+	want := []models.InstrumentsSyncError{
+		{Line: 3, Reason: `invalid instrument_token "0"`},
+		{Line: 4, Reason: `invalid exchange_token "x"`},
+		{Line: 5, Reason: "missing tradingsymbol"},
+		{Line: 6, Reason: `invalid last_price "abc"`},
+		{Line: 7, Reason: `invalid expiry "2024/10/31"`},
+		{Line: 8, Reason: `invalid strike "x"`},
+		{Line: 9, Reason: `invalid tick_size "x"`},
+		{Line: 10, Reason: `invalid lot_size "-1"`},
+		{Line: 11, Reason: "missing exchange"},
+		{Line: 12, Reason: "expected 12 fields, got 11"},
+		{Line: 13, Reason: "extraneous or missing \" in quoted-field"},
+	}
+	if report.Skipped != len(want) || len(report.Errors) != len(want) {
+		t.Fatalf("parseInstrumentsCSV() skipped = %d, errors = %v, want %d", report.Skipped, report.Errors, len(want))
+	}
+	for i, wantErr := range want {
+		if report.Errors[i] != wantErr {
+			t.Errorf("parseInstrumentsCSV() error %d = %+v, want %+v", i, report.Errors[i], wantErr)
+		}
+	}
+}
+
+func TestParseInstrumentsCSVErrorCap(t *testing.T) {
+	var sb strings.Builder
+	sb.WriteString("instrument_token,exchange_token,tradingsymbol,name,last_price,expiry,strike,tick_size,lot_size,instrument_type,segment,exchange\n")
+	for i := 0; i < maxInstrumentsSyncErrors+50; i++ {
+		sb.WriteString(fmt.Sprintf("%d,1,,NO SYMBOL,0,,0,0.05,1,EQ,NSE,NSE\n", 500000+i))
+	}
+	sb.WriteString("408065,1594,INFY,INFOSYS,0,,0,0.05,1,EQ,NSE,NSE\n")
+
+	records, report, err := parseInstrumentsCSV(strings.NewReader(sb.String()))
+	if err != nil {
+		t.Fatalf("parseInstrumentsCSV() error = %v", err)
+	}
+	if len(records) != 1 {
+		t.Errorf("parseInstrumentsCSV() records = %d, want 1", len(records))
+	}
+	if report.Skipped != maxInstrumentsSyncErrors+50 || len(report.Errors) != maxInstrumentsSyncErrors {
+		t.Errorf("parseInstrumentsCSV() skipped = %d, errors = %d, want %d, %d",
+			report.Skipped, len(report.Errors), maxInstrumentsSyncErrors+50, maxInstrumentsSyncErrors)
+	}
+	if last := report.Errors[len(report.Errors)-1]; last.Line != maxInstrumentsSyncErrors+1 {
+		t.Errorf("last reported line = %d, want %d", last.Line, maxInstrumentsSyncErrors+1)
+	}
+}
+
+func TestParseInstrumentsCSVHeader(t *testing.T) {
+	if _, _, err := parseInstrumentsCSV(strings.NewReader("")); err == nil {
+		t.Errorf("parseInstrumentsCSV() error = nil for an empty body, want an error")
+	}
+}
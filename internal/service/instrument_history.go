@@ -0,0 +1,61 @@
+// Package service contains the service layer for the Moneybots API
+package service
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/nsvirk/moneybotsapi/internal/models"
+	"github.com/nsvirk/moneybotsapi/pkg/utils/zaplogger"
+)
+
+// recordInstrumentVersions records the changes of a sync to the versions of the instrument master,
+// keyed by the market date of the sync, an empty exchange records a sync of the whole master
+// The instruments are already replaced, so a failure is only logged, the next sync diffs against the same versions
+func (s *InstrumentService) recordInstrumentVersions(exchange string, records [][]string) {
+	day := s.clock.Now().In(MarketLocation).Format("2006-01-02")
+	added, closed, err := s.repo.RecordInstrumentVersions(exchange, records, day)
+	if err != nil {
+		zaplogger.Error("Failed to record instrument versions", zaplogger.Fields{
+			"exchange": exchange,
+			"error":    err.Error(),
+		})
+		return
+	}
+	zaplogger.Info("Instrument versions recorded", zaplogger.Fields{
+		"exchange": exchange,
+		"date":     day,
+		"added":    added,
+		"closed":   closed,
+	})
+}
+
+// GetInstrumentsAsOfBySymbols returns the instruments of the EXCHANGE:TRADINGSYMBOL symbols as of the date day,
+// symbols not in that day's instrument master are left out
+func (s *InstrumentService) GetInstrumentsAsOfBySymbols(day string, symbols []string) ([]models.InstrumentVersionModel, error) {
+	symbolsByExchange := make(map[string][]string)
+	for _, symbol := range symbols {
+		exchange, tradingsymbol, ok := strings.Cut(strings.TrimSpace(symbol), ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid input: %s", symbol)
+		}
+		exchange = strings.TrimSpace(exchange)
+		symbolsByExchange[exchange] = append(symbolsByExchange[exchange], strings.TrimSpace(tradingsymbol))
+	}
+
+	var versions []models.InstrumentVersionModel
+	for exchange, tradingsymbols := range symbolsByExchange {
+		exchangeVersions, err := s.repo.GetInstrumentVersionsByExchangeTradingsymbols(day, exchange, tradingsymbols)
+		if err != nil {
+			return nil, err
+		}
+		versions = append(versions, exchangeVersions...)
+	}
+	return versions, nil
+}
+
+// GetInstrumentsAsOfByTokens returns the instruments of the tokens as of the date day,
+// tokens not in that day's instrument master are left out
+func (s *InstrumentService) GetInstrumentsAsOfByTokens(day string, tokens []uint32) ([]models.InstrumentVersionModel, error) {
+	return s.repo.GetInstrumentVersionsByTokens(day, tokens)
+}
@@ -0,0 +1,74 @@
+// Package service contains the service layer for the Moneybots API
+package service
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/nsvirk/moneybotsapi/internal/models"
+)
+
+// InstrumentRanker scores an instrument against a search query, higher ranks first
+type InstrumentRanker interface {
+	Score(query string, instrument models.InstrumentModel) int
+}
+
+// instrumentRankers are the available ranking strategies, selected by `MB_API_SEARCH_RANKING`
+var instrumentRankers = map[string]InstrumentRanker{
+	"match":        MatchRanker{},
+	"equity_first": EquityFirstRanker{},
+}
+
+// GetInstrumentRanker returns the ranker for the strategy, falling back to `match`
+func GetInstrumentRanker(strategy string) InstrumentRanker {
+	if ranker, ok := instrumentRankers[strategy]; ok {
+		return ranker
+	}
+	return instrumentRankers["match"]
+}
+
+// MatchRanker ranks exact symbol matches first, then prefix, then substring matches
+type MatchRanker struct{}
+
+// Score scores the instrument by how the tradingsymbol matches the query
+func (MatchRanker) Score(query string, instrument models.InstrumentModel) int {
+	query = strings.ToUpper(query)
+	tradingsymbol := strings.ToUpper(instrument.Tradingsymbol)
+	switch {
+	case tradingsymbol == query:
+		return 300
+	case strings.HasPrefix(tradingsymbol, query):
+		return 200
+	case strings.Contains(tradingsymbol, query):
+		return 100
+	}
+	return 0
+}
+
+// EquityFirstRanker ranks like MatchRanker but boosts cash equities, preferring NSE over BSE
+type EquityFirstRanker struct{}
+
+// Score scores the instrument by match and boosts equities
+func (EquityFirstRanker) Score(query string, instrument models.InstrumentModel) int {
+	score := MatchRanker{}.Score(query, instrument)
+	if instrument.InstrumentType == "EQ" {
+		switch instrument.Exchange {
+		case "NSE":
+			score += 50
+		case "BSE":
+			score += 25
+		}
+	}
+	return score
+}
+
+// RankInstruments sorts the instruments by the ranker score, ties keep tradingsymbol order
+func RankInstruments(ranker InstrumentRanker, query string, instruments []models.InstrumentModel) {
+	sort.SliceStable(instruments, func(i, j int) bool {
+		si, sj := ranker.Score(query, instruments[i]), ranker.Score(query, instruments[j])
+		if si != sj {
+			return si > sj
+		}
+		return instruments[i].Tradingsymbol < instruments[j].Tradingsymbol
+	})
+}
@@ -0,0 +1,59 @@
+package service
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/nsvirk/moneybotsapi/internal/models"
+)
+
+func TestRankInstruments(t *testing.T) {
+	instruments := []models.InstrumentModel{
+		{Tradingsymbol: "SBIN24OCT800CE", InstrumentType: "CE", Exchange: "NFO"},
+		{Tradingsymbol: "SBIN", InstrumentType: "EQ", Exchange: "BSE"},
+		{Tradingsymbol: "SBICARD", InstrumentType: "EQ", Exchange: "NSE"},
+		{Tradingsymbol: "SBIN24OCTFUT", InstrumentType: "FUT", Exchange: "NFO"},
+		{Tradingsymbol: "SBIN", InstrumentType: "EQ", Exchange: "NSE"},
+		{Tradingsymbol: "XSBIN", InstrumentType: "EQ", Exchange: "NSE"},
+	}
+
+	tests := []struct {
+		name     string
+		strategy string
+		query    string
+		want     []string
+	}{
+		{
+			name:     "match ranks exact then prefix then substring",
+			strategy: "match",
+			query:    "sbin",
+			want:     []string{"BSE:SBIN", "NSE:SBIN", "NFO:SBIN24OCT800CE", "NFO:SBIN24OCTFUT", "NSE:XSBIN", "NSE:SBICARD"},
+		},
+		{
+			name:     "equity first ranks the NSE equity first",
+			strategy: "equity_first",
+			query:    "SBIN",
+			want:     []string{"NSE:SBIN", "BSE:SBIN", "NFO:SBIN24OCT800CE", "NFO:SBIN24OCTFUT", "NSE:XSBIN", "NSE:SBICARD"},
+		},
+		{
+			name:     "unknown strategy falls back to match",
+			strategy: "unknown",
+			query:    "SBIN",
+			want:     []string{"BSE:SBIN", "NSE:SBIN", "NFO:SBIN24OCT800CE", "NFO:SBIN24OCTFUT", "NSE:XSBIN", "NSE:SBICARD"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ranked := slices.Clone(instruments)
+			RankInstruments(GetInstrumentRanker(tt.strategy), tt.query, ranked)
+			got := make([]string, len(ranked))
+			for i, instrument := range ranked {
+				got[i] = instrument.Exchange + ":" + instrument.Tradingsymbol
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("RankInstruments() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
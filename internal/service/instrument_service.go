@@ -2,25 +2,42 @@
 package service
 
 import (
-	"encoding/csv"
+	"context"
 	"fmt"
 	"net/http"
 	"strings"
 	"time"
 
+	"github.com/nsvirk/moneybotsapi/internal/config"
 	"github.com/nsvirk/moneybotsapi/internal/models"
 	"github.com/nsvirk/moneybotsapi/internal/repository"
+	"github.com/nsvirk/moneybotsapi/pkg/utils/clock"
 	"github.com/nsvirk/moneybotsapi/pkg/utils/state"
 	"github.com/nsvirk/moneybotsapi/pkg/utils/zaplogger"
 	"gorm.io/gorm"
 )
 
-var instrumentsUpdatedAtKey = "INSTRUMENTS_UPDATED_AT"
+var instrumentsURL = "https://api.kite.trade/instruments"
+
+var (
+	instrumentsUpdatedAtKey    = "INSTRUMENTS_UPDATED_AT"
+	instrumentsETagKey         = "INSTRUMENTS_ETAG"
+	instrumentsLastModifiedKey = "INSTRUMENTS_LAST_MODIFIED"
+)
+
+// instrumentsChecksumCache holds the checksums computed since the last sync,
+// it is cleared whenever the instruments are updated
+var instrumentsChecksumCache = newTTLCache[models.InstrumentsChecksum]("instruments_checksum")
+
+// searchCandidateLimit is the max number of instruments fetched for ranking a search
+const searchCandidateLimit = 500
 
 // InstrumentService is the service for managing instruments
 type InstrumentService struct {
-	repo  *repository.InstrumentRepository
-	state *state.State
+	client *http.Client
+	repo   *repository.InstrumentRepository
+	state  *state.State
+	clock  clock.Clock
 }
 
 // NewInstrumentService creates a new instrument service
@@ -30,13 +47,27 @@ func NewInstrumentService(db *gorm.DB) *InstrumentService {
 		zaplogger.Fatal("failed to create state manager", zaplogger.Fields{"error": err})
 	}
 	return &InstrumentService{
-		repo:  repository.NewInstrumentRepository(db),
-		state: stateManager,
+		client: &http.Client{Timeout: 2 * time.Minute},
+		repo:   repository.NewInstrumentRepository(db),
+		state:  stateManager,
+		clock:  clock.Real,
+	}
+}
+
+// WithContext returns a copy of the service whose database queries are bound to ctx
+func (s *InstrumentService) WithContext(ctx context.Context) *InstrumentService {
+	return &InstrumentService{
+		client: s.client,
+		repo:   repository.NewInstrumentRepository(s.repo.DB.WithContext(ctx)),
+		state:  s.state,
+		clock:  s.clock,
 	}
 }
 
-// UpdateInstruments updates the instruments in the database
-func (s *InstrumentService) UpdateInstruments() (int64, error) {
+// UpdateInstruments updates the instruments in the database, malformed rows are skipped and reported
+func (s *InstrumentService) UpdateInstruments() (models.InstrumentsSyncReport, error) {
+	var report models.InstrumentsSyncReport
+
 	// check if update is required
 	instrumentsUpdatedAtValue, err := s.state.Get(instrumentsUpdatedAtKey)
 	if err == nil {
@@ -44,7 +75,7 @@ func (s *InstrumentService) UpdateInstruments() (int64, error) {
 			zaplogger.Info("Instruments update not required", zaplogger.Fields{
 				instrumentsUpdatedAtKey: instrumentsUpdatedAtValue,
 			})
-			return 0, nil
+			return report, nil
 		}
 	}
 
@@ -52,61 +83,144 @@ func (s *InstrumentService) UpdateInstruments() (int64, error) {
 		instrumentsUpdatedAtKey: instrumentsUpdatedAtValue,
 	})
 
-	// get instruments from kite
-	resp, err := http.Get("https://api.kite.trade/instruments")
-	if err != nil {
-		return 0, fmt.Errorf("failed to fetch instruments: %v", err)
-	}
-	defer resp.Body.Close()
+	// freeze limits change independently of the instruments, so they are synced on every update
+	s.syncFreezeLimits()
 
-	// parse response body to csv
-	reader := csv.NewReader(resp.Body)
-	records, err := reader.ReadAll()
+	// get instruments from kite, conditional on the last seen ETag / Last-Modified
+	req, err := http.NewRequest(http.MethodGet, instrumentsURL, nil)
 	if err != nil {
-		return 0, fmt.Errorf("failed to parse CSV: %v", err)
+		return report, fmt.Errorf("failed to create instruments request: %v", err)
+	}
+	if etag, _ := s.state.Get(instrumentsETagKey); etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+	if lastModified, _ := s.state.Get(instrumentsLastModifiedKey); lastModified != "" {
+		req.Header.Set("If-Modified-Since", lastModified)
 	}
 
-	records = records[1:] // Skip header row
-
-	// truncate instruments table
-	if err := s.repo.TruncateInstrumentsTable(); err != nil {
-		return 0, fmt.Errorf("failed to truncate table: %v", err)
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return report, fmt.Errorf("failed to fetch instruments: %v", err)
 	}
+	defer resp.Body.Close()
 
-	// insert instruments in batches
-	batchSize := 500
-	var totalInserted int64 = 0
-	for i := 0; i < len(records); i += batchSize {
-		end := i + batchSize
-		if end > len(records) {
-			end = len(records)
+	// skip the reload if the instruments are unchanged upstream
+	if resp.StatusCode == http.StatusNotModified {
+		report.Unchanged = true
+		if err := s.state.Set(instrumentsUpdatedAtKey, s.clock.Now().Format("2006-01-02 15:04:05")); err != nil {
+			return report, fmt.Errorf("failed to update state: %v", err)
 		}
-
-		// insert instruments in batch
-		inserted, err := s.repo.InsertInstruments(records[i:end])
-
+		report.Records, err = s.repo.GetInstrumentsRecordCount()
 		if err != nil {
-			return totalInserted, fmt.Errorf("failed to insert batch starting at index %d: %v", i, err)
+			return report, fmt.Errorf("failed to get instruments record count: %v", err)
 		}
-		totalInserted += inserted
+		zaplogger.Info("Instruments unchanged upstream, reload skipped", zaplogger.Fields{
+			"records": report.Records,
+		})
+		return report, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return report, fmt.Errorf("failed to fetch instruments: unexpected status %s", resp.Status)
 	}
 
+	// parse response body to csv, a few malformed rows must not fail the whole sync
+	records, report, err := parseInstrumentsCSV(resp.Body)
+	if err != nil {
+		return report, err
+	}
+	if len(records) == 0 {
+		return report, fmt.Errorf("no valid instruments received, %d rows skipped", report.Skipped)
+	}
+	if report.Skipped > 0 {
+		zaplogger.Warn("Malformed instrument rows skipped", zaplogger.Fields{
+			"skipped": report.Skipped,
+			"errors":  report.Errors,
+		})
+	}
+
+	// replace the instruments atomically, so lookups during the reload never miss an instrument
+	report.Inserted, err = s.repo.ReplaceAllInstruments(records, 500)
+	if err != nil {
+		return report, fmt.Errorf("failed to replace instruments: %v", err)
+	}
+	s.recordInstrumentVersions("", records)
+
 	// update state after all instruments have been updated
-	if err := s.state.Set(instrumentsUpdatedAtKey, time.Now().Format("2006-01-02 15:04:05")); err != nil {
-		return 0, fmt.Errorf("failed to update state: %v", err)
+	if err := s.state.Set(instrumentsUpdatedAtKey, s.clock.Now().Format("2006-01-02 15:04:05")); err != nil {
+		return report, fmt.Errorf("failed to update state: %v", err)
 	}
+	if err := s.state.Set(instrumentsETagKey, resp.Header.Get("ETag")); err != nil {
+		return report, fmt.Errorf("failed to update state: %v", err)
+	}
+	if err := s.state.Set(instrumentsLastModifiedKey, resp.Header.Get("Last-Modified")); err != nil {
+		return report, fmt.Errorf("failed to update state: %v", err)
+	}
+
+	// instruments may have been listed, forget the instruments without quotes
+	quoteNegativeCache.Clear()
+	instrumentsChecksumCache.Clear()
 
 	zaplogger.Info("Instruments updated", zaplogger.Fields{
-		"totalInserted": totalInserted,
+		"totalInserted": report.Inserted,
+		"skipped":       report.Skipped,
 	})
 
 	// get instruments record count
-	recordCount, err := s.repo.GetInstrumentsRecordCount()
+	report.Records, err = s.repo.GetInstrumentsRecordCount()
 	if err != nil {
-		return 0, fmt.Errorf("failed to get instruments record count: %v", err)
+		return report, fmt.Errorf("failed to get instruments record count: %v", err)
 	}
 
-	return recordCount, nil
+	return report, nil
+}
+
+// UpdateExchangeInstruments reloads the instruments of a single exchange,
+// leaving the instruments of other exchanges untouched, malformed rows are skipped and reported
+func (s *InstrumentService) UpdateExchangeInstruments(exchange string) (models.InstrumentsSyncReport, error) {
+	var report models.InstrumentsSyncReport
+
+	resp, err := s.client.Get(instrumentsURL + "/" + exchange)
+	if err != nil {
+		return report, fmt.Errorf("failed to fetch %s instruments: %v", exchange, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return report, fmt.Errorf("failed to fetch %s instruments: unexpected status %s", exchange, resp.Status)
+	}
+
+	records, report, err := parseInstrumentsCSV(resp.Body)
+	if err != nil {
+		return report, err
+	}
+
+	// only keep rows of the requested exchange
+	exchangeRecords := make([][]string, 0, len(records))
+	for _, record := range records {
+		if record[11] == exchange {
+			exchangeRecords = append(exchangeRecords, record)
+		}
+	}
+	if len(exchangeRecords) == 0 {
+		return report, fmt.Errorf("no valid %s instruments received, %d rows skipped", exchange, report.Skipped)
+	}
+
+	report.Inserted, err = s.repo.ReplaceExchangeInstruments(exchange, exchangeRecords, 500)
+	if err != nil {
+		return report, err
+	}
+	s.recordInstrumentVersions(exchange, exchangeRecords)
+	report.Records = report.Inserted
+
+	quoteNegativeCache.Clear()
+	instrumentsChecksumCache.Clear()
+
+	zaplogger.Info("Exchange instruments updated", zaplogger.Fields{
+		"exchange":      exchange,
+		"totalInserted": report.Inserted,
+		"skipped":       report.Skipped,
+	})
+
+	return report, nil
 }
 
 // isUpdateInstrumentsRequired checks if the instruments need to be updated
@@ -119,7 +233,7 @@ func (s *InstrumentService) isUpdateInstrumentsRequired(lastUpdatedAt string) bo
 	}
 
 	// false only if last update is today and after 08:15am
-	if lastUpdatedAtTime.Day() == time.Now().Day() {
+	if lastUpdatedAtTime.Day() == s.clock.Now().Day() {
 		if lastUpdatedAtTime.Hour() == 8 && lastUpdatedAtTime.Minute() >= 15 {
 			return false
 		}
@@ -186,6 +300,35 @@ func (s *InstrumentService) GetInstrumentsByExpiry(expiry string) ([]models.Inst
 	return s.repo.GetInstrumentsByExpiry(expiry)
 }
 
+// SearchInstruments searches instruments by tradingsymbol and ranks them with the given ranker
+func (s *InstrumentService) SearchInstruments(ranker InstrumentRanker, query string, limit int) ([]models.InstrumentModel, error) {
+	// fetch a wider candidate set so the ranker can pick the best matches
+	candidates, err := s.repo.SearchInstrumentsByTradingsymbol(query, searchCandidateLimit)
+	if err != nil {
+		return nil, err
+	}
+	RankInstruments(ranker, query, candidates)
+	if len(candidates) > limit {
+		candidates = candidates[:limit]
+	}
+	return candidates, nil
+}
+
+// GetInstrumentsChecksum returns the checksum of the instruments of the exchange, or of all instruments,
+// it is computed once after a sync and then served from the cache, the `instruments` cache TTL bounds it
+// in case the instruments table changes outside a sync
+func (s *InstrumentService) GetInstrumentsChecksum(cfg *config.Config, exchange string) (models.InstrumentsChecksum, error) {
+	if checksum, ok := instrumentsChecksumCache.Get(exchange); ok {
+		return checksum, nil
+	}
+	checksum, err := s.repo.GetInstrumentsChecksum(exchange)
+	if err != nil {
+		return models.InstrumentsChecksum{}, err
+	}
+	instrumentsChecksumCache.Set(exchange, checksum, cfg.CacheTTL(config.CacheInstruments))
+	return checksum, nil
+}
+
 // GetFNOSegmentWiseName returns a list of segment wise name for a given expiry
 func (s *InstrumentService) GetFNOSegmentWiseName(expiry string) ([]models.InstrumentModel, error) {
 	return s.repo.GetFNOSegmentWiseName(expiry)
@@ -0,0 +1,66 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nsvirk/moneybotsapi/internal/config"
+	"github.com/nsvirk/moneybotsapi/internal/models"
+	"github.com/nsvirk/moneybotsapi/pkg/utils/clock"
+)
+
+func TestGetInstrumentsChecksumCached(t *testing.T) {
+	db := instrumentsDB(t, models.InstrumentModel{InstrumentToken: 100001, Tradingsymbol: "INFY", Exchange: "NSE", LastPrice: 1000})
+	s := NewInstrumentService(db)
+	cfg := &config.Config{}
+	t.Cleanup(instrumentsChecksumCache.Clear)
+
+	checksum := func() string {
+		t.Helper()
+		checksum, err := s.GetInstrumentsChecksum(cfg, "NSE")
+		if err != nil {
+			t.Fatalf("GetInstrumentsChecksum() error = %v", err)
+		}
+		return checksum.Checksum
+	}
+
+	before := checksum()
+	if err := db.Model(&models.InstrumentModel{}).Where("instrument_token = ?", 100001).Update("last_price", 1010).Error; err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	if got := checksum(); got != before {
+		t.Errorf("GetInstrumentsChecksum() before a sync = %s, want the cached %s", got, before)
+	}
+
+	// a sync clears the cached checksums
+	instrumentsChecksumCache.Clear()
+	if got := checksum(); got == before {
+		t.Errorf("GetInstrumentsChecksum() after a sync = %s, want it changed", got)
+	}
+}
+
+func TestIsUpdateInstrumentsRequired(t *testing.T) {
+	fake := clock.NewFake(time.Date(2024, 10, 15, 10, 0, 0, 0, time.UTC))
+	s := &InstrumentService{clock: fake}
+
+	tests := []struct {
+		name          string
+		lastUpdatedAt string
+		advance       time.Duration
+		want          bool
+	}{
+		{name: "never updated", lastUpdatedAt: "", want: true},
+		{name: "updated today after the refresh", lastUpdatedAt: "2024-10-15 08:20:00", want: false},
+		{name: "updated today before the refresh", lastUpdatedAt: "2024-10-15 08:10:00", want: true},
+		{name: "updated yesterday", lastUpdatedAt: "2024-10-14 09:00:00", want: true},
+		{name: "a day later", lastUpdatedAt: "2024-10-15 08:20:00", advance: 24 * time.Hour, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake.Set(time.Date(2024, 10, 15, 10, 0, 0, 0, time.UTC).Add(tt.advance))
+			if got := s.isUpdateInstrumentsRequired(tt.lastUpdatedAt); got != tt.want {
+				t.Errorf("isUpdateInstrumentsRequired(%q) at %v = %v, want %v", tt.lastUpdatedAt, fake.Now(), got, tt.want)
+			}
+		})
+	}
+}
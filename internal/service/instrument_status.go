@@ -0,0 +1,48 @@
+// Package service contains the service layer for the Moneybots API
+package service
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/nsvirk/moneybotsapi/internal/models"
+)
+
+// GetSuspended returns the tokens of the ticks which the instrument master no longer lists as tradable:
+// contracts past their expiry, and instruments dropped from the master by a sync
+// The feed keeps serving the last ticks of such instruments, so their tradable flag alone is not enough
+func (s *QuoteService) GetSuspended(tickDataMap map[string]*models.TickerData) (map[uint32]bool, error) {
+	tokens := make([]uint32, 0, len(tickDataMap))
+	for _, tick := range tickDataMap {
+		if !tick.IsIndex {
+			tokens = append(tokens, tick.InstrumentToken)
+		}
+	}
+	if len(tokens) == 0 {
+		return nil, nil
+	}
+
+	instruments, err := s.instrumentRepo.GetInstrumentsByTokens(tokens)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching instruments for the suspended check: %v", err)
+	}
+	delisted, err := s.instrumentRepo.GetDelistedTokens(tokens)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching delisted instruments: %v", err)
+	}
+	return suspendedTokens(instruments, delisted, time.Now().In(MarketLocation).Format("2006-01-02")), nil
+}
+
+// suspendedTokens returns the tokens of the instruments expired before the day, and the delisted tokens
+func suspendedTokens(instruments []models.InstrumentModel, delisted []uint32, day string) map[uint32]bool {
+	suspended := make(map[uint32]bool)
+	for _, instrument := range instruments {
+		if instrument.Expiry != "" && instrument.Expiry < day {
+			suspended[instrument.InstrumentToken] = true
+		}
+	}
+	for _, token := range delisted {
+		suspended[token] = true
+	}
+	return suspended
+}
@@ -0,0 +1,36 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/nsvirk/moneybotsapi/internal/models"
+)
+
+func TestSuspendedTokens(t *testing.T) {
+	instruments := []models.InstrumentModel{
+		{InstrumentToken: 1, Tradingsymbol: "INFY"},
+		{InstrumentToken: 2, Tradingsymbol: "NIFTY24OCTFUT", Expiry: "2024-10-31"},
+		{InstrumentToken: 3, Tradingsymbol: "NIFTY24OCT24500CE", Expiry: "2024-10-15"},
+		{InstrumentToken: 4, Tradingsymbol: "NIFTY24OCT1024500CE", Expiry: "2024-10-10"},
+	}
+	got := suspendedTokens(instruments, []uint32{5}, "2024-10-15")
+
+	tests := []struct {
+		name  string
+		token uint32
+		want  bool
+	}{
+		{name: "equity", token: 1, want: false},
+		{name: "future before expiry", token: 2, want: false},
+		{name: "option on expiry day", token: 3, want: false},
+		{name: "option past expiry", token: 4, want: true},
+		{name: "delisted", token: 5, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got[tt.token] != tt.want {
+				t.Errorf("suspendedTokens()[%d] = %v, want %v", tt.token, got[tt.token], tt.want)
+			}
+		})
+	}
+}
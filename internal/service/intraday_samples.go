@@ -0,0 +1,101 @@
+// Package service contains the service layer for the Moneybots API
+package service
+
+import (
+	"sync"
+	"time"
+
+	"github.com/nsvirk/moneybotsapi/pkg/utils/clock"
+)
+
+// intradaySampleStore holds the intraday price/volume samples fed by the ticker
+var intradaySampleStore = NewIntradaySampleStore()
+
+// IntradaySampleBucket is the price*volume and volume traded within a minute
+type IntradaySampleBucket struct {
+	Minute      time.Time
+	PriceVolume float64
+	Volume      uint64
+}
+
+// intradaySeries is the per minute sample series for an instrument
+type intradaySeries struct {
+	day        string
+	lastVolume uint32
+	buckets    []IntradaySampleBucket
+}
+
+// IntradaySampleStore keeps per minute price/volume buckets for each instrument for the current day
+type IntradaySampleStore struct {
+	mu     sync.RWMutex
+	series map[string]*intradaySeries
+	clock  clock.Clock
+}
+
+// NewIntradaySampleStore creates a new IntradaySampleStore
+func NewIntradaySampleStore() *IntradaySampleStore {
+	return &IntradaySampleStore{series: make(map[string]*intradaySeries), clock: clock.Real}
+}
+
+// Add adds a sample for the instrument, cumulativeVolume is the day volume reported by the feed
+// The volume traded since the previous sample is attributed to the given price
+func (s *IntradaySampleStore) Add(instrument string, t time.Time, price float64, cumulativeVolume uint32) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	day := t.In(MarketLocation).Format("2006-01-02")
+	series, ok := s.series[instrument]
+	if !ok || series.day != day || cumulativeVolume < series.lastVolume {
+		// first sample of the day only sets the volume baseline
+		s.series[instrument] = &intradaySeries{day: day, lastVolume: cumulativeVolume}
+		return
+	}
+
+	tradedVolume := cumulativeVolume - series.lastVolume
+	series.lastVolume = cumulativeVolume
+	if tradedVolume == 0 {
+		return
+	}
+
+	minute := t.Truncate(time.Minute)
+	n := len(series.buckets)
+	if n == 0 || !series.buckets[n-1].Minute.Equal(minute) {
+		series.buckets = append(series.buckets, IntradaySampleBucket{Minute: minute})
+		n++
+	}
+	series.buckets[n-1].PriceVolume += price * float64(tradedVolume)
+	series.buckets[n-1].Volume += uint64(tradedVolume)
+}
+
+// Buckets returns a copy of the buckets of the instrument from the given time onwards
+// A zero `from` returns all buckets of the current day, there are none before the first sample of the day
+func (s *IntradaySampleStore) Buckets(instrument string, from time.Time) []IntradaySampleBucket {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	series, ok := s.series[instrument]
+	if !ok || series.day != s.clock.Now().In(MarketLocation).Format("2006-01-02") {
+		return nil
+	}
+	buckets := make([]IntradaySampleBucket, 0, len(series.buckets))
+	for _, bucket := range series.buckets {
+		if !bucket.Minute.Before(from.Truncate(time.Minute)) {
+			buckets = append(buckets, bucket)
+		}
+	}
+	return buckets
+}
+
+// ComputeVWAP computes sum(price*volume)/sum(volume) over the buckets
+// ok is false when no volume was traded
+func ComputeVWAP(buckets []IntradaySampleBucket) (vwap float64, volume uint64, ok bool) {
+	var priceVolume float64
+	for _, bucket := range buckets {
+		priceVolume += bucket.PriceVolume
+		volume += bucket.Volume
+	}
+	if volume == 0 {
+		return 0, 0, false
+	}
+	return priceVolume / float64(volume), volume, true
+}
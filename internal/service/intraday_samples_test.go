@@ -0,0 +1,85 @@
+package service
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/nsvirk/moneybotsapi/pkg/utils/clock"
+)
+
+func TestComputeVWAP(t *testing.T) {
+	start := time.Date(2024, 10, 15, 9, 15, 0, 0, MarketLocation)
+
+	// sample is a traded price and the cumulative day volume reported with it, after a wait
+	type sample struct {
+		wait   time.Duration
+		price  float64
+		volume uint32
+	}
+	tests := []struct {
+		name       string
+		samples    []sample
+		from       time.Time
+		nextDay    bool
+		wantVWAP   float64
+		wantVolume uint64
+		wantOK     bool
+	}{
+		{
+			name:    "first sample only sets the baseline",
+			samples: []sample{{0, 100, 1000}},
+		},
+		{
+			name:       "volume weighted across minutes",
+			samples:    []sample{{0, 99, 1000}, {10 * time.Second, 100, 1100}, {10 * time.Second, 102, 1400}, {time.Minute, 101, 1500}},
+			wantVWAP:   101.4,
+			wantVolume: 500,
+			wantOK:     true,
+		},
+		{
+			name:       "window from the second minute",
+			samples:    []sample{{0, 99, 1000}, {10 * time.Second, 100, 1100}, {10 * time.Second, 102, 1400}, {time.Minute, 101, 1500}},
+			from:       start.Add(time.Minute),
+			wantVWAP:   101,
+			wantVolume: 100,
+			wantOK:     true,
+		},
+		{
+			name:    "no volume traded",
+			samples: []sample{{0, 99, 1000}, {10 * time.Second, 100, 1000}},
+		},
+		{
+			name:       "volume reset restarts the baseline",
+			samples:    []sample{{0, 99, 1000}, {10 * time.Second, 100, 1100}, {10 * time.Second, 98, 50}, {10 * time.Second, 97, 150}},
+			wantVWAP:   97,
+			wantVolume: 100,
+			wantOK:     true,
+		},
+		{
+			name:    "buckets of the previous day before the first sample of the day",
+			samples: []sample{{0, 99, 1000}, {10 * time.Second, 100, 1100}},
+			nextDay: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := NewIntradaySampleStore()
+			at := start
+			for _, s := range tt.samples {
+				at = at.Add(s.wait)
+				store.Add("NSE:INFY", at, s.price, s.volume)
+			}
+			now := clock.NewFake(at)
+			if tt.nextDay {
+				now.Set(start.AddDate(0, 0, 1))
+			}
+			store.clock = now
+			vwap, volume, ok := ComputeVWAP(store.Buckets("NSE:INFY", tt.from))
+			if ok != tt.wantOK || volume != tt.wantVolume || math.Abs(vwap-tt.wantVWAP) > 1e-9 {
+				t.Errorf("ComputeVWAP() = %v, %v, %v, want %v, %v, %v", vwap, volume, ok, tt.wantVWAP, tt.wantVolume, tt.wantOK)
+			}
+		})
+	}
+}
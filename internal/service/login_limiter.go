@@ -0,0 +1,109 @@
+// Package service contains the service layer for the Moneybots API
+package service
+
+import (
+	"sync"
+	"time"
+
+	"github.com/nsvirk/moneybotsapi/pkg/utils/clock"
+)
+
+// loginAttempts are the failed login attempts for a user id from an ip
+type loginAttempts struct {
+	failures    int
+	lastFailure time.Time
+	lockedUntil time.Time
+}
+
+// expired reports if the attempts no longer count, the failures are counted within the lockout window
+func (a *loginAttempts) expired(now time.Time, lockout time.Duration) bool {
+	return !a.lockedUntil.After(now) && now.Sub(a.lastFailure) >= lockout
+}
+
+// LoginLimiter locks out logins for a user id from an ip after repeated failures
+// A user id is not locked out from the other ips, so the failures of others cannot lock a user out, and an ip
+// is not locked out for the other user ids, as the clients behind a proxy or NAT share its ip
+// The attempts expire after the lockout window and are swept once a window, so the attempts of the
+// user ids and ips which never log in successfully do not pile up
+type LoginLimiter struct {
+	mu          sync.Mutex
+	maxAttempts int
+	lockout     time.Duration
+	attempts    map[string]*loginAttempts
+	lastSweep   time.Time
+	clock       clock.Clock
+}
+
+// NewLoginLimiter creates a new LoginLimiter, a maxAttempts of 0 disables the lockout
+func NewLoginLimiter(maxAttempts int, lockout time.Duration) *LoginLimiter {
+	return &LoginLimiter{
+		maxAttempts: maxAttempts,
+		lockout:     lockout,
+		attempts:    make(map[string]*loginAttempts),
+		clock:       clock.Real,
+	}
+}
+
+// LockedUntil returns the time until which logins for the user id from the ip are locked out
+// A zero time means logins are allowed
+func (l *LoginLimiter) LockedUntil(userID, ip string) time.Time {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if a, ok := l.attempts[loginLimiterKey(userID, ip)]; ok && a.lockedUntil.After(l.clock.Now()) {
+		return a.lockedUntil
+	}
+	return time.Time{}
+}
+
+// RecordFailure records a failed login and returns true if it triggered a lockout
+func (l *LoginLimiter) RecordFailure(userID, ip string) bool {
+	if l.maxAttempts <= 0 {
+		return false
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.clock.Now()
+	if now.Sub(l.lastSweep) >= l.lockout {
+		l.sweep(now)
+	}
+
+	key := loginLimiterKey(userID, ip)
+	a, ok := l.attempts[key]
+	if !ok || a.expired(now, l.lockout) {
+		// start afresh once the previous failures or lockout have expired
+		a = &loginAttempts{}
+		l.attempts[key] = a
+	}
+	a.failures++
+	a.lastFailure = now
+	if a.failures < l.maxAttempts {
+		return false
+	}
+	a.failures = 0
+	a.lockedUntil = now.Add(l.lockout)
+	return true
+}
+
+// RecordSuccess resets the failed login attempts of the user id and ip
+func (l *LoginLimiter) RecordSuccess(userID, ip string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.attempts, loginLimiterKey(userID, ip))
+}
+
+// sweep removes the expired attempts, l.mu must be held
+func (l *LoginLimiter) sweep(now time.Time) {
+	for key, a := range l.attempts {
+		if a.expired(now, l.lockout) {
+			delete(l.attempts, key)
+		}
+	}
+	l.lastSweep = now
+}
+
+// loginLimiterKey returns the attempts key for the user id from the ip
+func loginLimiterKey(userID, ip string) string {
+	return "user:" + userID + "|ip:" + ip
+}
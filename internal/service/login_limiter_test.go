@@ -0,0 +1,93 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nsvirk/moneybotsapi/pkg/utils/clock"
+)
+
+// loginAttempt is a failed login of a user id from an ip, after a wait
+type loginAttempt struct {
+	wait   time.Duration
+	userID string
+	ip     string
+}
+
+func TestLoginLimiter(t *testing.T) {
+	tests := []struct {
+		name       string
+		failures   []loginAttempt
+		wait       time.Duration
+		userID, ip string
+		wantLocked bool
+	}{
+		{
+			name:     "under the max attempts",
+			failures: []loginAttempt{{0, "AB1234", "1.1.1.1"}, {0, "AB1234", "1.1.1.1"}},
+			userID:   "AB1234", ip: "1.1.1.1",
+		},
+		{
+			name:     "locked out at the max attempts",
+			failures: []loginAttempt{{0, "AB1234", "1.1.1.1"}, {0, "AB1234", "1.1.1.1"}, {0, "AB1234", "1.1.1.1"}},
+			userID:   "AB1234", ip: "1.1.1.1", wantLocked: true,
+		},
+		{
+			name:     "user not locked out from another ip",
+			failures: []loginAttempt{{0, "AB1234", "1.1.1.1"}, {0, "AB1234", "1.1.1.1"}, {0, "AB1234", "1.1.1.1"}},
+			userID:   "AB1234", ip: "2.2.2.2",
+		},
+		{
+			name:     "clients sharing a proxy ip are not locked out by another client",
+			failures: []loginAttempt{{0, "AB1234", "1.1.1.1"}, {0, "AB1234", "1.1.1.1"}, {0, "AB1234", "1.1.1.1"}},
+			userID:   "CD5678", ip: "1.1.1.1",
+		},
+		{
+			name:     "failures of several users from an ip do not add up",
+			failures: []loginAttempt{{0, "AB1234", "1.1.1.1"}, {0, "CD5678", "1.1.1.1"}, {0, "EF9012", "1.1.1.1"}},
+			userID:   "AB1234", ip: "1.1.1.1",
+		},
+		{
+			name:     "lockout expires",
+			failures: []loginAttempt{{0, "AB1234", "1.1.1.1"}, {0, "AB1234", "1.1.1.1"}, {0, "AB1234", "1.1.1.1"}},
+			wait:     time.Minute,
+			userID:   "AB1234", ip: "1.1.1.1",
+		},
+		{
+			name:     "failures outside the window are not counted",
+			failures: []loginAttempt{{0, "AB1234", "1.1.1.1"}, {0, "AB1234", "1.1.1.1"}, {time.Minute, "AB1234", "1.1.1.1"}},
+			userID:   "AB1234", ip: "1.1.1.1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := clock.NewFake(time.Date(2024, 10, 15, 10, 0, 0, 0, MarketLocation))
+			limiter := NewLoginLimiter(3, time.Minute)
+			limiter.clock = fake
+			for _, failure := range tt.failures {
+				fake.Advance(failure.wait)
+				limiter.RecordFailure(failure.userID, failure.ip)
+			}
+			fake.Advance(tt.wait)
+			if locked := !limiter.LockedUntil(tt.userID, tt.ip).IsZero(); locked != tt.wantLocked {
+				t.Errorf("LockedUntil() locked = %v, want %v", locked, tt.wantLocked)
+			}
+		})
+	}
+}
+
+func TestLoginLimiterSweep(t *testing.T) {
+	fake := clock.NewFake(time.Date(2024, 10, 15, 10, 0, 0, 0, MarketLocation))
+	limiter := NewLoginLimiter(3, time.Minute)
+	limiter.clock = fake
+	for _, ip := range []string{"1.1.1.1", "2.2.2.2", "3.3.3.3"} {
+		limiter.RecordFailure("AB1234", ip)
+	}
+
+	fake.Advance(time.Minute)
+	limiter.RecordFailure("CD5678", "4.4.4.4")
+	if got := len(limiter.attempts); got != 1 {
+		t.Errorf("attempts after sweep = %d, want 1", got)
+	}
+}
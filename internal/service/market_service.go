@@ -0,0 +1,86 @@
+// Package service contains the service layer for the Moneybots API
+package service
+
+import (
+	"strings"
+	"time"
+
+	"github.com/nsvirk/moneybotsapi/internal/config"
+	"github.com/nsvirk/moneybotsapi/internal/models"
+	"github.com/nsvirk/moneybotsapi/pkg/utils/clock"
+)
+
+// MarketLocation is the timezone of the Indian exchanges
+var MarketLocation = time.FixedZone("IST", 5*60*60+30*60)
+
+// marketSession is a regular trading session in IST minutes from midnight
+type marketSession struct {
+	open  int
+	close int
+}
+
+// Regular market sessions in IST
+var (
+	equitySession    = marketSession{open: 9*60 + 15, close: 15*60 + 30} // 09:15am - 03:30pm
+	currencySession  = marketSession{open: 9 * 60, close: 17 * 60}       // 09:00am - 05:00pm
+	commoditySession = marketSession{open: 9 * 60, close: 23*60 + 30}    // 09:00am - 11:30pm
+)
+
+// sessionOf returns the regular trading session of the exchange
+func sessionOf(exchange models.Exchange) marketSession {
+	switch {
+	case exchange.IsCommodity():
+		return commoditySession
+	case exchange.IsCurrency():
+		return currencySession
+	}
+	return equitySession
+}
+
+// MarketService is the service for the market calendar
+type MarketService struct {
+	holidays map[string]bool
+	clock    clock.Clock
+}
+
+// NewMarketService creates a new MarketService
+// Holidays are read from the config as comma separated `YYYY-MM-DD` dates
+func NewMarketService(cfg *config.Config) *MarketService {
+	holidays := make(map[string]bool)
+	for _, holiday := range strings.Split(cfg.MarketHolidays, ",") {
+		if holiday = strings.TrimSpace(holiday); holiday != "" {
+			holidays[holiday] = true
+		}
+	}
+	return &MarketService{holidays: holidays, clock: clock.Real}
+}
+
+// Now returns the current time of the market calendar
+func (s *MarketService) Now() time.Time {
+	return s.clock.Now()
+}
+
+// IsTradingDay checks if the given time falls on a weekday which is not a holiday
+func (s *MarketService) IsTradingDay(t time.Time) bool {
+	t = t.In(MarketLocation)
+	if t.Weekday() == time.Saturday || t.Weekday() == time.Sunday {
+		return false
+	}
+	return !s.holidays[t.Format("2006-01-02")]
+}
+
+// IsMarketOpen checks if the equity market is open at the given time as per the calendar
+func (s *MarketService) IsMarketOpen(t time.Time) bool {
+	return s.IsExchangeOpen(models.ExchangeNSE, t)
+}
+
+// IsExchangeOpen checks if the exchange is in its regular session at the given time as per the calendar
+func (s *MarketService) IsExchangeOpen(exchange models.Exchange, t time.Time) bool {
+	if !s.IsTradingDay(t) {
+		return false
+	}
+	t = t.In(MarketLocation)
+	minutes := t.Hour()*60 + t.Minute()
+	session := sessionOf(exchange)
+	return minutes >= session.open && minutes < session.close
+}
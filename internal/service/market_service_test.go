@@ -0,0 +1,36 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nsvirk/moneybotsapi/internal/config"
+	"github.com/nsvirk/moneybotsapi/pkg/utils/clock"
+)
+
+func TestMarketServiceClock(t *testing.T) {
+	s := NewMarketService(&config.Config{MarketHolidays: "2024-10-18"})
+	// Thursday, before the open
+	fake := clock.NewFake(time.Date(2024, 10, 17, 9, 0, 0, 0, MarketLocation))
+	s.clock = fake
+
+	steps := []struct {
+		name    string
+		advance time.Duration
+		want    bool
+	}{
+		{name: "before the open", want: false},
+		{name: "at the open", advance: 15 * time.Minute, want: true},
+		{name: "before the close", advance: 6*time.Hour + 14*time.Minute, want: true},
+		{name: "at the close", advance: time.Minute, want: false},
+		{name: "holiday", advance: 18 * time.Hour, want: false},
+		{name: "weekend", advance: 24 * time.Hour, want: false},
+		{name: "monday", advance: 48 * time.Hour, want: true},
+	}
+	for _, step := range steps {
+		fake.Advance(step.advance)
+		if got := s.IsMarketOpen(s.Now()); got != step.want {
+			t.Errorf("%s: IsMarketOpen(%v) = %v, want %v", step.name, s.Now(), got, step.want)
+		}
+	}
+}
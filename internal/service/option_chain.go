@@ -0,0 +1,172 @@
+// Package service contains the service layer for the Moneybots API
+package service
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// optionSpotInstruments are the spot instruments of the index options,
+// the options of other names are spot on their instrument of the cash exchange
+var optionSpotInstruments = map[string]string{
+	"NIFTY":      "NSE:NIFTY 50",
+	"BANKNIFTY":  "NSE:NIFTY BANK",
+	"FINNIFTY":   "NSE:NIFTY FIN SERVICE",
+	"MIDCPNIFTY": "NSE:NIFTY MID SELECT",
+	"NIFTYNXT50": "NSE:NIFTY NEXT 50",
+	"SENSEX":     "BSE:SENSEX",
+	"BANKEX":     "BSE:BANKEX",
+}
+
+// optionCashExchanges are the cash exchanges of the derivatives exchanges
+var optionCashExchanges = map[string]string{
+	"NFO": "NSE",
+	"BFO": "BSE",
+}
+
+// OptionChainLeg is the CE or PE contract of a strike, the quote fields are nil when it has no quote
+type OptionChainLeg struct {
+	Instrument      string   `json:"instrument"`
+	InstrumentToken uint32   `json:"instrument_token"`
+	LotSize         uint     `json:"lot_size"`
+	LastPrice       *float64 `json:"last_price"`
+	OI              *uint32  `json:"oi"`
+}
+
+// OptionChainStrike is a strike of the option chain, a leg is nil when the strike is not listed for it
+type OptionChainStrike struct {
+	Strike float64         `json:"strike"`
+	CE     *OptionChainLeg `json:"ce"`
+	PE     *OptionChainLeg `json:"pe"`
+}
+
+// OptionChain is the option chain of an underlying for an expiry, with the strikes around the spot price
+// Without a spot price all the strikes are returned and ATMStrike is nil
+type OptionChain struct {
+	Name           string              `json:"name"`
+	Exchange       string              `json:"exchange"`
+	Expiry         string              `json:"expiry"`
+	SpotInstrument string              `json:"spot_instrument"`
+	SpotPrice      *float64            `json:"spot_price"`
+	ATMStrike      *float64            `json:"atm_strike"`
+	Strikes        []OptionChainStrike `json:"strikes"`
+}
+
+// GetOptionChain returns the option chain of the name on the exchange, with strikes strikes on each side of the
+// strike nearest to the spot price, an empty expiry picks the nearest expiry
+// It returns nil if the name has no options for the expiry
+func (s *QuoteService) GetOptionChain(exchange, name, expiry string, strikes int) (*OptionChain, error) {
+	if expiry == "" {
+		today := time.Now().In(MarketLocation).Format("2006-01-02")
+		nearest, err := s.instrumentRepo.GetNearestOptionExpiry(exchange, name, today)
+		if err != nil {
+			return nil, fmt.Errorf("error fetching the expiries of %s: %v", name, err)
+		}
+		if nearest == "" {
+			return nil, nil
+		}
+		expiry = nearest
+	}
+
+	contracts, err := s.instrumentRepo.GetOptionContracts(exchange, name, expiry)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching the options of %s: %v", name, err)
+	}
+	if len(contracts) == 0 {
+		return nil, nil
+	}
+
+	chain := &OptionChain{
+		Name:           name,
+		Exchange:       exchange,
+		Expiry:         expiry,
+		SpotInstrument: optionSpotInstrument(exchange, name),
+	}
+
+	// group the contracts by strike, the contracts are ordered by strike
+	var chainStrikes []OptionChainStrike
+	for _, contract := range contracts {
+		if n := len(chainStrikes); n == 0 || chainStrikes[n-1].Strike != contract.Strike {
+			chainStrikes = append(chainStrikes, OptionChainStrike{Strike: contract.Strike})
+		}
+		leg := &OptionChainLeg{
+			Instrument:      contract.Exchange + ":" + contract.Tradingsymbol,
+			InstrumentToken: contract.InstrumentToken,
+			LotSize:         contract.LotSize,
+		}
+		if contract.InstrumentType == "CE" {
+			chainStrikes[len(chainStrikes)-1].CE = leg
+		} else {
+			chainStrikes[len(chainStrikes)-1].PE = leg
+		}
+	}
+
+	// keep the strikes around the spot
+	if chain.SpotInstrument != "" {
+		ticks, err := s.GetTickData([]string{chain.SpotInstrument})
+		if err != nil && !errors.Is(err, ErrNoTickData) {
+			return nil, err
+		}
+		if tick := ticks[chain.SpotInstrument]; tick != nil && tick.LastPrice > 0 {
+			spotPrice := tick.LastPrice
+			chain.SpotPrice = &spotPrice
+			atm := nearestStrikeIndex(chainStrikes, spotPrice)
+			chain.ATMStrike = ptrTo(chainStrikes[atm].Strike)
+			chainStrikes = chainStrikes[max(atm-strikes, 0):min(atm+strikes+1, len(chainStrikes))]
+		}
+	}
+
+	// quote the legs of the kept strikes
+	var legs []string
+	for _, strike := range chainStrikes {
+		for _, leg := range []*OptionChainLeg{strike.CE, strike.PE} {
+			if leg != nil {
+				legs = append(legs, leg.Instrument)
+			}
+		}
+	}
+	ticks, err := s.GetTickData(legs)
+	if err != nil && !errors.Is(err, ErrNoTickData) {
+		return nil, err
+	}
+	for _, strike := range chainStrikes {
+		for _, leg := range []*OptionChainLeg{strike.CE, strike.PE} {
+			if leg == nil {
+				continue
+			}
+			if tick := ticks[leg.Instrument]; tick != nil {
+				leg.LastPrice = ptrTo(tick.LastPrice)
+				leg.OI = ptrTo(tick.OI)
+			}
+		}
+	}
+
+	chain.Strikes = chainStrikes
+	return chain, nil
+}
+
+// optionSpotInstrument returns the spot instrument of the options of the name, or "" if it is not known
+func optionSpotInstrument(exchange, name string) string {
+	if instrument, ok := optionSpotInstruments[name]; ok {
+		return instrument
+	}
+	if cashExchange, ok := optionCashExchanges[exchange]; ok {
+		return cashExchange + ":" + name
+	}
+	return ""
+}
+
+// nearestStrikeIndex returns the index of the strike nearest to the price, the strikes are sorted by strike
+func nearestStrikeIndex(strikes []OptionChainStrike, price float64) int {
+	i := sort.Search(len(strikes), func(i int) bool { return strikes[i].Strike >= price })
+	if i == len(strikes) {
+		return i - 1
+	}
+	if i > 0 && math.Abs(strikes[i-1].Strike-price) <= math.Abs(strikes[i].Strike-price) {
+		return i - 1
+	}
+	return i
+}
@@ -0,0 +1,99 @@
+// Package service contains the service layer for the Moneybots API
+package service
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/nsvirk/moneybotsapi/internal/models"
+	"github.com/nsvirk/moneybotsapi/pkg/utils/zaplogger"
+)
+
+// PriceAlert is an alert on an instrument's last price crossing above or below a price
+type PriceAlert struct {
+	Instrument string
+	Above      bool
+	Price      float64
+	triggered  bool
+}
+
+// PriceAlertEvaluator evaluates the price alerts of the instruments updated in a refresh cycle
+// Alerts are indexed by instrument, so the cost scales with the updated instruments and not all alerts
+type PriceAlertEvaluator struct {
+	mu           sync.Mutex
+	byInstrument map[string][]*PriceAlert
+}
+
+// NewPriceAlertEvaluator creates a PriceAlertEvaluator from alert rules like `NSE:INFY>1500,NSE:TCS<3000`
+func NewPriceAlertEvaluator(rules string) (*PriceAlertEvaluator, error) {
+	e := &PriceAlertEvaluator{byInstrument: make(map[string][]*PriceAlert)}
+	for _, rule := range strings.Split(rules, ",") {
+		rule = strings.TrimSpace(rule)
+		if rule == "" {
+			continue
+		}
+		alert, err := parsePriceAlert(rule)
+		if err != nil {
+			return nil, err
+		}
+		e.byInstrument[alert.Instrument] = append(e.byInstrument[alert.Instrument], alert)
+	}
+	return e, nil
+}
+
+// parsePriceAlert parses an `EXCHANGE:SYMBOL>PRICE` or `EXCHANGE:SYMBOL<PRICE` rule
+func parsePriceAlert(rule string) (*PriceAlert, error) {
+	i := strings.IndexAny(rule, "<>")
+	if i <= 0 {
+		return nil, fmt.Errorf("invalid price alert `%s`, must be `EXCHANGE:SYMBOL>PRICE` or `EXCHANGE:SYMBOL<PRICE`", rule)
+	}
+	price, err := strconv.ParseFloat(rule[i+1:], 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid price alert `%s`, price must be a number", rule)
+	}
+	return &PriceAlert{Instrument: rule[:i], Above: rule[i] == '>', Price: price}, nil
+}
+
+// Evaluate evaluates the alerts of the updated ticks and returns the messages of the triggered alerts
+// An alert triggers once when its condition becomes true, and re-arms when it is false again
+func (e *PriceAlertEvaluator) Evaluate(ticks []models.TickerData) []string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	var messages []string
+	for _, tick := range ticks {
+		for _, alert := range e.byInstrument[tick.Instrument] {
+			crossed := tick.LastPrice < alert.Price
+			if alert.Above {
+				crossed = tick.LastPrice > alert.Price
+			}
+			if crossed && !alert.triggered {
+				direction := "below"
+				if alert.Above {
+					direction = "above"
+				}
+				messages = append(messages, fmt.Sprintf("%s at %.2f is %s %.2f", alert.Instrument, tick.LastPrice, direction, alert.Price))
+			}
+			alert.triggered = crossed
+		}
+	}
+	return messages
+}
+
+// dispatchPriceAlerts sends the triggered alerts of a refresh cycle as a single alert
+func dispatchPriceAlerts(alertService *AlertService, messages []string) {
+	if len(messages) == 0 {
+		return
+	}
+	for _, result := range alertService.Send(context.Background(), "Price alerts:\n"+strings.Join(messages, "\n")) {
+		if !result.Success {
+			zaplogger.Warn("Price alert delivery failed", zaplogger.Fields{
+				"channel": result.Channel,
+				"error":   result.Error,
+			})
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/nsvirk/moneybotsapi/internal/models"
+)
+
+func TestPriceAlertEvaluator(t *testing.T) {
+	tick := func(instrument string, price float64) models.TickerData {
+		return models.TickerData{Instrument: instrument, LastPrice: price}
+	}
+
+	tests := []struct {
+		name   string
+		rules  string
+		cycles [][]models.TickerData
+		want   [][]string
+	}{
+		{
+			name:   "above triggers once",
+			rules:  "NSE:INFY>1500",
+			cycles: [][]models.TickerData{{tick("NSE:INFY", 1490)}, {tick("NSE:INFY", 1510)}, {tick("NSE:INFY", 1520)}},
+			want:   [][]string{nil, {"NSE:INFY at 1510.00 is above 1500.00"}, nil},
+		},
+		{
+			name:   "below re-arms once false again",
+			rules:  "NSE:TCS<3000",
+			cycles: [][]models.TickerData{{tick("NSE:TCS", 2990)}, {tick("NSE:TCS", 3010)}, {tick("NSE:TCS", 2980)}},
+			want:   [][]string{{"NSE:TCS at 2990.00 is below 3000.00"}, nil, {"NSE:TCS at 2980.00 is below 3000.00"}},
+		},
+		{
+			name:   "only the updated instruments are evaluated",
+			rules:  "NSE:INFY>1500, NSE:TCS<3000",
+			cycles: [][]models.TickerData{{tick("NSE:INFY", 1510), tick("NSE:SBIN", 800)}},
+			want:   [][]string{{"NSE:INFY at 1510.00 is above 1500.00"}},
+		},
+		{
+			name:   "no rules",
+			rules:  "",
+			cycles: [][]models.TickerData{{tick("NSE:INFY", 1510)}},
+			want:   [][]string{nil},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			evaluator, err := NewPriceAlertEvaluator(tt.rules)
+			if err != nil {
+				t.Fatalf("NewPriceAlertEvaluator() error = %v", err)
+			}
+			for i, ticks := range tt.cycles {
+				if got := evaluator.Evaluate(ticks); !reflect.DeepEqual(got, tt.want[i]) {
+					t.Errorf("Evaluate() cycle %d = %v, want %v", i, got, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestParsePriceAlert(t *testing.T) {
+	tests := []struct {
+		rule    string
+		want    *PriceAlert
+		wantErr bool
+	}{
+		{rule: "NSE:INFY>1500", want: &PriceAlert{Instrument: "NSE:INFY", Above: true, Price: 1500}},
+		{rule: "NSE:TCS<3000.5", want: &PriceAlert{Instrument: "NSE:TCS", Price: 3000.5}},
+		{rule: "NSE:INFY=1500", wantErr: true},
+		{rule: ">1500", wantErr: true},
+		{rule: "NSE:INFY>abc", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.rule, func(t *testing.T) {
+			got, err := parsePriceAlert(tt.rule)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parsePriceAlert() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parsePriceAlert() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
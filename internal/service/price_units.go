@@ -0,0 +1,83 @@
+// Package service contains the service layer for the Moneybots API
+package service
+
+import (
+	"fmt"
+
+	"github.com/nsvirk/moneybotsapi/internal/models"
+)
+
+// PriceUnit is the currency and unit an instrument's price is quoted in
+type PriceUnit struct {
+	Currency string
+	Unit     string
+}
+
+// defaultPriceUnit is the price unit of equities and of instruments without a known unit
+var defaultPriceUnit = PriceUnit{Currency: "INR"}
+
+// mcxPriceUnits are the price units of MCX commodities, by underlying name
+var mcxPriceUnits = map[string]string{
+	"GOLD":       "10 grams",
+	"GOLDM":      "10 grams",
+	"GOLDGUINEA": "8 grams",
+	"GOLDPETAL":  "1 gram",
+	"SILVER":     "1 kg",
+	"SILVERM":    "1 kg",
+	"SILVERMIC":  "1 kg",
+	"CRUDEOIL":   "1 barrel",
+	"CRUDEOILM":  "1 barrel",
+	"NATURALGAS": "1 mmBtu",
+	"NATGASMINI": "1 mmBtu",
+	"COPPER":     "1 kg",
+	"ZINC":       "1 kg",
+	"ZINCMINI":   "1 kg",
+	"LEAD":       "1 kg",
+	"LEADMINI":   "1 kg",
+	"ALUMINIUM":  "1 kg",
+	"ALUMINI":    "1 kg",
+	"NICKEL":     "1 kg",
+	"COTTON":     "1 bale",
+	"MENTHAOIL":  "1 kg",
+}
+
+// GetPriceUnits returns the price units of the ticks, MCX and CDS instruments are looked up
+// in the instrument master, all others are quoted in INR
+func (s *QuoteService) GetPriceUnits(tickDataMap map[string]*models.TickerData) (map[uint32]PriceUnit, error) {
+	units := make(map[uint32]PriceUnit, len(tickDataMap))
+	tokens := make([]uint32, 0)
+	for instrument, tick := range tickDataMap {
+		units[tick.InstrumentToken] = defaultPriceUnit
+		if exchange := models.InstrumentExchange(instrument); exchange == models.ExchangeMCX || exchange == models.ExchangeCDS {
+			tokens = append(tokens, tick.InstrumentToken)
+		}
+	}
+	if len(tokens) == 0 {
+		return units, nil
+	}
+
+	instruments, err := s.instrumentRepo.GetInstrumentsByTokens(tokens)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching instruments for price units: %v", err)
+	}
+	for _, instrument := range instruments {
+		units[instrument.InstrumentToken] = priceUnitOf(instrument)
+	}
+	return units, nil
+}
+
+// priceUnitOf returns the price unit of an MCX or CDS instrument
+// Currency pairs are quoted in the quote currency per unit of the base currency, e.g. USDINR in INR per 1 USD
+func priceUnitOf(instrument models.InstrumentModel) PriceUnit {
+	switch models.Exchange(instrument.Exchange) {
+	case models.ExchangeMCX:
+		if unit, ok := mcxPriceUnits[instrument.Name]; ok {
+			return PriceUnit{Currency: "INR", Unit: unit}
+		}
+	case models.ExchangeCDS:
+		if len(instrument.Name) == 6 {
+			return PriceUnit{Currency: instrument.Name[3:], Unit: "1 " + instrument.Name[:3]}
+		}
+	}
+	return defaultPriceUnit
+}
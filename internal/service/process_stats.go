@@ -0,0 +1,103 @@
+// Package service contains the service layer for the Moneybots API
+package service
+
+import (
+	"fmt"
+	"runtime"
+	"sync/atomic"
+	"time"
+
+	"github.com/nsvirk/moneybotsapi/pkg/utils/zaplogger"
+)
+
+// processCounters are the counters accumulated since startup
+var processCounters struct {
+	startedAt       time.Time
+	requests        atomic.Uint64
+	requestsByClass [6]atomic.Uint64 // indexed by the first digit of the status
+	quotesServed    atomic.Uint64
+	datasourceCalls atomic.Uint64
+}
+
+func init() {
+	processCounters.startedAt = time.Now()
+}
+
+// ProcessStats are the process level counters since startup
+type ProcessStats struct {
+	StartedAt       time.Time         `json:"started_at"`
+	Uptime          string            `json:"uptime"`
+	Requests        uint64            `json:"requests"`
+	RequestsByClass map[string]uint64 `json:"requests_by_class"`
+	QuotesServed    uint64            `json:"quotes_served"`
+	CacheHits       uint64            `json:"cache_hits"`
+	CacheMisses     uint64            `json:"cache_misses"`
+	CacheHitRatio   float64           `json:"cache_hit_ratio"`
+	DatasourceCalls uint64            `json:"datasource_calls"`
+	ErrorsLogged    uint64            `json:"errors_logged"`
+	Goroutines      int               `json:"goroutines"`
+	Memory          ProcessMemory     `json:"memory"`
+}
+
+// ProcessMemory is a summary of the memory stats of the process
+type ProcessMemory struct {
+	HeapAllocBytes uint64 `json:"heap_alloc_bytes"`
+	HeapObjects    uint64 `json:"heap_objects"`
+	SysBytes       uint64 `json:"sys_bytes"`
+	NumGC          uint32 `json:"num_gc"`
+	PauseTotal     string `json:"pause_total"`
+}
+
+// RecordRequest counts a served request with the response status
+func RecordRequest(status int) {
+	processCounters.requests.Add(1)
+	if class := status / 100; class >= 1 && class < len(processCounters.requestsByClass) {
+		processCounters.requestsByClass[class].Add(1)
+	}
+}
+
+// recordQuotesServed counts the quotes returned by a tick data fetch
+func recordQuotesServed(n int) {
+	processCounters.quotesServed.Add(uint64(n))
+}
+
+// recordDatasourceCall counts a query of the tick data
+func recordDatasourceCall() {
+	processCounters.datasourceCalls.Add(1)
+}
+
+// GetProcessStats returns the process level counters since startup
+func GetProcessStats() ProcessStats {
+	stats := ProcessStats{
+		StartedAt:       processCounters.startedAt,
+		Uptime:          time.Since(processCounters.startedAt).Round(time.Second).String(),
+		Requests:        processCounters.requests.Load(),
+		RequestsByClass: make(map[string]uint64, len(processCounters.requestsByClass)-1),
+		QuotesServed:    processCounters.quotesServed.Load(),
+		DatasourceCalls: processCounters.datasourceCalls.Load(),
+		ErrorsLogged:    zaplogger.ErrorsLogged(),
+		Goroutines:      runtime.NumGoroutine(),
+	}
+	for class := 1; class < len(processCounters.requestsByClass); class++ {
+		stats.RequestsByClass[fmt.Sprintf("%dxx", class)] = processCounters.requestsByClass[class].Load()
+	}
+
+	for _, cache := range GetCacheStats() {
+		stats.CacheHits += cache.Hits
+		stats.CacheMisses += cache.Misses
+	}
+	if lookups := stats.CacheHits + stats.CacheMisses; lookups > 0 {
+		stats.CacheHitRatio = roundTo(float64(stats.CacheHits)/float64(lookups), 4)
+	}
+
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	stats.Memory = ProcessMemory{
+		HeapAllocBytes: mem.HeapAlloc,
+		HeapObjects:    mem.HeapObjects,
+		SysBytes:       mem.Sys,
+		NumGC:          mem.NumGC,
+		PauseTotal:     time.Duration(mem.PauseTotalNs).String(),
+	}
+	return stats
+}
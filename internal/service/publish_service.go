@@ -35,6 +35,7 @@ func (s *PublishService) PublishTicksToRedisChannel() {
 	listener := pq.NewListener(s.pgConnStr, 10*time.Second, time.Minute, nil)
 	err := listener.Listen(PostgresChannel)
 	if err != nil {
+		zaplogger.Error("Failed to listen on the ticker channel", zaplogger.Fields{"channel": PostgresChannel, "error": err})
 		return
 	}
 
@@ -0,0 +1,102 @@
+// Package service contains the service layer for the Moneybots API
+package service
+
+import (
+	"sync"
+	"time"
+
+	"github.com/nsvirk/moneybotsapi/internal/models"
+	"github.com/nsvirk/moneybotsapi/internal/repository"
+	"github.com/nsvirk/moneybotsapi/pkg/utils/clock"
+	"github.com/nsvirk/moneybotsapi/pkg/utils/zaplogger"
+	"gorm.io/gorm"
+)
+
+// QuotaUsage is the usage of a user's daily quota, a Limit of 0 is unlimited
+type QuotaUsage struct {
+	Date      string    `json:"date"`
+	Used      int       `json:"used"`
+	Limit     int       `json:"limit"`
+	Remaining int       `json:"remaining"`
+	ResetAt   time.Time `json:"reset_at"`
+}
+
+// QuotaTracker counts the quota calls of each user per market day, the counts reset at midnight IST
+// The counts are kept in the database, so they survive restarts and are shared by the instances
+type QuotaTracker struct {
+	mu           sync.Mutex
+	defaultQuota int
+	repo         *repository.QuotaRepository
+	day          string // the day the usage of the previous days was deleted
+	clock        clock.Clock
+}
+
+// NewQuotaTracker creates a new QuotaTracker, a defaultQuota of 0 leaves users without their own quota unlimited
+func NewQuotaTracker(defaultQuota int, db *gorm.DB) *QuotaTracker {
+	return &QuotaTracker{
+		defaultQuota: defaultQuota,
+		repo:         repository.NewQuotaRepository(db),
+		clock:        clock.Real,
+	}
+}
+
+// LimitFor returns the daily quota of the session's user, its own quota overrides the default
+func (t *QuotaTracker) LimitFor(session *models.SessionModel) int {
+	if session != nil && session.DailyQuota != nil {
+		return max(*session.DailyQuota, 0)
+	}
+	return max(t.defaultQuota, 0)
+}
+
+// Consume counts a call of the user against limit, the call is not counted when the quota is used up
+func (t *QuotaTracker) Consume(userID string, limit int) (QuotaUsage, bool, error) {
+	now := t.clock.Now()
+	day := t.startDay(now)
+	used, allowed, err := t.repo.IncrementUsage(userID, day, limit)
+	if err != nil {
+		return QuotaUsage{}, false, err
+	}
+	return quotaUsage(day, used, limit, now), allowed, nil
+}
+
+// Usage returns the usage of the user's daily quota
+func (t *QuotaTracker) Usage(userID string, limit int) (QuotaUsage, error) {
+	now := t.clock.Now()
+	day := t.startDay(now)
+	used, err := t.repo.GetUsage(userID, day)
+	if err != nil {
+		return QuotaUsage{}, err
+	}
+	return quotaUsage(day, used, limit, now), nil
+}
+
+// startDay returns the market day of now, the usage of the previous days is deleted once the day has changed
+func (t *QuotaTracker) startDay(now time.Time) string {
+	day := now.In(MarketLocation).Format("2006-01-02")
+	t.mu.Lock()
+	changed := day != t.day
+	t.day = day
+	t.mu.Unlock()
+
+	if changed {
+		if _, err := t.repo.DeleteUsageBefore(day); err != nil {
+			zaplogger.Warn("Failed to delete the quota usage of the previous days", zaplogger.Fields{"error": err})
+		}
+	}
+	return day
+}
+
+// quotaUsage returns the quota usage of a user on the day
+func quotaUsage(day string, used, limit int, now time.Time) QuotaUsage {
+	local := now.In(MarketLocation)
+	usage := QuotaUsage{
+		Date:    day,
+		Used:    used,
+		Limit:   limit,
+		ResetAt: time.Date(local.Year(), local.Month(), local.Day()+1, 0, 0, 0, 0, MarketLocation),
+	}
+	if limit > 0 {
+		usage.Remaining = max(limit-usage.Used, 0)
+	}
+	return usage
+}
@@ -0,0 +1,155 @@
+package service
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"github.com/nsvirk/moneybotsapi/internal/models"
+	"github.com/nsvirk/moneybotsapi/pkg/utils/clock"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// quotaDB opens an in-memory database of the test's own with the quota usage table
+func quotaDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	name := strings.NewReplacer("/", "_", " ", "_").Replace(t.Name())
+	db, err := gorm.Open(sqlite.Open("file:"+name+"?mode=memory&cache=shared"), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("failed to open the database: %v", err)
+	}
+	if err := db.AutoMigrate(&models.QuotaUsageModel{}); err != nil {
+		t.Fatalf("AutoMigrate() error = %v", err)
+	}
+	return db
+}
+
+func TestQuotaTracker(t *testing.T) {
+	tests := []struct {
+		name          string
+		start         time.Time
+		limit         int
+		calls         int
+		wait          time.Duration
+		wantAllowed   bool
+		wantUsed      int
+		wantRemaining int
+		wantDate      string
+	}{
+		{
+			name:  "within the quota",
+			start: time.Date(2024, 10, 15, 10, 0, 0, 0, MarketLocation), limit: 3, calls: 2,
+			wantAllowed: true, wantUsed: 3, wantRemaining: 0, wantDate: "2024-10-15",
+		},
+		{
+			name:  "quota used up",
+			start: time.Date(2024, 10, 15, 10, 0, 0, 0, MarketLocation), limit: 3, calls: 3,
+			wantAllowed: false, wantUsed: 3, wantRemaining: 0, wantDate: "2024-10-15",
+		},
+		{
+			name:  "unlimited",
+			start: time.Date(2024, 10, 15, 10, 0, 0, 0, MarketLocation), limit: 0, calls: 10,
+			wantAllowed: true, wantUsed: 11, wantRemaining: 0, wantDate: "2024-10-15",
+		},
+		{
+			name:  "reset at midnight ist",
+			start: time.Date(2024, 10, 15, 23, 59, 0, 0, MarketLocation), limit: 3, calls: 3, wait: time.Minute,
+			wantAllowed: true, wantUsed: 1, wantRemaining: 2, wantDate: "2024-10-16",
+		},
+		{
+			name:  "no reset at midnight utc",
+			start: time.Date(2024, 10, 15, 23, 59, 0, 0, time.UTC), limit: 3, calls: 3, wait: time.Minute,
+			wantAllowed: false, wantUsed: 3, wantRemaining: 0, wantDate: "2024-10-16",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := clock.NewFake(tt.start)
+			tracker := NewQuotaTracker(0, quotaDB(t))
+			tracker.clock = fake
+			for i := 0; i < tt.calls; i++ {
+				if _, _, err := tracker.Consume("AB1234", tt.limit); err != nil {
+					t.Fatalf("Consume() error = %v", err)
+				}
+			}
+			fake.Advance(tt.wait)
+
+			usage, allowed, err := tracker.Consume("AB1234", tt.limit)
+			if err != nil {
+				t.Fatalf("Consume() error = %v", err)
+			}
+			if allowed != tt.wantAllowed || usage.Used != tt.wantUsed || usage.Remaining != tt.wantRemaining || usage.Date != tt.wantDate {
+				t.Errorf("Consume() = %+v, %v, want used %d, remaining %d on %s, %v",
+					usage, allowed, tt.wantUsed, tt.wantRemaining, tt.wantDate, tt.wantAllowed)
+			}
+			day, _ := time.ParseInLocation("2006-01-02", tt.wantDate, MarketLocation)
+			if wantReset := day.AddDate(0, 0, 1); !usage.ResetAt.Equal(wantReset) {
+				t.Errorf("Consume() reset at = %v, want %v", usage.ResetAt, wantReset)
+			}
+		})
+	}
+}
+
+func TestQuotaTrackerShared(t *testing.T) {
+	db := quotaDB(t)
+	fake := clock.NewFake(time.Date(2024, 10, 15, 10, 0, 0, 0, MarketLocation))
+	newTracker := func() *QuotaTracker {
+		tracker := NewQuotaTracker(0, db)
+		tracker.clock = fake
+		return tracker
+	}
+
+	// the calls of an instance, and of an instance before a restart, count against the same quota
+	first, second := newTracker(), newTracker()
+	for _, tracker := range []*QuotaTracker{first, second, first} {
+		if _, allowed, err := tracker.Consume("AB1234", 3); err != nil || !allowed {
+			t.Fatalf("Consume() = %v, %v, want allowed", allowed, err)
+		}
+	}
+	if _, allowed, _ := newTracker().Consume("AB1234", 3); allowed {
+		t.Errorf("Consume() allowed beyond the quota shared by the trackers")
+	}
+	if usage, err := second.Usage("AB1234", 3); err != nil || usage.Used != 3 {
+		t.Errorf("Usage() = %+v, %v, want 3 used", usage, err)
+	}
+	if usage, err := second.Usage("CD5678", 3); err != nil || usage.Used != 0 || usage.Remaining != 3 {
+		t.Errorf("Usage() of another user = %+v, %v, want none used", usage, err)
+	}
+
+	// the usage of the previous days is deleted on the first call of a day
+	fake.Advance(24 * time.Hour)
+	if _, _, err := newTracker().Consume("CD5678", 3); err != nil {
+		t.Fatalf("Consume() error = %v", err)
+	}
+	var days []string
+	db.Model(&models.QuotaUsageModel{}).Distinct().Pluck("day", &days)
+	if len(days) != 1 || days[0] != "2024-10-16" {
+		t.Errorf("quota usage days = %v, want [2024-10-16]", days)
+	}
+}
+
+func TestQuotaTrackerLimitFor(t *testing.T) {
+	own, negative := 50, -1
+	tests := []struct {
+		name         string
+		defaultQuota int
+		session      *models.SessionModel
+		want         int
+	}{
+		{name: "default quota", defaultQuota: 100, session: &models.SessionModel{}, want: 100},
+		{name: "own quota overrides the default", defaultQuota: 100, session: &models.SessionModel{DailyQuota: &own}, want: 50},
+		{name: "negative own quota is unlimited", defaultQuota: 100, session: &models.SessionModel{DailyQuota: &negative}, want: 0},
+		{name: "no session", defaultQuota: 100, want: 100},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NewQuotaTracker(tt.defaultQuota, nil).LimitFor(tt.session); got != tt.want {
+				t.Errorf("LimitFor() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,97 @@
+// Package service contains the service layer for the Moneybots API
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ErrNotFuturesContract is returned for a basis of an instrument that is not a futures contract
+var ErrNotFuturesContract = errors.New("not a futures contract")
+
+// QuoteBasisLeg is the quote of a leg of the basis
+type QuoteBasisLeg struct {
+	Instrument string    `json:"instrument"`
+	LastPrice  float64   `json:"last_price"`
+	Timestamp  time.Time `json:"timestamp"`
+}
+
+// QuoteBasis is the basis of a futures contract over its spot
+// The percentages are nil when the spot price is 0, the annualized one also on and after the expiry day
+type QuoteBasis struct {
+	Future                 QuoteBasisLeg `json:"future"`
+	Spot                   QuoteBasisLeg `json:"spot"`
+	Expiry                 string        `json:"expiry"`
+	DaysToExpiry           int           `json:"days_to_expiry"`
+	Basis                  float64       `json:"basis"`
+	BasisPercent           *float64      `json:"basis_percent"`
+	AnnualizedBasisPercent *float64      `json:"annualized_basis_percent"`
+	ComputedAt             time.Time     `json:"computed_at"`
+}
+
+// GetQuoteBasis returns the basis of the futures contract fut over the spot instrument,
+// it returns nil if either leg has no quote or fut is not in the instrument master
+func (s *QuoteService) GetQuoteBasis(fut, spot string) (*QuoteBasis, error) {
+	exchange, tradingsymbol, _ := strings.Cut(fut, ":")
+	contract, err := s.instrumentRepo.GetInstrumentByExchangeTradingsymbol(exchange, tradingsymbol)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error fetching the contract of %s: %v", fut, err)
+	}
+	if contract.InstrumentType != "FUT" {
+		return nil, ErrNotFuturesContract
+	}
+	expiry, err := time.ParseInLocation("2006-01-02", contract.Expiry, MarketLocation)
+	if err != nil {
+		return nil, fmt.Errorf("invalid expiry %q of %s", contract.Expiry, fut)
+	}
+
+	ticks, err := s.GetTickData([]string{fut, spot})
+	if err != nil {
+		return nil, err
+	}
+	futTick, spotTick := ticks[fut], ticks[spot]
+	if futTick == nil || spotTick == nil {
+		return nil, nil
+	}
+
+	basis := computeQuoteBasis(futTick.LastPrice, spotTick.LastPrice, expiry, time.Now())
+	basis.Future = QuoteBasisLeg{Instrument: fut, LastPrice: futTick.LastPrice, Timestamp: futTick.Timestamp}
+	basis.Spot = QuoteBasisLeg{Instrument: spot, LastPrice: spotTick.LastPrice, Timestamp: spotTick.Timestamp}
+	basis.Expiry = contract.Expiry
+	return &basis, nil
+}
+
+// computeQuoteBasis computes the basis of the futures price over the spot price, annualized over the
+// calendar days from the market day of now to the expiry
+// On the expiry day the contract has no time left to annualize over, so only the plain basis is computed
+func computeQuoteBasis(futPrice, spotPrice float64, expiry, now time.Time) QuoteBasis {
+	local := now.In(MarketLocation)
+	today := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, MarketLocation)
+
+	basis := QuoteBasis{
+		DaysToExpiry: int(expiry.Sub(today).Hours() / 24),
+		Basis:        roundTo(futPrice-spotPrice, 2),
+		ComputedAt:   now,
+	}
+	if spotPrice == 0 {
+		return basis
+	}
+	percent := (futPrice - spotPrice) / spotPrice * 100
+	basis.BasisPercent = ptrTo(roundTo(percent, 4))
+	if basis.DaysToExpiry > 0 {
+		basis.AnnualizedBasisPercent = ptrTo(roundTo(percent*365/float64(basis.DaysToExpiry), 4))
+	}
+	return basis
+}
+
+// ptrTo returns a pointer to v
+func ptrTo[T any](v T) *T {
+	return &v
+}
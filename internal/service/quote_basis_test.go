@@ -0,0 +1,73 @@
+package service
+
+import (
+	"testing"
+	"time"
+)
+
+func TestComputeQuoteBasis(t *testing.T) {
+	expiry := time.Date(2024, 10, 31, 0, 0, 0, 0, MarketLocation)
+	pct := func(v float64) *float64 { return &v }
+
+	tests := []struct {
+		name           string
+		fut, spot      float64
+		now            time.Time
+		wantDays       int
+		wantBasis      float64
+		wantPercent    *float64
+		wantAnnualized *float64
+	}{
+		{
+			name: "premium", fut: 24600, spot: 24500, now: time.Date(2024, 10, 15, 10, 0, 0, 0, MarketLocation),
+			wantDays: 16, wantBasis: 100, wantPercent: pct(0.4082), wantAnnualized: pct(9.3112),
+		},
+		{
+			name: "discount", fut: 24400, spot: 24500, now: time.Date(2024, 10, 15, 10, 0, 0, 0, MarketLocation),
+			wantDays: 16, wantBasis: -100, wantPercent: pct(-0.4082), wantAnnualized: pct(-9.3112),
+		},
+		{
+			name: "days counted from the ist market day", fut: 24600, spot: 24500, now: time.Date(2024, 10, 15, 20, 0, 0, 0, time.UTC),
+			wantDays: 15, wantBasis: 100, wantPercent: pct(0.4082), wantAnnualized: pct(9.932),
+		},
+		{
+			name: "expiry day is not annualized", fut: 24510, spot: 24500, now: time.Date(2024, 10, 31, 10, 0, 0, 0, MarketLocation),
+			wantDays: 0, wantBasis: 10, wantPercent: pct(0.0408),
+		},
+		{
+			name: "no spot price", fut: 24600, spot: 0, now: time.Date(2024, 10, 15, 10, 0, 0, 0, MarketLocation),
+			wantDays: 16, wantBasis: 24600,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := computeQuoteBasis(tt.fut, tt.spot, expiry, tt.now)
+			if got.DaysToExpiry != tt.wantDays || got.Basis != tt.wantBasis {
+				t.Errorf("computeQuoteBasis() days, basis = %d, %v, want %d, %v", got.DaysToExpiry, got.Basis, tt.wantDays, tt.wantBasis)
+			}
+			if !equalPercent(got.BasisPercent, tt.wantPercent) {
+				t.Errorf("computeQuoteBasis() basis percent = %v, want %v", formatPercent(got.BasisPercent), formatPercent(tt.wantPercent))
+			}
+			if !equalPercent(got.AnnualizedBasisPercent, tt.wantAnnualized) {
+				t.Errorf("computeQuoteBasis() annualized percent = %v, want %v", formatPercent(got.AnnualizedBasisPercent), formatPercent(tt.wantAnnualized))
+			}
+		})
+	}
+}
+
+// equalPercent reports if both percentages are nil or equal
+func equalPercent(a, b *float64) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// formatPercent formats an optional percentage for the test errors
+func formatPercent(p *float64) interface{} {
+	if p == nil {
+		return "nil"
+	}
+	return *p
+}
@@ -0,0 +1,117 @@
+// Package service contains the service layer for the Moneybots API
+package service
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/nsvirk/moneybotsapi/internal/config"
+	"github.com/nsvirk/moneybotsapi/internal/models"
+)
+
+// Rankings of the quote movers
+const (
+	MoversByPercent  = "percent"
+	MoversByAbsolute = "absolute"
+)
+
+// QuoteMover is an index constituent with its change from the previous close
+type QuoteMover struct {
+	Instrument    string  `json:"instrument"`
+	LastPrice     float64 `json:"last_price"`
+	PrevClose     float64 `json:"prev_close"`
+	NetChange     float64 `json:"net_change"`
+	ChangePercent float64 `json:"change_percent"`
+}
+
+// QuoteMovers are the top gainers and losers among the constituents of an index
+// Constituents without a quote or a previous close are excluded
+type QuoteMovers struct {
+	Index      string       `json:"index"`
+	Exchange   string       `json:"exchange"`
+	By         string       `json:"by"`
+	Gainers    []QuoteMover `json:"gainers"`
+	Losers     []QuoteMover `json:"losers"`
+	Excluded   int          `json:"excluded"`
+	ComputedAt time.Time    `json:"computed_at"`
+}
+
+// quoteMoversCache holds the full rankings of the recent movers requests, keyed by `EXCHANGE:INDEX:BY`
+var quoteMoversCache = newTTLCache[QuoteMovers]("quote_movers")
+
+// GetQuoteMovers returns the top limit gainers and losers of the index ranked by percent or absolute change,
+// it returns nil if the index has no constituents
+func (s *QuoteService) GetQuoteMovers(exchange, index, by string, limit int) (*QuoteMovers, error) {
+	key := exchange + ":" + index + ":" + by
+	movers, ok := quoteMoversCache.Get(key)
+	if !ok {
+		constituents, err := getIndexConstituents(s.indexRepo, s.cfg.CacheTTL(config.CacheIndices), exchange, index)
+		if err != nil {
+			return nil, err
+		}
+		if len(constituents) == 0 {
+			return nil, nil
+		}
+		instruments := make([]string, len(constituents))
+		for i, constituent := range constituents {
+			instruments[i] = constituent.Exchange + ":" + constituent.Tradingsymbol
+		}
+
+		tickDataMap, err := s.GetTickData(instruments)
+		if err != nil {
+			return nil, fmt.Errorf("error fetching tick data: %v", err)
+		}
+
+		movers = rankQuoteMovers(instruments, tickDataMap, by)
+		movers.Index = index
+		movers.Exchange = exchange
+		quoteMoversCache.Set(key, movers, s.cfg.CacheTTL(config.CacheQuoteMovers))
+	}
+
+	movers.Gainers = movers.Gainers[:min(limit, len(movers.Gainers))]
+	movers.Losers = movers.Losers[:min(limit, len(movers.Losers))]
+	return &movers, nil
+}
+
+// rankQuoteMovers ranks the gainers by descending and the losers by ascending change, unchanged instruments are in neither
+func rankQuoteMovers(instruments []string, tickDataMap map[string]*models.TickerData, by string) QuoteMovers {
+	movers := QuoteMovers{By: by, Gainers: []QuoteMover{}, Losers: []QuoteMover{}, ComputedAt: time.Now()}
+	for _, instrument := range instruments {
+		tick, ok := tickDataMap[instrument]
+		if !ok || tick.LastPrice <= 0 {
+			movers.Excluded++
+			continue
+		}
+		ohlc, err := tick.GetOHLC()
+		// the ohlc close is the previous day close
+		if err != nil || ohlc.Close <= 0 {
+			movers.Excluded++
+			continue
+		}
+		netChange := tick.LastPrice - ohlc.Close
+		mover := QuoteMover{
+			Instrument:    instrument,
+			LastPrice:     tick.LastPrice,
+			PrevClose:     ohlc.Close,
+			NetChange:     roundTo(netChange, 2),
+			ChangePercent: roundTo(netChange/ohlc.Close*100, 2),
+		}
+		switch {
+		case netChange > 0:
+			movers.Gainers = append(movers.Gainers, mover)
+		case netChange < 0:
+			movers.Losers = append(movers.Losers, mover)
+		}
+	}
+
+	change := func(m QuoteMover) float64 {
+		if by == MoversByAbsolute {
+			return m.NetChange
+		}
+		return m.ChangePercent
+	}
+	sort.SliceStable(movers.Gainers, func(i, j int) bool { return change(movers.Gainers[i]) > change(movers.Gainers[j]) })
+	sort.SliceStable(movers.Losers, func(i, j int) bool { return change(movers.Losers[i]) < change(movers.Losers[j]) })
+	return movers
+}
@@ -0,0 +1,90 @@
+package service
+
+import (
+	"encoding/json"
+	"slices"
+	"testing"
+
+	"github.com/nsvirk/moneybotsapi/internal/models"
+)
+
+func TestRankQuoteMovers(t *testing.T) {
+	// tick returns the ticker data of an instrument with the last price and previous close
+	tick := func(lastPrice, prevClose float64) *models.TickerData {
+		ohlc, _ := json.Marshal(models.TickerDataOHLC{Close: prevClose})
+		return &models.TickerData{LastPrice: lastPrice, OHLC: ohlc}
+	}
+	instruments := []string{"NSE:INFY", "NSE:TCS", "NSE:WIPRO", "NSE:HCLTECH", "NSE:TECHM", "NSE:LTIM", "NSE:MPHASIS"}
+	tickDataMap := map[string]*models.TickerData{
+		"NSE:INFY":    tick(1900, 1800), // +100, +5.56%
+		"NSE:TCS":     tick(4200, 4100), // +100, +2.44%
+		"NSE:WIPRO":   tick(510, 500),   // +10, +2%
+		"NSE:HCLTECH": tick(1700, 1800), // -100, -5.56%
+		"NSE:TECHM":   tick(1640, 1680), // -40, -2.38%
+		"NSE:LTIM":    tick(6000, 6000), // unchanged
+		"NSE:MPHASIS": tick(3000, 0),    // no previous close
+	}
+
+	tests := []struct {
+		name        string
+		by          string
+		wantGainers []string
+		wantLosers  []string
+	}{
+		{
+			name:        "by percent",
+			by:          MoversByPercent,
+			wantGainers: []string{"NSE:INFY", "NSE:TCS", "NSE:WIPRO"},
+			wantLosers:  []string{"NSE:HCLTECH", "NSE:TECHM"},
+		},
+		{
+			name:        "by absolute",
+			by:          MoversByAbsolute,
+			wantGainers: []string{"NSE:INFY", "NSE:TCS", "NSE:WIPRO"},
+			wantLosers:  []string{"NSE:HCLTECH", "NSE:TECHM"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			movers := rankQuoteMovers(append(instruments, "NSE:MISSING"), tickDataMap, tt.by)
+			if got := moverInstruments(movers.Gainers); !slices.Equal(got, tt.wantGainers) {
+				t.Errorf("rankQuoteMovers() gainers = %v, want %v", got, tt.wantGainers)
+			}
+			if got := moverInstruments(movers.Losers); !slices.Equal(got, tt.wantLosers) {
+				t.Errorf("rankQuoteMovers() losers = %v, want %v", got, tt.wantLosers)
+			}
+			if movers.Excluded != 2 {
+				t.Errorf("rankQuoteMovers() excluded = %d, want 2", movers.Excluded)
+			}
+		})
+	}
+
+	t.Run("absolute and percent rankings differ", func(t *testing.T) {
+		tickDataMap := map[string]*models.TickerData{
+			"NSE:MRF":  tick(130500, 130000), // +500, +0.38%
+			"NSE:IDEA": tick(11, 10),         // +1, +10%
+		}
+		byPercent := moverInstruments(rankQuoteMovers([]string{"NSE:MRF", "NSE:IDEA"}, tickDataMap, MoversByPercent).Gainers)
+		byAbsolute := moverInstruments(rankQuoteMovers([]string{"NSE:MRF", "NSE:IDEA"}, tickDataMap, MoversByAbsolute).Gainers)
+		if !slices.Equal(byPercent, []string{"NSE:IDEA", "NSE:MRF"}) || !slices.Equal(byAbsolute, []string{"NSE:MRF", "NSE:IDEA"}) {
+			t.Errorf("rankQuoteMovers() by percent = %v, by absolute = %v", byPercent, byAbsolute)
+		}
+	})
+
+	t.Run("change rounded", func(t *testing.T) {
+		movers := rankQuoteMovers([]string{"NSE:INFY"}, tickDataMap, MoversByPercent)
+		if mover := movers.Gainers[0]; mover.NetChange != 100 || mover.ChangePercent != 5.56 || mover.PrevClose != 1800 {
+			t.Errorf("rankQuoteMovers() mover = %+v, want a 100 and 5.56%% change from 1800", mover)
+		}
+	})
+}
+
+// moverInstruments returns the instruments of the movers in order
+func moverInstruments(movers []QuoteMover) []string {
+	instruments := make([]string, len(movers))
+	for i, mover := range movers {
+		instruments[i] = mover.Instrument
+	}
+	return instruments
+}
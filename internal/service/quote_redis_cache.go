@@ -0,0 +1,85 @@
+// Package service contains the service layer for the Moneybots API
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/nsvirk/moneybotsapi/internal/models"
+	"github.com/redis/go-redis/v9"
+)
+
+// quoteRedisKeyPrefix is the prefix of the Redis keys of the cached tick data, followed by the instrument
+const quoteRedisKeyPrefix = "API:TICKER:DATA:"
+
+// quoteRedisTimeout bounds the Redis calls of the quote cache, a slow Redis falls back to the database
+const quoteRedisTimeout = 200 * time.Millisecond
+
+// quoteRedisCache is the Redis cache of the latest tick data, nil when it is disabled
+var quoteRedisCache *quoteRedisStore
+
+// SetQuoteRedisCache sets the Redis cache of the latest tick data, which the ticker keeps warm on each flush
+// and the quote APIs read before the database, the tick data expires after ttl and a ttl of 0 disables the cache
+func SetQuoteRedisCache(client *redis.Client, ttl time.Duration) {
+	if client == nil || ttl <= 0 {
+		quoteRedisCache = nil
+		return
+	}
+	quoteRedisCache = &quoteRedisStore{client: client, ttl: ttl}
+}
+
+// quoteRedisStore keeps the tick data of each instrument as json under its own key
+type quoteRedisStore struct {
+	client *redis.Client
+	ttl    time.Duration
+}
+
+// put caches the tick data, replacing the tick data cached for the instruments
+func (c *quoteRedisStore) put(tickerData []models.TickerData) error {
+	ctx, cancel := context.WithTimeout(context.Background(), quoteRedisTimeout)
+	defer cancel()
+
+	pipe := c.client.Pipeline()
+	for i := range tickerData {
+		data, err := json.Marshal(&tickerData[i])
+		if err != nil {
+			return err
+		}
+		pipe.Set(ctx, quoteRedisKeyPrefix+tickerData[i].Instrument, data, c.ttl)
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// get returns the cached tick data of the instruments, and the instruments which are not cached
+func (c *quoteRedisStore) get(ctx context.Context, instruments []string) ([]models.TickerData, []string, error) {
+	ctx, cancel := context.WithTimeout(ctx, quoteRedisTimeout)
+	defer cancel()
+
+	keys := make([]string, len(instruments))
+	for i, instrument := range instruments {
+		keys[i] = quoteRedisKeyPrefix + instrument
+	}
+	values, err := c.client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tickerData := make([]models.TickerData, 0, len(instruments))
+	var missing []string
+	for i, value := range values {
+		data, ok := value.(string)
+		if !ok {
+			missing = append(missing, instruments[i])
+			continue
+		}
+		var tick models.TickerData
+		if err := json.Unmarshal([]byte(data), &tick); err != nil {
+			missing = append(missing, instruments[i])
+			continue
+		}
+		tickerData = append(tickerData, tick)
+	}
+	return tickerData, missing, nil
+}
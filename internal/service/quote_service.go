@@ -2,40 +2,408 @@
 package service
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"math"
+	"sort"
+	"strings"
+	"time"
 
+	"github.com/nsvirk/moneybotsapi/internal/config"
 	"github.com/nsvirk/moneybotsapi/internal/models"
+	"github.com/nsvirk/moneybotsapi/internal/repository"
+	"github.com/nsvirk/moneybotsapi/pkg/utils/zaplogger"
+	"golang.org/x/sync/singleflight"
 	"gorm.io/gorm"
 )
 
+// ErrNoTickData is returned when none of the requested instruments has tick data
+var ErrNoTickData = errors.New("no tick data found for any of the requested instruments")
+
+// quoteNegativeCache holds the instruments for which no tick data was found,
+// it is cleared whenever the instruments are updated
+var quoteNegativeCache = newTTLCache[struct{}]("quote_negative")
+
 // QuoteService is the service for the quote API
 type QuoteService struct {
-	db *gorm.DB
+	cfg            *config.Config
+	db             *gorm.DB
+	instrumentRepo *repository.InstrumentRepository
+	candleRepo     *repository.CandleRepository
+	indexRepo      *repository.IndexRepository
+	requestGroup   *singleflight.Group
 }
 
 // NewQuoteService creates a new quote service
-func NewQuoteService(db *gorm.DB) *QuoteService {
-	return &QuoteService{db: db}
+func NewQuoteService(cfg *config.Config, db *gorm.DB) *QuoteService {
+	return &QuoteService{
+		cfg:            cfg,
+		db:             db,
+		instrumentRepo: repository.NewInstrumentRepository(db),
+		candleRepo:     repository.NewCandleRepository(db),
+		indexRepo:      repository.NewIndexRepository(db),
+		requestGroup:   &singleflight.Group{},
+	}
+}
+
+// WithContext returns a copy of the service whose database queries are bound to ctx
+func (s *QuoteService) WithContext(ctx context.Context) *QuoteService {
+	db := s.db.WithContext(ctx)
+	return &QuoteService{
+		cfg:            s.cfg,
+		db:             db,
+		instrumentRepo: repository.NewInstrumentRepository(db),
+		candleRepo:     repository.NewCandleRepository(db),
+		indexRepo:      repository.NewIndexRepository(db),
+		requestGroup:   s.requestGroup,
+	}
 }
 
 // GetTickData gets the tick data for the given instruments
+// Concurrent requests for the same set of instruments share a single database fetch,
+// the returned map is shared between them and must not be modified
+// The shared fetch is not cancelled with the request which started it, it is bounded by the datasource
+// timeout instead, and each request stops waiting for it when its own context is done
 func (s *QuoteService) GetTickData(instruments []string) (map[string]*models.TickerData, error) {
+	if !s.cfg.QuoteCoalesce {
+		tickDataMap, err := s.fetchTickData(instruments)
+		recordQuotesServed(len(tickDataMap))
+		return tickDataMap, err
+	}
+
+	ctx := s.db.Statement.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	results := s.requestGroup.DoChan(coalesceKey(instruments), func() (interface{}, error) {
+		fetchCtx := context.WithoutCancel(ctx)
+		if s.cfg.DatasourceTimeout > 0 {
+			var cancel context.CancelFunc
+			fetchCtx, cancel = context.WithTimeout(fetchCtx, s.cfg.DatasourceTimeout)
+			defer cancel()
+		}
+		return s.WithContext(fetchCtx).fetchTickData(instruments)
+	})
+
+	select {
+	case result := <-results:
+		if result.Err != nil {
+			return nil, result.Err
+		}
+		tickDataMap := result.Val.(map[string]*models.TickerData)
+		recordQuotesServed(len(tickDataMap))
+		return tickDataMap, nil
+	case <-ctx.Done():
+		return nil, fmt.Errorf("tick data request cancelled: %w", ctx.Err())
+	}
+}
+
+// coalesceKey returns the request key for the instruments, independent of their order
+func coalesceKey(instruments []string) string {
+	sorted := make([]string, len(instruments))
+	copy(sorted, instruments)
+	sort.Strings(sorted)
+	return strings.Join(sorted, ",")
+}
+
+// fetchTickData fetches the tick data for the given instruments from the database
+func (s *QuoteService) fetchTickData(instruments []string) (map[string]*models.TickerData, error) {
+	// fast fail instruments recently found to have no data
+	lookupInstruments := make([]string, 0, len(instruments))
+	for _, instrument := range instruments {
+		if _, ok := quoteNegativeCache.Get(instrument); !ok {
+			lookupInstruments = append(lookupInstruments, instrument)
+		}
+	}
+	if len(lookupInstruments) == 0 {
+		return nil, ErrNoTickData
+	}
+
+	// the instruments in the quote cache are not queried, a failed cache read queries them all
 	var tickerData []models.TickerData
-	err := s.db.Where("instrument IN ?", instruments).Find(&tickerData).Error
-	if err != nil {
-		log.Printf("Database query error: %v", err)
-		return nil, fmt.Errorf("error fetching tick data from database: %v", err)
+	if quoteRedisCache != nil {
+		cached, missing, err := quoteRedisCache.get(s.db.Statement.Context, lookupInstruments)
+		if err != nil {
+			zaplogger.Warn("Quote cache read failed", zaplogger.Fields{"error": err.Error()})
+		} else {
+			tickerData = cached
+			lookupInstruments = missing
+		}
+	}
+
+	if len(lookupInstruments) > 0 {
+		var dbTickerData []models.TickerData
+		err := s.queryTickData(lookupInstruments, &dbTickerData)
+		if err != nil {
+			log.Printf("Database query error: %v", err)
+			return nil, fmt.Errorf("error fetching tick data from database: %v", err)
+		}
+
+		if s.cfg.CacheTTL(config.CacheQuoteNegative) > 0 {
+			s.cacheMissingInstruments(dbTickerData, lookupInstruments)
+		}
+		tickerData = append(tickerData, dbTickerData...)
+	}
+
+	if s.cfg.QuoteTickRounding {
+		if err := s.snapToTickSize(tickerData); err != nil {
+			return nil, err
+		}
 	}
 
 	return s.createTickerDataMap(tickerData, instruments)
 }
 
+// queryTickData queries the tick data of the instruments, bounded by the datasource timeout
+// independently of the request's own deadline
+func (s *QuoteService) queryTickData(instruments []string, tickerData *[]models.TickerData) error {
+	ctx := s.db.Statement.Context
+	if s.cfg.DatasourceTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.cfg.DatasourceTimeout)
+		defer cancel()
+	}
+
+	recordDatasourceCall()
+	err := s.db.WithContext(ctx).Where("instrument IN ?", instruments).Find(tickerData).Error
+	if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		zaplogger.Warn("Tick data query timed out", zaplogger.Fields{
+			"timeout":     s.cfg.DatasourceTimeout.String(),
+			"instruments": instruments,
+		})
+	}
+	return err
+}
+
+// cacheMissingInstruments adds the instruments without tick data to the negative cache
+func (s *QuoteService) cacheMissingInstruments(tickerData []models.TickerData, instruments []string) {
+	found := make(map[string]bool, len(tickerData))
+	for _, tick := range tickerData {
+		found[tick.Instrument] = true
+	}
+	for _, instrument := range instruments {
+		if !found[instrument] {
+			quoteNegativeCache.Set(instrument, struct{}{}, s.cfg.CacheTTL(config.CacheQuoteNegative))
+		}
+	}
+}
+
+// snapToTickSize rounds the last price and depth prices to the instrument's tick size
+// Indices have no tick size and are exempt
+func (s *QuoteService) snapToTickSize(tickerData []models.TickerData) error {
+	tokens := make([]uint32, 0, len(tickerData))
+	for _, tick := range tickerData {
+		if !tick.IsIndex {
+			tokens = append(tokens, tick.InstrumentToken)
+		}
+	}
+	if len(tokens) == 0 {
+		return nil
+	}
+
+	instruments, err := s.instrumentRepo.GetInstrumentsByTokens(tokens)
+	if err != nil {
+		return fmt.Errorf("error fetching tick sizes: %v", err)
+	}
+	tickSizes := make(map[uint32]float64, len(instruments))
+	for _, instrument := range instruments {
+		tickSizes[instrument.InstrumentToken] = instrument.TickSize
+	}
+
+	for i := range tickerData {
+		tick := &tickerData[i]
+		tickSize := tickSizes[tick.InstrumentToken]
+		if tick.IsIndex || tickSize <= 0 {
+			continue
+		}
+
+		if snapped := RoundToTick(tick.LastPrice, tickSize); snapped != tick.LastPrice {
+			zaplogger.Warn("Off-tick last price corrected", zaplogger.Fields{
+				"instrument": tick.Instrument,
+				"tick_size":  tickSize,
+				"price":      tick.LastPrice,
+				"snapped":    snapped,
+			})
+			tick.LastPrice = snapped
+		}
+
+		depth, err := tick.GetDepth()
+		if err != nil {
+			continue
+		}
+		corrected := snapDepthItems(depth.Buy[:], tickSize) + snapDepthItems(depth.Sell[:], tickSize)
+		if corrected > 0 {
+			zaplogger.Warn("Off-tick depth prices corrected", zaplogger.Fields{
+				"instrument": tick.Instrument,
+				"tick_size":  tickSize,
+				"corrected":  corrected,
+			})
+			depthJson, err := json.Marshal(depth)
+			if err != nil {
+				return fmt.Errorf("error marshaling depth for %s: %v", tick.Instrument, err)
+			}
+			tick.Depth = depthJson
+		}
+	}
+
+	return nil
+}
+
+// snapDepthItems rounds the depth item prices to the tick size and returns the number corrected
+func snapDepthItems(items []models.TickerDataDepthItem, tickSize float64) int {
+	corrected := 0
+	for i := range items {
+		if snapped := RoundToTick(items[i].Price, tickSize); snapped != items[i].Price {
+			items[i].Price = snapped
+			corrected++
+		}
+	}
+	return corrected
+}
+
+// RoundToTick rounds the price to the nearest multiple of the tick size
+func RoundToTick(price, tickSize float64) float64 {
+	if tickSize <= 0 {
+		return price
+	}
+	ticks := math.Round(price / tickSize)
+	// round to 4 decimals to drop float noise, tick sizes go down to 0.0025
+	return math.Round(ticks*tickSize*10000) / 10000
+}
+
+// VWAPData is the windowed VWAP for an instrument
+type VWAPData struct {
+	VWAP   *float64 `json:"vwap"`
+	Volume uint64   `json:"volume"`
+	Window string   `json:"window"`
+	Source string   `json:"source"`
+}
+
+// GetVWAP computes the VWAP over the window from the intraday samples
+// A zero window uses the full day, falling back to the feed's average price when no samples exist
+func (s *QuoteService) GetVWAP(instruments []string, window time.Duration) (map[string]VWAPData, error) {
+	windowStr := "day"
+	var from time.Time
+	if window > 0 {
+		windowStr = window.String()
+		from = time.Now().Add(-window)
+	}
+
+	result := make(map[string]VWAPData, len(instruments))
+	missing := make([]string, 0)
+	for _, instrument := range instruments {
+		vwap, volume, ok := ComputeVWAP(intradaySampleStore.Buckets(instrument, from))
+		if ok {
+			vwap = math.Round(vwap*100) / 100
+			result[instrument] = VWAPData{VWAP: &vwap, Volume: volume, Window: windowStr, Source: "samples"}
+			continue
+		}
+		if window > 0 {
+			// no volume traded within the window
+			result[instrument] = VWAPData{Window: windowStr, Source: "samples"}
+			continue
+		}
+		missing = append(missing, instrument)
+	}
+
+	if len(missing) > 0 {
+		var tickerData []models.TickerData
+		if err := s.queryTickData(missing, &tickerData); err != nil {
+			return nil, fmt.Errorf("error fetching tick data from database: %v", err)
+		}
+		for _, tick := range tickerData {
+			data := VWAPData{Volume: uint64(tick.VolumeTraded), Window: windowStr, Source: "feed"}
+			if tick.VolumeTraded > 0 {
+				averagePrice := tick.AverageTradePrice
+				data.VWAP = &averagePrice
+			}
+			result[tick.Instrument] = data
+		}
+	}
+
+	return result, nil
+}
+
+// GetTrades returns the last n trades of the day on the trade tape of each instrument, newest first
+// Instruments without trades, such as the ones not on the ticker, have an empty tape
+func (s *QuoteService) GetTrades(instruments []string, n int) map[string][]TapeTrade {
+	now := time.Now()
+	result := make(map[string][]TapeTrade, len(instruments))
+	for _, instrument := range instruments {
+		result[instrument] = tradeTapeStore.Trades(instrument, n, now)
+	}
+	return result
+}
+
+// OIChange is the change in open interest from the previous day
+type OIChange struct {
+	Change        int64
+	ChangePercent *float64
+}
+
+// GetOIChanges returns the OI change from the previous day candle for the derivative ticks
+// Equities, indices and contracts without a previous day OI are absent
+func (s *QuoteService) GetOIChanges(tickDataMap map[string]*models.TickerData) (map[uint32]OIChange, error) {
+	tokens := make([]uint32, 0, len(tickDataMap))
+	for _, tick := range tickDataMap {
+		if !tick.IsIndex {
+			tokens = append(tokens, tick.InstrumentToken)
+		}
+	}
+	if len(tokens) == 0 {
+		return nil, nil
+	}
+
+	instruments, err := s.instrumentRepo.GetInstrumentsByTokens(tokens)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching instrument types: %v", err)
+	}
+	derivativeTokens := make([]uint32, 0, len(instruments))
+	for _, instrument := range instruments {
+		if models.Segment(instrument.Segment).IsDerivative() {
+			derivativeTokens = append(derivativeTokens, instrument.InstrumentToken)
+		}
+	}
+	if len(derivativeTokens) == 0 {
+		return nil, nil
+	}
+
+	now := time.Now().In(MarketLocation)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, MarketLocation)
+	prevOI, err := s.candleRepo.GetPrevDayOI(derivativeTokens, today)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := make(map[uint32]OIChange, len(prevOI))
+	for _, tick := range tickDataMap {
+		prev, ok := prevOI[tick.InstrumentToken]
+		if !ok {
+			continue
+		}
+		changes[tick.InstrumentToken] = computeOIChange(uint64(tick.OI), prev)
+	}
+	return changes, nil
+}
+
+// computeOIChange computes the OI change, the percent is omitted when the previous OI is zero
+func computeOIChange(oi, prevOI uint64) OIChange {
+	change := OIChange{Change: int64(oi) - int64(prevOI)}
+	if prevOI > 0 {
+		percent := math.Round(float64(change.Change)/float64(prevOI)*10000) / 100
+		change.ChangePercent = &percent
+	}
+	return change
+}
+
 // createTickerDataMap creates a map of ticker data for the given instruments
 func (s *QuoteService) createTickerDataMap(tickerData []models.TickerData, instruments []string) (map[string]*models.TickerData, error) {
 	if len(tickerData) == 0 {
 		log.Printf("No tick data found for instruments: %v", instruments)
-		return nil, fmt.Errorf("no tick data found for any of the requested instruments")
+		return nil, ErrNoTickData
 	}
 
 	tickerDataMap := make(map[string]*models.TickerData)
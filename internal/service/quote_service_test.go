@@ -0,0 +1,178 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/nsvirk/moneybotsapi/internal/config"
+	"github.com/nsvirk/moneybotsapi/internal/models"
+	"github.com/nsvirk/moneybotsapi/internal/repository"
+	"gorm.io/gorm"
+)
+
+func TestRoundToTick(t *testing.T) {
+	tests := []struct {
+		name     string
+		price    float64
+		tickSize float64
+		want     float64
+	}{
+		{name: "on tick", price: 1500.05, tickSize: 0.05, want: 1500.05},
+		{name: "off tick rounded down", price: 1500.07, tickSize: 0.05, want: 1500.05},
+		{name: "off tick rounded up", price: 1500.08, tickSize: 0.05, want: 1500.1},
+		{name: "bse tick size", price: 101.03, tickSize: 0.01, want: 101.03},
+		{name: "currency tick size", price: 83.1013, tickSize: 0.0025, want: 83.1025},
+		{name: "whole rupee tick size", price: 72150.4, tickSize: 1, want: 72150},
+		{name: "zero tick size", price: 1500.07, tickSize: 0, want: 1500.07},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := RoundToTick(tt.price, tt.tickSize); got != tt.want {
+				t.Errorf("RoundToTick(%v, %v) = %v, want %v", tt.price, tt.tickSize, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSnapDepthItems(t *testing.T) {
+	items := []models.TickerDataDepthItem{
+		{Price: 1500.05, Quantity: 10, Orders: 1},
+		{Price: 1500.07, Quantity: 20, Orders: 2},
+		{Price: 0, Quantity: 0, Orders: 0},
+		{Price: 1499.93, Quantity: 30, Orders: 3},
+	}
+
+	if corrected := snapDepthItems(items, 0.05); corrected != 2 {
+		t.Errorf("snapDepthItems() corrected = %d, want 2", corrected)
+	}
+	want := []float64{1500.05, 1500.05, 0, 1499.95}
+	for i, item := range items {
+		if item.Price != want[i] {
+			t.Errorf("snapDepthItems() item %d price = %v, want %v", i, item.Price, want[i])
+		}
+	}
+}
+
+func TestComputeOIChange(t *testing.T) {
+	tests := []struct {
+		name        string
+		oi, prevOI  uint64
+		wantChange  int64
+		wantPercent float64
+		wantNoPct   bool
+	}{
+		{name: "oi added", oi: 1200, prevOI: 1000, wantChange: 200, wantPercent: 20},
+		{name: "oi shed", oi: 750, prevOI: 1000, wantChange: -250, wantPercent: -25},
+		{name: "percent rounded to two decimals", oi: 1001, prevOI: 3000, wantChange: -1999, wantPercent: -66.63},
+		{name: "unchanged", oi: 1000, prevOI: 1000, wantChange: 0, wantPercent: 0},
+		{name: "no previous oi", oi: 500, prevOI: 0, wantChange: 500, wantNoPct: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := computeOIChange(tt.oi, tt.prevOI)
+			if got.Change != tt.wantChange {
+				t.Errorf("computeOIChange() change = %d, want %d", got.Change, tt.wantChange)
+			}
+			if tt.wantNoPct {
+				if got.ChangePercent != nil {
+					t.Errorf("computeOIChange() percent = %v, want none", *got.ChangePercent)
+				}
+				return
+			}
+			if got.ChangePercent == nil || *got.ChangePercent != tt.wantPercent {
+				t.Errorf("computeOIChange() percent = %v, want %v", got.ChangePercent, tt.wantPercent)
+			}
+		})
+	}
+}
+
+func TestGetOIChanges(t *testing.T) {
+	cfg := &config.Config{FeedSampleInstr: "NSE:INFY"}
+	db, err := repository.ConnectMemory(cfg)
+	if err != nil {
+		t.Fatalf("ConnectMemory() error = %v", err)
+	}
+
+	future := models.InstrumentModel{InstrumentToken: 200001, Tradingsymbol: "INFY24OCTFUT", Name: "INFY", InstrumentType: "FUT", Segment: "NFO-FUT", Exchange: "NFO"}
+	if err := db.Create(&future).Error; err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now().In(MarketLocation)
+	yesterday := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, MarketLocation).AddDate(0, 0, -1)
+	prevDay := models.CandleModel{InstrumentToken: 200001, Interval: models.CandleIntervalDay, Timestamp: yesterday, OI: 1000}
+	if err := db.Create(&prevDay).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	changes, err := NewQuoteService(cfg, db).GetOIChanges(map[string]*models.TickerData{
+		"NSE:INFY":         {InstrumentToken: 100001, OI: 0},
+		"NFO:INFY24OCTFUT": {InstrumentToken: 200001, OI: 1200},
+	})
+	if err != nil {
+		t.Fatalf("GetOIChanges() error = %v", err)
+	}
+	if _, ok := changes[100001]; ok {
+		t.Errorf("GetOIChanges() has the equity, want it omitted")
+	}
+	if change, ok := changes[200001]; !ok || change.Change != 200 {
+		t.Errorf("GetOIChanges() future change = %+v, want 200", change)
+	}
+}
+
+func TestQueryTickDataTimeout(t *testing.T) {
+	tests := []struct {
+		name        string
+		timeout     time.Duration
+		wantTimeout bool
+	}{
+		{name: "slow query cancelled at the timeout", timeout: 50 * time.Millisecond, wantTimeout: true},
+		{name: "no timeout", timeout: 0},
+	}
+
+	// the datasource takes 300ms, unless its context is done first
+	const slow = 300 * time.Millisecond
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := instrumentsDB(t)
+			if err := db.AutoMigrate(&models.TickerData{}); err != nil {
+				t.Fatalf("AutoMigrate() error = %v", err)
+			}
+			err := db.Callback().Query().Before("gorm:query").Register("test:slow_datasource", func(db *gorm.DB) {
+				select {
+				case <-db.Statement.Context.Done():
+					db.AddError(db.Statement.Context.Err())
+				case <-time.After(slow):
+				}
+			})
+			if err != nil {
+				t.Fatalf("Register() error = %v", err)
+			}
+			s := NewQuoteService(&config.Config{DatasourceTimeout: tt.timeout}, db)
+
+			var tickerData []models.TickerData
+			start := time.Now()
+			err = s.queryTickData([]string{"NSE:INFY"}, &tickerData)
+			elapsed := time.Since(start)
+
+			if tt.wantTimeout {
+				if !errors.Is(err, context.DeadlineExceeded) {
+					t.Errorf("queryTickData() error = %v, want %v", err, context.DeadlineExceeded)
+				}
+				if elapsed >= slow {
+					t.Errorf("queryTickData() took %v, want it cancelled at %v", elapsed, tt.timeout)
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("queryTickData() error = %v, want nil", err)
+			}
+			if elapsed < slow {
+				t.Errorf("queryTickData() took %v, want the whole %v of the datasource", elapsed, slow)
+			}
+		})
+	}
+}
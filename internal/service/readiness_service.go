@@ -0,0 +1,232 @@
+// Package service contains the service layer for the Moneybots API
+package service
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/nsvirk/moneybotsapi/internal/config"
+	"github.com/nsvirk/moneybotsapi/internal/repository"
+	"github.com/nsvirk/moneybotsapi/pkg/utils/state"
+	"github.com/nsvirk/moneybotsapi/pkg/utils/zaplogger"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+)
+
+// dependencyCheckTimeout bounds each ping of the dependency checks
+const dependencyCheckTimeout = 2 * time.Second
+
+// instrumentsRecheck is how often the instruments are checked until they are loaded
+const instrumentsRecheck = time.Second
+
+// Statuses of a dependency check
+const (
+	DependencyUp       = "up"
+	DependencyDown     = "down"
+	DependencyDisabled = "disabled"
+)
+
+// Dependencies checked for the readiness, in the order their failures are reported
+const (
+	DependencyPostgres    = "postgres"
+	DependencyRedis       = "redis"
+	DependencyInstruments = "instruments"
+	DependencyTicker      = "ticker"
+)
+
+var readinessDependencies = []string{DependencyPostgres, DependencyRedis, DependencyInstruments, DependencyTicker}
+
+// quotesRefreshed is set once the quotes have been refreshed since startup
+var quotesRefreshed atomic.Bool
+
+// MarkQuotesRefreshed records that the quotes have been refreshed since startup
+func MarkQuotesRefreshed() {
+	quotesRefreshed.Store(true)
+}
+
+// Readiness is the readiness of the API to serve quotes
+// While the market is closed the stored quotes are final, so no refresh is waited for
+type Readiness struct {
+	Ready             bool                       `json:"ready"`
+	InstrumentsLoaded bool                       `json:"instruments_loaded"`
+	QuotesRefreshed   bool                       `json:"quotes_refreshed"`
+	MarketOpen        bool                       `json:"market_open"`
+	Checks            map[string]DependencyCheck `json:"checks,omitempty"`
+}
+
+// DependencyCheck is the status of a dependency of the API, only the required dependencies affect the readiness
+type DependencyCheck struct {
+	Status    string `json:"status"`
+	Required  bool   `json:"required"`
+	Latency   string `json:"latency,omitempty"`
+	UpdatedAt string `json:"updated_at,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// Reason returns why the API is not ready, or empty when it is
+func (r Readiness) Reason() string {
+	if r.Ready {
+		return ""
+	}
+	for _, name := range readinessDependencies {
+		if check, ok := r.Checks[name]; ok && check.Required && check.Status == DependencyDown {
+			if check.Error != "" {
+				return fmt.Sprintf("Dependency %s is down: %s", name, check.Error)
+			}
+			return fmt.Sprintf("Dependency %s is down", name)
+		}
+	}
+	switch {
+	case !r.InstrumentsLoaded:
+		return "Instruments are not loaded yet"
+	default:
+		return "Quotes are not refreshed yet"
+	}
+}
+
+// ReadinessService is the service for the readiness of the API
+type ReadinessService struct {
+	cfg               *config.Config
+	db                *gorm.DB
+	redisClient       *redis.Client
+	state             *state.State
+	instrumentRepo    *repository.InstrumentRepository
+	marketService     *MarketService
+	instrumentsLoaded atomic.Bool  // instruments are only checked until they are loaded
+	instrumentsCheck  atomic.Int64 // unix nanos of the last instruments check
+}
+
+// NewReadinessService creates a new ReadinessService
+func NewReadinessService(cfg *config.Config, db *gorm.DB, redisClient *redis.Client) *ReadinessService {
+	stateManager, err := state.NewState(db)
+	if err != nil {
+		zaplogger.Fatal("failed to create state manager", zaplogger.Fields{"error": err})
+	}
+	return &ReadinessService{
+		cfg:            cfg,
+		db:             db,
+		redisClient:    redisClient,
+		state:          stateManager,
+		instrumentRepo: repository.NewInstrumentRepository(db),
+		marketService:  NewMarketService(cfg),
+	}
+}
+
+// GetReadiness returns the readiness, the API is ready once the instruments are loaded
+// and, while the market is open, the quotes have been refreshed at least once
+// It is called on each quote request, so until the instruments are loaded they are checked at most
+// once every instrumentsRecheck, and once loaded they are not checked again
+func (s *ReadinessService) GetReadiness() (Readiness, error) {
+	if !s.instrumentsLoaded.Load() {
+		now := time.Now().UnixNano()
+		if last := s.instrumentsCheck.Load(); now-last >= int64(instrumentsRecheck) && s.instrumentsCheck.CompareAndSwap(last, now) {
+			loaded, err := s.instrumentRepo.HasInstruments()
+			if err != nil {
+				// the next request checks again
+				s.instrumentsCheck.Store(0)
+				return Readiness{}, err
+			}
+			if loaded {
+				s.instrumentsLoaded.Store(true)
+			}
+		}
+	}
+
+	readiness := Readiness{
+		InstrumentsLoaded: s.instrumentsLoaded.Load(),
+		QuotesRefreshed:   quotesRefreshed.Load(),
+		MarketOpen:        s.marketService.IsMarketOpen(s.marketService.Now()),
+	}
+	readiness.Ready = readiness.InstrumentsLoaded && (readiness.QuotesRefreshed || !readiness.MarketOpen)
+	return readiness, nil
+}
+
+// CheckReadiness returns the readiness with the checks of the dependencies, for the readiness probes
+// Unlike GetReadiness, which gates the quotes on each request, it pings Postgres and Redis,
+// so the API is not ready while a required dependency is down
+// The ticker only runs in the market hours, so it is reported without affecting the readiness
+func (s *ReadinessService) CheckReadiness(ctx context.Context) (Readiness, error) {
+	checks := map[string]DependencyCheck{
+		DependencyPostgres: s.checkPostgres(ctx),
+		DependencyRedis:    s.checkRedis(ctx),
+		DependencyTicker:   s.checkTicker(),
+	}
+
+	readiness, err := s.GetReadiness()
+	if err != nil {
+		if checks[DependencyPostgres].Status != DependencyDown {
+			return Readiness{}, err
+		}
+		// the instruments cannot be counted without Postgres
+		readiness = Readiness{
+			InstrumentsLoaded: s.instrumentsLoaded.Load(),
+			QuotesRefreshed:   quotesRefreshed.Load(),
+			MarketOpen:        s.marketService.IsMarketOpen(s.marketService.Now()),
+		}
+	}
+	checks[DependencyInstruments] = s.checkInstruments(readiness.InstrumentsLoaded)
+
+	readiness.Checks = checks
+	for _, check := range checks {
+		if check.Required && check.Status == DependencyDown {
+			readiness.Ready = false
+		}
+	}
+	return readiness, nil
+}
+
+// checkPostgres pings Postgres, in-memory storage is checked the same way
+func (s *ReadinessService) checkPostgres(ctx context.Context) DependencyCheck {
+	start := time.Now()
+	err := repository.PingDB(ctx, s.db, dependencyCheckTimeout)
+	return pingCheck(time.Since(start), err)
+}
+
+// checkRedis pings Redis, in-memory storage runs without it
+func (s *ReadinessService) checkRedis(ctx context.Context) DependencyCheck {
+	if s.cfg.IsMemoryStorage() || s.redisClient == nil {
+		return DependencyCheck{Status: DependencyDisabled}
+	}
+	ctx, cancel := context.WithTimeout(ctx, dependencyCheckTimeout)
+	defer cancel()
+	start := time.Now()
+	err := s.redisClient.Ping(ctx).Err()
+	return pingCheck(time.Since(start), err)
+}
+
+// checkTicker reports if a ticker is connected, it is not required
+func (s *ReadinessService) checkTicker() DependencyCheck {
+	if TickerConnected() {
+		return DependencyCheck{Status: DependencyUp}
+	}
+	return DependencyCheck{Status: DependencyDown}
+}
+
+// checkInstruments reports if the instruments are loaded and when they were last synced
+func (s *ReadinessService) checkInstruments(loaded bool) DependencyCheck {
+	check := DependencyCheck{Status: DependencyUp, Required: true}
+	if !loaded {
+		check.Status = DependencyDown
+		check.Error = "instruments are not loaded yet"
+	}
+	if updatedAt, err := s.state.Get(instrumentsUpdatedAtKey); err == nil {
+		check.UpdatedAt = updatedAt
+	}
+	return check
+}
+
+// pingCheck returns the check of a required dependency from its ping
+func pingCheck(latency time.Duration, err error) DependencyCheck {
+	check := DependencyCheck{
+		Status:   DependencyUp,
+		Required: true,
+		Latency:  latency.Round(time.Microsecond).String(),
+	}
+	if err != nil {
+		check.Status = DependencyDown
+		check.Error = err.Error()
+	}
+	return check
+}
@@ -0,0 +1,56 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"github.com/nsvirk/moneybotsapi/internal/config"
+	"github.com/nsvirk/moneybotsapi/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestGetReadinessInstrumentsCheck(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file:readiness?mode=memory&cache=shared"), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("failed to open the database: %v", err)
+	}
+	if err := db.AutoMigrate(&models.InstrumentModel{}); err != nil {
+		t.Fatalf("AutoMigrate() error = %v", err)
+	}
+	s := NewReadinessService(&config.Config{}, db, nil)
+
+	loaded := func() bool {
+		t.Helper()
+		readiness, err := s.GetReadiness()
+		if err != nil {
+			t.Fatalf("GetReadiness() error = %v", err)
+		}
+		return readiness.InstrumentsLoaded
+	}
+
+	if loaded() {
+		t.Fatalf("GetReadiness() instruments loaded before any instrument")
+	}
+	if err := db.Create(&models.InstrumentModel{InstrumentToken: 408065, Exchange: "NSE", Tradingsymbol: "INFY"}).Error; err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if loaded() {
+		t.Errorf("GetReadiness() checked the instruments again within %v", instrumentsRecheck)
+	}
+
+	// the next check is due
+	s.instrumentsCheck.Store(0)
+	if !loaded() {
+		t.Fatalf("GetReadiness() instruments not loaded")
+	}
+
+	// once loaded the instruments are not checked again
+	if err := db.Migrator().DropTable(&models.InstrumentModel{}); err != nil {
+		t.Fatalf("DropTable() error = %v", err)
+	}
+	s.instrumentsCheck.Store(0)
+	if !loaded() {
+		t.Errorf("GetReadiness() instruments not loaded after they were loaded")
+	}
+}
@@ -0,0 +1,188 @@
+// Package service contains the service layer for the Moneybots API
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/nsvirk/moneybotsapi/internal/config"
+	"github.com/nsvirk/moneybotsapi/pkg/utils/zaplogger"
+)
+
+// redactedValue replaces the value of a secret header or query param in a capture
+const redactedValue = "[REDACTED]"
+
+// capturedSecrets are the header and query param name patterns redacted in a capture,
+// `auth` covers Authorization and `key` covers api key headers such as X-Api-Key
+var capturedSecrets = []string{"auth", "key", "token", "secret", "password", "cookie", "totp"}
+
+// captureQueueSize is the number of captures queued for writing before captures are dropped
+const captureQueueSize = 64
+
+// RequestCapture is a captured request/response pair, it can be replayed against a dev instance
+type RequestCapture struct {
+	RequestID     string              `json:"request_id"`
+	Timestamp     time.Time           `json:"timestamp"`
+	Method        string              `json:"method"`
+	Path          string              `json:"path"`
+	Route         string              `json:"route"`
+	PathParams    map[string]string   `json:"path_params,omitempty"`
+	QueryParams   map[string][]string `json:"query_params,omitempty"`
+	Headers       map[string][]string `json:"headers,omitempty"`
+	Status        int                 `json:"status"`
+	RequestBytes  int64               `json:"request_bytes"`
+	ResponseBytes int64               `json:"response_bytes"`
+	LatencyMs     int64               `json:"latency_ms"`
+}
+
+// RequestCapturer writes sampled request captures as JSON files to the capture dir
+type RequestCapturer struct {
+	dir       string
+	routes    []string
+	percent   int
+	enabled   atomic.Bool
+	queue     chan *RequestCapture
+	startOnce sync.Once
+}
+
+// NewRequestCapturer creates a new RequestCapturer, capturing stays off until enabled by an admin
+func NewRequestCapturer(cfg *config.Config) *RequestCapturer {
+	var routes []string
+	for _, route := range strings.Split(cfg.CaptureRoutes, ",") {
+		if route = strings.TrimSpace(route); route != "" {
+			routes = append(routes, route)
+		}
+	}
+	return &RequestCapturer{
+		dir:     cfg.CaptureDir,
+		routes:  routes,
+		percent: cfg.CapturePercent,
+		queue:   make(chan *RequestCapture, captureQueueSize),
+	}
+}
+
+// Available checks if a capture dir is configured
+func (r *RequestCapturer) Available() bool {
+	return r.dir != ""
+}
+
+// Enabled checks if capturing is turned on
+func (r *RequestCapturer) Enabled() bool {
+	return r.enabled.Load()
+}
+
+// SetEnabled turns capturing on or off, it can only be turned on with a capture dir
+func (r *RequestCapturer) SetEnabled(enabled bool) error {
+	if enabled && !r.Available() {
+		return fmt.Errorf("request capture is not available, `MB_API_CAPTURE_DIR` is not set")
+	}
+	if enabled {
+		if err := os.MkdirAll(r.dir, 0o750); err != nil {
+			return fmt.Errorf("failed to create capture dir: %v", err)
+		}
+	}
+	r.enabled.Store(enabled)
+	return nil
+}
+
+// ShouldCapture checks if a request to the path is captured, applying the route filter and the sampling rate
+func (r *RequestCapturer) ShouldCapture(path string) bool {
+	if !r.Enabled() || r.percent <= 0 || !r.matchesRoute(path) {
+		return false
+	}
+	return r.percent >= 100 || rand.Intn(100) < r.percent
+}
+
+// matchesRoute checks if the path starts with one of the configured routes, no routes match all paths
+func (r *RequestCapturer) matchesRoute(path string) bool {
+	if len(r.routes) == 0 {
+		return true
+	}
+	for _, route := range r.routes {
+		if strings.HasPrefix(path, route) {
+			return true
+		}
+	}
+	return false
+}
+
+// Enqueue redacts the secrets of the capture and queues it to be written off the request path,
+// it returns false when the capture is dropped as the queue is full
+func (r *RequestCapturer) Enqueue(capture *RequestCapture) bool {
+	capture.Headers = redactCaptured(capture.Headers)
+	capture.QueryParams = redactCaptured(capture.QueryParams)
+	r.startOnce.Do(func() {
+		go r.run()
+	})
+	select {
+	case r.queue <- capture:
+		return true
+	default:
+		return false
+	}
+}
+
+// run writes the queued captures
+func (r *RequestCapturer) run() {
+	for capture := range r.queue {
+		if _, err := r.Write(capture); err != nil {
+			zaplogger.Warn("Failed to write request capture", zaplogger.Fields{"error": err.Error()})
+		}
+	}
+}
+
+// Write redacts the secrets of the capture and writes it to a new file in the capture dir
+func (r *RequestCapturer) Write(capture *RequestCapture) (string, error) {
+	capture.Headers = redactCaptured(capture.Headers)
+	capture.QueryParams = redactCaptured(capture.QueryParams)
+
+	data, err := json.MarshalIndent(capture, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal capture: %v", err)
+	}
+
+	id := capture.RequestID
+	if id == "" {
+		id = fmt.Sprintf("%d", rand.Int63())
+	}
+	name := fmt.Sprintf("%s_%s.json", capture.Timestamp.UTC().Format("20060102T150405.000000000"), filepath.Base(id))
+	path := filepath.Join(r.dir, name)
+	if err := os.WriteFile(path, data, 0o640); err != nil {
+		return "", fmt.Errorf("failed to write capture: %v", err)
+	}
+	return path, nil
+}
+
+// redactCaptured returns a copy of the values with the secret names redacted
+func redactCaptured(values map[string][]string) map[string][]string {
+	if len(values) == 0 {
+		return nil
+	}
+	redacted := make(map[string][]string, len(values))
+	for name, v := range values {
+		if isCapturedSecret(name) {
+			redacted[name] = []string{redactedValue}
+			continue
+		}
+		redacted[name] = append([]string{}, v...)
+	}
+	return redacted
+}
+
+// isCapturedSecret checks if the header or query param name looks like a secret
+func isCapturedSecret(name string) bool {
+	name = strings.ToLower(name)
+	for _, secret := range capturedSecrets {
+		if strings.Contains(name, secret) {
+			return true
+		}
+	}
+	return false
+}
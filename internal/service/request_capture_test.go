@@ -0,0 +1,93 @@
+package service
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/nsvirk/moneybotsapi/internal/config"
+)
+
+func TestIsCapturedSecret(t *testing.T) {
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{name: "Authorization", want: true},
+		{name: "X-Api-Key", want: true},
+		{name: "api_key", want: true},
+		{name: "X-Auth-User", want: true},
+		{name: "access_token", want: true},
+		{name: "Client-Secret", want: true},
+		{name: "password", want: true},
+		{name: "Cookie", want: true},
+		{name: "totp_value", want: true},
+		{name: "Accept", want: false},
+		{name: "User-Agent", want: false},
+		{name: "i", want: false},
+		{name: "depth", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isCapturedSecret(tt.name); got != tt.want {
+				t.Errorf("isCapturedSecret(%q) = %v, want %v", tt.name, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRequestCapturerEnqueue(t *testing.T) {
+	dir := t.TempDir()
+	capturer := NewRequestCapturer(&config.Config{CaptureDir: dir, CapturePercent: 100})
+	headers := map[string][]string{
+		"X-Api-Key":     {"k-123"},
+		"Authorization": {"token user:enctoken"},
+		"Accept":        {"application/json"},
+	}
+	query := map[string][]string{
+		"i":       {"NSE:INFY"},
+		"api_key": {"k-456"},
+	}
+	capture := &RequestCapture{RequestID: "req-1", Timestamp: time.Now(), Method: "GET", Path: "/quote", Headers: headers, QueryParams: query}
+	if !capturer.Enqueue(capture) {
+		t.Fatalf("Enqueue() = false, want true")
+	}
+	if headers["X-Api-Key"][0] != "k-123" {
+		t.Errorf("Enqueue() changed the request headers, X-Api-Key = %q", headers["X-Api-Key"][0])
+	}
+
+	// the capture is written in the background
+	var files []string
+	for deadline := time.Now().Add(2 * time.Second); len(files) == 0 && time.Now().Before(deadline); {
+		time.Sleep(5 * time.Millisecond)
+		files, _ = filepath.Glob(filepath.Join(dir, "*_req-1.json"))
+	}
+	if len(files) != 1 {
+		t.Fatalf("capture files = %v, want 1 file", files)
+	}
+	data, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+	var got RequestCapture
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+
+	redacted := map[string]string{
+		"header X-Api-Key":     got.Headers["X-Api-Key"][0],
+		"header Authorization": got.Headers["Authorization"][0],
+		"param api_key":        got.QueryParams["api_key"][0],
+	}
+	for name, value := range redacted {
+		if value != redactedValue {
+			t.Errorf("%s = %q, want %q", name, value, redactedValue)
+		}
+	}
+	if got.Headers["Accept"][0] != "application/json" || got.QueryParams["i"][0] != "NSE:INFY" {
+		t.Errorf("capture = %+v, want Accept and i kept", got)
+	}
+}
@@ -0,0 +1,127 @@
+// Package service contains the service layer for the Moneybots API
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/nsvirk/moneybotsapi/internal/models"
+	"gorm.io/gorm"
+)
+
+// jwtAuth is how the session JWTs are signed, JWTs are not issued or accepted without a secret
+var jwtAuth = struct {
+	secret []byte
+	ttl    time.Duration
+}{}
+
+// SetJWTAuth sets the HMAC secret the session JWTs are signed with and how long they are valid
+func SetJWTAuth(secret string, ttl time.Duration) {
+	jwtAuth.secret = []byte(secret)
+	jwtAuth.ttl = ttl
+}
+
+// JWTEnabled reports if session JWTs are issued at login and accepted by the AuthMiddleware
+func JWTEnabled() bool {
+	return len(jwtAuth.secret) > 0
+}
+
+// sessionClaims are the claims of a session JWT, the subject is the user id
+// The fingerprint ties the JWT to the enctoken of the session it was issued for,
+// so it is revoked along with the session by a new login or a logout
+type sessionClaims struct {
+	Fingerprint string `json:"fpr"`
+	jwt.StandardClaims
+}
+
+// SessionLogin is a session along with its JWT, the JWT is left out when JWTs are disabled
+type SessionLogin struct {
+	models.SessionModel
+	AccessToken          string     `json:"access_token,omitempty"`
+	AccessTokenExpiresAt *time.Time `json:"access_token_expires_at,omitempty"`
+}
+
+// NewSessionLogin creates the login response of the session, issuing its JWT when JWTs are enabled
+func NewSessionLogin(session models.SessionModel) (SessionLogin, error) {
+	login := SessionLogin{SessionModel: session}
+	if !JWTEnabled() {
+		return login, nil
+	}
+	now := time.Now()
+	expiresAt := now.Add(jwtAuth.ttl)
+	claims := sessionClaims{
+		Fingerprint: enctokenFingerprint(session.Enctoken),
+		StandardClaims: jwt.StandardClaims{
+			Subject:   session.UserId,
+			IssuedAt:  now.Unix(),
+			ExpiresAt: expiresAt.Unix(),
+		},
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(jwtAuth.secret)
+	if err != nil {
+		return login, fmt.Errorf("failed to sign access token: %v", err)
+	}
+	login.AccessToken = token
+	login.AccessTokenExpiresAt = &expiresAt
+	return login, nil
+}
+
+// VerifySessionJWT verifies the session JWT and returns the session it was issued for
+// Like VerifyUserAuthorization the enctoken of the session is checked with Kite and the session must be enabled
+func (s *SessionService) VerifySessionJWT(tokenString string) (*models.SessionModel, error) {
+	if !JWTEnabled() {
+		return nil, errors.New("access tokens are not enabled")
+	}
+	var claims sessionClaims
+	_, err := jwt.ParseWithClaims(tokenString, &claims, func(token *jwt.Token) (interface{}, error) {
+		if token.Method != jwt.SigningMethodHS256 {
+			return nil, fmt.Errorf("unexpected signing method %s", token.Header["alg"])
+		}
+		return jwtAuth.secret, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("invalid access token: %v", err)
+	}
+	userID := claims.Subject
+
+	session, err := s.repo.GetSessionByUserId(userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("`user_id` %s not found", userID)
+		}
+		// only a recently verified session of the same enctoken passes the fallback
+		enctoken := ""
+		if cached, ok := authSessionCache.Get(userID); ok && enctokenFingerprint(cached.Enctoken) == claims.Fingerprint {
+			enctoken = cached.Enctoken
+		}
+		return fallbackSession(userID, enctoken, err)
+	}
+	if session.Disabled {
+		return nil, fmt.Errorf("`user_id` %s is disabled", userID)
+	}
+	if enctokenFingerprint(session.Enctoken) != claims.Fingerprint {
+		return nil, fmt.Errorf("access token is revoked for `user_id` %s", userID)
+	}
+	if !offlineSessions {
+		isValid, err := s.kiteSession.CheckEnctokenValid(session.Enctoken)
+		if err != nil {
+			return nil, err
+		}
+		if !isValid {
+			return nil, fmt.Errorf("`enctoken` is expired for `user_id` %s", userID)
+		}
+	}
+
+	rememberVerifiedSession(session)
+	return session, nil
+}
+
+// enctokenFingerprint is the fingerprint of an enctoken put in the session JWTs, as the JWT claims are readable
+func enctokenFingerprint(enctoken string) string {
+	sum := sha256.Sum256([]byte(enctoken))
+	return hex.EncodeToString(sum[:8])
+}
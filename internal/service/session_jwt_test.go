@@ -0,0 +1,112 @@
+package service
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"github.com/golang-jwt/jwt"
+	"github.com/nsvirk/moneybotsapi/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// sessionDB opens an in-memory database of the test with the sessions
+func sessionDB(t *testing.T, sessions ...models.SessionModel) *gorm.DB {
+	t.Helper()
+	name := strings.NewReplacer("/", "_", " ", "_").Replace(t.Name())
+	db, err := gorm.Open(sqlite.Open("file:"+name+"?mode=memory&cache=shared"), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("failed to open the database: %v", err)
+	}
+	if err := db.AutoMigrate(&models.SessionModel{}); err != nil {
+		t.Fatalf("AutoMigrate() error = %v", err)
+	}
+	for _, session := range sessions {
+		if err := db.Create(&session).Error; err != nil {
+			t.Fatalf("failed to create the session: %v", err)
+		}
+	}
+	return db
+}
+
+// offlineTestSessions skips the Kite check of the enctokens for the test
+func offlineTestSessions(t *testing.T) {
+	offline := offlineSessions
+	SetOfflineSessions(true)
+	t.Cleanup(func() { SetOfflineSessions(offline) })
+}
+
+func TestVerifySessionJWT(t *testing.T) {
+	offlineTestSessions(t)
+	secret := "jwt-test-secret"
+	SetJWTAuth(secret, time.Hour)
+	t.Cleanup(func() { SetJWTAuth("", 0) })
+
+	db := sessionDB(t,
+		models.SessionModel{UserId: "AB1234", Enctoken: "enc-ab"},
+		models.SessionModel{UserId: "CD5678", Enctoken: "enc-cd", Disabled: true},
+	)
+	s := NewSessionService(db)
+
+	sign := func(method jwt.SigningMethod, key interface{}, userID, enctoken string, expiresAt time.Time) string {
+		t.Helper()
+		claims := sessionClaims{
+			Fingerprint:    enctokenFingerprint(enctoken),
+			StandardClaims: jwt.StandardClaims{Subject: userID, IssuedAt: time.Now().Unix(), ExpiresAt: expiresAt.Unix()},
+		}
+		token, err := jwt.NewWithClaims(method, claims).SignedString(key)
+		if err != nil {
+			t.Fatalf("SignedString() error = %v", err)
+		}
+		return token
+	}
+	valid := time.Now().Add(time.Hour)
+
+	login, err := NewSessionLogin(models.SessionModel{UserId: "AB1234", Enctoken: "enc-ab"})
+	if err != nil {
+		t.Fatalf("NewSessionLogin() error = %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr string
+	}{
+		{name: "issued at login", token: login.AccessToken},
+		{name: "expired", token: sign(jwt.SigningMethodHS256, []byte(secret), "AB1234", "enc-ab", time.Now().Add(-time.Minute)), wantErr: "invalid access token"},
+		{name: "bad signature", token: sign(jwt.SigningMethodHS256, []byte("other-secret"), "AB1234", "enc-ab", valid), wantErr: "invalid access token"},
+		{name: "hs512", token: sign(jwt.SigningMethodHS512, []byte(secret), "AB1234", "enc-ab", valid), wantErr: "unexpected signing method"},
+		{name: "none", token: sign(jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType, "AB1234", "enc-ab", valid), wantErr: "invalid access token"},
+		{name: "enctoken fingerprint mismatch", token: sign(jwt.SigningMethodHS256, []byte(secret), "AB1234", "enc-old", valid), wantErr: "revoked"},
+		{name: "disabled user", token: sign(jwt.SigningMethodHS256, []byte(secret), "CD5678", "enc-cd", valid), wantErr: "disabled"},
+		{name: "unknown user", token: sign(jwt.SigningMethodHS256, []byte(secret), "XX0000", "enc-xx", valid), wantErr: "not found"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			session, err := s.VerifySessionJWT(tt.token)
+			if tt.wantErr == "" {
+				if err != nil || session.UserId != "AB1234" {
+					t.Errorf("VerifySessionJWT() = %v, %v, want the session of AB1234", session, err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("VerifySessionJWT() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestVerifySessionJWTDisabled(t *testing.T) {
+	SetJWTAuth("", 0)
+	login, err := NewSessionLogin(models.SessionModel{UserId: "AB1234", Enctoken: "enc-ab"})
+	if err != nil || login.AccessToken != "" {
+		t.Errorf("NewSessionLogin() = %q, %v, want no access token", login.AccessToken, err)
+	}
+	if _, err := NewSessionService(sessionDB(t)).VerifySessionJWT("any"); err == nil {
+		t.Errorf("VerifySessionJWT() error = nil, want an error while access tokens are disabled")
+	}
+}
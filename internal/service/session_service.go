@@ -2,7 +2,10 @@
 package service
 
 import (
+	"errors"
 	"fmt"
+	"net/url"
+	"strings"
 
 	kitesession "github.com/nsvirk/gokitesession"
 	"github.com/nsvirk/moneybotsapi/internal/models"
@@ -11,6 +14,14 @@ import (
 	"gorm.io/gorm"
 )
 
+// offlineSessions skips checking enctokens with Kite, used with in-memory storage
+var offlineSessions = false
+
+// SetOfflineSessions sets if enctokens are only checked against the stored sessions
+func SetOfflineSessions(offline bool) {
+	offlineSessions = offline
+}
+
 type SessionService struct {
 	repo        *repository.SessionRepository
 	kiteSession *kitesession.Client
@@ -24,11 +35,60 @@ func NewSessionService(db *gorm.DB) *SessionService {
 	}
 }
 
+// Reasons of a LoginError
+const (
+	LoginRejected = "rejected" // Kite rejected the credentials
+	LoginDisabled = "disabled" // the user id is disabled
+	LoginUpstream = "upstream" // Kite could not be reached or failed
+)
+
+// kiteRejectionTypes are the error types of the Kite login responses rejecting the credentials
+var kiteRejectionTypes = []string{"InputException", "TwoFAException", "TokenException", "UserException", "PermissionException"}
+
+// LoginError is a failed login, with the reason it failed
+type LoginError struct {
+	Reason string
+	Err    error
+}
+
+func (e *LoginError) Error() string {
+	return "login failed: " + e.Err.Error()
+}
+
+func (e *LoginError) Unwrap() error {
+	return e.Err
+}
+
+// newKiteLoginError returns the LoginError of a failed Kite login, the credentials are only rejected
+// by an error response of a rejection type or a 4xx of the twofa step, the other errors are upstream failures
+func newKiteLoginError(err error) *LoginError {
+	loginErr := &LoginError{Reason: LoginUpstream, Err: err}
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return loginErr
+	}
+	message := err.Error()
+	for _, errorType := range kiteRejectionTypes {
+		if strings.Contains(message, "executing login request: "+errorType+":") {
+			loginErr.Reason = LoginRejected
+			break
+		}
+	}
+	if strings.Contains(message, "twofa request failed with status: 4") {
+		loginErr.Reason = LoginRejected
+	}
+	return loginErr
+}
+
 // GenerateSession generates a new session for the given user
+// A failed login is a *LoginError, the other errors are internal
 func (s *SessionService) GenerateSession(userId, password, totpValue string) (models.SessionModel, error) {
 
 	existingSession, err := s.repo.GetSessionByUserId(userId)
 	if err == nil {
+		if existingSession.Disabled {
+			return models.SessionModel{}, &LoginError{Reason: LoginDisabled, Err: fmt.Errorf("`user_id` %s is disabled", userId)}
+		}
 		if err := bcrypt.CompareHashAndPassword([]byte(existingSession.HashedPassword), []byte(password)); err == nil {
 			isValid, err := s.kiteSession.CheckEnctokenValid(existingSession.Enctoken)
 			if err == nil && isValid {
@@ -39,7 +99,7 @@ func (s *SessionService) GenerateSession(userId, password, totpValue string) (mo
 
 	session, err := s.kiteSession.GenerateSession(userId, password, totpValue)
 	if err != nil {
-		return models.SessionModel{}, fmt.Errorf("login failed: %v", err)
+		return models.SessionModel{}, newKiteLoginError(err)
 	}
 
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
@@ -73,6 +133,7 @@ func (s *SessionService) GenerateTOTP(totpSecret string) (string, error) {
 
 // DeleteSession deletes the session for the given user
 func (s *SessionService) DeleteSession(userId, enctoken string) (int64, error) {
+	forgetVerifiedSession(userId)
 	return s.repo.DeleteSession(userId, enctoken)
 }
 
@@ -87,9 +148,11 @@ func (s *SessionService) CheckEnctokenValid(enctoken string) (bool, error) {
 // Used by the AuthMiddleware to verify the session
 func (s *SessionService) VerifyUserAuthorization(userID, enctoken string) (*models.SessionModel, error) {
 	// Verify if the session is still valid with KiteConnect API
-	isValid, err := s.kiteSession.CheckEnctokenValid(enctoken)
-	if err != nil || !isValid {
-		return nil, err
+	if !offlineSessions {
+		isValid, err := s.kiteSession.CheckEnctokenValid(enctoken)
+		if err != nil || !isValid {
+			return nil, err
+		}
 	}
 
 	// Get the session from the database
@@ -98,7 +161,11 @@ func (s *SessionService) VerifyUserAuthorization(userID, enctoken string) (*mode
 		if err == gorm.ErrRecordNotFound {
 			return nil, fmt.Errorf("`user_id` %s not found", userID)
 		}
-		return nil, err
+		return fallbackSession(userID, enctoken, err)
+	}
+
+	if session.Disabled {
+		return nil, fmt.Errorf("`user_id` %s is disabled", userID)
 	}
 
 	// Compare the enctoken from database with the enctoken from the request
@@ -106,5 +173,32 @@ func (s *SessionService) VerifyUserAuthorization(userID, enctoken string) (*mode
 		return nil, fmt.Errorf("`enctoken` is invalid for `user_id` %s", userID)
 	}
 
+	rememberVerifiedSession(session)
 	return session, nil
 }
+
+// GetSessionUsers returns the users of all sessions
+func (s *SessionService) GetSessionUsers() ([]models.SessionUser, error) {
+	return s.repo.GetSessionUsers()
+}
+
+// SetUserDisabled disables or enables a user, a disabled user can neither login nor be authorized
+func (s *SessionService) SetUserDisabled(userId string, disabled bool) (int64, error) {
+	forgetVerifiedSession(userId)
+	return s.repo.SetSessionDisabled(userId, disabled)
+}
+
+// SetUserRole sets the role of a user, which grants the scopes of the routes the user may call
+func (s *SessionService) SetUserRole(userId, role string) (int64, error) {
+	if !models.IsValidRole(role) {
+		return 0, fmt.Errorf("invalid role `%s`", role)
+	}
+	forgetVerifiedSession(userId)
+	return s.repo.SetSessionRole(userId, role)
+}
+
+// ResetUser clears the stored password and enctoken of a user, so the next login goes to Kite
+func (s *SessionService) ResetUser(userId string) (int64, error) {
+	forgetVerifiedSession(userId)
+	return s.repo.ResetSession(userId)
+}
@@ -0,0 +1,62 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"testing"
+
+	"github.com/nsvirk/moneybotsapi/internal/models"
+)
+
+func TestNewKiteLoginError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{
+			name: "invalid password",
+			err:  fmt.Errorf("login failed: %w", errors.New("executing login request: InputException: Invalid username or password.")),
+			want: LoginRejected,
+		},
+		{
+			name: "invalid totp",
+			err:  errors.New("twofa request failed with status: 400 Bad Request"),
+			want: LoginRejected,
+		},
+		{
+			name: "kite unavailable",
+			err:  errors.New("twofa request failed with status: 503 Service Unavailable"),
+			want: LoginUpstream,
+		},
+		{
+			name: "kite error type",
+			err:  errors.New("login failed: executing login request: NetworkException: Gateway timed out"),
+			want: LoginUpstream,
+		},
+		{
+			name: "network error",
+			err:  fmt.Errorf("login failed: executing login request: %w", &url.Error{Op: "Post", URL: "https://kite.zerodha.com/api/login", Err: errors.New("connection refused")}),
+			want: LoginUpstream,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := newKiteLoginError(tt.err).Reason; got != tt.want {
+				t.Errorf("newKiteLoginError() reason = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGenerateSessionDisabled(t *testing.T) {
+	s := NewSessionService(sessionDB(t, models.SessionModel{UserId: "AB1234", Enctoken: "enc-ab", Disabled: true}))
+
+	_, err := s.GenerateSession("AB1234", "password", "123456")
+	var loginErr *LoginError
+	if !errors.As(err, &loginErr) || loginErr.Reason != LoginDisabled {
+		t.Errorf("GenerateSession() error = %v, want a %q LoginError", err, LoginDisabled)
+	}
+}
@@ -0,0 +1,195 @@
+// Package service contains the service layer for the Moneybots API
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	kiteticker "github.com/nsvirk/gokiteticker"
+)
+
+// eventsHeartbeatPeriod is how often a heartbeat comment is sent on an idle event stream,
+// often enough for the proxies which close idle connections
+const eventsHeartbeatPeriod = 15 * time.Second
+
+// streamLastTick is the last tick of a token and when it was received
+type streamLastTick struct {
+	tick       kiteticker.Tick
+	receivedAt time.Time
+}
+
+// rememberTick keeps the tick as the last tick of its token, for the event streams resumed with a Last-Event-ID
+func (s *StreamService) rememberTick(tick kiteticker.Tick, receivedAt time.Time) {
+	s.lastTicksMu.Lock()
+	defer s.lastTicksMu.Unlock()
+	s.lastTicks[tick.InstrumentToken] = streamLastTick{tick: tick, receivedAt: receivedAt}
+}
+
+// RunTickerEvents runs the event stream of the ticks of the instruments, sent like the socket ticks of the mode
+// Each tick event has its EventID as the id, a stream resumed with the lastEventID of a previous stream
+// first gets the last tick of each instrument received after it, so a reconnected client catches up
+// An error is returned when the stream could not be started, once started it runs until the client goes away
+// or the streams are drained
+func (s *StreamService) RunTickerEvents(ctx context.Context, c echo.Context, userId, enctoken string, instruments []string, mode string, lastEventID int64, mapper StreamTickMapper) error {
+	clientID := c.Response().Header().Get(echo.HeaderXRequestID)
+	if clientID == "" {
+		clientID = fmt.Sprintf("client-%d", time.Now().UnixNano())
+	}
+
+	tokenMap, err := s.prepareTokenMap(instruments)
+	if err != nil {
+		return err
+	}
+	tokens := make([]uint32, 0, len(tokenMap))
+	modes := make(map[uint32]string, len(tokenMap))
+	for token := range tokenMap {
+		tokens = append(tokens, token)
+		modes[token] = mode
+	}
+
+	clientChan := make(chan StreamTick, 100)
+	client := &StreamClient{
+		ID:          clientID,
+		Instruments: instruments,
+		Tokens:      tokens,
+		TokenMap:    tokenMap,
+		Channel:     clientChan,
+		Modes:       modes,
+		subscribed:  make(map[uint32]bool),
+		done:        make(chan struct{}),
+		mapper:      mapper,
+	}
+
+	if err := s.ConnectTicker(ctx, userId, enctoken); err != nil {
+		return err
+	}
+	if err := s.subscribeClientTokens(client, tokens); err != nil {
+		return fmt.Errorf("failed to subscribe client tokens: %v", err)
+	}
+	defer s.unsubscribeClient(client)
+
+	s.addClient(client)
+	defer s.removeClient(clientID)
+
+	// The stream outlives the server write timeout, so clear the write deadline
+	if err := http.NewResponseController(c.Response().Writer).SetWriteDeadline(time.Time{}); err != nil {
+		log.Printf("Error clearing write deadline: %v", err)
+	}
+
+	// Set headers for SSE, `X-Accel-Buffering` keeps nginx from buffering the stream
+	c.Response().Header().Set(echo.HeaderContentType, "text/event-stream")
+	c.Response().Header().Set(echo.HeaderCacheControl, "no-cache")
+	c.Response().Header().Set(echo.HeaderConnection, "keep-alive")
+	c.Response().Header().Set("X-Accel-Buffering", "no")
+	c.Response().WriteHeader(http.StatusOK)
+
+	// `retry` sets the reconnection time of EventSource clients
+	if _, err := c.Response().Write([]byte(fmt.Sprintf("retry: %d\n\n", s.reconnectBackoff.Milliseconds()))); err != nil {
+		log.Printf("Error writing to client %s: %v", clientID, err)
+		return nil
+	}
+	if lastEventID > 0 {
+		for _, tick := range s.ticksSince(tokenMap, lastEventID, mode, mapper) {
+			if err := writeTickEvent(c, tick); err != nil {
+				log.Printf("Error writing to client %s: %v", clientID, err)
+				return nil
+			}
+		}
+	}
+	c.Response().Flush()
+
+	heartbeat := time.NewTicker(eventsHeartbeatPeriod)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-s.draining:
+			if err := s.writeEventsFinalMessage(c, clientChan); err != nil {
+				log.Printf("Error writing final message to client %s: %v", clientID, err)
+			}
+			return nil
+		case tick := <-clientChan:
+			if err := writeTickEvent(c, tick); err != nil {
+				log.Printf("Error writing to client %s: %v", clientID, err)
+				return nil
+			}
+			c.Response().Flush()
+		case <-heartbeat.C:
+			if _, err := c.Response().Write([]byte(": heartbeat\n\n")); err != nil {
+				log.Printf("Error writing heartbeat to client %s: %v", clientID, err)
+				return nil
+			}
+			c.Response().Flush()
+		}
+	}
+}
+
+// ticksSince returns the last ticks of the tokens received after the event id, encoded in the mode, oldest first
+func (s *StreamService) ticksSince(tokenMap map[uint32]string, eventID int64, mode string, mapper StreamTickMapper) []StreamTick {
+	s.lastTicksMu.Lock()
+	var lastTicks []streamLastTick
+	for token := range tokenMap {
+		if last, ok := s.lastTicks[token]; ok && last.receivedAt.UnixNano() > eventID {
+			lastTicks = append(lastTicks, last)
+		}
+	}
+	s.lastTicksMu.Unlock()
+
+	sort.Slice(lastTicks, func(i, j int) bool { return lastTicks[i].receivedAt.Before(lastTicks[j].receivedAt) })
+	ticks := make([]StreamTick, 0, len(lastTicks))
+	for _, last := range lastTicks {
+		instrument := tokenMap[last.tick.InstrumentToken]
+		tickerData, err := newTickerData(instrument, last.tick)
+		if err != nil {
+			log.Printf("Error converting tick data: %v", err)
+		}
+		data, err := socketTickMessage(mapper, mode, instrument, &tickerData)
+		if err != nil {
+			log.Printf("Error marshaling tick data: %v", err)
+			continue
+		}
+		ticks = append(ticks, StreamTick{Token: last.tick.InstrumentToken, Data: data, EventID: last.receivedAt.UnixNano()})
+	}
+	return ticks
+}
+
+// writeEventsFinalMessage sends the ticks not yet sent to the client, followed by a `reconnect` event
+// with a jittered backoff, the client resumes from the id of the last tick it got
+func (s *StreamService) writeEventsFinalMessage(c echo.Context, clientChan <-chan StreamTick) error {
+buffered:
+	for {
+		select {
+		case tick := <-clientChan:
+			if err := writeTickEvent(c, tick); err != nil {
+				return err
+			}
+		default:
+			break buffered
+		}
+	}
+
+	reconnectHint := s.reconnectHint()
+	hint, err := json.Marshal(reconnectHint)
+	if err != nil {
+		return err
+	}
+	if _, err := c.Response().Write([]byte(fmt.Sprintf("retry: %d\nevent: reconnect\ndata: %s\n\n", reconnectHint.RetryAfterMs, hint))); err != nil {
+		return err
+	}
+	c.Response().Flush()
+	return nil
+}
+
+// writeTickEvent writes the tick as an event with its EventID as the id
+func writeTickEvent(c echo.Context, tick StreamTick) error {
+	_, err := c.Response().Write([]byte(fmt.Sprintf("id: %d\ndata: %s\n\n", tick.EventID, tick.Data)))
+	return err
+}
@@ -0,0 +1,100 @@
+package service
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"github.com/labstack/echo/v4"
+	kiteticker "github.com/nsvirk/gokiteticker"
+	"github.com/nsvirk/moneybotsapi/internal/config"
+	"github.com/nsvirk/moneybotsapi/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// instrumentsDB opens an in-memory database of the test's own with the instruments table
+func instrumentsDB(t *testing.T, instruments ...models.InstrumentModel) *gorm.DB {
+	t.Helper()
+	name := strings.NewReplacer("/", "_", " ", "_").Replace(t.Name())
+	db, err := gorm.Open(sqlite.Open("file:"+name+"?mode=memory&cache=shared"), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("failed to open the database: %v", err)
+	}
+	// closing the last connection drops the database, so a repeated run of the test starts empty
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	if err := db.AutoMigrate(&models.InstrumentModel{}); err != nil {
+		t.Fatalf("AutoMigrate() error = %v", err)
+	}
+	if len(instruments) > 0 {
+		if err := db.Create(&instruments).Error; err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+	}
+	return db
+}
+
+// connectedStreamService returns a stream service of the instruments with a connected ticker which already
+// holds their tokens, so the streams subscribe without calling the ticker
+func connectedStreamService(t *testing.T, cfg *config.Config, instruments ...models.InstrumentModel) *StreamService {
+	t.Helper()
+	s := NewStreamService(cfg, instrumentsDB(t, instruments...))
+	s.ticker = kiteticker.New("DEV001", "enctoken")
+	s.isConnected = true
+	for _, instrument := range instruments {
+		s.subscribedTokens[instrument.InstrumentToken] = 1
+	}
+	return s
+}
+
+func TestRunTickerEventsOutlivesWriteTimeout(t *testing.T) {
+	s := connectedStreamService(t, &config.Config{ReconnectBackoff: time.Second},
+		models.InstrumentModel{InstrumentToken: 100001, Tradingsymbol: "INFY", Exchange: "NSE"})
+
+	e := echo.New()
+	e.GET("/events", func(c echo.Context) error {
+		return s.RunTickerEvents(c.Request().Context(), c, "DEV001", "enctoken", []string{"NSE:INFY"}, "ltp", 0, nil)
+	})
+	server := httptest.NewUnstartedServer(e)
+	server.Config.WriteTimeout = 50 * time.Millisecond
+	server.Start()
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/events")
+	if err != nil {
+		t.Fatalf("GET /events error = %v", err)
+	}
+	defer resp.Body.Close()
+	if got := resp.Header.Get(echo.HeaderContentType); got != "text/event-stream" {
+		t.Fatalf("Content-Type = %q, want text/event-stream", got)
+	}
+	reader := bufio.NewReader(resp.Body)
+	if line, err := reader.ReadString('\n'); err != nil || line != "retry: 1000\n" {
+		t.Fatalf("first line = %q, %v, want the retry", line, err)
+	}
+
+	// a tick sent after the write timeout still reaches the client
+	time.Sleep(4 * server.Config.WriteTimeout)
+	s.broadcastTick(kiteticker.Tick{InstrumentToken: 100001, LastPrice: 1010})
+
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("reading the tick after the write timeout error = %v", err)
+		}
+		if strings.HasPrefix(line, "data: ") {
+			if !strings.Contains(line, `"last_price":1010`) {
+				t.Errorf("tick event = %q, want the tick at 1010", line)
+			}
+			return
+		}
+	}
+}
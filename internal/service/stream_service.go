@@ -6,13 +6,18 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"math/rand"
 	"net/http"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/labstack/echo/v4"
 	kiteticker "github.com/nsvirk/gokiteticker"
+	"github.com/nsvirk/moneybotsapi/internal/config"
+	"github.com/nsvirk/moneybotsapi/internal/metrics"
+	"github.com/nsvirk/moneybotsapi/internal/models"
 
 	"gorm.io/gorm"
 )
@@ -23,18 +28,40 @@ type StreamClient struct {
 	Instruments []string
 	Tokens      []uint32
 	TokenMap    map[uint32]string
-	Channel     chan<- []byte
+	Channel     chan<- StreamTick
+	Modes       map[uint32]string // modes of the tokens of a socket client
+	subscribed  map[uint32]bool   // tokens the client holds a ticker subscription on, owned by its stream goroutine
+	done        chan struct{}     // closed when the client is removed
+	lagging     atomic.Bool       // set when a send timed out, until the client catches up
+	mapper      StreamTickMapper  // maps the ticks of a socket client, nil for the event streams
 }
 
-// StreamSubscriptionRequest is a request to subscribe to a list of tokens
+// StreamTick is the json encoded tick of an instrument sent to the clients
+// EventID is when the tick was received in unix nanoseconds, it is the id of the tick events
+type StreamTick struct {
+	Token   uint32
+	Data    []byte
+	EventID int64
+}
+
+// streamBroadcast is a tick to be sent to a client by the broadcast workers
+type streamBroadcast struct {
+	client     *StreamClient
+	tick       StreamTick
+	receivedAt time.Time
+}
+
+// StreamSubscriptionRequest is a request to subscribe to a list of tokens, or to release them when unsubscribe is set
 type StreamSubscriptionRequest struct {
-	tokens []uint32
-	respCh chan error
+	tokens      []uint32
+	unsubscribe bool
+	respCh      chan error
 }
 
 // StreamService is the service for the stream API
 type StreamService struct {
 	instrumentService *InstrumentService
+	batchInterval     time.Duration
 	ticker            *kiteticker.Ticker
 	globalTokenMap    map[uint32]string
 	mu                sync.RWMutex
@@ -42,23 +69,52 @@ type StreamService struct {
 	isConnected       bool
 	connectChan       chan struct{}
 	subscriptionChan  chan StreamSubscriptionRequest
+	subscribedTokens  map[uint32]int // clients holding each token subscribed on the ticker, owned by subscriptionHandler
+	reconnectBackoff  time.Duration
+	reconnectJitter   time.Duration
+	draining          chan struct{}
+	drainOnce         sync.Once
+	broadcasts        chan streamBroadcast
+	broadcastTimeout  time.Duration
+	lastTicksMu       sync.Mutex
+	lastTicks         map[uint32]streamLastTick // replayed to the event streams resumed with a Last-Event-ID
+}
+
+// StreamReconnectHint is the final event sent to the clients on shutdown,
+// clients should reconnect after RetryAfterMs, which is jittered per client
+type StreamReconnectHint struct {
+	Reason       string `json:"reason"`
+	RetryAfterMs int64  `json:"retry_after_ms"`
 }
 
 // NewStreamService creates a new service for the stream API
-func NewStreamService(db *gorm.DB) *StreamService {
+func NewStreamService(cfg *config.Config, db *gorm.DB) *StreamService {
 	s := &StreamService{
 		instrumentService: NewInstrumentService(db),
+		batchInterval:     cfg.StreamBatchEvery,
 		globalTokenMap:    make(map[uint32]string),
 		clients:           make(map[string]*StreamClient),
 		connectChan:       make(chan struct{}),
 		subscriptionChan:  make(chan StreamSubscriptionRequest),
+		subscribedTokens:  make(map[uint32]int),
+		reconnectBackoff:  cfg.ReconnectBackoff,
+		reconnectJitter:   cfg.ReconnectJitter,
+		draining:          make(chan struct{}),
+		broadcastTimeout:  cfg.BroadcastTimeout,
+		lastTicks:         make(map[uint32]streamLastTick),
+	}
+	workers := max(cfg.BroadcastWorkers, 1)
+	s.broadcasts = make(chan streamBroadcast, workers*256)
+	for i := 0; i < workers; i++ {
+		go s.broadcastWorker()
 	}
 	go s.subscriptionHandler()
 	return s
 }
 
 // RunTickerStream runs the ticker stream for the given client
-func (s *StreamService) RunTickerStream(ctx context.Context, c echo.Context, userId, enctoken string, instruments []string, errChan chan<- error) {
+// In batch mode the ticks are sent as one array frame per batch interval, holding the latest tick of each updated token
+func (s *StreamService) RunTickerStream(ctx context.Context, c echo.Context, userId, enctoken string, instruments []string, batch bool, errChan chan<- error) {
 	clientID := c.Response().Header().Get(echo.HeaderXRequestID)
 	if clientID == "" {
 		clientID = fmt.Sprintf("client-%d", time.Now().UnixNano())
@@ -77,38 +133,36 @@ func (s *StreamService) RunTickerStream(ctx context.Context, c echo.Context, use
 		tokens = append(tokens, token)
 	}
 
-	clientChan := make(chan []byte, 100)
+	clientChan := make(chan StreamTick, 100)
 	client := &StreamClient{
 		ID:          clientID,
 		Instruments: instruments,
 		Tokens:      tokens,
 		TokenMap:    tokenMap,
 		Channel:     clientChan,
+		subscribed:  make(map[uint32]bool),
+		done:        make(chan struct{}),
 	}
 
 	s.addClient(client)
 	defer s.removeClient(clientID)
+	defer s.unsubscribeClient(client)
 
-	s.mu.Lock()
-	if s.ticker == nil {
-		if err := s.initTicker(userId, enctoken); err != nil {
-			s.mu.Unlock()
-			errChan <- fmt.Errorf("failed to initialize ticker: %v", err)
-			return
-		}
-	}
-	s.mu.Unlock()
-
-	if err := s.waitForConnection(ctx); err != nil {
-		errChan <- fmt.Errorf("connection timeout: %v", err)
+	if err := s.ConnectTicker(ctx, userId, enctoken); err != nil {
+		errChan <- err
 		return
 	}
 
-	if err := s.subscribeClientTokens(client.Tokens); err != nil {
+	if err := s.subscribeClientTokens(client, client.Tokens); err != nil {
 		errChan <- fmt.Errorf("failed to subscribe client tokens: %v", err)
 		return
 	}
 
+	// The stream outlives the server write timeout, so clear the write deadline
+	if err := http.NewResponseController(c.Response().Writer).SetWriteDeadline(time.Time{}); err != nil {
+		log.Printf("Error clearing write deadline: %v", err)
+	}
+
 	// Set headers for SSE
 	c.Response().Header().Set(echo.HeaderContentType, "text/event-stream")
 	c.Response().Header().Set(echo.HeaderCacheControl, "no-cache")
@@ -125,12 +179,40 @@ func (s *StreamService) RunTickerStream(ctx context.Context, c echo.Context, use
 	ticker := time.NewTicker(30 * time.Second)
 	defer ticker.Stop()
 
+	// batchTicker is only started in batch mode, a nil channel never fires
+	var batchC <-chan time.Time
+	if batch && s.batchInterval > 0 {
+		batchTicker := time.NewTicker(s.batchInterval)
+		defer batchTicker.Stop()
+		batchC = batchTicker.C
+	}
+	pending := newStreamBatch()
+
 	for {
 		select {
 		case <-ctx.Done():
 			return
-		case data := <-clientChan:
-			if _, err := c.Response().Write(data); err != nil {
+		case <-s.draining:
+			if err := s.writeFinalMessage(c, clientChan, pending); err != nil {
+				log.Printf("Error writing final message to client %s: %v", clientID, err)
+			}
+			errChan <- nil
+			return
+		case tick := <-clientChan:
+			if batchC != nil {
+				pending.add(tick)
+				continue
+			}
+			if _, err := c.Response().Write([]byte(fmt.Sprintf("data: %s\n\n", tick.Data))); err != nil {
+				log.Printf("Error writing to client %s: %v", clientID, err)
+				return
+			}
+			c.Response().Flush()
+		case <-batchC:
+			if pending.empty() {
+				continue
+			}
+			if _, err := c.Response().Write([]byte(fmt.Sprintf("data: %s\n\n", pending.flush()))); err != nil {
 				log.Printf("Error writing to client %s: %v", clientID, err)
 				return
 			}
@@ -146,22 +228,183 @@ func (s *StreamService) RunTickerStream(ctx context.Context, c echo.Context, use
 	}
 }
 
+// StreamStats are the open streams and the instrument tokens they are subscribed to
+type StreamStats struct {
+	Clients         int  `json:"clients"`
+	Tokens          int  `json:"tokens"`
+	TickerConnected bool `json:"ticker_connected"`
+}
+
+// Stats returns the stats of the open streams
+func (s *StreamService) Stats() StreamStats {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return StreamStats{
+		Clients:         len(s.clients),
+		Tokens:          len(s.globalTokenMap),
+		TickerConnected: s.isConnected,
+	}
+}
+
+// Drain ends all streams with a final snapshot and a reconnect hint, it is called on server shutdown
+func (s *StreamService) Drain() {
+	s.drainOnce.Do(func() {
+		close(s.draining)
+	})
+}
+
+// writeFinalMessage sends the ticks not yet sent to the client as a final snapshot,
+// followed by a `reconnect` event with a jittered backoff so clients do not all reconnect at once
+func (s *StreamService) writeFinalMessage(c echo.Context, clientChan <-chan StreamTick, pending *streamBatch) error {
+buffered:
+	for {
+		select {
+		case tick := <-clientChan:
+			pending.add(tick)
+		default:
+			break buffered
+		}
+	}
+	if !pending.empty() {
+		if _, err := c.Response().Write([]byte(fmt.Sprintf("event: snapshot\ndata: %s\n\n", pending.flush()))); err != nil {
+			return err
+		}
+	}
+
+	reconnectHint := s.reconnectHint()
+	hint, err := json.Marshal(reconnectHint)
+	if err != nil {
+		return err
+	}
+	// `retry` sets the reconnection time of EventSource clients
+	if _, err := c.Response().Write([]byte(fmt.Sprintf("retry: %d\nevent: reconnect\ndata: %s\n\n", reconnectHint.RetryAfterMs, hint))); err != nil {
+		return err
+	}
+	c.Response().Flush()
+	return nil
+}
+
+// reconnectHint returns the shutdown reconnect hint of a client, with the backoff jittered per client
+func (s *StreamService) reconnectHint() StreamReconnectHint {
+	retryAfter := s.reconnectBackoff
+	if s.reconnectJitter > 0 {
+		retryAfter += time.Duration(rand.Int63n(int64(s.reconnectJitter)))
+	}
+	return StreamReconnectHint{Reason: "shutdown", RetryAfterMs: retryAfter.Milliseconds()}
+}
+
 // subscriptionHandler handles the subscription requests
+// The tokens are refcounted across the clients, a token is subscribed on the ticker when its first client
+// subscribes it and unsubscribed when its last client releases it, so only the new tokens of a partially
+// overlapping request are subscribed
 func (s *StreamService) subscriptionHandler() {
 	for req := range s.subscriptionChan {
-		err := s.ticker.Subscribe(req.tokens)
+		if req.unsubscribe {
+			req.respCh <- s.releaseTokens(req.tokens)
+			continue
+		}
+
+		newTokens := make([]uint32, 0, len(req.tokens))
+		for _, token := range req.tokens {
+			if s.subscribedTokens[token] == 0 {
+				newTokens = append(newTokens, token)
+			}
+			s.subscribedTokens[token]++
+		}
+		if len(newTokens) == 0 {
+			req.respCh <- nil
+			continue
+		}
+
+		err := s.ticker.Subscribe(newTokens)
 		if err == nil {
-			err = s.ticker.SetMode(kiteticker.ModeFull, req.tokens)
+			err = s.ticker.SetMode(kiteticker.ModeFull, newTokens)
+		}
+		if err != nil {
+			// the request holds none of its tokens when it fails
+			for _, token := range req.tokens {
+				if s.subscribedTokens[token]--; s.subscribedTokens[token] <= 0 {
+					delete(s.subscribedTokens, token)
+				}
+			}
 		}
 		req.respCh <- err
 	}
 }
 
-// subscribeClientTokens subscribes the client to the given tokens
-func (s *StreamService) subscribeClientTokens(tokens []uint32) error {
+// releaseTokens drops a client from the counts of the tokens, and unsubscribes the tokens no client holds
+// from the ticker
+func (s *StreamService) releaseTokens(tokens []uint32) error {
+	unusedTokens := make([]uint32, 0, len(tokens))
+	for _, token := range tokens {
+		count, ok := s.subscribedTokens[token]
+		if !ok {
+			continue
+		}
+		if count > 1 {
+			s.subscribedTokens[token] = count - 1
+			continue
+		}
+		delete(s.subscribedTokens, token)
+		unusedTokens = append(unusedTokens, token)
+	}
+	if len(unusedTokens) == 0 {
+		return nil
+	}
+	return s.ticker.Unsubscribe(unusedTokens)
+}
+
+// subscribeClientTokens subscribes the client to the given tokens, the tokens it already holds are skipped
+func (s *StreamService) subscribeClientTokens(client *StreamClient, tokens []uint32) error {
+	newTokens := make([]uint32, 0, len(tokens))
+	for _, token := range tokens {
+		if !client.subscribed[token] {
+			newTokens = append(newTokens, token)
+		}
+	}
+	if len(newTokens) == 0 {
+		return nil
+	}
+
+	respCh := make(chan error)
+	s.subscriptionChan <- StreamSubscriptionRequest{tokens: newTokens, respCh: respCh}
+	if err := <-respCh; err != nil {
+		return err
+	}
+	for _, token := range newTokens {
+		client.subscribed[token] = true
+	}
+	return nil
+}
+
+// unsubscribeClientTokens releases the client's subscriptions of the given tokens
+// A failed ticker unsubscribe is only logged, the ticks of the released tokens are not sent to the client
+func (s *StreamService) unsubscribeClientTokens(client *StreamClient, tokens []uint32) {
+	heldTokens := make([]uint32, 0, len(tokens))
+	for _, token := range tokens {
+		if client.subscribed[token] {
+			delete(client.subscribed, token)
+			heldTokens = append(heldTokens, token)
+		}
+	}
+	if len(heldTokens) == 0 {
+		return
+	}
+
 	respCh := make(chan error)
-	s.subscriptionChan <- StreamSubscriptionRequest{tokens: tokens, respCh: respCh}
-	return <-respCh
+	s.subscriptionChan <- StreamSubscriptionRequest{tokens: heldTokens, unsubscribe: true, respCh: respCh}
+	if err := <-respCh; err != nil {
+		log.Printf("Error unsubscribing tokens of client %s: %v", client.ID, err)
+	}
+}
+
+// unsubscribeClient releases all the subscriptions of the client, it is called when the client disconnects
+func (s *StreamService) unsubscribeClient(client *StreamClient) {
+	tokens := make([]uint32, 0, len(client.subscribed))
+	for token := range client.subscribed {
+		tokens = append(tokens, token)
+	}
+	s.unsubscribeClientTokens(client, tokens)
 }
 
 // waitForConnection waits for the ticker to connect
@@ -194,11 +437,12 @@ func (s *StreamService) addClient(client *StreamClient) {
 }
 
 // removeClient removes a client from the service
+// The client channel is left open, as the broadcast workers may still hold ticks for it
 func (s *StreamService) removeClient(clientID string) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	if client, ok := s.clients[clientID]; ok {
-		close(client.Channel)
+		close(client.done)
 		delete(s.clients, clientID)
 	}
 	s.cleanupGlobalTokenMap()
@@ -279,8 +523,10 @@ func (s *StreamService) setupCallbacks() {
 	})
 }
 
-// broadcastTick broadcasts the tick to all clients
+// broadcastTick queues the tick for the broadcast workers, once per subscribed client
+// Ticks are dropped when all workers are busy, so the ticker is never blocked
 func (s *StreamService) broadcastTick(tick kiteticker.Tick) {
+	receivedAt := time.Now()
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
@@ -289,31 +535,140 @@ func (s *StreamService) broadcastTick(tick kiteticker.Tick) {
 		return
 	}
 
-	exchange, tradingsymbol, _ := strings.Cut(symbolInfo, ":")
+	s.rememberTick(tick, receivedAt)
 
-	tickData := map[string]interface{}{
-		"exchange":      exchange,
-		"tradingsymbol": tradingsymbol,
-		"last_price":    tick.LastPrice,
-		"volume":        tick.VolumeTraded,
-		"avg_price":     tick.AverageTradePrice,
+	// the event stream and socket payloads are encoded once per tick, only when a client needs them
+	var streamData []byte
+	var tickerData *models.TickerData
+	socketData := make(map[string][]byte)
+
+	for _, client := range s.clients {
+		if _, ok := client.TokenMap[tick.InstrumentToken]; !ok {
+			continue
+		}
+
+		var data []byte
+		if client.mapper == nil {
+			if streamData == nil {
+				jsonData, err := encodeStreamTick(symbolInfo, tick)
+				if err != nil {
+					log.Printf("Error marshaling tick data: %v", err)
+					return
+				}
+				streamData = jsonData
+			}
+			data = streamData
+		} else {
+			// the socket clients share the mapper of the stream handler
+			mode := client.Modes[tick.InstrumentToken]
+			if data, ok = socketData[mode]; !ok {
+				if tickerData == nil {
+					converted, err := newTickerData(symbolInfo, tick)
+					if err != nil {
+						log.Printf("Error converting tick data: %v", err)
+					}
+					tickerData = &converted
+				}
+				jsonData, err := socketTickMessage(client.mapper, mode, symbolInfo, tickerData)
+				if err != nil {
+					log.Printf("Error marshaling tick data: %v", err)
+					continue
+				}
+				socketData[mode] = jsonData
+				data = jsonData
+			}
+		}
+
+		select {
+		case s.broadcasts <- streamBroadcast{client: client, tick: StreamTick{Token: tick.InstrumentToken, Data: data, EventID: receivedAt.UnixNano()}, receivedAt: receivedAt}:
+		default:
+			metrics.RecordBroadcastDrop("queue_full")
+		}
 	}
+}
 
-	jsonData, err := json.Marshal(tickData)
-	if err != nil {
-		log.Printf("Error marshaling tick data: %v", err)
-		return
+// encodeStreamTick encodes the tick of the instrument sent to the event streams
+func encodeStreamTick(instrument string, tick kiteticker.Tick) ([]byte, error) {
+	exchange, tradingsymbol, _ := strings.Cut(instrument, ":")
+
+	tickData := map[string]interface{}{
+		"instrument_token": tick.InstrumentToken,
+		"exchange":         exchange,
+		"tradingsymbol":    tradingsymbol,
+		"last_price":       tick.LastPrice,
+		"volume":           tick.VolumeTraded,
+		"avg_price":        tick.AverageTradePrice,
 	}
+	return json.Marshal(tickData)
+}
 
-	data := []byte(fmt.Sprintf("data: %s\n\n", jsonData))
+// broadcastWorker sends the queued ticks to the clients
+// A send to a slow client waits at most broadcastTimeout, then the tick is dropped for that client
+// and its further ticks are dropped without waiting until it catches up, so it cannot hold up the workers
+func (s *StreamService) broadcastWorker() {
+	timer := time.NewTimer(0)
+	<-timer.C
+	for b := range s.broadcasts {
+		select {
+		case b.client.Channel <- b.tick:
+			b.client.lagging.Store(false)
+			metrics.RecordBroadcastSend(time.Since(b.receivedAt))
+			continue
+		case <-b.client.done:
+			continue
+		default:
+		}
+		if b.client.lagging.Load() {
+			metrics.RecordBroadcastDrop("lagging")
+			continue
+		}
 
-	for _, client := range s.clients {
-		if _, ok := client.TokenMap[tick.InstrumentToken]; ok {
-			select {
-			case client.Channel <- data:
-			default:
-				log.Printf("Skipping slow client: %s", client.ID)
-			}
+		timer.Reset(s.broadcastTimeout)
+		select {
+		case b.client.Channel <- b.tick:
+			metrics.RecordBroadcastSend(time.Since(b.receivedAt))
+		case <-b.client.done:
+		case <-timer.C:
+			b.client.lagging.Store(true)
+			metrics.RecordBroadcastDrop("timeout")
+			continue
+		}
+		if !timer.Stop() {
+			<-timer.C
 		}
 	}
 }
+
+// streamBatch holds the latest tick of each token updated since the last flush, in update order
+type streamBatch struct {
+	order []uint32
+	ticks map[uint32][]byte
+}
+
+func newStreamBatch() *streamBatch {
+	return &streamBatch{ticks: make(map[uint32][]byte)}
+}
+
+func (b *streamBatch) add(tick StreamTick) {
+	if _, ok := b.ticks[tick.Token]; !ok {
+		b.order = append(b.order, tick.Token)
+	}
+	b.ticks[tick.Token] = tick.Data
+}
+
+func (b *streamBatch) empty() bool {
+	return len(b.order) == 0
+}
+
+// flush returns the held ticks as a json array and resets the batch
+func (b *streamBatch) flush() []byte {
+	frame := make([]json.RawMessage, 0, len(b.order))
+	for _, token := range b.order {
+		frame = append(frame, b.ticks[token])
+	}
+	b.order = b.order[:0]
+	b.ticks = make(map[uint32][]byte)
+
+	data, _ := json.Marshal(frame)
+	return data
+}
@@ -0,0 +1,204 @@
+package service
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	kiteticker "github.com/nsvirk/gokiteticker"
+	"github.com/nsvirk/moneybotsapi/internal/config"
+	"github.com/nsvirk/moneybotsapi/internal/models"
+)
+
+func TestStreamBatch(t *testing.T) {
+	batch := newStreamBatch()
+	if !batch.empty() {
+		t.Fatalf("empty() = false for a new batch, want true")
+	}
+
+	batch.add(StreamTick{Token: 2, Data: []byte(`{"t":2,"p":1}`)})
+	batch.add(StreamTick{Token: 1, Data: []byte(`{"t":1,"p":1}`)})
+	batch.add(StreamTick{Token: 2, Data: []byte(`{"t":2,"p":2}`)})
+	if got, want := string(batch.flush()), `[{"t":2,"p":2},{"t":1,"p":1}]`; got != want {
+		t.Errorf("flush() = %s, want %s", got, want)
+	}
+	if !batch.empty() {
+		t.Errorf("empty() = false after the flush, want true")
+	}
+
+	batch.add(StreamTick{Token: 1, Data: []byte(`{"t":1,"p":3}`)})
+	if got, want := string(batch.flush()), `[{"t":1,"p":3}]`; got != want {
+		t.Errorf("flush() after the reset = %s, want %s", got, want)
+	}
+}
+
+func TestRunTickerStreamBatch(t *testing.T) {
+	s := connectedStreamService(t, &config.Config{StreamBatchEvery: 200 * time.Millisecond},
+		models.InstrumentModel{InstrumentToken: 100001, Tradingsymbol: "INFY", Exchange: "NSE"},
+		models.InstrumentModel{InstrumentToken: 100002, Tradingsymbol: "TCS", Exchange: "NSE"})
+
+	e := echo.New()
+	e.GET("/stream", func(c echo.Context) error {
+		errChan := make(chan error, 1)
+		s.RunTickerStream(c.Request().Context(), c, "DEV001", "enctoken", []string{"NSE:INFY", "NSE:TCS"}, true, errChan)
+		return nil
+	})
+	server := httptest.NewServer(e)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/stream")
+	if err != nil {
+		t.Fatalf("GET /stream error = %v", err)
+	}
+	defer resp.Body.Close()
+	reader := bufio.NewReader(resp.Body)
+	if line, err := reader.ReadString('\n'); err != nil || line != "data: connected\n" {
+		t.Fatalf("first line = %q, %v, want connected", line, err)
+	}
+
+	// the ticks are sent well within the batch interval, so they are all in the first frame
+	s.broadcastTick(kiteticker.Tick{InstrumentToken: 100001, LastPrice: 1000})
+	s.broadcastTick(kiteticker.Tick{InstrumentToken: 100002, LastPrice: 2000})
+	s.broadcastTick(kiteticker.Tick{InstrumentToken: 100001, LastPrice: 1010})
+
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("reading the batch frame error = %v", err)
+		}
+		data, ok := strings.CutPrefix(strings.TrimSpace(line), "data: ")
+		if !ok {
+			continue
+		}
+		var frame []struct {
+			InstrumentToken uint32  `json:"instrument_token"`
+			LastPrice       float64 `json:"last_price"`
+		}
+		if err := json.Unmarshal([]byte(data), &frame); err != nil {
+			t.Fatalf("batch frame %s is not an array: %v", data, err)
+		}
+		prices := make(map[uint32]float64, len(frame))
+		for _, tick := range frame {
+			prices[tick.InstrumentToken] = tick.LastPrice
+		}
+		if len(frame) != 2 || prices[100001] != 1010 || prices[100002] != 2000 {
+			t.Errorf("batch frame = %s, want the latest INFY and TCS ticks in one frame", data)
+		}
+		return
+	}
+}
+
+func TestReconnectHint(t *testing.T) {
+	s := &StreamService{reconnectBackoff: time.Second, reconnectJitter: 500 * time.Millisecond}
+	for i := 0; i < 100; i++ {
+		hint := s.reconnectHint()
+		if hint.Reason != "shutdown" || hint.RetryAfterMs < 1000 || hint.RetryAfterMs >= 1500 {
+			t.Fatalf("reconnectHint() = %+v, want shutdown with a retry in [1000, 1500)", hint)
+		}
+	}
+
+	s.reconnectJitter = 0
+	if hint := s.reconnectHint(); hint.RetryAfterMs != 1000 {
+		t.Errorf("reconnectHint() without jitter = %+v, want a retry of 1000", hint)
+	}
+}
+
+func TestRunTickerStreamDrain(t *testing.T) {
+	s := connectedStreamService(t, &config.Config{StreamBatchEvery: time.Minute, ReconnectBackoff: time.Second, ReconnectJitter: time.Second},
+		models.InstrumentModel{InstrumentToken: 100001, Tradingsymbol: "INFY", Exchange: "NSE"})
+
+	e := echo.New()
+	e.GET("/stream", func(c echo.Context) error {
+		errChan := make(chan error, 1)
+		s.RunTickerStream(c.Request().Context(), c, "DEV001", "enctoken", []string{"NSE:INFY"}, true, errChan)
+		return nil
+	})
+	server := httptest.NewServer(e)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/stream")
+	if err != nil {
+		t.Fatalf("GET /stream error = %v", err)
+	}
+	defer resp.Body.Close()
+	reader := bufio.NewReader(resp.Body)
+	if line, err := reader.ReadString('\n'); err != nil || line != "data: connected\n" {
+		t.Fatalf("first line = %q, %v, want connected", line, err)
+	}
+
+	// the tick is held for the next batch, a minute away, so it is only sent by the final snapshot
+	s.broadcastTick(kiteticker.Tick{InstrumentToken: 100001, LastPrice: 1010})
+	time.Sleep(50 * time.Millisecond)
+	s.Drain()
+
+	body, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("reading the final message error = %v", err)
+	}
+	events := strings.Split(strings.TrimSpace(string(body)), "\n\n")
+	if len(events) != 2 {
+		t.Fatalf("final message = %q, want a snapshot and a reconnect event", body)
+	}
+	snapshot, ok := strings.CutPrefix(events[0], "event: snapshot\ndata: ")
+	if !ok || !strings.Contains(snapshot, `"last_price":1010`) {
+		t.Errorf("snapshot event = %q, want the held tick", events[0])
+	}
+
+	lines := strings.Split(events[1], "\n")
+	if len(lines) != 3 || lines[1] != "event: reconnect" {
+		t.Fatalf("reconnect event = %q, want retry, event and data lines", events[1])
+	}
+	var hint StreamReconnectHint
+	if err := json.Unmarshal([]byte(strings.TrimPrefix(lines[2], "data: ")), &hint); err != nil {
+		t.Fatalf("reconnect hint %q error = %v", lines[2], err)
+	}
+	if hint.Reason != "shutdown" || hint.RetryAfterMs < 1000 || hint.RetryAfterMs >= 2000 {
+		t.Errorf("reconnect hint = %+v, want shutdown with a retry in [1000, 2000)", hint)
+	}
+	if want := fmt.Sprintf("retry: %d", hint.RetryAfterMs); lines[0] != want {
+		t.Errorf("reconnect retry = %q, want %q", lines[0], want)
+	}
+}
+
+func TestBroadcastSlowClient(t *testing.T) {
+	// a single worker, so a blocked client would stall every other client if its sends were not dropped
+	s := NewStreamService(&config.Config{BroadcastWorkers: 1, BroadcastTimeout: 20 * time.Millisecond}, instrumentsDB(t))
+	newClient := func(id string, channel chan StreamTick) *StreamClient {
+		client := &StreamClient{
+			ID:       id,
+			TokenMap: map[uint32]string{100001: "NSE:INFY"},
+			Channel:  channel,
+			done:     make(chan struct{}),
+		}
+		s.addClient(client)
+		t.Cleanup(func() { s.removeClient(id) })
+		return client
+	}
+	fast := make(chan StreamTick, 100)
+	newClient("fast", fast)
+	blocked := newClient("blocked", make(chan StreamTick))
+
+	const ticks = 50
+	for i := 0; i < ticks; i++ {
+		s.broadcastTick(kiteticker.Tick{InstrumentToken: 100001, LastPrice: float64(1000 + i)})
+	}
+
+	timeout := time.After(time.Second)
+	for received := 0; received < ticks; received++ {
+		select {
+		case <-fast:
+		case <-timeout:
+			t.Fatalf("fast client received %d of %d ticks, want all while the other client is blocked", received, ticks)
+		}
+	}
+	if !blocked.lagging.Load() {
+		t.Errorf("blocked client lagging = false, want true after a timed out send")
+	}
+}
@@ -0,0 +1,354 @@
+// Package service contains the service layer for the Moneybots API
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/nsvirk/moneybotsapi/internal/models"
+)
+
+// Modes of the instruments subscribed on a socket or event stream, the ticks are sent shaped like the quote, ohlc or ltp APIs
+const (
+	StreamModeQuote = "quote"
+	StreamModeOHLC  = "ohlc"
+	StreamModeLTP   = "ltp"
+)
+
+// IsStreamMode reports if the mode is one of the modes of the streamed ticks
+func IsStreamMode(mode string) bool {
+	return mode == StreamModeQuote || mode == StreamModeOHLC || mode == StreamModeLTP
+}
+
+// Socket actions sent by the clients
+const (
+	StreamActionSubscribe   = "subscribe"
+	StreamActionUnsubscribe = "unsubscribe"
+)
+
+const (
+	// socketWriteWait is the time allowed to write a message to the client
+	socketWriteWait = 10 * time.Second
+	// socketPongWait is the time allowed to read the next pong, or any message, from the client
+	socketPongWait = 60 * time.Second
+	// socketPingPeriod is how often the client is pinged, it must be less than socketPongWait
+	socketPingPeriod = 30 * time.Second
+	// socketMaxMessageSize is the max size of a message read from the client
+	socketMaxMessageSize = 64 * 1024
+)
+
+// StreamTickMapper maps the ticker data of an instrument to its payload in the mode
+type StreamTickMapper func(mode string, tick *models.TickerData) interface{}
+
+// StreamSocketRequest is a message sent by a socket client to change its subscriptions
+// The mode of a subscribe defaults to `quote`, and subscribing an instrument again changes its mode
+type StreamSocketRequest struct {
+	Action      string   `json:"action"`
+	Instruments []string `json:"instruments"`
+	Mode        string   `json:"mode"`
+}
+
+// StreamSocketMessage is a message sent to a socket client
+// The `tick` messages have the payload of the mode in Data, and `reconnect` has the StreamReconnectHint
+type StreamSocketMessage struct {
+	Type        string      `json:"type"`
+	Instrument  string      `json:"instrument,omitempty"`
+	Mode        string      `json:"mode,omitempty"`
+	Instruments []string    `json:"instruments,omitempty"`
+	Message     string      `json:"message,omitempty"`
+	Data        interface{} `json:"data,omitempty"`
+}
+
+// ConnectTicker starts the ticker of the streams with the user's session if it is not running,
+// and waits for it to connect
+func (s *StreamService) ConnectTicker(ctx context.Context, userId, enctoken string) error {
+	s.mu.Lock()
+	if s.ticker == nil {
+		if err := s.initTicker(userId, enctoken); err != nil {
+			s.mu.Unlock()
+			return fmt.Errorf("failed to initialize ticker: %v", err)
+		}
+	}
+	s.mu.Unlock()
+
+	if err := s.waitForConnection(ctx); err != nil {
+		return fmt.Errorf("connection timeout: %v", err)
+	}
+	return nil
+}
+
+// socketTracker counts the socket streams still running, the sockets are hijacked from the http server
+// so its shutdown does not wait for them, see WaitSocketsClosed
+// Once the wait has started no socket is added, as a WaitGroup must not be added to while it is waited on
+type socketTracker struct {
+	mu       sync.Mutex
+	draining bool
+	wg       sync.WaitGroup
+}
+
+// openSockets tracks the socket streams of the server
+var openSockets = &socketTracker{}
+
+// add tracks a new socket, it returns false once the sockets are draining
+func (t *socketTracker) add() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.draining {
+		return false
+	}
+	t.wg.Add(1)
+	return true
+}
+
+// done untracks a socket added with add
+func (t *socketTracker) done() {
+	t.wg.Done()
+}
+
+// wait stops tracking new sockets and waits for the tracked ones to end, until ctx is done
+func (t *socketTracker) wait(ctx context.Context) bool {
+	t.mu.Lock()
+	t.draining = true
+	t.mu.Unlock()
+
+	closed := make(chan struct{})
+	go func() {
+		t.wg.Wait()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// WaitSocketsClosed waits for the socket streams to end after they are drained, until ctx is done,
+// sockets opened after the wait has started are closed right away with a reconnect hint
+// It returns false if sockets were still open when ctx was done
+func WaitSocketsClosed(ctx context.Context) bool {
+	return openSockets.wait(ctx)
+}
+
+// RunTickerSocket runs the ticker stream of a socket client until it disconnects or the streams are drained
+// The client starts without subscriptions and manages them with StreamSocketRequest messages,
+// the ticks of its instruments are mapped by mapper to the mode they were subscribed in
+// The ticker must be connected with ConnectTicker first
+func (s *StreamService) RunTickerSocket(ctx context.Context, conn *websocket.Conn, clientID string, mapper StreamTickMapper) error {
+	if !openSockets.add() {
+		return s.writeSocketFinalMessage(conn, nil)
+	}
+	defer openSockets.done()
+
+	clientChan := make(chan StreamTick, 100)
+	client := &StreamClient{
+		ID:         clientID,
+		TokenMap:   make(map[uint32]string),
+		Modes:      make(map[uint32]string),
+		Channel:    clientChan,
+		subscribed: make(map[uint32]bool),
+		done:       make(chan struct{}),
+		mapper:     mapper,
+	}
+
+	s.addClient(client)
+	defer s.removeClient(clientID)
+	defer s.unsubscribeClient(client)
+
+	// the client messages are read on their own goroutine, as a websocket has a single reader
+	conn.SetReadLimit(socketMaxMessageSize)
+	_ = conn.SetReadDeadline(time.Now().Add(socketPongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(socketPongWait))
+	})
+	requests := make(chan []byte)
+	readErr := make(chan error, 1)
+	go func() {
+		for {
+			_, message, err := conn.ReadMessage()
+			if err != nil {
+				readErr <- err
+				return
+			}
+			_ = conn.SetReadDeadline(time.Now().Add(socketPongWait))
+			select {
+			case requests <- message:
+			case <-client.done:
+				return
+			}
+		}
+	}()
+
+	ping := time.NewTicker(socketPingPeriod)
+	defer ping.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-s.draining:
+			return s.writeSocketFinalMessage(conn, clientChan)
+		case err := <-readErr:
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway, websocket.CloseNoStatusReceived) {
+				return err
+			}
+			return nil
+		case message := <-requests:
+			if err := writeSocketJSON(conn, s.handleSocketRequest(client, message)); err != nil {
+				return err
+			}
+		case tick := <-clientChan:
+			if err := writeSocketMessage(conn, tick.Data); err != nil {
+				return err
+			}
+		case <-ping.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(socketWriteWait)); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// handleSocketRequest applies the subscription request of the client and returns the reply to it
+func (s *StreamService) handleSocketRequest(client *StreamClient, message []byte) StreamSocketMessage {
+	var req StreamSocketRequest
+	if err := json.Unmarshal(message, &req); err != nil {
+		return socketError("invalid message: %v", err)
+	}
+	if len(req.Instruments) == 0 {
+		return socketError("`instruments` is required")
+	}
+
+	switch req.Action {
+	case StreamActionSubscribe:
+		mode := req.Mode
+		if mode == "" {
+			mode = StreamModeQuote
+		}
+		if !IsStreamMode(mode) {
+			return socketError("invalid `mode` %s, must be one of quote, ohlc or ltp", req.Mode)
+		}
+		tokenMap, err := s.prepareTokenMap(req.Instruments)
+		if err != nil {
+			return socketError("%v", err)
+		}
+		instruments, err := s.subscribeSocketClient(client, tokenMap, mode)
+		if err != nil {
+			return socketError("failed to subscribe: %v", err)
+		}
+		return StreamSocketMessage{Type: "subscribed", Mode: mode, Instruments: instruments}
+	case StreamActionUnsubscribe:
+		return StreamSocketMessage{Type: "unsubscribed", Instruments: s.unsubscribeSocketClient(client, req.Instruments)}
+	default:
+		return socketError("invalid `action` %s, must be subscribe or unsubscribe", req.Action)
+	}
+}
+
+// subscribeSocketClient adds the instruments of the tokenMap to the subscriptions of the client in the mode,
+// and subscribes their tokens on the ticker, it returns the subscribed instruments
+func (s *StreamService) subscribeSocketClient(client *StreamClient, tokenMap map[uint32]string, mode string) ([]string, error) {
+	tokens := make([]uint32, 0, len(tokenMap))
+	instruments := make([]string, 0, len(tokenMap))
+	for token, instrument := range tokenMap {
+		tokens = append(tokens, token)
+		instruments = append(instruments, instrument)
+	}
+
+	// the ticker is subscribed first, so the client never waits on tokens which failed to subscribe
+	if err := s.subscribeClientTokens(client, tokens); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for token, instrument := range tokenMap {
+		client.TokenMap[token] = instrument
+		client.Modes[token] = mode
+		s.globalTokenMap[token] = instrument
+	}
+	return instruments, nil
+}
+
+// unsubscribeSocketClient removes the instruments from the subscriptions of the client,
+// it returns the instruments which were subscribed
+// Their tokens are unsubscribed on the ticker once no other client holds them
+func (s *StreamService) unsubscribeSocketClient(client *StreamClient, instruments []string) []string {
+	remove := make(map[string]bool, len(instruments))
+	for _, instrument := range instruments {
+		remove[strings.TrimSpace(instrument)] = true
+	}
+
+	s.mu.Lock()
+	removed := []string{}
+	removedTokens := []uint32{}
+	for token, instrument := range client.TokenMap {
+		if remove[instrument] {
+			delete(client.TokenMap, token)
+			delete(client.Modes, token)
+			removed = append(removed, instrument)
+			removedTokens = append(removedTokens, token)
+		}
+	}
+	s.cleanupGlobalTokenMap()
+	s.mu.Unlock()
+
+	s.unsubscribeClientTokens(client, removedTokens)
+	return removed
+}
+
+// writeSocketFinalMessage sends the ticks not yet sent to the client, followed by a `reconnect` message
+// with a jittered backoff, and closes the socket as going away
+func (s *StreamService) writeSocketFinalMessage(conn *websocket.Conn, clientChan <-chan StreamTick) error {
+buffered:
+	for {
+		select {
+		case tick := <-clientChan:
+			if err := writeSocketMessage(conn, tick.Data); err != nil {
+				return err
+			}
+		default:
+			break buffered
+		}
+	}
+
+	if err := writeSocketJSON(conn, StreamSocketMessage{Type: "reconnect", Data: s.reconnectHint()}); err != nil {
+		return err
+	}
+	closeMessage := websocket.FormatCloseMessage(websocket.CloseGoingAway, "shutdown")
+	return conn.WriteControl(websocket.CloseMessage, closeMessage, time.Now().Add(socketWriteWait))
+}
+
+// socketTickMessage encodes the `tick` message of the instrument in the mode
+func socketTickMessage(mapper StreamTickMapper, mode, instrument string, tick *models.TickerData) ([]byte, error) {
+	return json.Marshal(StreamSocketMessage{
+		Type:       "tick",
+		Instrument: instrument,
+		Mode:       mode,
+		Data:       mapper(mode, tick),
+	})
+}
+
+func socketError(format string, args ...interface{}) StreamSocketMessage {
+	return StreamSocketMessage{Type: "error", Message: fmt.Sprintf(format, args...)}
+}
+
+func writeSocketJSON(conn *websocket.Conn, message StreamSocketMessage) error {
+	data, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+	return writeSocketMessage(conn, data)
+}
+
+func writeSocketMessage(conn *websocket.Conn, data []byte) error {
+	if err := conn.SetWriteDeadline(time.Now().Add(socketWriteWait)); err != nil {
+		return err
+	}
+	return conn.WriteMessage(websocket.TextMessage, data)
+}
@@ -0,0 +1,53 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestSocketTrackerWait(t *testing.T) {
+	tracker := &socketTracker{}
+	if !tracker.add() {
+		t.Fatalf("add() = false before the wait, want true")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if tracker.wait(ctx) {
+		t.Errorf("wait() = true with an open socket, want false")
+	}
+	if tracker.add() {
+		t.Errorf("add() = true after the wait started, want false")
+	}
+
+	tracker.done()
+	if !tracker.wait(context.Background()) {
+		t.Errorf("wait() = false after the socket closed, want true")
+	}
+}
+
+func TestSocketTrackerAddDuringWait(t *testing.T) {
+	tracker := &socketTracker{}
+	if !tracker.add() {
+		t.Fatalf("add() = false before the wait, want true")
+	}
+
+	waited := make(chan bool)
+	go func() {
+		waited <- tracker.wait(context.Background())
+	}()
+	// sockets racing the wait are either rejected or tracked before it started, never lost
+	added := 0
+	for i := 0; i < 100; i++ {
+		if tracker.add() {
+			added++
+		}
+	}
+	for i := 0; i < added+1; i++ {
+		tracker.done()
+	}
+	if !<-waited {
+		t.Errorf("wait() = false, want true")
+	}
+}
@@ -0,0 +1,128 @@
+// Package service contains the service layer for the Moneybots API
+package service
+
+import (
+	"sync"
+	"time"
+
+	"github.com/nsvirk/moneybotsapi/internal/config"
+	"github.com/nsvirk/moneybotsapi/internal/metrics"
+	"github.com/nsvirk/moneybotsapi/internal/models"
+	"github.com/nsvirk/moneybotsapi/internal/repository"
+	"github.com/nsvirk/moneybotsapi/pkg/utils/zaplogger"
+	"gorm.io/gorm"
+)
+
+// tickWriterQueueBatches is the number of batches of ticks the writer queues before dropping ticks
+const tickWriterQueueBatches = 10
+
+// tickWriter persists the ticks of the ticker, nil when the tick store is disabled
+var tickWriter *TickWriter
+
+// StartTickWriter starts persisting the ticks of the ticker into the ticks table when the tick store is enabled
+func StartTickWriter(cfg *config.Config, db *gorm.DB) {
+	if !cfg.TickStore {
+		return
+	}
+	tickWriter = NewTickWriter(db, cfg.TickStoreBatch, cfg.TickStoreFlush)
+	go tickWriter.run()
+}
+
+// StopTickWriter writes the queued ticks and stops persisting the ticks, it is called on shutdown
+func StopTickWriter() {
+	if tickWriter != nil {
+		tickWriter.Stop()
+	}
+}
+
+// TickWriter batches the ticks of the ticker and bulk inserts them, a batch is written once it has batchSize
+// ticks or every flushInterval, the ticks are dropped instead of blocking the ticker when the writer falls behind
+type TickWriter struct {
+	repo          *repository.TickRepository
+	batchSize     int
+	flushInterval time.Duration
+	ticks         chan models.TickModel
+	stop          chan struct{}
+	stopOnce      sync.Once
+	done          chan struct{}
+}
+
+// NewTickWriter creates a new TickWriter
+func NewTickWriter(db *gorm.DB, batchSize int, flushInterval time.Duration) *TickWriter {
+	return &TickWriter{
+		repo:          repository.NewTickRepository(db),
+		batchSize:     batchSize,
+		flushInterval: flushInterval,
+		ticks:         make(chan models.TickModel, batchSize*tickWriterQueueBatches),
+		stop:          make(chan struct{}),
+		done:          make(chan struct{}),
+	}
+}
+
+// Add queues the tick to be written
+func (w *TickWriter) Add(tick *models.TickerData) {
+	select {
+	case w.ticks <- models.NewTickModel(tick):
+	default:
+		metrics.RecordTicksDropped("queue_full", 1)
+	}
+}
+
+// Stop writes the queued ticks and stops the writer, the ticks added after are dropped
+func (w *TickWriter) Stop() {
+	w.stopOnce.Do(func() {
+		close(w.stop)
+	})
+	<-w.done
+}
+
+// run writes the queued ticks in batches until the writer is stopped
+func (w *TickWriter) run() {
+	defer close(w.done)
+	batch := make([]models.TickModel, 0, w.batchSize)
+	ticker := time.NewTicker(w.flushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case tick := <-w.ticks:
+			batch = append(batch, tick)
+			if len(batch) >= w.batchSize {
+				batch = w.flush(batch)
+			}
+		case <-ticker.C:
+			batch = w.flush(batch)
+		case <-w.stop:
+			for {
+				select {
+				case tick := <-w.ticks:
+					batch = append(batch, tick)
+					if len(batch) >= w.batchSize {
+						batch = w.flush(batch)
+					}
+				default:
+					w.flush(batch)
+					return
+				}
+			}
+		}
+	}
+}
+
+// flush writes the batch and returns it emptied, a batch which failed to write is dropped
+func (w *TickWriter) flush(batch []models.TickModel) []models.TickModel {
+	if len(batch) == 0 {
+		return batch
+	}
+	count, err := w.repo.InsertTicks(batch)
+	if err != nil {
+		zaplogger.Error("Failed to persist ticks", zaplogger.Fields{
+			"ticks": len(batch),
+			"error": err.Error(),
+		})
+		metrics.RecordTicksDropped("insert_failed", len(batch))
+	} else {
+		metrics.RecordTicksStored(int(count))
+	}
+	return batch[:0]
+}
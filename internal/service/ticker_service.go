@@ -4,14 +4,19 @@ package service
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"math"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	kiteticker "github.com/nsvirk/gokiteticker"
+	"github.com/nsvirk/moneybotsapi/internal/config"
+	"github.com/nsvirk/moneybotsapi/internal/metrics"
 	"github.com/nsvirk/moneybotsapi/internal/models"
 	"github.com/nsvirk/moneybotsapi/internal/repository"
+	"github.com/nsvirk/moneybotsapi/pkg/utils/zaplogger"
 	"github.com/redis/go-redis/v9"
 
 	"gorm.io/gorm"
@@ -19,6 +24,14 @@ import (
 
 const tickerReconnectMaxRetries = 10 // 10 retries
 
+// tickerConnected is set while a ticker is connected, the ticker services of the routes and the cron jobs share it
+var tickerConnected atomic.Bool
+
+// TickerConnected returns if a ticker is connected
+func TickerConnected() bool {
+	return tickerConnected.Load()
+}
+
 // TickerService
 const (
 	batchSize                       = 1000
@@ -41,27 +54,37 @@ type TickerService struct {
 	ticker            *kiteticker.Ticker
 	mu                sync.Mutex
 	isRunning         bool
+	mode              kiteticker.Mode
 	instruments       map[uint32]string
 	tickChannel       chan kiteticker.Tick
 	ctx               context.Context
 	cancel            context.CancelFunc
 	instrumentService *InstrumentService
 	indexService      *IndexService
+	alertService      *AlertService
+	priceAlerts       *PriceAlertEvaluator
 }
 
 // NewService creates a new TickerService
-func NewTickerService(db *gorm.DB, redisClient *redis.Client) *TickerService {
+func NewTickerService(cfg *config.Config, db *gorm.DB, redisClient *redis.Client) *TickerService {
+	priceAlerts, err := NewPriceAlertEvaluator(cfg.PriceAlerts)
+	if err != nil {
+		zaplogger.Fatal("failed to create price alerts", zaplogger.Fields{"error": err})
+	}
 	ctx, cancel := context.WithCancel(context.Background())
 	return &TickerService{
 		repo:              repository.NewTickerRepository(db),
 		redisClient:       redisClient,
 		isRunning:         false,
+		mode:              kiteticker.Mode(cfg.TickerMode),
 		instruments:       make(map[uint32]string),
 		tickChannel:       make(chan kiteticker.Tick, channelCapacity),
 		ctx:               ctx,
 		cancel:            cancel,
 		instrumentService: NewInstrumentService(db),
 		indexService:      NewIndexService(db),
+		alertService:      NewAlertService(cfg),
+		priceAlerts:       priceAlerts,
 	}
 }
 
@@ -102,9 +125,10 @@ func (s *TickerService) Start(userID, enctoken string) error {
 	if err := s.ticker.Subscribe(tickerInstrumentTokens); err != nil {
 		return err
 	}
+	metrics.SetTickerSubscriptions(len(tickerInstrumentTokens))
 
-	// Set ticker mode to full
-	if err := s.ticker.SetMode(kiteticker.ModeFull, tickerInstrumentTokens); err != nil {
+	// Set the ticker mode, ltp and quote ticks leave the depth and the fields beyond them empty
+	if err := s.ticker.SetMode(s.mode, tickerInstrumentTokens); err != nil {
 		return err
 	}
 
@@ -114,6 +138,7 @@ func (s *TickerService) Start(userID, enctoken string) error {
 
 	s.repo.Info("Start", "Ticker started successfully")
 	s.isRunning = true
+	tickerConnected.Store(true)
 
 	return nil
 }
@@ -142,6 +167,7 @@ func (s *TickerService) Stop(userID string) error {
 
 	// Unsubscribe from instruments
 	s.ticker.Unsubscribe(tickerInstrumentTokens)
+	metrics.SetTickerSubscriptions(0)
 	time.Sleep(1 * time.Second)
 
 	// Stop the ticker
@@ -149,6 +175,7 @@ func (s *TickerService) Stop(userID string) error {
 	s.ticker.Stop()
 	s.ticker = nil
 	s.isRunning = false
+	tickerConnected.Store(false)
 
 	// s.cancel() // if this is enable then the ticker doesnt run on next start
 
@@ -197,12 +224,15 @@ func (s *TickerService) SetReconnectMaxRetries(retries int) {
 func (s *TickerService) setupTickerCallbacks() {
 	s.ticker.OnTick(func(tick kiteticker.Tick) {
 		// fmt.Println(tick)
+		recordRawTick(tick)
+		metrics.RecordTick()
 		s.tickChannel <- tick
 	})
 
 	s.ticker.OnConnect(func() {
 		s.repo.Info("OnConnect", "Connected to ticker")
 		s.isRunning = true
+		tickerConnected.Store(true)
 	})
 
 	s.ticker.OnError(func(err error) {
@@ -212,6 +242,7 @@ func (s *TickerService) setupTickerCallbacks() {
 	s.ticker.OnClose(func(code int, reason string) {
 		s.repo.Warn("OnClose", fmt.Sprintf("Closed with code %d: %s", code, reason))
 		s.isRunning = false
+		tickerConnected.Store(false)
 	})
 
 	s.ticker.OnReconnect(func(attempt int, delay time.Duration) {
@@ -253,26 +284,37 @@ func (s *TickerService) processTick(tick kiteticker.Tick, postgresData *[]models
 		return
 	}
 
-	// convert kiteticker.Tick to JSON
-	// tickJson, err := json.Marshal(tick)
-	// if err != nil {
-	// 	s.repo.LogTickerEvent("processTick", fmt.Sprintf("error marshaling tick to JSON: %v", tick.InstrumentToken))
-	// }
-
-	tickOHLCJson, err := json.Marshal(tick.OHLC)
+	tickerData, err := newTickerData(instrument, tick)
 	if err != nil {
-		s.repo.Error("processTick", fmt.Sprintf("error marshaling tick OHLC to JSON: %v", tick.InstrumentToken))
+		s.repo.Error("processTick", fmt.Sprintf("error marshaling tick to JSON: %v: %v", tick.InstrumentToken, err))
+	}
 
+	// ---- SAVE INTRADAY SAMPLE --------------------------------------
+	if tick.IsTradable {
+		intradaySampleStore.Add(instrument, tickerData.LastTradeTime, tick.LastPrice, tick.VolumeTraded)
+		tradeTapeStore.Add(instrument, tickerData.LastTradeTime, tick.LastPrice, tick.LastTradedQuantity, tick.VolumeTraded,
+			tick.Depth.Buy[0].Price, tick.Depth.Sell[0].Price)
 	}
-	tickDepthJson, err := json.Marshal(tick.Depth)
-	if err != nil {
-		s.repo.Error("processTick", fmt.Sprintf("error marshaling tick Depth to JSON: %v", tick.InstrumentToken))
+
+	// ---- PERSIST THE TICK ------------------------------------------
+	if tickWriter != nil {
+		tickWriter.Add(&tickerData)
 	}
 
+	// ---- SAVE TO POSTGRES -----------------------------------------
+	// Append the tick to the Postgres data slice
+	*postgresData = append(*postgresData, tickerData)
+}
+
+// newTickerData converts the kiteticker.Tick of the instrument to its ticker data
+// The data is returned along with a marshaling error, without the OHLC or depth which failed to marshal
+func newTickerData(instrument string, tick kiteticker.Tick) (models.TickerData, error) {
+	tickOHLCJson, ohlcErr := json.Marshal(tick.OHLC)
+	tickDepthJson, depthErr := json.Marshal(tick.Depth)
+
 	// Round NetChange to 2 decimal points
 	roundedNetChange := math.Round(tick.NetChange*100) / 100
 
-	// convert kiteticker.Tick type to ticker.TickerData tyep
 	tickerData := models.TickerData{
 		// custom
 		Instrument: instrument,
@@ -297,13 +339,9 @@ func (s *TickerService) processTick(tick kiteticker.Tick, postgresData *[]models
 		NetChange:         roundedNetChange,
 		OHLC:              tickOHLCJson,
 		Depth:             tickDepthJson,
-		// Tick:               tickJson,
-		UpdatedAt: time.Now(),
+		UpdatedAt:         time.Now(),
 	}
-
-	// ---- SAVE TO POSTGRES -----------------------------------------
-	// Append the tick to the Postgres data slice
-	*postgresData = append(*postgresData, tickerData)
+	return tickerData, errors.Join(ohlcErr, depthErr)
 }
 
 // flushData flushes the data to postgres
@@ -312,6 +350,24 @@ func (s *TickerService) flushData(postgresData *[]models.TickerData) {
 	if len(*postgresData) > 0 {
 		if err := s.repo.UpsertTickerData(*postgresData); err != nil {
 			s.repo.Error("flushData", fmt.Sprintf("Failed to save ticks to Postgres: %v", err))
+		} else {
+			instruments := make([]string, len(*postgresData))
+			for i, data := range *postgresData {
+				instruments[i] = data.Instrument
+			}
+			metrics.RecordQuoteRefresh(instruments, time.Now())
+			MarkQuotesRefreshed()
+
+			if quoteRedisCache != nil {
+				if err := quoteRedisCache.put(*postgresData); err != nil {
+					s.repo.Error("flushData", fmt.Sprintf("Failed to cache ticks in Redis: %v", err))
+				}
+			}
+
+			// only the alerts of the instruments updated in this cycle are evaluated
+			if messages := s.priceAlerts.Evaluate(*postgresData); len(messages) > 0 {
+				go dispatchPriceAlerts(s.alertService, messages)
+			}
 		}
 		*postgresData = (*postgresData)[:0]
 	}
@@ -0,0 +1,112 @@
+// Package service contains the service layer for the Moneybots API
+package service
+
+import (
+	"sync"
+	"time"
+)
+
+// TradeTapeSize is the max number of trades kept per instrument
+const TradeTapeSize = 200
+
+// tradeTapeStore holds the trade tape of the current day fed by the ticker
+var tradeTapeStore = NewTradeTapeStore(TradeTapeSize)
+
+// Aggressor sides of a trade
+const (
+	TradeSideBuy  = "buy"
+	TradeSideSell = "sell"
+)
+
+// TapeTrade is a trade on the tape, Side is the aggressor side, empty when it is not known
+type TapeTrade struct {
+	Price    float64   `json:"price"`
+	Quantity uint32    `json:"quantity"`
+	Time     time.Time `json:"time"`
+	Side     string    `json:"side"`
+}
+
+// tradeTape is the trade tape of an instrument, oldest trade first
+type tradeTape struct {
+	day        string
+	lastVolume uint32
+	lastPrice  float64
+	lastSide   string
+	trades     []TapeTrade
+}
+
+// TradeTapeStore keeps the last trades of each instrument for the current day
+// The feed only has the last trade of each tick, so a trade is recorded for each tick which traded volume,
+// trades between two ticks are not seen
+type TradeTapeStore struct {
+	mu    sync.RWMutex
+	size  int
+	tapes map[string]*tradeTape
+}
+
+// NewTradeTapeStore creates a new TradeTapeStore keeping size trades per instrument
+func NewTradeTapeStore(size int) *TradeTapeStore {
+	return &TradeTapeStore{size: size, tapes: make(map[string]*tradeTape)}
+}
+
+// Add adds the last trade of a tick of the instrument, cumulativeVolume is the day volume reported by the feed
+// and bestBid and bestAsk are the top of the depth, 0 when the tick has no depth
+// The aggressor is the side whose quote the trade hit, or by the tick rule when it traded inside the spread
+func (s *TradeTapeStore) Add(instrument string, t time.Time, price float64, quantity, cumulativeVolume uint32, bestBid, bestAsk float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	day := t.In(MarketLocation).Format("2006-01-02")
+	tape, ok := s.tapes[instrument]
+	if !ok || tape.day != day || cumulativeVolume < tape.lastVolume {
+		// first tick of the day only sets the volume baseline
+		s.tapes[instrument] = &tradeTape{day: day, lastVolume: cumulativeVolume, lastPrice: price}
+		return
+	}
+	if cumulativeVolume == tape.lastVolume {
+		return
+	}
+	tradedVolume := cumulativeVolume - tape.lastVolume
+	if quantity == 0 || quantity > tradedVolume {
+		quantity = tradedVolume
+	}
+
+	side := tape.lastSide
+	switch {
+	case bestAsk > 0 && price >= bestAsk:
+		side = TradeSideBuy
+	case bestBid > 0 && price <= bestBid:
+		side = TradeSideSell
+	case price > tape.lastPrice:
+		side = TradeSideBuy
+	case price < tape.lastPrice:
+		side = TradeSideSell
+	}
+
+	tape.lastVolume = cumulativeVolume
+	tape.lastPrice = price
+	tape.lastSide = side
+	if len(tape.trades) == s.size {
+		tape.trades = tape.trades[1:]
+	}
+	tape.trades = append(tape.trades, TapeTrade{Price: price, Quantity: quantity, Time: t, Side: side})
+}
+
+// Trades returns a copy of the last n trades of the instrument on the day of now, newest first
+func (s *TradeTapeStore) Trades(instrument string, n int, now time.Time) []TapeTrade {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	tape, ok := s.tapes[instrument]
+	if !ok || tape.day != now.In(MarketLocation).Format("2006-01-02") {
+		return []TapeTrade{}
+	}
+	if n > len(tape.trades) {
+		n = len(tape.trades)
+	}
+	trades := make([]TapeTrade, 0, n)
+	for i := len(tape.trades) - 1; i >= len(tape.trades)-n; i-- {
+		trades = append(trades, tape.trades[i])
+	}
+	return trades
+}
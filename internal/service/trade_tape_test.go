@@ -0,0 +1,110 @@
+package service
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTradeTapeStore(t *testing.T) {
+	start := time.Date(2024, 10, 15, 9, 15, 0, 0, MarketLocation)
+
+	// tick is the last trade of a tick and the cumulative day volume and top of book reported with it
+	type tick struct {
+		price    float64
+		quantity uint32
+		volume   uint32
+		bid, ask float64
+	}
+	ticks := []tick{
+		{price: 100, quantity: 10, volume: 1000, bid: 99.95, ask: 100.05}, // baseline
+		{price: 100.05, quantity: 5, volume: 1005, bid: 100, ask: 100.05},
+		{price: 100, quantity: 20, volume: 1025, bid: 100, ask: 100.05},
+		{price: 100, quantity: 7, volume: 1025, bid: 100, ask: 100.05}, // no volume traded
+		{price: 100.1, quantity: 50, volume: 1040},                     // capped at the volume traded, no depth
+		{price: 100.05, quantity: 3, volume: 1043},
+	}
+
+	tests := []struct {
+		name       string
+		size       int
+		n          int
+		wantPrices []float64
+		wantQty    []uint32
+		wantSides  []string
+	}{
+		{
+			name:       "newest first",
+			size:       10,
+			n:          10,
+			wantPrices: []float64{100.05, 100.1, 100, 100.05},
+			wantQty:    []uint32{3, 15, 20, 5},
+			wantSides:  []string{TradeSideSell, TradeSideBuy, TradeSideSell, TradeSideBuy},
+		},
+		{
+			name:       "last n",
+			size:       10,
+			n:          2,
+			wantPrices: []float64{100.05, 100.1},
+			wantQty:    []uint32{3, 15},
+			wantSides:  []string{TradeSideSell, TradeSideBuy},
+		},
+		{
+			name:       "capped at the size",
+			size:       3,
+			n:          10,
+			wantPrices: []float64{100.05, 100.1, 100},
+			wantQty:    []uint32{3, 15, 20},
+			wantSides:  []string{TradeSideSell, TradeSideBuy, TradeSideSell},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := NewTradeTapeStore(tt.size)
+			at := start
+			for _, tick := range ticks {
+				at = at.Add(time.Second)
+				store.Add("NSE:INFY", at, tick.price, tick.quantity, tick.volume, tick.bid, tick.ask)
+			}
+
+			trades := store.Trades("NSE:INFY", tt.n, at)
+			if len(trades) != len(tt.wantPrices) {
+				t.Fatalf("Trades() = %d trades, want %d: %+v", len(trades), len(tt.wantPrices), trades)
+			}
+			for i, trade := range trades {
+				if trade.Price != tt.wantPrices[i] || trade.Quantity != tt.wantQty[i] || trade.Side != tt.wantSides[i] {
+					t.Errorf("trade %d = %v x %d %s, want %v x %d %s",
+						i, trade.Price, trade.Quantity, trade.Side, tt.wantPrices[i], tt.wantQty[i], tt.wantSides[i])
+				}
+				if i > 0 && !trade.Time.Before(trades[i-1].Time) {
+					t.Errorf("trade %d at %v is not older than trade %d at %v", i, trade.Time, i-1, trades[i-1].Time)
+				}
+			}
+		})
+	}
+}
+
+func TestTradeTapeStoreReset(t *testing.T) {
+	store := NewTradeTapeStore(10)
+	day := time.Date(2024, 10, 15, 15, 29, 0, 0, MarketLocation)
+	store.Add("NSE:INFY", day, 100, 10, 1000, 0, 0)
+	store.Add("NSE:INFY", day.Add(time.Second), 101, 10, 1010, 0, 0)
+
+	if trades := store.Trades("NSE:TCS", 10, day); trades == nil || len(trades) != 0 {
+		t.Errorf("Trades() of an instrument without trades = %v, want an empty list", trades)
+	}
+	nextDay := day.AddDate(0, 0, 1).Add(-6 * time.Hour)
+	if trades := store.Trades("NSE:INFY", 10, nextDay); len(trades) != 0 {
+		t.Errorf("Trades() on the next day = %d trades, want none", len(trades))
+	}
+
+	// the first tick of the next day resets the tape and only sets the baseline
+	store.Add("NSE:INFY", nextDay, 102, 10, 500, 0, 0)
+	if trades := store.Trades("NSE:INFY", 10, nextDay); len(trades) != 0 {
+		t.Errorf("Trades() after the first tick of the day = %d trades, want none", len(trades))
+	}
+	store.Add("NSE:INFY", nextDay.Add(time.Second), 103, 10, 510, 0, 0)
+	if trades := store.Trades("NSE:INFY", 10, nextDay); len(trades) != 1 || trades[0].Price != 103 {
+		t.Errorf("Trades() of the next day = %+v, want the single trade at 103", trades)
+	}
+}
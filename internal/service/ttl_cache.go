@@ -0,0 +1,126 @@
+// Package service contains the service layer for the Moneybots API
+package service
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/nsvirk/moneybotsapi/pkg/utils/clock"
+)
+
+// ttlCachePurgeEvery is the number of sets between the purges of the expired items
+const ttlCachePurgeEvery = 256
+
+// ttlCacheItem is a cached value with its expiry
+type ttlCacheItem[V any] struct {
+	value     V
+	expiresAt time.Time
+}
+
+// ttlCache is a concurrency safe in-memory cache with per item expiry
+type ttlCache[V any] struct {
+	mu     sync.RWMutex
+	items  map[string]ttlCacheItem[V]
+	sets   int
+	hits   atomic.Uint64
+	misses atomic.Uint64
+	clock  clock.Clock
+}
+
+// CacheStats are the lookup counts and the size of a cache, expired items not purged yet are counted in Items
+type CacheStats struct {
+	Hits   uint64 `json:"hits"`
+	Misses uint64 `json:"misses"`
+	Items  int    `json:"items"`
+}
+
+// statsCache is a cache reporting its stats
+type statsCache interface {
+	Stats() CacheStats
+}
+
+// cacheRegistry holds the named caches for reporting their stats
+var cacheRegistry = struct {
+	sync.Mutex
+	caches map[string]statsCache
+}{caches: make(map[string]statsCache)}
+
+// newTTLCache creates a new ttlCache, registered under name for its stats
+func newTTLCache[V any](name string) *ttlCache[V] {
+	c := &ttlCache[V]{items: make(map[string]ttlCacheItem[V]), clock: clock.Real}
+	cacheRegistry.Lock()
+	cacheRegistry.caches[name] = c
+	cacheRegistry.Unlock()
+	return c
+}
+
+// GetCacheStats returns the stats of the registered caches by name
+func GetCacheStats() map[string]CacheStats {
+	cacheRegistry.Lock()
+	defer cacheRegistry.Unlock()
+	stats := make(map[string]CacheStats, len(cacheRegistry.caches))
+	for name, c := range cacheRegistry.caches {
+		stats[name] = c.Stats()
+	}
+	return stats
+}
+
+// Stats returns the stats of the cache
+func (c *ttlCache[V]) Stats() CacheStats {
+	c.mu.RLock()
+	items := len(c.items)
+	c.mu.RUnlock()
+	return CacheStats{Hits: c.hits.Load(), Misses: c.misses.Load(), Items: items}
+}
+
+// Get returns the value for the key if present and not expired
+func (c *ttlCache[V]) Get(key string) (V, bool) {
+	c.mu.RLock()
+	item, ok := c.items[key]
+	c.mu.RUnlock()
+	if !ok || c.clock.Now().After(item.expiresAt) {
+		c.misses.Add(1)
+		var zero V
+		return zero, false
+	}
+	c.hits.Add(1)
+	return item.value, true
+}
+
+// Set sets the value for the key with the given ttl, the expired items are purged every ttlCachePurgeEvery sets,
+// so a set does not scan the whole cache
+func (c *ttlCache[V]) Set(key string, value V, ttl time.Duration) {
+	now := c.clock.Now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.sets++
+	if c.sets >= ttlCachePurgeEvery {
+		c.sets = 0
+		c.purgeLocked(now)
+	}
+	c.items[key] = ttlCacheItem[V]{value: value, expiresAt: now.Add(ttl)}
+}
+
+// purgeLocked deletes the items expired at now, the caller holds the lock
+func (c *ttlCache[V]) purgeLocked(now time.Time) {
+	for k, item := range c.items {
+		if now.After(item.expiresAt) {
+			delete(c.items, k)
+		}
+	}
+}
+
+// Delete removes the key from the cache
+func (c *ttlCache[V]) Delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.items, key)
+}
+
+// Clear removes all items from the cache
+func (c *ttlCache[V]) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.items = make(map[string]ttlCacheItem[V])
+}
@@ -0,0 +1,70 @@
+package service
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/nsvirk/moneybotsapi/pkg/utils/clock"
+)
+
+func newTestTTLCache(now time.Time) (*ttlCache[int], *clock.Fake) {
+	fake := clock.NewFake(now)
+	return &ttlCache[int]{items: make(map[string]ttlCacheItem[int]), clock: fake}, fake
+}
+
+func TestTTLCacheExpiry(t *testing.T) {
+	c, fake := newTestTTLCache(time.Date(2024, 10, 15, 9, 15, 0, 0, MarketLocation))
+	c.Set("a", 1, time.Minute)
+	c.Set("b", 2, time.Hour)
+
+	tests := []struct {
+		name    string
+		advance time.Duration
+		key     string
+		want    int
+		wantOK  bool
+	}{
+		{name: "fresh", key: "a", want: 1, wantOK: true},
+		{name: "at the ttl", advance: time.Minute, key: "a", want: 1, wantOK: true},
+		{name: "past the ttl", advance: time.Second, key: "a", wantOK: false},
+		{name: "longer ttl kept", key: "b", want: 2, wantOK: true},
+		{name: "missing", key: "c", wantOK: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake.Advance(tt.advance)
+			got, ok := c.Get(tt.key)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("Get(%q) = %v, %v, want %v, %v", tt.key, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+
+	if stats := c.Stats(); stats.Hits != 3 || stats.Misses != 2 {
+		t.Errorf("Stats() = %+v, want 3 hits and 2 misses", stats)
+	}
+}
+
+func TestTTLCachePurge(t *testing.T) {
+	c, fake := newTestTTLCache(time.Date(2024, 10, 15, 9, 15, 0, 0, MarketLocation))
+	for i := 0; i < ttlCachePurgeEvery/2; i++ {
+		c.Set("expired"+strconv.Itoa(i), i, time.Minute)
+	}
+	fake.Advance(2 * time.Minute)
+
+	// the expired items are kept until the purge, and dropped by it
+	for i := ttlCachePurgeEvery / 2; i < ttlCachePurgeEvery-1; i++ {
+		c.Set("live"+strconv.Itoa(i), i, time.Hour)
+	}
+	if got := c.Stats().Items; got != ttlCachePurgeEvery-1 {
+		t.Errorf("Stats().Items before the purge = %d, want %d", got, ttlCachePurgeEvery-1)
+	}
+	c.Set("last", 0, time.Hour)
+	if got, want := c.Stats().Items, ttlCachePurgeEvery/2; got != want {
+		t.Errorf("Stats().Items after the purge = %d, want %d", got, want)
+	}
+	if _, ok := c.Get("expired0"); ok {
+		t.Errorf("Get(%q) ok = true, want false", "expired0")
+	}
+}
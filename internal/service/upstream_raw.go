@@ -0,0 +1,36 @@
+// Package service contains the service layer for the Moneybots API
+package service
+
+import (
+	"sync"
+	"time"
+
+	kiteticker "github.com/nsvirk/gokiteticker"
+)
+
+// RawTick is a tick as received from the ticker, before any transformation
+type RawTick struct {
+	ReceivedAt time.Time       `json:"received_at"`
+	Tick       kiteticker.Tick `json:"tick"`
+}
+
+// rawTickStore holds the last raw tick of each instrument token, for comparing the feed with the quotes served
+var rawTickStore = struct {
+	sync.RWMutex
+	ticks map[uint32]RawTick
+}{ticks: make(map[uint32]RawTick)}
+
+// recordRawTick keeps the tick as the last raw tick of its instrument token
+func recordRawTick(tick kiteticker.Tick) {
+	rawTickStore.Lock()
+	defer rawTickStore.Unlock()
+	rawTickStore.ticks[tick.InstrumentToken] = RawTick{ReceivedAt: time.Now(), Tick: tick}
+}
+
+// GetRawTick returns the last raw tick of the instrument token, false if none was received since startup
+func GetRawTick(token uint32) (RawTick, bool) {
+	rawTickStore.RLock()
+	defer rawTickStore.RUnlock()
+	tick, ok := rawTickStore.ticks[token]
+	return tick, ok
+}
@@ -0,0 +1,77 @@
+package service
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	kiteticker "github.com/nsvirk/gokiteticker"
+	kitemodels "github.com/nsvirk/gokiteticker/models"
+)
+
+func TestGetRawTick(t *testing.T) {
+	tick := kiteticker.Tick{
+		Mode:               "full",
+		InstrumentToken:    408065,
+		IsTradable:         true,
+		Timestamp:          kitemodels.Time{Time: time.Date(2026, 10, 15, 10, 0, 0, 0, time.UTC)},
+		LastTradeTime:      kitemodels.Time{Time: time.Date(2026, 10, 15, 9, 59, 58, 0, time.UTC)},
+		LastPrice:          1890.35,
+		LastTradedQuantity: 12,
+		TotalBuyQuantity:   5400,
+		TotalSellQuantity:  6100,
+		VolumeTraded:       1250000,
+		AverageTradePrice:  1885.1,
+		NetChange:          -4.65,
+		OHLC:               kiteticker.OHLC{Open: 1895, High: 1901.2, Low: 1880, Close: 1895},
+	}
+	tick.Depth.Buy[0] = kiteticker.DepthItem{Price: 1890.3, Quantity: 40, Orders: 3}
+	tick.Depth.Sell[0] = kiteticker.DepthItem{Price: 1890.4, Quantity: 25, Orders: 2}
+
+	t.Cleanup(func() {
+		rawTickStore.Lock()
+		delete(rawTickStore.ticks, tick.InstrumentToken)
+		rawTickStore.Unlock()
+	})
+
+	if _, ok := GetRawTick(tick.InstrumentToken); ok {
+		t.Fatalf("GetRawTick(%d) found a tick before any was received", tick.InstrumentToken)
+	}
+
+	before := time.Now()
+	recordRawTick(tick)
+	rawTick, ok := GetRawTick(tick.InstrumentToken)
+	if !ok {
+		t.Fatalf("GetRawTick(%d) found no tick after one was received", tick.InstrumentToken)
+	}
+	if !reflect.DeepEqual(rawTick.Tick, tick) {
+		t.Errorf("GetRawTick(%d) = %+v, want the received tick %+v", tick.InstrumentToken, rawTick.Tick, tick)
+	}
+	if rawTick.ReceivedAt.Before(before) {
+		t.Errorf("GetRawTick(%d) received at = %v, want after %v", tick.InstrumentToken, rawTick.ReceivedAt, before)
+	}
+
+	// the tick is served with the field names of the ticker, not the ones of the quotes
+	body, err := json.Marshal(rawTick)
+	if err != nil {
+		t.Fatalf("json.Marshal() error = %v", err)
+	}
+	for _, field := range []string{`"LastPrice":1890.35`, `"NetChange":-4.65`, `"AverageTradePrice":1885.1`} {
+		if !strings.Contains(string(body), field) {
+			t.Errorf("raw tick JSON = %s, want %s", body, field)
+		}
+	}
+
+	// a later tick replaces the last one of the token
+	next := tick
+	next.LastPrice = 1891
+	recordRawTick(next)
+	if rawTick, _ := GetRawTick(tick.InstrumentToken); rawTick.Tick.LastPrice != next.LastPrice {
+		t.Errorf("GetRawTick(%d) last price = %v, want the later %v", tick.InstrumentToken, rawTick.Tick.LastPrice, next.LastPrice)
+	}
+	if _, ok := GetRawTick(tick.InstrumentToken + 1); ok {
+		t.Errorf("GetRawTick(%d) found a tick of another token", tick.InstrumentToken+1)
+	}
+}
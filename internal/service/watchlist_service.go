@@ -0,0 +1,95 @@
+// Package service contains the service layer for the Moneybots API
+package service
+
+import (
+	"context"
+	"errors"
+
+	"github.com/nsvirk/moneybotsapi/internal/models"
+	"github.com/nsvirk/moneybotsapi/internal/repository"
+	"gorm.io/gorm"
+)
+
+// WatchlistService is the service for the watchlists of the users
+type WatchlistService struct {
+	repo         *repository.WatchlistRepository
+	quoteService *QuoteService
+}
+
+// NewWatchlistService creates a new WatchlistService, quotes of the watchlists are fetched with quoteService
+func NewWatchlistService(db *gorm.DB, quoteService *QuoteService) *WatchlistService {
+	return &WatchlistService{
+		repo:         repository.NewWatchlistRepository(db),
+		quoteService: quoteService,
+	}
+}
+
+// WithContext returns a copy of the service whose database queries are bound to ctx
+func (s *WatchlistService) WithContext(ctx context.Context) *WatchlistService {
+	return &WatchlistService{
+		repo:         repository.NewWatchlistRepository(s.repo.DB.WithContext(ctx)),
+		quoteService: s.quoteService.WithContext(ctx),
+	}
+}
+
+// CreateWatchlist creates a watchlist of the user
+func (s *WatchlistService) CreateWatchlist(userId string, req models.WatchlistRequest) (models.WatchlistModel, error) {
+	watchlist := models.WatchlistModel{UserId: userId, Name: req.Name, Instruments: req.Instruments}
+	err := s.repo.CreateWatchlist(&watchlist)
+	return watchlist, err
+}
+
+// GetWatchlists returns the watchlists of the user
+func (s *WatchlistService) GetWatchlists(userId string) ([]models.WatchlistModel, error) {
+	return s.repo.GetWatchlists(userId)
+}
+
+// GetWatchlist returns the watchlist of the user, nil if the user has no such watchlist
+func (s *WatchlistService) GetWatchlist(userId string, id uint) (*models.WatchlistModel, error) {
+	watchlist, err := s.repo.GetWatchlist(userId, id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &watchlist, nil
+}
+
+// UpdateWatchlist replaces the name and instruments of the user's watchlist,
+// it returns nil if the user has no such watchlist
+func (s *WatchlistService) UpdateWatchlist(userId string, id uint, req models.WatchlistRequest) (*models.WatchlistModel, error) {
+	watchlist, err := s.GetWatchlist(userId, id)
+	if err != nil || watchlist == nil {
+		return nil, err
+	}
+	watchlist.Name = req.Name
+	watchlist.Instruments = req.Instruments
+	if _, err := s.repo.UpdateWatchlist(watchlist); err != nil {
+		return nil, err
+	}
+	return watchlist, nil
+}
+
+// DeleteWatchlist deletes the user's watchlist, it returns false if the user has no such watchlist
+func (s *WatchlistService) DeleteWatchlist(userId string, id uint) (bool, error) {
+	deleted, err := s.repo.DeleteWatchlist(userId, id)
+	return deleted > 0, err
+}
+
+// GetWatchlistQuotes returns the user's watchlist with the tick data of its instruments,
+// it returns a nil watchlist if the user has no such watchlist
+func (s *WatchlistService) GetWatchlistQuotes(userId string, id uint) (*models.WatchlistModel, map[string]*models.TickerData, error) {
+	watchlist, err := s.GetWatchlist(userId, id)
+	if err != nil || watchlist == nil {
+		return nil, nil, err
+	}
+	if len(watchlist.Instruments) == 0 {
+		return watchlist, map[string]*models.TickerData{}, nil
+	}
+	tickDataMap, err := s.quoteService.GetTickData(watchlist.Instruments)
+	if err != nil {
+		return nil, nil, err
+	}
+	return watchlist, tickDataMap, nil
+}
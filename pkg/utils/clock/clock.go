@@ -0,0 +1,54 @@
+// Package clock provides the current time, so time dependent logic can be driven by a fake clock
+package clock
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock tells the current time
+type Clock interface {
+	Now() time.Time
+}
+
+// Real is the Clock of the system time
+var Real Clock = realClock{}
+
+type realClock struct{}
+
+// Now returns the system time
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+// Fake is a Clock which only moves when set or advanced
+type Fake struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+// NewFake creates a new Fake clock at now
+func NewFake(now time.Time) *Fake {
+	return &Fake{now: now}
+}
+
+// Now returns the time of the fake clock
+func (f *Fake) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+// Set sets the time of the fake clock
+func (f *Fake) Set(now time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.now = now
+}
+
+// Advance moves the fake clock forward by d
+func (f *Fake) Advance(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.now = f.now.Add(d)
+}
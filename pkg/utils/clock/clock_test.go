@@ -0,0 +1,39 @@
+package clock
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFake(t *testing.T) {
+	start := time.Date(2024, 10, 15, 9, 15, 0, 0, time.UTC)
+	fake := NewFake(start)
+	if got := fake.Now(); !got.Equal(start) {
+		t.Fatalf("Now() = %v, want %v", got, start)
+	}
+
+	// the fake clock stands still while the system time moves
+	time.Sleep(time.Millisecond)
+	if got := fake.Now(); !got.Equal(start) {
+		t.Errorf("Now() = %v after a sleep, want %v", got, start)
+	}
+
+	fake.Advance(24 * time.Hour)
+	if want := start.AddDate(0, 0, 1); !fake.Now().Equal(want) {
+		t.Errorf("Now() = %v after Advance(24h), want %v", fake.Now(), want)
+	}
+
+	set := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	fake.Set(set)
+	if got := fake.Now(); !got.Equal(set) {
+		t.Errorf("Now() = %v after Set(), want %v", got, set)
+	}
+}
+
+func TestReal(t *testing.T) {
+	before := time.Now()
+	got := Real.Now()
+	if got.Before(before) || got.After(time.Now()) {
+		t.Errorf("Real.Now() = %v, want the system time", got)
+	}
+}
@@ -0,0 +1,183 @@
+// Package response contains response utility functions and types
+package response
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Sentinel errors handlers return, wrapped with a message by NewError
+var (
+	ErrNotFound     = errors.New("not found")
+	ErrUnauthorized = errors.New("unauthorized")
+	ErrRateLimited  = errors.New("rate limited")
+	ErrValidation   = errors.New("validation failed")
+	ErrInternal     = errors.New("internal error")
+	ErrUpstream     = errors.New("upstream error")
+)
+
+// Error codes of the error responses, stable for clients to switch on, unlike the messages
+const (
+	CodeAuthFailed         = "auth_failed"
+	CodePermissionDenied   = "permission_denied"
+	CodeValidationFailed   = "validation_failed"
+	CodeNotFound           = "not_found"
+	CodeRateLimited        = "rate_limited"
+	CodeUpstreamError      = "upstream_error"
+	CodeServiceUnavailable = "service_unavailable"
+	CodeNotSupported       = "not_supported"
+	CodeInternalError      = "internal_error"
+)
+
+// errorTypeCodes are the codes of the error types, the other types get the code of their http status
+var errorTypeCodes = map[string]string{
+	"AuthenticationException":     CodeAuthFailed,
+	"AuthorizationException":      CodeAuthFailed,
+	"PermissionException":         CodePermissionDenied,
+	"InputException":              CodeValidationFailed,
+	"DataNotFound":                CodeNotFound,
+	"RouteNotFoundException":      CodeNotFound,
+	"RateLimitException":          CodeRateLimited,
+	"LockoutException":            CodeRateLimited,
+	"UpstreamException":           CodeUpstreamError,
+	"TickerException":             CodeUpstreamError,
+	"ServiceUnavailableException": CodeServiceUnavailable,
+}
+
+// errorCode returns the code of an error response of the type and http status
+func errorCode(status int, errorType string) string {
+	if code, ok := errorTypeCodes[errorType]; ok {
+		return code
+	}
+	switch {
+	case status == http.StatusUnauthorized:
+		return CodeAuthFailed
+	case status == http.StatusForbidden:
+		return CodePermissionDenied
+	case status == http.StatusNotFound:
+		return CodeNotFound
+	case status == http.StatusTooManyRequests:
+		return CodeRateLimited
+	case status == http.StatusBadGateway || status == http.StatusGatewayTimeout:
+		return CodeUpstreamError
+	case status == http.StatusServiceUnavailable:
+		return CodeServiceUnavailable
+	case status == http.StatusNotImplemented:
+		return CodeNotSupported
+	case status >= http.StatusInternalServerError:
+		return CodeInternalError
+	}
+	return CodeValidationFailed
+}
+
+// errorMapping is the http status and error type of a sentinel error
+type errorMapping struct {
+	sentinel  error
+	status    int
+	errorType string
+}
+
+var errorMappings = []errorMapping{
+	{ErrNotFound, http.StatusNotFound, "DataNotFound"},
+	{ErrUnauthorized, http.StatusUnauthorized, "AuthorizationException"},
+	{ErrRateLimited, http.StatusTooManyRequests, "RateLimitException"},
+	{ErrValidation, http.StatusBadRequest, "InputException"},
+	{ErrInternal, http.StatusInternalServerError, "ServerException"},
+	{ErrUpstream, http.StatusBadGateway, "UpstreamException"},
+}
+
+// Error is a sentinel error with the message sent to the client
+type Error struct {
+	Kind    error
+	Message string
+}
+
+// NewError creates an error of the sentinel kind with the message sent to the client
+func NewError(kind error, message string) error {
+	return &Error{Kind: kind, Message: message}
+}
+
+func (e *Error) Error() string {
+	return e.Kind.Error() + ": " + e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Kind
+}
+
+// HandleError sends the error envelope for the error, mapping sentinel errors to their status
+// Errors of an unknown kind are sent as internal errors
+func HandleError(c echo.Context, err error) error {
+	message := err.Error()
+	var apiErr *Error
+	if errors.As(err, &apiErr) {
+		message = apiErr.Message
+	}
+
+	for _, mapping := range errorMappings {
+		if errors.Is(err, mapping.sentinel) {
+			return ErrorResponse(c, mapping.status, mapping.errorType, message)
+		}
+	}
+	return ErrorResponse(c, http.StatusInternalServerError, "ServerException", message)
+}
+
+// StatusOf returns the http status the error is sent with
+func StatusOf(err error) int {
+	var httpErr *echo.HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr.Code
+	}
+	for _, mapping := range errorMappings {
+		if errors.Is(err, mapping.sentinel) {
+			return mapping.status
+		}
+	}
+	return http.StatusInternalServerError
+}
+
+// HTTPErrorHandler returns an echo error handler sending all errors as error envelopes,
+// including echo's own errors such as unknown routes (404) and methods (405)
+// The router sets the `Allow` header for 405 before the handler runs
+func HTTPErrorHandler(e *echo.Echo) echo.HTTPErrorHandler {
+	return func(err error, c echo.Context) {
+		if c.Response().Committed {
+			return
+		}
+
+		var httpErr *echo.HTTPError
+		if !errors.As(err, &httpErr) {
+			err = HandleError(c, err)
+		} else {
+			err = ErrorResponse(c, httpErr.Code, httpErrorType(httpErr.Code), fmt.Sprint(httpErr.Message))
+		}
+		if err != nil {
+			e.Logger.Error(err)
+		}
+	}
+}
+
+// httpErrorType returns the error type for an http status
+func httpErrorType(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return "InputException"
+	case http.StatusUnauthorized:
+		return "AuthorizationException"
+	case http.StatusForbidden:
+		return "PermissionException"
+	case http.StatusNotFound:
+		return "RouteNotFoundException"
+	case http.StatusMethodNotAllowed:
+		return "MethodNotAllowedException"
+	case http.StatusTooManyRequests:
+		return "RateLimitException"
+	}
+	if status >= http.StatusInternalServerError {
+		return "ServerException"
+	}
+	return "RequestException"
+}
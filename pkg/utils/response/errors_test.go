@@ -0,0 +1,53 @@
+package response
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+)
+
+func TestHandleError(t *testing.T) {
+	tests := []struct {
+		name        string
+		err         error
+		wantStatus  int
+		wantType    string
+		wantCode    string
+		wantMessage string
+	}{
+		{name: "not found", err: NewError(ErrNotFound, "No instruments found"), wantStatus: http.StatusNotFound, wantType: "DataNotFound", wantCode: CodeNotFound, wantMessage: "No instruments found"},
+		{name: "unauthorized", err: NewError(ErrUnauthorized, "Invalid session"), wantStatus: http.StatusUnauthorized, wantType: "AuthorizationException", wantCode: CodeAuthFailed, wantMessage: "Invalid session"},
+		{name: "rate limited", err: NewError(ErrRateLimited, "Quota exceeded"), wantStatus: http.StatusTooManyRequests, wantType: "RateLimitException", wantCode: CodeRateLimited, wantMessage: "Quota exceeded"},
+		{name: "validation", err: NewError(ErrValidation, "`i` is required"), wantStatus: http.StatusBadRequest, wantType: "InputException", wantCode: CodeValidationFailed, wantMessage: "`i` is required"},
+		{name: "upstream hides the detail", err: NewError(ErrUpstream, "Kite is down"), wantStatus: http.StatusBadGateway, wantType: "UpstreamException", wantCode: CodeUpstreamError, wantMessage: genericErrorMessage},
+		{name: "internal hides the detail", err: NewError(ErrInternal, "pq: connection refused"), wantStatus: http.StatusInternalServerError, wantType: "ServerException", wantCode: CodeInternalError, wantMessage: genericErrorMessage},
+		{name: "wrapped sentinel", err: fmt.Errorf("fetching quotes: %w", ErrNotFound), wantStatus: http.StatusNotFound, wantType: "DataNotFound", wantCode: CodeNotFound, wantMessage: "fetching quotes: not found"},
+		{name: "unknown error", err: fmt.Errorf("boom"), wantStatus: http.StatusInternalServerError, wantType: "ServerException", wantCode: CodeInternalError, wantMessage: genericErrorMessage},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			c := echo.New().NewContext(httptest.NewRequest(http.MethodGet, "/", nil), rec)
+			if err := HandleError(c, tt.err); err != nil {
+				t.Fatalf("HandleError() error = %v", err)
+			}
+
+			var resp Response
+			if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("failed to decode the response: %v", err)
+			}
+			if rec.Code != tt.wantStatus || resp.ErrorType != tt.wantType || resp.Code != tt.wantCode || resp.Message != tt.wantMessage {
+				t.Errorf("HandleError() = %d %s %s %q, want %d %s %s %q", rec.Code, resp.ErrorType, resp.Code, resp.Message,
+					tt.wantStatus, tt.wantType, tt.wantCode, tt.wantMessage)
+			}
+			if got := StatusOf(tt.err); got != tt.wantStatus {
+				t.Errorf("StatusOf() = %d, want %d", got, tt.wantStatus)
+			}
+		})
+	}
+}
@@ -0,0 +1,72 @@
+// Package response contains response utility functions and types
+package response
+
+import (
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+// defaultLanguage is the language of the messages passed to ErrorResponse
+const defaultLanguage = "en"
+
+// messageCatalog holds the translations of the error messages, keyed by language and
+// then by the English message, which serves as the error code
+// Messages missing from a language are sent in English
+var messageCatalog = map[string]map[string]string{
+	"hi": {
+		genericErrorMessage:                               "एक आंतरिक त्रुटि हुई, रिपोर्ट करते समय कृपया request_id बताएं",
+		"Not Found":                                       "नहीं मिला",
+		"Method Not Allowed":                              "यह मेथड अनुमत नहीं है",
+		"Invalid request body":                            "अमान्य अनुरोध बॉडी",
+		"Invalid JSON body":                               "अमान्य JSON बॉडी",
+		"No instruments specified":                        "कोई इंस्ट्रूमेंट निर्दिष्ट नहीं है",
+		"Instruments array cannot be empty":               "इंस्ट्रूमेंट्स की सूची खाली नहीं हो सकती",
+		"Invalid `exchange` value":                        "अमान्य `exchange` मान",
+		"`exchange` is required":                          "`exchange` आवश्यक है",
+		"`index` is required":                             "`index` आवश्यक है",
+		"`user_id` is required":                           "`user_id` आवश्यक है",
+		"`password` is required":                          "`password` आवश्यक है",
+		"`enctoken` is required":                          "`enctoken` आवश्यक है",
+		"`q` is required":                                 "`q` आवश्यक है",
+		"Session not found":                               "सेशन नहीं मिला",
+		"Too many failed login attempts, try again later": "बहुत अधिक असफल लॉगिन प्रयास, बाद में पुनः प्रयास करें",
+		"admin access required":                           "एडमिन एक्सेस आवश्यक है",
+		"admin access is not allowed from this ip":        "इस IP से एडमिन एक्सेस की अनुमति नहीं है",
+	},
+}
+
+// requestLanguage returns the language of the error messages for the request
+// The `lang` query param takes precedence over the `Accept-Language` header, the
+// first supported language is used and English is the default
+func requestLanguage(c echo.Context) string {
+	var tags []string
+	if lang := c.QueryParam("lang"); lang != "" {
+		tags = append(tags, lang)
+	}
+	for _, part := range strings.Split(c.Request().Header.Get("Accept-Language"), ",") {
+		tags = append(tags, strings.Split(part, ";")[0])
+	}
+	for _, tag := range tags {
+		lang := strings.ToLower(strings.TrimSpace(strings.Split(strings.TrimSpace(tag), "-")[0]))
+		if lang == defaultLanguage {
+			return defaultLanguage
+		}
+		if _, ok := messageCatalog[lang]; ok {
+			return lang
+		}
+	}
+	return defaultLanguage
+}
+
+// localizeMessage translates an error message to the language of the request
+func localizeMessage(c echo.Context, message string) string {
+	lang := requestLanguage(c)
+	if lang == defaultLanguage {
+		return message
+	}
+	if translated, ok := messageCatalog[lang][message]; ok {
+		return translated
+	}
+	return message
+}
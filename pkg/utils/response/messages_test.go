@@ -0,0 +1,40 @@
+package response
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+)
+
+func TestLocalizeMessage(t *testing.T) {
+	tests := []struct {
+		name           string
+		query          string
+		acceptLanguage string
+		message        string
+		want           string
+	}{
+		{name: "no language", message: "Session not found", want: "Session not found"},
+		{name: "supported language", acceptLanguage: "hi-IN,hi;q=0.9,en;q=0.8", message: "Session not found", want: "सेशन नहीं मिला"},
+		{name: "unsupported language falls back to english", acceptLanguage: "fr-FR,fr;q=0.9", message: "Session not found", want: "Session not found"},
+		{name: "first supported language is used", acceptLanguage: "fr,hi;q=0.8", message: "Session not found", want: "सेशन नहीं मिला"},
+		{name: "english preferred over a later language", acceptLanguage: "en-GB,hi;q=0.8", message: "Session not found", want: "Session not found"},
+		{name: "query param takes precedence", query: "?lang=hi", acceptLanguage: "en", message: "Session not found", want: "सेशन नहीं मिला"},
+		{name: "untranslated message sent in english", acceptLanguage: "hi", message: "Invalid `mode` value", want: "Invalid `mode` value"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/"+tt.query, nil)
+			if tt.acceptLanguage != "" {
+				req.Header.Set("Accept-Language", tt.acceptLanguage)
+			}
+			c := echo.New().NewContext(req, httptest.NewRecorder())
+			if got := localizeMessage(c, tt.message); got != tt.want {
+				t.Errorf("localizeMessage() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -5,14 +5,29 @@ import (
 	"net/http"
 
 	"github.com/labstack/echo/v4"
+	"github.com/nsvirk/moneybotsapi/pkg/utils/zaplogger"
 )
 
+// verboseErrors controls if internal error details are sent to the client
+// Set to true in development, in production only a generic message and the request id are sent
+var verboseErrors = false
+
+// genericErrorMessage is the message sent for internal errors when verbose errors are disabled
+const genericErrorMessage = "An internal error occurred, please quote the request_id when reporting"
+
 // Response represents the standard API response structure
 type Response struct {
 	Status    string      `json:"status"`
 	Data      interface{} `json:"data,omitempty"`
 	ErrorType string      `json:"error_type,omitempty"`
+	Code      string      `json:"code,omitempty"`
 	Message   string      `json:"message,omitempty"`
+	RequestID string      `json:"request_id,omitempty"`
+}
+
+// SetVerboseErrors sets if internal error details are sent to the client
+func SetVerboseErrors(verbose bool) {
+	verboseErrors = verbose
 }
 
 // SuccessResponse sends a successful JSON response
@@ -23,11 +38,40 @@ func SuccessResponse(c echo.Context, data interface{}) error {
 	})
 }
 
-// ErrorResponse sends an error JSON response
+// ErrorResponse sends an error JSON response, with the code of the error type, see errorCode
+// Internal errors (5xx) are always logged with full detail, but the detail is only
+// sent to the client when verbose errors are enabled
+// 503 is an expected, retryable state and 501 a feature not available in this deployment,
+// so they are neither logged nor hidden
+// The message is localized to the language of the request, see requestLanguage
 func ErrorResponse(c echo.Context, httpStatus int, errorType, message string) error {
+	return ErrorDataResponse(c, httpStatus, errorType, message, nil)
+}
+
+// ErrorDataResponse sends an error JSON response like ErrorResponse, with the data explaining the error
+func ErrorDataResponse(c echo.Context, httpStatus int, errorType, message string, data interface{}) error {
+	requestID := c.Response().Header().Get(echo.HeaderXRequestID)
+
+	if httpStatus >= http.StatusInternalServerError && httpStatus != http.StatusServiceUnavailable &&
+		httpStatus != http.StatusNotImplemented {
+		zaplogger.Error(message, zaplogger.Fields{
+			"request_id": requestID,
+			"error_type": errorType,
+			"status":     httpStatus,
+			"method":     c.Request().Method,
+			"path":       c.Path(),
+		})
+		if !verboseErrors {
+			message = genericErrorMessage
+		}
+	}
+
 	return c.JSON(httpStatus, Response{
 		Status:    "error",
+		Data:      data,
 		ErrorType: errorType,
-		Message:   message,
+		Code:      errorCode(httpStatus, errorType),
+		Message:   localizeMessage(c, message),
+		RequestID: requestID,
 	})
 }
@@ -0,0 +1,65 @@
+package response
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/nsvirk/moneybotsapi/pkg/utils/zaplogger"
+)
+
+func TestErrorResponseVerbosity(t *testing.T) {
+	t.Cleanup(func() { SetVerboseErrors(false) })
+	dbErr := `failed to get instruments: ERROR: relation "instruments" does not exist (SQLSTATE 42P01)`
+
+	tests := []struct {
+		name        string
+		verbose     bool
+		status      int
+		message     string
+		wantMessage string
+		wantLogged  bool
+	}{
+		{name: "db error in development", verbose: true, status: http.StatusInternalServerError, message: dbErr, wantMessage: dbErr, wantLogged: true},
+		{name: "db error in production", status: http.StatusInternalServerError, message: dbErr, wantMessage: genericErrorMessage, wantLogged: true},
+		{name: "upstream error in production", status: http.StatusBadGateway, message: "kite: connection reset", wantMessage: genericErrorMessage, wantLogged: true},
+		{name: "unavailable in production", status: http.StatusServiceUnavailable, message: "Quotes are warming up", wantMessage: "Quotes are warming up"},
+		{name: "not implemented in production", status: http.StatusNotImplemented, message: "Not supported in memory mode", wantMessage: "Not supported in memory mode"},
+		{name: "client error in production", status: http.StatusBadRequest, message: "`i` is required", wantMessage: "`i` is required"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			SetVerboseErrors(tt.verbose)
+			rec := httptest.NewRecorder()
+			c := echo.New().NewContext(httptest.NewRequest(http.MethodGet, "/", nil), rec)
+			c.Response().Header().Set(echo.HeaderXRequestID, "req-"+tt.name)
+			logged := zaplogger.ErrorsLogged()
+
+			if err := ErrorResponse(c, tt.status, "ServerException", tt.message); err != nil {
+				t.Fatalf("ErrorResponse() error = %v", err)
+			}
+			var resp Response
+			if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("failed to decode the response: %v", err)
+			}
+			if rec.Code != tt.status || resp.Message != tt.wantMessage || resp.RequestID != "req-"+tt.name {
+				t.Errorf("ErrorResponse() = %d %q %q, want %d %q %q", rec.Code, resp.Message, resp.RequestID, tt.status, tt.wantMessage, "req-"+tt.name)
+			}
+
+			// the full detail always goes to the logs
+			gotLogged := zaplogger.ErrorsLogged() > logged
+			if gotLogged != tt.wantLogged {
+				t.Errorf("error logged = %v, want %v", gotLogged, tt.wantLogged)
+			}
+			if tt.wantLogged {
+				// the recent errors are listed most recent first
+				if last := zaplogger.RecentErrors()[0]; last.Message != tt.message {
+					t.Errorf("logged message = %q, want %q", last.Message, tt.message)
+				}
+			}
+		})
+	}
+}
@@ -4,7 +4,7 @@ import (
 	"time"
 )
 
-var StateTableName = "state"
+const StateTableName = "state"
 
 type StateEntry struct {
 	Key       string `gorm:"primaryKey"`
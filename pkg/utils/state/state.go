@@ -2,8 +2,10 @@ package state
 
 import (
 	"errors"
+	"time"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type State struct {
@@ -53,3 +55,22 @@ func (s *State) Set(key, value string) error {
 func (s *State) Delete(key string) error {
 	return s.db.Where("key = ?", key).Delete(&StateEntry{}).Error
 }
+
+// CompareAndSet sets the key to value only if it is still old, an absent key is ""
+// It returns false if the key was changed by someone else
+func (s *State) CompareAndSet(key, old, value string) (bool, error) {
+	result := s.db.Model(&StateEntry{}).
+		Where("key = ? AND value = ?", key, old).
+		Updates(map[string]interface{}{"value": value, "updated_at": time.Now()})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected > 0 || old != "" {
+		return result.RowsAffected > 0, nil
+	}
+	result = s.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&StateEntry{Key: key, Value: value})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
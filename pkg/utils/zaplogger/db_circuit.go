@@ -0,0 +1,117 @@
+// Package zaplogger contains utility functions and types
+package zaplogger
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/nsvirk/moneybotsapi/pkg/utils/clock"
+)
+
+// Circuit states of the database log sink
+const (
+	CircuitClosed   = "closed"
+	CircuitOpen     = "open"
+	CircuitHalfOpen = "half_open"
+)
+
+// dbCircuit stops the database log writes after repeated insert failures, so logging does not
+// add load to a struggling database. Once the cooldown has passed a single write probes the database,
+// closing the circuit on success and reopening it on failure
+type dbCircuit struct {
+	mu          sync.Mutex
+	maxFailures int
+	cooldown    time.Duration
+	state       string
+	failures    int
+	openedAt    time.Time
+	dropped     uint64
+	clock       clock.Clock
+}
+
+// DBCircuitStats are the state of the database log sink circuit and the log entries dropped while open
+type DBCircuitStats struct {
+	State    string     `json:"state"`
+	Failures int        `json:"failures"`
+	OpenedAt *time.Time `json:"opened_at,omitempty"`
+	Dropped  uint64     `json:"dropped"`
+}
+
+var logCircuit = newDBCircuit(5, 30*time.Second)
+
+func newDBCircuit(maxFailures int, cooldown time.Duration) *dbCircuit {
+	return &dbCircuit{maxFailures: maxFailures, cooldown: cooldown, state: CircuitClosed, clock: clock.Real}
+}
+
+// SetDBCircuit sets the consecutive insert failures which open the database log sink circuit and
+// how long it stays open, a maxFailures of 0 disables the circuit
+func SetDBCircuit(maxFailures int, cooldown time.Duration) {
+	logCircuit.mu.Lock()
+	defer logCircuit.mu.Unlock()
+	logCircuit.maxFailures = maxFailures
+	logCircuit.cooldown = cooldown
+}
+
+// GetDBCircuitStats returns the stats of the database log sink circuit
+func GetDBCircuitStats() DBCircuitStats {
+	return logCircuit.stats()
+}
+
+// allow reports if a write of n entries may be attempted, an open circuit turns half open after the cooldown
+// and lets a single probe write through, the entries not allowed are counted as dropped
+func (c *dbCircuit) allow(n int) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	switch c.state {
+	case CircuitOpen:
+		if c.clock.Now().Sub(c.openedAt) < c.cooldown {
+			c.dropped += uint64(n)
+			return false
+		}
+		c.state = CircuitHalfOpen
+		return true
+	case CircuitHalfOpen:
+		// a probe is in flight
+		c.dropped += uint64(n)
+		return false
+	}
+	return true
+}
+
+// record records the outcome of an attempted write
+func (c *dbCircuit) record(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err == nil {
+		if c.state != CircuitClosed {
+			fmt.Fprintf(os.Stderr, "zaplogger: database log sink recovered, %d log entries dropped\n", c.dropped)
+		}
+		c.state = CircuitClosed
+		c.failures = 0
+		return
+	}
+	c.failures++
+	if c.maxFailures <= 0 {
+		return
+	}
+	if c.state == CircuitHalfOpen || c.failures >= c.maxFailures {
+		if c.state == CircuitClosed {
+			fmt.Fprintf(os.Stderr, "zaplogger: database log sink paused for %s after %d failed inserts: %v\n", c.cooldown, c.failures, err)
+		}
+		c.state = CircuitOpen
+		c.openedAt = c.clock.Now()
+	}
+}
+
+func (c *dbCircuit) stats() DBCircuitStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stats := DBCircuitStats{State: c.state, Failures: c.failures, Dropped: c.dropped}
+	if c.state != CircuitClosed {
+		openedAt := c.openedAt
+		stats.OpenedAt = &openedAt
+	}
+	return stats
+}
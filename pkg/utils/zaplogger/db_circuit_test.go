@@ -0,0 +1,94 @@
+package zaplogger
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/nsvirk/moneybotsapi/pkg/utils/clock"
+)
+
+func TestDBCircuit(t *testing.T) {
+	opened := time.Date(2024, 10, 15, 9, 15, 0, 0, time.UTC)
+	fake := clock.NewFake(opened)
+	circuit := newDBCircuit(3, 30*time.Second)
+	circuit.clock = fake
+	insertErr := errors.New("insert failed")
+
+	steps := []struct {
+		name        string
+		before      func()
+		batch       int
+		err         error
+		wantAllowed bool
+		wantState   string
+		wantDropped uint64
+	}{
+		{name: "first failure", batch: 1, err: insertErr, wantAllowed: true, wantState: CircuitClosed},
+		{name: "second failure", batch: 1, err: insertErr, wantAllowed: true, wantState: CircuitClosed},
+		{name: "opened after max failures", batch: 1, err: insertErr, wantAllowed: true, wantState: CircuitOpen},
+		{name: "batch dropped while open", batch: 4, wantState: CircuitOpen, wantDropped: 4},
+		{name: "dropped until the cooldown", before: func() { fake.Advance(29 * time.Second) }, batch: 1, wantState: CircuitOpen, wantDropped: 5},
+		{name: "failed probe reopens", before: func() { fake.Advance(time.Second) }, batch: 1, err: insertErr, wantAllowed: true, wantState: CircuitOpen, wantDropped: 5},
+		{name: "reopened for another cooldown", before: func() { fake.Advance(29 * time.Second) }, batch: 2, wantState: CircuitOpen, wantDropped: 7},
+		{name: "probe recovers", before: func() { fake.Advance(time.Second) }, batch: 1, wantAllowed: true, wantState: CircuitClosed, wantDropped: 7},
+		{name: "writes after the recovery", batch: 1, wantAllowed: true, wantState: CircuitClosed, wantDropped: 7},
+	}
+
+	for _, step := range steps {
+		if step.before != nil {
+			step.before()
+		}
+		allowed := circuit.allow(step.batch)
+		if allowed {
+			circuit.record(step.err)
+		}
+		stats := circuit.stats()
+		if allowed != step.wantAllowed || stats.State != step.wantState || stats.Dropped != step.wantDropped {
+			t.Errorf("%s: allowed = %v, state = %s, dropped = %d, want %v, %s, %d",
+				step.name, allowed, stats.State, stats.Dropped, step.wantAllowed, step.wantState, step.wantDropped)
+		}
+		if (stats.OpenedAt != nil) != (stats.State != CircuitClosed) {
+			t.Errorf("%s: opened at = %v in the %s state", step.name, stats.OpenedAt, stats.State)
+		}
+	}
+	if stats := circuit.stats(); stats.Failures != 0 {
+		t.Errorf("failures = %d after the recovery, want 0", stats.Failures)
+	}
+}
+
+func TestDBCircuitHalfOpen(t *testing.T) {
+	fake := clock.NewFake(time.Date(2024, 10, 15, 9, 15, 0, 0, time.UTC))
+	circuit := newDBCircuit(1, time.Minute)
+	circuit.clock = fake
+	circuit.record(errors.New("insert failed"))
+	fake.Advance(time.Minute)
+
+	if !circuit.allow(1) {
+		t.Fatalf("allow() = false after the cooldown, want the probe let through")
+	}
+	if state := circuit.stats().State; state != CircuitHalfOpen {
+		t.Errorf("state = %s with the probe in flight, want %s", state, CircuitHalfOpen)
+	}
+	// only the probe reaches the database until its outcome is known
+	if circuit.allow(2) {
+		t.Errorf("allow() = true with the probe in flight, want false")
+	}
+	if dropped := circuit.stats().Dropped; dropped != 2 {
+		t.Errorf("dropped = %d with the probe in flight, want 2", dropped)
+	}
+}
+
+func TestSetDBCircuit(t *testing.T) {
+	testCircuit(t, 5, 30*time.Second, clock.Real)
+	SetDBCircuit(1, time.Hour)
+	logCircuit.record(errors.New("insert failed"))
+
+	stats := GetDBCircuitStats()
+	if stats.State != CircuitOpen || stats.Failures != 1 {
+		t.Fatalf("GetDBCircuitStats() = %+v, want open after 1 failure", stats)
+	}
+	if logCircuit.allow(1) {
+		t.Errorf("allow() = true within the hour cooldown, want false")
+	}
+}
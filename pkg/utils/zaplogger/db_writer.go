@@ -0,0 +1,167 @@
+// Package zaplogger contains utility functions and types
+package zaplogger
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Defaults of the database log writer, until SetDBWriter is called
+const (
+	defaultDBWriterQueueSize     = 10000
+	defaultDBWriterFlushInterval = time.Second
+)
+
+// dbWriterBatchSize is the max number of log entries inserted at a time
+const dbWriterBatchSize = 500
+
+// logWriter is the database log writer, nil until InitLogger is called
+var logWriter atomic.Pointer[DbWriter]
+
+// DbWriter implements zapcore.WriteSyncer interface for database logging using GORM
+// The entries are queued and inserted in batches by a background flusher, once a batch is queued
+// or every flush interval, when the queue is full the oldest entries are dropped for the new ones
+// An entry is inserted up to a flush interval after it is logged, so the logs are read by id and not by timestamp
+type DbWriter struct {
+	db      *gorm.DB
+	mu      sync.Mutex
+	queue   []LogModel
+	size    int
+	every   time.Duration
+	dropped uint64
+	failed  uint64
+	written uint64
+	flushMu sync.Mutex    // one flush at a time, so the entries are inserted in order
+	signal  chan struct{} // wakes the flusher once a batch is queued
+}
+
+// DBWriterStats are the stats of the database log writer queue
+type DBWriterStats struct {
+	Queued        int    `json:"queued"`
+	QueueSize     int    `json:"queue_size"`
+	FlushInterval string `json:"flush_interval"`
+	Dropped       uint64 `json:"dropped"`
+	Failed        uint64 `json:"failed"`
+	Written       uint64 `json:"written"`
+}
+
+func newDbWriter(db *gorm.DB, queueSize int, flushInterval time.Duration) *DbWriter {
+	return &DbWriter{
+		db:     db,
+		queue:  make([]LogModel, 0, min(queueSize, dbWriterBatchSize)),
+		size:   queueSize,
+		every:  flushInterval,
+		signal: make(chan struct{}, 1),
+	}
+}
+
+// SetDBWriter sets the max number of log entries queued for the database and how often they are flushed
+func SetDBWriter(queueSize int, flushInterval time.Duration) {
+	w := logWriter.Load()
+	if w == nil {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if queueSize > 0 {
+		w.size = queueSize
+	}
+	if flushInterval > 0 {
+		w.every = flushInterval
+	}
+}
+
+// GetDBWriterStats returns the stats of the database log writer, they are zero before InitLogger is called
+func GetDBWriterStats() DBWriterStats {
+	w := logWriter.Load()
+	if w == nil {
+		return DBWriterStats{}
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return DBWriterStats{
+		Queued:        len(w.queue),
+		QueueSize:     w.size,
+		FlushInterval: w.every.String(),
+		Dropped:       w.dropped,
+		Failed:        w.failed,
+		Written:       w.written,
+	}
+}
+
+// enqueue queues the entry, dropping the oldest entries when the queue is full
+func (w *DbWriter) enqueue(entry LogModel) {
+	w.mu.Lock()
+	if over := len(w.queue) - w.size + 1; over > 0 {
+		w.queue = w.queue[over:]
+		w.dropped += uint64(over)
+	}
+	w.queue = append(w.queue, entry)
+	queued := len(w.queue)
+	w.mu.Unlock()
+
+	if queued >= dbWriterBatchSize {
+		select {
+		case w.signal <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Sync inserts the queued entries, it is called on shutdown by Sync
+func (w *DbWriter) Sync() error {
+	w.flush()
+	return nil
+}
+
+// run flushes the queue every flush interval, or as soon as a batch is queued
+func (w *DbWriter) run() {
+	for {
+		w.mu.Lock()
+		every := w.every
+		w.mu.Unlock()
+
+		timer := time.NewTimer(every)
+		select {
+		case <-w.signal:
+			timer.Stop()
+		case <-timer.C:
+		}
+		w.flush()
+	}
+}
+
+// flush inserts the queued entries in batches, a batch which failed to insert is dropped
+func (w *DbWriter) flush() {
+	w.flushMu.Lock()
+	defer w.flushMu.Unlock()
+	for {
+		w.mu.Lock()
+		n := min(len(w.queue), dbWriterBatchSize)
+		batch := make([]LogModel, n)
+		copy(batch, w.queue[:n])
+		w.queue = w.queue[n:]
+		w.mu.Unlock()
+		if n == 0 {
+			return
+		}
+
+		// the batch is dropped while the circuit is open, it is still written to the console
+		if !logCircuit.allow(n) {
+			continue
+		}
+		err := w.db.CreateInBatches(batch, n).Error
+		logCircuit.record(err)
+
+		w.mu.Lock()
+		if err != nil {
+			w.failed += uint64(n)
+		} else {
+			w.written += uint64(n)
+		}
+		w.mu.Unlock()
+	}
+}
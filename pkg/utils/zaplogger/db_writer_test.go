@@ -0,0 +1,151 @@
+package zaplogger
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"github.com/nsvirk/moneybotsapi/pkg/utils/clock"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// logDB opens an in-memory database of the test, with the logs table when migrate is set
+func logDB(t *testing.T, migrate bool) *gorm.DB {
+	t.Helper()
+	name := strings.NewReplacer("/", "_", " ", "_").Replace(t.Name())
+	db, err := gorm.Open(sqlite.Open("file:"+name+"?mode=memory&cache=shared"), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("failed to open the database: %v", err)
+	}
+	// closing the last connection drops the database, so a repeated run of the test starts empty
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	if migrate {
+		if err := db.AutoMigrate(&LogModel{}); err != nil {
+			t.Fatalf("AutoMigrate() error = %v", err)
+		}
+	}
+	return db
+}
+
+// testCircuit replaces the database log sink circuit for the test
+func testCircuit(t *testing.T, maxFailures int, cooldown time.Duration, clk clock.Clock) *dbCircuit {
+	t.Helper()
+	circuit := newDBCircuit(maxFailures, cooldown)
+	circuit.clock = clk
+	saved := logCircuit
+	logCircuit = circuit
+	t.Cleanup(func() { logCircuit = saved })
+	return circuit
+}
+
+func TestDbWriterOverflow(t *testing.T) {
+	w := newDbWriter(logDB(t, true), 3, time.Hour)
+	for i := 0; i < 5; i++ {
+		w.enqueue(LogModel{Message: fmt.Sprintf("entry %d", i)})
+	}
+
+	var got []string
+	for _, entry := range w.queue {
+		got = append(got, entry.Message)
+	}
+	if want := "entry 2,entry 3,entry 4"; strings.Join(got, ",") != want {
+		t.Errorf("queue = %v, want %s", got, want)
+	}
+	if w.dropped != 2 {
+		t.Errorf("dropped = %d, want 2", w.dropped)
+	}
+}
+
+func TestDbWriterSync(t *testing.T) {
+	testCircuit(t, 5, time.Minute, clock.Real)
+	db := logDB(t, true)
+	w := newDbWriter(db, 100, time.Hour)
+	for i := 0; i < dbWriterBatchSize+10; i++ {
+		w.enqueue(LogModel{Timestamp: time.Now(), Level: "info", Message: fmt.Sprintf("entry %d", i)})
+	}
+
+	if err := w.Sync(); err != nil {
+		t.Fatalf("Sync() error = %v", err)
+	}
+	var count int64
+	if err := db.Model(&LogModel{}).Count(&count).Error; err != nil {
+		t.Fatalf("Count() error = %v", err)
+	}
+	// the queue holds 100 entries, the older ones were dropped
+	if count != 100 || w.written != 100 || len(w.queue) != 0 {
+		t.Errorf("after Sync() rows = %d, written = %d, queued = %d, want 100, 100, 0", count, w.written, len(w.queue))
+	}
+	var first LogModel
+	if err := db.Order("id").First(&first).Error; err != nil || first.Message != fmt.Sprintf("entry %d", dbWriterBatchSize-90) {
+		t.Errorf("first row = %q, %v, want the oldest entry kept", first.Message, err)
+	}
+}
+
+func TestDbWriterCircuit(t *testing.T) {
+	fake := clock.NewFake(time.Date(2024, 10, 15, 9, 15, 0, 0, time.UTC))
+	circuit := testCircuit(t, 2, time.Minute, fake)
+	// the logs table is missing, so every insert fails until it is created
+	db := logDB(t, false)
+	w := newDbWriter(db, 100, time.Hour)
+	write := func() {
+		w.enqueue(LogModel{Timestamp: fake.Now(), Level: "error", Message: "entry"})
+		_ = w.Sync()
+	}
+
+	steps := []struct {
+		name        string
+		before      func()
+		wantState   string
+		wantFailed  uint64
+		wantWritten uint64
+	}{
+		{name: "first failure", wantState: CircuitClosed, wantFailed: 1},
+		{name: "opened after max failures", wantState: CircuitOpen, wantFailed: 2},
+		{name: "dropped while open", wantState: CircuitOpen, wantFailed: 2},
+		{name: "failed probe reopens", before: func() { fake.Advance(time.Minute) }, wantState: CircuitOpen, wantFailed: 3},
+		{
+			name: "probe after the cooldown closes",
+			before: func() {
+				fake.Advance(time.Minute)
+				if err := db.AutoMigrate(&LogModel{}); err != nil {
+					t.Fatalf("AutoMigrate() error = %v", err)
+				}
+			},
+			wantState:   CircuitClosed,
+			wantFailed:  3,
+			wantWritten: 1,
+		},
+	}
+
+	for _, step := range steps {
+		if step.before != nil {
+			step.before()
+		}
+		write()
+		if state := circuit.stats().State; state != step.wantState || w.failed != step.wantFailed || w.written != step.wantWritten {
+			t.Errorf("%s: state = %s, failed = %d, written = %d, want %s, %d, %d",
+				step.name, state, w.failed, w.written, step.wantState, step.wantFailed, step.wantWritten)
+		}
+	}
+	if dropped := circuit.stats().Dropped; dropped != 1 {
+		t.Errorf("circuit dropped = %d, want 1", dropped)
+	}
+}
+
+func TestDBCircuitDisabled(t *testing.T) {
+	circuit := newDBCircuit(0, time.Minute)
+	for i := 0; i < 10; i++ {
+		circuit.record(errors.New("insert failed"))
+	}
+	if !circuit.allow(1) || circuit.stats().State != CircuitClosed {
+		t.Errorf("disabled circuit state = %s, want %s", circuit.stats().State, CircuitClosed)
+	}
+}
@@ -0,0 +1,123 @@
+// Package zaplogger contains utility functions and types
+package zaplogger
+
+import (
+	"sync"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// errorRingSize is the number of recent error events kept in memory
+const errorRingSize = 100
+
+// ErrorEvent is an error level log event kept for introspection
+type ErrorEvent struct {
+	Timestamp time.Time              `json:"timestamp"`
+	Level     string                 `json:"level"`
+	Caller    string                 `json:"caller"`
+	Message   string                 `json:"message"`
+	Fields    map[string]interface{} `json:"fields,omitempty"`
+}
+
+// errorRing is a bounded, lock safe ring buffer of the most recent error events
+type errorRing struct {
+	mu     sync.Mutex
+	events []ErrorEvent
+	next   int
+	full   bool
+	total  uint64
+}
+
+var recentErrors = newErrorRing(errorRingSize)
+
+func newErrorRing(size int) *errorRing {
+	return &errorRing{events: make([]ErrorEvent, size)}
+}
+
+// add adds an event, overwriting the oldest when the ring is full
+func (r *errorRing) add(event ErrorEvent) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events[r.next] = event
+	r.total++
+	r.next = (r.next + 1) % len(r.events)
+	if r.next == 0 {
+		r.full = true
+	}
+}
+
+// list returns the events, most recent first
+func (r *errorRing) list() []ErrorEvent {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	count := r.next
+	if r.full {
+		count = len(r.events)
+	}
+	events := make([]ErrorEvent, 0, count)
+	for i := 1; i <= count; i++ {
+		events = append(events, r.events[(r.next-i+len(r.events))%len(r.events)])
+	}
+	return events
+}
+
+// RecentErrors returns the most recent error level log events, most recent first
+func RecentErrors() []ErrorEvent {
+	return recentErrors.list()
+}
+
+// ErrorsLogged returns the number of error level log events since startup
+func ErrorsLogged() uint64 {
+	recentErrors.mu.Lock()
+	defer recentErrors.mu.Unlock()
+	return recentErrors.total
+}
+
+// errorRingCore is a zapcore.Core that taps error level entries into the ring
+type errorRingCore struct {
+	ring   *errorRing
+	fields []zapcore.Field
+}
+
+// Enabled implements zapcore.LevelEnabler
+func (c *errorRingCore) Enabled(level zapcore.Level) bool {
+	return level >= zapcore.ErrorLevel
+}
+
+// With implements zapcore.Core
+func (c *errorRingCore) With(fields []zapcore.Field) zapcore.Core {
+	return &errorRingCore{ring: c.ring, fields: append(append([]zapcore.Field{}, c.fields...), fields...)}
+}
+
+// Check implements zapcore.Core
+func (c *errorRingCore) Check(entry zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(entry.Level) {
+		return ce.AddCore(entry, c)
+	}
+	return ce
+}
+
+// Write implements zapcore.Core
+func (c *errorRingCore) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	encoder := zapcore.NewMapObjectEncoder()
+	for _, field := range c.fields {
+		field.AddTo(encoder)
+	}
+	for _, field := range fields {
+		field.AddTo(encoder)
+	}
+	c.ring.add(ErrorEvent{
+		Timestamp: entry.Time,
+		Level:     entry.Level.CapitalString(),
+		Caller:    entry.Caller.TrimmedPath(),
+		Message:   entry.Message,
+		Fields:    encoder.Fields,
+	})
+	return nil
+}
+
+// Sync implements zapcore.Core
+func (c *errorRingCore) Sync() error {
+	return nil
+}
@@ -0,0 +1,56 @@
+package zaplogger
+
+import (
+	"fmt"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestErrorRing(t *testing.T) {
+	tests := []struct {
+		name       string
+		size       int
+		errors     int
+		warnings   int
+		wantEvents int
+		wantNewest string
+		wantOldest string
+		wantLogged uint64
+	}{
+		{name: "empty", size: 3},
+		{name: "warnings are not captured", size: 3, warnings: 2},
+		{name: "under the size", size: 3, errors: 2, warnings: 1, wantEvents: 2, wantNewest: "error 2", wantOldest: "error 1", wantLogged: 2},
+		{name: "capped at the size", size: 3, errors: 5, wantEvents: 3, wantNewest: "error 5", wantOldest: "error 3", wantLogged: 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ring := newErrorRing(tt.size)
+			logger := zap.New(&errorRingCore{ring: ring}).With(zap.String("module", "test"))
+			for i := 1; i <= tt.warnings; i++ {
+				logger.Warn(fmt.Sprintf("warning %d", i))
+			}
+			for i := 1; i <= tt.errors; i++ {
+				logger.Error(fmt.Sprintf("error %d", i), zap.Int("attempt", i))
+			}
+
+			events := ring.list()
+			if len(events) != tt.wantEvents {
+				t.Fatalf("list() returned %d events, want %d", len(events), tt.wantEvents)
+			}
+			if ring.total != tt.wantLogged {
+				t.Errorf("total = %d, want %d", ring.total, tt.wantLogged)
+			}
+			if len(events) == 0 {
+				return
+			}
+			if newest, oldest := events[0].Message, events[len(events)-1].Message; newest != tt.wantNewest || oldest != tt.wantOldest {
+				t.Errorf("list() newest, oldest = %q, %q, want %q, %q", newest, oldest, tt.wantNewest, tt.wantOldest)
+			}
+			if events[0].Level != "ERROR" || events[0].Fields["module"] != "test" {
+				t.Errorf("list() newest event = %+v, want an ERROR with the module field", events[0])
+			}
+		})
+	}
+}
@@ -0,0 +1,53 @@
+// Package zaplogger contains utility functions and types
+package zaplogger
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// logMigrateAttempts is the number of attempts at migrating the log table
+const logMigrateAttempts = 5
+
+// logMigrateBackoff is the wait before the retry of a failed attempt, it grows with each attempt
+var logMigrateBackoff = 200 * time.Millisecond
+
+// transientSQLStates are the postgres error codes of a migration racing another instance's migration
+var transientSQLStates = map[string]bool{
+	"42P07": true, // duplicate_table, also raised for a duplicate index
+	"42710": true, // duplicate_object
+	"23505": true, // unique_violation, on the catalog when two instances create the same table
+	"40P01": true, // deadlock_detected
+	"40001": true, // serialization_failure
+	"55P03": true, // lock_not_available
+}
+
+// migrateLogTable migrates the log table, retrying when the migration races another instance's
+// A retry sees the table and indexes created by the other instance, so it does not create them again
+func migrateLogTable(db *gorm.DB) error {
+	var err error
+	for attempt := 1; attempt <= logMigrateAttempts; attempt++ {
+		if err = db.AutoMigrate(&LogModel{}); err == nil || !isTransientMigrateError(err) {
+			return err
+		}
+		if attempt < logMigrateAttempts {
+			fmt.Fprintf(os.Stderr, "zaplogger: log table migration raced another instance, retrying: %v\n", err)
+			time.Sleep(time.Duration(attempt) * logMigrateBackoff)
+		}
+	}
+	return err
+}
+
+// isTransientMigrateError checks if the migration error is from racing another instance's migration
+func isTransientMigrateError(err error) bool {
+	var sqlErr interface{ SQLState() string }
+	if errors.As(err, &sqlErr) {
+		return transientSQLStates[sqlErr.SQLState()]
+	}
+	return strings.Contains(err.Error(), "already exists")
+}
@@ -0,0 +1,116 @@
+package zaplogger
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// sqlStateError is an error of a driver with postgres SQL states
+type sqlStateError struct {
+	state string
+}
+
+func (e sqlStateError) Error() string    { return "ERROR: (SQLSTATE " + e.state + ")" }
+func (e sqlStateError) SQLState() string { return e.state }
+
+func TestIsTransientMigrateError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "duplicate table", err: sqlStateError{"42P07"}, want: true},
+		{name: "duplicate object", err: sqlStateError{"42710"}, want: true},
+		{name: "catalog unique violation", err: sqlStateError{"23505"}, want: true},
+		{name: "deadlock", err: sqlStateError{"40P01"}, want: true},
+		{name: "wrapped lock timeout", err: fmt.Errorf("migrate: %w", sqlStateError{"55P03"}), want: true},
+		{name: "permission denied", err: sqlStateError{"42501"}, want: false},
+		{name: "already exists without a SQL state", err: errors.New("index idx_app_logs already exists"), want: true},
+		{name: "other error without a SQL state", err: errors.New("connection refused"), want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isTransientMigrateError(tt.err); got != tt.want {
+				t.Errorf("isTransientMigrateError(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+// racingLogDB returns a log database whose first CREATE statement fails with err, the "already exists" case
+// also creates the log table from another connection first, as an instance starting at the same time would
+func racingLogDB(t *testing.T, err error) (*gorm.DB, *int) {
+	t.Helper()
+	db := logDB(t, false)
+	name := strings.NewReplacer("/", "_", " ", "_").Replace(t.Name())
+	other, openErr := gorm.Open(sqlite.Open("file:"+name+"?mode=memory&cache=shared"), &gorm.Config{Logger: logger.Discard})
+	if openErr != nil {
+		t.Fatalf("failed to open the database of the other instance: %v", openErr)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := other.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+
+	creates := 0
+	callbackErr := db.Callback().Raw().Before("gorm:raw").Register("test:race", func(tx *gorm.DB) {
+		if !strings.HasPrefix(strings.TrimSpace(tx.Statement.SQL.String()), "CREATE") {
+			return
+		}
+		creates++
+		if creates > 1 {
+			return
+		}
+		if isTransientMigrateError(err) {
+			if err := other.AutoMigrate(&LogModel{}); err != nil {
+				t.Errorf("AutoMigrate() of the other instance error = %v", err)
+			}
+		}
+		tx.AddError(err)
+	})
+	if callbackErr != nil {
+		t.Fatalf("failed to register the race callback: %v", callbackErr)
+	}
+	return db, &creates
+}
+
+func TestMigrateLogTableRace(t *testing.T) {
+	saved := logMigrateBackoff
+	logMigrateBackoff = time.Millisecond
+	t.Cleanup(func() { logMigrateBackoff = saved })
+
+	tests := []struct {
+		name        string
+		err         error
+		wantErr     bool
+		wantCreates int
+	}{
+		{name: "table already exists", err: sqlStateError{"42P07"}, wantCreates: 1},
+		{name: "already exists without a SQL state", err: errors.New(`table "_app_logs" already exists`), wantCreates: 1},
+		{name: "permanent error", err: sqlStateError{"42501"}, wantErr: true, wantCreates: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, creates := racingLogDB(t, tt.err)
+			err := migrateLogTable(db)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("migrateLogTable() error = %v, want error %v", err, tt.wantErr)
+			}
+			// the retry finds the table and index of the other instance, so it creates nothing again
+			if *creates != tt.wantCreates {
+				t.Errorf("CREATE statements = %d, want %d", *creates, tt.wantCreates)
+			}
+			if !tt.wantErr && !db.Migrator().HasTable(&LogModel{}) {
+				t.Errorf("log table missing after the raced migration")
+			}
+		})
+	}
+}
@@ -20,22 +20,20 @@ type Fields map[string]interface{}
 
 // LogModel represents the structure of the log entry in the database
 type LogModel struct {
-	ID        uint      `gorm:"primaryKey"`
-	Timestamp time.Time `gorm:"index"`
-	Level     string
-	Caller    string
-	Message   string
-	Fields    string // JSON string of additional fields
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	Timestamp time.Time `gorm:"index" json:"timestamp"`
+	Level     string    `json:"level"`
+	Caller    string    `json:"caller"`
+	Message   string    `json:"message"`
+	Fields    string    `json:"fields"` // JSON string of additional fields
 }
 
-// TableName specifies the table name for LogEntry
-func (LogModel) TableName() string {
-	return "_app_logs"
-}
+// LogsTableName is the name of the table for app logs
+const LogsTableName = "_app_logs"
 
-// DbWriter implements zapcore.WriteSyncer interface for database logging using GORM
-type DbWriter struct {
-	db *gorm.DB
+// TableName specifies the table name for LogModel
+func (LogModel) TableName() string {
+	return LogsTableName
 }
 
 // LogData represents the structure of the JSON log data// LogData represents the structure of the JSON log data
@@ -47,6 +45,7 @@ type LogData struct {
 	Fields    map[string]interface{} `json:"fields"`    // Additional fields
 }
 
+// Write parses the JSON log entry and queues it for the database
 func (w *DbWriter) Write(p []byte) (n int, err error) {
 	var logData LogData
 	err = json.Unmarshal(p, &logData)
@@ -86,17 +85,11 @@ func (w *DbWriter) Write(p []byte) (n int, err error) {
 		Fields:    string(fieldsJSON), // Store only the additional fields
 	}
 
-	result := w.db.Create(&logRecord)
-	if result.Error != nil {
-		return 0, result.Error
-	}
+	// the entry is written by the flusher, so a slow database does not stall the logging callers
+	w.enqueue(logRecord)
 	return len(p), nil
 }
 
-func (w *DbWriter) Sync() error {
-	return nil
-}
-
 // func customTimeEncoder(t time.Time, enc zapcore.PrimitiveArrayEncoder) {
 // 	enc.AppendString(t.Format("2006-01-02 15:04:05"))
 // }
@@ -123,7 +116,9 @@ func init() {
 	}
 
 	var err error
-	log, err = zapConfig.Build(zap.AddCallerSkip(1))
+	log, err = zapConfig.Build(zap.AddCallerSkip(1), zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+		return zapcore.NewTee(core, &errorRingCore{ring: recentErrors})
+	}))
 	if err != nil {
 		panic(err)
 	}
@@ -132,14 +127,16 @@ func init() {
 // InitLogger initializes the logger with both console and database output
 func InitLogger(db *gorm.DB) error {
 
-	// Create the table if it doesn't exist
-	err := db.AutoMigrate(&LogModel{})
+	// Create the table if it doesn't exist, tolerating other instances starting at the same time
+	err := migrateLogTable(db)
 	if err != nil {
 		return fmt.Errorf("failed to auto migrate: %v", err)
 	}
 
-	// Create DbWriter
-	dbWriter := &DbWriter{db: db}
+	// Create DbWriter, its queued entries are written in batches in the background
+	dbWriter := newDbWriter(db, defaultDBWriterQueueSize, defaultDBWriterFlushInterval)
+	go dbWriter.run()
+	logWriter.Store(dbWriter)
 
 	// Create encoders
 	consoleEncoder := zapcore.NewConsoleEncoder(zapConfig.EncoderConfig)
@@ -149,6 +146,7 @@ func InitLogger(db *gorm.DB) error {
 	core := zapcore.NewTee(
 		zapcore.NewCore(consoleEncoder, zapcore.AddSync(os.Stdout), zapConfig.Level),
 		zapcore.NewCore(dbEncoder, zapcore.AddSync(dbWriter), zapConfig.Level),
+		&errorRingCore{ring: recentErrors},
 	)
 
 	log = zap.New(core, zap.AddCaller(), zap.AddCallerSkip(1))
@@ -170,7 +168,12 @@ func SetLogLevel(level string) {
 	default:
 		l = zapcore.InfoLevel
 	}
-	log.Core().Enabled(l)
+	zapConfig.Level.SetLevel(l)
+}
+
+// GetLogLevel returns the effective logging level
+func GetLogLevel() string {
+	return zapConfig.Level.String()
 }
 
 // Info logs an info message
@@ -238,7 +241,7 @@ func getZapFields(fields Fields) []zap.Field {
 	return zapFields
 }
 
-// Sync flushes any buffered log entries
+// Sync flushes any buffered log entries, including the entries queued for the database
 func Sync() error {
 	return log.Sync()
 }